@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"sso/internal/lib/pwned"
+)
+
+// pwnedbloom builds the bloom filter of breached passwords for deployments
+// without access to the Pwned Passwords API. The input is a list of SHA-1
+// hashes in the format of the API, one HASH:COUNT per line.
+func main() {
+	var inputPath, outputPath string
+	var falsePositiveRate float64
+
+	flag.StringVar(&inputPath, "input", "", "path to the list of SHA-1 hashes")
+	flag.StringVar(&outputPath, "output", "", "path to write the bloom filter to")
+	flag.Float64Var(&falsePositiveRate, "fp", 0.001, "false positive rate")
+	flag.Parse()
+
+	if inputPath == "" {
+		panic("input path is required")
+	}
+
+	if outputPath == "" {
+		panic("output path is required")
+	}
+
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		panic("false positive rate must be between 0 and 1")
+	}
+
+	// the list is read twice, so that the filter is sized before filling it
+	// without holding the hashes in memory
+	var count uint64
+	if err := eachHash(inputPath, func([20]byte) { count++ }); err != nil {
+		panic(err)
+	}
+
+	filter := pwned.NewBloomFilter(count, falsePositiveRate)
+	if err := eachHash(inputPath, filter.Add); err != nil {
+		panic(err)
+	}
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		panic(err)
+	}
+
+	w := bufio.NewWriter(out)
+	if _, err = filter.WriteTo(w); err != nil {
+		panic(err)
+	}
+	if err = w.Flush(); err != nil {
+		panic(err)
+	}
+	if err = out.Close(); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("bloom filter of %d hashes written\n", count)
+}
+
+func eachHash(path string, fn func([20]byte)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		hexHash, _, _ := strings.Cut(text, ":")
+
+		var hash [20]byte
+		if len(hexHash) != 2*len(hash) {
+			return fmt.Errorf("line %d: invalid SHA-1 hash", line)
+		}
+		if _, err := hex.Decode(hash[:], []byte(hexHash)); err != nil {
+			return fmt.Errorf("line %d: invalid SHA-1 hash", line)
+		}
+
+		fn(hash)
+	}
+
+	return scanner.Err()
+}
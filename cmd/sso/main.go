@@ -1,11 +1,13 @@
 package main
 
 import (
+	"flag"
 	"log/slog"
 	"os"
 	"os/signal"
 	"sso/internal/app"
 	"sso/internal/config"
+	"sso/internal/lib/logger/sl"
 	"syscall"
 )
 
@@ -20,17 +22,68 @@ func main() {
 
 	log := setupLogger(cfg.Env)
 
+	// sso migrate up|down|status, sso migrate-data and sso seed, with the
+	// flags of sso before the command
+	if args := flag.Args(); len(args) > 0 {
+		switch {
+		case args[0] == "migrate" && len(args) == 2:
+			if err := app.Migrate(log, cfg, args[1]); err != nil {
+				log.Error("failed to migrate", sl.Err(err))
+				os.Exit(1)
+			}
+		case args[0] == "seed":
+			data, err := seedData(args[1:])
+			if err != nil {
+				log.Error("invalid seed", sl.Err(err))
+				os.Exit(2)
+			}
+			if err = app.Seed(log, cfg, data); err != nil {
+				log.Error("failed to seed", sl.Err(err))
+				os.Exit(1)
+			}
+		case args[0] == "migrate-data":
+			flags := flag.NewFlagSet("migrate-data", flag.ContinueOnError)
+			from := flags.String("from", "", "database to copy from, as driver:dsn")
+			to := flags.String("to", "", "database to copy into, as driver:dsn")
+			if err := flags.Parse(args[1:]); err != nil || *from == "" || *to == "" || flags.NArg() > 0 {
+				log.Error("usage: sso migrate-data --from driver:dsn --to driver:dsn")
+				os.Exit(2)
+			}
+			if err := app.MigrateData(log, cfg, *from, *to); err != nil {
+				log.Error("failed to migrate data", sl.Err(err))
+				os.Exit(1)
+			}
+		default:
+			log.Error("usage: sso [-config path] [-dev] [migrate up|down|status | migrate-data --from driver:dsn --to driver:dsn | seed [-file path] [-app name] [-admin-email email]]")
+			os.Exit(2)
+		}
+		return
+	}
+
 	log.Info("starting application")
 
-	application := app.New(log, cfg.Grpc.Port, cfg.StoragePath, cfg.TokenTTL)
+	application := app.New(log, cfg)
+	go application.Health.MustRun()
 	go application.GRPCServer.MustRun()
+	go application.HTTPServer.MustRun()
+	go application.Purger.MustRun()
+	go application.Outbox.MustRun()
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
 
 	<-stop
 
+	// load balancers stop routing to the instance before it stops serving
+	application.Health.Stop()
+	application.Outbox.Stop()
+	application.Purger.Stop()
+	application.HTTPServer.Stop()
 	application.GRPCServer.Stop()
+
+	if err := application.Storage.Close(); err != nil {
+		log.Error("failed to close storage", sl.Err(err))
+	}
 }
 
 func setupLogger(env string) *slog.Logger {
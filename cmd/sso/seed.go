@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"sso/internal/seed"
+)
+
+// seedData reads what sso seed creates from the YAML file of -file, or else
+// from -app and -admin-email. The password of the admin comes from
+// SSO_ADMIN_PASSWORD rather than a flag, so that it does not show up in
+// process lists, and is generated if that is empty.
+func seedData(args []string) (seed.Data, error) {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	file := flags.String("file", "", `path to a YAML file of apps and admins, "-" for stdin`)
+	appName := flags.String("app", "", "name of an app to create")
+	adminEmail := flags.String("admin-email", "", "email of an admin to create")
+	if err := flags.Parse(args); err != nil {
+		return seed.Data{}, err
+	}
+	if flags.NArg() > 0 {
+		return seed.Data{}, errors.New("seed takes no arguments besides its flags")
+	}
+
+	if *file != "" {
+		if *appName != "" || *adminEmail != "" {
+			return seed.Data{}, errors.New("-file excludes -app and -admin-email")
+		}
+		return seed.Load(*file)
+	}
+
+	var data seed.Data
+	if *appName != "" {
+		data.Apps = append(data.Apps, seed.App{Name: *appName})
+	}
+	if *adminEmail != "" {
+		data.Admins = append(data.Admins, seed.Admin{Email: *adminEmail, Password: os.Getenv("SSO_ADMIN_PASSWORD")})
+	}
+	if len(data.Apps) == 0 && len(data.Admins) == 0 {
+		return seed.Data{}, errors.New("nothing to seed, want -file, -app or -admin-email")
+	}
+
+	return data, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+type result struct {
+	Line             int    `json:"line"`
+	Email            string `json:"email"`
+	UserID           int64  `json:"user_id"`
+	PasswordReset    bool   `json:"password_reset"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// userimport imports users from another system through the admin API. The
+// input has a JSON object per line with the email, password_hash,
+// email_verified, username, display_name, app_metadata and user_metadata of
+// a user; users without a password hash are sent a password reset link.
+// Failed records are printed with their line numbers, and the command
+// exits with status 1 if any failed.
+func main() {
+	var baseURL, inputPath string
+
+	flag.StringVar(&baseURL, "url", "http://localhost:8082", "base URL of the HTTP API")
+	flag.StringVar(&inputPath, "input", "", `path to the users to import, "-" for stdin`)
+	flag.Parse()
+
+	// the token is not a flag, so that it does not show up in process lists
+	token := os.Getenv("SSO_ADMIN_TOKEN")
+	if token == "" {
+		panic("SSO_ADMIN_TOKEN is required")
+	}
+
+	if inputPath == "" {
+		panic("input path is required")
+	}
+
+	var input io.Reader = os.Stdin
+	if inputPath != "-" {
+		f, err := os.Open(inputPath)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/admin/users/import", input)
+	if err != nil {
+		panic(err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		panic(fmt.Sprintf("import failed with status %d: %s", resp.StatusCode, body))
+	}
+
+	var imported, resets, failed int
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var res result
+		if err = json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			panic(err)
+		}
+
+		if res.Error != "" {
+			failed++
+			fmt.Fprintf(os.Stderr, "line %d: %s: %s %s\n", res.Line, res.Email, res.Error, res.ErrorDescription)
+			continue
+		}
+
+		imported++
+		if res.PasswordReset {
+			resets++
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("imported %d users, %d sent a password reset link, %d failed\n", imported, resets, failed)
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -1,29 +1,683 @@
 package app
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
 	"sso/internal/app/grpcapp"
+	"sso/internal/app/healthapp"
+	"sso/internal/app/httpapp"
+	"sso/internal/app/outboxapp"
+	"sso/internal/app/purgeapp"
+	"sso/internal/config"
+	"sso/internal/lib/captcha"
+	"sso/internal/lib/email"
+	"sso/internal/lib/emailaddr"
+	"sso/internal/lib/idp"
+	"sso/internal/lib/ldap"
+	"sso/internal/lib/metrics"
+	"sso/internal/lib/password"
+	"sso/internal/lib/piibox"
+	"sso/internal/lib/policy"
+	"sso/internal/lib/publisher"
+	"sso/internal/lib/pwned"
+	"sso/internal/lib/secretbox"
+	"sso/internal/lib/sms"
+	"sso/internal/lib/tokens"
+	"sso/internal/lib/webhook"
 	"sso/internal/services/auth"
+	"sso/internal/services/management"
+	"sso/internal/services/outbox"
+	"sso/internal/storage"
+	"sso/internal/storage/cache"
+	"sso/internal/storage/factory"
+	"sso/internal/storage/memory"
+	"sso/internal/storage/pii"
+	"sso/internal/storage/redis"
+	"sso/internal/storage/retry"
 	"sso/internal/storage/sqlite"
+	"strings"
 	"time"
 )
 
 type App struct {
 	GRPCServer *grpcapp.App
+	Health     *healthapp.App
+	HTTPServer *httpapp.App
+	Purger     *purgeapp.App
+	Outbox     *outboxapp.App
+	Storage    factory.Storage
 }
 
-func New(log *slog.Logger, grpcPort int, storagePath string, tokenTTL time.Duration) *App {
+func New(log *slog.Logger, cfg *config.Config) *App {
 
-	storage, err := sqlite.New(storagePath)
+	// the memory driver starts out with the schema the migrations leave
+	if cfg.Storage.AutoMigrate && cfg.Storage.Driver != factory.DriverMemory {
+		if err := Migrate(log, cfg, "up"); err != nil {
+			panic(err)
+		}
+	}
+
+	emails := emailaddr.Normalizer{FoldGmail: cfg.Registration.FoldGmail}
+	box, err := newPIIBox(cfg.Storage.Encryption)
+	if err != nil {
+		panic(err)
+	}
+	storage, err := factory.New(storageConfig(cfg), emailKeys(box, emails))
+	if err != nil {
+		panic(err)
+	}
+	retries := metrics.NewStorageRetries()
+	if transient := factory.Transient(cfg.Storage.Driver); transient != nil && cfg.Storage.Retry.MaxAttempts > 1 {
+		storage = retry.New(storage, transient, retry.Policy{
+			MaxAttempts: cfg.Storage.Retry.MaxAttempts,
+			BaseDelay:   cfg.Storage.Retry.BaseDelay,
+			MaxDelay:    cfg.Storage.Retry.MaxDelay,
+			Budget:      cfg.Storage.Retry.Budget,
+		}, retries)
+	}
+
+	conflicts, err := storage.SyncEmailKeys(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	if len(conflicts) > 0 {
+		log.Warn("users have the email of other users, merge them", slog.Any("user_ids", conflicts))
+	}
+
+	hashed, err := storage.HashAppSecrets(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	if hashed > 0 {
+		log.Info("hashed plaintext app secrets", slog.Int("apps", hashed))
+	}
+
+	if cfg.Dev {
+		if err = seedDev(context.Background(), log, storage, cfg.Audience); err != nil {
+			panic(err)
+		}
+	}
+
+	memoryStorage := memory.New(emails)
+	var denylist auth.TokenDenylist = memoryStorage
+	var throttle auth.LoginThrottle = memoryStorage
+	var quotas auth.QuotaCounter = memoryStorage
+	var sessions tokens.SessionStore = storage
+	if cfg.Redis.Addr != "" {
+		redisStorage, err := redis.New(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
+		if err != nil {
+			panic(err)
+		}
+		denylist = redisStorage
+		throttle = redisStorage
+		quotas = redisStorage
+		sessions = redisStorage
+
+		if cfg.Cache.TTL > 0 {
+			storage = cache.New(log, storage, redisStorage, cfg.Cache.TTL)
+		}
+	} else if cfg.Cache.TTL > 0 {
+		panic("cache requires redis.addr")
+	}
+
+	// encryption wraps the cache too, which then keeps ciphertexts only
+	if box != nil {
+		encrypted := pii.New(storage, box)
+		sealed, err := encrypted.SealUsers(context.Background())
+		if err != nil {
+			panic(err)
+		}
+		if sealed > 0 {
+			log.Info("encrypted the plaintext emails and phones of users", slog.Int("users", sealed))
+		}
+		storage = encrypted
+	}
+
+	tokenManager, err := tokens.NewManager(cfg.Issuer, cfg.TokenFormat, cfg.TokenLeeway, sessions, storage)
+	if err != nil {
+		panic(err)
+	}
+
+	emailSender, err := newEmailSender(log, cfg.Email)
+	if err != nil {
+		panic(err)
+	}
+
+	secrets, err := secretbox.New(cfg.MFA.EncryptionKey)
+	if err != nil {
+		panic(err)
+	}
+
+	smsSender, err := newSMSSender(log, cfg.SMS)
+	if err != nil {
+		panic(err)
+	}
+
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthn.RPID,
+		RPDisplayName: cfg.WebAuthn.RPDisplayName,
+		RPOrigins:     cfg.WebAuthn.RPOrigins,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	passwordPolicy, err := password.New(password.Config{
+		MinLength:     cfg.PasswordPolicy.MinLength,
+		MaxLength:     cfg.PasswordPolicy.MaxLength,
+		RequireUpper:  cfg.PasswordPolicy.RequireUpper,
+		RequireLower:  cfg.PasswordPolicy.RequireLower,
+		RequireDigit:  cfg.PasswordPolicy.RequireDigit,
+		RequireSymbol: cfg.PasswordPolicy.RequireSymbol,
+		BannedFile:    cfg.PasswordPolicy.BannedFile,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	directories, err := newDirectories(cfg.LDAP)
+	if err != nil {
+		panic(err)
+	}
+
+	pwnedChecker, err := newPwnedChecker(cfg.PwnedPasswords)
+	if err != nil {
+		panic(err)
+	}
+
+	throttleBypass, err := parseNetworks(cfg.LoginThrottle.Bypass)
+	if err != nil {
+		panic(fmt.Errorf("login throttle bypass: %w", err))
+	}
+
+	hooks, err := newHooks(cfg.Hooks)
+	if err != nil {
+		panic(err)
+	}
+
+	switch cfg.AccountExpiry.Action {
+	case auth.ExpiryDisable, auth.ExpiryDelete:
+	default:
+		panic(fmt.Errorf("unknown account expiry action %q", cfg.AccountExpiry.Action))
+	}
+
+	authService := auth.New(
+		log,
+		storage,
+		storage,
+		storage,
+		storage,
+		storage,
+		denylist,
+		throttle,
+		quotas,
+		storage,
+		storage,
+		storage,
+		storage,
+		storage,
+		storage,
+		secrets,
+		storage,
+		webAuthn,
+		storage,
+		storage,
+		storage,
+		storage,
+		storage,
+		newIdentityProviders(cfg.Federation),
+		directories,
+		emailSender,
+		smsSender,
+		tokenManager,
+		passwordPolicy,
+		pwnedChecker,
+		hooks,
+		auth.Config{
+			TokenTTL:                   cfg.TokenTTL,
+			RefreshTokenTTL:            cfg.RefreshTokenTTL,
+			DeviceCodeTTL:              cfg.Device.CodeTTL,
+			DevicePollInterval:         cfg.Device.PollInterval,
+			VerificationURI:            cfg.Device.VerificationURI,
+			SlidingSessions:            cfg.Session.Sliding,
+			SessionMaxAge:              cfg.Session.MaxAge,
+			LoginHistorySize:           cfg.Session.LoginHistory,
+			PasswordResetTTL:           cfg.PasswordReset.TokenTTL,
+			PasswordResetURL:           cfg.PasswordReset.URL,
+			PasswordChangeTTL:          cfg.PasswordReset.ChangeTokenTTL,
+			EmailVerificationTTL:       cfg.EmailVerification.TokenTTL,
+			EmailVerificationURL:       cfg.EmailVerification.URL,
+			RequireVerifiedEmail:       cfg.EmailVerification.Required,
+			EmailChangeTTL:             cfg.EmailChange.TokenTTL,
+			EmailChangeURL:             cfg.EmailChange.URL,
+			MagicLinkTTL:               cfg.MagicLink.TokenTTL,
+			MagicLinkURL:               cfg.MagicLink.URL,
+			MagicLinkLimit:             cfg.MagicLink.Limit,
+			MagicLinkWindow:            cfg.MagicLink.Window,
+			MFAIssuer:                  cfg.MFA.Issuer,
+			MFAChallengeTTL:            cfg.MFA.ChallengeTTL,
+			MFAMaxAttempts:             cfg.MFA.MaxAttempts,
+			TrustedDeviceTTL:           cfg.MFA.TrustedDeviceTTL,
+			WebAuthnSessionTTL:         cfg.WebAuthn.SessionTTL,
+			SMSCodeTTL:                 cfg.SMS.CodeTTL,
+			SMSResendInterval:          cfg.SMS.ResendInterval,
+			SMSMaxAttempts:             cfg.SMS.MaxAttempts,
+			FederationStateTTL:         cfg.Federation.StateTTL,
+			FederationRedirectBaseURL:  cfg.Federation.RedirectBaseURL,
+			LockoutThreshold:           cfg.Lockout.Threshold,
+			LockoutWindow:              cfg.Lockout.Window,
+			LockoutDuration:            cfg.Lockout.Duration,
+			LoginThrottleEmailLimit:    cfg.LoginThrottle.EmailLimit,
+			LoginThrottleIPLimit:       cfg.LoginThrottle.IPLimit,
+			LoginThrottleWindow:        cfg.LoginThrottle.Window,
+			LoginThrottleBypass:        throttleBypass,
+			InvitationOnly:             cfg.Registration.InvitationOnly,
+			InvitationTTL:              cfg.Registration.InvitationTTL,
+			InvitationURL:              cfg.Registration.InvitationURL,
+			OrgInvitationURL:           cfg.Registration.OrgInvitationURL,
+			ImpersonationTTL:           cfg.Impersonation.TokenTTL,
+			AccountDeletionGracePeriod: cfg.AccountDeletion.GracePeriod,
+			ExpiredAccountAction:       cfg.AccountExpiry.Action,
+			DefaultRoles:               defaultRoles(cfg.RBAC.DefaultRoles),
+		},
+	)
+
+	challenge, err := newChallenge(cfg.Challenge)
 	if err != nil {
 		panic(err)
 	}
 
-	authService := auth.New(log, storage, storage, storage, tokenTTL)
+	healthApp := healthapp.New(log, storage, cfg.Health.CheckInterval, cfg.Health.Timeout)
+
+	grpcApp := grpcapp.New(log, authService, challenge, grpcapp.Authorization{
+		Authorizer:  authService,
+		Audience:    cfg.Audience,
+		MethodRoles: methodRoles(cfg.Grpc.MethodRoles),
+	}, healthApp, cfg.Grpc.Port)
+
+	policyEngine, err := newPolicyEngine(cfg.RBAC, storage)
+	if err != nil {
+		panic(err)
+	}
 
-	grpcApp := grpcapp.New(log, authService, grpcPort)
+	managementService := management.New(log, storage, storage, storage, storage, storage, storage, storage, storage, authService, authService, storage, storage, storage, policyEngine, management.Config{
+		DeletedUserRetention: cfg.AccountDeletion.GracePeriod,
+		LoginHistorySize:     cfg.Session.LoginHistory,
+		PermissionCacheTTL:   cfg.RBAC.PermissionCacheTTL,
+	})
+
+	var metricsHandler http.Handler
+	if cfg.Metrics.Enabled {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+			metrics.NewDBStatsCollector(storage, "primary"),
+			retries,
+		)
+		for i := range cfg.Storage.Replicas {
+			replica := metrics.DBStatsFunc(func() sql.DBStats { return storage.ReplicaStats()[i] })
+			registry.MustRegister(metrics.NewDBStatsCollector(replica, fmt.Sprintf("replica-%d", i)))
+		}
+		metricsHandler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	}
+
+	// the admin API is better off with an app of its own, so that no token
+	// users get for the account endpoints lets admins in
+	adminAudience := cfg.AdminAudience
+	if adminAudience == "" {
+		adminAudience = cfg.Audience
+	}
+
+	httpApp := httpapp.New(log, authService, managementService, metricsHandler, healthApp,
+		cfg.Audience, adminAudience, cfg.HTTP.Port, cfg.HTTP.Timeout)
+
+	purgeApp := purgeapp.New(log, authService, cfg.AccountDeletion.PurgeInterval)
+
+	eventPublisher, err := newPublisher(log, cfg.Outbox)
+	if err != nil {
+		panic(err)
+	}
+	relay := outbox.New(log, storage, eventPublisher, outbox.Config{
+		BatchSize: cfg.Outbox.BatchSize,
+		Retention: cfg.Outbox.Retention,
+	})
+	outboxApp := outboxapp.New(log, relay, cfg.Outbox.Interval)
 
 	return &App{
 		GRPCServer: grpcApp,
+		Health:     healthApp,
+		HTTPServer: httpApp,
+		Purger:     purgeApp,
+		Outbox:     outboxApp,
+		Storage:    storage,
 	}
 }
+
+// storageConfig returns the storage the config selects. The sqlite driver
+// falls back to storage_path when no dsn is set.
+func storageConfig(cfg *config.Config) factory.Config {
+	dsn := cfg.Storage.DSN
+	if dsn == "" && cfg.Storage.Driver == factory.DriverSQLite {
+		dsn = cfg.StoragePath
+	}
+
+	return factory.Config{
+		Driver: cfg.Storage.Driver,
+		DSN:    dsn,
+		Pool: storage.PoolConfig{
+			MaxOpenConns:    cfg.Storage.MaxOpenConns,
+			MaxIdleConns:    cfg.Storage.MaxIdleConns,
+			ConnMaxLifetime: cfg.Storage.ConnMaxLifetime,
+			ConnMaxIdleTime: cfg.Storage.ConnMaxIdleTime,
+			QueryTimeout:    cfg.Storage.QueryTimeout,
+		},
+		Replicas: storage.ReplicaConfig{
+			DSNs:          cfg.Storage.Replicas,
+			CheckInterval: cfg.Storage.ReplicaCheckInterval,
+		},
+		SQLite: sqlite.Pragmas{
+			JournalMode: cfg.Storage.SQLite.JournalMode,
+			Synchronous: cfg.Storage.SQLite.Synchronous,
+			BusyTimeout: cfg.Storage.SQLite.BusyTimeout,
+			ForeignKeys: cfg.Storage.SQLite.ForeignKeys,
+			TxLock:      cfg.Storage.SQLite.TxLock,
+		},
+	}
+}
+
+// newPIIBox returns the box the storage encrypts personal data with, or nil
+// if encryption is off.
+func newPIIBox(cfg config.StorageEncryptionConfig) (*piibox.Box, error) {
+	key := cfg.Key
+	if cfg.KeyFile != "" {
+		if key != "" {
+			return nil, fmt.Errorf("storage.encryption has both key and key_file")
+		}
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("storage.encryption.key_file: %w", err)
+		}
+		key = strings.TrimSpace(string(data))
+	}
+	if key == "" {
+		return nil, nil
+	}
+
+	return piibox.New(key)
+}
+
+// emailKeys returns what the storage derives the email keys of users with:
+// the keys of the normalizer, blind indexed if personal data is encrypted.
+func emailKeys(box *piibox.Box, emails emailaddr.Normalizer) factory.EmailNormalizer {
+	if box == nil {
+		return emails
+	}
+
+	return pii.NewEmailIndex(box, emails)
+}
+
+func newPublisher(log *slog.Logger, cfg config.OutboxConfig) (outbox.Publisher, error) {
+	switch cfg.Publisher {
+	case "log":
+		return publisher.NewLog(log), nil
+	case "webhook":
+		if cfg.Webhook.URL == "" {
+			return nil, fmt.Errorf("the webhook publisher requires outbox.webhook.url")
+		}
+		timeout := cfg.Webhook.Timeout
+		if timeout <= 0 {
+			timeout = 5 * time.Second
+		}
+		return publisher.NewWebhook(cfg.Webhook.URL, cfg.Webhook.Secret, timeout), nil
+	case "kafka":
+		if len(cfg.Kafka.Brokers) == 0 {
+			return nil, fmt.Errorf("the kafka publisher requires outbox.kafka.brokers")
+		}
+		return publisher.NewKafka(cfg.Kafka.Brokers, cfg.Kafka.Topic), nil
+	default:
+		return nil, fmt.Errorf("unknown outbox publisher %q", cfg.Publisher)
+	}
+}
+
+func newEmailSender(log *slog.Logger, cfg config.EmailConfig) (auth.EmailSender, error) {
+	switch cfg.Sender {
+	case "log":
+		return email.NewLog(log), nil
+	case "file":
+		return email.NewFile(cfg.Dir, cfg.From)
+	case "smtp":
+		return email.NewSMTP(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unknown email sender %q", cfg.Sender)
+	}
+}
+
+func newSMSSender(log *slog.Logger, cfg config.SMSConfig) (auth.SMSSender, error) {
+	switch cfg.Sender {
+	case "log":
+		return sms.NewLog(log), nil
+	case "file":
+		return sms.NewFile(cfg.Dir)
+	case "twilio":
+		return sms.NewTwilio(cfg.Twilio.AccountSID, cfg.Twilio.AuthToken, cfg.Twilio.From), nil
+	default:
+		return nil, fmt.Errorf("unknown sms sender %q", cfg.Sender)
+	}
+}
+
+// newPwnedChecker returns nil if no source of breached passwords is
+// configured.
+func newPwnedChecker(cfg config.PwnedPasswordsConfig) (auth.PwnedChecker, error) {
+	pwnedCfg := pwned.Config{
+		BloomFile: cfg.BloomFile,
+		Timeout:   cfg.Timeout,
+	}
+	if cfg.HIBP {
+		pwnedCfg.RangeURL = cfg.HIBPURL
+	}
+
+	checker, err := pwned.New(pwnedCfg)
+	if err != nil {
+		return nil, err
+	}
+	if !checker.Enabled() {
+		return nil, nil
+	}
+
+	return checker, nil
+}
+
+func newPolicyEngine(cfg config.RBACConfig, rules policy.RuleProvider) (policy.Engine, error) {
+	switch cfg.PolicyEngine {
+	case "":
+		return nil, nil
+	case "casbin":
+		return policy.NewCasbin(context.Background(), cfg.PolicyModel, rules)
+	default:
+		return nil, fmt.Errorf("unknown policy engine %q", cfg.PolicyEngine)
+	}
+}
+
+// adminMethods are the gRPC methods only admins call. The management
+// methods of the protos go here as they are added.
+var adminMethods []string
+
+// methodRoles returns the roles the gRPC methods require, those of the
+// config on top of the admin role for adminMethods.
+func methodRoles(configured map[string][]string) map[string][]string {
+	roles := make(map[string][]string, len(adminMethods)+len(configured))
+	for _, method := range adminMethods {
+		roles[method] = []string{auth.RoleAdmin}
+	}
+	for method, required := range configured {
+		roles[method] = append(roles[method], required...)
+	}
+
+	return roles
+}
+
+func defaultRoles(configured []config.DefaultRoleConfig) []auth.DefaultRole {
+	roles := make([]auth.DefaultRole, 0, len(configured))
+	for _, role := range configured {
+		roles = append(roles, auth.DefaultRole{Role: role.Role, AppID: role.AppID})
+	}
+
+	return roles
+}
+
+func newChallenge(cfg config.ChallengeConfig) (grpcapp.Challenge, error) {
+	var verifier *captcha.Verifier
+	switch cfg.Provider {
+	case "":
+		return grpcapp.Challenge{}, nil
+	case "recaptcha":
+		verifier = captcha.NewRecaptcha(cfg.Secret, cfg.MinScore)
+	case "hcaptcha":
+		verifier = captcha.NewHCaptcha(cfg.Secret)
+	case "turnstile":
+		verifier = captcha.NewTurnstile(cfg.Secret)
+	default:
+		return grpcapp.Challenge{}, fmt.Errorf("unknown challenge provider %q", cfg.Provider)
+	}
+
+	if cfg.Secret == "" {
+		return grpcapp.Challenge{}, fmt.Errorf("challenge provider %q has no secret", cfg.Provider)
+	}
+
+	if cfg.VerifyURL != "" {
+		verifier = verifier.WithURL(cfg.VerifyURL)
+	}
+
+	bypass, err := parseNetworks(cfg.Bypass)
+	if err != nil {
+		return grpcapp.Challenge{}, fmt.Errorf("challenge bypass: %w", err)
+	}
+
+	return grpcapp.Challenge{Verifier: verifier, Bypass: bypass}, nil
+}
+
+// newHooks registers the email domain checks and webhooks of the config.
+func newHooks(cfg config.HooksConfig) (*auth.Hooks, error) {
+	hooks := &auth.Hooks{FailOpen: cfg.FailOpen}
+
+	if len(cfg.AllowedEmailDomains) > 0 {
+		hooks.OnPreRegister(auth.AllowEmailDomains(cfg.AllowedEmailDomains))
+	}
+	if len(cfg.BlockedEmailDomains) > 0 {
+		hooks.OnPreRegister(auth.BlockEmailDomains(cfg.BlockedEmailDomains))
+	}
+
+	for _, hook := range cfg.Webhooks {
+		if hook.URL == "" || hook.Secret == "" {
+			return nil, fmt.Errorf("webhook %q needs a url and a secret", hook.URL)
+		}
+
+		timeout := hook.Timeout
+		if timeout <= 0 {
+			timeout = 5 * time.Second
+		}
+		client := webhook.New(hook.URL, hook.Secret, timeout)
+
+		for _, event := range hook.Events {
+			switch event {
+			case auth.HookPreRegister:
+				hooks.OnPreRegister(auth.PreRegisterWebhook(client))
+			case auth.HookPostRegister:
+				hooks.OnPostRegister(auth.PostRegisterWebhook(client))
+			case auth.HookPreLogin:
+				hooks.OnPreLogin(auth.PreLoginWebhook(client))
+			case auth.HookOrgMembership:
+				hooks.OnOrgMembership(auth.OrgMembershipWebhook(client))
+			default:
+				return nil, fmt.Errorf("webhook %q: unknown event %q", hook.URL, event)
+			}
+		}
+	}
+
+	return hooks, nil
+}
+
+// parseNetworks parses networks given in CIDR notation.
+func parseNetworks(networks []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(networks))
+	for _, network := range networks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// newIdentityProviders returns the configured upstream identity providers
+// by name.
+func newIdentityProviders(cfg config.FederationConfig) map[string]auth.IdentityProvider {
+	providers := make(map[string]auth.IdentityProvider)
+
+	if cfg.Google.ClientID != "" {
+		var issuers []string
+		if cfg.Google.Issuer != "" {
+			issuers = []string{cfg.Google.Issuer}
+		}
+
+		providers["google"] = idp.NewGoogle(idp.Config{
+			ClientID:     cfg.Google.ClientID,
+			ClientSecret: cfg.Google.ClientSecret,
+			RedirectURL:  cfg.Google.RedirectURL,
+			Issuers:      issuers,
+			AuthURL:      cfg.Google.AuthURL,
+			TokenURL:     cfg.Google.TokenURL,
+			JWKSURL:      cfg.Google.JWKSURL,
+		})
+	}
+
+	return providers
+}
+
+// newDirectories returns the directories users log in against by app id.
+func newDirectories(cfg config.LDAPConfig) (map[int]auth.AppDirectory, error) {
+	directories := make(map[int]auth.AppDirectory, len(cfg.Apps))
+
+	for appID, name := range cfg.Apps {
+		dir, ok := cfg.Directories[name]
+		if !ok {
+			return nil, fmt.Errorf("app %d uses unknown directory %q", appID, name)
+		}
+
+		for group, role := range dir.Roles {
+			if role != auth.RoleAdmin {
+				return nil, fmt.Errorf("directory %q maps group %q to unknown role %q", name, group, role)
+			}
+		}
+
+		directories[appID] = auth.AppDirectory{
+			Directory: ldap.New(ldap.Config{
+				URL:            dir.URL,
+				StartTLS:       dir.StartTLS,
+				BindDN:         dir.BindDN,
+				BindPassword:   dir.BindPassword,
+				BaseDN:         dir.BaseDN,
+				UserFilter:     dir.UserFilter,
+				EmailAttribute: dir.EmailAttribute,
+				GroupAttribute: dir.GroupAttribute,
+				Timeout:        dir.Timeout,
+			}),
+			Provision: dir.Provision,
+			Roles:     dir.Roles,
+		}
+	}
+
+	return directories, nil
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/internal/seed"
+	"sso/internal/storage/factory"
+)
+
+const devAdminEmail = "admin@sso.dev"
+
+// seedDev creates the app and the admin the -dev mode starts with, and logs
+// the secret of the one and the password of the other, which are generated
+// anew on every start. The app is named after the audience of the APIs, so
+// its tokens are the ones they take.
+func seedDev(ctx context.Context, log *slog.Logger, storage factory.Storage, appName string) error {
+	const op = "app.seedDev"
+
+	res, err := seed.Apply(ctx, storage, seed.Data{
+		Apps:   []seed.App{{Name: appName, Scopes: []string{"profile", "email"}}},
+		Admins: []seed.Admin{{Email: devAdminEmail}},
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Warn("running in dev mode, nothing is persisted",
+		slog.Int("app_id", res.Apps[0].ID),
+		slog.String("app_secret", res.Apps[0].Secret),
+		slog.String("admin_email", devAdminEmail),
+		slog.String("admin_password", res.Admins[0].Password),
+	)
+
+	return nil
+}
@@ -6,6 +6,8 @@ import (
 	"google.golang.org/grpc"
 	"log/slog"
 	"net"
+	"net/netip"
+	"sso/internal/domain/models"
 	authgrpc "sso/internal/grpc/auth"
 )
 
@@ -15,23 +17,62 @@ type App struct {
 	port       int
 }
 
+// HealthService serves the gRPC health protocol for the services of the
+// server.
+type HealthService interface {
+	Register(server *grpc.Server)
+}
+
 type Auth interface {
 	Login(ctx context.Context,
 		email string,
 		password string,
 		appID int,
-	) (token string, err error)
+		scopes []string,
+	) (models.TokenPair, error)
 	RegisterNewUser(ctx context.Context,
 		email string,
 		password string,
+		profile models.UserProfile,
 	) (userID int64, err error)
 	IsAdmin(ctx context.Context, userID int64) (bool, error)
+	authgrpc.QuotaChecker
 }
 
-func New(log *slog.Logger, authService Auth, port int) *App {
-	gRPCServer := grpc.NewServer()
+// Challenge makes Register and Login require a solved challenge. A nil
+// Verifier disables it.
+type Challenge struct {
+	Verifier authgrpc.ChallengeVerifier
+	// Bypass lists the networks of trusted callers that are not challenged.
+	Bypass []netip.Prefix
+}
+
+// Authorization makes the methods of MethodRoles, keyed by full method name,
+// require a token issued for Audience, of a user with one of the roles, see
+// authgrpc.AuthzInterceptor. Other methods are open.
+type Authorization struct {
+	Authorizer  authgrpc.Authorizer
+	Audience    string
+	MethodRoles map[string][]string
+}
+
+func New(log *slog.Logger, authService Auth, challenge Challenge, authorization Authorization, health HealthService, port int) *App {
+	interceptors := []grpc.UnaryServerInterceptor{authgrpc.ClientInfoInterceptor()}
+	if len(authorization.MethodRoles) > 0 {
+		interceptors = append(interceptors,
+			authgrpc.AuthzInterceptor(log, authorization.Authorizer, authorization.Audience, authorization.MethodRoles),
+		)
+	}
+	if challenge.Verifier != nil {
+		interceptors = append(interceptors, authgrpc.ChallengeInterceptor(log, challenge.Verifier, challenge.Bypass))
+	}
+	// requests failing the challenge do not use up the quotas of apps
+	interceptors = append(interceptors, authgrpc.QuotaInterceptor(log, authService))
+
+	gRPCServer := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
 
 	authgrpc.RegisterServer(gRPCServer, authService)
+	health.Register(gRPCServer)
 
 	return &App{
 		log:        log,
@@ -0,0 +1,170 @@
+package healthapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrStopping = errors.New("service is stopping")
+
+// App checks that the storage can be reached every interval, and reports the
+// gRPC services as not serving while it can not, so that load balancers stop
+// routing to the instance until it can again.
+type App struct {
+	log      *slog.Logger
+	pinger   Pinger
+	interval time.Duration
+	timeout  time.Duration
+	server   *health.Server
+
+	mu       sync.Mutex
+	services []string
+	ready    bool
+	stopping atomic.Bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// New reports the services as serving until a check fails, as the storage
+// was reached at startup. Each check fails after timeout, zero leaving it to
+// the deadline of the caller.
+func New(log *slog.Logger, pinger Pinger, interval time.Duration, timeout time.Duration) *App {
+	return &App{
+		log:      log,
+		pinger:   pinger,
+		interval: interval,
+		timeout:  timeout,
+		server:   health.NewServer(),
+		services: []string{""},
+		ready:    true,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Register serves the gRPC health protocol on the server, for the server as
+// a whole and for each service registered on it before.
+func (a *App) Register(server *grpc.Server) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for name := range server.GetServiceInfo() {
+		a.services = append(a.services, name)
+	}
+	a.setStatus()
+
+	healthgrpc.RegisterHealthServer(server, a.server)
+}
+
+// Check pings the storage, updates the status of the services with the
+// result and returns it. It fails with ErrStopping once Stop is called.
+func (a *App) Check(ctx context.Context) error {
+	const op = "app.healthapp.Check"
+
+	if a.stopping.Load() {
+		return fmt.Errorf("%s: %w", op, ErrStopping)
+	}
+
+	if a.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.timeout)
+		defer cancel()
+	}
+
+	err := a.pinger.Ping(ctx)
+	a.setReady(err)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (a *App) setReady(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ready := err == nil
+	if a.ready == ready {
+		return
+	}
+	a.ready = ready
+
+	if ready {
+		a.log.Info("storage is reachable again, serving")
+	} else {
+		a.log.Error("storage is unreachable, not serving", sl.Err(err))
+	}
+	a.setStatus()
+}
+
+func (a *App) setStatus() {
+	status := healthgrpc.HealthCheckResponse_NOT_SERVING
+	if a.ready {
+		status = healthgrpc.HealthCheckResponse_SERVING
+	}
+	for _, service := range a.services {
+		a.server.SetServingStatus(service, status)
+	}
+}
+
+// MustRun checks the storage every interval until Stop is called.
+func (a *App) MustRun() {
+	const op = "app.healthapp.Run"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Duration("interval", a.interval),
+	)
+
+	defer close(a.done)
+
+	if a.interval <= 0 {
+		log.Info("storage health checks are disabled")
+		return
+	}
+
+	log.Info("storage health checks are running")
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+
+		_ = a.Check(context.Background())
+	}
+}
+
+// Stop reports the services as not serving from then on, so that load
+// balancers stop routing to the instance while it drains.
+func (a *App) Stop() {
+	const op = "app.healthapp.Stop"
+
+	a.log.With(slog.String("op", op)).
+		Info("stopping storage health checks")
+
+	a.stopping.Store(true)
+	close(a.stop)
+	<-a.done
+
+	a.server.Shutdown()
+}
@@ -0,0 +1,147 @@
+package httpapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	authhttp "sso/internal/http/auth"
+	managementhttp "sso/internal/http/management"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+type App struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	port       int
+}
+
+// AuthService serves the OAuth endpoints, the admin API authentication,
+// impersonation, session revocation, invitations, org membership and app
+// usage reports.
+type AuthService interface {
+	authhttp.Auth
+	managementhttp.Authenticator
+	managementhttp.Impersonator
+	managementhttp.SessionRevoker
+	managementhttp.Inviter
+	managementhttp.OrgMembership
+	managementhttp.UsageReporter
+}
+
+// Readiness tells whether the service can serve requests.
+type Readiness interface {
+	Check(ctx context.Context) error
+}
+
+func New(
+	log *slog.Logger,
+	authService AuthService,
+	managementService managementhttp.Management,
+	metrics http.Handler,
+	readiness Readiness,
+	audience string,
+	adminAudience string,
+	port int,
+	timeout time.Duration,
+) *App {
+	mux := http.NewServeMux()
+
+	authhttp.RegisterHandlers(mux, authService, audience)
+	managementhttp.RegisterHandlers(mux, authService, authService, authService, authService, authService, authService, managementService, adminAudience)
+	if metrics != nil {
+		mux.Handle("GET /metrics", metrics)
+	}
+	mux.Handle("GET /readyz", readyHandler(readiness))
+
+	return &App{
+		log: log,
+		httpServer: &http.Server{
+			Addr:         fmt.Sprintf(":%d", port),
+			Handler:      withClientInfo(mux),
+			ReadTimeout:  timeout,
+			WriteTimeout: timeout,
+		},
+		port: port,
+	}
+}
+
+func (a *App) MustRun() {
+	if err := a.run(); err != nil {
+		panic(err)
+	}
+}
+
+func (a *App) run() error {
+	const op = "app.httpapp.Run"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("port", a.port),
+	)
+
+	lis, err := net.Listen("tcp", a.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("HTTP server is running", slog.String("address", lis.Addr().String()))
+
+	if err = a.httpServer.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (a *App) Stop() {
+	const op = "app.httpapp.Stop"
+
+	a.log.With(slog.String("op", op)).
+		Info("stopping HTTP server", slog.Int("port", a.port))
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.httpServer.WriteTimeout)
+	defer cancel()
+
+	if err := a.httpServer.Shutdown(ctx); err != nil {
+		a.log.Error("failed to stop HTTP server gracefully", sl.Err(err))
+	}
+}
+
+// withClientInfo passes the address and the user agent of the client to the
+// handlers in the request context.
+func withClientInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		ctx := clientinfo.NewContext(r.Context(), clientinfo.Client{
+			IP:          ip,
+			UserAgent:   r.UserAgent(),
+			DeviceToken: r.Header.Get("X-Device-Token"),
+		})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// readyHandler answers 503 while the service can not serve requests, so that
+// load balancers stop routing to it. Readiness logs the changes of the
+// status itself.
+func readyHandler(readiness Readiness) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := readiness.Check(r.Context()); err != nil {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok"))
+	})
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/storage/schema"
+)
+
+// Migrate runs the migrate command, one of up, down and status, against
+// the storage of the config.
+func Migrate(log *slog.Logger, cfg *config.Config, command string) error {
+	const op = "app.Migrate"
+
+	migrator, err := schema.New(storageConfig(cfg), cfg.Storage.MigrationsTable)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = migrator.Close() }()
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if !applied {
+			log.Info("no migrations to apply")
+			return nil
+		}
+		log.Info("migrations applied")
+	case "down":
+		rolledBack, err := migrator.Down()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if !rolledBack {
+			log.Info("no migrations to roll back")
+			return nil
+		}
+		log.Info("last migration rolled back")
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		log.Info("migration status",
+			slog.Uint64("version", uint64(status.Version)),
+			slog.Uint64("latest", uint64(status.Latest)),
+			slog.Bool("dirty", status.Dirty),
+		)
+	default:
+		return fmt.Errorf("%s: unknown command %q, want up, down or status", op, command)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage/factory"
+	"sso/internal/storage/schema"
+	"sso/internal/storage/sqlite"
+	"sso/internal/storage/transfer"
+	"strings"
+)
+
+// MigrateData copies the data of the database at from into the database at
+// to, both given as driver:dsn, such as sqlite:./storage/sso.db. The target
+// is migrated to the latest schema first, and the source must be at the
+// latest schema of its driver already.
+func MigrateData(log *slog.Logger, cfg *config.Config, from string, to string) error {
+	const op = "app.MigrateData"
+
+	fromCfg, err := parseDatabase(cfg, from)
+	if err != nil {
+		return fmt.Errorf("%s: --from: %w", op, err)
+	}
+	toCfg, err := parseDatabase(cfg, to)
+	if err != nil {
+		return fmt.Errorf("%s: --to: %w", op, err)
+	}
+	if fromCfg.Driver == toCfg.Driver && fromCfg.DSN == toCfg.DSN {
+		return fmt.Errorf("%s: the source and the target are the same database", op)
+	}
+
+	if err = checkSchema(fromCfg, cfg.Storage.MigrationsTable); err != nil {
+		return fmt.Errorf("%s: source: %w", op, err)
+	}
+	if err = migrateUp(log, toCfg, cfg.Storage.MigrationsTable); err != nil {
+		return fmt.Errorf("%s: target: %w", op, err)
+	}
+
+	fromDB, err := factory.OpenDB(fromCfg)
+	if err != nil {
+		return fmt.Errorf("%s: source: %w", op, err)
+	}
+	defer func() { _ = fromDB.Close() }()
+	toDB, err := factory.OpenDB(toCfg)
+	if err != nil {
+		return fmt.Errorf("%s: target: %w", op, err)
+	}
+	defer func() { _ = toDB.Close() }()
+
+	log.Info("copying data", slog.String("from", fromCfg.Driver), slog.String("to", toCfg.Driver))
+
+	report, err := transfer.Copy(context.Background(),
+		transfer.Database{Driver: fromCfg.Driver, DB: fromDB},
+		transfer.Database{Driver: toCfg.Driver, DB: toDB},
+		[]string{cfg.Storage.MigrationsTable},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var rows int64
+	for _, t := range report.Tables {
+		log.Debug("table copied", slog.String("table", t.Table), slog.Int64("rows", t.Rows))
+		rows += t.Rows
+	}
+	if len(report.Skipped) > 0 {
+		log.Info("tables of the source not in the target, not copied", slog.Any("tables", report.Skipped))
+	}
+	log.Info("data copied and verified", slog.Int("tables", len(report.Tables)), slog.Int64("rows", rows))
+
+	return nil
+}
+
+// parseDatabase reads a database given as driver:dsn.
+func parseDatabase(cfg *config.Config, database string) (factory.Config, error) {
+	driver, dsn, ok := strings.Cut(database, ":")
+	if !ok || dsn == "" {
+		return factory.Config{}, fmt.Errorf("want driver:dsn, got %q", database)
+	}
+	if driver == factory.DriverMemory {
+		return factory.Config{}, errors.New("the memory driver has no database to copy")
+	}
+
+	return factory.Config{
+		Driver: driver,
+		DSN:    dsn,
+		// reading the source does not take the write lock, as immediate
+		// transactions would
+		SQLite: sqlite.Pragmas{
+			JournalMode: cfg.Storage.SQLite.JournalMode,
+			Synchronous: cfg.Storage.SQLite.Synchronous,
+			BusyTimeout: cfg.Storage.SQLite.BusyTimeout,
+			ForeignKeys: cfg.Storage.SQLite.ForeignKeys,
+		},
+	}, nil
+}
+
+// checkSchema checks that the database is at the latest migration of its
+// driver, which the schemas of the drivers are kept alike at.
+func checkSchema(dbCfg factory.Config, table string) error {
+	migrator, err := schema.New(dbCfg, table)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = migrator.Close() }()
+
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	if status.Dirty || status.Version != status.Latest {
+		return fmt.Errorf("at migration %d of %d (dirty: %t), migrate it up first", status.Version, status.Latest, status.Dirty)
+	}
+
+	return nil
+}
+
+func migrateUp(log *slog.Logger, dbCfg factory.Config, table string) error {
+	migrator, err := schema.New(dbCfg, table)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := migrator.Close(); err != nil {
+			log.Error("failed to close migrator", sl.Err(err))
+		}
+	}()
+
+	applied, err := migrator.Up()
+	if err != nil {
+		return err
+	}
+	if applied {
+		log.Info("target migrated to the latest schema")
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package outboxapp
+
+import (
+	"context"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+// App relays the events recorded in the outbox to the publisher every
+// interval, and deletes those delivered long enough ago.
+type App struct {
+	log      *slog.Logger
+	relay    Relay
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type Relay interface {
+	Relay(ctx context.Context) (int, error)
+	DeleteDelivered(ctx context.Context) (int, error)
+	Close() error
+}
+
+func New(log *slog.Logger, relay Relay, interval time.Duration) *App {
+	return &App{
+		log:      log,
+		relay:    relay,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// MustRun relays events until Stop is called.
+func (a *App) MustRun() {
+	const op = "app.outboxapp.Run"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Duration("interval", a.interval),
+	)
+
+	defer close(a.done)
+
+	if a.interval <= 0 {
+		log.Info("outbox relay is disabled")
+		return
+	}
+
+	log.Info("outbox relay is running")
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		a.relayEvents(log)
+
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *App) relayEvents(log *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), a.interval)
+	defer cancel()
+
+	published, err := a.relay.Relay(ctx)
+	if err != nil {
+		log.Error("failed to relay events", sl.Err(err))
+	}
+	if published > 0 {
+		log.Debug("events published", slog.Int("published", published))
+	}
+
+	deleted, err := a.relay.DeleteDelivered(ctx)
+	if err != nil {
+		log.Error("failed to delete delivered events", sl.Err(err))
+	}
+	if deleted > 0 {
+		log.Info("delivered events deleted", slog.Int("deleted", deleted))
+	}
+}
+
+// Stop waits for the running relay to finish and closes the publisher.
+func (a *App) Stop() {
+	const op = "app.outboxapp.Stop"
+
+	log := a.log.With(slog.String("op", op))
+
+	log.Info("stopping outbox relay")
+
+	close(a.stop)
+	<-a.done
+
+	if err := a.relay.Close(); err != nil {
+		log.Error("failed to close publisher", sl.Err(err))
+	}
+}
@@ -0,0 +1,97 @@
+package purgeapp
+
+import (
+	"context"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+// App deletes the accounts whose deletion grace period is over and disables
+// or deletes expired accounts, every interval.
+type App struct {
+	log      *slog.Logger
+	purger   Purger
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type Purger interface {
+	PurgeDeletedAccounts(ctx context.Context) (int, error)
+	ExpireAccounts(ctx context.Context) (int, error)
+}
+
+func New(log *slog.Logger, purger Purger, interval time.Duration) *App {
+	return &App{
+		log:      log,
+		purger:   purger,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// MustRun purges accounts until Stop is called.
+func (a *App) MustRun() {
+	const op = "app.purgeapp.Run"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Duration("interval", a.interval),
+	)
+
+	defer close(a.done)
+
+	if a.interval <= 0 {
+		log.Info("account purging is disabled")
+		return
+	}
+
+	log.Info("account purging is running")
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		a.purge(log)
+
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *App) purge(log *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), a.interval)
+	defer cancel()
+
+	deleted, err := a.purger.PurgeDeletedAccounts(ctx)
+	if err != nil {
+		log.Error("failed to purge accounts", sl.Err(err))
+	}
+	if deleted > 0 {
+		log.Info("accounts purged", slog.Int("deleted", deleted))
+	}
+
+	expired, err := a.purger.ExpireAccounts(ctx)
+	if err != nil {
+		log.Error("failed to expire accounts", sl.Err(err))
+	}
+	if expired > 0 {
+		log.Info("accounts expired", slog.Int("expired", expired))
+	}
+}
+
+// Stop waits for the running purge to finish.
+func (a *App) Stop() {
+	const op = "app.purgeapp.Stop"
+
+	a.log.With(slog.String("op", op)).
+		Info("stopping account purging")
+
+	close(a.stop)
+	<-a.done
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/internal/config"
+	"sso/internal/lib/emailaddr"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/seed"
+	"sso/internal/storage/factory"
+	"sso/internal/storage/pii"
+)
+
+// Seed creates the apps and admins of the data in the storage of the
+// config, skipping those which exist, and logs the secrets and passwords
+// it generated.
+func Seed(log *slog.Logger, cfg *config.Config, data seed.Data) error {
+	const op = "app.Seed"
+
+	if cfg.Storage.Driver == factory.DriverMemory {
+		return fmt.Errorf("%s: the memory driver keeps nothing past the command", op)
+	}
+
+	box, err := newPIIBox(cfg.Storage.Encryption)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	emails := emailaddr.Normalizer{FoldGmail: cfg.Registration.FoldGmail}
+	storage, err := factory.New(storageConfig(cfg), emailKeys(box, emails))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err := storage.Close(); err != nil {
+			log.Error("failed to close storage", sl.Err(err))
+		}
+	}()
+	if box != nil {
+		storage = pii.New(storage, box)
+	}
+
+	res, err := seed.Apply(context.Background(), storage, data)
+
+	for _, app := range res.Apps {
+		log.Info("app created", slog.Int("app_id", app.ID), slog.String("name", app.Name))
+		if app.Secret != "" {
+			log.Warn("generated app secret, store it now", slog.String("name", app.Name), slog.String("secret", app.Secret))
+		}
+	}
+	for _, name := range res.SkippedApps {
+		log.Info("app exists, skipped", slog.String("name", name))
+	}
+	for _, admin := range res.Admins {
+		log.Info("admin created", slog.Int64("user_id", admin.ID), slog.String("email", admin.Email))
+		if admin.Password != "" {
+			log.Warn("generated admin password, store it now", slog.String("email", admin.Email), slog.String("password", admin.Password))
+		}
+	}
+	for _, email := range res.SkippedAdmins {
+		log.Info("user exists, skipped", slog.String("email", email))
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
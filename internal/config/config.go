@@ -8,24 +8,531 @@ import (
 )
 
 type Config struct {
-	Env         string        `yaml:"env" env-default:"local"`
-	StoragePath string        `yaml:"storage_path" env-required:"true"`
-	TokenTTL    time.Duration `yaml:"token_ttl" env-required:"true"`
-	Grpc        GrpcConfig    `yaml:"grpcapp"`
+	Env               string                  `yaml:"env" env-default:"local"`
+	StoragePath       string                  `yaml:"storage_path"`
+	Storage           StorageConfig           `yaml:"storage"`
+	Issuer            string                  `yaml:"issuer" env-default:"sso"`
+	Audience          string                  `yaml:"audience" env-default:"sso"`
+	AdminAudience     string                  `yaml:"admin_audience"`
+	TokenFormat       string                  `yaml:"token_format" env-default:"jwt"`
+	TokenTTL          time.Duration           `yaml:"token_ttl" env-required:"true"`
+	TokenLeeway       time.Duration           `yaml:"token_leeway" env-default:"30s"`
+	RefreshTokenTTL   time.Duration           `yaml:"refresh_token_ttl" env-default:"720h"`
+	Grpc              GrpcConfig              `yaml:"grpcapp"`
+	HTTP              HTTPConfig              `yaml:"httpapp"`
+	Metrics           MetricsConfig           `yaml:"metrics"`
+	Health            HealthConfig            `yaml:"health"`
+	Redis             RedisConfig             `yaml:"redis"`
+	Cache             CacheConfig             `yaml:"cache"`
+	Device            DeviceConfig            `yaml:"device"`
+	Session           SessionConfig           `yaml:"session"`
+	Email             EmailConfig             `yaml:"email"`
+	PasswordReset     PasswordResetConfig     `yaml:"password_reset"`
+	EmailVerification EmailVerificationConfig `yaml:"email_verification"`
+	EmailChange       EmailChangeConfig       `yaml:"email_change"`
+	MagicLink         MagicLinkConfig         `yaml:"magic_link"`
+	MFA               MFAConfig               `yaml:"mfa"`
+	WebAuthn          WebAuthnConfig          `yaml:"webauthn"`
+	SMS               SMSConfig               `yaml:"sms"`
+	Federation        FederationConfig        `yaml:"federation"`
+	LDAP              LDAPConfig              `yaml:"ldap"`
+	Lockout           LockoutConfig           `yaml:"lockout"`
+	LoginThrottle     LoginThrottleConfig     `yaml:"login_throttle"`
+	PasswordPolicy    PasswordPolicyConfig    `yaml:"password_policy"`
+	PwnedPasswords    PwnedPasswordsConfig    `yaml:"pwned_passwords"`
+	Registration      RegistrationConfig      `yaml:"registration"`
+	Challenge         ChallengeConfig         `yaml:"challenge"`
+	Impersonation     ImpersonationConfig     `yaml:"impersonation"`
+	AccountDeletion   AccountDeletionConfig   `yaml:"account_deletion"`
+	AccountExpiry     AccountExpiryConfig     `yaml:"account_expiry"`
+	Outbox            OutboxConfig            `yaml:"outbox"`
+	Hooks             HooksConfig             `yaml:"hooks"`
+	RBAC              RBACConfig              `yaml:"rbac"`
+
+	// Dev is set by the -dev flag, which keeps everything in process memory
+	// and seeds an app and an admin to try the service with.
+	Dev bool `yaml:"-"`
 }
 
+// GrpcConfig configures the gRPC server. MethodRoles makes methods, keyed by
+// full method name such as "/auth.Auth/IsAdmin", require the access token of
+// a user with one of the roles in the authorization metadata. Admin methods
+// always require it, and roles configured for them let further users in.
 type GrpcConfig struct {
-	Port    int           `yaml:"port"`
+	Port        int                 `yaml:"port"`
+	Timeout     time.Duration       `yaml:"timeout"`
+	MethodRoles map[string][]string `yaml:"method_roles"`
+}
+
+type HTTPConfig struct {
+	Port    int           `yaml:"port" env-default:"8080"`
+	Timeout time.Duration `yaml:"timeout" env-default:"5s"`
+}
+
+// MetricsConfig serves Prometheus metrics, such as those of the storage
+// connection pool, at /metrics of the HTTP server when Enabled.
+type MetricsConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// HealthConfig checks that the storage can be reached every CheckInterval,
+// and on every request to /readyz of the HTTP server, each check failing
+// after Timeout. While it can not, /readyz answers 503 and the gRPC health
+// service reports NOT_SERVING, so that load balancers stop routing to the
+// instance. A zero CheckInterval leaves the gRPC status to /readyz.
+type HealthConfig struct {
+	CheckInterval time.Duration `yaml:"check_interval" env-default:"10s"`
+	Timeout       time.Duration `yaml:"timeout" env-default:"2s"`
+}
+
+// StorageConfig selects the database users, apps and the rest are kept in.
+// Driver is one of:
+//   - sqlite: the database file at DSN, or at StoragePath without one;
+//   - memory: process memory, lost on exit, with no DSN and no migrations.
+//
+// AutoMigrate applies the migrations embedded into the binary at startup,
+// as `sso migrate up` does, keeping track of them in MigrationsTable.
+//
+// MaxOpenConns, MaxIdleConns, ConnMaxLifetime and ConnMaxIdleTime limit the
+// pool of connections to the database. Zero keeps the default of the driver.
+// QueryTimeout limits how long each query may take, zero leaving them to the
+// deadline of the request they are made for.
+//
+// Replicas are the DSNs of read replicas of the database, which lookups of
+// users and apps go to while they answer the health check made every
+// ReplicaCheckInterval. Neither driver has replicas yet, so any set fail the
+// startup.
+type StorageConfig struct {
+	Driver          string        `yaml:"driver" env-default:"sqlite"`
+	DSN             string        `yaml:"dsn" env:"STORAGE_DSN"`
+	AutoMigrate     bool          `yaml:"auto_migrate"`
+	MigrationsTable string        `yaml:"migrations_table" env-default:"schema_migrations"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	QueryTimeout    time.Duration `yaml:"query_timeout"`
+
+	Replicas             []string      `yaml:"replicas" env:"STORAGE_REPLICAS" env-separator:","`
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval" env-default:"10s"`
+
+	Retry      StorageRetryConfig      `yaml:"retry"`
+	SQLite     SQLiteConfig            `yaml:"sqlite"`
+	Encryption StorageEncryptionConfig `yaml:"encryption"`
+}
+
+// StorageEncryptionConfig encrypts the emails and phone numbers the storage
+// keeps with a key derived from Key, or from the contents of KeyFile, as
+// written by a KMS agent or mounted from a secret store. Emails are then
+// looked up by blind indexes. Values stored in plaintext are encrypted at
+// startup. Empty keys leave the data in plaintext. TOTP secrets are
+// encrypted regardless, with mfa.encryption_key.
+type StorageEncryptionConfig struct {
+	Key     string `yaml:"key" env:"STORAGE_ENCRYPTION_KEY"`
+	KeyFile string `yaml:"key_file" env:"STORAGE_ENCRYPTION_KEY_FILE"`
+}
+
+// SQLiteConfig sets pragmas on every connection of the sqlite driver. The
+// defaults let readers go on while a write is made, have writers wait for
+// each other for up to BusyTimeout and enforce foreign keys, as the other
+// drivers do. TxLock is deferred, immediate or exclusive, how transactions
+// take the lock of the database, and the default of immediate has them
+// wait for each other as they begin rather than fail when both go on to
+// write. Settings of the DSN take precedence.
+type SQLiteConfig struct {
+	JournalMode string        `yaml:"journal_mode" env-default:"wal"`
+	Synchronous string        `yaml:"synchronous" env-default:"normal"`
+	BusyTimeout time.Duration `yaml:"busy_timeout" env-default:"5s"`
+	ForeignKeys string        `yaml:"foreign_keys" env-default:"on"`
+	TxLock      string        `yaml:"tx_lock" env-default:"immediate"`
+}
+
+// StorageRetryConfig retries storage calls failing with transient errors,
+// such as deadlocks, serialization failures, a locked SQLite database or a
+// database which could not be reached, up to MaxAttempts attempts in all.
+// The waits between attempts are random, capped by BaseDelay and doubling
+// up to MaxDelay. Budget is the share of calls that may be retried, so that
+// retries do not add to the load of an overloaded database. MaxAttempts of
+// 1 disables retries.
+type StorageRetryConfig struct {
+	MaxAttempts int           `yaml:"max_attempts" env-default:"3"`
+	BaseDelay   time.Duration `yaml:"base_delay" env-default:"20ms"`
+	MaxDelay    time.Duration `yaml:"max_delay" env-default:"500ms"`
+	Budget      float64       `yaml:"budget" env-default:"0.1"`
+}
+
+// RedisConfig configures the shared token denylist, login throttle, app
+// quota counters and opaque token sessions. Without an address revoked
+// tokens, failed logins and quota usage are kept in process memory and
+// sessions in the database.
+type RedisConfig struct {
+	Addr     string `yaml:"addr" env:"REDIS_ADDR"`
+	Password string `yaml:"password" env:"REDIS_PASSWORD"`
+	DB       int    `yaml:"db"`
+}
+
+// CacheConfig caches the user and app lookups of logins in Redis, which
+// needs the redis address. Cached users and apps are dropped when they
+// change, and kept for TTL at most. Their password hashes, secrets and
+// signing keys stay out of Redis. Zero TTL, the default, disables the
+// cache.
+type CacheConfig struct {
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// DeviceConfig configures the device authorization grant.
+type DeviceConfig struct {
+	CodeTTL         time.Duration `yaml:"code_ttl" env-default:"10m"`
+	PollInterval    time.Duration `yaml:"poll_interval" env-default:"5s"`
+	VerificationURI string        `yaml:"verification_uri" env-default:"http://localhost:8080/device"`
+}
+
+// SessionConfig configures session expiration. Sessions last for the refresh
+// token lifetime unless Sliding is set: then each refresh extends them, up to
+// MaxAge since the login. LoginHistory is how many of the latest logins of
+// each user are kept.
+type SessionConfig struct {
+	Sliding      bool          `yaml:"sliding" env-default:"false"`
+	MaxAge       time.Duration `yaml:"max_age" env-default:"2160h"`
+	LoginHistory int           `yaml:"login_history" env-default:"50"`
+}
+
+// EmailConfig configures how emails to users are delivered. Sender is one of:
+//   - log: emails are only logged, for local development;
+//   - file: emails are written to files in Dir;
+//   - smtp: emails are sent through the SMTP server.
+type EmailConfig struct {
+	Sender string     `yaml:"sender" env-default:"log"`
+	From   string     `yaml:"from" env-default:"sso@localhost"`
+	Dir    string     `yaml:"dir"`
+	SMTP   SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port" env-default:"587"`
+	Username string `yaml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD"`
+}
+
+// PasswordResetConfig configures password reset links. The reset token is
+// appended to URL as the token query parameter. ChangeTokenTTL is the
+// lifetime of the reset tokens logins return to users an admin required to
+// set a new password.
+type PasswordResetConfig struct {
+	TokenTTL       time.Duration `yaml:"token_ttl" env-default:"1h"`
+	URL            string        `yaml:"url" env-default:"http://localhost:8080/password/reset"`
+	ChangeTokenTTL time.Duration `yaml:"change_token_ttl" env-default:"10m"`
+}
+
+// RegistrationConfig configures how users sign up. InvitationOnly closes
+// open registration, so that only invited emails can register. The
+// invitation token is appended to InvitationURL as the token query
+// parameter, and the token of invitations to join an org to
+// OrgInvitationURL.
+//
+// Emails that differ only in case or in the spelling of an international
+// domain belong to one account. FoldGmail also makes Gmail addresses that
+// differ in dots or a plus suffix the same, as Gmail delivers them to one
+// mailbox.
+type RegistrationConfig struct {
+	InvitationOnly   bool          `yaml:"invitation_only" env-default:"false"`
+	InvitationTTL    time.Duration `yaml:"invitation_ttl" env-default:"168h"`
+	InvitationURL    string        `yaml:"invitation_url" env-default:"http://localhost:8080/invitation"`
+	OrgInvitationURL string        `yaml:"org_invitation_url" env-default:"http://localhost:8080/org-invitation"`
+	FoldGmail        bool          `yaml:"fold_gmail" env-default:"false"`
+}
+
+// HooksConfig plugs custom checks into registration and login. With
+// AllowedEmailDomains set, only emails of those domains can register, and
+// emails of BlockedEmailDomains never can. Webhooks are called on their
+// Events: pre_register, post_register, pre_login and org_membership. FailOpen lets
+// registrations and logins go on when a webhook fails.
+type HooksConfig struct {
+	AllowedEmailDomains []string        `yaml:"allowed_email_domains"`
+	BlockedEmailDomains []string        `yaml:"blocked_email_domains"`
+	Webhooks            []WebhookConfig `yaml:"webhooks"`
+	FailOpen            bool            `yaml:"fail_open" env-default:"false"`
+}
+
+// WebhookConfig configures a webhook called on registration and login
+// events. Requests are signed with Secret, see package webhook. A zero
+// Timeout means 5s.
+type WebhookConfig struct {
+	URL     string        `yaml:"url"`
+	Secret  string        `yaml:"secret"`
+	Events  []string      `yaml:"events"`
 	Timeout time.Duration `yaml:"timeout"`
 }
 
+// EmailVerificationConfig configures the links sent to verify user emails.
+// Required denies logins until the user has verified the email.
+type EmailVerificationConfig struct {
+	Required bool          `yaml:"required" env-default:"false"`
+	TokenTTL time.Duration `yaml:"token_ttl" env-default:"24h"`
+	URL      string        `yaml:"url" env-default:"http://localhost:8080/email/verify"`
+}
+
+// EmailChangeConfig configures the links confirming a new user email.
+type EmailChangeConfig struct {
+	TokenTTL time.Duration `yaml:"token_ttl" env-default:"1h"`
+	URL      string        `yaml:"url" env-default:"http://localhost:8080/email/change/confirm"`
+}
+
+// MagicLinkConfig configures passwordless login links. At most Limit links
+// are emailed to a user within Window.
+type MagicLinkConfig struct {
+	TokenTTL time.Duration `yaml:"token_ttl" env-default:"15m"`
+	URL      string        `yaml:"url" env-default:"http://localhost:8080/magic-link"`
+	Limit    int           `yaml:"limit" env-default:"3"`
+	Window   time.Duration `yaml:"window" env-default:"1h"`
+}
+
+// MFAConfig configures multi-factor authentication. EncryptionKey encrypts
+// the TOTP secrets of users at rest and must not change once set. Devices
+// users trust skip the second factor for TrustedDeviceTTL, zero disables
+// trusted devices.
+type MFAConfig struct {
+	EncryptionKey    string        `yaml:"encryption_key" env:"MFA_ENCRYPTION_KEY" env-required:"true"`
+	Issuer           string        `yaml:"issuer" env-default:"sso"`
+	ChallengeTTL     time.Duration `yaml:"challenge_ttl" env-default:"5m"`
+	MaxAttempts      int           `yaml:"max_attempts" env-default:"5"`
+	TrustedDeviceTTL time.Duration `yaml:"trusted_device_ttl" env-default:"720h"`
+}
+
+// WebAuthnConfig describes the relying party passkeys are registered for.
+// RPID is the domain of the login page, RPOrigins are the origins the
+// browser may run the ceremonies from.
+type WebAuthnConfig struct {
+	RPID          string        `yaml:"rp_id" env-default:"localhost"`
+	RPDisplayName string        `yaml:"rp_display_name" env-default:"sso"`
+	RPOrigins     []string      `yaml:"rp_origins" env-default:"http://localhost:8080"`
+	SessionTTL    time.Duration `yaml:"session_ttl" env-default:"5m"`
+}
+
+// SMSConfig configures one-time codes sent by SMS. Sender is one of:
+//   - log: messages are only logged, for local development;
+//   - file: messages are written to files in Dir;
+//   - twilio: messages are sent through the Twilio API.
+//
+// A new code is sent at most every ResendInterval and accepts MaxAttempts
+// wrong entries.
+type SMSConfig struct {
+	Sender         string        `yaml:"sender" env-default:"log"`
+	Dir            string        `yaml:"dir"`
+	Twilio         TwilioConfig  `yaml:"twilio"`
+	CodeTTL        time.Duration `yaml:"code_ttl" env-default:"5m"`
+	ResendInterval time.Duration `yaml:"resend_interval" env-default:"30s"`
+	MaxAttempts    int           `yaml:"max_attempts" env-default:"5"`
+}
+
+type TwilioConfig struct {
+	AccountSID string `yaml:"account_sid" env:"TWILIO_ACCOUNT_SID"`
+	AuthToken  string `yaml:"auth_token" env:"TWILIO_AUTH_TOKEN"`
+	From       string `yaml:"from"`
+}
+
+// FederationConfig configures login through upstream identity providers.
+// StateTTL limits the time a user may take to log in at the provider.
+type FederationConfig struct {
+	StateTTL time.Duration `yaml:"state_ttl" env-default:"10m"`
+	// RedirectBaseURL prefixes the callbacks of the identity providers
+	// configured through the admin API: <base>/<name>/callback.
+	RedirectBaseURL string       `yaml:"redirect_base_url" env-default:"http://localhost:8080/oauth"`
+	Google          GoogleConfig `yaml:"google"`
+}
+
+// GoogleConfig enables login with Google when ClientID is set. The issuer
+// and endpoints default to Google's and only need to be set for testing.
+type GoogleConfig struct {
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret" env:"GOOGLE_CLIENT_SECRET"`
+	RedirectURL  string `yaml:"redirect_url" env-default:"http://localhost:8080/oauth/google/callback"`
+	Issuer       string `yaml:"issuer"`
+	AuthURL      string `yaml:"auth_url"`
+	TokenURL     string `yaml:"token_url"`
+	JWKSURL      string `yaml:"jwks_url"`
+}
+
+// LockoutConfig locks accounts for Duration after Threshold failed password
+// logins within Window. A zero Threshold disables lockouts.
+type LockoutConfig struct {
+	Threshold int           `yaml:"threshold" env-default:"5"`
+	Window    time.Duration `yaml:"window" env-default:"15m"`
+	Duration  time.Duration `yaml:"duration" env-default:"15m"`
+}
+
+// LoginThrottleConfig rejects password logins for a login once EmailLimit
+// logins for it, or from an IP once IPLimit logins from it, have failed
+// within Window. Unlike lockouts, the throttle does not lock accounts and
+// also holds back attempts spread over many accounts. Zero limits disable
+// the throttle. Failures are counted in Redis if it is configured, so that
+// every instance sees them. IPs in one of the Bypass networks, given in CIDR
+// notation, are not throttled.
+type LoginThrottleConfig struct {
+	EmailLimit int           `yaml:"email_limit" env-default:"10"`
+	IPLimit    int           `yaml:"ip_limit" env-default:"50"`
+	Window     time.Duration `yaml:"window" env-default:"15m"`
+	Bypass     []string      `yaml:"bypass"`
+}
+
+// ImpersonationConfig limits the lifetime of the tokens admins impersonate
+// users with. Tokens never outlive the access tokens of the app.
+type ImpersonationConfig struct {
+	TokenTTL time.Duration `yaml:"token_ttl" env-default:"15m"`
+}
+
+// AccountDeletionConfig configures the deletion of accounts by their users.
+// Accounts are anonymized GracePeriod after the user asks, or after an
+// admin deletes the user. Accounts due for deletion are looked for every
+// PurgeInterval.
+type AccountDeletionConfig struct {
+	GracePeriod   time.Duration `yaml:"grace_period" env-default:"720h"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env-default:"1h"`
+}
+
+// AccountExpiryConfig configures what happens to temporary accounts once
+// they expire: Action disable ends their sessions until an admin extends
+// the expiry, delete deletes them as admins do. Expired accounts are looked
+// for with those due for deletion, every AccountDeletion.PurgeInterval.
+type AccountExpiryConfig struct {
+	Action string `yaml:"action" env-default:"disable"`
+}
+
+// OutboxConfig configures the relay of the events recorded with user and
+// session changes, such as user_registered, session_started and the audit
+// events. Every Interval, up to BatchSize pending events are published in
+// order through Publisher, one of:
+//   - log: events are only logged, for local development;
+//   - webhook: events are posted to Webhook, whatever its Events, with the
+//     event type in the X-Webhook-Event header;
+//   - kafka: events are written to Kafka.Topic on Kafka.Brokers.
+//
+// Delivered events are deleted after Retention. A zero Interval disables
+// the relay, and events pile up until it is enabled.
+type OutboxConfig struct {
+	Publisher string        `yaml:"publisher" env-default:"log"`
+	Interval  time.Duration `yaml:"interval" env-default:"5s"`
+	BatchSize int           `yaml:"batch_size" env-default:"100"`
+	Retention time.Duration `yaml:"retention" env-default:"168h"`
+	Webhook   WebhookConfig `yaml:"webhook"`
+	Kafka     KafkaConfig   `yaml:"kafka"`
+}
+
+type KafkaConfig struct {
+	Brokers []string `yaml:"brokers" env:"KAFKA_BROKERS" env-separator:","`
+	Topic   string   `yaml:"topic" env-default:"sso.events"`
+}
+
+// RBACConfig configures roles and permissions. Answers to permission checks
+// of resource servers are cached for PermissionCacheTTL, zero disables the
+// cache. PolicyEngine is "casbin" to decide permissions roles do not grant
+// by the stored policy rules, with the Casbin model of the PolicyModel file
+// or the built-in one, and empty to only follow roles. DefaultRoles are
+// assigned to every registered user.
+type RBACConfig struct {
+	PermissionCacheTTL time.Duration       `yaml:"permission_cache_ttl" env-default:"1m"`
+	PolicyEngine       string              `yaml:"policy_engine"`
+	PolicyModel        string              `yaml:"policy_model"`
+	DefaultRoles       []DefaultRoleConfig `yaml:"default_roles"`
+}
+
+// DefaultRoleConfig is a role assigned on registration within the app with
+// AppID, or globally if it is zero.
+type DefaultRoleConfig struct {
+	Role  string `yaml:"role"`
+	AppID int    `yaml:"app_id"`
+}
+
+// PasswordPolicyConfig sets the rules new passwords must follow. Lengths
+// count characters. BannedFile lists further banned passwords, one per line,
+// besides the built-in list of common ones.
+type PasswordPolicyConfig struct {
+	MinLength     int    `yaml:"min_length" env-default:"8"`
+	MaxLength     int    `yaml:"max_length" env-default:"64"`
+	RequireUpper  bool   `yaml:"require_upper" env-default:"false"`
+	RequireLower  bool   `yaml:"require_lower" env-default:"false"`
+	RequireDigit  bool   `yaml:"require_digit" env-default:"false"`
+	RequireSymbol bool   `yaml:"require_symbol" env-default:"false"`
+	BannedFile    string `yaml:"banned_file"`
+}
+
+// PwnedPasswordsConfig rejects new passwords known from data breaches. With
+// HIBP set, the first five hex digits of the SHA-1 hash of the password are
+// sent to the Pwned Passwords range API at HIBPURL. BloomFile is a filter
+// built with cmd/pwnedbloom for deployments without internet access; with
+// both set, it is used when the API is unavailable.
+type PwnedPasswordsConfig struct {
+	HIBP      bool          `yaml:"hibp" env-default:"false"`
+	HIBPURL   string        `yaml:"hibp_url" env-default:"https://api.pwnedpasswords.com"`
+	BloomFile string        `yaml:"bloom_file"`
+	Timeout   time.Duration `yaml:"timeout" env-default:"2s"`
+}
+
+// ChallengeConfig makes the Register and Login RPCs require a solved
+// CAPTCHA, sent in the x-challenge-token metadata. Provider is recaptcha,
+// hcaptcha or turnstile; empty disables the challenge. VerifyURL overrides
+// the siteverify endpoint of the provider. MinScore applies to reCAPTCHA v3.
+// Callers with an address in one of the Bypass networks, given in CIDR
+// notation, are not challenged.
+type ChallengeConfig struct {
+	Provider  string   `yaml:"provider"`
+	Secret    string   `yaml:"secret"`
+	VerifyURL string   `yaml:"verify_url"`
+	MinScore  float64  `yaml:"min_score" env-default:"0.5"`
+	Bypass    []string `yaml:"bypass"`
+}
+
+// LDAPConfig makes the users of some apps log in against LDAP directories
+// instead of with their local passwords.
+type LDAPConfig struct {
+	Directories map[string]DirectoryConfig `yaml:"directories"`
+	// Apps maps app ids to the names of the directories their users log in
+	// against.
+	Apps map[int]string `yaml:"apps"`
+}
+
+// DirectoryConfig describes an LDAP directory. Empty filter and attributes
+// default to (mail=%s), mail and memberOf.
+type DirectoryConfig struct {
+	URL            string        `yaml:"url"`
+	StartTLS       bool          `yaml:"start_tls"`
+	BindDN         string        `yaml:"bind_dn"`
+	BindPassword   string        `yaml:"bind_password"`
+	BaseDN         string        `yaml:"base_dn"`
+	UserFilter     string        `yaml:"user_filter"`
+	EmailAttribute string        `yaml:"email_attribute"`
+	GroupAttribute string        `yaml:"group_attribute"`
+	Timeout        time.Duration `yaml:"timeout"`
+	// Provision creates local users on their first login.
+	Provision bool `yaml:"provision"`
+	// Roles maps group DNs to roles. Only the admin role exists.
+	Roles map[string]string `yaml:"roles"`
+}
+
 func MustLoad() *Config {
-	configPath := fetchConfigPath()
+	configPath, dev := fetchFlags()
 	if configPath == "" {
 		panic("config configPath is empty")
 	}
 
-	return MustLoadPath(configPath)
+	cfg := MustLoadPath(configPath)
+	if dev {
+		cfg.SetDev()
+	}
+
+	return cfg
+}
+
+// SetDev switches the config to the -dev mode, which keeps everything in
+// process memory and whose APIs take the tokens of the app it seeds.
+func (c *Config) SetDev() {
+	c.Dev = true
+	c.Audience = "dev"
+	c.AdminAudience = ""
+	c.Storage.Driver = "memory"
+	c.Storage.AutoMigrate = false
+	c.Storage.Replicas = nil
 }
 
 func MustLoadPath(configPath string) *Config {
@@ -42,15 +549,17 @@ func MustLoadPath(configPath string) *Config {
 	return &cfg
 }
 
-func fetchConfigPath() string {
+func fetchFlags() (string, bool) {
 	var res string
+	var dev bool
 
 	flag.StringVar(&res, "config", "", "path to config file")
+	flag.BoolVar(&dev, "dev", false, "keep everything in memory and seed a dev app and admin")
 	flag.Parse()
 
 	if res == "" {
 		res = os.Getenv("CONFIG_PATH")
 	}
 
-	return res
+	return res, dev
 }
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// APIKey is a long-lived credential of an app for integrations that do not
+// go through OAuth flows. Only the hash of the key is stored. Its prefix is
+// kept in the clear so admins can tell keys apart.
+type APIKey struct {
+	ID      int64
+	AppID   int
+	Name    string
+	Prefix  string
+	KeyHash string
+	// Key is only set on a key that was just issued.
+	Key        string
+	CreatedBy  int64
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
@@ -1,7 +1,133 @@
 package models
 
+import (
+	"slices"
+	"time"
+)
+
 type App struct {
-	ID     int
-	Name   string
-	Secret string
+	ID   int
+	Name string
+	// OrgID is the org the app belongs to. Only users of the org can log in
+	// to apps of orgs other than the default one.
+	OrgID int64
+	// Secret is the plaintext secret the app authenticates with. Only its
+	// hash is stored, so it is only set right after it is generated.
+	Secret     string
+	SecretHash string
+	// PreviousSecretHash is the hash of the secret the current one replaced,
+	// which is still accepted until PreviousSecretExpiresAt.
+	PreviousSecretHash      string
+	PreviousSecretExpiresAt *time.Time
+	// SigningKey is the random key tokens issued for the app are signed
+	// with, replaced along with the secret. It is never shown, so apps verify
+	// their tokens through introspection.
+	SigningKey string
+	// Audience is the aud claim of tokens issued for the app.
+	Audience string
+	// TokenFormat is jwt, paseto or opaque. Empty means the configured default.
+	TokenFormat string
+	// Scopes the app is allowed to request for its tokens.
+	Scopes []string
+	// GrantTypes the app may get tokens with. Apps without grant types may
+	// use every grant.
+	GrantTypes []string
+	// RedirectURIs are where users may be sent back to with tokens of the
+	// app after they authorize it.
+	RedirectURIs []string
+	// Claims are static claims added to every token issued for the app.
+	Claims map[string]any
+	// ClaimMappings rename claims of tokens issued for the app, by source name.
+	ClaimMappings map[string]string
+	// AccessTokenTTL and RefreshTokenTTL override the global token lifetimes
+	// when they are not zero.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// ThirdParty apps only get tokens of users who consented to the scopes.
+	ThirdParty bool
+	// LoginQuota and RegistrationQuota limit the logins to the app per
+	// minute and the registrations through it per day. Zero means no limit.
+	LoginQuota        int
+	RegistrationQuota int
+	// CreatedAt is zero for apps created before apps were managed through
+	// the admin API.
+	CreatedAt time.Time
+	// Status is one of the AppStatus values.
+	Status string
+	// DisabledAt is set while an admin has the app disabled. Users can not
+	// log in to disabled apps.
+	DisabledAt *time.Time
+}
+
+// Statuses of apps. Deprecated apps keep working, the status only warns
+// their owners to move off them.
+const (
+	AppStatusActive     = "active"
+	AppStatusDeprecated = "deprecated"
+	AppStatusDisabled   = "disabled"
+)
+
+// Grant types apps can be limited to.
+const (
+	GrantPassword          = "password"
+	GrantClientCredentials = "client_credentials"
+	GrantDeviceCode        = "device_code"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// AllowsGrant tells whether the app may get tokens with the grant type.
+func (a App) AllowsGrant(grant string) bool {
+	return len(a.GrantTypes) == 0 || slices.Contains(a.GrantTypes, grant)
+}
+
+// ClaimMapping renames the Source claim to Target in tokens issued for the app.
+type ClaimMapping struct {
+	AppID  int
+	Source string
+	Target string
+}
+
+// Quotas apps can be limited by.
+const (
+	QuotaLogins        = "logins"
+	QuotaRegistrations = "registrations"
+)
+
+// QuotaUsage is how much of a quota an app used in the current window.
+type QuotaUsage struct {
+	Used int
+	// Limit is zero if the app has no limit.
+	Limit    int
+	ResetsAt time.Time
+}
+
+// AppUsage is the usage of the quotas of an app.
+type AppUsage struct {
+	AppID         int
+	Logins        QuotaUsage
+	Registrations QuotaUsage
+}
+
+// AppStats is the activity of an app from From up to To. TokensIssued counts
+// the access tokens issued for the app, those of refreshed sessions
+// included.
+type AppStats struct {
+	AppID        int
+	From         time.Time
+	To           time.Time
+	Logins       int
+	FailedLogins int
+	UniqueUsers  int
+	TokensIssued int
+}
+
+// FailureRate returns the share of the login attempts that failed, or zero
+// if there were none.
+func (s AppStats) FailureRate() float64 {
+	attempts := s.Logins + s.FailedLogins
+	if attempts == 0 {
+		return 0
+	}
+
+	return float64(s.FailedLogins) / float64(attempts)
 }
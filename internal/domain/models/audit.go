@@ -0,0 +1,113 @@
+package models
+
+import "time"
+
+type AuditEventType string
+
+const (
+	AuditRefreshTokenReuse  AuditEventType = "refresh_token_reuse"
+	AuditRevokedTokenReplay AuditEventType = "revoked_token_replay"
+	AuditAccountLocked      AuditEventType = "account_locked"
+	AuditImpersonation      AuditEventType = "impersonation"
+	// AuditAccountDeletionScheduled and AuditAccountDeleted tell downstream
+	// services that a user asked to delete the account and that the data of
+	// the user is gone, so that they delete theirs.
+	AuditAccountDeletionScheduled AuditEventType = "account_deletion_scheduled"
+	AuditAccountDeleted           AuditEventType = "account_deleted"
+	// AuditConsentGranted and AuditConsentRevoked track what users let
+	// third-party apps access.
+	AuditConsentGranted AuditEventType = "consent_granted"
+	AuditConsentRevoked AuditEventType = "consent_revoked"
+	// AuditUserDeleted and AuditUserRestored track admins soft-deleting
+	// users and undoing it. AuditAccountDeleted follows once a deleted user
+	// is purged.
+	AuditUserDeleted  AuditEventType = "user_deleted"
+	AuditUserRestored AuditEventType = "user_restored"
+	// AuditUserSuspended and AuditUserUnsuspended track admins banning users
+	// and lifting it.
+	AuditUserSuspended   AuditEventType = "user_suspended"
+	AuditUserUnsuspended AuditEventType = "user_unsuspended"
+	// AuditUserImported tracks admins bringing users over from other systems.
+	AuditUserImported AuditEventType = "user_imported"
+	// AuditUserDataExported tracks admins exporting the data of users, as
+	// for data portability requests.
+	AuditUserDataExported AuditEventType = "user_data_exported"
+	// AuditUserMerged tracks admins merging a duplicate user into another.
+	// It is recorded for both users.
+	AuditUserMerged AuditEventType = "user_merged"
+	// AuditUserExpirySet tracks admins setting or extending the expiry of
+	// users, AuditUserExpired accounts being disabled or deleted on expiry.
+	AuditUserExpirySet AuditEventType = "user_expiry_set"
+	AuditUserExpired   AuditEventType = "user_expired"
+	// AuditPasswordChangeForced tracks admins making users set a new
+	// password.
+	AuditPasswordChangeForced AuditEventType = "password_change_forced"
+	// AuditRoleAssigned and AuditRoleRevoked track admins granting roles to
+	// users and taking them, within the app of the event or globally.
+	AuditRoleAssigned AuditEventType = "role_assigned"
+	AuditRoleRevoked  AuditEventType = "role_revoked"
+	// AuditGroupRoleAssigned and AuditGroupRoleRevoked track admins changing
+	// the roles groups carry. They are not related to a user.
+	AuditGroupRoleAssigned AuditEventType = "group_role_assigned"
+	AuditGroupRoleRevoked  AuditEventType = "group_role_revoked"
+	// AuditAppCreated, AuditAppUpdated, AuditAppStatusChanged and
+	// AuditAppSecretRotated track admins managing apps. The detail of
+	// AuditAppStatusChanged is the new status. AuditAppDisabled was recorded
+	// for disabled apps before apps had statuses.
+	AuditAppCreated       AuditEventType = "app_created"
+	AuditAppUpdated       AuditEventType = "app_updated"
+	AuditAppStatusChanged AuditEventType = "app_status_changed"
+	AuditAppDisabled      AuditEventType = "app_disabled"
+	AuditAppSecretRotated AuditEventType = "app_secret_rotated"
+	// AuditOrgCreated tracks admins creating orgs, and AuditUserOrgChanged
+	// moving users to another org, named by its id in the detail.
+	AuditOrgCreated     AuditEventType = "org_created"
+	AuditUserOrgChanged AuditEventType = "user_org_changed"
+	// AuditOrgInvitationCreated, AuditOrgInvitationAccepted and
+	// AuditOrgInvitationDeclined track invitations to join an org, and
+	// AuditOrgMemberRoleChanged and AuditOrgMemberRemoved admins managing
+	// the members of an org. The org is named by its id in the detail.
+	AuditOrgInvitationCreated  AuditEventType = "org_invitation_created"
+	AuditOrgInvitationAccepted AuditEventType = "org_invitation_accepted"
+	AuditOrgInvitationDeclined AuditEventType = "org_invitation_declined"
+	AuditOrgMemberRoleChanged  AuditEventType = "org_member_role_changed"
+	AuditOrgMemberRemoved      AuditEventType = "org_member_removed"
+	// AuditAPIKeyCreated and AuditAPIKeyRevoked track admins managing the
+	// API keys of apps. The detail is the prefix of the key.
+	AuditAPIKeyCreated AuditEventType = "api_key_created"
+	AuditAPIKeyRevoked AuditEventType = "api_key_revoked"
+	// AuditLoginSucceeded tracks users logging in to apps and
+	// AuditLoginFailed password logins to apps failing on wrong credentials,
+	// whose user is not always known. App stats are counted from them.
+	AuditLoginSucceeded AuditEventType = "login_succeeded"
+	AuditLoginFailed    AuditEventType = "login_failed"
+)
+
+// AuditEvent is a security relevant event kept in the audit log.
+type AuditEvent struct {
+	ID   int64
+	Type AuditEventType
+	// UserID and AppID are zero if the event is not related to a user or an app.
+	UserID int64
+	AppID  int
+	// ActorID is the user who acted on behalf of UserID, if any.
+	ActorID int64
+	// TokenID is the jti of the token involved, if any.
+	TokenID string
+	// Detail names what the event is about besides the user and the app,
+	// as the role of role events and group/role for groups.
+	Detail    string
+	CreatedAt time.Time
+}
+
+// AuditEventFilter selects audit events. Zero fields do not filter.
+type AuditEventFilter struct {
+	Type   AuditEventType
+	UserID int64
+	// OrgID selects the events of users and apps of the org.
+	OrgID int64
+	// AfterID skips the events up to the one with the id, so that consumers
+	// can page through the log in order.
+	AfterID int64
+	Limit   int
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Consent is what a user allowed a third-party app to access.
+type Consent struct {
+	UserID  int64
+	AppID   int
+	AppName string
+	// Scopes the app may get in tokens of the user.
+	Scopes    []string
+	GrantedAt time.Time
+}
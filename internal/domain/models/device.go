@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type DeviceAuthorizationStatus string
+
+const (
+	DeviceAuthorizationPending  DeviceAuthorizationStatus = "pending"
+	DeviceAuthorizationApproved DeviceAuthorizationStatus = "approved"
+	DeviceAuthorizationDenied   DeviceAuthorizationStatus = "denied"
+	DeviceAuthorizationConsumed DeviceAuthorizationStatus = "consumed"
+)
+
+// DeviceAuthorization is a pending login of a device (RFC 8628).
+type DeviceAuthorization struct {
+	ID             int64
+	DeviceCodeHash string
+	UserCode       string
+	AppID          int
+	// UserID is set once a user approves or denies the device.
+	UserID       int64
+	Status       DeviceAuthorizationStatus
+	ExpiresAt    time.Time
+	LastPolledAt *time.Time
+	CreatedAt    time.Time
+}
+
+// DeviceCode is what the device gets when it starts the authorization.
+type DeviceCode struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresIn               time.Duration
+	Interval                time.Duration
+}
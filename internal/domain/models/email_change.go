@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// EmailChangeToken confirms that the user owns NewEmail before the email of
+// the user is changed to it.
+type EmailChangeToken struct {
+	ID        int64
+	TokenHash string
+	UserID    int64
+	NewEmail  string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// EmailVerificationToken proves that the user owns Email once it is used.
+type EmailVerificationToken struct {
+	ID        int64
+	TokenHash string
+	UserID    int64
+	Email     string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// FederationState is a login started at an upstream identity provider. It
+// is looked up by the state parameter when the user returns.
+type FederationState struct {
+	ID        int64
+	StateHash string
+	Provider  string
+	AppID     int
+	Scopes    []string
+	// Nonce is expected in the ID token and CodeVerifier is the PKCE
+	// verifier of the authorization code.
+	Nonce        string
+	CodeVerifier string
+	// Redirect is where the user is sent back to with the tokens. Without
+	// it, the callback answers like the token endpoint.
+	Redirect  ClientRedirect
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
+
+// ClientRedirect is the registered URI the client asked the user to be
+// sent back to after an authorization, with the State it passed.
+type ClientRedirect struct {
+	URI   string
+	State string
+}
+
+// UserIdentity links a user to the Subject of an upstream identity provider.
+type UserIdentity struct {
+	ID        int64
+	UserID    int64
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
+
+// IdentityProvider is an upstream OpenID Connect provider configured by an
+// operator. Its endpoints are read from the discovery document of Issuer.
+type IdentityProvider struct {
+	ID           int64
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Scopes requested besides openid.
+	Scopes []string
+	// Claims name the ID token claims user attributes are read from.
+	Claims    IdentityClaims
+	CreatedAt time.Time
+}
+
+type IdentityClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified string
+	Name          string
+}
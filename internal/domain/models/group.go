@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Group gives its members the roles it carries, on top of the roles
+// assigned to them directly.
+type Group struct {
+	ID          int64
+	Name        string
+	Description string
+	Roles       []string
+	CreatedAt   time.Time
+}
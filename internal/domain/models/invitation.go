@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Invitation lets the invited email register once, before it expires, on
+// deployments where open registration is closed.
+type Invitation struct {
+	ID        int64
+	TokenHash string
+	Email     string
+	// IsAdmin makes the invited user an admin.
+	IsAdmin bool
+	// AppID is the app the user is logged in to on accepting, if set.
+	AppID int
+	// InvitedBy is the admin who created the invitation.
+	InvitedBy  int64
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	AcceptedAt *time.Time
+	// UserID is the user registered with the invitation once accepted.
+	UserID int64
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Login is a successful login of a user, kept in the login history of the
+// user so that users and admins can spot suspicious access.
+type Login struct {
+	ID     int64
+	UserID int64
+	AppID  int
+	// IP and UserAgent are those of the client that logged in.
+	IP        string
+	UserAgent string
+	// Device describes the user agent for people to recognize it.
+	Device string
+	// Methods are the amr methods the user authenticated with.
+	Methods   []string
+	CreatedAt time.Time
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// MagicLink logs the user into the app with Scopes without a password once
+// it is redeemed.
+type MagicLink struct {
+	ID        int64
+	TokenHash string
+	UserID    int64
+	AppID     int
+	Scopes    []string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+// TOTP is the authenticator app secret of a user. Secret is encrypted at rest.
+// MFA is enabled for the user once the enrollment is confirmed with a code.
+type TOTP struct {
+	UserID int64
+	Secret string
+	// LastUsedStep is the time step of the last accepted code, so a code can
+	// not be used twice.
+	LastUsedStep int64
+	CreatedAt    time.Time
+	ConfirmedAt  *time.Time
+}
+
+// TOTPEnrollment is shown to the user once to set up an authenticator app.
+type TOTPEnrollment struct {
+	Secret string
+	// URI is the otpauth URI, usually rendered as a QR code.
+	URI string
+	// RecoveryCodes replace TOTP codes when the user lost the authenticator.
+	// Each works once.
+	RecoveryCodes []string
+}
+
+// MFAChallenge is a login that passed the password check and waits for the
+// second factor.
+type MFAChallenge struct {
+	ID        int64
+	TokenHash string
+	UserID    int64
+	AppID     int
+	Scopes    []string
+	// AuthMethods are the amr values of the first factor.
+	AuthMethods []string
+	Attempts    int
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
+	UsedAt      *time.Time
+}
+
+// SMSCodePurpose is what an SMS code is sent for.
+type SMSCodePurpose string
+
+const (
+	SMSPhoneVerification SMSCodePurpose = "phone_verification"
+	SMSLogin             SMSCodePurpose = "login"
+	SMSMFA               SMSCodePurpose = "mfa"
+)
+
+// SMSCode is a one-time code texted to Phone. Only the latest code of a user
+// for a purpose is valid.
+type SMSCode struct {
+	ID        int64
+	UserID    int64
+	Phone     string
+	Purpose   SMSCodePurpose
+	CodeHash  string
+	Attempts  int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
+
+// TrustedDevice is a device the user chose to skip the second factor on
+// after passing it there. The device proves itself with a token that only
+// works together with the fingerprint it was issued for.
+type TrustedDevice struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	// FingerprintHash is the hash of the user agent the token was issued to.
+	FingerprintHash string
+	// IP is the address of the last login from the device.
+	IP string
+	// Device describes the user agent for people to recognize the device.
+	Device     string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// NotificationPreferences are the emails a user agrees to get. Emails users
+// ask for, such as password reset links, are sent regardless.
+type NotificationPreferences struct {
+	UserID int64
+	// SecurityEmails tell the user about changes to the account, such as
+	// its email being changed or its deletion being scheduled.
+	SecurityEmails bool
+	// LoginAlerts tell the user about logins from devices and networks not
+	// seen in the login history.
+	LoginAlerts bool
+	// Marketing is the opt-in to marketing emails, which other services
+	// send after checking it.
+	Marketing bool
+	// UpdatedAt is zero until the user sets the preferences.
+	UpdatedAt time.Time
+}
+
+// DefaultNotificationPreferences are the preferences of users who have not
+// set theirs: security emails only, as marketing needs an opt-in.
+func DefaultNotificationPreferences(userID int64) NotificationPreferences {
+	return NotificationPreferences{
+		UserID:         userID,
+		SecurityEmails: true,
+	}
+}
+
+// NotificationPreferencesUpdate changes the preferences that are set and
+// keeps the others.
+type NotificationPreferencesUpdate struct {
+	SecurityEmails *bool
+	LoginAlerts    *bool
+	Marketing      *bool
+}
+
+// Notification is a kind of email users choose whether to get.
+type Notification string
+
+const (
+	NotificationSecurity   Notification = "security"
+	NotificationLoginAlert Notification = "login_alert"
+	NotificationMarketing  Notification = "marketing"
+)
+
+// Allows tells whether the user wants the notification.
+func (p NotificationPreferences) Allows(n Notification) bool {
+	switch n {
+	case NotificationSecurity:
+		return p.SecurityEmails
+	case NotificationLoginAlert:
+		return p.LoginAlerts
+	case NotificationMarketing:
+		return p.Marketing
+	default:
+		return false
+	}
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// DefaultOrgID is the org users and apps belong to unless they are put in
+// another one. Its admins manage every org.
+const DefaultOrgID int64 = 1
+
+// Org is an organization, a tenant users and apps belong to. Admins of an
+// org other than the default one only manage its users and apps.
+type Org struct {
+	ID        int64
+	Name      string
+	CreatedAt time.Time
+}
+
+// Roles of the members of an org. Admins of an org are its members with the
+// admin role.
+const (
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// OrgRole returns the role of the user within their org.
+func OrgRole(user User) string {
+	if user.IsAdmin {
+		return OrgRoleAdmin
+	}
+
+	return OrgRoleMember
+}
+
+// OrgInvitation lets the user with the invited email join the org with the
+// role, once, before it expires. Invitees join with an account of their own,
+// registering first if they have none.
+type OrgInvitation struct {
+	ID        int64
+	TokenHash string
+	OrgID     int64
+	Email     string
+	Role      string
+	// InvitedBy is the admin who created the invitation.
+	InvitedBy  int64
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	AcceptedAt *time.Time
+	DeclinedAt *time.Time
+	// UserID is the user who accepted the invitation.
+	UserID int64
+}
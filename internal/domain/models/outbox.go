@@ -0,0 +1,24 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Types of the outbox events recorded besides the audit events, which are
+// recorded with the type of the audit event.
+const (
+	OutboxUserRegistered = "user_registered"
+	OutboxSessionStarted = "session_started"
+)
+
+// OutboxEvent is an event recorded in the same transaction as the change
+// it is about, waiting to be published. Payload is a JSON object whose
+// fields depend on Type.
+type OutboxEvent struct {
+	ID        int64
+	Type      string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+	Attempts  int
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PasswordResetToken lets the user set a new password once, before it expires.
+type PasswordResetToken struct {
+	ID        int64
+	TokenHash string
+	UserID    int64
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// PolicyRule is a rule of the policy engine: a policy type of its model, as
+// "p", and the values of the fields the model defines for the type.
+type PolicyRule struct {
+	ID        int64
+	Type      string
+	Values    []string
+	CreatedAt time.Time
+}
+
+// Resource is what a permission is checked on, so that policies can decide
+// by it, as owners editing their own resources. It is zero for checks not
+// about a single resource.
+type Resource struct {
+	Type    string
+	ID      string
+	OwnerID int64
+}
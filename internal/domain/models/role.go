@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Role grants its permissions to the users it is assigned to. Permissions
+// are names like "invoices:read" that apps check.
+type Role struct {
+	ID          int64
+	Name        string
+	Description string
+	Permissions []string
+	// Conditions restrict permissions of the role, by name, to the checks
+	// their expression holds for, see policy.Condition. Permissions without
+	// a condition are granted unconditionally.
+	Conditions map[string]string
+	// Inherits are the roles the role implies: users with the role have
+	// those too, and the roles these inherit in turn.
+	Inherits  []string
+	CreatedAt time.Time
+}
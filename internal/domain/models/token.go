@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+type TokenIntrospection struct {
+	Active bool
+	// UserID is zero for tokens issued to apps themselves.
+	UserID   int64
+	AppID    int
+	Issuer   string
+	Audience string
+	Scopes   []string
+	// AuthTime, AuthMethods and ACR describe the authentication of the user
+	// (RFC 9470). They are empty for tokens that do not come from a login.
+	AuthTime    time.Time
+	AuthMethods []string
+	ACR         string
+	ExpiresAt   time.Time
+}
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+	// IDToken is the OpenID Connect ID token, issued for user logins only.
+	IDToken string
+	// Scopes granted to the access token.
+	Scopes []string
+	// MFAToken is set instead of the tokens when the login needs a second
+	// factor to complete.
+	MFAToken string
+	// PasswordChangeToken is set instead of the tokens when an admin
+	// required the user to set a new password. It is a password reset
+	// token.
+	PasswordChangeToken string
+	// DeviceToken is set when the user chose to trust the device the MFA
+	// login was completed on.
+	DeviceToken string
+}
+
+type RefreshToken struct {
+	ID        int64
+	TokenHash string
+	// FamilyID is shared by all tokens obtained by rotating the same login.
+	FamilyID string
+	UserID   int64
+	AppID    int
+	// Scopes are granted again to tokens obtained with the refresh token.
+	Scopes []string
+	// AuthMethods are the amr values of the login the token family comes from
+	// and of the step-ups since.
+	AuthMethods []string
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
+	// SessionStartedAt is the time of the login the token family comes from.
+	SessionStartedAt time.Time
+	// AuthTime is when the user last proved a factor: the login, or the
+	// latest step-up.
+	AuthTime  time.Time
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+}
+
+// Session holds the claims of an opaque access token on the server side.
+type Session struct {
+	// TokenHash is the hash of the opaque token, the token itself is not stored.
+	TokenHash string
+	AppID     int
+	Claims    map[string]any
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// TokenIssuance records an access token issued by the service.
+type TokenIssuance struct {
+	// ID is the jti claim of the token.
+	ID string
+	// UserID is zero for tokens issued to apps themselves.
+	UserID    int64
+	AppID     int
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
@@ -1,7 +1,188 @@
 package models
 
+import "time"
+
 type User struct {
-	ID       int
-	Email    string
-	PassHash string
+	ID    int
+	Email string
+	// Username is the optional, normalized name the user can log in with
+	// instead of the email.
+	Username    string
+	DisplayName string
+	// FirstName, LastName, Locale and AvatarURL are the optional profile of
+	// the user, see UserProfile.
+	FirstName string
+	LastName  string
+	Locale    string
+	AvatarURL string
+	PassHash  string
+	// EmailVerified is set once the user has confirmed owning the email.
+	EmailVerified bool
+	// IsGuest is set for anonymous users until they upgrade to a full
+	// account. Guests have a placeholder email and no password.
+	IsGuest bool
+	// IsAdmin users manage the users and apps of their org, or everything
+	// if they belong to the default org.
+	IsAdmin bool
+	// OrgID is the org the user belongs to.
+	OrgID int64
+	// Phone is the verified phone number of the user in E.164 format, if any.
+	Phone         string
+	PhoneVerified bool
+	// TokenVersion is embedded into issued tokens. Bumping it invalidates
+	// every token issued before.
+	TokenVersion int64
+	// FailedLogins counts the recent failed password logins. Logins fail
+	// with a locked account until LockedUntil.
+	FailedLogins int
+	LockedUntil  *time.Time
+	// DeletionScheduledAt is when the account the user asked to delete is
+	// anonymized. Logins fail until then, unless the user cancels the
+	// deletion.
+	DeletionScheduledAt *time.Time
+	// Status is UserStatusActive unless an admin suspends the user.
+	Status UserStatus
+	// DeletedAt is set once an admin deletes the user or the account is
+	// anonymized. Deleted users can not log in, and admins can restore them
+	// until they are anonymized at DeletionScheduledAt.
+	DeletedAt *time.Time
+	// ExpiresAt is when the account of a temporary user expires, if it
+	// does. Logins fail from then on, until an admin extends the expiry.
+	// ExpiredAt is set once the expired account is disabled or deleted.
+	ExpiresAt *time.Time
+	ExpiredAt *time.Time
+	// PasswordChangeRequired is set by admins, as after a credential
+	// stuffing incident. Logins only let the user set a new password until
+	// then.
+	PasswordChangeRequired bool
+	// LastLoginAt, LastLoginIP and LastLoginUserAgent tell when and from
+	// where the user last logged in, if ever.
+	LastLoginAt        *time.Time
+	LastLoginIP        string
+	LastLoginUserAgent string
+	// CreatedAt and UpdatedAt are zero for users that predate their
+	// tracking. UpdatedAt follows changes of the profile and credentials,
+	// not of login bookkeeping.
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// Version grows with every change UpdatedAt follows, so that writers can
+	// detect concurrent changes.
+	Version int64
+	// AppMetadata is set by admins and apps acting through the admin API,
+	// UserMetadata by the user as well. Both are small string maps, see
+	// package metadata for their limits.
+	AppMetadata  map[string]string
+	UserMetadata map[string]string
+	// Groups are the names of the groups of the user. They are only loaded
+	// for tokens that carry them.
+	Groups []string
+	// Roles are the names of the roles the user has within the app tokens
+	// are issued for, including those of its groups. They are only loaded
+	// for tokens that carry them.
+	Roles []string
+}
+
+// UserProfile is what users tell about themselves besides their
+// credentials. All fields are optional. Locale is a BCP 47 language tag and
+// AvatarURL an https URL, see package profile for the limits.
+type UserProfile struct {
+	FirstName   string
+	LastName    string
+	DisplayName string
+	Locale      string
+	AvatarURL   string
+}
+
+// UserStatus tells whether the user may log in.
+type UserStatus string
+
+const (
+	UserStatusActive UserStatus = "active"
+	// UserStatusSuspended users can not log in until an admin unsuspends
+	// them.
+	UserStatusSuspended UserStatus = "suspended"
+)
+
+// User fields a UserUpdate can patch.
+const (
+	UserFieldEmail        = "email"
+	UserFieldUsername     = "username"
+	UserFieldDisplayName  = "display_name"
+	UserFieldFirstName    = "first_name"
+	UserFieldLastName     = "last_name"
+	UserFieldLocale       = "locale"
+	UserFieldAvatarURL    = "avatar_url"
+	UserFieldAppMetadata  = "app_metadata"
+	UserFieldUserMetadata = "user_metadata"
+)
+
+// UserUpdate patches the fields of a user named in Fields, like a field
+// mask, and leaves the others as they are. Setting the email resets its
+// verification.
+type UserUpdate struct {
+	UserID      int64
+	Fields      []string
+	Email       string
+	Username    string
+	DisplayName string
+	FirstName   string
+	LastName    string
+	Locale      string
+	AvatarURL   string
+	// AppMetadata and UserMetadata replace the metadata of the kind as a
+	// whole.
+	AppMetadata  map[string]string
+	UserMetadata map[string]string
+	// Version must be the current version of the user for the update to
+	// apply. Zero skips the check.
+	Version int64
+}
+
+// UserSort is the key listed users are ordered by. Ties are broken by id.
+type UserSort string
+
+const (
+	UserSortCreatedAt UserSort = "created_at"
+	UserSortEmail     UserSort = "email"
+)
+
+// UserFilter selects the users to list. Zero fields do not filter.
+type UserFilter struct {
+	// EmailPrefix matches the start of the email case-insensitively.
+	EmailPrefix string
+	// Search matches users whose email, username or display name has words
+	// starting with each word of it.
+	Search        string
+	EmailVerified *bool
+	Admin         *bool
+	OrgID         int64
+	CreatedAfter  time.Time
+	Sort          UserSort
+	Desc          bool
+	// After skips the users up to the one it points at in the sort order,
+	// so that callers can page through the users.
+	After *UserCursor
+	Limit int
+}
+
+// UserCursor points at a user in a listing by the sort keys of the user.
+type UserCursor struct {
+	ID        int64
+	CreatedAt time.Time
+	Email     string
+}
+
+// UserImport is a user migrated from another system. PassHash is the hash
+// of the password there, see passhash.Compare for the formats. Users
+// imported without one have to reset their password to log in.
+type UserImport struct {
+	Email         string
+	PassHash      string
+	EmailVerified bool
+	Username      string
+	DisplayName   string
+	AppMetadata   map[string]string
+	UserMetadata  map[string]string
+	// OrgID is the org the user is imported into.
+	OrgID int64
 }
@@ -0,0 +1,15 @@
+package models
+
+// UserDataChunk is a part of the data of a user exported for portability.
+// The first chunk of an export carries the user and the records of few
+// rows, the following ones the audit events in order, so that long
+// histories do not have to be held at once.
+type UserDataChunk struct {
+	User           *User
+	Sessions       []UserSession
+	Consents       []Consent
+	Identities     []UserIdentity
+	TrustedDevices []TrustedDevice
+	LoginHistory   []Login
+	AuditEvents    []AuditEvent
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// UserSession is a login of a user that can be kept alive with refresh
+// tokens. It lasts as long as the refresh token family of the login.
+type UserSession struct {
+	ID       int64
+	FamilyID string
+	UserID   int64
+	AppID    int
+	// IP and UserAgent are those of the last login or refresh.
+	IP        string
+	UserAgent string
+	// Device describes the user agent for people to recognize the session.
+	Device     string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebAuthnCredential is a passkey registered by a user.
+type WebAuthnCredential struct {
+	ID           int64
+	UserID       int64
+	CredentialID []byte
+	// Data is the JSON encoded credential record: the public key, the
+	// signature counter and the authenticator flags.
+	Data       []byte
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// WebAuthnCeremony is either a registration or a login.
+type WebAuthnCeremony string
+
+const (
+	WebAuthnRegistration WebAuthnCeremony = "registration"
+	WebAuthnLogin        WebAuthnCeremony = "login"
+)
+
+// WebAuthnSession keeps the challenge of a ceremony between its begin and
+// finish steps. UserID is zero for logins, AppID and Scopes are set for
+// logins only.
+type WebAuthnSession struct {
+	ID        int64
+	TokenHash string
+	Ceremony  WebAuthnCeremony
+	UserID    int64
+	AppID     int
+	Scopes    []string
+	// Data is the JSON encoded session data of the ceremony.
+	Data      []byte
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
+
+// WebAuthnOptions are sent to the browser to begin a ceremony. Session
+// identifies the ceremony when it is finished.
+type WebAuthnOptions struct {
+	Session string
+	Options json.RawMessage
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"slices"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/tokens"
+	"sso/internal/services/auth"
+	"strings"
+)
+
+// AuthorizationMetadataKey carries the access token of the caller of
+// protected methods as "Bearer <token>".
+const AuthorizationMetadataKey = "authorization"
+
+// Authorizer validates the tokens of callers and tells their roles.
+type Authorizer interface {
+	ValidateToken(ctx context.Context, token string, audience string) (tokens.Claims, error)
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+	UserRoles(ctx context.Context, userID int64, appID int) ([]string, error)
+}
+
+type callerKey struct{}
+
+// CallerFromContext returns the claims of the token the caller of a
+// protected method authorized with.
+func CallerFromContext(ctx context.Context) (tokens.Claims, bool) {
+	claims, ok := ctx.Value(callerKey{}).(tokens.Claims)
+
+	return claims, ok
+}
+
+// AuthzInterceptor makes the methods of methodRoles require an access token
+// issued for audience, of a user with one of the roles listed for the
+// method within that app. auth.RoleAdmin stands for admins of every
+// app, as admin methods are not about a single app. Tokens of someone
+// acting on behalf of the user, such as impersonation tokens, are refused.
+// Other methods are left open.
+func AuthzInterceptor(log *slog.Logger, authorizer Authorizer, audience string, methodRoles map[string][]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		required, ok := methodRoles[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		token, ok := bearerToken(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "access token required")
+		}
+
+		claims, err := authorizer.ValidateToken(ctx, token, audience)
+		if err != nil {
+			if errors.Is(err, auth.ErrWrongAudience) {
+				return nil, status.Error(codes.PermissionDenied, "permission denied")
+			}
+			if errors.Is(err, auth.ErrInvalidToken) {
+				return nil, status.Error(codes.Unauthenticated, "invalid access token")
+			}
+
+			log.Error("failed to validate token", slog.String("method", info.FullMethod), sl.Err(err))
+			return nil, internalError(err)
+		}
+
+		if claims.UserID == 0 || claims.Actor != nil {
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		}
+
+		allowed, err := hasRequiredRole(ctx, authorizer, claims, required)
+		if err != nil {
+			if errors.Is(err, auth.ErrUserNotFound) {
+				return nil, status.Error(codes.Unauthenticated, "invalid access token")
+			}
+
+			log.Error("failed to check roles", slog.String("method", info.FullMethod), sl.Err(err))
+			return nil, internalError(err)
+		}
+		if !allowed {
+			log.Warn("caller lacks required role",
+				slog.String("method", info.FullMethod),
+				slog.Int64("user_id", claims.UserID),
+			)
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		}
+
+		return handler(context.WithValue(ctx, callerKey{}, claims), req)
+	}
+}
+
+// hasRequiredRole tells whether the user of the claims has one of the
+// roles.
+func hasRequiredRole(ctx context.Context, authorizer Authorizer, claims tokens.Claims, required []string) (bool, error) {
+	if slices.Contains(required, auth.RoleAdmin) {
+		isAdmin, err := authorizer.IsAdmin(ctx, claims.UserID)
+		if err != nil || isAdmin {
+			return isAdmin, err
+		}
+	}
+
+	roles, err := authorizer.UserRoles(ctx, claims.UserID, claims.AppID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, role := range roles {
+		// admins of the app are not admins of every app
+		if role != auth.RoleAdmin && slices.Contains(required, role) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// bearerToken extracts the token from the authorization metadata.
+func bearerToken(ctx context.Context) (string, bool) {
+	const prefix = "Bearer "
+
+	values := metadata.ValueFromIncomingContext(ctx, AuthorizationMetadataKey)
+	if len(values) == 0 {
+		return "", false
+	}
+
+	value := values[0]
+	if len(value) <= len(prefix) || !strings.EqualFold(value[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	return value[len(prefix):], true
+}
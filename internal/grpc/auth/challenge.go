@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"net/netip"
+	"sso/internal/lib/captcha"
+	"sso/internal/lib/logger/sl"
+)
+
+// ChallengeMetadataKey is the metadata key clients send the token of a
+// solved challenge in. The requests have no field for it.
+const ChallengeMetadataKey = "x-challenge-token"
+
+// ChallengeVerifier checks the token of a solved challenge, such as a CAPTCHA.
+// It fails with captcha.ErrChallengeFailed if the token is not valid.
+type ChallengeVerifier interface {
+	Verify(ctx context.Context, token string, remoteIP string) error
+}
+
+// challengedMethods are the methods bots call.
+var challengedMethods = map[string]bool{
+	ssov1.Auth_Register_FullMethodName: true,
+	ssov1.Auth_Login_FullMethodName:    true,
+}
+
+// ChallengeInterceptor requires a solved challenge on Register and Login,
+// except from callers with an address in one of the bypass prefixes, such as
+// trusted internal services.
+func ChallengeInterceptor(log *slog.Logger, verifier ChallengeVerifier, bypass []netip.Prefix) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !challengedMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		addr := peerAddr(ctx)
+		for _, prefix := range bypass {
+			if addr.IsValid() && prefix.Contains(addr) {
+				return handler(ctx, req)
+			}
+		}
+
+		var token string
+		if values := metadata.ValueFromIncomingContext(ctx, ChallengeMetadataKey); len(values) > 0 {
+			token = values[0]
+		}
+
+		var remoteIP string
+		if addr.IsValid() {
+			remoteIP = addr.String()
+		}
+
+		if err := verifier.Verify(ctx, token, remoteIP); err != nil {
+			if errors.Is(err, captcha.ErrChallengeFailed) {
+				return nil, status.Error(codes.PermissionDenied, "challenge required")
+			}
+
+			log.Error("failed to verify challenge",
+				slog.String("method", info.FullMethod),
+				sl.Err(err),
+			)
+			return nil, status.Error(codes.Unavailable, "challenge cannot be verified")
+		}
+
+		return handler(ctx, req)
+	}
+}
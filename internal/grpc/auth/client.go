@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"net/netip"
+	"sso/internal/lib/clientinfo"
+)
+
+// DeviceTokenMetadataKey carries the token of a trusted device, which lets
+// Login skip the second factor.
+const DeviceTokenMetadataKey = "x-device-token"
+
+// ClientInfoInterceptor passes the address, the user agent and the device
+// token of the caller to the handlers in the context.
+func ClientInfoInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var client clientinfo.Client
+		if addr := peerAddr(ctx); addr.IsValid() {
+			client.IP = addr.String()
+		}
+		if values := metadata.ValueFromIncomingContext(ctx, "user-agent"); len(values) > 0 {
+			client.UserAgent = values[0]
+		}
+		if values := metadata.ValueFromIncomingContext(ctx, DeviceTokenMetadataKey); len(values) > 0 {
+			client.DeviceToken = values[0]
+		}
+
+		return handler(clientinfo.NewContext(ctx, client), req)
+	}
+}
+
+// peerAddr returns the IP address of the caller, or the zero address if it
+// is not known.
+func peerAddr(ctx context.Context) netip.Addr {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return netip.Addr{}
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	return addr.Unmap()
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"math"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/services/auth"
+	"strconv"
+)
+
+// AppIDMetadataKey is the metadata key clients send the id of the app they
+// register users through in. RegisterRequest has no field for it.
+const AppIDMetadataKey = "x-app-id"
+
+// RetryAfterMetadataKey is the header metadata key that tells callers over
+// their quota after how many seconds to retry.
+const RetryAfterMetadataKey = "retry-after"
+
+// QuotaChecker counts requests against the quotas of apps.
+type QuotaChecker interface {
+	UseAppQuota(ctx context.Context, appID int, quota string) error
+}
+
+// QuotaInterceptor counts Login against the login quota of the app of the
+// request and Register against the registration quota of the app named in
+// the AppIDMetadataKey metadata. Registrations without it are not counted.
+// Requests over quota fail with ResourceExhausted.
+func QuotaInterceptor(log *slog.Logger, checker QuotaChecker) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var appID int
+		var quota string
+		switch r := req.(type) {
+		case *ssov1.LoginRequest:
+			appID, quota = int(r.GetAppId()), models.QuotaLogins
+		case *ssov1.RegisterRequest:
+			if values := metadata.ValueFromIncomingContext(ctx, AppIDMetadataKey); len(values) > 0 {
+				appID, _ = strconv.Atoi(values[0])
+			}
+			quota = models.QuotaRegistrations
+		}
+		if appID <= 0 {
+			return handler(ctx, req)
+		}
+
+		if err := checker.UseAppQuota(ctx, appID, quota); err != nil {
+			var exceeded *auth.QuotaExceededError
+			if errors.As(err, &exceeded) {
+				retryAfter := strconv.Itoa(int(math.Ceil(exceeded.RetryAfter.Seconds())))
+				if err = grpc.SetHeader(ctx, metadata.Pairs(RetryAfterMetadataKey, retryAfter)); err != nil {
+					log.Error("failed to set retry-after", slog.String("method", info.FullMethod), sl.Err(err))
+				}
+				return nil, status.Errorf(codes.ResourceExhausted, "%s quota of the app exceeded", quota)
+			}
+
+			log.Error("failed to check quota", slog.String("method", info.FullMethod), sl.Err(err))
+			return nil, internalError(err)
+		}
+
+		return handler(ctx, req)
+	}
+}
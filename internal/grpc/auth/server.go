@@ -6,34 +6,42 @@ import (
 	"fmt"
 	ssov1 "github.com/SamEkb/protos/gen/go/sso"
 	"github.com/go-playground/validator/v10"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"sso/internal/domain/models"
+	"sso/internal/lib/username"
 	"sso/internal/services/auth"
+	"strings"
 )
 
 type Auth interface {
 	Login(ctx context.Context,
-		email string,
+		login string,
 		password string,
 		appID int,
-	) (token string, err error)
+		scopes []string,
+	) (models.TokenPair, error)
 	RegisterNewUser(ctx context.Context,
 		email string,
 		password string,
+		profile models.UserProfile,
 	) (userID int64, err error)
 	IsAdmin(ctx context.Context, userID int64) (bool, error)
 }
 
 type LoginRequestValidation struct {
-	Email    string `validate:"required,email"`
-	Password string `validate:"required,min=6"`
+	// Email is the email or the username of the user
+	Email    string `validate:"required,login"`
+	Password string `validate:"required"`
 	AppId    int32  `validate:"required,gt=0"`
 }
 
 type RegisterRequestValidation struct {
-	Email    string `validate:"required,email"`
-	Password string `validate:"required,min=6,max=32"`
+	Email string `validate:"required,email"`
+	// the password policy is checked by the service
+	Password string `validate:"required"`
 }
 
 type IsAdminRequestValidation struct {
@@ -47,8 +55,33 @@ type serverAPI struct {
 
 const internalServerError = "internal server error"
 
+// internalError is the status of an unexpected error, DeadlineExceeded if
+// the request ran out of time, be it in the storage, and Internal otherwise.
+func internalError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	}
+
+	return status.Error(codes.Internal, internalServerError)
+}
+
 var validate = validator.New()
 
+func init() {
+	validate.RegisterValidation("login", validLogin)
+}
+
+// validLogin accepts an email or a username, trimmed and in any case, as
+// services.auth.Login does.
+func validLogin(fl validator.FieldLevel) bool {
+	login := strings.TrimSpace(fl.Field().String())
+	if username.IsEmail(login) {
+		return validate.Var(login, "email") == nil
+	}
+
+	return username.Valid(username.Normalize(login))
+}
+
 func RegisterServer(gRPC *grpc.Server, auth Auth) {
 	ssov1.RegisterAuthServer(gRPC, &serverAPI{auth: auth})
 }
@@ -64,15 +97,59 @@ func (s *serverAPI) Login(ctx context.Context, req *ssov1.LoginRequest) (*ssov1.
 		return nil, status.Errorf(codes.InvalidArgument, "validation error: %v", validationErrors)
 	}
 
-	token, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()))
+	tokens, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()), nil)
 	if err != nil {
 		if errors.Is(err, auth.ErrInvalidCredentials) {
 			return nil, status.Error(codes.InvalidArgument, "invalid email or password")
 		}
-		return nil, status.Error(codes.Internal, internalServerError)
+		if errors.Is(err, auth.ErrInvalidAppID) {
+			return nil, status.Error(codes.InvalidArgument, "invalid app id")
+		}
+		if errors.Is(err, auth.ErrAppDisabled) {
+			return nil, status.Error(codes.FailedPrecondition, "the app is disabled")
+		}
+		if errors.Is(err, auth.ErrUnauthorizedClient) {
+			return nil, status.Error(codes.PermissionDenied, "the app may not log users in with a password")
+		}
+		if errors.Is(err, auth.ErrOrgMismatch) {
+			return nil, status.Error(codes.PermissionDenied, "the app belongs to another organization")
+		}
+		if errors.Is(err, auth.ErrEmailNotVerified) {
+			return nil, status.Error(codes.FailedPrecondition, "email is not verified")
+		}
+		if errors.Is(err, auth.ErrAccountLocked) {
+			return nil, status.Error(codes.FailedPrecondition, "account is locked")
+		}
+		if errors.Is(err, auth.ErrAccountPendingDeletion) {
+			return nil, status.Error(codes.FailedPrecondition, "account is pending deletion")
+		}
+		if errors.Is(err, auth.ErrAccountSuspended) {
+			return nil, status.Error(codes.PermissionDenied, "account is suspended")
+		}
+		if errors.Is(err, auth.ErrAccountExpired) {
+			return nil, status.Error(codes.PermissionDenied, "account is expired")
+		}
+		if errors.Is(err, auth.ErrConsentRequired) {
+			return nil, status.Error(codes.PermissionDenied, "consent required")
+		}
+		if errors.Is(err, auth.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, "too many failed login attempts, try again later")
+		}
+		var rejected *auth.RejectedError
+		if errors.As(err, &rejected) {
+			return nil, status.Error(codes.PermissionDenied, rejected.Error())
+		}
+		return nil, internalError(err)
+	}
+	if tokens.MFAToken != "" {
+		// LoginResponse has no field for the challenge yet
+		return nil, status.Error(codes.FailedPrecondition, "mfa required, log in over HTTP")
+	}
+	if tokens.PasswordChangeToken != "" {
+		return nil, status.Error(codes.FailedPrecondition, "password change required, log in over HTTP")
 	}
 
-	return &ssov1.LoginResponse{Token: token}, nil
+	return &ssov1.LoginResponse{Token: tokens.AccessToken}, nil
 }
 
 func (s *serverAPI) Register(ctx context.Context, req *ssov1.RegisterRequest) (*ssov1.RegisterResponse, error) {
@@ -85,12 +162,31 @@ func (s *serverAPI) Register(ctx context.Context, req *ssov1.RegisterRequest) (*
 		return nil, status.Errorf(codes.InvalidArgument, "validation error: %v", validationErrors)
 	}
 
-	userID, err := s.auth.RegisterNewUser(ctx, req.GetEmail(), req.GetPassword())
+	// RegisterRequest has no profile fields yet, users fill in their
+	// profile after registering
+	userID, err := s.auth.RegisterNewUser(ctx, req.GetEmail(), req.GetPassword(), models.UserProfile{})
 	if err != nil {
 		if errors.Is(err, auth.ErrUserExists) {
 			return nil, status.Error(codes.AlreadyExists, "user already exists")
 		}
-		return nil, status.Error(codes.Internal, internalServerError)
+		if errors.Is(err, auth.ErrInvalidProfile) {
+			// the reason follows the error in the message
+			_, reason, _ := strings.Cut(err.Error(), auth.ErrInvalidProfile.Error()+": ")
+			return nil, status.Errorf(codes.InvalidArgument, "%s: %s", auth.ErrInvalidProfile, reason)
+		}
+		if errors.Is(err, auth.ErrRegistrationClosed) {
+			// the Register request has no field for the token yet
+			return nil, status.Error(codes.PermissionDenied, "registration requires an invitation, accept it over HTTP")
+		}
+		var weak *auth.WeakPasswordError
+		if errors.As(err, &weak) {
+			return nil, weakPasswordStatus(weak).Err()
+		}
+		var rejected *auth.RejectedError
+		if errors.As(err, &rejected) {
+			return nil, status.Error(codes.PermissionDenied, rejected.Error())
+		}
+		return nil, internalError(err)
 	}
 
 	return &ssov1.RegisterResponse{UserId: userID}, nil
@@ -109,12 +205,34 @@ func (s *serverAPI) IsAdmin(ctx context.Context, req *ssov1.IsAdminRequest) (*ss
 			return nil, status.Error(codes.NotFound, "user not found")
 		}
 
-		return nil, status.Error(codes.Internal, internalServerError)
+		return nil, internalError(err)
 	}
 
 	return &ssov1.IsAdminResponse{IsAdmin: isAdmin}, nil
 }
 
+// weakPasswordStatus lists the violated rules of the password policy as
+// field violations of the password.
+func weakPasswordStatus(weak *auth.WeakPasswordError) *status.Status {
+	st := status.New(codes.InvalidArgument, "password does not meet the policy")
+
+	details := &errdetails.BadRequest{}
+	for _, violation := range weak.Violations {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       "password",
+			Description: "password " + violation.Message,
+			Reason:      violation.Code,
+		})
+	}
+
+	withDetails, err := st.WithDetails(details)
+	if err != nil {
+		return st
+	}
+
+	return withDetails
+}
+
 func formatValidationErrors(err error) []string {
 	validationErrors, ok := err.(validator.ValidationErrors)
 	if !ok {
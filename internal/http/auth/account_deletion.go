@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+	"time"
+)
+
+type accountDeletionResponse struct {
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+}
+
+// deleteAccount schedules the deletion of the account of the user the
+// bearer token was issued to. The user confirms it with the password.
+func (h *handler) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	password := r.PostForm.Get("password")
+	if password == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	deleteAt, err := h.auth.DeleteMyAccount(r.Context(), userID, password)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidGrant})
+		case errors.Is(err, auth.ErrAccountLocked):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errAccountLocked})
+		case errors.Is(err, auth.ErrUserNotFound):
+			writeInvalidToken(w)
+		default:
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, accountDeletionResponse{DeletionScheduledAt: deleteAt})
+}
+
+// cancelAccountDeletion keeps the account the user asked to delete. The
+// user has no tokens left, so it authenticates with the credentials.
+func (h *handler) cancelAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	login, password := r.PostForm.Get("username"), r.PostForm.Get("password")
+	if login == "" || password == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.CancelAccountDeletion(r.Context(), login, password); err != nil {
+		writeTokenError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
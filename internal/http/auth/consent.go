@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/services/auth"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	errConsentRequired = "consent_required"
+	errConsentNotFound = "consent_not_found"
+)
+
+type consentResponse struct {
+	AppID     int       `json:"app_id"`
+	AppName   string    `json:"app_name"`
+	Scope     string    `json:"scope"`
+	GrantedAt time.Time `json:"granted_at"`
+}
+
+type consentsResponse struct {
+	Consents []consentResponse `json:"consents"`
+}
+
+// listConsents returns the third-party apps the user of the bearer token
+// consented to.
+func (h *handler) listConsents(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	consents, err := h.auth.ListConsents(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	resp := consentsResponse{Consents: make([]consentResponse, 0, len(consents))}
+	for _, consent := range consents {
+		resp.Consents = append(resp.Consents, newConsentResponse(consent))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// grantConsent lets the app of client_id get tokens of the user of the
+// bearer token with the scopes. Only the user grants consents: the token
+// must be their own, issued for the first-party app of the account
+// endpoints, never a token of the third-party app asking for consent or a
+// token of someone acting on their behalf.
+func (h *handler) grantConsent(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	accessToken, ok := bearerToken(r)
+	if !ok {
+		writeInvalidToken(w)
+		return
+	}
+
+	claims, err := h.auth.ValidateToken(r.Context(), accessToken, h.audience)
+	if err != nil {
+		if errors.Is(err, auth.ErrWrongAudience) {
+			writeJSON(w, http.StatusForbidden, errorResponse{Error: errAccessDenied})
+			return
+		}
+		writeInvalidToken(w)
+		return
+	}
+	if claims.UserID == 0 {
+		writeInvalidToken(w)
+		return
+	}
+	if claims.Actor != nil {
+		writeJSON(w, http.StatusForbidden, errorResponse{Error: errAccessDenied})
+		return
+	}
+
+	appID, err := strconv.Atoi(r.PostForm.Get("client_id"))
+	if err != nil || appID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	consent, err := h.auth.GrantConsent(r.Context(), claims.UserID, appID, scopes(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidAppID):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidClient})
+		case errors.Is(err, auth.ErrInvalidScope):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidScope})
+		default:
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newConsentResponse(consent))
+}
+
+// revokeConsent stops new tokens of the user of the bearer token for the
+// app.
+func (h *handler) revokeConsent(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	appID, err := strconv.Atoi(r.PathValue("app_id"))
+	if err != nil || appID <= 0 {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: errConsentNotFound})
+		return
+	}
+
+	if err = h.auth.RevokeConsent(r.Context(), userID, appID); err != nil {
+		if errors.Is(err, auth.ErrConsentNotFound) {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: errConsentNotFound})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newConsentResponse(consent models.Consent) consentResponse {
+	return consentResponse{
+		AppID:     consent.AppID,
+		AppName:   consent.AppName,
+		Scope:     strings.Join(consent.Scopes, " "),
+		GrantedAt: consent.GrantedAt,
+	}
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/services/auth"
+	"strconv"
+)
+
+// deviceAuthorizationResponse follows RFC 8628, section 3.2.
+type deviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+const (
+	errAuthorizationPending = "authorization_pending"
+	errSlowDown             = "slow_down"
+	errAccessDenied         = "access_denied"
+	errExpiredToken         = "expired_token"
+	errInvalidCredentials   = "invalid_credentials"
+	errInvalidUserCode      = "invalid_user_code"
+)
+
+const grantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+func (h *handler) deviceAuthorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	appID, err := strconv.Atoi(r.PostForm.Get("client_id"))
+	if err != nil || appID <= 0 {
+		writeInvalidClient(w)
+		return
+	}
+
+	code, err := h.auth.StartDeviceAuthorization(r.Context(), appID)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidAppID) {
+			writeInvalidClient(w)
+			return
+		}
+		if errors.Is(err, auth.ErrUnauthorizedClient) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errUnauthorizedClient})
+			return
+		}
+		if errors.Is(err, auth.ErrAppDisabled) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errAppDisabled})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deviceAuthorizationResponse{
+		DeviceCode:              code.DeviceCode,
+		UserCode:                code.UserCode,
+		VerificationURI:         code.VerificationURI,
+		VerificationURIComplete: code.VerificationURIComplete,
+		ExpiresIn:               int64(code.ExpiresIn.Seconds()),
+		Interval:                int64(code.Interval.Seconds()),
+	})
+}
+
+// deviceVerify is where the user approves or denies a device by its user code.
+func (h *handler) deviceVerify(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	userCode := r.PostForm.Get("user_code")
+	username, password := r.PostForm.Get("username"), r.PostForm.Get("password")
+	otp := r.PostForm.Get("otp")
+
+	var approve bool
+	switch r.PostForm.Get("action") {
+	case "approve":
+		approve = true
+	case "deny":
+		approve = false
+	default:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if userCode == "" || username == "" || password == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.VerifyDevice(r.Context(), userCode, username, password, otp, approve); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: errInvalidCredentials})
+		case errors.Is(err, auth.ErrAccountLocked):
+			writeJSON(w, http.StatusForbidden, errorResponse{Error: errAccountLocked})
+		case errors.Is(err, auth.ErrInvalidMFACode):
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: errInvalidMFACode})
+		case errors.Is(err, auth.ErrInvalidUserCode):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidUserCode})
+		default:
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) deviceCodeGrant(r *http.Request) (models.TokenPair, error) {
+	deviceCode := r.PostForm.Get("device_code")
+	appID, err := strconv.Atoi(r.PostForm.Get("client_id"))
+	if deviceCode == "" || err != nil || appID <= 0 {
+		return models.TokenPair{}, errMalformedRequest
+	}
+
+	return h.auth.DeviceToken(r.Context(), deviceCode, appID)
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+)
+
+const (
+	errInvalidEmailChangeToken = "invalid_email_change_token"
+	errEmailTaken              = "email_taken"
+)
+
+// requestEmailChange starts changing the email of the user the bearer token
+// was issued to.
+func (h *handler) requestEmailChange(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	password, newEmail := r.PostForm.Get("password"), r.PostForm.Get("new_email")
+	if password == "" || newEmail == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.RequestEmailChange(r.Context(), userID, password, newEmail); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidGrant})
+		case errors.Is(err, auth.ErrUserExists):
+			writeJSON(w, http.StatusConflict, errorResponse{Error: errEmailTaken})
+		case errors.Is(err, auth.ErrUserNotFound):
+			writeInvalidToken(w)
+		default:
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *handler) confirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.ConfirmEmailChange(r.Context(), token); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidEmailChangeToken):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidEmailChangeToken})
+		case errors.Is(err, auth.ErrUserExists):
+			writeJSON(w, http.StatusConflict, errorResponse{Error: errEmailTaken})
+		default:
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+)
+
+const (
+	errInvalidVerificationToken = "invalid_verification_token"
+	errEmailNotVerified         = "email_not_verified"
+)
+
+func (h *handler) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.VerifyEmail(r.Context(), token); err != nil {
+		if errors.Is(err, auth.ErrInvalidVerificationToken) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidVerificationToken})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sso/internal/domain/models"
+	"sso/internal/services/auth"
+	"strconv"
+	"strings"
+)
+
+const (
+	errUnknownProvider     = "unknown_provider"
+	errInvalidState        = "invalid_state"
+	errFederationFailed    = "federation_failed"
+	errUnverifiedIdentity  = "unverified_identity"
+	errIdentityNotLinkable = "identity_not_linkable"
+	errInvalidRedirectURI  = "invalid_redirect_uri"
+)
+
+// maxClientStateLength caps the state clients pass along with a redirect
+// URI.
+const maxClientStateLength = 512
+
+// federatedAuthorize sends the user to the login page of the provider. With
+// a redirect_uri, the user is eventually sent back there with the tokens
+// and the state of the request.
+func (h *handler) federatedAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	appID, err := strconv.Atoi(q.Get("client_id"))
+	if err != nil || appID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	var redirect models.ClientRedirect
+	if redirect.URI = q.Get("redirect_uri"); redirect.URI != "" {
+		redirect.State = q.Get("state")
+	}
+	if len(redirect.State) > maxClientStateLength {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	authURL, err := h.auth.StartFederatedLogin(
+		r.Context(),
+		r.PathValue("provider"),
+		appID,
+		strings.Fields(q.Get("scope")),
+		redirect,
+	)
+	if err != nil {
+		writeFederationError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// federatedCallback is where the provider sends the user back to. It answers
+// like the token endpoint, unless the login was started with a redirect
+// URI: then the user is sent there with the response in the fragment, as in
+// the implicit grant (RFC 6749, section 4.2.2). Errors are answered here,
+// as the redirect URI is not known for them.
+func (h *handler) federatedCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	if q.Get("error") != "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errAccessDenied})
+		return
+	}
+
+	state, code := q.Get("state"), q.Get("code")
+	if state == "" || code == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	tokens, redirect, err := h.auth.FinishFederatedLogin(r.Context(), r.PathValue("provider"), state, code)
+	if err != nil {
+		writeFederationError(w, err)
+		return
+	}
+
+	if redirect.URI != "" {
+		redirectWithTokens(w, r, redirect, tokens)
+		return
+	}
+
+	if tokens.MFAToken != "" {
+		writeJSON(w, http.StatusForbidden, mfaRequiredResponse{Error: errMFARequired, MFAToken: tokens.MFAToken})
+		return
+	}
+
+	if tokens.PasswordChangeToken != "" {
+		writeJSON(w, http.StatusForbidden, passwordChangeRequiredResponse{
+			Error:               errPasswordChangeRequired,
+			PasswordChangeToken: tokens.PasswordChangeToken,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTokenResponse(tokens))
+}
+
+func redirectWithTokens(w http.ResponseWriter, r *http.Request, redirect models.ClientRedirect, tokens models.TokenPair) {
+	fragment := url.Values{}
+	switch {
+	case tokens.MFAToken != "":
+		fragment.Set("error", errMFARequired)
+		fragment.Set("mfa_token", tokens.MFAToken)
+	case tokens.PasswordChangeToken != "":
+		fragment.Set("error", errPasswordChangeRequired)
+		fragment.Set("password_change_token", tokens.PasswordChangeToken)
+	default:
+		resp := newTokenResponse(tokens)
+		fragment.Set("access_token", resp.AccessToken)
+		fragment.Set("token_type", resp.TokenType)
+		fragment.Set("expires_in", strconv.FormatInt(resp.ExpiresIn, 10))
+		if resp.RefreshToken != "" {
+			fragment.Set("refresh_token", resp.RefreshToken)
+		}
+		if resp.IDToken != "" {
+			fragment.Set("id_token", resp.IDToken)
+		}
+		if resp.Scope != "" {
+			fragment.Set("scope", resp.Scope)
+		}
+	}
+	if redirect.State != "" {
+		fragment.Set("state", redirect.State)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, redirect.URI+"#"+fragment.Encode(), http.StatusFound)
+}
+
+func writeFederationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUnknownIdentityProvider):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: errUnknownProvider})
+	case errors.Is(err, auth.ErrInvalidRedirectURI):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRedirectURI})
+	case errors.Is(err, auth.ErrInvalidFederationState):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidState})
+	case errors.Is(err, auth.ErrFederationFailed):
+		writeJSON(w, http.StatusBadGateway, errorResponse{Error: errFederationFailed})
+	case errors.Is(err, auth.ErrUnverifiedIdentity):
+		writeJSON(w, http.StatusForbidden, errorResponse{Error: errUnverifiedIdentity})
+	case errors.Is(err, auth.ErrIdentityNotLinkable):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: errIdentityNotLinkable})
+	case errors.Is(err, auth.ErrRegistrationClosed):
+		writeJSON(w, http.StatusForbidden, errorResponse{Error: errRegistrationClosed})
+	default:
+		writeTokenError(w, err)
+	}
+}
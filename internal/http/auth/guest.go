@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+	"strconv"
+)
+
+const errNotGuest = "not_guest"
+
+// createGuest logs a new anonymous user in to the app of client_id.
+func (h *handler) createGuest(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	appID, err := strconv.Atoi(r.PostForm.Get("client_id"))
+	if err != nil || appID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	tokens, err := h.auth.CreateGuest(r.Context(), appID, scopes(r))
+	if err != nil {
+		if errors.Is(err, auth.ErrRegistrationClosed) {
+			writeJSON(w, http.StatusForbidden, errorResponse{Error: errRegistrationClosed})
+			return
+		}
+		writeTokenError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTokenResponse(tokens))
+}
+
+// upgradeGuest turns the guest the bearer token was issued to into a full
+// user with the email and the password.
+func (h *handler) upgradeGuest(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	email, password := r.PostForm.Get("email"), r.PostForm.Get("password")
+	if email == "" || password == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.UpgradeGuest(r.Context(), userID, email, password); err != nil {
+		if writeWeakPassword(w, err) || writeRejected(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, auth.ErrNotGuest):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errNotGuest})
+		case errors.Is(err, auth.ErrUserExists):
+			writeJSON(w, http.StatusConflict, errorResponse{Error: errUserExists})
+		case errors.Is(err, auth.ErrUserNotFound):
+			writeInvalidToken(w)
+		default:
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,340 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/lib/tokens"
+	"sso/internal/services/auth"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Auth interface {
+	Login(ctx context.Context,
+		email string,
+		password string,
+		appID int,
+		scopes []string,
+	) (models.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error)
+	StepUp(ctx context.Context, refreshToken string, password string, otp string) (models.TokenPair, error)
+	Logout(ctx context.Context, accessToken string, refreshToken string) error
+	AppLogin(ctx context.Context, appID int, appSecret string, scopes []string) (models.TokenPair, error)
+	StartDeviceAuthorization(ctx context.Context, appID int) (models.DeviceCode, error)
+	DeviceToken(ctx context.Context, deviceCode string, appID int) (models.TokenPair, error)
+	VerifyDevice(ctx context.Context,
+		userCode string,
+		email string,
+		password string,
+		otp string,
+		approve bool,
+	) error
+	ExchangeToken(ctx context.Context,
+		subjectToken string,
+		appID int,
+		appSecret string,
+		audience string,
+	) (models.TokenPair, error)
+	Introspect(ctx context.Context,
+		token string,
+		appID int,
+		appSecret string,
+	) (models.TokenIntrospection, error)
+	RequestPasswordReset(ctx context.Context, email string) error
+	ConfirmPasswordReset(ctx context.Context, token string, newPassword string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ValidateToken(ctx context.Context, token string, audience string) (tokens.Claims, error)
+	ChangePassword(ctx context.Context, userID int64, oldPassword string, newPassword string) error
+	RequestEmailChange(ctx context.Context, userID int64, password string, newEmail string) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	RequestMagicLink(ctx context.Context, email string, appID int, scopes []string) error
+	RedeemMagicLink(ctx context.Context, token string) (models.TokenPair, error)
+	StartFederatedLogin(
+		ctx context.Context,
+		provider string,
+		appID int,
+		scopes []string,
+		redirect models.ClientRedirect,
+	) (string, error)
+	FinishFederatedLogin(
+		ctx context.Context,
+		provider string,
+		state string,
+		code string,
+	) (models.TokenPair, models.ClientRedirect, error)
+	EnrollTOTP(ctx context.Context, userID int64) (models.TOTPEnrollment, error)
+	VerifyTOTP(ctx context.Context, userID int64, code string) error
+	DisableTOTP(ctx context.Context, userID int64, code string) error
+	LoginMFA(ctx context.Context, mfaToken string, code string, rememberDevice bool) (models.TokenPair, error)
+	LoginRecoveryCode(ctx context.Context, mfaToken string, code string) (models.TokenPair, error)
+	RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error)
+	BeginWebAuthnRegistration(ctx context.Context, userID int64) (models.WebAuthnOptions, error)
+	FinishWebAuthnRegistration(ctx context.Context, userID int64, session string, response []byte) error
+	BeginWebAuthnLogin(ctx context.Context, appID int, scopes []string) (models.WebAuthnOptions, error)
+	FinishWebAuthnLogin(ctx context.Context, session string, response []byte) (models.TokenPair, error)
+	RequestPhoneVerification(ctx context.Context, userID int64, phone string) error
+	VerifyPhone(ctx context.Context, userID int64, code string) error
+	SetUsername(ctx context.Context, userID int64, name string) error
+	Metadata(ctx context.Context, userID int64) (models.User, error)
+	SetUserMetadata(ctx context.Context, userID int64, md map[string]string) error
+	AcceptInvitation(ctx context.Context, token string, password string) (int64, models.TokenPair, error)
+	AcceptOrgInvitation(ctx context.Context, userID int64, token string) (int64, error)
+	DeclineOrgInvitation(ctx context.Context, token string) error
+	GrantConsent(ctx context.Context, userID int64, appID int, scopes []string) (models.Consent, error)
+	RevokeConsent(ctx context.Context, userID int64, appID int) error
+	ListConsents(ctx context.Context, userID int64) ([]models.Consent, error)
+	CreateGuest(ctx context.Context, appID int, scopes []string) (models.TokenPair, error)
+	UpgradeGuest(ctx context.Context, userID int64, email string, password string) error
+	DeleteMyAccount(ctx context.Context, userID int64, password string) (time.Time, error)
+	CancelAccountDeletion(ctx context.Context, login string, password string) error
+	RequestSMSLogin(ctx context.Context, phone string) error
+	LoginSMS(ctx context.Context, phone string, code string, appID int, scopes []string) (models.TokenPair, error)
+	SendMFASMS(ctx context.Context, mfaToken string) error
+	LoginMFASMS(ctx context.Context, mfaToken string, code string, rememberDevice bool) (models.TokenPair, error)
+	ListSessions(ctx context.Context, userID int64) ([]models.UserSession, error)
+	LoginHistory(ctx context.Context, userID int64) ([]models.Login, error)
+	NotificationPreferences(ctx context.Context, userID int64) (models.NotificationPreferences, error)
+	UpdateNotificationPreferences(ctx context.Context,
+		userID int64,
+		update models.NotificationPreferencesUpdate,
+	) (models.NotificationPreferences, error)
+	RevokeSession(ctx context.Context, userID int64, sessionID int64) error
+	ListTrustedDevices(ctx context.Context, userID int64) ([]models.TrustedDevice, error)
+	RevokeTrustedDevice(ctx context.Context, userID int64, deviceID int64) error
+}
+
+type handler struct {
+	auth     Auth
+	audience string
+}
+
+// introspectResponse follows RFC 7662, section 2.2, with the
+// authentication claims of RFC 9470, section 6.2.
+type introspectResponse struct {
+	Active    bool     `json:"active"`
+	Sub       string   `json:"sub,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Aud       string   `json:"aud,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	AuthTime  int64    `json:"auth_time,omitempty"`
+	ACR       string   `json:"acr,omitempty"`
+	AMR       []string `json:"amr,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+const (
+	errInvalidRequest       = "invalid_request"
+	errInvalidClient        = "invalid_client"
+	errUnauthorizedClient   = "unauthorized_client"
+	errInvalidGrant         = "invalid_grant"
+	errInvalidToken         = "invalid_token"
+	errUnsupportedGrantType = "unsupported_grant_type"
+	errServerError          = "server_error"
+)
+
+// RegisterHandlers registers the OAuth and account endpoints. The account
+// endpoints take the access tokens issued for audience only.
+func RegisterHandlers(mux *http.ServeMux, auth Auth, audience string) {
+	h := &handler{auth: auth, audience: audience}
+
+	mux.HandleFunc("POST /token", h.token)
+	mux.HandleFunc("POST /introspect", h.introspect)
+	mux.HandleFunc("POST /logout", h.logout)
+	mux.HandleFunc("POST /step-up", h.stepUp)
+	mux.HandleFunc("POST /device/authorize", h.deviceAuthorize)
+	mux.HandleFunc("POST /device", h.deviceVerify)
+	mux.HandleFunc("POST /password/reset", h.requestPasswordReset)
+	mux.HandleFunc("POST /password/reset/confirm", h.confirmPasswordReset)
+	mux.HandleFunc("POST /password/change", h.changePassword)
+	mux.HandleFunc("POST /email/verify", h.verifyEmail)
+	mux.HandleFunc("POST /email/change", h.requestEmailChange)
+	mux.HandleFunc("POST /email/change/confirm", h.confirmEmailChange)
+	mux.HandleFunc("POST /invitations/accept", h.acceptInvitation)
+	mux.HandleFunc("POST /org-invitations/accept", h.acceptOrgInvitation)
+	mux.HandleFunc("POST /org-invitations/decline", h.declineOrgInvitation)
+	mux.HandleFunc("POST /guest", h.createGuest)
+	mux.HandleFunc("POST /guest/upgrade", h.upgradeGuest)
+	mux.HandleFunc("POST /magic-link", h.requestMagicLink)
+	mux.HandleFunc("POST /magic-link/redeem", h.redeemMagicLink)
+	mux.HandleFunc("GET /oauth/{provider}/authorize", h.federatedAuthorize)
+	mux.HandleFunc("GET /oauth/{provider}/callback", h.federatedCallback)
+	mux.HandleFunc("POST /mfa/totp/enroll", h.enrollTOTP)
+	mux.HandleFunc("POST /mfa/totp/verify", h.verifyTOTP)
+	mux.HandleFunc("POST /mfa/totp/disable", h.disableTOTP)
+	mux.HandleFunc("POST /mfa/recovery-codes", h.regenerateRecoveryCodes)
+	mux.HandleFunc("POST /webauthn/register/begin", h.beginWebAuthnRegistration)
+	mux.HandleFunc("POST /webauthn/register/finish", h.finishWebAuthnRegistration)
+	mux.HandleFunc("POST /webauthn/login/begin", h.beginWebAuthnLogin)
+	mux.HandleFunc("POST /webauthn/login/finish", h.finishWebAuthnLogin)
+	mux.HandleFunc("POST /username", h.setUsername)
+	mux.HandleFunc("GET /metadata", h.metadata)
+	mux.HandleFunc("PUT /metadata", h.setUserMetadata)
+	mux.HandleFunc("POST /phone", h.requestPhoneVerification)
+	mux.HandleFunc("POST /phone/verify", h.verifyPhone)
+	mux.HandleFunc("POST /sms/login", h.requestSMSLogin)
+	mux.HandleFunc("POST /mfa/sms", h.sendMFASMS)
+	mux.HandleFunc("GET /sessions", h.listSessions)
+	mux.HandleFunc("DELETE /sessions/{session_id}", h.revokeSession)
+	mux.HandleFunc("GET /login-history", h.loginHistory)
+	mux.HandleFunc("GET /notification-preferences", h.notificationPreferences)
+	mux.HandleFunc("PATCH /notification-preferences", h.updateNotificationPreferences)
+	mux.HandleFunc("POST /account/delete", h.deleteAccount)
+	mux.HandleFunc("POST /account/delete/cancel", h.cancelAccountDeletion)
+	mux.HandleFunc("GET /consents", h.listConsents)
+	mux.HandleFunc("POST /consents", h.grantConsent)
+	mux.HandleFunc("DELETE /consents/{app_id}", h.revokeConsent)
+	mux.HandleFunc("GET /trusted-devices", h.listTrustedDevices)
+	mux.HandleFunc("DELETE /trusted-devices/{device_id}", h.revokeTrustedDevice)
+}
+
+func (h *handler) introspect(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	appID, appSecret, ok := clientCredentials(r)
+	if !ok {
+		writeInvalidClient(w)
+		return
+	}
+
+	info, err := h.auth.Introspect(r.Context(), token, appID, appSecret)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidClient) {
+			writeInvalidClient(w)
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	if !info.Active {
+		writeJSON(w, http.StatusOK, introspectResponse{Active: false})
+		return
+	}
+
+	resp := introspectResponse{
+		Active:    true,
+		ClientID:  strconv.Itoa(info.AppID),
+		Iss:       info.Issuer,
+		Aud:       info.Audience,
+		Exp:       info.ExpiresAt.Unix(),
+		Scope:     strings.Join(info.Scopes, " "),
+		TokenType: "Bearer",
+		ACR:       info.ACR,
+		AMR:       info.AuthMethods,
+	}
+	if info.UserID != 0 {
+		resp.Sub = strconv.FormatInt(info.UserID, 10)
+	}
+	if !info.AuthTime.IsZero() {
+		resp.AuthTime = info.AuthTime.Unix()
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) logout(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	accessToken, ok := bearerToken(r)
+	if !ok {
+		writeInvalidToken(w)
+		return
+	}
+
+	if err := h.auth.Logout(r.Context(), accessToken, r.PostForm.Get("refresh_token")); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			writeInvalidToken(w)
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticatedUser returns the id of the user the bearer token of the
+// request was issued to, which must be a token of the audience of the
+// account endpoints. It writes the error response if there is none.
+func (h *handler) authenticatedUser(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	accessToken, ok := bearerToken(r)
+	if !ok {
+		writeInvalidToken(w)
+		return 0, false
+	}
+
+	claims, err := h.auth.ValidateToken(r.Context(), accessToken, h.audience)
+	if err != nil || claims.UserID == 0 {
+		writeInvalidToken(w)
+		return 0, false
+	}
+
+	return claims.UserID, true
+}
+
+// clientCredentials returns the app id and secret the client authenticated
+// with, either with HTTP Basic or in the form body (RFC 6749, section 2.3.1).
+func clientCredentials(r *http.Request) (int, string, bool) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	appID, err := strconv.Atoi(clientID)
+	if err != nil || appID <= 0 || clientSecret == "" {
+		return 0, "", false
+	}
+
+	return appID, clientSecret, true
+}
+
+// bearerToken extracts the token from the Authorization header (RFC 6750).
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	return header[len(prefix):], true
+}
+
+func writeInvalidToken(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	writeJSON(w, http.StatusUnauthorized, errorResponse{Error: errInvalidToken})
+}
+
+func writeInvalidClient(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="sso"`)
+	writeJSON(w, http.StatusUnauthorized, errorResponse{Error: errInvalidClient})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+)
+
+const errRejected = "rejected"
+
+// rejectedResponse tells why a hook rejected the registration or the login.
+type rejectedResponse struct {
+	Error  string `json:"error"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// writeRejected writes the reason if err is a *auth.RejectedError and
+// reports whether it was one.
+func writeRejected(w http.ResponseWriter, err error) bool {
+	var rejected *auth.RejectedError
+	if !errors.As(err, &rejected) {
+		return false
+	}
+
+	writeJSON(w, http.StatusForbidden, rejectedResponse{Error: errRejected, Reason: rejected.Reason})
+
+	return true
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+)
+
+const (
+	errInvalidInvitation  = "invalid_invitation"
+	errRegistrationClosed = "registration_closed"
+	errUserExists         = "user_exists"
+)
+
+type invitationAcceptedResponse struct {
+	UserID int64 `json:"user_id"`
+	// tokens are set if the invitation logs the user in to an app
+	*tokenResponse
+}
+
+// acceptInvitation registers the invited user with the password.
+func (h *handler) acceptInvitation(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	token, password := r.PostForm.Get("token"), r.PostForm.Get("password")
+	if token == "" || password == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	userID, tokens, err := h.auth.AcceptInvitation(r.Context(), token, password)
+	if err != nil {
+		if writeWeakPassword(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, auth.ErrInvalidInvitation):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidInvitation})
+		case errors.Is(err, auth.ErrUserExists):
+			writeJSON(w, http.StatusConflict, errorResponse{Error: errUserExists})
+		default:
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		}
+		return
+	}
+
+	resp := invitationAcceptedResponse{UserID: userID}
+	if tokens.AccessToken != "" {
+		tokenResp := newTokenResponse(tokens)
+		resp.tokenResponse = &tokenResp
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+)
+
+type loginResponse struct {
+	AppID     int       `json:"app_id"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Device    string    `json:"device"`
+	AMR       []string  `json:"amr"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type loginHistoryResponse struct {
+	Logins []loginResponse `json:"logins"`
+}
+
+// loginHistory returns the latest logins of the user of the bearer token,
+// the most recent first.
+func (h *handler) loginHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	logins, err := h.auth.LoginHistory(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	resp := loginHistoryResponse{Logins: make([]loginResponse, 0, len(logins))}
+	for _, login := range logins {
+		resp.Logins = append(resp.Logins, loginResponse{
+			AppID:     login.AppID,
+			IP:        login.IP,
+			UserAgent: login.UserAgent,
+			Device:    login.Device,
+			AMR:       login.Methods,
+			CreatedAt: login.CreatedAt,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+	"strconv"
+)
+
+const errInvalidMagicLink = "invalid_magic_link"
+
+func (h *handler) requestMagicLink(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	email := r.PostForm.Get("email")
+	appID, err := strconv.Atoi(r.PostForm.Get("client_id"))
+	if email == "" || err != nil || appID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.RequestMagicLink(r.Context(), email, appID, scopes(r)); err != nil {
+		writeTokenError(w, err)
+		return
+	}
+
+	// the same response whether the user exists or not
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// redeemMagicLink answers like the token endpoint.
+func (h *handler) redeemMagicLink(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	tokens, err := h.auth.RedeemMagicLink(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidMagicLink) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidMagicLink})
+			return
+		}
+
+		writeTokenError(w, err)
+		return
+	}
+
+	if tokens.MFAToken != "" {
+		writeJSON(w, http.StatusForbidden, mfaRequiredResponse{Error: errMFARequired, MFAToken: tokens.MFAToken})
+		return
+	}
+
+	if tokens.PasswordChangeToken != "" {
+		writeJSON(w, http.StatusForbidden, passwordChangeRequiredResponse{
+			Error:               errPasswordChangeRequired,
+			PasswordChangeToken: tokens.PasswordChangeToken,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTokenResponse(tokens))
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+)
+
+const errInvalidMetadata = "invalid_metadata"
+
+type metadataResponse struct {
+	AppMetadata  map[string]string `json:"app_metadata"`
+	UserMetadata map[string]string `json:"user_metadata"`
+}
+
+type setUserMetadataRequest struct {
+	UserMetadata map[string]string `json:"user_metadata"`
+}
+
+// metadata returns the app and user metadata of the user of the bearer
+// token.
+func (h *handler) metadata(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.auth.Metadata(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			writeInvalidToken(w)
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	resp := metadataResponse{AppMetadata: user.AppMetadata, UserMetadata: user.UserMetadata}
+	if resp.AppMetadata == nil {
+		resp.AppMetadata = map[string]string{}
+	}
+	if resp.UserMetadata == nil {
+		resp.UserMetadata = map[string]string{}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// setUserMetadata replaces the user metadata of the user of the bearer
+// token with the JSON object in the body. App metadata is only writable
+// through the admin API.
+func (h *handler) setUserMetadata(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req setUserMetadataRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.SetUserMetadata(r.Context(), userID, req.UserMetadata); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidMetadata):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidMetadata})
+		case errors.Is(err, auth.ErrUserNotFound):
+			writeInvalidToken(w)
+		default:
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/services/auth"
+)
+
+const (
+	errMFARequired       = "mfa_required"
+	errMFAAlreadyEnabled = "mfa_already_enabled"
+	errMFANotEnabled     = "mfa_not_enabled"
+	errInvalidMFACode    = "invalid_mfa_code"
+)
+
+type totpEnrollmentResponse struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// mfaRequiredResponse answers a password grant of a user with MFA enabled.
+// The client completes the login with the mfa-otp grant.
+type mfaRequiredResponse struct {
+	Error    string `json:"error"`
+	MFAToken string `json:"mfa_token"`
+}
+
+func (h *handler) enrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.auth.EnrollTOTP(r.Context(), userID)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, totpEnrollmentResponse{
+		Secret:        enrollment.Secret,
+		URI:           enrollment.URI,
+		RecoveryCodes: enrollment.RecoveryCodes,
+	})
+}
+
+func (h *handler) verifyTOTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	if code == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.VerifyTOTP(r.Context(), userID, code); err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) disableTOTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	if code == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.DisableTOTP(r.Context(), userID, code); err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// regenerateRecoveryCodes replaces the recovery codes of the user, who
+// confirms with a TOTP code.
+func (h *handler) regenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	if code == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	codes, err := h.auth.RegenerateRecoveryCodes(r.Context(), userID, code)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *handler) mfaOTPGrant(r *http.Request) (models.TokenPair, error) {
+	mfaToken, otp := r.PostForm.Get("mfa_token"), r.PostForm.Get("otp")
+	if mfaToken == "" || otp == "" {
+		return models.TokenPair{}, errMalformedRequest
+	}
+
+	return h.auth.LoginMFA(r.Context(), mfaToken, otp, rememberDevice(r))
+}
+
+func (h *handler) mfaRecoveryCodeGrant(r *http.Request) (models.TokenPair, error) {
+	mfaToken, code := r.PostForm.Get("mfa_token"), r.PostForm.Get("recovery_code")
+	if mfaToken == "" || code == "" {
+		return models.TokenPair{}, errMalformedRequest
+	}
+
+	return h.auth.LoginRecoveryCode(r.Context(), mfaToken, code)
+}
+
+func writeMFAError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrMFAAlreadyEnabled):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: errMFAAlreadyEnabled})
+	case errors.Is(err, auth.ErrMFANotEnabled):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errMFANotEnabled})
+	case errors.Is(err, auth.ErrInvalidMFACode):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidMFACode})
+	case errors.Is(err, auth.ErrUserNotFound):
+		writeInvalidToken(w)
+	default:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+	}
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/services/auth"
+	"time"
+)
+
+type notificationPreferencesResponse struct {
+	SecurityEmails bool       `json:"security_emails"`
+	LoginAlerts    bool       `json:"login_alerts"`
+	Marketing      bool       `json:"marketing"`
+	UpdatedAt      *time.Time `json:"updated_at,omitempty"`
+}
+
+// updateNotificationPreferencesRequest changes the preferences it names and
+// keeps the others.
+type updateNotificationPreferencesRequest struct {
+	SecurityEmails *bool `json:"security_emails"`
+	LoginAlerts    *bool `json:"login_alerts"`
+	Marketing      *bool `json:"marketing"`
+}
+
+// notificationPreferences returns the notification preferences of the user
+// of the bearer token.
+func (h *handler) notificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	prefs, err := h.auth.NotificationPreferences(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newNotificationPreferencesResponse(prefs))
+}
+
+func (h *handler) updateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req updateNotificationPreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	prefs, err := h.auth.UpdateNotificationPreferences(r.Context(), userID, models.NotificationPreferencesUpdate{
+		SecurityEmails: req.SecurityEmails,
+		LoginAlerts:    req.LoginAlerts,
+		Marketing:      req.Marketing,
+	})
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			writeInvalidToken(w)
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newNotificationPreferencesResponse(prefs))
+}
+
+func newNotificationPreferencesResponse(prefs models.NotificationPreferences) notificationPreferencesResponse {
+	resp := notificationPreferencesResponse{
+		SecurityEmails: prefs.SecurityEmails,
+		LoginAlerts:    prefs.LoginAlerts,
+		Marketing:      prefs.Marketing,
+	}
+	if !prefs.UpdatedAt.IsZero() {
+		resp.UpdatedAt = &prefs.UpdatedAt
+	}
+
+	return resp
+}
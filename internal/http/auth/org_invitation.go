@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+)
+
+type orgInvitationAcceptedResponse struct {
+	OrgID int64 `json:"org_id"`
+}
+
+// acceptOrgInvitation moves the user of the bearer token to the org of the
+// invitation. The user has to log in again to get tokens of the org.
+func (h *handler) acceptOrgInvitation(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	orgID, err := h.auth.AcceptOrgInvitation(r.Context(), userID, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidInvitation):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidInvitation})
+		case errors.Is(err, auth.ErrUserNotFound):
+			writeInvalidToken(w)
+		default:
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, orgInvitationAcceptedResponse{OrgID: orgID})
+}
+
+// declineOrgInvitation declines the invitation. Anyone holding the token can
+// decline it, so the invited email need not have an account.
+func (h *handler) declineOrgInvitation(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.DeclineOrgInvitation(r.Context(), token); err != nil {
+		if errors.Is(err, auth.ErrInvalidInvitation) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidInvitation})
+			return
+		}
+
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
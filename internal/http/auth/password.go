@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+)
+
+const (
+	errInvalidPassword        = "invalid_password"
+	errInvalidResetToken      = "invalid_reset_token"
+	errPasswordChangeRequired = "password_change_required"
+)
+
+// passwordChangeRequiredResponse answers logins of users an admin required
+// to set a new password. The client sets it with the password reset token
+// at /password/reset/confirm and logs in again.
+type passwordChangeRequiredResponse struct {
+	Error               string `json:"error"`
+	PasswordChangeToken string `json:"password_change_token"`
+}
+
+type passwordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// weakPasswordResponse lists the rules of the password policy the new
+// password violates.
+type weakPasswordResponse struct {
+	Error      string              `json:"error"`
+	Violations []passwordViolation `json:"violations"`
+}
+
+func (h *handler) requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	email := r.PostForm.Get("email")
+	if email == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.RequestPasswordReset(r.Context(), email); err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	// the same response whether the user exists or not
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *handler) confirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	token, password := r.PostForm.Get("token"), r.PostForm.Get("password")
+	if token == "" || password == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.ConfirmPasswordReset(r.Context(), token, password); err != nil {
+		if writeWeakPassword(w, err) {
+			return
+		}
+		if errors.Is(err, auth.ErrInvalidResetToken) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidResetToken})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// changePassword sets a new password for the user the bearer token was
+// issued to.
+func (h *handler) changePassword(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	oldPassword, newPassword := r.PostForm.Get("old_password"), r.PostForm.Get("new_password")
+	if oldPassword == "" || newPassword == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.ChangePassword(r.Context(), userID, oldPassword, newPassword); err != nil {
+		if writeWeakPassword(w, err) {
+			return
+		}
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidGrant})
+			return
+		}
+		if errors.Is(err, auth.ErrUserNotFound) {
+			writeInvalidToken(w)
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeWeakPassword writes the violations if err is a
+// *auth.WeakPasswordError and reports whether it was one.
+func writeWeakPassword(w http.ResponseWriter, err error) bool {
+	var weak *auth.WeakPasswordError
+	if !errors.As(err, &weak) {
+		return false
+	}
+
+	resp := weakPasswordResponse{Error: errInvalidPassword}
+	for _, violation := range weak.Violations {
+		resp.Violations = append(resp.Violations, passwordViolation{Code: violation.Code, Message: violation.Message})
+	}
+
+	writeJSON(w, http.StatusBadRequest, resp)
+
+	return true
+}
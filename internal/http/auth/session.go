@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+	"strconv"
+	"time"
+)
+
+const errSessionNotFound = "session_not_found"
+
+type sessionResponse struct {
+	ID         int64     `json:"id"`
+	AppID      int       `json:"app_id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Device     string    `json:"device"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+type sessionsResponse struct {
+	Sessions []sessionResponse `json:"sessions"`
+}
+
+// listSessions returns where the user of the bearer token is logged in.
+func (h *handler) listSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.auth.ListSessions(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	resp := sessionsResponse{Sessions: make([]sessionResponse, 0, len(sessions))}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, sessionResponse{
+			ID:         session.ID,
+			AppID:      session.AppID,
+			IP:         session.IP,
+			UserAgent:  session.UserAgent,
+			Device:     session.Device,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// revokeSession logs the user of the bearer token out of one of their
+// sessions.
+func (h *handler) revokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(r.PathValue("session_id"), 10, 64)
+	if err != nil || sessionID <= 0 {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: errSessionNotFound})
+		return
+	}
+
+	if err = h.auth.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: errSessionNotFound})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
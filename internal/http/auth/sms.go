@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/services/auth"
+	"strconv"
+)
+
+const (
+	errInvalidPhone     = "invalid_phone"
+	errPhoneTaken       = "phone_taken"
+	errPhoneNotVerified = "phone_not_verified"
+	errInvalidSMSCode   = "invalid_sms_code"
+)
+
+// requestPhoneVerification texts a code to the phone number the user the
+// bearer token was issued to wants to add.
+func (h *handler) requestPhoneVerification(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	phone := r.PostForm.Get("phone")
+	if phone == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.RequestPhoneVerification(r.Context(), userID, phone); err != nil {
+		writeSMSError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *handler) verifyPhone(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	if code == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.VerifyPhone(r.Context(), userID, code); err != nil {
+		writeSMSError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestSMSLogin always answers 202 for well-formed requests, so that it
+// does not disclose which phone numbers are registered.
+func (h *handler) requestSMSLogin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	phone := r.PostForm.Get("phone")
+	if phone == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.RequestSMSLogin(r.Context(), phone); err != nil {
+		writeSMSError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendMFASMS texts the code completing an MFA challenge, which the client
+// then exchanges with the mfa-sms grant.
+func (h *handler) sendMFASMS(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	mfaToken := r.PostForm.Get("mfa_token")
+	if mfaToken == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.SendMFASMS(r.Context(), mfaToken); err != nil {
+		writeSMSError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *handler) smsOTPGrant(r *http.Request) (models.TokenPair, error) {
+	phone, otp := r.PostForm.Get("phone"), r.PostForm.Get("otp")
+	appID, err := strconv.Atoi(r.PostForm.Get("client_id"))
+	if phone == "" || otp == "" || err != nil || appID <= 0 {
+		return models.TokenPair{}, errMalformedRequest
+	}
+
+	return h.auth.LoginSMS(r.Context(), phone, otp, appID, scopes(r))
+}
+
+func (h *handler) mfaSMSGrant(r *http.Request) (models.TokenPair, error) {
+	mfaToken, otp := r.PostForm.Get("mfa_token"), r.PostForm.Get("otp")
+	if mfaToken == "" || otp == "" {
+		return models.TokenPair{}, errMalformedRequest
+	}
+
+	return h.auth.LoginMFASMS(r.Context(), mfaToken, otp, rememberDevice(r))
+}
+
+func writeSMSError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidPhone):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidPhone})
+	case errors.Is(err, auth.ErrPhoneTaken):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: errPhoneTaken})
+	case errors.Is(err, auth.ErrPhoneNotVerified):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errPhoneNotVerified})
+	case errors.Is(err, auth.ErrInvalidSMSCode):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidSMSCode})
+	case errors.Is(err, auth.ErrSMSRateLimited):
+		writeJSON(w, http.StatusTooManyRequests, errorResponse{Error: errSlowDown})
+	case errors.Is(err, auth.ErrInvalidMFAToken):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidGrant})
+	case errors.Is(err, auth.ErrUserNotFound):
+		writeInvalidToken(w)
+	default:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+	}
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+)
+
+// stepUp upgrades the session of a refresh token once the user enters the
+// password or a one-time code again. It answers like the token endpoint.
+func (h *handler) stepUp(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	refreshToken := r.PostForm.Get("refresh_token")
+	password, otp := r.PostForm.Get("password"), r.PostForm.Get("otp")
+	if refreshToken == "" || (password == "") == (otp == "") {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	tokens, err := h.auth.StepUp(r.Context(), refreshToken, password, otp)
+	if err != nil {
+		if errors.Is(err, auth.ErrMFANotEnabled) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errMFANotEnabled})
+			return
+		}
+		writeTokenError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTokenResponse(tokens))
+}
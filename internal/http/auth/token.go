@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/services/auth"
+	"strconv"
+	"strings"
+)
+
+// tokenResponse follows RFC 6749, section 5.1.
+type tokenResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	IDToken         string `json:"id_token,omitempty"`
+	Scope           string `json:"scope,omitempty"`
+	// DeviceToken is sent in the X-Device-Token header of later logins on
+	// the device to skip the second factor.
+	DeviceToken string `json:"device_token,omitempty"`
+}
+
+const (
+	grantTypePassword          = "password"
+	grantTypeRefreshToken      = "refresh_token"
+	grantTypeClientCredentials = "client_credentials"
+	grantTypeTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+	grantTypeMFAOTP            = "urn:sso:params:oauth:grant-type:mfa-otp"
+	grantTypeMFARecoveryCode   = "urn:sso:params:oauth:grant-type:mfa-recovery-code"
+	grantTypeSMSOTP            = "urn:sso:params:oauth:grant-type:sms-otp"
+	grantTypeMFASMS            = "urn:sso:params:oauth:grant-type:mfa-sms"
+)
+
+const (
+	tokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	tokenTypeJWT         = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+const (
+	errInvalidTarget = "invalid_target"
+	errInvalidScope  = "invalid_scope"
+	errAccountLocked = "account_locked"
+	// errAccountPendingDeletion is returned until the user cancels the
+	// deletion of the account.
+	errAccountPendingDeletion = "account_pending_deletion"
+	errAccountSuspended       = "account_suspended"
+	errAccountExpired         = "account_expired"
+	errAppDisabled            = "app_disabled"
+)
+
+var (
+	errMalformedRequest = errors.New("malformed token request")
+	errNoClient         = errors.New("client authentication required")
+)
+
+type grantFunc func(r *http.Request) (models.TokenPair, error)
+
+func (h *handler) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	grantType := r.PostForm.Get("grant_type")
+
+	var grant grantFunc
+	switch grantType {
+	case grantTypePassword:
+		grant = h.passwordGrant
+	case grantTypeRefreshToken:
+		grant = h.refreshTokenGrant
+	case grantTypeClientCredentials:
+		grant = h.clientCredentialsGrant
+	case grantTypeTokenExchange:
+		grant = h.tokenExchangeGrant
+	case grantTypeDeviceCode:
+		grant = h.deviceCodeGrant
+	case grantTypeMFAOTP:
+		grant = h.mfaOTPGrant
+	case grantTypeMFARecoveryCode:
+		grant = h.mfaRecoveryCodeGrant
+	case grantTypeSMSOTP:
+		grant = h.smsOTPGrant
+	case grantTypeMFASMS:
+		grant = h.mfaSMSGrant
+	default:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errUnsupportedGrantType})
+		return
+	}
+
+	tokens, err := grant(r)
+	if err != nil {
+		writeTokenError(w, err)
+		return
+	}
+
+	if tokens.MFAToken != "" {
+		writeJSON(w, http.StatusForbidden, mfaRequiredResponse{Error: errMFARequired, MFAToken: tokens.MFAToken})
+		return
+	}
+
+	if tokens.PasswordChangeToken != "" {
+		writeJSON(w, http.StatusForbidden, passwordChangeRequiredResponse{
+			Error:               errPasswordChangeRequired,
+			PasswordChangeToken: tokens.PasswordChangeToken,
+		})
+		return
+	}
+
+	resp := newTokenResponse(tokens)
+	if grantType == grantTypeTokenExchange {
+		resp.IssuedTokenType = tokenTypeAccessToken
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func newTokenResponse(tokens models.TokenPair) tokenResponse {
+	return tokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(tokens.ExpiresIn.Seconds()),
+		RefreshToken: tokens.RefreshToken,
+		IDToken:      tokens.IDToken,
+		Scope:        strings.Join(tokens.Scopes, " "),
+		DeviceToken:  tokens.DeviceToken,
+	}
+}
+
+func (h *handler) passwordGrant(r *http.Request) (models.TokenPair, error) {
+	username, password := r.PostForm.Get("username"), r.PostForm.Get("password")
+	appID, err := strconv.Atoi(r.PostForm.Get("client_id"))
+	if username == "" || password == "" || err != nil || appID <= 0 {
+		return models.TokenPair{}, errMalformedRequest
+	}
+
+	return h.auth.Login(r.Context(), username, password, appID, scopes(r))
+}
+
+func (h *handler) refreshTokenGrant(r *http.Request) (models.TokenPair, error) {
+	refreshToken := r.PostForm.Get("refresh_token")
+	if refreshToken == "" {
+		return models.TokenPair{}, errMalformedRequest
+	}
+
+	return h.auth.Refresh(r.Context(), refreshToken)
+}
+
+func (h *handler) clientCredentialsGrant(r *http.Request) (models.TokenPair, error) {
+	appID, appSecret, ok := clientCredentials(r)
+	if !ok {
+		return models.TokenPair{}, errNoClient
+	}
+
+	return h.auth.AppLogin(r.Context(), appID, appSecret, scopes(r))
+}
+
+// tokenExchangeGrant implements RFC 8693 for access tokens issued by this service.
+func (h *handler) tokenExchangeGrant(r *http.Request) (models.TokenPair, error) {
+	appID, appSecret, ok := clientCredentials(r)
+	if !ok {
+		return models.TokenPair{}, errNoClient
+	}
+
+	subjectToken, audience := r.PostForm.Get("subject_token"), r.PostForm.Get("audience")
+	switch r.PostForm.Get("subject_token_type") {
+	case tokenTypeAccessToken, tokenTypeJWT:
+	default:
+		return models.TokenPair{}, errMalformedRequest
+	}
+	if subjectToken == "" || audience == "" {
+		return models.TokenPair{}, errMalformedRequest
+	}
+
+	return h.auth.ExchangeToken(r.Context(), subjectToken, appID, appSecret, audience)
+}
+
+// scopes returns the space-delimited scope parameter of the request
+// (RFC 6749, section 3.3).
+func scopes(r *http.Request) []string {
+	return strings.Fields(r.PostForm.Get("scope"))
+}
+
+func writeTokenError(w http.ResponseWriter, err error) {
+	if writeRejected(w, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, errMalformedRequest):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+	case errors.Is(err, errNoClient),
+		errors.Is(err, auth.ErrInvalidClient),
+		errors.Is(err, auth.ErrInvalidAppID):
+		writeInvalidClient(w)
+	case errors.Is(err, auth.ErrUnauthorizedClient):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errUnauthorizedClient})
+	case errors.Is(err, auth.ErrAppDisabled):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errAppDisabled})
+	case errors.Is(err, auth.ErrInvalidTarget):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidTarget})
+	case errors.Is(err, auth.ErrInvalidScope):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidScope})
+	case errors.Is(err, auth.ErrAuthorizationPending):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errAuthorizationPending})
+	case errors.Is(err, auth.ErrSlowDown):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errSlowDown})
+	case errors.Is(err, auth.ErrAccessDenied), errors.Is(err, auth.ErrOrgMismatch):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errAccessDenied})
+	case errors.Is(err, auth.ErrDeviceCodeExpired):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errExpiredToken})
+	case errors.Is(err, auth.ErrEmailNotVerified):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errEmailNotVerified})
+	case errors.Is(err, auth.ErrAccountLocked):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errAccountLocked})
+	case errors.Is(err, auth.ErrAccountPendingDeletion):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errAccountPendingDeletion})
+	case errors.Is(err, auth.ErrAccountSuspended):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errAccountSuspended})
+	case errors.Is(err, auth.ErrAccountExpired):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errAccountExpired})
+	case errors.Is(err, auth.ErrConsentRequired):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errConsentRequired})
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		writeJSON(w, http.StatusTooManyRequests, errorResponse{Error: errSlowDown})
+	case errors.Is(err, auth.ErrInvalidCredentials), errors.Is(err, auth.ErrInvalidToken),
+		errors.Is(err, auth.ErrInvalidMFAToken), errors.Is(err, auth.ErrInvalidMFACode),
+		errors.Is(err, auth.ErrInvalidWebAuthnSession), errors.Is(err, auth.ErrInvalidWebAuthnResponse):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidGrant})
+	default:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+	}
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+	"strconv"
+	"time"
+)
+
+const errTrustedDeviceNotFound = "trusted_device_not_found"
+
+type trustedDeviceResponse struct {
+	ID         int64     `json:"id"`
+	IP         string    `json:"ip"`
+	Device     string    `json:"device"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type trustedDevicesResponse struct {
+	Devices []trustedDeviceResponse `json:"devices"`
+}
+
+// listTrustedDevices returns the devices the user of the bearer token skips
+// the second factor on.
+func (h *handler) listTrustedDevices(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	devices, err := h.auth.ListTrustedDevices(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	resp := trustedDevicesResponse{Devices: make([]trustedDeviceResponse, 0, len(devices))}
+	for _, device := range devices {
+		resp.Devices = append(resp.Devices, trustedDeviceResponse{
+			ID:         device.ID,
+			IP:         device.IP,
+			Device:     device.Device,
+			CreatedAt:  device.CreatedAt,
+			LastUsedAt: device.LastUsedAt,
+			ExpiresAt:  device.ExpiresAt,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// revokeTrustedDevice makes one of the devices of the user of the bearer
+// token ask for the second factor again.
+func (h *handler) revokeTrustedDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	deviceID, err := strconv.ParseInt(r.PathValue("device_id"), 10, 64)
+	if err != nil || deviceID <= 0 {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: errTrustedDeviceNotFound})
+		return
+	}
+
+	if err = h.auth.RevokeTrustedDevice(r.Context(), userID, deviceID); err != nil {
+		if errors.Is(err, auth.ErrTrustedDeviceNotFound) {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: errTrustedDeviceNotFound})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// rememberDevice reports whether the user asked to trust the device an MFA
+// grant is made on.
+func rememberDevice(r *http.Request) bool {
+	remember, _ := strconv.ParseBool(r.PostForm.Get("remember_device"))
+
+	return remember
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+)
+
+const (
+	errInvalidUsername = "invalid_username"
+	errUsernameTaken   = "username_taken"
+)
+
+// setUsername sets the username the user of the bearer token can log in
+// with instead of the email.
+func (h *handler) setUsername(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	name := r.PostForm.Get("username")
+	if name == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.auth.SetUsername(r.Context(), userID, name); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidUsername):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidUsername})
+		case errors.Is(err, auth.ErrUsernameTaken):
+			writeJSON(w, http.StatusConflict, errorResponse{Error: errUsernameTaken})
+		case errors.Is(err, auth.ErrUserNotFound):
+			writeInvalidToken(w)
+		default:
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+	"strings"
+)
+
+const (
+	errInvalidWebAuthnSession   = "invalid_webauthn_session"
+	errInvalidWebAuthnResponse  = "invalid_webauthn_response"
+	errWebAuthnCredentialExists = "webauthn_credential_exists"
+)
+
+// webAuthnOptionsResponse carries the options for navigator.credentials.create
+// or navigator.credentials.get. The session is sent back with the result.
+type webAuthnOptionsResponse struct {
+	Session string          `json:"session"`
+	Options json.RawMessage `json:"options"`
+}
+
+// webAuthnFinishRequest carries the credential the browser returned, as
+// PublicKeyCredential.toJSON() encodes it.
+type webAuthnFinishRequest struct {
+	Session    string          `json:"session"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+type webAuthnLoginRequest struct {
+	ClientID int    `json:"client_id"`
+	Scope    string `json:"scope"`
+}
+
+func (h *handler) beginWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	options, err := h.auth.BeginWebAuthnRegistration(r.Context(), userID)
+	if err != nil {
+		writeWebAuthnError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, webAuthnOptionsResponse{Session: options.Session, Options: options.Options})
+}
+
+func (h *handler) finishWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := decodeWebAuthnFinish(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.auth.FinishWebAuthnRegistration(r.Context(), userID, req.Session, req.Credential); err != nil {
+		writeWebAuthnError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) beginWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	var req webAuthnLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ClientID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	options, err := h.auth.BeginWebAuthnLogin(r.Context(), req.ClientID, strings.Fields(req.Scope))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidAppID):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidClient})
+		case errors.Is(err, auth.ErrInvalidScope):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidScope})
+		default:
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, webAuthnOptionsResponse{Session: options.Session, Options: options.Options})
+}
+
+// finishWebAuthnLogin answers like the token endpoint.
+func (h *handler) finishWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeWebAuthnFinish(w, r)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.auth.FinishWebAuthnLogin(r.Context(), req.Session, req.Credential)
+	if err != nil {
+		writeTokenError(w, err)
+		return
+	}
+
+	if tokens.PasswordChangeToken != "" {
+		writeJSON(w, http.StatusForbidden, passwordChangeRequiredResponse{
+			Error:               errPasswordChangeRequired,
+			PasswordChangeToken: tokens.PasswordChangeToken,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTokenResponse(tokens))
+}
+
+func decodeWebAuthnFinish(w http.ResponseWriter, r *http.Request) (webAuthnFinishRequest, bool) {
+	var req webAuthnFinishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Session == "" || len(req.Credential) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return webAuthnFinishRequest{}, false
+	}
+
+	return req, true
+}
+
+func writeWebAuthnError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidWebAuthnSession):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidWebAuthnSession})
+	case errors.Is(err, auth.ErrInvalidWebAuthnResponse):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidWebAuthnResponse})
+	case errors.Is(err, auth.ErrWebAuthnCredentialExists):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: errWebAuthnCredentialExists})
+	case errors.Is(err, auth.ErrUserNotFound):
+		writeInvalidToken(w)
+	default:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+	}
+}
@@ -0,0 +1,140 @@
+package management
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/services/management"
+	"strconv"
+	"time"
+)
+
+type apiKey struct {
+	ID         int64      `json:"id"`
+	AppID      int        `json:"app_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"`
+	CreatedBy  int64      `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type apiKeysResponse struct {
+	Keys []apiKey `json:"keys"`
+}
+
+// apiKeyValidation is the answer to a resource server validating a key.
+// Only valid keys are described.
+type apiKeyValidation struct {
+	Valid bool   `json:"valid"`
+	KeyID int64  `json:"key_id,omitempty"`
+	AppID int    `json:"app_id,omitempty"`
+	Name  string `json:"name,omitempty"`
+}
+
+func (h *handler) apiKeys(w http.ResponseWriter, r *http.Request) {
+	id, ok := appID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	keys, err := h.management.APIKeys(r.Context(), id)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	resp := apiKeysResponse{Keys: make([]apiKey, 0, len(keys))}
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, newAPIKey(key))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// issueAPIKey responds with the new key of the app, which is not shown
+// again.
+func (h *handler) issueAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := appID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	key, err := h.management.IssueAPIKey(r.Context(), adminID(r), id, req.Name)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newAPIKey(key))
+}
+
+func (h *handler) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := appID(r)
+	keyID, err := strconv.ParseInt(r.PathValue("key_id"), 10, 64)
+	if !ok || err != nil || keyID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err = h.management.RevokeAPIKey(r.Context(), adminID(r), id, keyID); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateAPIKey tells the resource server of the app whether the key form
+// value is a valid key of the app. As with token introspection, unknown,
+// revoked and foreign keys are all reported as not valid.
+func (h *handler) validateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("key") == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	key, err := h.management.ValidateAPIKey(r.Context(), authenticatedApp(r), r.PostForm.Get("key"))
+	if err != nil {
+		if errors.Is(err, management.ErrAPIKeyNotFound) {
+			writeJSON(w, http.StatusOK, apiKeyValidation{})
+			return
+		}
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiKeyValidation{
+		Valid: true,
+		KeyID: key.ID,
+		AppID: key.AppID,
+		Name:  key.Name,
+	})
+}
+
+func newAPIKey(k models.APIKey) apiKey {
+	return apiKey{
+		ID:         k.ID,
+		AppID:      k.AppID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Key:        k.Key,
+		CreatedBy:  k.CreatedBy,
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
@@ -0,0 +1,272 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"sso/internal/domain/models"
+	"time"
+)
+
+const errAppExists = "app_exists"
+
+// app is an app of the admin API. Token lifetimes are in seconds, zero
+// meaning the global ones. Quotas are logins per minute and registrations
+// per day, zero meaning no limit. Secret is only set in the responses to the
+// creation of the app and to the rotation of its secret.
+type app struct {
+	ID                int            `json:"id"`
+	OrgID             int64          `json:"org_id"`
+	Name              string         `json:"name"`
+	Secret            string         `json:"secret,omitempty"`
+	Audience          string         `json:"audience"`
+	TokenFormat       string         `json:"token_format"`
+	Scopes            []string       `json:"scopes"`
+	GrantTypes        []string       `json:"grant_types"`
+	RedirectURIs      []string       `json:"redirect_uris"`
+	Claims            map[string]any `json:"claims"`
+	AccessTokenTTL    int64          `json:"access_token_ttl"`
+	RefreshTokenTTL   int64          `json:"refresh_token_ttl"`
+	ThirdParty        bool           `json:"third_party"`
+	LoginQuota        int            `json:"login_quota"`
+	RegistrationQuota int            `json:"registration_quota"`
+	Status            string         `json:"status"`
+	CreatedAt         *time.Time     `json:"created_at,omitempty"`
+	DisabledAt        *time.Time     `json:"disabled_at,omitempty"`
+	// PreviousSecretExpiresAt is when the secret replaced by the last
+	// rotation stops being accepted.
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"`
+}
+
+type appsResponse struct {
+	Apps []app `json:"apps"`
+}
+
+// appRequest are the settings of an app admins create or update. Apps
+// without grant types may use every grant. OrgID, only read on creation,
+// is the org of the app, the default one if zero.
+type appRequest struct {
+	OrgID             int64          `json:"org_id"`
+	Name              string         `json:"name"`
+	Audience          string         `json:"audience"`
+	TokenFormat       string         `json:"token_format"`
+	Scopes            []string       `json:"scopes"`
+	GrantTypes        []string       `json:"grant_types"`
+	RedirectURIs      []string       `json:"redirect_uris"`
+	Claims            map[string]any `json:"claims"`
+	AccessTokenTTL    int64          `json:"access_token_ttl"`
+	RefreshTokenTTL   int64          `json:"refresh_token_ttl"`
+	ThirdParty        bool           `json:"third_party"`
+	LoginQuota        int            `json:"login_quota"`
+	RegistrationQuota int            `json:"registration_quota"`
+}
+
+func (req appRequest) app(id int) models.App {
+	return models.App{
+		ID:                id,
+		OrgID:             req.OrgID,
+		Name:              req.Name,
+		Audience:          req.Audience,
+		TokenFormat:       req.TokenFormat,
+		Scopes:            req.Scopes,
+		GrantTypes:        req.GrantTypes,
+		RedirectURIs:      req.RedirectURIs,
+		Claims:            req.Claims,
+		AccessTokenTTL:    time.Duration(req.AccessTokenTTL) * time.Second,
+		RefreshTokenTTL:   time.Duration(req.RefreshTokenTTL) * time.Second,
+		ThirdParty:        req.ThirdParty,
+		LoginQuota:        req.LoginQuota,
+		RegistrationQuota: req.RegistrationQuota,
+	}
+}
+
+func (h *handler) apps(w http.ResponseWriter, r *http.Request) {
+	apps, err := h.management.Apps(r.Context(), orgScope(r))
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	resp := appsResponse{Apps: make([]app, 0, len(apps))}
+	for _, a := range apps {
+		resp.Apps = append(resp.Apps, newApp(a))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) app(w http.ResponseWriter, r *http.Request) {
+	id, ok := appID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	found, err := h.management.App(r.Context(), id)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newApp(found))
+}
+
+// createApp creates the app and responds with its id and secret, which is
+// not shown again. Admins of orgs other than the default one create apps in
+// their org.
+func (h *handler) createApp(w http.ResponseWriter, r *http.Request) {
+	var req appRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+	if scope := orgScope(r); scope != 0 {
+		req.OrgID = scope
+	}
+
+	created, err := h.management.CreateApp(r.Context(), adminID(r), req.app(0))
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newApp(created))
+}
+
+// updateApp replaces the settings of the app.
+func (h *handler) updateApp(w http.ResponseWriter, r *http.Request) {
+	id, ok := appID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	var req appRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	updated, err := h.management.UpdateApp(r.Context(), adminID(r), req.app(id))
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newApp(updated))
+}
+
+func (h *handler) disableApp(w http.ResponseWriter, r *http.Request) {
+	id, ok := appID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.management.DisableApp(r.Context(), adminID(r), id); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// appStatusRequest sets the status of an app. RevokeTokens revokes the
+// tokens issued for the app for good.
+type appStatusRequest struct {
+	Status       string `json:"status"`
+	RevokeTokens bool   `json:"revoke_tokens"`
+}
+
+func (h *handler) setAppStatus(w http.ResponseWriter, r *http.Request) {
+	id, ok := appID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	var req appStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Status == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	updated, err := h.management.SetAppStatus(r.Context(), adminID(r), id, req.Status, req.RevokeTokens)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newApp(updated))
+}
+
+// rotateSecretRequest sets how long, in seconds, the replaced secret is
+// still accepted. Zero revokes it right away.
+type rotateSecretRequest struct {
+	GracePeriod int64 `json:"grace_period"`
+}
+
+// rotateAppSecret replaces the secret of the app and responds with the app
+// and its new secret, which is not shown again.
+func (h *handler) rotateAppSecret(w http.ResponseWriter, r *http.Request) {
+	id, ok := appID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	var req rotateSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	rotated, err := h.management.RotateAppSecret(r.Context(), adminID(r), id, time.Duration(req.GracePeriod)*time.Second)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newApp(rotated))
+}
+
+func newApp(a models.App) app {
+	resp := app{
+		ID:                a.ID,
+		OrgID:             a.OrgID,
+		Name:              a.Name,
+		Secret:            a.Secret,
+		Audience:          a.Audience,
+		TokenFormat:       a.TokenFormat,
+		Scopes:            a.Scopes,
+		GrantTypes:        a.GrantTypes,
+		RedirectURIs:      a.RedirectURIs,
+		Claims:            a.Claims,
+		AccessTokenTTL:    int64(a.AccessTokenTTL / time.Second),
+		RefreshTokenTTL:   int64(a.RefreshTokenTTL / time.Second),
+		ThirdParty:        a.ThirdParty,
+		LoginQuota:        a.LoginQuota,
+		RegistrationQuota: a.RegistrationQuota,
+		Status:            a.Status,
+		DisabledAt:        a.DisabledAt,
+	}
+	if a.PreviousSecretExpiresAt != nil && a.PreviousSecretExpiresAt.After(time.Now()) {
+		resp.PreviousSecretExpiresAt = a.PreviousSecretExpiresAt
+	}
+	if resp.Scopes == nil {
+		resp.Scopes = []string{}
+	}
+	if resp.GrantTypes == nil {
+		resp.GrantTypes = []string{}
+	}
+	if resp.RedirectURIs == nil {
+		resp.RedirectURIs = []string{}
+	}
+	if resp.Claims == nil {
+		resp.Claims = map[string]any{}
+	}
+	if !a.CreatedAt.IsZero() {
+		resp.CreatedAt = &a.CreatedAt
+	}
+
+	return resp
+}
@@ -0,0 +1,58 @@
+package management
+
+import (
+	"net/http"
+	"time"
+)
+
+type appStatsResponse struct {
+	AppID        int       `json:"app_id"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Logins       int       `json:"logins"`
+	FailedLogins int       `json:"failed_logins"`
+	FailureRate  float64   `json:"failure_rate"`
+	UniqueUsers  int       `json:"unique_users"`
+	TokensIssued int       `json:"tokens_issued"`
+}
+
+// appStats reports the activity of the app over the time range of the
+// optional from and to query parameters, RFC 3339 times. It covers the last
+// 30 days by default.
+func (h *handler) appStats(w http.ResponseWriter, r *http.Request) {
+	id, ok := appID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	var from, to time.Time
+	q := r.URL.Query()
+	for name, dest := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+				return
+			}
+			*dest = t
+		}
+	}
+
+	stats, err := h.management.AppStats(r.Context(), id, from, to)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, appStatsResponse{
+		AppID:        stats.AppID,
+		From:         stats.From,
+		To:           stats.To,
+		Logins:       stats.Logins,
+		FailedLogins: stats.FailedLogins,
+		FailureRate:  stats.FailureRate(),
+		UniqueUsers:  stats.UniqueUsers,
+		TokensIssued: stats.TokensIssued,
+	})
+}
@@ -0,0 +1,77 @@
+package management
+
+import (
+	"net/http"
+	"sso/internal/domain/models"
+	"strconv"
+	"time"
+)
+
+type auditEvent struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	UserID    int64     `json:"user_id,omitempty"`
+	AppID     int       `json:"app_id,omitempty"`
+	ActorID   int64     `json:"actor_id,omitempty"`
+	TokenID   string    `json:"token_id,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type auditEventsResponse struct {
+	Events []auditEvent `json:"events"`
+}
+
+// auditEvents returns the audit log, oldest first. The type and user_id
+// query parameters filter the events, after and limit page through them.
+// Admins of orgs other than the default one only see the events of the
+// users and apps of their org.
+func (h *handler) auditEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	filter := models.AuditEventFilter{Type: models.AuditEventType(q.Get("type")), OrgID: orgScope(r)}
+	for name, dest := range map[string]*int64{"user_id": &filter.UserID, "after": &filter.AfterID} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+				return
+			}
+			*dest = n
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	events, err := h.management.AuditEvents(r.Context(), filter)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	resp := auditEventsResponse{Events: make([]auditEvent, 0, len(events))}
+	for _, event := range events {
+		resp.Events = append(resp.Events, newAuditEvent(event))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func newAuditEvent(event models.AuditEvent) auditEvent {
+	return auditEvent{
+		ID:        event.ID,
+		Type:      string(event.Type),
+		UserID:    event.UserID,
+		AppID:     event.AppID,
+		ActorID:   event.ActorID,
+		TokenID:   event.TokenID,
+		Detail:    event.Detail,
+		CreatedAt: event.CreatedAt,
+	}
+}
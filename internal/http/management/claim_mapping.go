@@ -0,0 +1,204 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/services/management"
+	"time"
+)
+
+type Management interface {
+	Apps(ctx context.Context, orgID int64) ([]models.App, error)
+	App(ctx context.Context, appID int) (models.App, error)
+	CreateApp(ctx context.Context, adminID int64, app models.App) (models.App, error)
+	UpdateApp(ctx context.Context, adminID int64, app models.App) (models.App, error)
+	DisableApp(ctx context.Context, adminID int64, appID int) error
+	SetAppStatus(ctx context.Context, adminID int64, appID int, status string, revokeTokens bool) (models.App, error)
+	RotateAppSecret(ctx context.Context, adminID int64, appID int, grace time.Duration) (models.App, error)
+	AppStats(ctx context.Context, appID int, from time.Time, to time.Time) (models.AppStats, error)
+	APIKeys(ctx context.Context, appID int) ([]models.APIKey, error)
+	IssueAPIKey(ctx context.Context, adminID int64, appID int, name string) (models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, adminID int64, appID int, keyID int64) error
+	ValidateAPIKey(ctx context.Context, appID int, key string) (models.APIKey, error)
+	Orgs(ctx context.Context) ([]models.Org, error)
+	Org(ctx context.Context, orgID int64) (models.Org, error)
+	CreateOrg(ctx context.Context, adminID int64, org models.Org) (models.Org, error)
+	SetUserOrg(ctx context.Context, adminID int64, userID int64, orgID int64) (models.User, error)
+	ClaimMappings(ctx context.Context, appID int) ([]models.ClaimMapping, error)
+	SetClaimMapping(ctx context.Context, mapping models.ClaimMapping) error
+	DeleteClaimMapping(ctx context.Context, appID int, source string) error
+	IdentityProviders(ctx context.Context) ([]models.IdentityProvider, error)
+	SetIdentityProvider(ctx context.Context, provider models.IdentityProvider) error
+	DeleteIdentityProvider(ctx context.Context, name string) error
+	User(ctx context.Context, userID int64) (models.User, error)
+	UserByEmail(ctx context.Context, email string) (models.User, error)
+	ListUsers(ctx context.Context, filter models.UserFilter, pageToken string) ([]models.User, string, error)
+	SearchUsers(ctx context.Context, search string, filter models.UserFilter, pageToken string) ([]models.User, string, error)
+	UpdateUser(ctx context.Context, update models.UserUpdate) (models.User, error)
+	UnlockUser(ctx context.Context, userID int64) error
+	DeleteUser(ctx context.Context, adminID int64, userID int64) error
+	RestoreUser(ctx context.Context, adminID int64, userID int64) error
+	SuspendUser(ctx context.Context, adminID int64, userID int64) error
+	UnsuspendUser(ctx context.Context, adminID int64, userID int64) error
+	RequirePasswordChange(ctx context.Context, adminID int64, userID int64) error
+	BatchGetUsers(ctx context.Context, ids []int64, send func(users []models.User, missing []int64) error) error
+	MergeUsers(ctx context.Context, adminID int64, primaryID int64, duplicateID int64) (models.User, error)
+	SetUserExpiry(ctx context.Context, adminID int64, userID int64, expiresAt *time.Time) (models.User, error)
+	ExtendUserExpiry(ctx context.Context, adminID int64, userID int64, by time.Duration) (models.User, error)
+	ImportUser(ctx context.Context, adminID int64, user models.UserImport) (int64, bool, error)
+	ExportUserData(ctx context.Context, adminID int64, userID int64, send func(models.UserDataChunk) error) error
+	LoginHistory(ctx context.Context, userID int64) ([]models.Login, error)
+	NotificationPreferences(ctx context.Context, userID int64) (models.NotificationPreferences, error)
+	AuditEvents(ctx context.Context, filter models.AuditEventFilter) ([]models.AuditEvent, error)
+	Roles(ctx context.Context) ([]models.Role, error)
+	Role(ctx context.Context, name string) (models.Role, error)
+	SetRole(ctx context.Context, role models.Role) (models.Role, error)
+	DeleteRole(ctx context.Context, name string) error
+	UserRoles(ctx context.Context, userID int64, appID int) ([]models.Role, error)
+	HasPermission(ctx context.Context, userID int64, appID int, permission string) (bool, error)
+	AssignRole(ctx context.Context, adminID int64, userID int64, role string, appID int) error
+	RevokeRole(ctx context.Context, adminID int64, userID int64, role string, appID int) error
+	CheckPermission(
+		ctx context.Context,
+		userID int64,
+		appID int,
+		permission string,
+		resource models.Resource,
+		attributes map[string]string,
+	) (bool, error)
+	PolicyRules(ctx context.Context) ([]models.PolicyRule, error)
+	AddPolicyRule(ctx context.Context, rule models.PolicyRule) (models.PolicyRule, error)
+	DeletePolicyRule(ctx context.Context, id int64) error
+	Groups(ctx context.Context) ([]models.Group, error)
+	Group(ctx context.Context, name string) (models.Group, error)
+	SetGroup(ctx context.Context, group models.Group) (models.Group, error)
+	DeleteGroup(ctx context.Context, name string) error
+	AssignGroupRole(ctx context.Context, adminID int64, name string, role string) error
+	RevokeGroupRole(ctx context.Context, adminID int64, name string, role string) error
+	GroupMembers(ctx context.Context, name string) ([]int64, error)
+	AddGroupMember(ctx context.Context, name string, userID int64) error
+	RemoveGroupMember(ctx context.Context, name string, userID int64) error
+	UserGroups(ctx context.Context, userID int64) ([]models.Group, error)
+}
+
+type claimMapping struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+type claimMappingsResponse struct {
+	Mappings []claimMapping `json:"mappings"`
+}
+
+func (h *handler) claimMappings(w http.ResponseWriter, r *http.Request) {
+	appID, ok := appID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	mappings, err := h.management.ClaimMappings(r.Context(), appID)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	resp := claimMappingsResponse{Mappings: make([]claimMapping, 0, len(mappings))}
+	for _, mapping := range mappings {
+		resp.Mappings = append(resp.Mappings, claimMapping{Source: mapping.Source, Target: mapping.Target})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) setClaimMapping(w http.ResponseWriter, r *http.Request) {
+	appID, ok := appID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	var req struct {
+		Target string `json:"target"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	err := h.management.SetClaimMapping(r.Context(), models.ClaimMapping{
+		AppID:  appID,
+		Source: r.PathValue("source"),
+		Target: req.Target,
+	})
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) deleteClaimMapping(w http.ResponseWriter, r *http.Request) {
+	appID, ok := appID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.management.DeleteClaimMapping(r.Context(), appID, r.PathValue("source")); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeManagementError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, management.ErrInvalidClaimMapping),
+		errors.Is(err, management.ErrInvalidIdentityProvider),
+		errors.Is(err, management.ErrInvalidUserUpdate),
+		errors.Is(err, management.ErrInvalidUserFilter),
+		errors.Is(err, management.ErrInvalidUserBatch),
+		errors.Is(err, management.ErrSelfDeletion),
+		errors.Is(err, management.ErrSelfSuspension),
+		errors.Is(err, management.ErrSelfMerge),
+		errors.Is(err, management.ErrInvalidExpiry),
+		errors.Is(err, management.ErrInvalidRole),
+		errors.Is(err, management.ErrInvalidPermission),
+		errors.Is(err, management.ErrInvalidAttributes),
+		errors.Is(err, management.ErrInvalidGroup),
+		errors.Is(err, management.ErrInvalidPolicyRule),
+		errors.Is(err, management.ErrInvalidOrg),
+		errors.Is(err, management.ErrInvalidApp),
+		errors.Is(err, management.ErrInvalidAPIKey),
+		errors.Is(err, management.ErrInvalidTimeRange):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+	case errors.Is(err, management.ErrUserExists):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: errUserExists})
+	case errors.Is(err, management.ErrOrgExists):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: errOrgExists})
+	case errors.Is(err, management.ErrAppExists):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: errAppExists})
+	case errors.Is(err, management.ErrUsernameTaken):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: errUsernameTaken})
+	case errors.Is(err, management.ErrUserModified):
+		writeJSON(w, http.StatusPreconditionFailed, errorResponse{Error: errUserModified})
+	case errors.Is(err, management.ErrAppNotFound),
+		errors.Is(err, management.ErrUserNotFound),
+		errors.Is(err, management.ErrClaimMappingNotFound),
+		errors.Is(err, management.ErrIdentityProviderNotFound),
+		errors.Is(err, management.ErrRoleNotFound),
+		errors.Is(err, management.ErrGroupNotFound),
+		errors.Is(err, management.ErrOrgNotFound),
+		errors.Is(err, management.ErrPolicyRuleNotFound),
+		errors.Is(err, management.ErrAPIKeyNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: errNotFound})
+	default:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+	}
+}
@@ -0,0 +1,182 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"sso/internal/domain/models"
+	"strconv"
+	"time"
+)
+
+type group struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Roles       []string   `json:"roles"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+type groupsResponse struct {
+	Groups []group `json:"groups"`
+}
+
+type groupMembersResponse struct {
+	UserIDs []int64 `json:"user_ids"`
+}
+
+func (h *handler) groups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.management.Groups(r.Context())
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newGroupsResponse(groups))
+}
+
+func (h *handler) group(w http.ResponseWriter, r *http.Request) {
+	found, err := h.management.Group(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newGroup(found))
+}
+
+// setGroup creates the group or replaces its description and roles.
+func (h *handler) setGroup(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Description string   `json:"description"`
+		Roles       []string `json:"roles"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	saved, err := h.management.SetGroup(r.Context(), models.Group{
+		Name:        r.PathValue("name"),
+		Description: req.Description,
+		Roles:       req.Roles,
+	})
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newGroup(saved))
+}
+
+func (h *handler) deleteGroup(w http.ResponseWriter, r *http.Request) {
+	if err := h.management.DeleteGroup(r.Context(), r.PathValue("name")); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) groupMembers(w http.ResponseWriter, r *http.Request) {
+	members, err := h.management.GroupMembers(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	if members == nil {
+		members = []int64{}
+	}
+
+	writeJSON(w, http.StatusOK, groupMembersResponse{UserIDs: members})
+}
+
+func (h *handler) assignGroupRole(w http.ResponseWriter, r *http.Request) {
+	err := h.management.AssignGroupRole(r.Context(), adminID(r), r.PathValue("name"), r.PathValue("role"))
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) revokeGroupRole(w http.ResponseWriter, r *http.Request) {
+	err := h.management.RevokeGroupRole(r.Context(), adminID(r), r.PathValue("name"), r.PathValue("role"))
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) addGroupMember(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err = h.management.AddGroupMember(r.Context(), r.PathValue("name"), userID); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) removeGroupMember(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err = h.management.RemoveGroupMember(r.Context(), r.PathValue("name"), userID); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) userGroups(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	groups, err := h.management.UserGroups(r.Context(), userID)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newGroupsResponse(groups))
+}
+
+func newGroupsResponse(groups []models.Group) groupsResponse {
+	resp := groupsResponse{Groups: make([]group, 0, len(groups))}
+	for _, g := range groups {
+		resp.Groups = append(resp.Groups, newGroup(g))
+	}
+
+	return resp
+}
+
+func newGroup(g models.Group) group {
+	resp := group{
+		Name:        g.Name,
+		Description: g.Description,
+		Roles:       g.Roles,
+	}
+	if resp.Roles == nil {
+		resp.Roles = []string{}
+	}
+	if !g.CreatedAt.IsZero() {
+		resp.CreatedAt = &g.CreatedAt
+	}
+
+	return resp
+}
@@ -0,0 +1,386 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/lib/tokens"
+	"sso/internal/services/auth"
+	"strconv"
+	"strings"
+)
+
+// Authenticator checks the access tokens admin requests are made with.
+type Authenticator interface {
+	ValidateToken(ctx context.Context, token string, audience string) (tokens.Claims, error)
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+	AuthenticateApp(ctx context.Context, appID int, appSecret string) error
+}
+
+type handler struct {
+	authenticator Authenticator
+	impersonator  Impersonator
+	sessions      SessionRevoker
+	inviter       Inviter
+	orgMembership OrgMembership
+	usage         UsageReporter
+	management    Management
+	audience      string
+}
+
+type adminKey struct{}
+
+type orgKey struct{}
+
+type appKey struct{}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+const (
+	errInvalidRequest = "invalid_request"
+	errInvalidToken   = "invalid_token"
+	errInvalidClient  = "invalid_client"
+	errForbidden      = "forbidden"
+	errNotFound       = "not_found"
+	errServerError    = "server_error"
+)
+
+// RegisterHandlers registers the admin API. Every request must carry an
+// access token issued for audience to an admin user, except for those of resource servers,
+// which authenticate as their app. Admins of orgs other than the default one
+// only see and manage the users and apps of their org.
+func RegisterHandlers(
+	mux *http.ServeMux,
+	authenticator Authenticator,
+	impersonator Impersonator,
+	sessions SessionRevoker,
+	inviter Inviter,
+	orgMembership OrgMembership,
+	usage UsageReporter,
+	management Management,
+	audience string,
+) {
+	h := &handler{
+		authenticator: authenticator,
+		impersonator:  impersonator,
+		sessions:      sessions,
+		inviter:       inviter,
+		orgMembership: orgMembership,
+		usage:         usage,
+		management:    management,
+		audience:      audience,
+	}
+
+	mux.HandleFunc("GET /admin/apps", h.requireAdmin(h.apps))
+	mux.HandleFunc("POST /admin/apps", h.requireAdmin(h.createApp))
+	mux.HandleFunc("GET /admin/apps/{app_id}", h.requireAdmin(h.app))
+	mux.HandleFunc("PUT /admin/apps/{app_id}", h.requireAdmin(h.updateApp))
+	mux.HandleFunc("POST /admin/apps/{app_id}/disable", h.requireAdmin(h.disableApp))
+	mux.HandleFunc("PUT /admin/apps/{app_id}/status", h.requireAdmin(h.setAppStatus))
+	mux.HandleFunc("POST /admin/apps/{app_id}/rotate-secret", h.requireAdmin(h.rotateAppSecret))
+	mux.HandleFunc("GET /admin/apps/{app_id}/usage", h.requireAdmin(h.appUsage))
+	mux.HandleFunc("GET /admin/apps/{app_id}/stats", h.requireAdmin(h.appStats))
+	mux.HandleFunc("GET /admin/apps/{app_id}/api-keys", h.requireAdmin(h.apiKeys))
+	mux.HandleFunc("POST /admin/apps/{app_id}/api-keys", h.requireAdmin(h.issueAPIKey))
+	mux.HandleFunc("DELETE /admin/apps/{app_id}/api-keys/{key_id}", h.requireAdmin(h.revokeAPIKey))
+	mux.HandleFunc("GET /admin/apps/{app_id}/claim-mappings", h.requireAdmin(h.claimMappings))
+	mux.HandleFunc("PUT /admin/apps/{app_id}/claim-mappings/{source}", h.requireAdmin(h.setClaimMapping))
+	mux.HandleFunc("DELETE /admin/apps/{app_id}/claim-mappings/{source}", h.requireAdmin(h.deleteClaimMapping))
+	mux.HandleFunc("GET /admin/orgs", h.requirePlatformAdmin(h.orgs))
+	mux.HandleFunc("POST /admin/orgs", h.requirePlatformAdmin(h.createOrg))
+	mux.HandleFunc("GET /admin/orgs/{org_id}", h.requirePlatformAdmin(h.org))
+	mux.HandleFunc("POST /admin/orgs/{org_id}/invitations", h.requireAdmin(h.inviteToOrg))
+	mux.HandleFunc("GET /admin/orgs/{org_id}/members", h.requireAdmin(h.orgMembers))
+	mux.HandleFunc("PUT /admin/orgs/{org_id}/members/{user_id}/role", h.requireAdmin(h.setOrgMemberRole))
+	mux.HandleFunc("DELETE /admin/orgs/{org_id}/members/{user_id}", h.requireAdmin(h.removeOrgMember))
+	mux.HandleFunc("GET /admin/identity-providers", h.requirePlatformAdmin(h.identityProviders))
+	mux.HandleFunc("PUT /admin/identity-providers/{name}", h.requirePlatformAdmin(h.setIdentityProvider))
+	mux.HandleFunc("DELETE /admin/identity-providers/{name}", h.requirePlatformAdmin(h.deleteIdentityProvider))
+	mux.HandleFunc("GET /admin/roles", h.requirePlatformAdmin(h.roles))
+	mux.HandleFunc("GET /admin/roles/{name}", h.requirePlatformAdmin(h.role))
+	mux.HandleFunc("PUT /admin/roles/{name}", h.requirePlatformAdmin(h.setRole))
+	mux.HandleFunc("DELETE /admin/roles/{name}", h.requirePlatformAdmin(h.deleteRole))
+	mux.HandleFunc("GET /admin/policy-rules", h.requirePlatformAdmin(h.policyRules))
+	mux.HandleFunc("POST /admin/policy-rules", h.requirePlatformAdmin(h.addPolicyRule))
+	mux.HandleFunc("DELETE /admin/policy-rules/{id}", h.requirePlatformAdmin(h.deletePolicyRule))
+	mux.HandleFunc("GET /admin/groups", h.requirePlatformAdmin(h.groups))
+	mux.HandleFunc("GET /admin/groups/{name}", h.requirePlatformAdmin(h.group))
+	mux.HandleFunc("PUT /admin/groups/{name}", h.requirePlatformAdmin(h.setGroup))
+	mux.HandleFunc("DELETE /admin/groups/{name}", h.requirePlatformAdmin(h.deleteGroup))
+	mux.HandleFunc("PUT /admin/groups/{name}/roles/{role}", h.requirePlatformAdmin(h.assignGroupRole))
+	mux.HandleFunc("DELETE /admin/groups/{name}/roles/{role}", h.requirePlatformAdmin(h.revokeGroupRole))
+	mux.HandleFunc("GET /admin/groups/{name}/members", h.requirePlatformAdmin(h.groupMembers))
+	mux.HandleFunc("PUT /admin/groups/{name}/members/{user_id}", h.requirePlatformAdmin(h.addGroupMember))
+	mux.HandleFunc("DELETE /admin/groups/{name}/members/{user_id}", h.requirePlatformAdmin(h.removeGroupMember))
+	mux.HandleFunc("GET /admin/users", h.requireAdmin(h.users))
+	mux.HandleFunc("POST /admin/users/import", h.requireAdmin(h.importUsers))
+	mux.HandleFunc("POST /admin/users/batch-get", h.requireAdmin(h.batchGetUsers))
+	mux.HandleFunc("GET /admin/users/search", h.requireAdmin(h.searchUsers))
+	mux.HandleFunc("GET /admin/users/{user}", h.requireAdmin(h.user))
+	mux.HandleFunc("PATCH /admin/users/{user_id}", h.requireAdmin(h.updateUser))
+	mux.HandleFunc("DELETE /admin/users/{user_id}", h.requireAdmin(h.deleteUser))
+	mux.HandleFunc("POST /admin/users/{user_id}/restore", h.requireAdmin(h.restoreUser))
+	mux.HandleFunc("POST /admin/users/{user_id}/suspend", h.requireAdmin(h.suspendUser))
+	mux.HandleFunc("POST /admin/users/{user_id}/unsuspend", h.requireAdmin(h.unsuspendUser))
+	mux.HandleFunc("POST /admin/users/{user_id}/require-password-change", h.requireAdmin(h.requirePasswordChange))
+	mux.HandleFunc("POST /admin/users/{user_id}/merge", h.requireAdmin(h.mergeUsers))
+	mux.HandleFunc("PUT /admin/users/{user_id}/expiry", h.requireAdmin(h.setUserExpiry))
+	mux.HandleFunc("PUT /admin/users/{user_id}/org", h.requirePlatformAdmin(h.setUserOrg))
+	mux.HandleFunc("GET /admin/users/{user_id}/export", h.requireAdmin(h.exportUserData))
+	mux.HandleFunc("GET /admin/users/{user_id}/login-history", h.requireAdmin(h.loginHistory))
+	mux.HandleFunc("GET /admin/users/{user_id}/notification-preferences", h.requireAdmin(h.notificationPreferences))
+	mux.HandleFunc("GET /admin/users/{user_id}/roles", h.requireAdmin(h.userRoles))
+	mux.HandleFunc("PUT /admin/users/{user_id}/roles/{role}", h.requireAdmin(h.assignRole))
+	mux.HandleFunc("DELETE /admin/users/{user_id}/roles/{role}", h.requireAdmin(h.revokeRole))
+	mux.HandleFunc("GET /admin/users/{user_id}/groups", h.requireAdmin(h.userGroups))
+	mux.HandleFunc("GET /admin/users/{user_id}/permissions/{permission}", h.requireAdmin(h.hasPermission))
+	mux.HandleFunc("POST /admin/users/{user_id}/unlock", h.requireAdmin(h.unlockUser))
+	mux.HandleFunc("POST /admin/users/{user_id}/impersonate", h.requireAdmin(h.impersonate))
+	mux.HandleFunc("POST /admin/users/{user_id}/revoke-sessions", h.requireAdmin(h.revokeUserSessions))
+	mux.HandleFunc("POST /admin/invitations", h.requirePlatformAdmin(h.createInvitation))
+	mux.HandleFunc("GET /admin/audit-events", h.requireAdmin(h.auditEvents))
+	mux.HandleFunc("POST /permissions/check", h.requireApp(h.checkPermission))
+	mux.HandleFunc("POST /api-keys/validate", h.requireApp(h.validateAPIKey))
+}
+
+// requireAdmin lets the request through only if its bearer token is valid
+// and belongs to an admin user. Tokens of someone acting on behalf of the
+// user, such as impersonation tokens, are refused. Admins of orgs other than
+// the default one are only let through if the users and apps the request
+// names belong to their org.
+func (h *handler) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return h.admin(next, false)
+}
+
+// requirePlatformAdmin is requireAdmin for settings shared by every org,
+// which only admins of the default org manage.
+func (h *handler) requirePlatformAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return h.admin(next, true)
+}
+
+func (h *handler) admin(next http.HandlerFunc, platform bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			writeInvalidToken(w)
+			return
+		}
+
+		claims, err := h.authenticator.ValidateToken(r.Context(), token, h.audience)
+		if err != nil {
+			if errors.Is(err, auth.ErrWrongAudience) {
+				writeJSON(w, http.StatusForbidden, errorResponse{Error: errForbidden})
+				return
+			}
+			if errors.Is(err, auth.ErrInvalidToken) {
+				writeInvalidToken(w)
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+			return
+		}
+
+		if claims.UserID == 0 || claims.Actor != nil {
+			writeJSON(w, http.StatusForbidden, errorResponse{Error: errForbidden})
+			return
+		}
+
+		isAdmin, err := h.authenticator.IsAdmin(r.Context(), claims.UserID)
+		if err != nil {
+			if errors.Is(err, auth.ErrUserNotFound) {
+				writeInvalidToken(w)
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+			return
+		}
+		if !isAdmin {
+			writeJSON(w, http.StatusForbidden, errorResponse{Error: errForbidden})
+			return
+		}
+
+		// users moved to another org have their tokens revoked, so the claim
+		// is current; tokens without it predate orgs
+		scope := claims.OrgID
+		if scope == models.DefaultOrgID {
+			scope = 0
+		}
+		if scope != 0 && platform {
+			writeJSON(w, http.StatusForbidden, errorResponse{Error: errForbidden})
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), adminKey{}, claims.UserID)
+		r = r.WithContext(context.WithValue(ctx, orgKey{}, scope))
+		if scope != 0 && !h.withinOrg(w, r, scope) {
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// withinOrg checks that the org, users and apps named by the path and the
+// app_id query parameter are, or belong to, the org. It writes a not found response if they
+// do not, so that admins can not tell users and apps of other orgs from
+// missing ones. Malformed ids are left for the handler to reject.
+func (h *handler) withinOrg(w http.ResponseWriter, r *http.Request, orgID int64) bool {
+	ctx := r.Context()
+
+	if id, err := strconv.ParseInt(r.PathValue("org_id"), 10, 64); err == nil && id != orgID {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: errNotFound})
+		return false
+	}
+
+	if key := r.PathValue("user"); strings.Contains(key, "@") {
+		user, err := h.management.UserByEmail(ctx, key)
+		if !inOrg(w, user.OrgID, orgID, err) {
+			return false
+		}
+	} else if userID, err := strconv.ParseInt(key, 10, 64); err == nil {
+		user, err := h.management.User(ctx, userID)
+		if !inOrg(w, user.OrgID, orgID, err) {
+			return false
+		}
+	}
+
+	if userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64); err == nil {
+		user, err := h.management.User(ctx, userID)
+		if !inOrg(w, user.OrgID, orgID, err) {
+			return false
+		}
+	}
+
+	for _, id := range []func(*http.Request) (int, bool){appID, scopedAppID} {
+		if appID, ok := id(r); ok && appID != 0 {
+			app, err := h.management.App(ctx, appID)
+			if !inOrg(w, app.OrgID, orgID, err) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// inOrg writes the error response and returns false if the user or app
+// could not be found or belongs to another org than orgID.
+func inOrg(w http.ResponseWriter, found int64, orgID int64, err error) bool {
+	if err != nil {
+		writeManagementError(w, err)
+		return false
+	}
+	if found != orgID {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: errNotFound})
+		return false
+	}
+
+	return true
+}
+
+// requireApp lets the request through only if it carries the credentials
+// of an app with HTTP basic authentication.
+func (h *handler) requireApp(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, ok := r.BasicAuth()
+		appID, err := strconv.Atoi(clientID)
+		if !ok || err != nil || appID <= 0 || clientSecret == "" {
+			writeInvalidClient(w)
+			return
+		}
+
+		if err = h.authenticator.AuthenticateApp(r.Context(), appID, clientSecret); err != nil {
+			if errors.Is(err, auth.ErrInvalidClient) {
+				writeInvalidClient(w)
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), appKey{}, appID)))
+	}
+}
+
+// adminID returns the id of the admin requireAdmin let the request through
+// for.
+func adminID(r *http.Request) int64 {
+	id, _ := r.Context().Value(adminKey{}).(int64)
+
+	return id
+}
+
+// orgScope returns the org of the admin requireAdmin let the request
+// through for, or zero if the admin manages every org.
+func orgScope(r *http.Request) int64 {
+	id, _ := r.Context().Value(orgKey{}).(int64)
+
+	return id
+}
+
+// authenticatedApp returns the id of the app requireApp let the request
+// through for.
+func authenticatedApp(r *http.Request) int {
+	id, _ := r.Context().Value(appKey{}).(int)
+
+	return id
+}
+
+// appID returns the app_id path value.
+func appID(r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("app_id"))
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+
+	return id, true
+}
+
+// scopedAppID returns the optional app_id query parameter, which is zero if
+// absent.
+func scopedAppID(r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("app_id")
+	if value == "" {
+		return 0, true
+	}
+
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+
+	return id, true
+}
+
+// bearerToken extracts the token from the Authorization header (RFC 6750).
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	return header[len(prefix):], true
+}
+
+func writeInvalidToken(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	writeJSON(w, http.StatusUnauthorized, errorResponse{Error: errInvalidToken})
+}
+
+func writeInvalidClient(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="sso"`)
+	writeJSON(w, http.StatusUnauthorized, errorResponse{Error: errInvalidClient})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,102 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"sso/internal/domain/models"
+)
+
+type identityClaims struct {
+	Subject       string `json:"subject,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified string `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+}
+
+// identityProvider is a stored provider as shown to admins. The client
+// secret is write-only.
+type identityProvider struct {
+	Name     string         `json:"name"`
+	Issuer   string         `json:"issuer"`
+	ClientID string         `json:"client_id"`
+	Scopes   []string       `json:"scopes"`
+	Claims   identityClaims `json:"claims"`
+}
+
+type identityProvidersResponse struct {
+	Providers []identityProvider `json:"providers"`
+}
+
+func (h *handler) identityProviders(w http.ResponseWriter, r *http.Request) {
+	providers, err := h.management.IdentityProviders(r.Context())
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	resp := identityProvidersResponse{Providers: make([]identityProvider, 0, len(providers))}
+	for _, provider := range providers {
+		scopes := provider.Scopes
+		if scopes == nil {
+			scopes = []string{}
+		}
+
+		resp.Providers = append(resp.Providers, identityProvider{
+			Name:     provider.Name,
+			Issuer:   provider.Issuer,
+			ClientID: provider.ClientID,
+			Scopes:   scopes,
+			Claims: identityClaims{
+				Subject:       provider.Claims.Subject,
+				Email:         provider.Claims.Email,
+				EmailVerified: provider.Claims.EmailVerified,
+				Name:          provider.Claims.Name,
+			},
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) setIdentityProvider(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Issuer       string         `json:"issuer"`
+		ClientID     string         `json:"client_id"`
+		ClientSecret string         `json:"client_secret"`
+		Scopes       []string       `json:"scopes"`
+		Claims       identityClaims `json:"claims"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	err := h.management.SetIdentityProvider(r.Context(), models.IdentityProvider{
+		Name:         r.PathValue("name"),
+		Issuer:       req.Issuer,
+		ClientID:     req.ClientID,
+		ClientSecret: req.ClientSecret,
+		Scopes:       req.Scopes,
+		Claims: models.IdentityClaims{
+			Subject:       req.Claims.Subject,
+			Email:         req.Claims.Email,
+			EmailVerified: req.Claims.EmailVerified,
+			Name:          req.Claims.Name,
+		},
+	})
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) deleteIdentityProvider(w http.ResponseWriter, r *http.Request) {
+	if err := h.management.DeleteIdentityProvider(r.Context(), r.PathValue("name")); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
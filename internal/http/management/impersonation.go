@@ -0,0 +1,71 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/services/auth"
+	"strconv"
+)
+
+// Impersonator issues the tokens admins act as users with.
+type Impersonator interface {
+	Impersonate(ctx context.Context, adminID int64, targetUserID int64, appID int) (models.TokenPair, error)
+}
+
+type impersonationRequest struct {
+	AppID int `json:"app_id"`
+}
+
+type impersonationResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// impersonate issues the admin a token of the user for the app.
+func (h *handler) impersonate(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	var req impersonationRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil || req.AppID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if scope := orgScope(r); scope != 0 {
+		app, err := h.management.App(r.Context(), req.AppID)
+		if err == nil && app.OrgID != scope && app.OrgID != models.DefaultOrgID {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+			return
+		}
+	}
+
+	pair, err := h.impersonator.Impersonate(r.Context(), adminID(r), userID, req.AppID)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrUserNotFound):
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: errNotFound})
+		case errors.Is(err, auth.ErrInvalidAppID):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		case errors.Is(err, auth.ErrNotAdmin),
+			errors.Is(err, auth.ErrImpersonationNotAllowed):
+			writeJSON(w, http.StatusForbidden, errorResponse{Error: errForbidden})
+		default:
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, impersonationResponse{
+		AccessToken: pair.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(pair.ExpiresIn.Seconds()),
+	})
+}
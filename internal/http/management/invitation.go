@@ -0,0 +1,82 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/services/auth"
+	"time"
+)
+
+// Inviter invites users to register on deployments with closed registration.
+type Inviter interface {
+	CreateInvitation(ctx context.Context, adminID int64, invitation models.Invitation) (models.Invitation, error)
+}
+
+const (
+	roleUser  = "user"
+	roleAdmin = "admin"
+)
+
+const errUserExists = "user_exists"
+
+type invitationRequest struct {
+	Email string `json:"email"`
+	// Role is user, the default, or admin.
+	Role  string `json:"role"`
+	AppID int    `json:"app_id"`
+}
+
+type invitationResponse struct {
+	ID        int64     `json:"id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	AppID     int       `json:"app_id,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// createInvitation emails an invitation to register. The token is only sent
+// to the invited email, never to the admin.
+func (h *handler) createInvitation(w http.ResponseWriter, r *http.Request) {
+	var req invitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" || req.AppID < 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+	if req.Role == "" {
+		req.Role = roleUser
+	}
+	if req.Role != roleUser && req.Role != roleAdmin {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	invitation, err := h.inviter.CreateInvitation(r.Context(), adminID(r), models.Invitation{
+		Email:   req.Email,
+		IsAdmin: req.Role == roleAdmin,
+		AppID:   req.AppID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrUserExists):
+			writeJSON(w, http.StatusConflict, errorResponse{Error: errUserExists})
+		case errors.Is(err, auth.ErrInvalidAppID):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		case errors.Is(err, auth.ErrNotAdmin):
+			writeJSON(w, http.StatusForbidden, errorResponse{Error: errForbidden})
+		default:
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, invitationResponse{
+		ID:        invitation.ID,
+		Email:     invitation.Email,
+		Role:      req.Role,
+		AppID:     invitation.AppID,
+		ExpiresAt: invitation.ExpiresAt,
+	})
+}
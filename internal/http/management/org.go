@@ -0,0 +1,104 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"sso/internal/domain/models"
+	"strconv"
+	"time"
+)
+
+const errOrgExists = "org_exists"
+
+type org struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+type orgsResponse struct {
+	Orgs []org `json:"orgs"`
+}
+
+func (h *handler) orgs(w http.ResponseWriter, r *http.Request) {
+	orgs, err := h.management.Orgs(r.Context())
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	resp := orgsResponse{Orgs: make([]org, 0, len(orgs))}
+	for _, o := range orgs {
+		resp.Orgs = append(resp.Orgs, newOrg(o))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) org(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("org_id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	found, err := h.management.Org(r.Context(), id)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newOrg(found))
+}
+
+func (h *handler) createOrg(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	created, err := h.management.CreateOrg(r.Context(), adminID(r), models.Org{Name: req.Name})
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newOrg(created))
+}
+
+// setUserOrg moves the user to the org of the body and returns the user.
+func (h *handler) setUserOrg(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	var req struct {
+		OrgID int64 `json:"org_id"`
+	}
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil || req.OrgID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	user, err := h.management.SetUserOrg(r.Context(), adminID(r), userID, req.OrgID)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeUser(w, user)
+}
+
+func newOrg(o models.Org) org {
+	resp := org{ID: o.ID, Name: o.Name}
+	if !o.CreatedAt.IsZero() {
+		resp.CreatedAt = &o.CreatedAt
+	}
+
+	return resp
+}
@@ -0,0 +1,187 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/services/auth"
+	"strconv"
+	"time"
+)
+
+// OrgMembership invites users to orgs and manages the members of orgs.
+type OrgMembership interface {
+	InviteToOrg(ctx context.Context, adminID int64, invitation models.OrgInvitation) (models.OrgInvitation, error)
+	SetOrgMemberRole(ctx context.Context, adminID int64, orgID int64, userID int64, role string) error
+	RemoveOrgMember(ctx context.Context, adminID int64, orgID int64, userID int64) error
+}
+
+const errAlreadyMember = "already_member"
+
+type orgInvitationResponse struct {
+	ID        int64     `json:"id"`
+	OrgID     int64     `json:"org_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type orgMember struct {
+	UserID int64  `json:"user_id"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+}
+
+type orgMembersResponse struct {
+	Members       []orgMember `json:"members"`
+	NextPageToken string      `json:"next_page_token,omitempty"`
+}
+
+// inviteToOrg emails an invitation to join the org. As with invitations to
+// register, the token is only sent to the invited email.
+func (h *handler) inviteToOrg(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.ParseInt(r.PathValue("org_id"), 10, 64)
+	if err != nil || orgID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+		// Role is member, the default, or admin.
+		Role string `json:"role"`
+	}
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	invitation, err := h.orgMembership.InviteToOrg(r.Context(), adminID(r), models.OrgInvitation{
+		OrgID: orgID,
+		Email: req.Email,
+		Role:  req.Role,
+	})
+	if err != nil {
+		writeOrgMembershipError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, orgInvitationResponse{
+		ID:        invitation.ID,
+		OrgID:     invitation.OrgID,
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		ExpiresAt: invitation.ExpiresAt,
+	})
+}
+
+// orgMembers lists the members of the org. It takes the page_size and
+// page_token query parameters of users.
+func (h *handler) orgMembers(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.ParseInt(r.PathValue("org_id"), 10, 64)
+	if err != nil || orgID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	filter := models.UserFilter{OrgID: orgID}
+	q := r.URL.Query()
+	if v := q.Get("page_size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size <= 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+			return
+		}
+		filter.Limit = size
+	}
+
+	if _, err = h.management.Org(r.Context(), orgID); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	users, next, err := h.management.ListUsers(r.Context(), filter, q.Get("page_token"))
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	resp := orgMembersResponse{Members: make([]orgMember, 0, len(users)), NextPageToken: next}
+	for _, user := range users {
+		resp.Members = append(resp.Members, orgMember{
+			UserID: int64(user.ID),
+			Email:  user.Email,
+			Role:   models.OrgRole(user),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) setOrgMemberRole(w http.ResponseWriter, r *http.Request) {
+	orgID, userID, ok := orgMemberPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Role == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err := h.orgMembership.SetOrgMemberRole(r.Context(), adminID(r), orgID, userID, req.Role); err != nil {
+		writeOrgMembershipError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) removeOrgMember(w http.ResponseWriter, r *http.Request) {
+	orgID, userID, ok := orgMemberPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.orgMembership.RemoveOrgMember(r.Context(), adminID(r), orgID, userID); err != nil {
+		writeOrgMembershipError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// orgMemberPath parses the org and user ids of the path. It writes the error
+// response if they are invalid.
+func orgMemberPath(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	orgID, err := strconv.ParseInt(r.PathValue("org_id"), 10, 64)
+	if err != nil || orgID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return 0, 0, false
+	}
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return 0, 0, false
+	}
+
+	return orgID, userID, true
+}
+
+func writeOrgMembershipError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidOrgMembership):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+	case errors.Is(err, auth.ErrAlreadyOrgMember):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: errAlreadyMember})
+	case errors.Is(err, auth.ErrOrgNotFound), errors.Is(err, auth.ErrUserNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: errNotFound})
+	default:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+	}
+}
@@ -0,0 +1,80 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"sso/internal/domain/models"
+	"strconv"
+	"time"
+)
+
+type policyRule struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	Values    []string  `json:"values"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type policyRulesResponse struct {
+	Rules []policyRule `json:"rules"`
+}
+
+func (h *handler) policyRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.management.PolicyRules(r.Context())
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	resp := policyRulesResponse{Rules: make([]policyRule, 0, len(rules))}
+	for _, rule := range rules {
+		resp.Rules = append(resp.Rules, newPolicyRule(rule))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// addPolicyRule adds a rule of the policy engine, as
+// {"type": "p", "values": ["r.sub.ID == r.obj.OwnerID", "*", "documents:edit"]}.
+func (h *handler) addPolicyRule(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Type   string   `json:"type"`
+		Values []string `json:"values"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	rule, err := h.management.AddPolicyRule(r.Context(), models.PolicyRule{Type: req.Type, Values: req.Values})
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newPolicyRule(rule))
+}
+
+func (h *handler) deletePolicyRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err = h.management.DeletePolicyRule(r.Context(), id); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newPolicyRule(rule models.PolicyRule) policyRule {
+	return policyRule{
+		ID:        rule.ID,
+		Type:      rule.Type,
+		Values:    rule.Values,
+		CreatedAt: rule.CreatedAt,
+	}
+}
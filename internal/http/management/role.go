@@ -0,0 +1,280 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"sso/internal/domain/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type role struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Permissions []string          `json:"permissions"`
+	Conditions  map[string]string `json:"conditions,omitempty"`
+	Inherits    []string          `json:"inherits"`
+	CreatedAt   *time.Time        `json:"created_at,omitempty"`
+}
+
+type rolesResponse struct {
+	Roles []role `json:"roles"`
+}
+
+type permissionResponse struct {
+	Allowed bool `json:"allowed"`
+}
+
+func (h *handler) roles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.management.Roles(r.Context())
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newRolesResponse(roles))
+}
+
+func (h *handler) role(w http.ResponseWriter, r *http.Request) {
+	found, err := h.management.Role(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newRole(found))
+}
+
+// setRole creates the role or replaces its description, permissions, their
+// conditions and inherited roles.
+func (h *handler) setRole(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Description string            `json:"description"`
+		Permissions []string          `json:"permissions"`
+		Conditions  map[string]string `json:"conditions"`
+		Inherits    []string          `json:"inherits"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	saved, err := h.management.SetRole(r.Context(), models.Role{
+		Name:        r.PathValue("name"),
+		Description: req.Description,
+		Permissions: req.Permissions,
+		Conditions:  req.Conditions,
+		Inherits:    req.Inherits,
+	})
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newRole(saved))
+}
+
+func (h *handler) deleteRole(w http.ResponseWriter, r *http.Request) {
+	if err := h.management.DeleteRole(r.Context(), r.PathValue("name")); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userRoles returns the roles of the user within the app of the app_id query
+// parameter, or the global ones without it.
+func (h *handler) userRoles(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	appID, ok := scopedAppID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	roles, err := h.management.UserRoles(r.Context(), userID, appID)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newRolesResponse(roles))
+}
+
+// assignRole grants the role to the user within the app of the app_id query
+// parameter, or globally without it.
+func (h *handler) assignRole(w http.ResponseWriter, r *http.Request) {
+	userID, appID, ok := roleAssignment(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+	// global roles hold within every org
+	if appID == 0 && orgScope(r) != 0 {
+		writeJSON(w, http.StatusForbidden, errorResponse{Error: errForbidden})
+		return
+	}
+
+	if err := h.management.AssignRole(r.Context(), adminID(r), userID, r.PathValue("role"), appID); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeRole takes the role the user has within the app of the app_id query
+// parameter, or globally without it.
+func (h *handler) revokeRole(w http.ResponseWriter, r *http.Request) {
+	userID, appID, ok := roleAssignment(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+	// global roles hold within every org
+	if appID == 0 && orgScope(r) != 0 {
+		writeJSON(w, http.StatusForbidden, errorResponse{Error: errForbidden})
+		return
+	}
+
+	if err := h.management.RevokeRole(r.Context(), adminID(r), userID, r.PathValue("role"), appID); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// roleAssignment returns the user_id path value and the app_id query
+// parameter of role assignments.
+func roleAssignment(r *http.Request) (int64, int, bool) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		return 0, 0, false
+	}
+
+	appID, ok := scopedAppID(r)
+	if !ok {
+		return 0, 0, false
+	}
+
+	return userID, appID, true
+}
+
+// hasPermission tells whether the user holds the permission within the app
+// of the app_id query parameter, or globally without it.
+func (h *handler) hasPermission(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	appID, ok := scopedAppID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	allowed, err := h.management.HasPermission(r.Context(), userID, appID, r.PathValue("permission"))
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, permissionResponse{Allowed: allowed})
+}
+
+func newRolesResponse(roles []models.Role) rolesResponse {
+	resp := rolesResponse{Roles: make([]role, 0, len(roles))}
+	for _, r := range roles {
+		resp.Roles = append(resp.Roles, newRole(r))
+	}
+
+	return resp
+}
+
+func newRole(r models.Role) role {
+	resp := role{
+		Name:        r.Name,
+		Description: r.Description,
+		Permissions: r.Permissions,
+		Conditions:  r.Conditions,
+		Inherits:    r.Inherits,
+	}
+	if resp.Permissions == nil {
+		resp.Permissions = []string{}
+	}
+	if resp.Inherits == nil {
+		resp.Inherits = []string{}
+	}
+	if !r.CreatedAt.IsZero() {
+		resp.CreatedAt = &r.CreatedAt
+	}
+
+	return resp
+}
+
+// checkPermission tells the resource server of the app whether the user
+// of the user_id form value holds the permission form value. The optional
+// resource_type, resource_id and resource_owner_id form values describe the
+// resource the permission is checked on, and form values named
+// attrs.<name> are the attributes of the check, such as attrs.tenant.
+func (h *handler) checkPermission(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	userID, err := strconv.ParseInt(r.PostForm.Get("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	resource := models.Resource{
+		Type: r.PostForm.Get("resource_type"),
+		ID:   r.PostForm.Get("resource_id"),
+	}
+	if v := r.PostForm.Get("resource_owner_id"); v != "" {
+		resource.OwnerID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || resource.OwnerID <= 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+			return
+		}
+	}
+
+	var attributes map[string]string
+	for key, values := range r.PostForm {
+		name, ok := strings.CutPrefix(key, "attrs.")
+		if !ok {
+			continue
+		}
+		if attributes == nil {
+			attributes = make(map[string]string)
+		}
+		attributes[name] = values[0]
+	}
+
+	allowed, err := h.management.CheckPermission(
+		r.Context(),
+		userID,
+		authenticatedApp(r),
+		r.PostForm.Get("permission"),
+		resource,
+		attributes,
+	)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, permissionResponse{Allowed: allowed})
+}
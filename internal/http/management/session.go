@@ -0,0 +1,35 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sso/internal/services/auth"
+	"strconv"
+)
+
+// SessionRevoker logs users out everywhere.
+type SessionRevoker interface {
+	RevokeUserSessions(ctx context.Context, userID int64) error
+}
+
+// revokeUserSessions invalidates every token issued to the user so far and
+// revokes the refresh tokens of the user.
+func (h *handler) revokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err = h.sessions.RevokeUserSessions(r.Context(), userID); err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: errNotFound})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,56 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/services/auth"
+	"time"
+)
+
+// UsageReporter reports the usage of the quotas of apps.
+type UsageReporter interface {
+	AppUsage(ctx context.Context, appID int) (models.AppUsage, error)
+}
+
+// quotaUsage is the usage of a quota in its current window. Limit is zero
+// if the app has no limit.
+type quotaUsage struct {
+	Used     int       `json:"used"`
+	Limit    int       `json:"limit"`
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+type appUsageResponse struct {
+	AppID         int        `json:"app_id"`
+	Logins        quotaUsage `json:"logins"`
+	Registrations quotaUsage `json:"registrations"`
+}
+
+// appUsage reports the logins to the app this minute and the registrations
+// through it today against its quotas.
+func (h *handler) appUsage(w http.ResponseWriter, r *http.Request) {
+	id, ok := appID(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	usage, err := h.usage.AppUsage(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidAppID) {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: errNotFound})
+			return
+		}
+
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, appUsageResponse{
+		AppID:         usage.AppID,
+		Logins:        quotaUsage(usage.Logins),
+		Registrations: quotaUsage(usage.Registrations),
+	})
+}
@@ -0,0 +1,538 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"sso/internal/domain/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	errUsernameTaken = "username_taken"
+	errUserModified  = "user_modified"
+)
+
+type userResponse struct {
+	ID                  int               `json:"id"`
+	Email               string            `json:"email"`
+	Username            string            `json:"username,omitempty"`
+	DisplayName         string            `json:"display_name,omitempty"`
+	FirstName           string            `json:"first_name,omitempty"`
+	LastName            string            `json:"last_name,omitempty"`
+	Locale              string            `json:"locale,omitempty"`
+	AvatarURL           string            `json:"avatar_url,omitempty"`
+	EmailVerified       bool              `json:"email_verified"`
+	Phone               string            `json:"phone,omitempty"`
+	PhoneVerified       bool              `json:"phone_verified"`
+	IsAdmin             bool              `json:"is_admin"`
+	OrgID               int64             `json:"org_id"`
+	Roles               []string          `json:"roles"`
+	Guest               bool              `json:"guest"`
+	Status              string            `json:"status"`
+	MustChangePassword  bool              `json:"password_change_required"`
+	LockedUntil         *time.Time        `json:"locked_until,omitempty"`
+	DeletionScheduledAt *time.Time        `json:"deletion_scheduled_at,omitempty"`
+	DeletedAt           *time.Time        `json:"deleted_at,omitempty"`
+	LastLoginAt         *time.Time        `json:"last_login_at,omitempty"`
+	LastLoginIP         string            `json:"last_login_ip,omitempty"`
+	LastLoginUserAgent  string            `json:"last_login_user_agent,omitempty"`
+	ExpiresAt           *time.Time        `json:"expires_at,omitempty"`
+	ExpiredAt           *time.Time        `json:"expired_at,omitempty"`
+	CreatedAt           *time.Time        `json:"created_at,omitempty"`
+	UpdatedAt           *time.Time        `json:"updated_at,omitempty"`
+	AppMetadata         map[string]string `json:"app_metadata,omitempty"`
+	UserMetadata        map[string]string `json:"user_metadata,omitempty"`
+	ETag                string            `json:"etag"`
+}
+
+// updateUserRequest sets the fields named in UpdateMask, a comma-separated
+// list like the JSON form of a protobuf FieldMask. Other fields are ignored.
+type updateUserRequest struct {
+	UpdateMask   string            `json:"update_mask"`
+	Email        string            `json:"email"`
+	Username     string            `json:"username"`
+	DisplayName  string            `json:"display_name"`
+	FirstName    string            `json:"first_name"`
+	LastName     string            `json:"last_name"`
+	Locale       string            `json:"locale"`
+	AvatarURL    string            `json:"avatar_url"`
+	AppMetadata  map[string]string `json:"app_metadata"`
+	UserMetadata map[string]string `json:"user_metadata"`
+}
+
+type usersResponse struct {
+	Users         []userResponse `json:"users"`
+	NextPageToken string         `json:"next_page_token,omitempty"`
+}
+
+// users lists the users. The email_prefix, email_verified, admin and
+// created_after query parameters filter them, sort orders them by
+// created_at or email, descending with a leading "-", and page_size and
+// page_token page through them.
+func (h *handler) users(w http.ResponseWriter, r *http.Request) {
+	filter, ok := userFilter(w, r)
+	if !ok {
+		return
+	}
+
+	users, next, err := h.management.ListUsers(r.Context(), filter, r.URL.Query().Get("page_token"))
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeUsers(w, users, next)
+}
+
+// searchUsers lists the users whose email, username or display name have
+// words starting with the words of the q query parameter. It takes the
+// query parameters of users otherwise.
+func (h *handler) searchUsers(w http.ResponseWriter, r *http.Request) {
+	filter, ok := userFilter(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	users, next, err := h.management.SearchUsers(r.Context(), q.Get("q"), filter, q.Get("page_token"))
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeUsers(w, users, next)
+}
+
+// userFilter parses the filter, sort and page size of a user listing from
+// the query parameters. It writes the error response if they are invalid.
+func userFilter(w http.ResponseWriter, r *http.Request) (models.UserFilter, bool) {
+	q := r.URL.Query()
+
+	filter := models.UserFilter{EmailPrefix: q.Get("email_prefix"), OrgID: orgScope(r)}
+	for name, dest := range map[string]**bool{"email_verified": &filter.EmailVerified, "admin": &filter.Admin} {
+		if v := q.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+				return models.UserFilter{}, false
+			}
+			*dest = &b
+		}
+	}
+	if v := q.Get("created_after"); v != "" {
+		createdAfter, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+			return models.UserFilter{}, false
+		}
+		filter.CreatedAfter = createdAfter
+	}
+	if v := q.Get("sort"); v != "" {
+		sort, desc := strings.CutPrefix(v, "-")
+		filter.Sort = models.UserSort(sort)
+		filter.Desc = desc
+	}
+	if v := q.Get("page_size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size <= 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+			return models.UserFilter{}, false
+		}
+		filter.Limit = size
+	}
+
+	return filter, true
+}
+
+func writeUsers(w http.ResponseWriter, users []models.User, nextPageToken string) {
+	resp := usersResponse{Users: make([]userResponse, 0, len(users)), NextPageToken: nextPageToken}
+	for _, user := range users {
+		resp.Users = append(resp.Users, newUserResponse(user))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// user returns the user with the id, or with the email if the path names
+// one.
+func (h *handler) user(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	var err error
+	if key := r.PathValue("user"); strings.Contains(key, "@") {
+		user, err = h.management.UserByEmail(r.Context(), key)
+	} else {
+		userID, parseErr := strconv.ParseInt(key, 10, 64)
+		if parseErr != nil || userID <= 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+			return
+		}
+		user, err = h.management.User(r.Context(), userID)
+	}
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeUser(w, user)
+}
+
+// updateUser patches the fields of the update mask. An If-Match header with
+// the etag of the user makes the update fail with 412 if the user has
+// changed since.
+func (h *handler) updateUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	var req updateUserRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	update := models.UserUpdate{
+		UserID:       userID,
+		Email:        req.Email,
+		Username:     req.Username,
+		DisplayName:  req.DisplayName,
+		FirstName:    req.FirstName,
+		LastName:     req.LastName,
+		Locale:       req.Locale,
+		AvatarURL:    req.AvatarURL,
+		AppMetadata:  req.AppMetadata,
+		UserMetadata: req.UserMetadata,
+	}
+	for _, field := range strings.Split(req.UpdateMask, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			update.Fields = append(update.Fields, field)
+		}
+	}
+	if match := r.Header.Get("If-Match"); match != "" {
+		version, err := strconv.ParseInt(strings.Trim(match, `"`), 10, 64)
+		if err != nil || version <= 0 {
+			writeJSON(w, http.StatusPreconditionFailed, errorResponse{Error: errUserModified})
+			return
+		}
+		update.Version = version
+	}
+
+	user, err := h.management.UpdateUser(r.Context(), update)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeUser(w, user)
+}
+
+func (h *handler) unlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err = h.management.UnlockUser(r.Context(), userID); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) deleteUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err = h.management.DeleteUser(r.Context(), adminID(r), userID); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) restoreUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err = h.management.RestoreUser(r.Context(), adminID(r), userID); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) suspendUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err = h.management.SuspendUser(r.Context(), adminID(r), userID); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) unsuspendUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err = h.management.UnsuspendUser(r.Context(), adminID(r), userID); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) requirePasswordChange(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	if err = h.management.RequirePasswordChange(r.Context(), adminID(r), userID); err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mergeUsersRequest names the user merged into the one of the path.
+type mergeUsersRequest struct {
+	DuplicateID int64 `json:"duplicate_id"`
+}
+
+// mergeUsers merges the duplicate user into the user of the path and
+// returns the latter.
+func (h *handler) mergeUsers(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	var req mergeUsersRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil || req.DuplicateID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+	if scope := orgScope(r); scope != 0 {
+		duplicate, err := h.management.User(r.Context(), req.DuplicateID)
+		if !inOrg(w, duplicate.OrgID, scope, err) {
+			return
+		}
+	}
+
+	user, err := h.management.MergeUsers(r.Context(), adminID(r), userID, req.DuplicateID)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeUser(w, user)
+}
+
+// userExpiryRequest either sets when the account expires, never if ExpiresAt
+// is null or missing, or extends its expiry by ExtendBy, a duration like
+// "720h".
+type userExpiryRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	ExtendBy  string     `json:"extend_by"`
+}
+
+// setUserExpiry sets or extends the expiry of the account of the user and
+// returns the user.
+func (h *handler) setUserExpiry(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	var req userExpiryRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	var user models.User
+	if req.ExtendBy != "" {
+		if req.ExpiresAt != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+			return
+		}
+
+		by, parseErr := time.ParseDuration(req.ExtendBy)
+		if parseErr != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+			return
+		}
+		user, err = h.management.ExtendUserExpiry(r.Context(), adminID(r), userID, by)
+	} else {
+		user, err = h.management.SetUserExpiry(r.Context(), adminID(r), userID, req.ExpiresAt)
+	}
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	writeUser(w, user)
+}
+
+// writeUser writes the user with its etag, which is also set as the ETag
+// header.
+func writeUser(w http.ResponseWriter, user models.User) {
+	resp := newUserResponse(user)
+	w.Header().Set("ETag", resp.ETag)
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func newUserResponse(user models.User) userResponse {
+	resp := userResponse{
+		ID:                  user.ID,
+		Email:               user.Email,
+		Username:            user.Username,
+		DisplayName:         user.DisplayName,
+		FirstName:           user.FirstName,
+		LastName:            user.LastName,
+		Locale:              user.Locale,
+		AvatarURL:           user.AvatarURL,
+		EmailVerified:       user.EmailVerified,
+		Phone:               user.Phone,
+		PhoneVerified:       user.PhoneVerified,
+		IsAdmin:             user.IsAdmin,
+		OrgID:               user.OrgID,
+		Roles:               []string{},
+		Guest:               user.IsGuest,
+		Status:              string(user.Status),
+		MustChangePassword:  user.PasswordChangeRequired,
+		DeletionScheduledAt: user.DeletionScheduledAt,
+		DeletedAt:           user.DeletedAt,
+		LastLoginAt:         user.LastLoginAt,
+		LastLoginIP:         user.LastLoginIP,
+		LastLoginUserAgent:  user.LastLoginUserAgent,
+		ExpiresAt:           user.ExpiresAt,
+		ExpiredAt:           user.ExpiredAt,
+		AppMetadata:         user.AppMetadata,
+		UserMetadata:        user.UserMetadata,
+		ETag:                `"` + strconv.FormatInt(user.Version, 10) + `"`,
+	}
+	// the roles stored for the user are listed by /admin/users/{id}/roles,
+	// which a listing of users would query once per user
+	if user.IsAdmin {
+		resp.Roles = append(resp.Roles, "admin")
+	}
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		resp.LockedUntil = user.LockedUntil
+	}
+	if !user.CreatedAt.IsZero() {
+		resp.CreatedAt = &user.CreatedAt
+	}
+	if !user.UpdatedAt.IsZero() {
+		resp.UpdatedAt = &user.UpdatedAt
+	}
+
+	return resp
+}
+
+type loginResponse struct {
+	AppID     int       `json:"app_id"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Device    string    `json:"device"`
+	AMR       []string  `json:"amr"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type loginHistoryResponse struct {
+	Logins []loginResponse `json:"logins"`
+}
+
+// loginHistory returns the latest logins of the user, the most recent
+// first.
+func (h *handler) loginHistory(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	logins, err := h.management.LoginHistory(r.Context(), userID)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	resp := loginHistoryResponse{Logins: make([]loginResponse, 0, len(logins))}
+	for _, login := range logins {
+		resp.Logins = append(resp.Logins, newLoginResponse(login))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type notificationPreferencesResponse struct {
+	SecurityEmails bool       `json:"security_emails"`
+	LoginAlerts    bool       `json:"login_alerts"`
+	Marketing      bool       `json:"marketing"`
+	UpdatedAt      *time.Time `json:"updated_at,omitempty"`
+}
+
+func (h *handler) notificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	prefs, err := h.management.NotificationPreferences(r.Context(), userID)
+	if err != nil {
+		writeManagementError(w, err)
+		return
+	}
+
+	resp := notificationPreferencesResponse{
+		SecurityEmails: prefs.SecurityEmails,
+		LoginAlerts:    prefs.LoginAlerts,
+		Marketing:      prefs.Marketing,
+	}
+	if !prefs.UpdatedAt.IsZero() {
+		resp.UpdatedAt = &prefs.UpdatedAt
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func newLoginResponse(login models.Login) loginResponse {
+	return loginResponse{
+		AppID:     login.AppID,
+		IP:        login.IP,
+		UserAgent: login.UserAgent,
+		Device:    login.Device,
+		AMR:       login.Methods,
+		CreatedAt: login.CreatedAt,
+	}
+}
@@ -0,0 +1,71 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"sso/internal/domain/models"
+)
+
+type batchGetUsersRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+// batchResult is a line of a batch get: a user, or the id of a user that
+// does not exist.
+type batchResult struct {
+	User  *userResponse `json:"user,omitempty"`
+	ID    int64         `json:"id,omitempty"`
+	Error string        `json:"error,omitempty"`
+}
+
+// batchGetUsers streams a JSON line for each of the ids of the body as the
+// users are loaded, chunk by chunk: the users found in the order of the
+// ids, then the ids without a user. Failures once streaming has started
+// abort the response, so that clients do not take a truncated batch for a
+// complete one. Users of other orgs than the one of the admin, if any, are
+// reported missing.
+func (h *handler) batchGetUsers(w http.ResponseWriter, r *http.Request) {
+	var req batchGetUsersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	started := false
+	scope := orgScope(r)
+
+	err := h.management.BatchGetUsers(r.Context(), req.IDs, func(users []models.User, missing []int64) error {
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+
+		for _, user := range users {
+			if scope != 0 && user.OrgID != scope {
+				missing = append(missing, int64(user.ID))
+				continue
+			}
+			resp := newUserResponse(user)
+			if err := enc.Encode(batchResult{User: &resp}); err != nil {
+				return err
+			}
+		}
+		for _, id := range missing {
+			if err := enc.Encode(batchResult{ID: id, Error: errNotFound}); err != nil {
+				return err
+			}
+		}
+
+		return rc.Flush()
+	})
+	if err != nil {
+		if started {
+			panic(http.ErrAbortHandler)
+		}
+		writeManagementError(w, err)
+		return
+	}
+}
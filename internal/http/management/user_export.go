@@ -0,0 +1,170 @@
+package management
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sso/internal/domain/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type exportSession struct {
+	ID         int64     `json:"id"`
+	AppID      int       `json:"app_id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Device     string    `json:"device"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+type exportConsent struct {
+	AppID     int       `json:"app_id"`
+	AppName   string    `json:"app_name"`
+	Scope     string    `json:"scope"`
+	GrantedAt time.Time `json:"granted_at"`
+}
+
+type exportIdentity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportTrustedDevice struct {
+	ID         int64     `json:"id"`
+	IP         string    `json:"ip"`
+	Device     string    `json:"device"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// exportHead is the start of an export up to the audit events, which
+// follow in chunks.
+type exportHead struct {
+	User           userResponse          `json:"user"`
+	Sessions       []exportSession       `json:"sessions"`
+	Consents       []exportConsent       `json:"consents"`
+	Identities     []exportIdentity      `json:"identities"`
+	TrustedDevices []exportTrustedDevice `json:"trusted_devices"`
+	LoginHistory   []loginResponse       `json:"login_history"`
+}
+
+// exportUserData returns the data kept about the user as a single JSON
+// document, written as the service produces it. Failures once writing has
+// started abort the response, so that clients do not take a truncated
+// export for a complete one.
+func (h *handler) exportUserData(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	started := false
+	events := 0
+
+	err = h.management.ExportUserData(r.Context(), adminID(r), userID, func(chunk models.UserDataChunk) error {
+		var buf bytes.Buffer
+		if chunk.User != nil {
+			head, err := json.Marshal(newExportHead(chunk))
+			if err != nil {
+				return err
+			}
+			// the audit events go into the object head ends
+			buf.Write(head[:len(head)-1])
+			buf.WriteString(`,"audit_events":[`)
+		}
+		for _, event := range chunk.AuditEvents {
+			data, err := json.Marshal(newAuditEvent(event))
+			if err != nil {
+				return err
+			}
+			if events > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(data)
+			events++
+		}
+
+		if !started {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Disposition", `attachment; filename="user-`+strconv.FormatInt(userID, 10)+`.json"`)
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+
+		return rc.Flush()
+	})
+	if err != nil {
+		if started {
+			panic(http.ErrAbortHandler)
+		}
+		writeManagementError(w, err)
+		return
+	}
+
+	_, _ = w.Write([]byte("]}\n"))
+}
+
+func newExportHead(chunk models.UserDataChunk) exportHead {
+	head := exportHead{
+		User:           newUserResponse(*chunk.User),
+		Sessions:       make([]exportSession, 0, len(chunk.Sessions)),
+		Consents:       make([]exportConsent, 0, len(chunk.Consents)),
+		Identities:     make([]exportIdentity, 0, len(chunk.Identities)),
+		TrustedDevices: make([]exportTrustedDevice, 0, len(chunk.TrustedDevices)),
+		LoginHistory:   make([]loginResponse, 0, len(chunk.LoginHistory)),
+	}
+	for _, session := range chunk.Sessions {
+		head.Sessions = append(head.Sessions, exportSession{
+			ID:         session.ID,
+			AppID:      session.AppID,
+			IP:         session.IP,
+			UserAgent:  session.UserAgent,
+			Device:     session.Device,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+		})
+	}
+	for _, consent := range chunk.Consents {
+		head.Consents = append(head.Consents, exportConsent{
+			AppID:     consent.AppID,
+			AppName:   consent.AppName,
+			Scope:     strings.Join(consent.Scopes, " "),
+			GrantedAt: consent.GrantedAt,
+		})
+	}
+	for _, identity := range chunk.Identities {
+		head.Identities = append(head.Identities, exportIdentity{
+			Provider:  identity.Provider,
+			Subject:   identity.Subject,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+	for _, device := range chunk.TrustedDevices {
+		head.TrustedDevices = append(head.TrustedDevices, exportTrustedDevice{
+			ID:         device.ID,
+			IP:         device.IP,
+			Device:     device.Device,
+			CreatedAt:  device.CreatedAt,
+			LastUsedAt: device.LastUsedAt,
+			ExpiresAt:  device.ExpiresAt,
+		})
+	}
+	for _, login := range chunk.LoginHistory {
+		head.LoginHistory = append(head.LoginHistory, newLoginResponse(login))
+	}
+
+	return head
+}
@@ -0,0 +1,117 @@
+package management
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/services/management"
+	"strings"
+	"time"
+)
+
+const (
+	// maxImportRecordSize caps the length of a line of an import.
+	maxImportRecordSize = 64 << 10
+	// importRecordTimeout is the time each record of an import gets on top
+	// of the deadlines of the request, so that imports of any size finish.
+	importRecordTimeout = 10 * time.Second
+)
+
+type importRecord struct {
+	Email         string            `json:"email"`
+	PasswordHash  string            `json:"password_hash"`
+	EmailVerified bool              `json:"email_verified"`
+	Username      string            `json:"username"`
+	DisplayName   string            `json:"display_name"`
+	AppMetadata   map[string]string `json:"app_metadata"`
+	UserMetadata  map[string]string `json:"user_metadata"`
+}
+
+type importResult struct {
+	Line             int    `json:"line"`
+	Email            string `json:"email,omitempty"`
+	UserID           int64  `json:"user_id,omitempty"`
+	PasswordReset    bool   `json:"password_reset,omitempty"`
+	Error            string `json:"error,omitempty"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// importUsers imports users from the JSON lines of the body, one user per
+// line, and streams back a result line for each as soon as the user is
+// imported. A failed record does not stop the import.
+func (h *handler) importUsers(w http.ResponseWriter, r *http.Request) {
+	admin := adminID(r)
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 4096), maxImportRecordSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		deadline := time.Now().Add(importRecordTimeout)
+		_ = rc.SetReadDeadline(deadline)
+		_ = rc.SetWriteDeadline(deadline)
+
+		result := h.importUser(r, admin, scanner.Bytes())
+		result.Line = line
+		if err := enc.Encode(result); err != nil {
+			return
+		}
+		_ = rc.Flush()
+	}
+	if err := scanner.Err(); err != nil {
+		_ = enc.Encode(importResult{
+			Line:             line + 1,
+			Error:            errInvalidRequest,
+			ErrorDescription: "unreadable record: " + err.Error(),
+		})
+	}
+}
+
+func (h *handler) importUser(r *http.Request, admin int64, data []byte) importResult {
+	var rec importRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return importResult{Error: errInvalidRequest, ErrorDescription: "malformed record"}
+	}
+
+	userID, reset, err := h.management.ImportUser(r.Context(), admin, models.UserImport{
+		Email:         rec.Email,
+		PassHash:      rec.PasswordHash,
+		EmailVerified: rec.EmailVerified,
+		Username:      rec.Username,
+		DisplayName:   rec.DisplayName,
+		AppMetadata:   rec.AppMetadata,
+		UserMetadata:  rec.UserMetadata,
+		OrgID:         orgScope(r),
+	})
+	if err != nil {
+		result := importResult{Email: rec.Email}
+		switch {
+		case errors.Is(err, management.ErrInvalidUserImport):
+			// the reason follows the error in the message
+			_, reason, _ := strings.Cut(err.Error(), management.ErrInvalidUserImport.Error()+": ")
+			result.Error = errInvalidRequest
+			result.ErrorDescription = reason
+		case errors.Is(err, management.ErrUserExists):
+			result.Error = errUserExists
+		case errors.Is(err, management.ErrUsernameTaken):
+			result.Error = errUsernameTaken
+		default:
+			result.Error = errServerError
+		}
+		return result
+	}
+
+	return importResult{Email: rec.Email, UserID: userID, PasswordReset: reset}
+}
@@ -0,0 +1,80 @@
+package clientinfo
+
+import (
+	"context"
+	"strings"
+)
+
+// Client describes where a request comes from. Fields are empty if the
+// transport does not know them.
+type Client struct {
+	IP        string
+	UserAgent string
+	// DeviceToken is the token of a trusted device the client presented.
+	DeviceToken string
+}
+
+type ctxKey struct{}
+
+func NewContext(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, ctxKey{}, client)
+}
+
+func FromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(ctxKey{}).(Client)
+
+	return client
+}
+
+// browsers and systems are checked in order, so that user agents naming
+// several, like Chrome and Safari in the one of Chrome, match the right one.
+var (
+	browsers = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"grpc-", "gRPC client"},
+		{"curl/", "curl"},
+		{"Go-http-client/", "Go HTTP client"},
+	}
+	systems = []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// Device returns a short description of the device of the user agent for
+// people to recognize their sessions by, such as "Firefox on Linux".
+func Device(userAgent string) string {
+	var browser, system string
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, s := range systems {
+		if strings.Contains(userAgent, s.token) {
+			system = s.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	default:
+		return "Unknown device"
+	}
+}
@@ -0,0 +1,110 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// SMTPSender sends messages through an SMTP server.
+type SMTPSender struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTP(host string, port int, username string, password string, from string) *SMTPSender {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPSender{
+		addr: host + ":" + strconv.Itoa(port),
+		from: from,
+		auth: auth,
+	}
+}
+
+func (s *SMTPSender) Send(_ context.Context, msg Message) error {
+	const op = "email.SMTPSender.Send"
+
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, format(s.from, msg)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// FileSender writes every message to its own file in a directory instead of
+// sending it. It is meant for local development and tests.
+type FileSender struct {
+	dir  string
+	from string
+}
+
+func NewFile(dir string, from string) (*FileSender, error) {
+	const op = "email.NewFile"
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &FileSender{dir: dir, from: from}, nil
+}
+
+// Send writes the message to <unix nanos>_<recipient>.eml.
+func (s *FileSender) Send(_ context.Context, msg Message) error {
+	const op = "email.FileSender.Send"
+
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + "_" + msg.To + ".eml"
+	if err := os.WriteFile(filepath.Join(s.dir, name), format(s.from, msg), 0o640); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// LogSender only logs messages. Their bodies may contain secrets, so it must
+// not be used in production.
+type LogSender struct {
+	log *slog.Logger
+}
+
+func NewLog(log *slog.Logger) *LogSender {
+	return &LogSender{log: log}
+}
+
+func (s *LogSender) Send(_ context.Context, msg Message) error {
+	s.log.Info("email",
+		slog.String("to", msg.To),
+		slog.String("subject", msg.Subject),
+		slog.String("body", msg.Body),
+	)
+
+	return nil
+}
+
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + msg.Subject + "\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+
+	return []byte(b.String())
+}
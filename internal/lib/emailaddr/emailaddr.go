@@ -0,0 +1,46 @@
+package emailaddr
+
+import (
+	"golang.org/x/net/idna"
+	"strings"
+)
+
+// gmailDomains are the domains of Gmail addresses, which ignore dots and
+// everything after a plus in the local part.
+var gmailDomains = map[string]struct{}{
+	"gmail.com":      {},
+	"googlemail.com": {},
+}
+
+// Normalizer derives the keys emails are told apart by, so that addresses
+// reaching the same mailbox belong to one account.
+type Normalizer struct {
+	// FoldGmail makes Gmail addresses that differ only in dots or a plus
+	// suffix of the local part, or in the googlemail.com domain, the same.
+	FoldGmail bool
+}
+
+// Key returns the key of the email: the address in lower case with the
+// domain in its ASCII form, so that internationalized domains match their
+// punycode spelling.
+func (n Normalizer) Key(email string) string {
+	email = strings.TrimSpace(email)
+
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return strings.ToLower(email)
+	}
+	local, domain := strings.ToLower(email[:at]), strings.ToLower(email[at+1:])
+
+	if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+		domain = ascii
+	}
+
+	if _, ok := gmailDomains[domain]; ok && n.FoldGmail {
+		local, _, _ = strings.Cut(local, "+")
+		local = strings.ReplaceAll(local, ".", "")
+		domain = "gmail.com"
+	}
+
+	return local + "@" + domain
+}
@@ -0,0 +1,69 @@
+package idp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var ErrDiscovery = errors.New("openid provider discovery failed")
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Discover returns the provider with the issuer, reading its endpoints from
+// the discovery document (OpenID Connect Discovery 1.0, section 4). The
+// endpoints of cfg are ignored.
+func Discover(ctx context.Context, issuer string, cfg Config) (*Provider, error) {
+	const op = "idp.Discover"
+
+	issuer = strings.TrimSuffix(issuer, "/")
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", op, ErrDiscovery, err.Error())
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", op, ErrDiscovery, err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %w: unexpected status %d", op, ErrDiscovery, resp.StatusCode)
+	}
+
+	var doc discoveryDocument
+	if err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", op, ErrDiscovery, err.Error())
+	}
+
+	// the document must be about the issuer it was fetched from (section 4.3)
+	if strings.TrimSuffix(doc.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("%s: %w: issuer mismatch %q", op, ErrDiscovery, doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("%s: %w: missing endpoints", op, ErrDiscovery)
+	}
+
+	cfg.Issuers = []string{doc.Issuer}
+	cfg.AuthURL = doc.AuthorizationEndpoint
+	cfg.TokenURL = doc.TokenEndpoint
+	cfg.JWKSURL = doc.JWKSURI
+
+	return New(cfg), nil
+}
@@ -0,0 +1,32 @@
+package idp
+
+// Google endpoints, see https://accounts.google.com/.well-known/openid-configuration.
+const (
+	googleIssuer   = "https://accounts.google.com"
+	googleAuthURL  = "https://accounts.google.com/o/oauth2/v2/auth"
+	googleTokenURL = "https://oauth2.googleapis.com/token"
+	googleJWKSURL  = "https://www.googleapis.com/oauth2/v3/certs"
+)
+
+// NewGoogle returns a provider logging users in with Google. Endpoints left
+// empty in cfg default to Google's.
+func NewGoogle(cfg Config) *Provider {
+	if len(cfg.Issuers) == 0 {
+		// Google ID tokens may omit the scheme of the issuer
+		cfg.Issuers = []string{googleIssuer, "accounts.google.com"}
+	}
+	if cfg.AuthURL == "" {
+		cfg.AuthURL = googleAuthURL
+	}
+	if cfg.TokenURL == "" {
+		cfg.TokenURL = googleTokenURL
+	}
+	if cfg.JWKSURL == "" {
+		cfg.JWKSURL = googleJWKSURL
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"email", "profile"}
+	}
+
+	return New(cfg)
+}
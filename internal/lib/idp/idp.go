@@ -0,0 +1,224 @@
+package idp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrExchange       = errors.New("authorization code exchange failed")
+	ErrInvalidIDToken = errors.New("invalid id token")
+)
+
+// Identity is a user as asserted by the ID token of an upstream provider.
+type Identity struct {
+	// Subject identifies the user at the provider.
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	// Claims are all claims of the ID token.
+	Claims map[string]any
+}
+
+// Config describes an OpenID Connect provider and the client registered
+// with it.
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback the provider sends the user back to.
+	RedirectURL string
+	// Issuers are the accepted iss claims of ID tokens. The first one is
+	// the issuer URL of the provider.
+	Issuers  []string
+	AuthURL  string
+	TokenURL string
+	JWKSURL  string
+	// Scopes requested besides openid.
+	Scopes []string
+	// Claims name the ID token claims the identity is read from. Empty
+	// names default to the standard claims.
+	Claims ClaimNames
+}
+
+type ClaimNames struct {
+	Subject       string
+	Email         string
+	EmailVerified string
+	Name          string
+}
+
+// Provider logs users in with an OpenID Connect provider using the
+// authorization code flow with PKCE.
+type Provider struct {
+	cfg    Config
+	keys   *keySet
+	client *http.Client
+}
+
+func New(cfg Config) *Provider {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	cfg.Claims.Subject = defaultClaim(cfg.Claims.Subject, "sub")
+	cfg.Claims.Email = defaultClaim(cfg.Claims.Email, "email")
+	cfg.Claims.EmailVerified = defaultClaim(cfg.Claims.EmailVerified, "email_verified")
+	cfg.Claims.Name = defaultClaim(cfg.Claims.Name, "name")
+
+	return &Provider{
+		cfg:    cfg,
+		keys:   newKeySet(cfg.JWKSURL, client),
+		client: client,
+	}
+}
+
+// AuthCodeURL returns the URL of the provider's login page. The state and
+// nonce are checked when the user returns, verifier is the PKCE code
+// verifier the code is later exchanged with.
+func (p *Provider) AuthCodeURL(state string, nonce string, verifier string) string {
+	challenge := sha256.Sum256([]byte(verifier))
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, p.cfg.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(p.cfg.AuthURL, "?") {
+		sep = "&"
+	}
+
+	return p.cfg.AuthURL + sep + q.Encode()
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange redeems the authorization code and returns the identity of the
+// verified ID token, which must carry the nonce.
+func (p *Provider) Exchange(ctx context.Context, code string, verifier string, nonce string) (Identity, error) {
+	const op = "idp.Provider.Exchange"
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"client_secret": {p.cfg.ClientSecret},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	if err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return Identity{}, fmt.Errorf("%s: %w: %s", op, ErrExchange, err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("%s: %w: %s %s", op, ErrExchange, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return Identity{}, fmt.Errorf("%s: %w: no id_token in response", op, ErrExchange)
+	}
+
+	identity, err := p.verify(ctx, body.IDToken, nonce)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return identity, nil
+}
+
+// verify checks the signature, issuer, audience, expiry and nonce of the ID
+// token (OpenID Connect Core, section 3.1.3.7).
+func (p *Provider) verify(ctx context.Context, idToken string, nonce string) (Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.keys.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %s", ErrInvalidIDToken, err.Error())
+	}
+
+	iss, _ := claims["iss"].(string)
+	if !slices.Contains(p.cfg.Issuers, iss) {
+		return Identity{}, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, iss)
+	}
+
+	if claimed, _ := claims["nonce"].(string); claimed != nonce {
+		return Identity{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	sub, _ := claims[p.cfg.Claims.Subject].(string)
+	if sub == "" {
+		return Identity{}, fmt.Errorf("%w: missing %s claim", ErrInvalidIDToken, p.cfg.Claims.Subject)
+	}
+
+	email, _ := claims[p.cfg.Claims.Email].(string)
+	name, _ := claims[p.cfg.Claims.Name].(string)
+
+	return Identity{
+		Subject:       sub,
+		Email:         email,
+		EmailVerified: boolClaim(claims[p.cfg.Claims.EmailVerified]),
+		Name:          name,
+		Claims:        claims,
+	}, nil
+}
+
+// boolClaim reads a boolean claim some providers send as a string.
+func boolClaim(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
+
+func defaultClaim(name string, standard string) string {
+	if name == "" {
+		return standard
+	}
+
+	return name
+}
@@ -1,23 +1,56 @@
 package jwt
 
 import (
+	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v5"
-	"sso/internal/domain/models"
-	"time"
 )
 
-func NewToken(user models.User, app models.App, duration time.Duration) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
-	claims["uid"] = user.ID
-	claims["email"] = user.Email
-	claims["exp"] = time.Now().Add(duration).Unix()
-	claims["app_id"] = app.ID
+var ErrInvalidToken = errors.New("invalid jwt")
 
-	signedString, err := token.SignedString([]byte(app.Secret))
+// Codec encodes claims as HS256 signed JWTs.
+type Codec struct{}
+
+func (Codec) Encode(claims map[string]any, secret string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims(claims))
+
+	signedString, err := token.SignedString([]byte(secret))
 	if err != nil {
 		return "", err
 	}
 
 	return signedString, nil
 }
+
+// Decode verifies the token signature and returns its claims.
+// Claims are not validated.
+func (Codec) Decode(tokenString string, secret string) (map[string]any, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithoutClaimsValidation(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err.Error())
+	}
+
+	return claims, nil
+}
+
+// AppID returns the app_id claim of the token without verifying its signature.
+// It must only be used to find the app whose secret the token is verified with.
+func (Codec) AppID(tokenString string) (int, error) {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidToken, err.Error())
+	}
+
+	appID, ok := claims["app_id"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("%w: missing app_id claim", ErrInvalidToken)
+	}
+
+	return int(appID), nil
+}
@@ -0,0 +1,157 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	"net"
+	"net/url"
+	"time"
+)
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Config describes an LDAP directory and how users are found in it.
+type Config struct {
+	// URL of the server, ldap:// or ldaps://.
+	URL string
+	// StartTLS upgrades ldap:// connections to TLS.
+	StartTLS bool
+	// BindDN and BindPassword authenticate the searches for users. The
+	// searches are anonymous if BindDN is empty.
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserFilter finds the entry of a user, %s is replaced with the
+	// username. Defaults to (mail=%s).
+	UserFilter string
+	// EmailAttribute holds the email of a user. Defaults to mail.
+	EmailAttribute string
+	// GroupAttribute lists the DNs of the groups of a user. Defaults to
+	// memberOf.
+	GroupAttribute string
+	Timeout        time.Duration
+}
+
+// Entry is a user authenticated by the directory.
+type Entry struct {
+	DN     string
+	Email  string
+	Groups []string
+}
+
+// Directory authenticates users by binding to an LDAP directory, e.g. Active
+// Directory, with their credentials.
+type Directory struct {
+	cfg Config
+}
+
+func New(cfg Config) *Directory {
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = "(mail=%s)"
+	}
+	if cfg.EmailAttribute == "" {
+		cfg.EmailAttribute = "mail"
+	}
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = "memberOf"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	return &Directory{cfg: cfg}
+}
+
+// Authenticate finds the entry of the user and binds as it with the
+// password. It fails with ErrInvalidCredentials unless exactly one entry
+// matches and the password is correct.
+func (d *Directory) Authenticate(ctx context.Context, username string, password string) (Entry, error) {
+	const op = "ldap.Directory.Authenticate"
+
+	// a bind with an empty password is an unauthenticated bind, which
+	// servers accept for any DN (RFC 4513, section 5.1.2)
+	if username == "" || password == "" {
+		return Entry{}, ErrInvalidCredentials
+	}
+
+	conn, err := d.dial(ctx)
+	if err != nil {
+		return Entry{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer conn.Close()
+
+	// closing the connection aborts the pending request
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if d.cfg.BindDN != "" {
+		if err = conn.Bind(d.cfg.BindDN, d.cfg.BindPassword); err != nil {
+			return Entry{}, fmt.Errorf("%s: service bind: %w", op, err)
+		}
+	}
+
+	res, err := conn.Search(ldapv3.NewSearchRequest(
+		d.cfg.BaseDN,
+		ldapv3.ScopeWholeSubtree,
+		ldapv3.NeverDerefAliases,
+		2, // more than one entry is ambiguous
+		int(d.cfg.Timeout.Seconds()),
+		false,
+		fmt.Sprintf(d.cfg.UserFilter, ldapv3.EscapeFilter(username)),
+		[]string{d.cfg.EmailAttribute, d.cfg.GroupAttribute},
+		nil,
+	))
+	if err != nil {
+		if ldapv3.IsErrorWithCode(err, ldapv3.LDAPResultSizeLimitExceeded) {
+			return Entry{}, fmt.Errorf("%s: %w: ambiguous username", op, ErrInvalidCredentials)
+		}
+		return Entry{}, fmt.Errorf("%s: search: %w", op, err)
+	}
+	if len(res.Entries) != 1 {
+		return Entry{}, fmt.Errorf("%s: %w: %d entries found", op, ErrInvalidCredentials, len(res.Entries))
+	}
+
+	entry := res.Entries[0]
+	if err = conn.Bind(entry.DN, password); err != nil {
+		if ldapv3.IsErrorWithCode(err, ldapv3.LDAPResultInvalidCredentials) {
+			return Entry{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		return Entry{}, fmt.Errorf("%s: user bind: %w", op, err)
+	}
+
+	return Entry{
+		DN:     entry.DN,
+		Email:  entry.GetAttributeValue(d.cfg.EmailAttribute),
+		Groups: entry.GetAttributeValues(d.cfg.GroupAttribute),
+	}, nil
+}
+
+func (d *Directory) dial(ctx context.Context) (*ldapv3.Conn, error) {
+	u, err := url.Parse(d.cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: d.cfg.Timeout}
+	if deadline, ok := ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+
+	conn, err := ldapv3.DialURL(d.cfg.URL, ldapv3.DialWithDialer(dialer))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(d.cfg.Timeout)
+
+	if d.cfg.StartTLS && u.Scheme == "ldap" {
+		if err = conn.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
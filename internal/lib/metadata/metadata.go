@@ -0,0 +1,55 @@
+package metadata
+
+import (
+	"fmt"
+)
+
+// Limits of the metadata of a user, per kind.
+const (
+	MaxKeys        = 32
+	MaxKeyLength   = 64
+	MaxValueLength = 512
+	// MaxSize caps the total length of the keys and values, so that the
+	// metadata stays small enough to carry in tokens.
+	MaxSize = 4096
+)
+
+// Validate reports why the metadata is not acceptable, if it is not. Keys
+// are ASCII letters, digits, dashes and underscores.
+func Validate(md map[string]string) error {
+	if len(md) > MaxKeys {
+		return fmt.Errorf("more than %d keys", MaxKeys)
+	}
+
+	size := 0
+	for key, value := range md {
+		if !validKey(key) {
+			return fmt.Errorf("invalid key %q", key)
+		}
+		if len(value) > MaxValueLength {
+			return fmt.Errorf("value of %q is longer than %d bytes", key, MaxValueLength)
+		}
+		size += len(key) + len(value)
+	}
+	if size > MaxSize {
+		return fmt.Errorf("larger than %d bytes", MaxSize)
+	}
+
+	return nil
+}
+
+func validKey(key string) bool {
+	if key == "" || len(key) > MaxKeyLength {
+		return false
+	}
+
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
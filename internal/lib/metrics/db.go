@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DBStatser reports the statistics of a pool of database connections.
+type DBStatser interface {
+	Stats() sql.DBStats
+}
+
+// DBStatsFunc reports the statistics of a pool by calling itself.
+type DBStatsFunc func() sql.DBStats
+
+func (f DBStatsFunc) Stats() sql.DBStats {
+	return f()
+}
+
+type dbStatsCollector struct {
+	db DBStatser
+
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxIdleTimeClosed *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+// NewDBStatsCollector exports the connection pool statistics of the db,
+// labeled with its name.
+func NewDBStatsCollector(db DBStatser, name string) prometheus.Collector {
+	labels := prometheus.Labels{"db": name}
+	desc := func(metric string, help string) *prometheus.Desc {
+		return prometheus.NewDesc("sso_storage_"+metric, help, nil, labels)
+	}
+
+	return &dbStatsCollector{
+		db:                db,
+		maxOpen:           desc("max_open_connections", "Maximum number of open connections to the database."),
+		open:              desc("open_connections", "Number of established connections, in use and idle."),
+		inUse:             desc("in_use_connections", "Number of connections in use."),
+		idle:              desc("idle_connections", "Number of idle connections."),
+		waitCount:         desc("wait_count_total", "Total number of waits for a connection."),
+		waitDuration:      desc("wait_duration_seconds_total", "Total time spent waiting for a connection."),
+		maxIdleClosed:     desc("max_idle_closed_total", "Total number of connections closed due to the idle limit."),
+		maxIdleTimeClosed: desc("max_idle_time_closed_total", "Total number of connections closed due to the idle time limit."),
+		maxLifetimeClosed: desc("max_lifetime_closed_total", "Total number of connections closed due to the lifetime limit."),
+	}
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxIdleTimeClosed
+	ch <- c.maxLifetimeClosed
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxIdleTimeClosed, prometheus.CounterValue, float64(stats.MaxIdleTimeClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed))
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StorageRetries counts the retries of storage calls failing with transient
+// errors, by storage method.
+type StorageRetries struct {
+	retries   *prometheus.CounterVec
+	exhausted *prometheus.CounterVec
+}
+
+func NewStorageRetries() *StorageRetries {
+	return &StorageRetries{
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sso_storage_retries_total",
+			Help: "Total number of retried storage calls.",
+		}, []string{"method"}),
+		exhausted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sso_storage_retry_budget_exhausted_total",
+			Help: "Total number of storage calls not retried because the retry budget ran out.",
+		}, []string{"method"}),
+	}
+}
+
+func (r *StorageRetries) Retried(method string) {
+	r.retries.WithLabelValues(method).Inc()
+}
+
+func (r *StorageRetries) BudgetExhausted(method string) {
+	r.exhausted.WithLabelValues(method).Inc()
+}
+
+func (r *StorageRetries) Describe(ch chan<- *prometheus.Desc) {
+	r.retries.Describe(ch)
+	r.exhausted.Describe(ch)
+}
+
+func (r *StorageRetries) Collect(ch chan<- prometheus.Metric) {
+	r.retries.Collect(ch)
+	r.exhausted.Collect(ch)
+}
@@ -0,0 +1,117 @@
+package passhash
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// Limits of the argon2 parameters, so that a hash can not make checking a
+// password take all the memory or CPU.
+const (
+	maxArgon2Memory  = 256 << 10 // KiB
+	maxArgon2Time    = 16
+	maxArgon2Threads = 16
+)
+
+var (
+	ErrMismatch    = errors.New("password does not match the hash")
+	ErrUnsupported = errors.New("unsupported password hash")
+)
+
+// Compare checks the password against the hash. Besides the bcrypt hashes
+// the service creates, it understands argon2i and argon2id hashes in the
+// PHC string format, as imported from other systems:
+//
+//	$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>
+//
+// It returns ErrMismatch if the password does not match.
+func Compare(hash string, password string) error {
+	if strings.HasPrefix(hash, "$argon2") {
+		return compareArgon2(hash, password)
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+
+	return err
+}
+
+// Valid reports whether Compare can check passwords against the hash.
+func Valid(hash string) bool {
+	if strings.HasPrefix(hash, "$argon2") {
+		_, err := parseArgon2(hash)
+		return err == nil
+	}
+
+	_, err := bcrypt.Cost([]byte(hash))
+	return err == nil
+}
+
+type argon2Hash struct {
+	variant string
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func compareArgon2(hash string, password string) error {
+	h, err := parseArgon2(hash)
+	if err != nil {
+		return err
+	}
+
+	var key []byte
+	if h.variant == "argon2id" {
+		key = argon2.IDKey([]byte(password), h.salt, h.time, h.memory, h.threads, uint32(len(h.key)))
+	} else {
+		key = argon2.Key([]byte(password), h.salt, h.time, h.memory, h.threads, uint32(len(h.key)))
+	}
+
+	if subtle.ConstantTimeCompare(key, h.key) != 1 {
+		return ErrMismatch
+	}
+
+	return nil
+}
+
+func parseArgon2(hash string) (argon2Hash, error) {
+	// "", variant, version, params, salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return argon2Hash{}, ErrUnsupported
+	}
+
+	h := argon2Hash{variant: parts[1]}
+	if h.variant != "argon2id" && h.variant != "argon2i" {
+		return argon2Hash{}, ErrUnsupported
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2Hash{}, ErrUnsupported
+	}
+
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.time, &h.threads)
+	if err != nil || h.memory == 0 || h.time == 0 || h.threads == 0 ||
+		h.memory > maxArgon2Memory || h.time > maxArgon2Time || h.threads > maxArgon2Threads {
+		return argon2Hash{}, ErrUnsupported
+	}
+
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return argon2Hash{}, ErrUnsupported
+	}
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(h.key) == 0 {
+		return argon2Hash{}, ErrUnsupported
+	}
+
+	return h, nil
+}
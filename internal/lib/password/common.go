@@ -0,0 +1,19 @@
+package password
+
+// commonPasswords are among the most used passwords found in breaches and
+// are always banned. Operators add longer lists with Config.BannedFile.
+var commonPasswords = []string{
+	"123456", "123456789", "12345678", "1234567890", "12345", "1234567",
+	"111111", "000000", "123123", "654321", "666666", "121212", "112233",
+	"123321", "7777777", "88888888", "11111111", "987654321", "1q2w3e4r",
+	"1q2w3e4r5t", "1qaz2wsx", "qwerty", "qwerty123", "qwertyuiop", "qwe123",
+	"asdfgh", "asdfghjkl", "zxcvbnm", "abc123", "abcd1234", "a1b2c3d4",
+	"password", "password1", "password123", "passw0rd", "p@ssw0rd",
+	"iloveyou", "princess", "sunshine", "monkey", "dragon", "football",
+	"baseball", "superman", "batman", "shadow", "master", "letmein",
+	"welcome", "welcome1", "admin", "admin123", "administrator", "login",
+	"starwars", "trustno1", "whatever", "freedom", "hello123", "charlie",
+	"michael", "jennifer", "jordan23", "mustang", "access", "secret",
+	"changeme", "default", "test1234", "testtest", "computer", "internet",
+	"football1", "q1w2e3r4", "zaq12wsx", "1234qwer", "qazwsx", "aa123456",
+}
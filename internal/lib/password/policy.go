@@ -0,0 +1,144 @@
+package password
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bcryptMaxBytes is the length bcrypt silently truncates passwords to.
+const bcryptMaxBytes = 72
+
+// Codes of the rules a password can violate.
+const (
+	ViolationTooShort      = "too_short"
+	ViolationTooLong       = "too_long"
+	ViolationMissingUpper  = "missing_upper"
+	ViolationMissingLower  = "missing_lower"
+	ViolationMissingDigit  = "missing_digit"
+	ViolationMissingSymbol = "missing_symbol"
+	ViolationBanned        = "banned"
+	// ViolationBreached is not checked by the policy but by the breach check
+	// of the auth service.
+	ViolationBreached = "breached"
+	// ViolationReused is not checked by the policy either: users an admin
+	// required to set a new password can not keep the current one.
+	ViolationReused = "reused"
+)
+
+// Violation is a rule of the policy a password does not follow.
+type Violation struct {
+	Code    string
+	Message string
+}
+
+// Config describes a password policy. Lengths count characters, not bytes.
+type Config struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// BannedFile lists passwords that are not allowed, one per line, in
+	// addition to the built-in list of the most common ones.
+	BannedFile string
+}
+
+// Policy checks new passwords against configurable rules.
+type Policy struct {
+	cfg    Config
+	banned map[string]struct{}
+}
+
+func New(cfg Config) (*Policy, error) {
+	banned := make(map[string]struct{}, len(commonPasswords))
+	for _, password := range commonPasswords {
+		banned[password] = struct{}{}
+	}
+
+	if cfg.BannedFile != "" {
+		if err := readBanned(cfg.BannedFile, banned); err != nil {
+			return nil, fmt.Errorf("read banned passwords: %w", err)
+		}
+	}
+
+	return &Policy{cfg: cfg, banned: banned}, nil
+}
+
+// Check returns the rules the password violates, none if it is acceptable.
+func (p *Policy) Check(password string) []Violation {
+	var violations []Violation
+
+	length := utf8.RuneCountInString(password)
+	if length < p.cfg.MinLength {
+		violations = append(violations, Violation{
+			Code:    ViolationTooShort,
+			Message: fmt.Sprintf("must be at least %d characters long", p.cfg.MinLength),
+		})
+	}
+	if p.cfg.MaxLength > 0 && length > p.cfg.MaxLength {
+		violations = append(violations, Violation{
+			Code:    ViolationTooLong,
+			Message: fmt.Sprintf("must be at most %d characters long", p.cfg.MaxLength),
+		})
+	} else if len(password) > bcryptMaxBytes {
+		violations = append(violations, Violation{
+			Code:    ViolationTooLong,
+			Message: fmt.Sprintf("must be at most %d bytes long", bcryptMaxBytes),
+		})
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	if p.cfg.RequireUpper && !upper {
+		violations = append(violations, Violation{Code: ViolationMissingUpper, Message: "must contain an uppercase letter"})
+	}
+	if p.cfg.RequireLower && !lower {
+		violations = append(violations, Violation{Code: ViolationMissingLower, Message: "must contain a lowercase letter"})
+	}
+	if p.cfg.RequireDigit && !digit {
+		violations = append(violations, Violation{Code: ViolationMissingDigit, Message: "must contain a digit"})
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		violations = append(violations, Violation{Code: ViolationMissingSymbol, Message: "must contain a symbol"})
+	}
+
+	if _, ok := p.banned[strings.ToLower(password)]; ok {
+		violations = append(violations, Violation{Code: ViolationBanned, Message: "is too common"})
+	}
+
+	return violations
+}
+
+func readBanned(path string, banned map[string]struct{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			banned[strings.ToLower(line)] = struct{}{}
+		}
+	}
+
+	return scanner.Err()
+}
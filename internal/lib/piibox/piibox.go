@@ -0,0 +1,112 @@
+package piibox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// prefix marks sealed values, so that values stored before encryption was
+// turned on are told apart and read as they are.
+const prefix = "pii1:"
+
+// Box encrypts personal data stored at rest with AES-256-GCM, using keys
+// derived from a configured passphrase. Unlike secretbox, sealing is
+// deterministic: the nonce is a MAC of the plaintext, so that equal values
+// seal to equal ciphertexts, which the database can still compare and
+// keep unique. This leaks which values are equal, and nothing else.
+type Box struct {
+	aead     cipher.AEAD
+	nonceKey []byte
+	indexKey []byte
+}
+
+func New(passphrase string) (*Box, error) {
+	if passphrase == "" {
+		return nil, errors.New("piibox: empty passphrase")
+	}
+
+	master := sha256.Sum256([]byte(passphrase))
+
+	block, err := aes.NewCipher(deriveKey(master[:], "encryption"))
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Box{
+		aead:     aead,
+		nonceKey: deriveKey(master[:], "nonce"),
+		indexKey: deriveKey(master[:], "index"),
+	}, nil
+}
+
+// deriveKey returns a key for the purpose, so that no key serves two.
+func deriveKey(master []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte("sso piibox " + purpose))
+
+	return mac.Sum(nil)
+}
+
+// Sealed reports whether the value was returned by Seal.
+func Sealed(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Seal encrypts the plaintext and returns it base64 encoded, prefixed with
+// the nonce and a marker. Empty and already sealed values are returned as
+// they are.
+func (b *Box) Seal(plaintext string) string {
+	if plaintext == "" || Sealed(plaintext) {
+		return plaintext
+	}
+
+	mac := hmac.New(sha256.New, b.nonceKey)
+	mac.Write([]byte(plaintext))
+	nonce := mac.Sum(nil)[:b.aead.NonceSize()]
+
+	return prefix + base64.RawStdEncoding.EncodeToString(b.aead.Seal(nonce, nonce, []byte(plaintext), nil))
+}
+
+// Open decrypts a value returned by Seal. Values without the marker were
+// stored in plaintext and are returned as they are.
+func (b *Box) Open(value string) (string, error) {
+	if !Sealed(value) {
+		return value, nil
+	}
+
+	data, err := base64.RawStdEncoding.DecodeString(value[len(prefix):])
+	if err != nil || len(data) < b.aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, ciphertext := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	return string(plaintext), nil
+}
+
+// Index returns the blind index of the value: a MAC which finds the value
+// among others without revealing it, hex encoded.
+func (b *Box) Index(value string) string {
+	mac := hmac.New(sha256.New, b.indexKey)
+	mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,178 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// DefaultModel is the Casbin model used unless deployments configure their
+// own. Its rules name the app, "*" for every app, the permission, which may
+// end with a "*" wildcard, and a condition on the subject and the resource
+// of the request, as
+//
+//	p, r.sub.ID == r.obj.OwnerID, *, documents:edit
+//
+// for owners editing their own documents, or "true" to grant the permission
+// to everyone.
+const DefaultModel = `
+[request_definition]
+r = sub, app, obj, act
+
+[policy_definition]
+p = sub_rule, app, act
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = (p.app == "*" || p.app == r.app) && keyMatch(r.act, p.act) && eval(p.sub_rule)
+`
+
+// Casbin is an Engine evaluating the rules of the storage with a Casbin
+// model. Models take requests as the Subject, the app id as a string, the
+// models.Resource and the permission.
+type Casbin struct {
+	mu       sync.RWMutex
+	enforcer casbin.IEnforcerContext
+	// empty is set while there are no rules, when Casbin fails matchers
+	// with eval instead of denying
+	empty bool
+}
+
+// NewCasbin loads the model of the file, DefaultModel if path is empty, and
+// the rules of the provider.
+func NewCasbin(ctx context.Context, path string, rules RuleProvider) (*Casbin, error) {
+	const op = "policy.NewCasbin"
+
+	text := DefaultModel
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		text = string(data)
+	}
+
+	m, err := model.NewModelFromString(text)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	enforcer, err := casbin.NewContextEnforcer(m, &adapter{rules: rules})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	// rules are only changed in the storage, see adapter
+	enforcer.EnableAutoSave(false)
+
+	c := &Casbin{enforcer: enforcer}
+	if err = c.Reload(ctx); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Casbin) Allow(ctx context.Context, req Request) (bool, error) {
+	const op = "policy.Casbin.Allow"
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.empty {
+		return false, nil
+	}
+
+	allowed, err := c.enforcer.Enforce(req.Subject, strconv.Itoa(req.AppID), req.Resource, req.Permission)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return allowed, nil
+}
+
+func (c *Casbin) Reload(ctx context.Context) error {
+	const op = "policy.Casbin.Reload"
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.enforcer.LoadPolicyCtx(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rules, err := c.enforcer.GetPolicy()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	c.empty = len(rules) == 0
+
+	return nil
+}
+
+// errReadOnly is returned by the adapter for changes, which go through the
+// admin API to the storage instead.
+var errReadOnly = errors.New("policy rules are read-only to casbin")
+
+// adapter loads the rules of the storage into Casbin models.
+type adapter struct {
+	rules RuleProvider
+}
+
+func (a *adapter) LoadPolicy(m model.Model) error {
+	return a.LoadPolicyCtx(context.Background(), m)
+}
+
+func (a *adapter) LoadPolicyCtx(ctx context.Context, m model.Model) error {
+	rules, err := a.rules.PolicyRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if err = persist.LoadPolicyArray(append([]string{rule.Type}, rule.Values...), m); err != nil {
+			return fmt.Errorf("rule %d: %w", rule.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (a *adapter) SavePolicy(model.Model) error {
+	return errReadOnly
+}
+
+func (a *adapter) SavePolicyCtx(context.Context, model.Model) error {
+	return errReadOnly
+}
+
+func (a *adapter) AddPolicy(string, string, []string) error {
+	return errReadOnly
+}
+
+func (a *adapter) AddPolicyCtx(context.Context, string, string, []string) error {
+	return errReadOnly
+}
+
+func (a *adapter) RemovePolicy(string, string, []string) error {
+	return errReadOnly
+}
+
+func (a *adapter) RemovePolicyCtx(context.Context, string, string, []string) error {
+	return errReadOnly
+}
+
+func (a *adapter) RemoveFilteredPolicy(string, string, int, ...string) error {
+	return errReadOnly
+}
+
+func (a *adapter) RemoveFilteredPolicyCtx(context.Context, string, string, int, ...string) error {
+	return errReadOnly
+}
@@ -0,0 +1,113 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+
+	"github.com/casbin/govaluate"
+)
+
+// MaxConditionLength caps the length of condition expressions.
+const MaxConditionLength = 512
+
+// Condition is an expression a permission of a role is granted under, such
+// as `attrs.org == user.org && resource.owner_id == user.id`. It sees:
+//
+//   - user.id and the app metadata of the user as user.<key>
+//   - app.id
+//   - resource.type, resource.id and resource.owner_id
+//   - the attributes of the check as attrs.<name>
+//
+// Conditions referring to values a check does not have do not hold.
+type Condition struct {
+	expr *govaluate.EvaluableExpression
+}
+
+// conditionRoots are the variables conditions may refer to.
+var conditionRoots = map[string]bool{
+	"user":     true,
+	"app":      true,
+	"resource": true,
+	"attrs":    true,
+}
+
+// ParseCondition parses the expression, failing if it is not valid or
+// refers to unknown variables.
+func ParseCondition(expression string) (*Condition, error) {
+	if len(expression) > MaxConditionLength {
+		return nil, fmt.Errorf("condition is longer than %d characters", MaxConditionLength)
+	}
+
+	expr, err := govaluate.NewEvaluableExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, token := range expr.Tokens() {
+		var root string
+		switch token.Kind {
+		case govaluate.VARIABLE:
+			root = token.Value.(string)
+		case govaluate.ACCESSOR:
+			root = token.Value.([]string)[0]
+		default:
+			continue
+		}
+		if !conditionRoots[root] {
+			return nil, fmt.Errorf("unknown variable %q", root)
+		}
+	}
+
+	return &Condition{expr: expr}, nil
+}
+
+// ConditionInput is what conditions are evaluated on.
+type ConditionInput struct {
+	User       models.User
+	AppID      int
+	Resource   models.Resource
+	Attributes map[string]string
+}
+
+// Eval tells whether the condition holds for the input.
+func (c *Condition) Eval(in ConditionInput) (bool, error) {
+	user := make(map[string]any, len(in.User.AppMetadata)+1)
+	for key, value := range in.User.AppMetadata {
+		user[key] = value
+	}
+	user["id"] = float64(in.User.ID)
+
+	resource := map[string]any{}
+	if in.Resource.Type != "" {
+		resource["type"] = in.Resource.Type
+	}
+	if in.Resource.ID != "" {
+		resource["id"] = in.Resource.ID
+	}
+	if in.Resource.OwnerID != 0 {
+		resource["owner_id"] = float64(in.Resource.OwnerID)
+	}
+
+	attrs := make(map[string]any, len(in.Attributes))
+	for name, value := range in.Attributes {
+		attrs[name] = value
+	}
+
+	result, err := c.expr.Evaluate(map[string]any{
+		"user":     user,
+		"app":      map[string]any{"id": float64(in.AppID)},
+		"resource": resource,
+		"attrs":    attrs,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	holds, ok := result.(bool)
+	if !ok {
+		return false, errors.New("condition does not evaluate to a boolean")
+	}
+
+	return holds, nil
+}
@@ -0,0 +1,37 @@
+// Package policy decides permissions by rules richer than role lists, such
+// as owners editing their own resources.
+package policy
+
+import (
+	"context"
+	"sso/internal/domain/models"
+)
+
+// Subject is the user a permission is checked for.
+type Subject struct {
+	ID int64
+	// Roles are the names of the roles the user has within the app.
+	Roles []string
+}
+
+// Request asks whether the subject holds the permission within the app,
+// on the resource if it is set.
+type Request struct {
+	Subject    Subject
+	AppID      int
+	Resource   models.Resource
+	Permission string
+}
+
+// Engine decides requests by the policy rules it loaded. Engines other than
+// Casbin, such as OPA, plug in by implementing it.
+type Engine interface {
+	Allow(ctx context.Context, req Request) (bool, error)
+	// Reload loads the rules again once they changed.
+	Reload(ctx context.Context) error
+}
+
+// RuleProvider provides the stored policy rules.
+type RuleProvider interface {
+	PolicyRules(ctx context.Context) ([]models.PolicyRule, error)
+}
@@ -0,0 +1,92 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/text/language"
+	"net/url"
+	"sso/internal/domain/models"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits of the profile fields of a user.
+const (
+	// MaxNameLength caps names and display names, in runes.
+	MaxNameLength      = 64
+	MaxAvatarURLLength = 2048
+)
+
+// Normalize normalizes the fields of the profile with the functions below
+// and reports the first that is not acceptable.
+func Normalize(p models.UserProfile) (models.UserProfile, error) {
+	var err error
+	if p.FirstName, err = NormalizeName("first name", p.FirstName); err != nil {
+		return p, err
+	}
+	if p.LastName, err = NormalizeName("last name", p.LastName); err != nil {
+		return p, err
+	}
+	if p.DisplayName, err = NormalizeName("display name", p.DisplayName); err != nil {
+		return p, err
+	}
+	if p.Locale, err = NormalizeLocale(p.Locale); err != nil {
+		return p, err
+	}
+	if p.AvatarURL, err = NormalizeAvatarURL(p.AvatarURL); err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// NormalizeName trims the name and reports why it is not acceptable, if it
+// is not. The field names the name in errors.
+func NormalizeName(field string, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		return "", fmt.Errorf("%s is longer than %d characters", field, MaxNameLength)
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("%s contains control characters", field)
+	}
+
+	return name, nil
+}
+
+// NormalizeLocale returns the canonical form of the BCP 47 language tag,
+// such as "en-US" for "en_us". An empty locale stays empty.
+func NormalizeLocale(locale string) (string, error) {
+	locale = strings.TrimSpace(locale)
+	if locale == "" {
+		return "", nil
+	}
+
+	tag, err := language.Parse(strings.ReplaceAll(locale, "_", "-"))
+	if err != nil {
+		return "", errors.New("invalid locale")
+	}
+
+	return tag.String(), nil
+}
+
+// NormalizeAvatarURL checks that the avatar URL is an absolute https URL,
+// so that pages showing it do not load mixed content. An empty URL stays
+// empty.
+func NormalizeAvatarURL(avatarURL string) (string, error) {
+	avatarURL = strings.TrimSpace(avatarURL)
+	if avatarURL == "" {
+		return "", nil
+	}
+	if len(avatarURL) > MaxAvatarURLLength {
+		return "", fmt.Errorf("avatar url is longer than %d bytes", MaxAvatarURLLength)
+	}
+
+	u, err := url.Parse(avatarURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return "", errors.New("avatar url must be an absolute https url")
+	}
+
+	return u.String(), nil
+}
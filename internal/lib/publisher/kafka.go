@@ -0,0 +1,48 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"strconv"
+)
+
+// KafkaPublisher writes events to a Kafka topic, keyed by their ID.
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func NewKafka(brokers []string, topic string) *KafkaPublisher {
+	return &KafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			RequiredAcks: kafka.RequireAll,
+		},
+	}
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, msg Message) error {
+	const op = "publisher.KafkaPublisher.Publish"
+
+	value, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(strconv.FormatInt(msg.ID, 10)),
+		Value: value,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Close flushes the writer and closes its connections.
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,52 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sso/internal/lib/webhook"
+	"time"
+)
+
+// Message is an event as it is published: its ID tells receivers which
+// events they have seen already, since an event is published again when
+// marking it delivered fails.
+type Message struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// LogPublisher only logs events. It is meant for local development.
+type LogPublisher struct {
+	log *slog.Logger
+}
+
+func NewLog(log *slog.Logger) *LogPublisher {
+	return &LogPublisher{log: log}
+}
+
+func (p *LogPublisher) Publish(_ context.Context, msg Message) error {
+	p.log.Debug("event",
+		slog.Int64("id", msg.ID),
+		slog.String("type", msg.Type),
+		slog.String("data", string(msg.Data)),
+	)
+
+	return nil
+}
+
+// WebhookPublisher posts events to a webhook, with the type of the event in
+// webhook.EventHeader.
+type WebhookPublisher struct {
+	client *webhook.Client
+}
+
+func NewWebhook(url string, secret string, timeout time.Duration) *WebhookPublisher {
+	return &WebhookPublisher{client: webhook.New(url, secret, timeout)}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, msg Message) error {
+	return p.client.Call(ctx, msg.Type, msg, nil)
+}
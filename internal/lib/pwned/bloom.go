@@ -0,0 +1,148 @@
+package pwned
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// bloomMagic starts bloom filter files. It changes whenever the bits a hash
+// maps to do, so that filters of an older layout fail to load instead of
+// missing breached passwords.
+const bloomMagic = "SSOBLOM2"
+
+// legacyBloomMagic starts filters probed with plain double hashing.
+const legacyBloomMagic = "SSOBLOOM"
+
+// minBloomBits is the size of the smallest filter.
+const minBloomBits = 1 << 16
+
+var ErrInvalidBloomFilter = errors.New("invalid bloom filter file")
+
+// BloomFilter is a set of SHA-1 password hashes with false positives but no
+// false negatives. It lets deployments without internet access check
+// passwords against a local copy of the breached password list.
+type BloomFilter struct {
+	k    uint32
+	m    uint64
+	bits []byte
+}
+
+// NewBloomFilter returns an empty filter sized for n hashes with the false
+// positive rate p.
+func NewBloomFilter(n uint64, p float64) *BloomFilter {
+	if n == 0 {
+		n = 1
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	// double hashing yields at most m*m different sets of bits, so small
+	// filters get more bits than n and p need to keep the rate
+	m = max(m, minBloomBits)
+
+	return &BloomFilter{k: k, m: m, bits: make([]byte, (m+7)/8)}
+}
+
+// Add adds the SHA-1 hash of a password.
+func (f *BloomFilter) Add(hash [sha1.Size]byte) {
+	f.probe(hash, func(bit uint64) bool {
+		f.bits[bit/8] |= 1 << (bit % 8)
+		return true
+	})
+}
+
+// Contains reports whether the hash may have been added.
+func (f *BloomFilter) Contains(hash [sha1.Size]byte) bool {
+	return f.probe(hash, func(bit uint64) bool {
+		return f.bits[bit/8]&(1<<(bit%8)) != 0
+	})
+}
+
+// probe calls visit with the k bits of the hash until it returns false and
+// reports whether it never did. The bits are derived from two halves of the
+// hash with enhanced double hashing, which unlike plain double hashing does
+// not collapse to a few bits when the step shares a factor with m.
+func (f *BloomFilter) probe(hash [sha1.Size]byte, visit func(bit uint64) bool) bool {
+	h1, h2 := split(hash)
+	a, b := h1%f.m, h2%f.m
+	for i := uint64(0); i < uint64(f.k); i++ {
+		if !visit(a) {
+			return false
+		}
+		a = (a + b) % f.m
+		b = (b + i + 1) % f.m
+	}
+
+	return true
+}
+
+// WriteTo writes the filter in the format ReadBloomFilter reads: the magic,
+// the number of hash functions, the number of bits and the bits.
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, len(bloomMagic)+4+8)
+	copy(header, bloomMagic)
+	binary.LittleEndian.PutUint32(header[len(bloomMagic):], f.k)
+	binary.LittleEndian.PutUint64(header[len(bloomMagic)+4:], f.m)
+
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+
+	m, err := w.Write(f.bits)
+
+	return int64(n + m), err
+}
+
+// ReadBloomFilter reads a filter written by WriteTo.
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	header := make([]byte, len(bloomMagic)+4+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidBloomFilter, err.Error())
+	}
+	switch string(header[:len(bloomMagic)]) {
+	case bloomMagic:
+	case legacyBloomMagic:
+		return nil, fmt.Errorf("%w: outdated layout, rebuild it with pwnedbloom", ErrInvalidBloomFilter)
+	default:
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidBloomFilter)
+	}
+
+	f := &BloomFilter{
+		k: binary.LittleEndian.Uint32(header[len(bloomMagic):]),
+		m: binary.LittleEndian.Uint64(header[len(bloomMagic)+4:]),
+	}
+	if f.k == 0 || f.m == 0 {
+		return nil, fmt.Errorf("%w: empty filter", ErrInvalidBloomFilter)
+	}
+
+	f.bits = make([]byte, (f.m+7)/8)
+	if _, err := io.ReadFull(r, f.bits); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidBloomFilter, err.Error())
+	}
+
+	return f, nil
+}
+
+// LoadBloomFilter reads the filter from the file.
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadBloomFilter(bufio.NewReader(file))
+}
+
+// split derives the two hashes of double hashing from the SHA-1 hash, which
+// is uniformly distributed already.
+func split(hash [sha1.Size]byte) (uint64, uint64) {
+	return binary.LittleEndian.Uint64(hash[0:8]), binary.LittleEndian.Uint64(hash[8:16])
+}
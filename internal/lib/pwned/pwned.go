@@ -0,0 +1,116 @@
+package pwned
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Config selects the sources of breached passwords. With both set, the
+// bloom filter is only consulted when the range API is unavailable.
+type Config struct {
+	// RangeURL is the base URL of a Pwned Passwords compatible range API,
+	// e.g. https://api.pwnedpasswords.com. Empty disables the API.
+	RangeURL string
+	// BloomFile is a filter built by cmd/pwnedbloom. Empty disables it.
+	BloomFile string
+	Timeout   time.Duration
+}
+
+// Checker tells whether passwords appear in known data breaches.
+type Checker struct {
+	rangeURL string
+	bloom    *BloomFilter
+	client   *http.Client
+}
+
+func New(cfg Config) (*Checker, error) {
+	c := &Checker{
+		rangeURL: strings.TrimSuffix(cfg.RangeURL, "/"),
+		client:   &http.Client{Timeout: cfg.Timeout},
+	}
+
+	if cfg.BloomFile != "" {
+		bloom, err := LoadBloomFilter(cfg.BloomFile)
+		if err != nil {
+			return nil, fmt.Errorf("load bloom filter: %w", err)
+		}
+		c.bloom = bloom
+	}
+
+	return c, nil
+}
+
+// Enabled reports whether the checker has a source to check passwords
+// against.
+func (c *Checker) Enabled() bool {
+	return c.rangeURL != "" || c.bloom != nil
+}
+
+// Pwned reports whether the password is known to be breached. It fails
+// only if the range API is unavailable and there is no bloom filter to fall
+// back to.
+func (c *Checker) Pwned(ctx context.Context, password string) (bool, error) {
+	const op = "pwned.Checker.Pwned"
+
+	hash := sha1.Sum([]byte(password))
+
+	if c.rangeURL != "" {
+		pwned, err := c.checkRange(ctx, hash)
+		if err == nil || c.bloom == nil {
+			if err != nil {
+				return false, fmt.Errorf("%s: %w", op, err)
+			}
+			return pwned, nil
+		}
+	}
+
+	if c.bloom != nil {
+		return c.bloom.Contains(hash), nil
+	}
+
+	return false, nil
+}
+
+// checkRange sends the first five hex digits of the hash to the range API
+// and looks for the rest among the returned suffixes (k-anonymity), so the
+// API never learns the hash.
+func (c *Checker) checkRange(ctx context.Context, hash [sha1.Size]byte) (bool, error) {
+	hexHash := strings.ToUpper(hex.EncodeToString(hash[:]))
+	prefix, suffix := hexHash[:5], hexHash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.rangeURL+"/range/"+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	// padding hides the number of suffixes of the prefix from observers
+	req.Header.Set("Add-Padding", "true")
+	req.Header.Set("User-Agent", "sso")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("range api: unexpected status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, 4<<20))
+	for scanner.Scan() {
+		candidate, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		// padding entries have a count of zero
+		if ok && count != "0" && strings.EqualFold(candidate, suffix) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
@@ -0,0 +1,31 @@
+package randtoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// size is the number of random bytes in a token.
+const size = 32
+
+// New returns a random URL-safe token and its hash.
+// Only the hash is meant to be persisted.
+func New() (token string, hash string, err error) {
+	b := make([]byte, size)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+
+	return token, Hash(token), nil
+}
+
+// Hash returns the hex encoded SHA-256 of the token.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
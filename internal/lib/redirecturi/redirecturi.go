@@ -0,0 +1,81 @@
+// Package redirecturi checks the redirect URIs apps register and matches
+// the ones of authorization requests against them, so that tokens are only
+// ever sent back to the app they were issued for.
+package redirecturi
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+)
+
+// MaxLength caps the length of redirect URIs.
+const MaxLength = 2048
+
+// loopbackHosts are the hosts of redirect URIs that may use plain http and
+// any port.
+var loopbackHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// Validate checks that the URI can be registered: an absolute https URI, or
+// an http one on a loopback host, without credentials or a fragment.
+func Validate(uri string) error {
+	if len(uri) > MaxLength {
+		return errors.New("redirect uri is too long")
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return errors.New("redirect uri is not a valid uri")
+	}
+
+	switch {
+	case u.Host == "" || u.Opaque != "":
+		return errors.New("redirect uri must be absolute")
+	case u.User != nil:
+		return errors.New("redirect uri can not have credentials")
+	case u.Fragment != "" || u.RawFragment != "" || u.ForceQuery:
+		return errors.New("redirect uri can not have a fragment")
+	case u.Scheme == "https":
+		return nil
+	case u.Scheme == "http" && isLoopback(u):
+		return nil
+	default:
+		return errors.New("redirect uri must use https unless it is on localhost")
+	}
+}
+
+// Match tells whether the requested URI is one of the registered ones. URIs
+// must be equal, except that the port of loopback URIs is ignored (RFC 8252,
+// section 7.3), as native apps and local development servers listen on
+// whatever port is free.
+func Match(registered []string, requested string) bool {
+	if slices.Contains(registered, requested) {
+		return true
+	}
+
+	if Validate(requested) != nil {
+		return false
+	}
+
+	req, err := url.Parse(requested)
+	if err != nil || req.Scheme != "http" || !isLoopback(req) {
+		return false
+	}
+
+	for _, uri := range registered {
+		reg, err := url.Parse(uri)
+		if err != nil || reg.Scheme != "http" || !isLoopback(reg) {
+			continue
+		}
+
+		if reg.Hostname() == req.Hostname() && reg.EscapedPath() == req.EscapedPath() && reg.RawQuery == req.RawQuery {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isLoopback(u *url.URL) bool {
+	return slices.Contains(loopbackHosts, u.Hostname())
+}
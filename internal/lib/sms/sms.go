@@ -0,0 +1,64 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+type Message struct {
+	// To is the phone number in E.164 format.
+	To   string
+	Body string
+}
+
+// FileSender writes every message to its own file in a directory instead of
+// sending it. It is meant for local development and tests.
+type FileSender struct {
+	dir string
+}
+
+func NewFile(dir string) (*FileSender, error) {
+	const op = "sms.NewFile"
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &FileSender{dir: dir}, nil
+}
+
+// Send writes the message to <unix nanos>_<recipient>.sms.
+func (s *FileSender) Send(_ context.Context, msg Message) error {
+	const op = "sms.FileSender.Send"
+
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + "_" + msg.To + ".sms"
+	if err := os.WriteFile(filepath.Join(s.dir, name), []byte(msg.Body), 0o640); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// LogSender only logs messages. Their bodies contain codes, so it must not
+// be used in production.
+type LogSender struct {
+	log *slog.Logger
+}
+
+func NewLog(log *slog.Logger) *LogSender {
+	return &LogSender{log: log}
+}
+
+func (s *LogSender) Send(_ context.Context, msg Message) error {
+	s.log.Info("sms",
+		slog.String("to", msg.To),
+		slog.String("body", msg.Body),
+	)
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const twilioAPI = "https://api.twilio.com/2010-04-01"
+
+// TwilioSender sends messages with the Twilio Programmable Messaging API.
+type TwilioSender struct {
+	accountSID string
+	authToken  string
+	from       string
+	client     *http.Client
+}
+
+func NewTwilio(accountSID string, authToken string, from string) *TwilioSender {
+	return &TwilioSender{
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type twilioError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (s *TwilioSender) Send(ctx context.Context, msg Message) error {
+	const op = "sms.TwilioSender.Send"
+
+	form := url.Values{}
+	form.Set("To", msg.To)
+	form.Set("From", s.from)
+	form.Set("Body", msg.Body)
+
+	endpoint := twilioAPI + "/Accounts/" + url.PathEscape(s.accountSID) + "/Messages.json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(s.accountSID, s.authToken)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var twErr twilioError
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(body, &twErr) == nil && twErr.Message != "" {
+			return fmt.Errorf("%s: twilio error %d: %s", op, twErr.Code, twErr.Message)
+		}
+		return fmt.Errorf("%s: twilio responded with %s", op, resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,706 @@
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/paseto"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatJWT    = "jwt"
+	FormatPASETO = "paseto"
+	// FormatOpaque tokens are random strings, their claims are kept
+	// server-side in the session store.
+	FormatOpaque = "opaque"
+)
+
+const opaquePrefix = "ot_"
+
+var (
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrUnknownFormat = errors.New("unknown token format")
+)
+
+// codec turns claims into a token string and back.
+type codec interface {
+	Encode(claims map[string]any, secret string) (string, error)
+	// Decode checks the token integrity with the secret, without validating claims.
+	Decode(token string, secret string) (map[string]any, error)
+	// AppID returns the app the token was issued for, without any verification.
+	AppID(token string) (int, error)
+}
+
+var codecs = map[string]codec{
+	FormatJWT:    jwt.Codec{},
+	FormatPASETO: paseto.Codec{},
+}
+
+// SessionStore keeps the claims of opaque tokens.
+type SessionStore interface {
+	SaveSession(ctx context.Context, session models.Session) error
+	Session(ctx context.Context, tokenHash string) (models.Session, error)
+	ExtendSession(ctx context.Context, session models.Session) error
+	DeleteSession(ctx context.Context, tokenHash string) error
+}
+
+// IssuanceStore keeps a record of every issued token by its jti.
+type IssuanceStore interface {
+	SaveTokenIssuance(ctx context.Context, issuance models.TokenIssuance) error
+	RevokeTokenIssuance(ctx context.Context, tokenID string) error
+}
+
+// Claims are the verified claims of a token issued by Manager.
+type Claims struct {
+	ID string
+	// UserID is zero in tokens issued to apps themselves.
+	UserID int64
+	Email  string
+	AppID  int
+	// OrgID is the org of the user, or of the app in tokens issued to apps.
+	// It is zero in tokens issued before orgs were introduced, when every
+	// user belonged to the default org.
+	OrgID    int64
+	Issuer   string
+	Audience string
+	// TokenVersion is the user token version at the time of issuing.
+	TokenVersion int64
+	// Scopes granted to the token.
+	Scopes []string
+	// Actor is the act claim of delegated tokens, nil otherwise.
+	Actor map[string]any
+	// AuthTime is when the user last authenticated, zero in tokens that do
+	// not come from a login.
+	AuthTime time.Time
+	// AuthMethods are the amr methods the user authenticated with.
+	AuthMethods []string
+	// ACR is the authentication context class the methods reach.
+	ACR string
+	// Guest is set in tokens of guests, which have no email yet.
+	Guest     bool
+	ExpiresAt time.Time
+}
+
+// Authentication context classes of tokens (RFC 9470).
+const (
+	ACRSingleFactor = "aal1"
+	ACRMultiFactor  = "aal2"
+)
+
+// reservedClaims are set by Manager itself and can not be overridden by app claims.
+var reservedClaims = map[string]struct{}{
+	"uid":           {},
+	"email":         {},
+	"exp":           {},
+	"app_id":        {},
+	"org_id":        {},
+	"iat":           {},
+	"nbf":           {},
+	"iss":           {},
+	"sub":           {},
+	"aud":           {},
+	"jti":           {},
+	"ver":           {},
+	"act":           {},
+	"scope":         {},
+	"auth_time":     {},
+	"amr":           {},
+	"acr":           {},
+	"guest":         {},
+	"app_metadata":  {},
+	"user_metadata": {},
+	"groups":        {},
+}
+
+const (
+	// ScopeMetadata makes tokens carry the app and user metadata of the user.
+	ScopeMetadata = "metadata"
+	// ScopeGroups makes tokens carry the names of the groups of the user.
+	ScopeGroups = "groups"
+	// ScopeRoles makes tokens carry the names of the roles the user has
+	// within the app, in place of a static roles claim of the app.
+	ScopeRoles = "roles"
+)
+
+// Manager issues and parses tokens in the format configured for each app,
+// falling back to the default format.
+type Manager struct {
+	issuer        string
+	defaultFormat string
+	// leeway is the clock skew tolerated when checking exp and nbf.
+	leeway    time.Duration
+	sessions  SessionStore
+	issuances IssuanceStore
+}
+
+func NewManager(
+	issuer string,
+	defaultFormat string,
+	leeway time.Duration,
+	sessions SessionStore,
+	issuances IssuanceStore,
+) (*Manager, error) {
+	if !validFormat(defaultFormat) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, defaultFormat)
+	}
+
+	return &Manager{
+		issuer:        issuer,
+		defaultFormat: defaultFormat,
+		leeway:        leeway,
+		sessions:      sessions,
+		issuances:     issuances,
+	}, nil
+}
+
+// NewToken issues a token for the user with the granted scopes, protected
+// with the app secret. The token audience is the app audience, or the app
+// name if it has none. authTime and amr tell resource servers when and how
+// the user authenticated, so that they can ask for a step-up.
+func (m *Manager) NewToken(
+	ctx context.Context,
+	user models.User,
+	app models.App,
+	scopes []string,
+	duration time.Duration,
+	authTime time.Time,
+	amr []string,
+) (string, error) {
+	claims := map[string]any{
+		"auth_time": authTime.Unix(),
+		"amr":       amr,
+		"acr":       ACR(amr),
+	}
+
+	token, _, err := m.newToken(ctx, &user, app, scopes, duration, nil, claims)
+
+	return token, err
+}
+
+// NewAppToken issues a token for the app itself, as in the client credentials
+// grant. It has no user claims.
+func (m *Manager) NewAppToken(
+	ctx context.Context,
+	app models.App,
+	scopes []string,
+	duration time.Duration,
+) (string, error) {
+	token, _, err := m.newToken(ctx, nil, app, scopes, duration, nil, nil)
+
+	return token, err
+}
+
+// NewDelegatedToken issues a token with the scopes for the user that actor
+// acts on behalf of. The actor chain of the token it was exchanged for, if
+// any, is nested into the act claim (RFC 8693, section 4.1).
+func (m *Manager) NewDelegatedToken(
+	ctx context.Context,
+	user models.User,
+	app models.App,
+	scopes []string,
+	duration time.Duration,
+	actor string,
+	prevActor map[string]any,
+) (string, error) {
+	act := map[string]any{"sub": actor}
+	if prevActor != nil {
+		act["act"] = prevActor
+	}
+
+	token, _, err := m.newToken(ctx, &user, app, scopes, duration, act, nil)
+
+	return token, err
+}
+
+// NewImpersonationToken issues a token for the user to the admin acting as
+// them. The act claim names the admin, so that apps can tell the token
+// apart. It returns the token and its id.
+func (m *Manager) NewImpersonationToken(
+	ctx context.Context,
+	user models.User,
+	app models.App,
+	duration time.Duration,
+	adminID int64,
+) (string, string, error) {
+	act := map[string]any{"sub": ImpersonationActor(adminID)}
+
+	return m.newToken(ctx, &user, app, nil, duration, act, nil)
+}
+
+// ImpersonationActor is the act subject of tokens admins impersonate users
+// with.
+func ImpersonationActor(adminID int64) string {
+	return "admin:" + strconv.FormatInt(adminID, 10)
+}
+
+// NewIDToken issues an OpenID Connect ID token for the user, authenticated
+// at authTime with the amr methods. ID tokens are always JWTs signed with the
+// signing key of the app, whatever format the app uses for access tokens.
+func (m *Manager) NewIDToken(
+	user models.User,
+	app models.App,
+	authTime time.Time,
+	amr []string,
+	duration time.Duration,
+) (string, error) {
+	now := time.Now()
+	claims := map[string]any{
+		"sub":       strconv.Itoa(user.ID),
+		"aud":       strconv.Itoa(app.ID),
+		"exp":       now.Add(duration).Unix(),
+		"iat":       now.Unix(),
+		"auth_time": authTime.Unix(),
+		"amr":       amr,
+		"acr":       ACR(amr),
+	}
+	if user.IsGuest {
+		claims["guest"] = true
+	} else {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerified
+	}
+	if user.Username != "" {
+		claims["preferred_username"] = user.Username
+	}
+	if m.issuer != "" {
+		claims["iss"] = m.issuer
+	}
+
+	return codecs[FormatJWT].Encode(claims, app.SigningKey)
+}
+
+// ACR returns the authentication context class reached by the amr methods:
+// multi-factor if the user has passed more than one factor, such as a
+// password and a one-time code, or a security key with user verification.
+func ACR(amr []string) string {
+	factors := make(map[string]struct{}, len(amr))
+	for _, method := range amr {
+		factors[method] = struct{}{}
+	}
+	if _, ok := factors["mfa"]; ok || len(factors) > 1 {
+		return ACRMultiFactor
+	}
+
+	return ACRSingleFactor
+}
+
+// newToken issues a token for the user, or for the app itself if user is nil,
+// and returns it with its id. auth holds the authentication claims, if any.
+func (m *Manager) newToken(
+	ctx context.Context,
+	user *models.User,
+	app models.App,
+	scopes []string,
+	duration time.Duration,
+	act map[string]any,
+	auth map[string]any,
+) (string, string, error) {
+	format := m.format(app)
+	if !validFormat(format) {
+		return "", "", fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return "", "", err
+	}
+
+	claims := make(map[string]any, len(app.Claims)+10)
+	for name, value := range app.Claims {
+		if _, ok := reservedClaims[name]; ok {
+			continue
+		}
+		claims[name] = value
+	}
+	if user != nil {
+		claims["uid"] = user.ID
+		claims["ver"] = user.TokenVersion
+		if user.IsGuest {
+			claims["guest"] = true
+		} else {
+			claims["email"] = user.Email
+		}
+		if slices.Contains(scopes, ScopeMetadata) {
+			if len(user.AppMetadata) > 0 {
+				claims["app_metadata"] = user.AppMetadata
+			}
+			if len(user.UserMetadata) > 0 {
+				claims["user_metadata"] = user.UserMetadata
+			}
+		}
+		if slices.Contains(scopes, ScopeGroups) {
+			groups := user.Groups
+			if groups == nil {
+				groups = []string{}
+			}
+			claims["groups"] = groups
+		}
+		if slices.Contains(scopes, ScopeRoles) {
+			roles := user.Roles
+			if roles == nil {
+				roles = []string{}
+			}
+			claims["roles"] = roles
+		}
+	}
+	orgID := app.OrgID
+	if user != nil {
+		orgID = user.OrgID
+	}
+	if orgID != 0 {
+		claims["org_id"] = orgID
+	}
+	now := time.Now()
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(duration).Unix()
+	claims["app_id"] = app.ID
+	claims["jti"] = id
+	claims["aud"] = Audience(app)
+	if m.issuer != "" {
+		claims["iss"] = m.issuer
+	}
+	if len(scopes) > 0 {
+		claims["scope"] = strings.Join(scopes, " ")
+	}
+	if act != nil {
+		claims["act"] = act
+	}
+	for name, value := range auth {
+		claims[name] = value
+	}
+	mapClaims(claims, app.ClaimMappings)
+
+	var token string
+	if format == FormatOpaque {
+		token, err = m.newSession(ctx, app, claims, now, duration)
+	} else {
+		token, err = codecs[format].Encode(claims, app.SigningKey)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	issuance := models.TokenIssuance{
+		ID:        id,
+		AppID:     app.ID,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(duration),
+	}
+	if user != nil {
+		issuance.UserID = int64(user.ID)
+	}
+	if err = m.issuances.SaveTokenIssuance(ctx, issuance); err != nil {
+		return "", "", err
+	}
+
+	return token, id, nil
+}
+
+// newSession saves the claims in the session store and returns the opaque
+// token they are looked up by.
+func (m *Manager) newSession(
+	ctx context.Context,
+	app models.App,
+	claims map[string]any,
+	now time.Time,
+	duration time.Duration,
+) (string, error) {
+	token, _, err := randtoken.New()
+	if err != nil {
+		return "", err
+	}
+	token = opaquePrefix + token
+
+	err = m.sessions.SaveSession(ctx, models.Session{
+		TokenHash: randtoken.Hash(token),
+		AppID:     app.ID,
+		Claims:    claims,
+		ExpiresAt: now.Add(duration),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Parse verifies the token integrity, expiration, issuer and audience
+// against the app and returns its claims.
+func (m *Manager) Parse(ctx context.Context, token string, app models.App) (Claims, error) {
+	claims, err := m.decode(ctx, token, app)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	jti, _ := claims["jti"].(string)
+	uid, _ := claims["uid"].(float64)
+	email, _ := claims["email"].(string)
+	appID, _ := claims["app_id"].(float64)
+	orgID, _ := claims["org_id"].(float64)
+	exp, _ := claims["exp"].(float64)
+	nbf, _ := claims["nbf"].(float64)
+	iss, _ := claims["iss"].(string)
+	aud, _ := claims["aud"].(string)
+	ver, _ := claims["ver"].(float64)
+	scope, _ := claims["scope"].(string)
+	act, _ := claims["act"].(map[string]any)
+	authTime, _ := claims["auth_time"].(float64)
+	acr, _ := claims["acr"].(string)
+	guest, _ := claims["guest"].(bool)
+
+	if exp == 0 {
+		return Claims{}, fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+	now := time.Now()
+	expiresAt := time.Unix(int64(exp), 0)
+	if !now.Before(expiresAt.Add(m.leeway)) {
+		return Claims{}, fmt.Errorf("%w: token is expired", ErrInvalidToken)
+	}
+	if nbf != 0 && now.Add(m.leeway).Before(time.Unix(int64(nbf), 0)) {
+		return Claims{}, fmt.Errorf("%w: token is not valid yet", ErrInvalidToken)
+	}
+
+	if int(appID) != app.ID {
+		return Claims{}, fmt.Errorf("%w: token was issued for another app", ErrInvalidToken)
+	}
+
+	if aud != Audience(app) {
+		return Claims{}, fmt.Errorf("%w: token has invalid audience", ErrInvalidToken)
+	}
+
+	if m.issuer != "" && iss != m.issuer {
+		return Claims{}, fmt.Errorf("%w: token has invalid issuer", ErrInvalidToken)
+	}
+
+	return Claims{
+		ID:           jti,
+		UserID:       int64(uid),
+		Email:        email,
+		AppID:        int(appID),
+		OrgID:        int64(orgID),
+		Issuer:       iss,
+		Audience:     aud,
+		TokenVersion: int64(ver),
+		Scopes:       strings.Fields(scope),
+		Actor:        act,
+		AuthTime:     authTimeOf(authTime),
+		AuthMethods:  stringsOf(claims["amr"]),
+		ACR:          acr,
+		Guest:        guest,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+// ValidClaimMapping reports whether the source claim can be renamed to
+// target. Claims the service validates tokens by can not be renamed, and no
+// claim can be renamed to a reserved one.
+func ValidClaimMapping(source string, target string) bool {
+	if source == "" || target == "" || source == target {
+		return false
+	}
+	if _, ok := reservedClaims[target]; ok {
+		return false
+	}
+	if _, ok := reservedClaims[source]; ok && source != "email" {
+		return false
+	}
+
+	return true
+}
+
+// mapClaims renames the claims according to the source to target mappings.
+// Every mapping applies to the claims as they were before any renaming.
+func mapClaims(claims map[string]any, mappings map[string]string) {
+	mapped := make(map[string]any, len(mappings))
+	for source, target := range mappings {
+		if value, ok := claims[source]; ok {
+			mapped[target] = value
+		}
+	}
+	for source := range mappings {
+		delete(claims, source)
+	}
+	for target, value := range mapped {
+		claims[target] = value
+	}
+}
+
+// authTimeOf returns the auth_time claim as a time, zero if it is not set.
+func authTimeOf(authTime float64) time.Time {
+	if authTime == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(int64(authTime), 0)
+}
+
+// stringsOf returns the strings of a decoded array claim.
+func stringsOf(claim any) []string {
+	values, _ := claim.([]any)
+
+	var res []string
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			res = append(res, s)
+		}
+	}
+
+	return res
+}
+
+// AppID returns the app the token claims to be issued for, without verifying
+// it. It must only be used to find the app the token is parsed with.
+func (m *Manager) AppID(ctx context.Context, token string) (int, error) {
+	if isOpaque(token) {
+		session, err := m.session(ctx, token)
+		if err != nil {
+			return 0, err
+		}
+
+		return session.AppID, nil
+	}
+
+	appID, err := codecOf(token).AppID(token)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidToken, err.Error())
+	}
+
+	return appID, nil
+}
+
+// Extend moves the expiration of an opaque token to duration from now, but
+// not past maxAge since the session started. Self-contained tokens can not be
+// extended and are left as is.
+func (m *Manager) Extend(ctx context.Context, token string, duration time.Duration, maxAge time.Duration) error {
+	if !isOpaque(token) {
+		return nil
+	}
+
+	session, err := m.session(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(duration)
+	if maxAge > 0 {
+		if limit := session.CreatedAt.Add(maxAge); expiresAt.After(limit) {
+			expiresAt = limit
+		}
+	}
+	if !expiresAt.After(session.ExpiresAt) {
+		return nil
+	}
+
+	session.ExpiresAt = expiresAt
+	session.Claims["exp"] = expiresAt.Unix()
+
+	return m.sessions.ExtendSession(ctx, session)
+}
+
+// Revoke marks the issuance record of the token with the id as revoked and
+// deletes the session of an opaque token. Self-contained tokens stay valid
+// until they expire, they have to be denied by the caller.
+func (m *Manager) Revoke(ctx context.Context, token string, tokenID string) error {
+	if err := m.issuances.RevokeTokenIssuance(ctx, tokenID); err != nil {
+		return err
+	}
+
+	if !isOpaque(token) {
+		return nil
+	}
+
+	return m.sessions.DeleteSession(ctx, randtoken.Hash(token))
+}
+
+// Audience returns the audience tokens of the app are issued for.
+func Audience(app models.App) string {
+	if app.Audience != "" {
+		return app.Audience
+	}
+
+	return app.Name
+}
+
+// decode returns the claims of the token, checking its integrity for
+// self-contained formats or looking it up in the session store for opaque ones.
+func (m *Manager) decode(ctx context.Context, token string, app models.App) (map[string]any, error) {
+	if isOpaque(token) {
+		session, err := m.session(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+
+		return session.Claims, nil
+	}
+
+	claims, err := codecOf(token).Decode(token, app.SigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err.Error())
+	}
+
+	return claims, nil
+}
+
+func (m *Manager) session(ctx context.Context, token string) (models.Session, error) {
+	session, err := m.sessions.Session(ctx, randtoken.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return models.Session{}, fmt.Errorf("%w: session not found", ErrInvalidToken)
+		}
+		return models.Session{}, err
+	}
+
+	return session, nil
+}
+
+func (m *Manager) format(app models.App) string {
+	if app.TokenFormat != "" {
+		return app.TokenFormat
+	}
+
+	return m.defaultFormat
+}
+
+func validFormat(format string) bool {
+	if format == FormatOpaque {
+		return true
+	}
+
+	_, ok := codecs[format]
+	return ok
+}
+
+func isOpaque(token string) bool {
+	return strings.HasPrefix(token, opaquePrefix)
+}
+
+// codecOf tells the token format apart by its header, so tokens stay valid
+// when the app switches formats.
+func codecOf(token string) codec {
+	if paseto.IsToken(token) {
+		return codecs[FormatPASETO]
+	}
+
+	return codecs[FormatJWT]
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
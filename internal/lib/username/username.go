@@ -0,0 +1,44 @@
+package username
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MinLength = 3
+	MaxLength = 32
+)
+
+// Normalize returns the form usernames are stored and looked up in:
+// trimmed and lowercase, so that they are matched case-insensitively.
+func Normalize(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// Valid reports whether the normalized username is acceptable: MinLength to
+// MaxLength lowercase letters, digits, dots, dashes and underscores,
+// starting with a letter or a digit. Usernames never contain "@", so they
+// can not be mistaken for emails.
+func Valid(username string) bool {
+	if n := utf8.RuneCountInString(username); n < MinLength || n > MaxLength {
+		return false
+	}
+
+	for i, r := range username {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case i > 0 && (r == '.' || r == '-' || r == '_'):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsEmail reports whether the login identifier is an email rather than a
+// username.
+func IsEmail(login string) bool {
+	return strings.Contains(login, "@")
+}
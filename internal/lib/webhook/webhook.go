@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers of webhook requests. SignatureHeader carries the hex encoded
+// HMAC-SHA256 of the timestamp, a dot and the body, keyed with the secret
+// of the webhook, so that receivers can check that requests come from the
+// service and are recent.
+const (
+	EventHeader     = "X-Webhook-Event"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// Client posts events to a webhook as JSON.
+type Client struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func New(url string, secret string, timeout time.Duration) *Client {
+	return &Client{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Call posts the payload as the event and decodes the JSON response of the
+// webhook into resp, unless resp is nil or the webhook responds without a
+// body. Responses other than 200 and 204 fail the call.
+func (c *Client) Call(ctx context.Context, event string, payload any, resp any) error {
+	const op = "webhook.Client.Call"
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(c.secret, timestamp, body))
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return nil
+	default:
+		return fmt.Errorf("%s: webhook responded with %s", op, res.Status)
+	}
+
+	if resp == nil {
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, 1<<16))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(data, resp); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Sign returns the signature of a webhook request, see SignatureHeader.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,206 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ilyakaznacheev/cleanenv"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"net/mail"
+	"os"
+	"sso/internal/domain/models"
+	"sso/internal/lib/randtoken"
+	"sso/internal/services/management"
+	"sso/internal/storage"
+	"time"
+)
+
+// Data is what a fresh environment is seeded with.
+type Data struct {
+	Apps   []App   `yaml:"apps"`
+	Admins []Admin `yaml:"admins"`
+}
+
+// App is an app to create. An empty Secret is generated.
+type App struct {
+	Name         string   `yaml:"name"`
+	OrgID        int64    `yaml:"org_id"`
+	Secret       string   `yaml:"secret"`
+	Scopes       []string `yaml:"scopes"`
+	GrantTypes   []string `yaml:"grant_types"`
+	RedirectURIs []string `yaml:"redirect_uris"`
+}
+
+// Admin is an admin user to create, with a verified email. An empty
+// Password is generated.
+type Admin struct {
+	Email    string `yaml:"email"`
+	Password string `yaml:"password"`
+}
+
+// Storage is what seeding writes to.
+type Storage interface {
+	SaveApp(ctx context.Context, app models.App, adminID int64) (int, error)
+	SaveUser(ctx context.Context, email string, passHash []byte, profile models.UserProfile) (int64, error)
+	SetEmailVerified(ctx context.Context, userID int64, email string) error
+	SetAdmin(ctx context.Context, userID int64, isAdmin bool) error
+}
+
+// Result tells what seeding created. Generated secrets and passwords are
+// only known from it.
+type Result struct {
+	Apps   []CreatedApp
+	Admins []CreatedAdmin
+	// SkippedApps and SkippedAdmins already existed, and were left as they
+	// are.
+	SkippedApps   []string
+	SkippedAdmins []string
+}
+
+type CreatedApp struct {
+	ID   int
+	Name string
+	// Secret is set if it was generated.
+	Secret string
+}
+
+type CreatedAdmin struct {
+	ID    int64
+	Email string
+	// Password is set if it was generated.
+	Password string
+}
+
+// Load reads the data from the YAML file at path, or from stdin if path is
+// "-".
+func Load(path string) (Data, error) {
+	const op = "seed.Load"
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return Data{}, fmt.Errorf("%s: %w", op, err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var data Data
+	if err := cleanenv.ParseYAML(r, &data); err != nil {
+		return Data{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return data, nil
+}
+
+// Apply creates the apps and admins of the data. Apps whose name is taken
+// and admins whose email is are skipped, so that seeding again is safe.
+// Everything is validated before anything is created.
+func Apply(ctx context.Context, s Storage, data Data) (Result, error) {
+	const op = "seed.Apply"
+
+	apps := make([]models.App, 0, len(data.Apps))
+	for _, app := range data.Apps {
+		valid, err := management.ValidateApp(models.App{
+			Name:         app.Name,
+			OrgID:        app.OrgID,
+			Secret:       app.Secret,
+			Scopes:       app.Scopes,
+			GrantTypes:   app.GrantTypes,
+			RedirectURIs: app.RedirectURIs,
+		})
+		if err != nil {
+			return Result{}, fmt.Errorf("%s: app %q: %w", op, app.Name, err)
+		}
+		apps = append(apps, valid)
+	}
+	for _, admin := range data.Admins {
+		if _, err := mail.ParseAddress(admin.Email); err != nil {
+			return Result{}, fmt.Errorf("%s: admin %q: invalid email", op, admin.Email)
+		}
+	}
+
+	var res Result
+	for _, app := range apps {
+		created, err := saveApp(ctx, s, app)
+		if errors.Is(err, storage.ErrAppExists) {
+			res.SkippedApps = append(res.SkippedApps, app.Name)
+			continue
+		}
+		if err != nil {
+			return res, fmt.Errorf("%s: app %q: %w", op, app.Name, err)
+		}
+		res.Apps = append(res.Apps, created)
+	}
+	for _, admin := range data.Admins {
+		created, err := saveAdmin(ctx, s, admin)
+		if errors.Is(err, storage.ErrUserExists) {
+			res.SkippedAdmins = append(res.SkippedAdmins, admin.Email)
+			continue
+		}
+		if err != nil {
+			return res, fmt.Errorf("%s: admin %q: %w", op, admin.Email, err)
+		}
+		res.Admins = append(res.Admins, created)
+	}
+
+	return res, nil
+}
+
+func saveApp(ctx context.Context, s Storage, app models.App) (CreatedApp, error) {
+	created := CreatedApp{Name: app.Name}
+
+	var err error
+	if app.Secret == "" {
+		if app.Secret, app.SecretHash, err = randtoken.New(); err != nil {
+			return created, err
+		}
+		created.Secret = app.Secret
+	} else {
+		app.SecretHash = randtoken.Hash(app.Secret)
+	}
+	if app.SigningKey, _, err = randtoken.New(); err != nil {
+		return created, err
+	}
+	if app.OrgID == 0 {
+		app.OrgID = models.DefaultOrgID
+	}
+	app.Secret = ""
+	app.CreatedAt = time.Now()
+	app.Status = models.AppStatusActive
+
+	created.ID, err = s.SaveApp(ctx, app, 0)
+
+	return created, err
+}
+
+func saveAdmin(ctx context.Context, s Storage, admin Admin) (CreatedAdmin, error) {
+	created := CreatedAdmin{Email: admin.Email}
+
+	password := admin.Password
+	if password == "" {
+		var err error
+		if password, _, err = randtoken.New(); err != nil {
+			return created, err
+		}
+		created.Password = password
+	}
+	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return created, err
+	}
+
+	if created.ID, err = s.SaveUser(ctx, admin.Email, passHash, models.UserProfile{}); err != nil {
+		return created, err
+	}
+	if err = s.SetEmailVerified(ctx, created.ID, admin.Email); err != nil {
+		return created, err
+	}
+	if err = s.SetAdmin(ctx, created.ID, true); err != nil {
+		return created, err
+	}
+
+	return created, nil
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/email"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/passhash"
+	"sso/internal/storage"
+	"time"
+)
+
+var ErrAccountPendingDeletion = errors.New("account is pending deletion")
+
+// purgeBatchSize limits the accounts PurgeDeletedAccounts deletes at once.
+const purgeBatchSize = 100
+
+// DeleteMyAccount schedules the deletion of the account of the user after
+// checking the password, and returns when the account is deleted. The
+// tokens of the user are revoked right away and logins fail until then,
+// unless the user cancels the deletion with CancelAccountDeletion. The
+// personal data is kept for AccountDeletionGracePeriod and anonymized by
+// PurgeDeletedAccounts.
+func (a *Auth) DeleteMyAccount(ctx context.Context, userID int64, password string) (time.Time, error) {
+	const op = "services.auth.DeleteMyAccount"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("scheduling account deletion")
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return time.Time{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.checkLockout(log, user); err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = passhash.Compare(user.PassHash, password); err != nil {
+		log.Warn("invalid credentials", sl.Err(err))
+
+		if err = a.recordFailedLogin(ctx, log, user); err != nil {
+			return time.Time{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return time.Time{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	now := time.Now()
+	deleteAt := now.Add(a.cfg.AccountDeletionGracePeriod)
+
+	if err = a.userSaver.ScheduleUserDeletion(ctx, userID, deleteAt); err != nil {
+		log.Error("failed to schedule account deletion", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.revokeSessions(ctx, userID); err != nil {
+		log.Error("failed to revoke user sessions", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.auditLog.SaveAuditEvent(ctx, models.AuditEvent{
+		Type:      models.AuditAccountDeletionScheduled,
+		UserID:    userID,
+		CreatedAt: now,
+	})
+	if err != nil {
+		log.Error("failed to save audit event", sl.Err(err))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.notify(ctx, log, userID, models.NotificationSecurity, accountDeletionEmail(user.Email, deleteAt))
+	if err != nil {
+		// the deletion is scheduled anyway
+		log.Error("failed to send account deletion email", sl.Err(err))
+	}
+
+	log.Info("account deletion scheduled", slog.Time("delete_at", deleteAt))
+
+	return deleteAt, nil
+}
+
+// CancelAccountDeletion keeps the account of the user identified by login
+// and password, if its deletion is scheduled but not done yet. The user can
+// log in again afterwards.
+func (a *Auth) CancelAccountDeletion(ctx context.Context, login string, password string) error {
+	const op = "services.auth.CancelAccountDeletion"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("login", login),
+	)
+
+	log.Info("canceling account deletion")
+
+	user, err := a.checkCredentials(ctx, log, login, password)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int("user_id", user.ID))
+
+	if user.DeletionScheduledAt == nil {
+		log.Info("account deletion is not scheduled")
+		return nil
+	}
+
+	if err = a.userSaver.CancelUserDeletion(ctx, int64(user.ID)); err != nil {
+		log.Error("failed to cancel account deletion", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("account deletion canceled")
+
+	return nil
+}
+
+// PurgeDeletedAccounts anonymizes the accounts whose grace period is over
+// and returns how many it deleted. Every deletion is recorded in the audit
+// log as AuditAccountDeleted together with the anonymization.
+func (a *Auth) PurgeDeletedAccounts(ctx context.Context) (int, error) {
+	const op = "services.auth.PurgeDeletedAccounts"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	ids, err := a.userProvider.UsersDueForDeletion(ctx, time.Now(), purgeBatchSize)
+	if err != nil {
+		log.Error("failed to get users due for deletion", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted := 0
+	for _, id := range ids {
+		if err = a.userSaver.AnonymizeUser(ctx, id, time.Now()); err != nil {
+			if errors.Is(err, storage.ErrUserNotFound) {
+				// the deletion was canceled meanwhile
+				log.Info("account deletion canceled", slog.Int64("user_id", id))
+				continue
+			}
+
+			log.Error("failed to anonymize user", slog.Int64("user_id", id), sl.Err(err))
+			return deleted, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("account deleted", slog.Int64("user_id", id))
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+func accountDeletionEmail(to string, deleteAt time.Time) email.Message {
+	return email.Message{
+		To:      to,
+		Subject: "Your account will be deleted",
+		Body: "You asked to delete your account. It will be deleted on " +
+			deleteAt.UTC().Format(time.RFC1123) + ".\n\n" +
+			"If you change your mind, cancel the deletion before then.\n",
+	}
+}
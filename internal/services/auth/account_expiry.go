@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"time"
+)
+
+// ErrAccountExpired is returned to users of temporary accounts who log in
+// after the account expired.
+var ErrAccountExpired = errors.New("account is expired")
+
+// What ExpireAccounts does with expired accounts.
+const (
+	// ExpiryDisable ends the sessions of expired accounts. Admins enable the
+	// accounts again by extending their expiry.
+	ExpiryDisable = "disable"
+	// ExpiryDelete deletes expired accounts as admins do, so that they are
+	// anonymized after AccountDeletionGracePeriod unless restored.
+	ExpiryDelete = "delete"
+)
+
+// checkExpiry fails with ErrAccountExpired once the account of the user has
+// expired.
+func (a *Auth) checkExpiry(log *slog.Logger, user models.User) error {
+	if user.ExpiresAt != nil && !time.Now().Before(*user.ExpiresAt) {
+		log.Warn("account is expired", slog.Time("expires_at", *user.ExpiresAt))
+		return ErrAccountExpired
+	}
+
+	return nil
+}
+
+// ExpireAccounts disables or deletes, as ExpiredAccountAction says, the
+// accounts that have expired, and returns how many. Logins fail once an
+// account expires anyway; this ends the sessions of the account as well.
+func (a *Auth) ExpireAccounts(ctx context.Context) (int, error) {
+	const op = "services.auth.ExpireAccounts"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("action", a.cfg.ExpiredAccountAction),
+	)
+
+	now := time.Now()
+	ids, err := a.userProvider.ExpiredUsers(ctx, now, purgeBatchSize)
+	if err != nil {
+		log.Error("failed to get expired users", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var purgeAt time.Time
+	if a.cfg.ExpiredAccountAction == ExpiryDelete {
+		purgeAt = now.Add(a.cfg.AccountDeletionGracePeriod)
+	}
+
+	expired := 0
+	for _, id := range ids {
+		if err = a.userSaver.ExpireUser(ctx, id, now, purgeAt); err != nil {
+			if errors.Is(err, storage.ErrUserNotFound) {
+				// the expiry was extended meanwhile
+				log.Info("account expiry extended", slog.Int64("user_id", id))
+				continue
+			}
+
+			log.Error("failed to expire user", slog.Int64("user_id", id), sl.Err(err))
+			return expired, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("account expired", slog.Int64("user_id", id))
+		expired++
+	}
+
+	return expired, nil
+}
@@ -4,33 +4,360 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"golang.org/x/crypto/bcrypt"
 	"log/slog"
+	"net/netip"
+	"slices"
 	"sso/internal/domain/models"
-	"sso/internal/lib/jwt"
+	"sso/internal/lib/email"
+	"sso/internal/lib/idp"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/passhash"
+	"sso/internal/lib/profile"
+	"sso/internal/lib/randtoken"
+	"sso/internal/lib/sms"
+	"sso/internal/lib/tokens"
+	"sso/internal/lib/username"
 	"sso/internal/storage"
+	"strings"
 	"time"
 )
 
 type Auth struct {
-	log          *slog.Logger
-	userSaver    UserSaver
-	userProvider UserProvider
-	appProvider  AppProvider
-	tokenTTL     time.Duration
+	log                *slog.Logger
+	userSaver          UserSaver
+	userProvider       UserProvider
+	appProvider        AppProvider
+	tokenStorage       RefreshTokenStorage
+	deviceStore        DeviceAuthorizationStorage
+	denylist           TokenDenylist
+	throttle           LoginThrottle
+	quotas             QuotaCounter
+	auditLog           AuditLog
+	resetTokens        PasswordResetStorage
+	verificationTokens EmailVerificationStorage
+	emailChangeTokens  EmailChangeStorage
+	magicLinks         MagicLinkStorage
+	mfa                MFAStorage
+	secrets            SecretBox
+	passkeys           WebAuthnStorage
+	webAuthn           *webauthn.WebAuthn
+	smsCodes           SMSCodeStorage
+	federation         FederationStorage
+	invitations        InvitationStorage
+	orgs               OrgMembershipStorage
+	consents           ConsentStorage
+	identityProviders  map[string]IdentityProvider
+	upstreams          *upstreamCache
+	directories        map[int]AppDirectory
+	emailSender        EmailSender
+	smsSender          SMSSender
+	tokens             *tokens.Manager
+	passwordPolicy     PasswordPolicy
+	pwned              PwnedChecker
+	hooks              *Hooks
+	cfg                Config
+}
+
+// Config holds the token settings of the service.
+type Config struct {
+	TokenTTL        time.Duration
+	RefreshTokenTTL time.Duration
+	// DeviceCodeTTL and DevicePollInterval configure the device authorization grant.
+	DeviceCodeTTL      time.Duration
+	DevicePollInterval time.Duration
+	// VerificationURI is where users enter device user codes.
+	VerificationURI string
+	// SlidingSessions extends sessions on every refresh and on validation
+	// of opaque tokens, up to SessionMaxAge since the login.
+	SlidingSessions bool
+	SessionMaxAge   time.Duration
+	// LoginHistorySize is how many of the latest logins of each user are
+	// kept. Zero disables the login history and the last login tracking.
+	LoginHistorySize int
+	// PasswordResetTTL is the lifetime of password reset links. The reset
+	// token is appended to PasswordResetURL.
+	PasswordResetTTL time.Duration
+	PasswordResetURL string
+	// PasswordChangeTTL is the lifetime of the password reset tokens users
+	// an admin required to set a new password get when they log in.
+	PasswordChangeTTL time.Duration
+	// EmailVerificationTTL is the lifetime of email verification links. The
+	// verification token is appended to EmailVerificationURL.
+	EmailVerificationTTL time.Duration
+	EmailVerificationURL string
+	// RequireVerifiedEmail denies logins of users who have not verified
+	// their email.
+	RequireVerifiedEmail bool
+	// EmailChangeTTL is the lifetime of the links confirming a new email.
+	// The token is appended to EmailChangeURL.
+	EmailChangeTTL time.Duration
+	EmailChangeURL string
+	// MagicLinkTTL is the lifetime of passwordless login links. The token is
+	// appended to MagicLinkURL. At most MagicLinkLimit links are sent to a
+	// user within MagicLinkWindow.
+	MagicLinkTTL    time.Duration
+	MagicLinkURL    string
+	MagicLinkLimit  int
+	MagicLinkWindow time.Duration
+	// MFAIssuer names the service in authenticator apps. MFAChallengeTTL
+	// and MFAMaxAttempts limit how a login waits for the second factor.
+	MFAIssuer       string
+	MFAChallengeTTL time.Duration
+	MFAMaxAttempts  int
+	// TrustedDeviceTTL is how long devices the user trusts skip the second
+	// factor. Zero disables trusted devices.
+	TrustedDeviceTTL time.Duration
+	// WebAuthnSessionTTL limits the time between the begin and finish steps
+	// of passkey ceremonies.
+	WebAuthnSessionTTL time.Duration
+	// SMSCodeTTL is the lifetime of codes sent by SMS. A new code is sent at
+	// most every SMSResendInterval and accepts SMSMaxAttempts wrong entries.
+	SMSCodeTTL        time.Duration
+	SMSResendInterval time.Duration
+	SMSMaxAttempts    int
+	// FederationStateTTL limits the time a user may take to log in at an
+	// upstream identity provider.
+	FederationStateTTL time.Duration
+	// FederationRedirectBaseURL is the prefix of the callback URLs of
+	// identity providers stored by operators: <base>/<name>/callback.
+	FederationRedirectBaseURL string
+	// LockoutThreshold failed password logins within LockoutWindow lock the
+	// account for LockoutDuration. Zero disables lockouts.
+	LockoutThreshold int
+	LockoutWindow    time.Duration
+	LockoutDuration  time.Duration
+	// Password logins for a login, or from an IP, are rejected once
+	// LoginThrottleEmailLimit, or LoginThrottleIPLimit, of them have failed
+	// within LoginThrottleWindow. Zero limits disable the throttle.
+	LoginThrottleEmailLimit int
+	LoginThrottleIPLimit    int
+	LoginThrottleWindow     time.Duration
+	// LoginThrottleBypass lists the networks of trusted clients whose IPs
+	// are not throttled.
+	LoginThrottleBypass []netip.Prefix
+	// InvitationOnly closes open registration: users register by accepting
+	// an invitation. InvitationTTL is the lifetime of invitations, their
+	// token is appended to InvitationURL.
+	InvitationOnly bool
+	InvitationTTL  time.Duration
+	InvitationURL  string
+	// OrgInvitationURL is where the tokens of invitations to join an org
+	// are appended to. They also expire after InvitationTTL.
+	OrgInvitationURL string
+	// AccountDeletionGracePeriod is how long accounts users asked to delete
+	// are kept before they are anonymized.
+	AccountDeletionGracePeriod time.Duration
+	// ExpiredAccountAction is what ExpireAccounts does with expired
+	// accounts, ExpiryDisable or ExpiryDelete.
+	ExpiredAccountAction string
+	// ImpersonationTTL caps the lifetime of the tokens admins impersonate
+	// users with.
+	ImpersonationTTL time.Duration
+	// DefaultRoles are assigned to users once they register.
+	DefaultRoles []DefaultRole
+}
+
+// DefaultRole is a role assigned on registration within the app, or
+// globally if AppID is zero.
+type DefaultRole struct {
+	Role  string
+	AppID int
 }
 
 type UserSaver interface {
-	SaveUser(ctx context.Context, email string, passHash []byte) (int64, error)
+	SaveUser(ctx context.Context, email string, passHash []byte, profile models.UserProfile) (int64, error)
+	IncrementTokenVersion(ctx context.Context, userID int64) error
+	UpdatePassword(ctx context.Context, userID int64, passHash []byte) error
+	SetEmailVerified(ctx context.Context, userID int64, email string) error
+	UpdateEmail(ctx context.Context, userID int64, email string) error
+	SetPhone(ctx context.Context, userID int64, phone string) error
+	SetUsername(ctx context.Context, userID int64, username string) error
+	SetUserMetadata(ctx context.Context, userID int64, md map[string]string) error
+	SetAppMetadata(ctx context.Context, userID int64, md map[string]string) error
+	ExpireUser(ctx context.Context, userID int64, at time.Time, purgeAt time.Time) error
+	SetAdmin(ctx context.Context, userID int64, isAdmin bool) error
+	RecordFailedLogin(ctx context.Context, userID int64, at time.Time, windowStart time.Time) (int, error)
+	LockUser(ctx context.Context, userID int64, until time.Time) error
+	UnlockUser(ctx context.Context, userID int64) error
+	ScheduleUserDeletion(ctx context.Context, userID int64, at time.Time) error
+	CancelUserDeletion(ctx context.Context, userID int64) error
+	AnonymizeUser(ctx context.Context, userID int64, at time.Time) error
+	SaveGuest(ctx context.Context) (int64, error)
+	UpgradeGuest(ctx context.Context, userID int64, email string, passHash []byte) error
+	RecordLogin(ctx context.Context, login models.Login, keep int) error
+	AssignRole(ctx context.Context, userID int64, role string, appID int, adminID int64, at time.Time) error
+	SaveNotificationPreferences(ctx context.Context, prefs models.NotificationPreferences) error
 }
 
 type UserProvider interface {
 	User(ctx context.Context, email string) (models.User, error)
+	UserByID(ctx context.Context, userID int64) (models.User, error)
+	UserByPhone(ctx context.Context, phone string) (models.User, error)
+	UserByUsername(ctx context.Context, username string) (models.User, error)
+	UsersDueForDeletion(ctx context.Context, now time.Time, limit int) ([]int64, error)
+	ExpiredUsers(ctx context.Context, now time.Time, limit int) ([]int64, error)
 	IsAdmin(ctx context.Context, userID int64) (bool, error)
+	LoginHistory(ctx context.Context, userID int64, limit int) ([]models.Login, error)
+	NotificationPreferences(ctx context.Context, userID int64) (models.NotificationPreferences, error)
+	UserGroups(ctx context.Context, userID int64) ([]models.Group, error)
+	UserRoles(ctx context.Context, userID int64, appID int) ([]models.Role, error)
+	IsAppAdmin(ctx context.Context, userID int64, appID int) (bool, error)
 }
 
 type AppProvider interface {
 	App(ctx context.Context, appID int) (models.App, error)
+	AppByAudience(ctx context.Context, audience string) (models.App, error)
+}
+
+type RefreshTokenStorage interface {
+	SaveRefreshToken(ctx context.Context, token models.RefreshToken) error
+	RefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, oldID int64, token models.RefreshToken) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID int64) error
+	SaveUserSession(ctx context.Context, session models.UserSession) error
+	TouchUserSession(ctx context.Context, familyID string, ip string, userAgent string, device string, at time.Time) error
+	UserSession(ctx context.Context, id int64) (models.UserSession, error)
+	ActiveUserSessions(ctx context.Context, userID int64) ([]models.UserSession, error)
+}
+
+type DeviceAuthorizationStorage interface {
+	SaveDeviceAuthorization(ctx context.Context, auth models.DeviceAuthorization) error
+	DeviceAuthorization(ctx context.Context, deviceCodeHash string) (models.DeviceAuthorization, error)
+	DeviceAuthorizationByUserCode(ctx context.Context, userCode string) (models.DeviceAuthorization, error)
+	UpdateDeviceAuthorizationStatus(ctx context.Context,
+		id int64,
+		from models.DeviceAuthorizationStatus,
+		to models.DeviceAuthorizationStatus,
+		userID int64,
+	) error
+	TouchDeviceAuthorization(ctx context.Context, id int64, polledAt time.Time) error
+}
+
+// TokenDenylist keeps ids of revoked access tokens until the tokens expire.
+type TokenDenylist interface {
+	Deny(ctx context.Context, tokenID string, expiresAt time.Time) error
+	IsDenied(ctx context.Context, tokenID string) (bool, error)
+}
+
+// LoginThrottle counts failed logins by key in sliding windows.
+type LoginThrottle interface {
+	Attempts(ctx context.Context, key string, since time.Time) (int, error)
+	RecordAttempt(ctx context.Context, key string, at time.Time, window time.Duration) error
+}
+
+type PasswordResetStorage interface {
+	SavePasswordResetToken(ctx context.Context, token models.PasswordResetToken) error
+	PasswordResetToken(ctx context.Context, tokenHash string) (models.PasswordResetToken, error)
+	UsePasswordResetToken(ctx context.Context, id int64) error
+}
+
+type EmailVerificationStorage interface {
+	SaveEmailVerificationToken(ctx context.Context, token models.EmailVerificationToken) error
+	EmailVerificationToken(ctx context.Context, tokenHash string) (models.EmailVerificationToken, error)
+	UseEmailVerificationToken(ctx context.Context, id int64) error
+}
+
+type EmailChangeStorage interface {
+	SaveEmailChangeToken(ctx context.Context, token models.EmailChangeToken) error
+	EmailChangeToken(ctx context.Context, tokenHash string) (models.EmailChangeToken, error)
+	UseEmailChangeToken(ctx context.Context, id int64) error
+}
+
+type MagicLinkStorage interface {
+	SaveMagicLink(ctx context.Context, link models.MagicLink) error
+	MagicLink(ctx context.Context, tokenHash string) (models.MagicLink, error)
+	UseMagicLink(ctx context.Context, id int64) error
+	CountMagicLinks(ctx context.Context, userID int64, since time.Time) (int, error)
+}
+
+type InvitationStorage interface {
+	SaveInvitation(ctx context.Context, invitation models.Invitation) (int64, error)
+	Invitation(ctx context.Context, tokenHash string) (models.Invitation, error)
+	AcceptInvitation(ctx context.Context, id int64, passHash []byte, at time.Time) (int64, error)
+}
+
+type ConsentStorage interface {
+	SaveConsent(ctx context.Context, consent models.Consent) error
+	Consent(ctx context.Context, userID int64, appID int) (models.Consent, error)
+	Consents(ctx context.Context, userID int64) ([]models.Consent, error)
+	DeleteConsent(ctx context.Context, userID int64, appID int) error
+}
+
+type MFAStorage interface {
+	SaveTOTP(ctx context.Context, totp models.TOTP) error
+	TOTP(ctx context.Context, userID int64) (models.TOTP, error)
+	ConfirmTOTP(ctx context.Context, userID int64, step int64) error
+	UseTOTPStep(ctx context.Context, userID int64, step int64) error
+	DeleteTOTP(ctx context.Context, userID int64) error
+	SaveMFAChallenge(ctx context.Context, challenge models.MFAChallenge) error
+	MFAChallenge(ctx context.Context, tokenHash string) (models.MFAChallenge, error)
+	FailMFAChallenge(ctx context.Context, id int64) error
+	UseMFAChallenge(ctx context.Context, id int64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error
+	SaveTrustedDevice(ctx context.Context, device models.TrustedDevice) error
+	TrustedDevice(ctx context.Context, tokenHash string) (models.TrustedDevice, error)
+	TouchTrustedDevice(ctx context.Context, id int64, ip string, at time.Time) error
+	TrustedDevices(ctx context.Context, userID int64) ([]models.TrustedDevice, error)
+	DeleteTrustedDevice(ctx context.Context, userID int64, id int64) error
+	DeleteUserTrustedDevices(ctx context.Context, userID int64) error
+}
+
+type WebAuthnStorage interface {
+	SaveWebAuthnCredential(ctx context.Context, credential models.WebAuthnCredential) error
+	WebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error)
+	UpdateWebAuthnCredential(ctx context.Context, credentialID []byte, data []byte, usedAt time.Time) error
+	SaveWebAuthnSession(ctx context.Context, session models.WebAuthnSession) error
+	WebAuthnSession(ctx context.Context, tokenHash string) (models.WebAuthnSession, error)
+	UseWebAuthnSession(ctx context.Context, id int64) error
+}
+
+type SMSCodeStorage interface {
+	SaveSMSCode(ctx context.Context, code models.SMSCode) error
+	LatestSMSCode(ctx context.Context, userID int64, purpose models.SMSCodePurpose) (models.SMSCode, error)
+	FailSMSCode(ctx context.Context, id int64) error
+	UseSMSCode(ctx context.Context, id int64) error
+}
+
+type FederationStorage interface {
+	SaveFederationState(ctx context.Context, state models.FederationState) error
+	FederationState(ctx context.Context, stateHash string) (models.FederationState, error)
+	UseFederationState(ctx context.Context, id int64) error
+	SaveUserIdentity(ctx context.Context, identity models.UserIdentity) error
+	UserIdentity(ctx context.Context, provider string, subject string) (models.UserIdentity, error)
+	IdentityProvider(ctx context.Context, name string) (models.IdentityProvider, error)
+}
+
+// IdentityProvider is an upstream OpenID Connect provider users can log in
+// with.
+type IdentityProvider interface {
+	AuthCodeURL(state string, nonce string, verifier string) string
+	Exchange(ctx context.Context, code string, verifier string, nonce string) (idp.Identity, error)
+}
+
+// SecretBox encrypts secrets the service stores, such as TOTP secrets.
+type SecretBox interface {
+	Seal(plaintext []byte) (string, error)
+	Open(sealed string) ([]byte, error)
+}
+
+type EmailSender interface {
+	Send(ctx context.Context, msg email.Message) error
+}
+
+type SMSSender interface {
+	Send(ctx context.Context, msg sms.Message) error
+}
+
+// amrPassword is the amr value of password logins (RFC 8176).
+const amrPassword = "pwd"
+
+// AuditLog keeps security relevant events.
+type AuditLog interface {
+	SaveAuditEvent(ctx context.Context, event models.AuditEvent) error
 }
 
 var (
@@ -38,6 +365,21 @@ var (
 	ErrInvalidAppID       = errors.New("invalid app id")
 	ErrUserExists         = errors.New("user already exists")
 	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidClient      = errors.New("invalid client credentials")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrInvalidTarget      = errors.New("invalid target audience")
+	ErrInvalidScope       = errors.New("invalid scope")
+	ErrInvalidProfile     = errors.New("invalid profile")
+	// ErrWrongAudience means that the token is valid, but was issued for
+	// another app than the one it is presented to.
+	ErrWrongAudience = fmt.Errorf("%w: issued for another audience", ErrInvalidToken)
+	// ErrUnauthorizedClient means that the app may not use the grant type.
+	ErrUnauthorizedClient = errors.New("grant type not allowed for the app")
+	// ErrOrgMismatch means that the user does not belong to the org of the
+	// app.
+	ErrOrgMismatch = errors.New("user does not belong to the org of the app")
+	// ErrAppDisabled means that an admin disabled the app.
+	ErrAppDisabled = errors.New("app is disabled")
 )
 
 func New(
@@ -45,60 +387,657 @@ func New(
 	userSaver UserSaver,
 	userProvider UserProvider,
 	appProvider AppProvider,
-	tokenTTL time.Duration,
+	tokenStorage RefreshTokenStorage,
+	deviceStore DeviceAuthorizationStorage,
+	denylist TokenDenylist,
+	throttle LoginThrottle,
+	quotas QuotaCounter,
+	auditLog AuditLog,
+	resetTokens PasswordResetStorage,
+	verificationTokens EmailVerificationStorage,
+	emailChangeTokens EmailChangeStorage,
+	magicLinks MagicLinkStorage,
+	mfa MFAStorage,
+	secrets SecretBox,
+	passkeys WebAuthnStorage,
+	webAuthn *webauthn.WebAuthn,
+	smsCodes SMSCodeStorage,
+	federation FederationStorage,
+	invitations InvitationStorage,
+	orgs OrgMembershipStorage,
+	consents ConsentStorage,
+	identityProviders map[string]IdentityProvider,
+	directories map[int]AppDirectory,
+	emailSender EmailSender,
+	smsSender SMSSender,
+	tokens *tokens.Manager,
+	passwordPolicy PasswordPolicy,
+	pwned PwnedChecker,
+	hooks *Hooks,
+	cfg Config,
 ) *Auth {
 	return &Auth{
-		log:          log,
-		userSaver:    userSaver,
-		userProvider: userProvider,
-		appProvider:  appProvider,
-		tokenTTL:     tokenTTL,
+		log:                log,
+		userSaver:          userSaver,
+		userProvider:       userProvider,
+		appProvider:        appProvider,
+		tokenStorage:       tokenStorage,
+		deviceStore:        deviceStore,
+		denylist:           denylist,
+		throttle:           throttle,
+		quotas:             quotas,
+		auditLog:           auditLog,
+		resetTokens:        resetTokens,
+		verificationTokens: verificationTokens,
+		emailChangeTokens:  emailChangeTokens,
+		magicLinks:         magicLinks,
+		mfa:                mfa,
+		secrets:            secrets,
+		passkeys:           passkeys,
+		webAuthn:           webAuthn,
+		smsCodes:           smsCodes,
+		federation:         federation,
+		invitations:        invitations,
+		orgs:               orgs,
+		consents:           consents,
+		identityProviders:  identityProviders,
+		upstreams:          newUpstreamCache(),
+		directories:        directories,
+		emailSender:        emailSender,
+		smsSender:          smsSender,
+		tokens:             tokens,
+		passwordPolicy:     passwordPolicy,
+		pwned:              pwned,
+		hooks:              hooks,
+		cfg:                cfg,
 	}
 }
 
-func (a *Auth) Login(ctx context.Context, email string, password string, appID int) (token string, err error) {
+// Login checks the user credentials and issues tokens for the app. The user
+// is identified by login, either the email or the username. Requested
+// scopes must all be allowed for the app, otherwise Login fails with
+// ErrInvalidScope. If the user has enabled MFA, only the MFAToken of the pair
+// is set and the login is completed with LoginMFA. Too many failed logins
+// for the login or from the IP of the client fail with ErrTooManyAttempts.
+// Apps not allowed the password grant fail with ErrUnauthorizedClient.
+func (a *Auth) Login(
+	ctx context.Context,
+	login string,
+	password string,
+	appID int,
+	scopes []string,
+) (models.TokenPair, error) {
 	const op = "services.auth.Login"
 	log := a.log.With(
 		slog.String("op", op),
-		slog.String("email", email),
+		slog.String("login", login),
 	)
 
 	log.Info("logging user")
 
-	user, err := a.userProvider.User(ctx, email)
+	if _, err := a.grantingApp(ctx, log, appID, models.GrantPassword); err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys := a.throttleKeys(ctx, login)
+	if err := a.checkThrottle(ctx, log, keys); err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var user models.User
+	var err error
+	if directory, ok := a.directories[appID]; ok {
+		user, err = a.checkDirectoryCredentials(ctx, log, directory, strings.TrimSpace(login), password)
+	} else {
+		user, err = a.checkCredentials(ctx, log, login, password)
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			a.recordThrottledFailure(ctx, log, keys)
+			a.recordLoginEvent(ctx, models.AuditLoginFailed, 0, appID)
+		}
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pair, err := a.login(ctx, log, user, appID, scopes, []string{amrPassword})
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pair, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented token
+// is rotated: it can not be used again, and presenting it again revokes every
+// token obtained from the same login.
+func (a *Auth) Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error) {
+	const op = "services.auth.Refresh"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("refreshing tokens")
+
+	current, user, app, err := a.refreshSession(ctx, log, refreshToken)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(
+		slog.Int64("user_id", current.UserID),
+		slog.Int("app_id", current.AppID),
+		slog.String("family_id", current.FamilyID),
+	)
+
+	if err = checkGrant(log, app, models.GrantRefreshToken); err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pair, err := a.issueTokens(ctx, user, app, current.Scopes, current.AuthMethods, current.FamilyID, &current)
+	if err != nil {
+		if errors.Is(err, storage.ErrRefreshTokenRotated) {
+			// another request rotated the same token first
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, a.revokeReusedFamily(ctx, log, current))
+		}
+		if errors.Is(err, ErrConsentRequired) {
+			log.Warn("user revoked the consent to the app")
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to issue tokens", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("tokens refreshed")
+
+	return pair, nil
+}
+
+// refreshSession returns the refresh token with its user and app if it can
+// still be used. Reusing a rotated token revokes its whole family. It fails
+// with ErrInvalidToken if the token can not be used.
+func (a *Auth) refreshSession(
+	ctx context.Context,
+	log *slog.Logger,
+	refreshToken string,
+) (models.RefreshToken, models.User, models.App, error) {
+	current, err := a.tokenStorage.RefreshToken(ctx, randtoken.Hash(refreshToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrRefreshTokenNotFound) {
+			log.Warn("refresh token not found", sl.Err(err))
+			return models.RefreshToken{}, models.User{}, models.App{}, ErrInvalidToken
+		}
+
+		log.Error("failed to get refresh token", sl.Err(err))
+		return models.RefreshToken{}, models.User{}, models.App{}, err
+	}
+
+	log = log.With(
+		slog.Int64("user_id", current.UserID),
+		slog.Int("app_id", current.AppID),
+		slog.String("family_id", current.FamilyID),
+	)
+
+	if current.RevokedAt != nil {
+		log.Warn("refresh token is revoked")
+		return models.RefreshToken{}, models.User{}, models.App{}, ErrInvalidToken
+	}
+
+	if current.RotatedAt != nil {
+		return models.RefreshToken{}, models.User{}, models.App{}, a.revokeReusedFamily(ctx, log, current)
+	}
+
+	if time.Now().After(current.ExpiresAt) {
+		log.Warn("refresh token is expired")
+		return models.RefreshToken{}, models.User{}, models.App{}, ErrInvalidToken
+	}
+
+	user, err := a.userProvider.UserByID(ctx, current.UserID)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
-			log.Warn("invalid credentials", err.Error())
-			return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+			log.Warn("user not found", sl.Err(err))
+			return models.RefreshToken{}, models.User{}, models.App{}, ErrInvalidToken
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.RefreshToken{}, models.User{}, models.App{}, err
+	}
+
+	if err = a.checkExpiry(log, user); err != nil {
+		return models.RefreshToken{}, models.User{}, models.App{}, err
+	}
+
+	app, err := a.appProvider.App(ctx, current.AppID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppDisabled) {
+			log.Warn("app is disabled", sl.Err(err))
+			return models.RefreshToken{}, models.User{}, models.App{}, ErrAppDisabled
+		}
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.RefreshToken{}, models.User{}, models.App{}, ErrInvalidToken
 		}
 
-		log.Error("failed to get user", err.Error())
-		return "", fmt.Errorf("%s: %w", op, err)
+		log.Error("failed to get app", sl.Err(err))
+		return models.RefreshToken{}, models.User{}, models.App{}, err
 	}
 
-	if err = bcrypt.CompareHashAndPassword([]byte(user.PassHash), []byte(password)); err != nil {
-		log.Warn("invalid credentials", err.Error())
-		return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	return current, user, app, nil
+}
+
+// checkCredentials returns the user with the email or username if the
+// password matches. It fails with ErrInvalidCredentials otherwise.
+func (a *Auth) checkCredentials(ctx context.Context, log *slog.Logger, login string, password string) (models.User, error) {
+	user, err := a.userByLogin(ctx, login)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("invalid credentials", sl.Err(err))
+			return models.User{}, ErrInvalidCredentials
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.User{}, err
+	}
+
+	if err = a.checkLockout(log, user); err != nil {
+		return models.User{}, err
+	}
+
+	if err = passhash.Compare(user.PassHash, password); err != nil {
+		log.Warn("invalid credentials", sl.Err(err))
+
+		if err = a.recordFailedLogin(ctx, log, user); err != nil {
+			return models.User{}, err
+		}
+
+		return models.User{}, ErrInvalidCredentials
+	}
+
+	if user.FailedLogins > 0 {
+		if err = a.userSaver.UnlockUser(ctx, int64(user.ID)); err != nil {
+			log.Error("failed to reset failed logins", sl.Err(err))
+			return models.User{}, err
+		}
+	}
+
+	if a.cfg.RequireVerifiedEmail && !user.EmailVerified {
+		log.Warn("email is not verified")
+		return models.User{}, ErrEmailNotVerified
+	}
+
+	return user, nil
+}
+
+// userByLogin returns the user identified by login: the email if it is one,
+// the username otherwise. Both are trimmed and matched case-insensitively.
+func (a *Auth) userByLogin(ctx context.Context, login string) (models.User, error) {
+	login = strings.TrimSpace(login)
+	if username.IsEmail(login) {
+		return a.userProvider.User(ctx, login)
+	}
+
+	return a.userProvider.UserByUsername(ctx, username.Normalize(login))
+}
+
+// login issues tokens to the user, who has passed the first factor with the
+// amr methods. Users who have enabled MFA get an MFA token instead, unless
+// the request comes from a device they trust.
+func (a *Auth) login(
+	ctx context.Context,
+	log *slog.Logger,
+	user models.User,
+	appID int,
+	scopes []string,
+	amr []string,
+) (models.TokenPair, error) {
+	if user.Status == models.UserStatusSuspended {
+		log.Warn("account is suspended")
+		return models.TokenPair{}, ErrAccountSuspended
+	}
+
+	if err := a.checkExpiry(log, user); err != nil {
+		return models.TokenPair{}, err
+	}
+
+	if user.DeletionScheduledAt != nil {
+		log.Warn("account is pending deletion")
+		return models.TokenPair{}, ErrAccountPendingDeletion
+	}
+
+	if err := a.runPreLogin(ctx, log, user, appID, amr); err != nil {
+		return models.TokenPair{}, err
 	}
 
 	app, err := a.appProvider.App(ctx, appID)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, storage.ErrAppDisabled) {
+			log.Warn("app is disabled", sl.Err(err))
+			return models.TokenPair{}, ErrAppDisabled
+		}
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.TokenPair{}, ErrInvalidAppID
+		}
+
+		return models.TokenPair{}, err
+	}
+	if app.Status == models.AppStatusDeprecated {
+		log.Warn("login to a deprecated app")
 	}
 
-	token, err = jwt.NewToken(user, app, a.tokenTTL)
+	// apps of the default org are open to users of every org
+	if app.OrgID != models.DefaultOrgID && user.OrgID != app.OrgID {
+		log.Warn("user does not belong to the org of the app", slog.Int64("org_id", app.OrgID))
+		return models.TokenPair{}, ErrOrgMismatch
+	}
+
+	granted, err := grantScopes(app, scopes)
 	if err != nil {
-		a.log.Error("failed to generate token", err.Error())
+		log.Warn("scope is not allowed for the app", slog.Any("scopes", scopes))
+		return models.TokenPair{}, err
+	}
 
-		return "", fmt.Errorf("%s: %w", op, err)
+	// checked before the second factor too, so that users do not pass it
+	// in vain
+	if err = a.checkConsent(ctx, int64(user.ID), app, granted); err != nil {
+		if errors.Is(err, ErrConsentRequired) {
+			log.Warn("user has not consented to the scopes", slog.Any("scopes", granted))
+			return models.TokenPair{}, err
+		}
+
+		log.Error("failed to check consent", sl.Err(err))
+		return models.TokenPair{}, err
+	}
+
+	mfa, err := a.mfaEnabled(ctx, int64(user.ID))
+	if err != nil {
+		log.Error("failed to check mfa", sl.Err(err))
+		return models.TokenPair{}, err
+	}
+	if mfa {
+		trusted, err := a.trustedDevice(ctx, log, int64(user.ID))
+		if err != nil {
+			log.Error("failed to check trusted device", sl.Err(err))
+			return models.TokenPair{}, err
+		}
+		if trusted {
+			log.Info("second factor skipped on trusted device")
+		}
+		mfa = !trusted
+	}
+	if mfa {
+		mfaToken, err := a.startMFAChallenge(ctx, int64(user.ID), app.ID, granted, amr)
+		if err != nil {
+			log.Error("failed to start mfa challenge", sl.Err(err))
+			return models.TokenPair{}, err
+		}
+
+		log.Info("user needs a second factor")
+
+		return models.TokenPair{MFAToken: mfaToken}, nil
+	}
+
+	if user.PasswordChangeRequired {
+		return a.passwordChangeToken(ctx, log, user)
+	}
+
+	familyID, _, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate token family", sl.Err(err))
+		return models.TokenPair{}, err
+	}
+
+	pair, err := a.issueTokens(ctx, user, app, granted, amr, familyID, nil)
+	if err != nil {
+		log.Error("failed to issue tokens", sl.Err(err))
+		return models.TokenPair{}, err
 	}
 
 	log.Info("user logged in successfully")
 
-	return token, nil
+	return pair, nil
+}
+
+// revokeReusedFamily handles presentation of an already rotated refresh token,
+// which means it has leaked: the whole token family is revoked.
+func (a *Auth) revokeReusedFamily(ctx context.Context, log *slog.Logger, token models.RefreshToken) error {
+	a.audit(ctx, log, models.AuditEvent{
+		Type:   models.AuditRefreshTokenReuse,
+		UserID: token.UserID,
+		AppID:  token.AppID,
+	})
+	log.Warn("refresh token reuse detected, revoking token family")
+
+	if err := a.tokenStorage.RevokeRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+		log.Error("failed to revoke token family", sl.Err(err))
+		return err
+	}
+
+	return ErrInvalidToken
+}
+
+// audit records the event in the audit log. A failure to record it is logged
+// and does not fail the operation the event belongs to.
+func (a *Auth) audit(ctx context.Context, log *slog.Logger, event models.AuditEvent) {
+	event.CreatedAt = time.Now()
+
+	log.Warn("security event",
+		slog.String("event", string(event.Type)),
+		slog.String("token_id", event.TokenID),
+	)
+
+	if err := a.auditLog.SaveAuditEvent(ctx, event); err != nil {
+		log.Error("failed to save audit event", sl.Err(err))
+	}
+}
+
+// issueTokens mints an access token, an ID token and a refresh token of
+// the given family for a user authenticated with the amr methods.
+// If previous is set, it is rotated in favour of the new refresh token and
+// the tokens keep its auth time. Third-party apps only get tokens with the
+// scopes the user consented to, otherwise issueTokens fails with
+// ErrConsentRequired.
+func (a *Auth) issueTokens(
+	ctx context.Context,
+	user models.User,
+	app models.App,
+	scopes []string,
+	amr []string,
+	familyID string,
+	previous *models.RefreshToken,
+) (models.TokenPair, error) {
+	if err := a.checkConsent(ctx, int64(user.ID), app, scopes); err != nil {
+		return models.TokenPair{}, err
+	}
+
+	accessTTL := a.accessTokenTTL(app)
+
+	authTime := time.Now()
+	if previous != nil {
+		authTime = previous.AuthTime
+	}
+
+	if slices.Contains(scopes, tokens.ScopeGroups) {
+		groups, err := a.userProvider.UserGroups(ctx, int64(user.ID))
+		if err != nil {
+			return models.TokenPair{}, err
+		}
+		for _, group := range groups {
+			user.Groups = append(user.Groups, group.Name)
+		}
+	}
+
+	if slices.Contains(scopes, tokens.ScopeRoles) {
+		if err := a.loadRoles(ctx, &user, app.ID); err != nil {
+			return models.TokenPair{}, err
+		}
+	}
+
+	accessToken, err := a.tokens.NewToken(ctx, user, app, scopes, accessTTL, authTime, amr)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	idToken, err := a.tokens.NewIDToken(user, app, authTime, amr, accessTTL)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	refreshToken, refreshHash, err := randtoken.New()
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	now := time.Now()
+	record := models.RefreshToken{
+		TokenHash:        refreshHash,
+		FamilyID:         familyID,
+		UserID:           int64(user.ID),
+		AppID:            app.ID,
+		Scopes:           scopes,
+		AuthMethods:      amr,
+		ExpiresAt:        now.Add(a.refreshTokenTTL(app)),
+		CreatedAt:        now,
+		SessionStartedAt: now,
+		AuthTime:         authTime,
+	}
+	if previous != nil {
+		record.SessionStartedAt = previous.SessionStartedAt
+		record.ExpiresAt = a.rotatedRefreshTokenExpiry(app, *previous, now)
+	}
+
+	if previous != nil {
+		err = a.tokenStorage.RotateRefreshToken(ctx, previous.ID, record)
+	} else {
+		err = a.tokenStorage.SaveRefreshToken(ctx, record)
+	}
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	if err = a.trackSession(ctx, user, record, previous != nil); err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    accessTTL,
+		IDToken:      idToken,
+		Scopes:       scopes,
+	}, nil
+}
+
+// loadRoles sets the roles the user has within the app, the admin role first
+// if the user administers the app.
+func (a *Auth) loadRoles(ctx context.Context, user *models.User, appID int) error {
+	isAdmin, err := a.userProvider.IsAppAdmin(ctx, int64(user.ID), appID)
+	if err != nil {
+		return err
+	}
+	if isAdmin {
+		user.Roles = append(user.Roles, RoleAdmin)
+	}
+
+	roles, err := a.userProvider.UserRoles(ctx, int64(user.ID), appID)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if role.Name != RoleAdmin {
+			user.Roles = append(user.Roles, role.Name)
+		}
+	}
+
+	return nil
+}
+
+// assignDefaultRoles assigns the default roles to the registered user.
+// Failures are logged and do not fail the registration, as the user exists
+// already.
+func (a *Auth) assignDefaultRoles(ctx context.Context, log *slog.Logger, userID int64) {
+	for _, role := range a.cfg.DefaultRoles {
+		if err := a.userSaver.AssignRole(ctx, userID, role.Role, role.AppID, 0, time.Now()); err != nil {
+			log.Error("failed to assign default role",
+				slog.String("role", role.Role),
+				slog.Int("app_id", role.AppID),
+				sl.Err(err),
+			)
+		}
+	}
+}
+
+// grantingApp returns the app if it may get tokens with the grant type. It
+// fails with ErrInvalidAppID if there is no such app, with ErrAppDisabled if
+// it is disabled and with ErrUnauthorizedClient if the grant is not allowed.
+func (a *Auth) grantingApp(ctx context.Context, log *slog.Logger, appID int, grant string) (models.App, error) {
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppDisabled) {
+			log.Warn("app is disabled", sl.Err(err))
+			return models.App{}, ErrAppDisabled
+		}
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.App{}, ErrInvalidAppID
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return models.App{}, err
+	}
+
+	if err = checkGrant(log, app, grant); err != nil {
+		return models.App{}, err
+	}
+
+	return app, nil
+}
+
+// checkGrant fails with ErrUnauthorizedClient if the app may not get tokens
+// with the grant type.
+func checkGrant(log *slog.Logger, app models.App, grant string) error {
+	if !app.AllowsGrant(grant) {
+		log.Warn("grant type is not allowed for the app", slog.String("grant_type", grant))
+		return ErrUnauthorizedClient
+	}
+
+	return nil
+}
+
+// grantScopes checks that every requested scope is allowed for the app and
+// returns them without duplicates.
+func grantScopes(app models.App, requested []string) ([]string, error) {
+	allowed := make(map[string]struct{}, len(app.Scopes))
+	for _, scope := range app.Scopes {
+		allowed[scope] = struct{}{}
+	}
+
+	var granted []string
+	seen := make(map[string]struct{}, len(requested))
+	for _, scope := range requested {
+		if _, ok := allowed[scope]; !ok {
+			return nil, ErrInvalidScope
+		}
+		if _, ok := seen[scope]; ok {
+			continue
+		}
+		seen[scope] = struct{}{}
+		granted = append(granted, scope)
+	}
+
+	return granted, nil
 }
 
-func (a *Auth) RegisterNewUser(ctx context.Context, email string, password string) (userID int64, err error) {
+// RegisterNewUser creates a user with the password and the optional
+// profile, and sends the email verification link. It fails with
+// ErrInvalidProfile if a profile field is not acceptable.
+func (a *Auth) RegisterNewUser(
+	ctx context.Context,
+	email string,
+	password string,
+	userProfile models.UserProfile,
+) (userID int64, err error) {
 	const op = "services.auth.RegisterNewUser"
 	log := a.log.With(
 		slog.String("op", op),
@@ -107,25 +1046,52 @@ func (a *Auth) RegisterNewUser(ctx context.Context, email string, password strin
 
 	log.Info("registering user")
 
+	if a.cfg.InvitationOnly {
+		log.Warn("registration without an invitation")
+		return 0, fmt.Errorf("%s: %w", op, ErrRegistrationClosed)
+	}
+
+	userProfile, err = profile.Normalize(userProfile)
+	if err != nil {
+		log.Warn("invalid profile", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w: %s", op, ErrInvalidProfile, err.Error())
+	}
+
+	if err = a.checkPasswordPolicy(ctx, log, password); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	reg := Registration{Email: email, Profile: userProfile}
+	if err = a.runPreRegister(ctx, log, &reg); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
 	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		log.Error("failed to generate password hash", err.Error())
+		log.Error("failed to generate password hash", sl.Err(err))
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	userID, err = a.userSaver.SaveUser(ctx, email, passHash)
+	userID, err = a.userSaver.SaveUser(ctx, email, passHash, userProfile)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
-			log.Warn("user already exists", err.Error())
+			log.Warn("user already exists", sl.Err(err))
 
 			return 0, fmt.Errorf("%s: %w", op, ErrUserExists)
 		}
-		log.Error("failed to save user", err.Error())
+		log.Error("failed to save user", sl.Err(err))
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
 	log.Info("user registered")
 
+	a.runPostRegister(ctx, log, userID, reg)
+
+	if err = a.sendVerificationEmail(ctx, userID, email); err != nil {
+		// the user can still ask for the email again
+		log.Error("failed to send verification email", sl.Err(err))
+	}
+
 	return userID, nil
 }
 
@@ -142,8 +1108,8 @@ func (a *Auth) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 	isAdmin, err := a.userProvider.IsAdmin(ctx, userID)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
-			log.Warn("invalid credentials", err.Error())
-			return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+			log.Warn("user not found", sl.Err(err))
+			return false, fmt.Errorf("%s: %w", op, ErrUserNotFound)
 		}
 
 		return false, fmt.Errorf("%s: %w", op, err)
@@ -153,3 +1119,67 @@ func (a *Auth) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 
 	return isAdmin, nil
 }
+
+// UserRoles returns the names of the roles the user has within the app, the
+// admin role first if the user administers the app.
+func (a *Auth) UserRoles(ctx context.Context, userID int64, appID int) ([]string, error) {
+	const op = "services.auth.UserRoles"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int("app_id", appID),
+	)
+
+	user := models.User{ID: int(userID)}
+	if err := a.loadRoles(ctx, &user, appID); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get roles", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user.Roles, nil
+}
+
+// accessTokenTTL returns the access token lifetime of the app,
+// falling back to the global one.
+func (a *Auth) accessTokenTTL(app models.App) time.Duration {
+	if app.AccessTokenTTL > 0 {
+		return app.AccessTokenTTL
+	}
+
+	return a.cfg.TokenTTL
+}
+
+// rotatedRefreshTokenExpiry returns when the successor of the refresh token
+// expires. Sessions have a fixed lifetime unless sliding sessions are
+// enabled: then every refresh extends the session by the refresh token
+// lifetime, up to SessionMaxAge since the login.
+func (a *Auth) rotatedRefreshTokenExpiry(app models.App, previous models.RefreshToken, now time.Time) time.Time {
+	if !a.cfg.SlidingSessions {
+		return previous.ExpiresAt
+	}
+
+	expiresAt := now.Add(a.refreshTokenTTL(app))
+	if a.cfg.SessionMaxAge > 0 {
+		if limit := previous.SessionStartedAt.Add(a.cfg.SessionMaxAge); expiresAt.After(limit) {
+			expiresAt = limit
+		}
+	}
+
+	return expiresAt
+}
+
+// refreshTokenTTL returns the refresh token lifetime of the app,
+// falling back to the global one.
+func (a *Auth) refreshTokenTTL(app models.App) time.Duration {
+	if app.RefreshTokenTTL > 0 {
+		return app.RefreshTokenTTL
+	}
+
+	return a.cfg.RefreshTokenTTL
+}
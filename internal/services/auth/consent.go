@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"time"
+)
+
+var (
+	ErrConsentRequired = errors.New("consent required")
+	ErrConsentNotFound = errors.New("consent not found")
+)
+
+// GrantConsent lets the third-party app get tokens of the user with the
+// scopes, on top of the scopes the user already consented to. Scopes must
+// all be allowed for the app, otherwise GrantConsent fails with
+// ErrInvalidScope.
+func (a *Auth) GrantConsent(ctx context.Context, userID int64, appID int, scopes []string) (models.Consent, error) {
+	const op = "services.auth.GrantConsent"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int("app_id", appID),
+	)
+
+	log.Info("granting consent")
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.Consent{}, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return models.Consent{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	granted, err := grantScopes(app, scopes)
+	if err != nil {
+		log.Warn("scope is not allowed for the app", slog.Any("scopes", scopes))
+		return models.Consent{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	consent, err := a.consents.Consent(ctx, userID, appID)
+	if err != nil && !errors.Is(err, storage.ErrConsentNotFound) {
+		log.Error("failed to get consent", sl.Err(err))
+		return models.Consent{}, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, scope := range granted {
+		if !slices.Contains(consent.Scopes, scope) {
+			consent.Scopes = append(consent.Scopes, scope)
+		}
+	}
+	consent.UserID = userID
+	consent.AppID = app.ID
+	consent.AppName = app.Name
+	consent.GrantedAt = time.Now()
+
+	if err = a.consents.SaveConsent(ctx, consent); err != nil {
+		log.Error("failed to save consent", sl.Err(err))
+		return models.Consent{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("consent granted", slog.Any("scopes", consent.Scopes))
+
+	a.audit(ctx, log, models.AuditEvent{
+		Type:   models.AuditConsentGranted,
+		UserID: userID,
+		AppID:  app.ID,
+	})
+
+	return consent, nil
+}
+
+// RevokeConsent withdraws the consent of the user to the app, which then
+// gets no new tokens of the user, refreshed ones included. It fails with
+// ErrConsentNotFound if the user has not consented to the app.
+func (a *Auth) RevokeConsent(ctx context.Context, userID int64, appID int) error {
+	const op = "services.auth.RevokeConsent"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int("app_id", appID),
+	)
+
+	log.Info("revoking consent")
+
+	if err := a.consents.DeleteConsent(ctx, userID, appID); err != nil {
+		if errors.Is(err, storage.ErrConsentNotFound) {
+			log.Warn("consent not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrConsentNotFound)
+		}
+
+		log.Error("failed to delete consent", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("consent revoked")
+
+	a.audit(ctx, log, models.AuditEvent{
+		Type:   models.AuditConsentRevoked,
+		UserID: userID,
+		AppID:  appID,
+	})
+
+	return nil
+}
+
+// ListConsents returns the apps the user consented to, the most recently
+// granted first.
+func (a *Auth) ListConsents(ctx context.Context, userID int64) ([]models.Consent, error) {
+	const op = "services.auth.ListConsents"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	consents, err := a.consents.Consents(ctx, userID)
+	if err != nil {
+		log.Error("failed to get consents", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return consents, nil
+}
+
+// checkConsent fails with ErrConsentRequired unless the app is first-party
+// or the user consented to all the scopes.
+func (a *Auth) checkConsent(ctx context.Context, userID int64, app models.App, scopes []string) error {
+	if !app.ThirdParty {
+		return nil
+	}
+
+	consent, err := a.consents.Consent(ctx, userID, app.ID)
+	if err != nil {
+		if errors.Is(err, storage.ErrConsentNotFound) {
+			return ErrConsentRequired
+		}
+		return err
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(consent.Scopes, scope) {
+			return ErrConsentRequired
+		}
+	}
+
+	return nil
+}
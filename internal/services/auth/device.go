@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/url"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAuthorizationPending = errors.New("authorization pending")
+	ErrSlowDown             = errors.New("polling too fast")
+	ErrAccessDenied         = errors.New("access denied")
+	ErrDeviceCodeExpired    = errors.New("device code expired")
+	ErrInvalidUserCode      = errors.New("invalid user code")
+)
+
+// userCodeAlphabet has no vowels, so user codes do not spell words,
+// and no characters that are easy to confuse.
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+const userCodeLen = 8
+
+// StartDeviceAuthorization starts the device authorization grant (RFC 8628)
+// for the app. The device shows the user code and polls DeviceToken with the
+// device code until the user approves or denies it.
+func (a *Auth) StartDeviceAuthorization(ctx context.Context, appID int) (models.DeviceCode, error) {
+	const op = "services.auth.StartDeviceAuthorization"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	log.Info("starting device authorization")
+
+	if _, err := a.grantingApp(ctx, log, appID, models.GrantDeviceCode); err != nil {
+		return models.DeviceCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deviceCode, deviceCodeHash, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate device code", sl.Err(err))
+		return models.DeviceCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userCode, err := newUserCode()
+	if err != nil {
+		log.Error("failed to generate user code", sl.Err(err))
+		return models.DeviceCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	err = a.deviceStore.SaveDeviceAuthorization(ctx, models.DeviceAuthorization{
+		DeviceCodeHash: deviceCodeHash,
+		UserCode:       userCode,
+		AppID:          appID,
+		Status:         models.DeviceAuthorizationPending,
+		ExpiresAt:      now.Add(a.cfg.DeviceCodeTTL),
+		CreatedAt:      now,
+	})
+	if err != nil {
+		log.Error("failed to save device authorization", sl.Err(err))
+		return models.DeviceCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("device authorization started")
+
+	return models.DeviceCode{
+		DeviceCode:              deviceCode,
+		UserCode:                formatUserCode(userCode),
+		VerificationURI:         a.cfg.VerificationURI,
+		VerificationURIComplete: a.cfg.VerificationURI + "?user_code=" + url.QueryEscape(formatUserCode(userCode)),
+		ExpiresIn:               a.cfg.DeviceCodeTTL,
+		Interval:                a.cfg.DevicePollInterval,
+	}, nil
+}
+
+// DeviceToken is polled by the device. It returns tokens once the user has
+// approved the device, and ErrAuthorizationPending until then.
+func (a *Auth) DeviceToken(ctx context.Context, deviceCode string, appID int) (models.TokenPair, error) {
+	const op = "services.auth.DeviceToken"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	auth, err := a.deviceStore.DeviceAuthorization(ctx, randtoken.Hash(deviceCode))
+	if err != nil {
+		if errors.Is(err, storage.ErrDeviceCodeNotFound) {
+			log.Warn("device code not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		log.Error("failed to get device authorization", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if auth.AppID != appID {
+		log.Warn("device code was issued for another app")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	now := time.Now()
+	if now.After(auth.ExpiresAt) {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrDeviceCodeExpired)
+	}
+
+	if err = a.deviceStore.TouchDeviceAuthorization(ctx, auth.ID, now); err != nil {
+		log.Error("failed to update device authorization", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if auth.LastPolledAt != nil && now.Sub(*auth.LastPolledAt) < a.cfg.DevicePollInterval {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrSlowDown)
+	}
+
+	switch auth.Status {
+	case models.DeviceAuthorizationPending:
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrAuthorizationPending)
+	case models.DeviceAuthorizationDenied:
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrAccessDenied)
+	case models.DeviceAuthorizationApproved:
+	default:
+		log.Warn("device code already used")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	err = a.deviceStore.UpdateDeviceAuthorizationStatus(ctx,
+		auth.ID,
+		models.DeviceAuthorizationApproved,
+		models.DeviceAuthorizationConsumed,
+		0,
+	)
+	if err != nil {
+		if errors.Is(err, storage.ErrDeviceCodeNotFound) {
+			log.Warn("device code already used")
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		log.Error("failed to consume device authorization", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, auth.UserID)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appProvider.App(ctx, auth.AppID)
+	if err != nil {
+		log.Error("failed to get app", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the grant may have been taken from the app since the authorization
+	// started
+	if err = checkGrant(log, app, models.GrantDeviceCode); err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	familyID, _, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate token family", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pair, err := a.issueTokens(ctx, user, app, nil, []string{amrPassword}, familyID, nil)
+	if err != nil {
+		log.Error("failed to issue tokens", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("device logged in", slog.Int64("user_id", auth.UserID))
+
+	return pair, nil
+}
+
+// VerifyDevice lets the user approve or deny the device showing the user code.
+// The user authenticates with email and password, and with a TOTP code if
+// MFA is enabled for the user.
+func (a *Auth) VerifyDevice(ctx context.Context, userCode string, email string, password string, otp string, approve bool) error {
+	const op = "services.auth.VerifyDevice"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	log.Info("verifying device")
+
+	user, err := a.checkCredentials(ctx, log, email, password)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.checkSecondFactor(ctx, log, int64(user.ID), otp); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	auth, err := a.deviceStore.DeviceAuthorizationByUserCode(ctx, normalizeUserCode(userCode))
+	if err != nil {
+		if errors.Is(err, storage.ErrDeviceCodeNotFound) {
+			log.Warn("user code not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidUserCode)
+		}
+
+		log.Error("failed to get device authorization", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if time.Now().After(auth.ExpiresAt) {
+		log.Warn("user code expired")
+		return fmt.Errorf("%s: %w", op, ErrInvalidUserCode)
+	}
+
+	status := models.DeviceAuthorizationDenied
+	if approve {
+		status = models.DeviceAuthorizationApproved
+	}
+
+	err = a.deviceStore.UpdateDeviceAuthorizationStatus(ctx, auth.ID, models.DeviceAuthorizationPending, status, int64(user.ID))
+	if err != nil {
+		if errors.Is(err, storage.ErrDeviceCodeNotFound) {
+			log.Warn("user code already used")
+			return fmt.Errorf("%s: %w", op, ErrInvalidUserCode)
+		}
+
+		log.Error("failed to update device authorization", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("device verified", slog.String("status", string(status)))
+
+	return nil
+}
+
+func newUserCode() (string, error) {
+	b := make([]byte, userCodeLen)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(userCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = userCodeAlphabet[n.Int64()]
+	}
+
+	return string(b), nil
+}
+
+// formatUserCode splits the code in two halves for readability: BCDF-GHJK.
+func formatUserCode(code string) string {
+	return code[:userCodeLen/2] + "-" + code[userCodeLen/2:]
+}
+
+// normalizeUserCode accepts user codes typed in any case, with or without dashes.
+func normalizeUserCode(code string) string {
+	code = strings.ToUpper(code)
+
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
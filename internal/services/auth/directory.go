@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/ldap"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"strings"
+)
+
+// RoleAdmin is the role of admin users.
+const RoleAdmin = "admin"
+
+// Directory verifies credentials against an external user directory.
+type Directory interface {
+	Authenticate(ctx context.Context, username string, password string) (ldap.Entry, error)
+}
+
+// AppDirectory is the directory the users of an app log in against instead
+// of with their local password.
+type AppDirectory struct {
+	Directory Directory
+	// Provision creates a local user on the first login of a directory
+	// user. Otherwise only users who already exist locally can log in.
+	Provision bool
+	// Roles maps the DNs of directory groups to the roles of their members.
+	// The roles of a user are updated on every login if set.
+	Roles map[string]string
+}
+
+// checkDirectoryCredentials authenticates the user against the directory and
+// returns the local user with the email of the directory entry.
+func (a *Auth) checkDirectoryCredentials(
+	ctx context.Context,
+	log *slog.Logger,
+	directory AppDirectory,
+	email string,
+	password string,
+) (models.User, error) {
+	entry, err := directory.Directory.Authenticate(ctx, email, password)
+	if err != nil {
+		if errors.Is(err, ldap.ErrInvalidCredentials) {
+			log.Warn("invalid directory credentials", sl.Err(err))
+			return models.User{}, ErrInvalidCredentials
+		}
+
+		log.Error("failed to authenticate against directory", sl.Err(err))
+		return models.User{}, err
+	}
+
+	log = log.With(slog.String("dn", entry.DN))
+
+	if entry.Email != "" {
+		email = entry.Email
+	}
+
+	user, err := a.userProvider.User(ctx, email)
+	switch {
+	case err == nil:
+		// as with identity providers, an unverified local account may have
+		// been registered by someone else to take over the directory user
+		if !user.EmailVerified {
+			log.Warn("email of the existing user is not verified")
+			return models.User{}, ErrEmailNotVerified
+		}
+	case errors.Is(err, storage.ErrUserNotFound):
+		if !directory.Provision {
+			log.Warn("directory user has no local user")
+			return models.User{}, ErrInvalidCredentials
+		}
+
+		user, err = a.provisionExternalUser(ctx, log, email)
+		if err != nil {
+			return models.User{}, err
+		}
+
+		log.Info("user created from directory entry")
+	default:
+		log.Error("failed to get user", sl.Err(err))
+		return models.User{}, err
+	}
+
+	if len(directory.Roles) > 0 {
+		isAdmin := hasRole(directory.Roles, entry.Groups, RoleAdmin)
+		if err = a.userSaver.SetAdmin(ctx, int64(user.ID), isAdmin); err != nil {
+			log.Error("failed to update roles", sl.Err(err))
+			return models.User{}, err
+		}
+	}
+
+	return user, nil
+}
+
+// hasRole reports whether one of the groups is mapped to the role. DNs are
+// compared case-insensitively.
+func hasRole(roles map[string]string, groups []string, role string) bool {
+	for group, mapped := range roles {
+		if mapped != role {
+			continue
+		}
+
+		for _, member := range groups {
+			if strings.EqualFold(member, group) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
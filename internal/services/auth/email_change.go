@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/email"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/passhash"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage"
+	"time"
+)
+
+var ErrInvalidEmailChangeToken = errors.New("invalid email change token")
+
+// RequestEmailChange sends a confirmation link to the new email and notifies
+// the current one. The user must enter the password again, so a stolen access
+// token is not enough to take the account over. It fails with
+// ErrInvalidCredentials if the password does not match and with ErrUserExists
+// if the new email is taken.
+func (a *Auth) RequestEmailChange(ctx context.Context, userID int64, password string, newEmail string) error {
+	const op = "services.auth.RequestEmailChange"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("requesting email change")
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = passhash.Compare(user.PassHash, password); err != nil {
+		log.Warn("invalid password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	_, err = a.userProvider.User(ctx, newEmail)
+	if err == nil {
+		log.Warn("email is taken")
+		return fmt.Errorf("%s: %w", op, ErrUserExists)
+	}
+	if !errors.Is(err, storage.ErrUserNotFound) {
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, hash, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate email change token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	err = a.emailChangeTokens.SaveEmailChangeToken(ctx, models.EmailChangeToken{
+		TokenHash: hash,
+		UserID:    userID,
+		NewEmail:  newEmail,
+		ExpiresAt: now.Add(a.cfg.EmailChangeTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		log.Error("failed to save email change token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.emailSender.Send(ctx, email.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email",
+		Body: "Follow the link to use this address for your account:\n" +
+			withToken(a.cfg.EmailChangeURL, token) + "\n",
+	})
+	if err != nil {
+		log.Error("failed to send email change confirmation", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.notify(ctx, log, userID, models.NotificationSecurity, email.Message{
+		To:      user.Email,
+		Subject: "Your email is being changed",
+		Body: "Someone asked to change the email of your account to " + newEmail + ".\n\n" +
+			"If it was not you, reset your password.\n",
+	})
+	if err != nil {
+		log.Error("failed to send email change notification", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("email change requested")
+
+	return nil
+}
+
+// ConfirmEmailChange changes the email of the user to the address the token
+// was sent to. It fails with ErrInvalidEmailChangeToken if the token is
+// unknown, used or expired, and with ErrUserExists if the email has been taken
+// since the request.
+func (a *Auth) ConfirmEmailChange(ctx context.Context, token string) error {
+	const op = "services.auth.ConfirmEmailChange"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("confirming email change")
+
+	change, err := a.emailChangeTokens.EmailChangeToken(ctx, randtoken.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrEmailChangeTokenNotFound) {
+			log.Warn("email change token not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidEmailChangeToken)
+		}
+
+		log.Error("failed to get email change token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", change.UserID))
+
+	if time.Now().After(change.ExpiresAt) {
+		log.Warn("email change token is expired")
+		return fmt.Errorf("%s: %w", op, ErrInvalidEmailChangeToken)
+	}
+
+	if err = a.emailChangeTokens.UseEmailChangeToken(ctx, change.ID); err != nil {
+		if errors.Is(err, storage.ErrEmailChangeTokenUsed) {
+			log.Warn("email change token already used", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidEmailChangeToken)
+		}
+
+		log.Error("failed to use email change token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.userSaver.UpdateEmail(ctx, change.UserID, change.NewEmail); err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			log.Warn("email is taken", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserExists)
+		}
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidEmailChangeToken)
+		}
+
+		log.Error("failed to update email", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("email changed")
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/email"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage"
+	"time"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid email verification token")
+	ErrEmailNotVerified         = errors.New("email is not verified")
+)
+
+// VerifyEmail marks the email the verification token was sent to as verified.
+// It fails with ErrInvalidVerificationToken if the token is unknown, used or
+// expired, or if the user has changed the email since.
+func (a *Auth) VerifyEmail(ctx context.Context, token string) error {
+	const op = "services.auth.VerifyEmail"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("verifying email")
+
+	verification, err := a.verificationTokens.EmailVerificationToken(ctx, randtoken.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrVerificationTokenNotFound) {
+			log.Warn("verification token not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidVerificationToken)
+		}
+
+		log.Error("failed to get verification token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", verification.UserID))
+
+	if time.Now().After(verification.ExpiresAt) {
+		log.Warn("verification token is expired")
+		return fmt.Errorf("%s: %w", op, ErrInvalidVerificationToken)
+	}
+
+	if err = a.verificationTokens.UseEmailVerificationToken(ctx, verification.ID); err != nil {
+		if errors.Is(err, storage.ErrVerificationTokenUsed) {
+			log.Warn("verification token already used", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidVerificationToken)
+		}
+
+		log.Error("failed to use verification token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.userSaver.SetEmailVerified(ctx, verification.UserID, verification.Email); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user email has changed", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidVerificationToken)
+		}
+
+		log.Error("failed to set email verified", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("email verified")
+
+	return nil
+}
+
+// sendVerificationEmail emails the user a link that proves owning the address.
+func (a *Auth) sendVerificationEmail(ctx context.Context, userID int64, address string) error {
+	token, hash, err := randtoken.New()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = a.verificationTokens.SaveEmailVerificationToken(ctx, models.EmailVerificationToken{
+		TokenHash: hash,
+		UserID:    userID,
+		Email:     address,
+		ExpiresAt: now.Add(a.cfg.EmailVerificationTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.emailSender.Send(ctx, email.Message{
+		To:      address,
+		Subject: "Verify your email",
+		Body: "Follow the link to confirm that this is your email address:\n" +
+			withToken(a.cfg.EmailVerificationURL, token) + "\n",
+	})
+}
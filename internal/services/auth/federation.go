@@ -0,0 +1,374 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/idp"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/randtoken"
+	"sso/internal/lib/redirecturi"
+	"sso/internal/storage"
+	"strings"
+	"sync"
+	"time"
+)
+
+// amrFederated is the amr value of logins at an upstream identity provider.
+// RFC 8176 has no value for it.
+const amrFederated = "fed"
+
+var (
+	ErrUnknownIdentityProvider = errors.New("unknown identity provider")
+	ErrInvalidFederationState  = errors.New("invalid federation state")
+	ErrFederationFailed        = errors.New("federated login failed")
+	ErrUnverifiedIdentity      = errors.New("identity provider did not verify the email")
+	ErrIdentityNotLinkable     = errors.New("email belongs to an account with an unverified email")
+	ErrInvalidRedirectURI      = errors.New("redirect uri is not registered for the app")
+)
+
+// StartFederatedLogin returns the URL of the provider's login page the user
+// is sent to. The login continues with FinishFederatedLogin when the
+// provider sends the user back. If the redirect URI is set, it must be one
+// registered for the app, or StartFederatedLogin fails with
+// ErrInvalidRedirectURI.
+func (a *Auth) StartFederatedLogin(
+	ctx context.Context,
+	provider string,
+	appID int,
+	scopes []string,
+	redirect models.ClientRedirect,
+) (string, error) {
+	const op = "services.auth.StartFederatedLogin"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("provider", provider),
+	)
+
+	log.Info("starting federated login")
+
+	upstream, err := a.identityProvider(ctx, log, provider)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	granted, err := grantScopes(app, scopes)
+	if err != nil {
+		log.Warn("scope is not allowed for the app", slog.Any("scopes", scopes))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if redirect.URI != "" && !redirecturi.Match(app.RedirectURIs, redirect.URI) {
+		log.Warn("redirect uri is not registered for the app", slog.String("redirect_uri", redirect.URI))
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidRedirectURI)
+	}
+	state, stateHash, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate state", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	nonce, _, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate nonce", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	verifier, _, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate code verifier", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	err = a.federation.SaveFederationState(ctx, models.FederationState{
+		StateHash:    stateHash,
+		Provider:     provider,
+		AppID:        app.ID,
+		Scopes:       granted,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		Redirect:     redirect,
+		ExpiresAt:    now.Add(a.cfg.FederationStateTTL),
+		CreatedAt:    now,
+	})
+	if err != nil {
+		log.Error("failed to save federation state", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return upstream.AuthCodeURL(state, nonce, verifier), nil
+}
+
+// FinishFederatedLogin exchanges the authorization code the provider sent the
+// user back with and issues tokens of the app the login was started for.
+// The user linked to the upstream identity is logged in. Otherwise the
+// identity is linked to the user with the same email, or a user without a
+// password is created, provided the provider has verified the email. The
+// redirect the login was started with is returned along with the tokens.
+func (a *Auth) FinishFederatedLogin(
+	ctx context.Context,
+	provider string,
+	state string,
+	code string,
+) (models.TokenPair, models.ClientRedirect, error) {
+	const op = "services.auth.FinishFederatedLogin"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("provider", provider),
+	)
+
+	log.Info("finishing federated login")
+
+	upstream, err := a.identityProvider(ctx, log, provider)
+	if err != nil {
+		return models.TokenPair{}, models.ClientRedirect{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	login, err := a.federation.FederationState(ctx, randtoken.Hash(state))
+	if err != nil {
+		if errors.Is(err, storage.ErrFederationStateNotFound) {
+			log.Warn("federation state not found", sl.Err(err))
+			return models.TokenPair{}, models.ClientRedirect{}, fmt.Errorf("%s: %w", op, ErrInvalidFederationState)
+		}
+
+		log.Error("failed to get federation state", sl.Err(err))
+		return models.TokenPair{}, models.ClientRedirect{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if login.Provider != provider || time.Now().After(login.ExpiresAt) {
+		log.Warn("federation state is not valid for the callback")
+		return models.TokenPair{}, models.ClientRedirect{}, fmt.Errorf("%s: %w", op, ErrInvalidFederationState)
+	}
+
+	if err = a.federation.UseFederationState(ctx, login.ID); err != nil {
+		if errors.Is(err, storage.ErrFederationStateUsed) {
+			log.Warn("federation state already used", sl.Err(err))
+			return models.TokenPair{}, models.ClientRedirect{}, fmt.Errorf("%s: %w", op, ErrInvalidFederationState)
+		}
+
+		log.Error("failed to use federation state", sl.Err(err))
+		return models.TokenPair{}, models.ClientRedirect{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	identity, err := upstream.Exchange(ctx, code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		log.Warn("failed to exchange authorization code", sl.Err(err))
+		return models.TokenPair{}, models.ClientRedirect{}, fmt.Errorf("%s: %w: %s", op, ErrFederationFailed, err.Error())
+	}
+
+	log = log.With(slog.String("subject", identity.Subject))
+
+	user, err := a.federatedUser(ctx, log, provider, identity)
+	if err != nil {
+		return models.TokenPair{}, models.ClientRedirect{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", int64(user.ID)))
+
+	pair, err := a.login(ctx, log, user, login.AppID, login.Scopes, []string{amrFederated})
+	if err != nil {
+		return models.TokenPair{}, models.ClientRedirect{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pair, login.Redirect, nil
+}
+
+// identityProvider returns the provider configured with the name, or else
+// the one stored by an operator. Stored providers are discovered from their
+// issuer once and kept until their configuration changes.
+func (a *Auth) identityProvider(ctx context.Context, log *slog.Logger, name string) (IdentityProvider, error) {
+	if upstream, ok := a.identityProviders[name]; ok {
+		return upstream, nil
+	}
+
+	stored, err := a.federation.IdentityProvider(ctx, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrIdentityProviderNotFound) {
+			log.Warn("identity provider not found", sl.Err(err))
+			return nil, ErrUnknownIdentityProvider
+		}
+
+		log.Error("failed to get identity provider", sl.Err(err))
+		return nil, err
+	}
+
+	if upstream, ok := a.upstreams.get(stored); ok {
+		return upstream, nil
+	}
+
+	upstream, err := idp.Discover(ctx, stored.Issuer, idp.Config{
+		ClientID:     stored.ClientID,
+		ClientSecret: stored.ClientSecret,
+		RedirectURL:  strings.TrimSuffix(a.cfg.FederationRedirectBaseURL, "/") + "/" + name + "/callback",
+		Scopes:       stored.Scopes,
+		Claims: idp.ClaimNames{
+			Subject:       stored.Claims.Subject,
+			Email:         stored.Claims.Email,
+			EmailVerified: stored.Claims.EmailVerified,
+			Name:          stored.Claims.Name,
+		},
+	})
+	if err != nil {
+		log.Error("failed to discover identity provider", sl.Err(err))
+		return nil, fmt.Errorf("%w: %s", ErrFederationFailed, err.Error())
+	}
+
+	a.upstreams.put(stored, upstream)
+
+	return upstream, nil
+}
+
+// federatedUser returns the user linked to the upstream identity, linking or
+// creating one on the first login.
+func (a *Auth) federatedUser(
+	ctx context.Context,
+	log *slog.Logger,
+	provider string,
+	identity idp.Identity,
+) (models.User, error) {
+	linked, err := a.federation.UserIdentity(ctx, provider, identity.Subject)
+	if err == nil {
+		user, err := a.userProvider.UserByID(ctx, linked.UserID)
+		if err != nil {
+			log.Error("failed to get linked user", sl.Err(err))
+			return models.User{}, err
+		}
+
+		return user, nil
+	}
+	if !errors.Is(err, storage.ErrUserIdentityNotFound) {
+		log.Error("failed to get user identity", sl.Err(err))
+		return models.User{}, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		log.Warn("identity has no verified email")
+		return models.User{}, ErrUnverifiedIdentity
+	}
+
+	user, err := a.userProvider.User(ctx, identity.Email)
+	switch {
+	case err == nil:
+		// Whoever registered an unverified email may know the password of
+		// the account, so the owner of the email must verify it first.
+		if !user.EmailVerified {
+			log.Warn("email of the existing user is not verified")
+			return models.User{}, ErrIdentityNotLinkable
+		}
+
+		log.Info("linking identity to existing user")
+	case errors.Is(err, storage.ErrUserNotFound):
+		if a.cfg.InvitationOnly {
+			log.Warn("sign-up through identity provider without an invitation")
+			return models.User{}, ErrRegistrationClosed
+		}
+
+		user, err = a.provisionExternalUser(ctx, log, identity.Email)
+		if err != nil {
+			return models.User{}, err
+		}
+
+		log.Info("user created from identity")
+	default:
+		log.Error("failed to get user", sl.Err(err))
+		return models.User{}, err
+	}
+
+	err = a.federation.SaveUserIdentity(ctx, models.UserIdentity{
+		UserID:    int64(user.ID),
+		Provider:  provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		log.Error("failed to link identity", sl.Err(err))
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// provisionExternalUser creates a user who can only log in through identity
+// providers or directories until setting a password with a password reset.
+// The email is marked as verified on the word of the provider or directory.
+func (a *Auth) provisionExternalUser(ctx context.Context, log *slog.Logger, email string) (models.User, error) {
+	reg := Registration{Email: email}
+	if err := a.runPreRegister(ctx, log, &reg); err != nil {
+		return models.User{}, err
+	}
+
+	id, err := a.userSaver.SaveUser(ctx, email, []byte{}, models.UserProfile{})
+	if err != nil {
+		log.Error("failed to create user", sl.Err(err))
+		return models.User{}, err
+	}
+
+	if err = a.userSaver.SetEmailVerified(ctx, id, email); err != nil {
+		log.Error("failed to verify email", sl.Err(err))
+		return models.User{}, err
+	}
+
+	a.runPostRegister(ctx, log, id, reg)
+
+	return a.userProvider.UserByID(ctx, id)
+}
+
+// upstreamCache keeps the discovered identity providers stored by operators
+// together with the configuration they were discovered with.
+type upstreamCache struct {
+	mu        sync.Mutex
+	providers map[string]cachedUpstream
+}
+
+type cachedUpstream struct {
+	config   models.IdentityProvider
+	provider *idp.Provider
+}
+
+func newUpstreamCache() *upstreamCache {
+	return &upstreamCache{providers: make(map[string]cachedUpstream)}
+}
+
+// get returns the provider if it was discovered with the same configuration.
+func (c *upstreamCache) get(config models.IdentityProvider) (*idp.Provider, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.providers[config.Name]
+	if !ok || !sameIdentityProvider(cached.config, config) {
+		return nil, false
+	}
+
+	return cached.provider, true
+}
+
+func (c *upstreamCache) put(config models.IdentityProvider, provider *idp.Provider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.providers[config.Name] = cachedUpstream{config: config, provider: provider}
+}
+
+func sameIdentityProvider(a models.IdentityProvider, b models.IdentityProvider) bool {
+	return a.ID == b.ID &&
+		a.Issuer == b.Issuer &&
+		a.ClientID == b.ClientID &&
+		a.ClientSecret == b.ClientSecret &&
+		slices.Equal(a.Scopes, b.Scopes) &&
+		a.Claims == b.Claims
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"strings"
+)
+
+var ErrNotGuest = errors.New("user is not a guest")
+
+// CreateGuest creates an anonymous user and logs it in to the app, so that
+// apps can let people try them before registering. The guest keeps its
+// tokens fresh with the refresh token and becomes a full user with
+// UpgradeGuest. Guests are users too, so they can not be created while
+// registration is by invitation only.
+func (a *Auth) CreateGuest(ctx context.Context, appID int, scopes []string) (models.TokenPair, error) {
+	const op = "services.auth.CreateGuest"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	log.Info("creating guest")
+
+	if a.cfg.InvitationOnly {
+		log.Warn("guest without an invitation")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrRegistrationClosed)
+	}
+
+	// checked first, so that failed requests do not leave guests behind
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err = grantScopes(app, scopes); err != nil {
+		log.Warn("scope is not allowed for the app", slog.Any("scopes", scopes))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err := a.userSaver.SaveGuest(ctx)
+	if err != nil {
+		log.Error("failed to save guest", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", userID))
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		log.Error("failed to get guest", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the guest has proven nothing, so the tokens carry no amr
+	pair, err := a.login(ctx, log, user, appID, scopes, nil)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("guest created")
+
+	return pair, nil
+}
+
+// UpgradeGuest attaches the email and the password to the guest, which
+// becomes a full user with the same id. The email has to be verified like
+// the one of a registered user. It fails with ErrNotGuest if the user is
+// not a guest and with ErrUserExists if the email is taken.
+func (a *Auth) UpgradeGuest(ctx context.Context, userID int64, email string, password string) error {
+	const op = "services.auth.UpgradeGuest"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.String("email", email),
+	)
+
+	log.Info("upgrading guest")
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !user.IsGuest {
+		log.Warn("user is not a guest")
+		return fmt.Errorf("%s: %w", op, ErrNotGuest)
+	}
+
+	if err = a.checkPasswordPolicy(ctx, log, password); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Error("failed to generate password hash", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	email = strings.TrimSpace(email)
+
+	reg := Registration{Email: email}
+	if err = a.runPreRegister(ctx, log, &reg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.userSaver.UpgradeGuest(ctx, userID, email, passHash); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserExists):
+			log.Warn("user already exists", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserExists)
+		case errors.Is(err, storage.ErrUserNotFound):
+			// upgraded by a concurrent request
+			log.Warn("user is not a guest", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrNotGuest)
+		}
+
+		log.Error("failed to upgrade guest", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("guest upgraded")
+
+	a.runPostRegister(ctx, log, userID, reg)
+
+	if err = a.sendVerificationEmail(ctx, userID, email); err != nil {
+		// the user can still ask for the email again
+		log.Error("failed to send verification email", sl.Err(err))
+	}
+
+	return nil
+}
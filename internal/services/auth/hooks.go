@@ -0,0 +1,387 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/metadata"
+	"strings"
+)
+
+// ErrRejected is returned when a hook rejects a registration or a login.
+var ErrRejected = errors.New("rejected by hook")
+
+// RejectedError tells why a hook rejected a registration or a login. It
+// matches ErrRejected.
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return ErrRejected.Error() + ": " + e.Reason
+}
+
+func (e *RejectedError) Unwrap() error {
+	return ErrRejected
+}
+
+// Reject returns the error hooks reject a registration or a login with. The
+// reason is shown to the user.
+func Reject(reason string) error {
+	return &RejectedError{Reason: reason}
+}
+
+// Registration is a user about to be created by signing up, upgrading a
+// guest or logging in through an identity provider or a directory for the
+// first time.
+type Registration struct {
+	Email   string
+	Profile models.UserProfile
+	// AppMetadata is set as the app metadata of the user once it is created,
+	// so that pre-register hooks can enrich users.
+	AppMetadata map[string]string
+}
+
+// LoginAttempt is a user about to log in to the app, having passed the first
+// factor with the amr methods.
+type LoginAttempt struct {
+	User    models.User
+	AppID   int
+	Methods []string
+	Client  clientinfo.Client
+}
+
+// PreRegisterHook runs before a user is created. It may set the app
+// metadata of the registration and fails the registration by returning an
+// error, a RejectedError to tell the user why.
+type PreRegisterHook func(ctx context.Context, reg *Registration) error
+
+// PostRegisterHook runs once the user is created. Its errors are logged and
+// do not fail the registration.
+type PostRegisterHook func(ctx context.Context, userID int64, reg Registration) error
+
+// PreLoginHook runs before tokens are issued or the second factor is asked
+// for. It fails the login by returning an error, a RejectedError to tell the
+// user why.
+type PreLoginHook func(ctx context.Context, attempt LoginAttempt) error
+
+// Actions of org membership events.
+const (
+	OrgInvited            = "invited"
+	OrgInvitationDeclined = "invitation_declined"
+	OrgJoined             = "joined"
+	OrgRoleChanged        = "role_changed"
+	OrgMemberRemoved      = "removed"
+)
+
+// OrgMembershipEvent is a change to the members of an org, made by the
+// actor: an admin, or the user accepting an invitation. UserID is zero for
+// invitations, which name the invited email instead.
+type OrgMembershipEvent struct {
+	Action  string
+	OrgID   int64
+	UserID  int64
+	Email   string
+	Role    string
+	ActorID int64
+}
+
+// OrgMembershipHook runs once the members of an org have changed. Its
+// errors are logged and do not fail the change.
+type OrgMembershipHook func(ctx context.Context, event OrgMembershipEvent) error
+
+// Hooks are the custom functions deployments run at points of the user
+// lifecycle, in the order they are added. Hooks are added before the
+// service starts and not after.
+type Hooks struct {
+	// FailOpen lets registrations and logins go on when a pre hook fails
+	// other than by rejecting them, as when a webhook is unavailable.
+	// Otherwise they fail with the hook.
+	FailOpen bool
+
+	preRegister   []PreRegisterHook
+	postRegister  []PostRegisterHook
+	preLogin      []PreLoginHook
+	orgMembership []OrgMembershipHook
+}
+
+func (h *Hooks) OnPreRegister(hook PreRegisterHook) {
+	h.preRegister = append(h.preRegister, hook)
+}
+
+func (h *Hooks) OnPostRegister(hook PostRegisterHook) {
+	h.postRegister = append(h.postRegister, hook)
+}
+
+func (h *Hooks) OnPreLogin(hook PreLoginHook) {
+	h.preLogin = append(h.preLogin, hook)
+}
+
+func (h *Hooks) OnOrgMembership(hook OrgMembershipHook) {
+	h.orgMembership = append(h.orgMembership, hook)
+}
+
+// AllowEmailDomains rejects registrations with emails outside the domains.
+// Domains are matched case-insensitively.
+func AllowEmailDomains(domains []string) PreRegisterHook {
+	allowed := make(map[string]struct{}, len(domains))
+	for _, domain := range domains {
+		allowed[strings.ToLower(strings.TrimSpace(domain))] = struct{}{}
+	}
+
+	return func(ctx context.Context, reg *Registration) error {
+		if _, ok := allowed[emailDomain(reg.Email)]; !ok {
+			return Reject("email domain is not allowed")
+		}
+
+		return nil
+	}
+}
+
+// BlockEmailDomains rejects registrations with emails of the domains, such as
+// disposable email services. Domains are matched case-insensitively.
+func BlockEmailDomains(domains []string) PreRegisterHook {
+	blocked := make(map[string]struct{}, len(domains))
+	for _, domain := range domains {
+		blocked[strings.ToLower(strings.TrimSpace(domain))] = struct{}{}
+	}
+
+	return func(ctx context.Context, reg *Registration) error {
+		if _, ok := blocked[emailDomain(reg.Email)]; ok {
+			return Reject("email domain is not allowed")
+		}
+
+		return nil
+	}
+}
+
+func emailDomain(email string) string {
+	_, domain, _ := strings.Cut(strings.TrimSpace(email), "@")
+
+	return strings.ToLower(domain)
+}
+
+// WebhookCaller posts events to a webhook, see webhook.Client.
+type WebhookCaller interface {
+	Call(ctx context.Context, event string, payload any, resp any) error
+}
+
+// Events webhooks are called with.
+const (
+	HookPreRegister   = "pre_register"
+	HookPostRegister  = "post_register"
+	HookPreLogin      = "pre_login"
+	HookOrgMembership = "org_membership"
+)
+
+type webhookRegistration struct {
+	UserID      int64             `json:"user_id,omitempty"`
+	Email       string            `json:"email"`
+	FirstName   string            `json:"first_name,omitempty"`
+	LastName    string            `json:"last_name,omitempty"`
+	DisplayName string            `json:"display_name,omitempty"`
+	Locale      string            `json:"locale,omitempty"`
+	AppMetadata map[string]string `json:"app_metadata,omitempty"`
+}
+
+type webhookLogin struct {
+	UserID    int64    `json:"user_id"`
+	Email     string   `json:"email"`
+	AppID     int      `json:"app_id"`
+	AMR       []string `json:"amr"`
+	IP        string   `json:"ip,omitempty"`
+	UserAgent string   `json:"user_agent,omitempty"`
+}
+
+type webhookOrgMembership struct {
+	Action  string `json:"action"`
+	OrgID   int64  `json:"org_id"`
+	UserID  int64  `json:"user_id,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Role    string `json:"role,omitempty"`
+	ActorID int64  `json:"actor_id,omitempty"`
+}
+
+// webhookDecision is what webhooks of pre hooks respond with. An empty
+// response allows the registration or the login.
+type webhookDecision struct {
+	Deny   bool   `json:"deny"`
+	Reason string `json:"reason"`
+	// AppMetadata is merged into the app metadata of registrations.
+	AppMetadata map[string]string `json:"app_metadata"`
+}
+
+// PreRegisterWebhook calls the webhook with the registration. The webhook
+// denies it with {"deny": true, "reason": "..."} and enriches it with
+// {"app_metadata": {...}}.
+func PreRegisterWebhook(caller WebhookCaller) PreRegisterHook {
+	return func(ctx context.Context, reg *Registration) error {
+		var decision webhookDecision
+		if err := caller.Call(ctx, HookPreRegister, newWebhookRegistration(0, *reg), &decision); err != nil {
+			return err
+		}
+		if decision.Deny {
+			return Reject(decision.Reason)
+		}
+
+		if len(decision.AppMetadata) > 0 {
+			if reg.AppMetadata == nil {
+				reg.AppMetadata = make(map[string]string, len(decision.AppMetadata))
+			}
+			maps.Copy(reg.AppMetadata, decision.AppMetadata)
+		}
+
+		return nil
+	}
+}
+
+// PostRegisterWebhook notifies the webhook of the created user.
+func PostRegisterWebhook(caller WebhookCaller) PostRegisterHook {
+	return func(ctx context.Context, userID int64, reg Registration) error {
+		return caller.Call(ctx, HookPostRegister, newWebhookRegistration(userID, reg), nil)
+	}
+}
+
+// PreLoginWebhook calls the webhook with the login, which it denies with
+// {"deny": true, "reason": "..."}.
+func PreLoginWebhook(caller WebhookCaller) PreLoginHook {
+	return func(ctx context.Context, attempt LoginAttempt) error {
+		login := webhookLogin{
+			UserID:    int64(attempt.User.ID),
+			Email:     attempt.User.Email,
+			AppID:     attempt.AppID,
+			AMR:       attempt.Methods,
+			IP:        attempt.Client.IP,
+			UserAgent: attempt.Client.UserAgent,
+		}
+
+		var decision webhookDecision
+		if err := caller.Call(ctx, HookPreLogin, login, &decision); err != nil {
+			return err
+		}
+		if decision.Deny {
+			return Reject(decision.Reason)
+		}
+
+		return nil
+	}
+}
+
+// OrgMembershipWebhook notifies the webhook of changes to the members of
+// orgs.
+func OrgMembershipWebhook(caller WebhookCaller) OrgMembershipHook {
+	return func(ctx context.Context, event OrgMembershipEvent) error {
+		return caller.Call(ctx, HookOrgMembership, webhookOrgMembership(event), nil)
+	}
+}
+
+func newWebhookRegistration(userID int64, reg Registration) webhookRegistration {
+	return webhookRegistration{
+		UserID:      userID,
+		Email:       reg.Email,
+		FirstName:   reg.Profile.FirstName,
+		LastName:    reg.Profile.LastName,
+		DisplayName: reg.Profile.DisplayName,
+		Locale:      reg.Profile.Locale,
+		AppMetadata: reg.AppMetadata,
+	}
+}
+
+// runPreRegister runs the pre-register hooks on the registration.
+func (a *Auth) runPreRegister(ctx context.Context, log *slog.Logger, reg *Registration) error {
+	if a.hooks == nil {
+		return nil
+	}
+
+	for _, hook := range a.hooks.preRegister {
+		if err := hook(ctx, reg); err != nil {
+			if errors.Is(err, ErrRejected) {
+				log.Warn("registration rejected by hook", sl.Err(err))
+				return err
+			}
+
+			log.Error("pre-register hook failed", sl.Err(err))
+			if !a.hooks.FailOpen {
+				return err
+			}
+		}
+	}
+
+	if err := metadata.Validate(reg.AppMetadata); err != nil {
+		log.Error("pre-register hooks set invalid app metadata", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// runPostRegister saves the app metadata the pre-register hooks set on the
+// registration of the created user, assigns the default roles and runs the
+// post-register hooks. Failures are logged and do not fail the
+// registration, as the user exists already.
+func (a *Auth) runPostRegister(ctx context.Context, log *slog.Logger, userID int64, reg Registration) {
+	a.assignDefaultRoles(ctx, log, userID)
+
+	if len(reg.AppMetadata) > 0 {
+		if err := a.userSaver.SetAppMetadata(ctx, userID, reg.AppMetadata); err != nil {
+			log.Error("failed to set app metadata", sl.Err(err))
+		}
+	}
+
+	if a.hooks == nil {
+		return
+	}
+
+	for _, hook := range a.hooks.postRegister {
+		if err := hook(ctx, userID, reg); err != nil {
+			log.Error("post-register hook failed", sl.Err(err))
+		}
+	}
+}
+
+// runPreLogin runs the pre-login hooks on the login of the user to the app.
+func (a *Auth) runPreLogin(ctx context.Context, log *slog.Logger, user models.User, appID int, amr []string) error {
+	if a.hooks == nil {
+		return nil
+	}
+
+	attempt := LoginAttempt{
+		User:    user,
+		AppID:   appID,
+		Methods: amr,
+		Client:  clientinfo.FromContext(ctx),
+	}
+	for _, hook := range a.hooks.preLogin {
+		if err := hook(ctx, attempt); err != nil {
+			if errors.Is(err, ErrRejected) {
+				log.Warn("login rejected by hook", sl.Err(err))
+				return err
+			}
+
+			log.Error("pre-login hook failed", sl.Err(err))
+			if !a.hooks.FailOpen {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// runOrgMembership runs the org membership hooks on the event. Failures are
+// logged and do not fail the change, which is made already.
+func (a *Auth) runOrgMembership(ctx context.Context, log *slog.Logger, event OrgMembershipEvent) {
+	if a.hooks == nil {
+		return
+	}
+
+	for _, hook := range a.hooks.orgMembership {
+		if err := hook(ctx, event); err != nil {
+			log.Error("org membership hook failed", sl.Err(err))
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/email"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidInvitation  = errors.New("invalid invitation")
+	ErrRegistrationClosed = errors.New("registration requires an invitation")
+)
+
+// CreateInvitation emails the invited email a single-use link to register
+// with, even if registration is by invitation only. The invited user is made
+// an admin if IsAdmin is set and is logged in to AppID on accepting, if
+// set. It fails with ErrUserExists if the email is already registered.
+func (a *Auth) CreateInvitation(ctx context.Context, adminID int64, invitation models.Invitation) (models.Invitation, error) {
+	const op = "services.auth.CreateInvitation"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.String("email", invitation.Email),
+	)
+
+	log.Info("creating invitation")
+
+	isAdmin, err := a.userProvider.IsAdmin(ctx, adminID)
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		log.Error("failed to check admin", sl.Err(err))
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !isAdmin {
+		log.Warn("invitation by a user who is not an admin")
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, ErrNotAdmin)
+	}
+
+	invitation.Email = strings.TrimSpace(invitation.Email)
+
+	_, err = a.userProvider.User(ctx, invitation.Email)
+	if err == nil {
+		log.Warn("user already exists")
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, ErrUserExists)
+	}
+	if !errors.Is(err, storage.ErrUserNotFound) {
+		log.Error("failed to get user", sl.Err(err))
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if invitation.AppID != 0 {
+		if _, err = a.appProvider.App(ctx, invitation.AppID); err != nil {
+			if errors.Is(err, storage.ErrAppNotFound) {
+				log.Warn("app not found", sl.Err(err))
+				return models.Invitation{}, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+			}
+
+			log.Error("failed to get app", sl.Err(err))
+			return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	token, hash, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate invitation token", sl.Err(err))
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	invitation.TokenHash = hash
+	invitation.InvitedBy = adminID
+	invitation.CreatedAt = now
+	invitation.ExpiresAt = now.Add(a.cfg.InvitationTTL)
+
+	invitation.ID, err = a.invitations.SaveInvitation(ctx, invitation)
+	if err != nil {
+		log.Error("failed to save invitation", sl.Err(err))
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.emailSender.Send(ctx, invitationEmail(invitation.Email, withToken(a.cfg.InvitationURL, token)))
+	if err != nil {
+		log.Error("failed to send invitation email", sl.Err(err))
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("invitation created", slog.Int64("invitation_id", invitation.ID))
+
+	return invitation, nil
+}
+
+// AcceptInvitation registers the invited user with the password. The email
+// counts as verified, since the invitation was delivered to it. If the
+// invitation names an app, the user is logged in to it and the tokens are
+// returned. It fails with ErrInvalidInvitation if the token is unknown,
+// used or expired.
+func (a *Auth) AcceptInvitation(ctx context.Context, token string, password string) (int64, models.TokenPair, error) {
+	const op = "services.auth.AcceptInvitation"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("accepting invitation")
+
+	// checked first, so that a rejected password does not use up the invitation
+	if err := a.checkPasswordPolicy(ctx, log, password); err != nil {
+		return 0, models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	invitation, err := a.invitations.Invitation(ctx, randtoken.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrInvitationNotFound) {
+			log.Warn("invitation not found", sl.Err(err))
+			return 0, models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidInvitation)
+		}
+
+		log.Error("failed to get invitation", sl.Err(err))
+		return 0, models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("invitation_id", invitation.ID))
+
+	if time.Now().After(invitation.ExpiresAt) {
+		log.Warn("invitation is expired")
+		return 0, models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidInvitation)
+	}
+
+	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Error("failed to generate password hash", sl.Err(err))
+		return 0, models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err := a.invitations.AcceptInvitation(ctx, invitation.ID, passHash, time.Now())
+	if err != nil {
+		if errors.Is(err, storage.ErrInvitationUsed) {
+			log.Warn("invitation already accepted", sl.Err(err))
+			return 0, models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidInvitation)
+		}
+		if errors.Is(err, storage.ErrUserExists) {
+			log.Warn("user already exists", sl.Err(err))
+			return 0, models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrUserExists)
+		}
+
+		log.Error("failed to accept invitation", sl.Err(err))
+		return 0, models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", userID))
+	log.Info("invitation accepted")
+
+	if invitation.AppID == 0 {
+		return userID, models.TokenPair{}, nil
+	}
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return 0, models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pair, err := a.login(ctx, log, user, invitation.AppID, nil, []string{amrPassword})
+	if err != nil {
+		return 0, models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userID, pair, nil
+}
+
+func invitationEmail(to string, link string) email.Message {
+	return email.Message{
+		To:      to,
+		Subject: "You are invited",
+		Body: "You have been invited to create an account.\n\n" +
+			"Follow the link to choose a password:\n" + link + "\n\n" +
+			"If you did not expect the invitation, ignore this email.\n",
+	}
+}
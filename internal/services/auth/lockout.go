@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+var (
+	ErrAccountLocked = errors.New("account is locked")
+	// ErrAccountSuspended is returned to suspended users who log in with
+	// valid credentials.
+	ErrAccountSuspended = errors.New("account is suspended")
+)
+
+// checkLockout fails with ErrAccountLocked while the user is locked out.
+func (a *Auth) checkLockout(log *slog.Logger, user models.User) error {
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		log.Warn("account is locked", slog.Time("locked_until", *user.LockedUntil))
+		return ErrAccountLocked
+	}
+
+	return nil
+}
+
+// recordFailedLogin counts a failed password login of the user and locks
+// the user out once LockoutThreshold logins have failed within
+// LockoutWindow.
+func (a *Auth) recordFailedLogin(ctx context.Context, log *slog.Logger, user models.User) error {
+	if a.cfg.LockoutThreshold <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	failed, err := a.userSaver.RecordFailedLogin(ctx, int64(user.ID), now, now.Add(-a.cfg.LockoutWindow))
+	if err != nil {
+		log.Error("failed to record failed login", sl.Err(err))
+		return err
+	}
+
+	if failed < a.cfg.LockoutThreshold {
+		return nil
+	}
+
+	until := now.Add(a.cfg.LockoutDuration)
+	if err = a.userSaver.LockUser(ctx, int64(user.ID), until); err != nil {
+		log.Error("failed to lock account", sl.Err(err))
+		return err
+	}
+
+	log.Info("account locked after failed logins", slog.Int("failed_logins", failed), slog.Time("locked_until", until))
+
+	a.audit(ctx, log, models.AuditEvent{
+		Type:   models.AuditAccountLocked,
+		UserID: int64(user.ID),
+	})
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+// LoginHistory returns the latest successful logins of the user, the most
+// recent first. At most cfg.LoginHistorySize logins are kept per user.
+func (a *Auth) LoginHistory(ctx context.Context, userID int64) ([]models.Login, error) {
+	const op = "services.auth.LoginHistory"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	logins, err := a.userProvider.LoginHistory(ctx, userID, a.cfg.LoginHistorySize)
+	if err != nil {
+		log.Error("failed to get login history", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return logins, nil
+}
+
+// recordLogin adds the login the refresh token starts to the login history
+// of the user. A failure to record it is logged and does not fail the
+// login.
+func (a *Auth) recordLogin(ctx context.Context, token models.RefreshToken, client clientinfo.Client, device string) {
+	const op = "services.auth.recordLogin"
+
+	if a.cfg.LoginHistorySize <= 0 {
+		return
+	}
+
+	err := a.userSaver.RecordLogin(ctx, models.Login{
+		UserID:    token.UserID,
+		AppID:     token.AppID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Device:    device,
+		Methods:   token.AuthMethods,
+		CreatedAt: token.CreatedAt,
+	}, a.cfg.LoginHistorySize)
+	if err != nil {
+		a.log.Error("failed to record login",
+			slog.String("op", op),
+			slog.Int64("user_id", token.UserID),
+			sl.Err(err),
+		)
+	}
+}
+
+// recordLoginEvent records a login to the app, or a failed one, in the audit
+// log for the stats of the app. Unlike audit, it is not logged as a security
+// event, as most logins are not. A failure to record it is logged and does
+// not fail the login.
+func (a *Auth) recordLoginEvent(ctx context.Context, eventType models.AuditEventType, userID int64, appID int) {
+	const op = "services.auth.recordLoginEvent"
+
+	err := a.auditLog.SaveAuditEvent(ctx, models.AuditEvent{
+		Type:      eventType,
+		UserID:    userID,
+		AppID:     appID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		a.log.Error("failed to record login event",
+			slog.String("op", op),
+			slog.String("event", string(eventType)),
+			slog.Int("app_id", appID),
+			sl.Err(err),
+		)
+	}
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/email"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage"
+	"time"
+)
+
+var ErrInvalidMagicLink = errors.New("invalid magic link")
+
+// RequestMagicLink emails the user a single-use link that logs into the app
+// without a password. Like RequestPasswordReset it does not tell whether a
+// user with the email exists, and it silently drops requests above the limit
+// of links per user so that it can not be used to flood a mailbox.
+func (a *Auth) RequestMagicLink(ctx context.Context, address string, appID int, scopes []string) error {
+	const op = "services.auth.RequestMagicLink"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", address),
+	)
+
+	log.Info("requesting magic link")
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	granted, err := grantScopes(app, scopes)
+	if err != nil {
+		log.Warn("scope is not allowed for the app", slog.Any("scopes", scopes))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.User(ctx, address)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return nil
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", int64(user.ID)))
+
+	now := time.Now()
+
+	sent, err := a.magicLinks.CountMagicLinks(ctx, int64(user.ID), now.Add(-a.cfg.MagicLinkWindow))
+	if err != nil {
+		log.Error("failed to count magic links", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if sent >= a.cfg.MagicLinkLimit {
+		log.Warn("magic link limit reached", slog.Int("sent", sent))
+		return nil
+	}
+
+	token, hash, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate magic link token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.magicLinks.SaveMagicLink(ctx, models.MagicLink{
+		TokenHash: hash,
+		UserID:    int64(user.ID),
+		AppID:     app.ID,
+		Scopes:    granted,
+		ExpiresAt: now.Add(a.cfg.MagicLinkTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		log.Error("failed to save magic link", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.emailSender.Send(ctx, email.Message{
+		To:      user.Email,
+		Subject: "Your login link",
+		Body: "Follow the link to log in to " + app.Name + ":\n" +
+			withToken(a.cfg.MagicLinkURL, token) + "\n\n" +
+			"If it was not you, ignore this email.\n",
+	})
+	if err != nil {
+		log.Error("failed to send magic link", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("magic link sent")
+
+	return nil
+}
+
+// RedeemMagicLink logs the user in with the token of a link sent by
+// RequestMagicLink. It fails with ErrInvalidMagicLink if the token is unknown,
+// used or expired. As with Login, users who have enabled MFA get an MFA token.
+func (a *Auth) RedeemMagicLink(ctx context.Context, token string) (models.TokenPair, error) {
+	const op = "services.auth.RedeemMagicLink"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("redeeming magic link")
+
+	link, err := a.magicLinks.MagicLink(ctx, randtoken.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrMagicLinkNotFound) {
+			log.Warn("magic link not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidMagicLink)
+		}
+
+		log.Error("failed to get magic link", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", link.UserID))
+
+	if time.Now().After(link.ExpiresAt) {
+		log.Warn("magic link is expired")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidMagicLink)
+	}
+
+	if err = a.magicLinks.UseMagicLink(ctx, link.ID); err != nil {
+		if errors.Is(err, storage.ErrMagicLinkUsed) {
+			log.Warn("magic link already used", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidMagicLink)
+		}
+
+		log.Error("failed to use magic link", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, link.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidMagicLink)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.cfg.RequireVerifiedEmail && !user.EmailVerified {
+		log.Warn("email is not verified")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrEmailNotVerified)
+	}
+
+	// the link is a one-time secret delivered by email
+	pair, err := a.login(ctx, log, user, link.AppID, link.Scopes, []string{amrOTP})
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pair, nil
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/metadata"
+	"sso/internal/storage"
+)
+
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// Metadata returns the user with its app and user metadata. Users can read
+// both kinds but only write their user metadata.
+func (a *Auth) Metadata(ctx context.Context, userID int64) (models.User, error) {
+	const op = "services.auth.Metadata"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// SetUserMetadata replaces the user metadata of the user. Tokens carry it
+// once they are reissued.
+func (a *Auth) SetUserMetadata(ctx context.Context, userID int64, md map[string]string) error {
+	const op = "services.auth.SetUserMetadata"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("setting user metadata")
+
+	if err := metadata.Validate(md); err != nil {
+		log.Warn("invalid metadata", sl.Err(err))
+		return fmt.Errorf("%s: %w: %s", op, ErrInvalidMetadata, err.Error())
+	}
+
+	if err := a.userSaver.SetUserMetadata(ctx, userID, md); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to set user metadata", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user metadata set")
+
+	return nil
+}
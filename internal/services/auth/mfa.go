@@ -0,0 +1,424 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/randtoken"
+	"sso/internal/lib/totp"
+	"sso/internal/storage"
+	"time"
+)
+
+// amrOTP is the amr value of logins completed with a one-time code (RFC 8176).
+const amrOTP = "otp"
+
+// totpSkew is the number of time steps a code is accepted before or after the
+// current one, to allow for clock drift.
+const totpSkew = 1
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+	ErrMFANotEnabled     = errors.New("mfa not enabled")
+	ErrInvalidMFACode    = errors.New("invalid mfa code")
+	ErrInvalidMFAToken   = errors.New("invalid mfa token")
+)
+
+// EnrollTOTP generates a TOTP secret and recovery codes for the user. MFA is
+// not enabled until the user proves to have set up an authenticator app with
+// VerifyTOTP. Enrolling again replaces an unconfirmed secret and its codes.
+func (a *Auth) EnrollTOTP(ctx context.Context, userID int64) (models.TOTPEnrollment, error) {
+	const op = "services.auth.EnrollTOTP"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("enrolling totp")
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.TOTPEnrollment{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.TOTPEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		log.Error("failed to generate totp secret", sl.Err(err))
+		return models.TOTPEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sealed, err := a.secrets.Seal([]byte(secret))
+	if err != nil {
+		log.Error("failed to encrypt totp secret", sl.Err(err))
+		return models.TOTPEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.mfa.SaveTOTP(ctx, models.TOTP{
+		UserID:    userID,
+		Secret:    sealed,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrTOTPExists) {
+			log.Warn("totp already enabled", sl.Err(err))
+			return models.TOTPEnrollment{}, fmt.Errorf("%s: %w", op, ErrMFAAlreadyEnabled)
+		}
+
+		log.Error("failed to save totp", sl.Err(err))
+		return models.TOTPEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	recoveryCodes, err := a.newRecoveryCodes(ctx, userID)
+	if err != nil {
+		log.Error("failed to save recovery codes", sl.Err(err))
+		return models.TOTPEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp enrolled")
+
+	return models.TOTPEnrollment{
+		Secret:        secret,
+		URI:           totp.URI(a.cfg.MFAIssuer, user.Email, secret),
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// VerifyTOTP enables MFA for the user with the first code of the enrolled
+// authenticator app.
+func (a *Auth) VerifyTOTP(ctx context.Context, userID int64, code string) error {
+	const op = "services.auth.VerifyTOTP"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("verifying totp")
+
+	enrollment, err := a.mfa.TOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrTOTPNotFound) {
+			log.Warn("totp not enrolled", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrMFANotEnabled)
+		}
+
+		log.Error("failed to get totp", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if enrollment.ConfirmedAt != nil {
+		log.Warn("totp already enabled")
+		return fmt.Errorf("%s: %w", op, ErrMFAAlreadyEnabled)
+	}
+
+	step, err := a.matchTOTP(enrollment, code)
+	if err != nil {
+		log.Warn("invalid totp code", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.mfa.ConfirmTOTP(ctx, userID, step); err != nil {
+		if errors.Is(err, storage.ErrTOTPNotFound) {
+			log.Warn("totp enrollment replaced", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidMFACode)
+		}
+
+		log.Error("failed to confirm totp", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp enabled")
+
+	return nil
+}
+
+// DisableTOTP turns MFA off for the user, who must enter a current code.
+func (a *Auth) DisableTOTP(ctx context.Context, userID int64, code string) error {
+	const op = "services.auth.DisableTOTP"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("disabling totp")
+
+	if err := a.checkTOTP(ctx, log, userID, code); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.mfa.DeleteTOTP(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrTOTPNotFound) {
+			log.Warn("totp not enabled", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrMFANotEnabled)
+		}
+
+		log.Error("failed to delete totp", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.mfa.ReplaceRecoveryCodes(ctx, userID, nil); err != nil {
+		log.Error("failed to delete recovery codes", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.mfa.DeleteUserTrustedDevices(ctx, userID); err != nil {
+		log.Error("failed to delete trusted devices", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp disabled")
+
+	return nil
+}
+
+// LoginMFA completes a login that Login answered with an MFA token, with a
+// code of the authenticator app. A challenge accepts a limited number of
+// wrong codes. If rememberDevice is set, the device is trusted to skip the
+// second factor from now on.
+func (a *Auth) LoginMFA(
+	ctx context.Context,
+	mfaToken string,
+	code string,
+	rememberDevice bool,
+) (models.TokenPair, error) {
+	const op = "services.auth.LoginMFA"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("completing mfa login")
+
+	pair, err := a.completeMFALogin(ctx, log, mfaToken, amrOTP, rememberDevice, func(challenge models.MFAChallenge) error {
+		return a.checkTOTP(ctx, log, challenge.UserID, code)
+	})
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pair, nil
+}
+
+// completeMFALogin issues the tokens of the MFA challenge once check accepts
+// the second factor of the user, which is recorded as the amr method. Wrong
+// codes count against the challenge. If rememberDevice is set, the device
+// token is returned with the tokens.
+func (a *Auth) completeMFALogin(
+	ctx context.Context,
+	log *slog.Logger,
+	mfaToken string,
+	amr string,
+	rememberDevice bool,
+	check func(challenge models.MFAChallenge) error,
+) (models.TokenPair, error) {
+	challenge, err := a.mfaChallenge(ctx, log, mfaToken)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	log = log.With(slog.Int64("user_id", challenge.UserID))
+
+	if err = check(challenge); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if failErr := a.mfa.FailMFAChallenge(ctx, challenge.ID); failErr != nil {
+				log.Error("failed to count mfa attempt", sl.Err(failErr))
+			}
+		}
+
+		return models.TokenPair{}, err
+	}
+
+	if err = a.mfa.UseMFAChallenge(ctx, challenge.ID); err != nil {
+		if errors.Is(err, storage.ErrMFAChallengeUsed) {
+			log.Warn("mfa challenge already used", sl.Err(err))
+			return models.TokenPair{}, ErrInvalidMFAToken
+		}
+
+		log.Error("failed to use mfa challenge", sl.Err(err))
+		return models.TokenPair{}, err
+	}
+
+	user, err := a.userProvider.UserByID(ctx, challenge.UserID)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return models.TokenPair{}, err
+	}
+
+	app, err := a.appProvider.App(ctx, challenge.AppID)
+	if err != nil {
+		log.Error("failed to get app", sl.Err(err))
+		return models.TokenPair{}, err
+	}
+
+	if user.PasswordChangeRequired {
+		return a.passwordChangeToken(ctx, log, user)
+	}
+
+	familyID, _, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate token family", sl.Err(err))
+		return models.TokenPair{}, err
+	}
+
+	methods := append(challenge.AuthMethods, amr)
+
+	pair, err := a.issueTokens(ctx, user, app, challenge.Scopes, methods, familyID, nil)
+	if err != nil {
+		log.Error("failed to issue tokens", sl.Err(err))
+		return models.TokenPair{}, err
+	}
+
+	if rememberDevice {
+		pair.DeviceToken, err = a.trustDevice(ctx, challenge.UserID)
+		if err != nil {
+			log.Error("failed to trust device", sl.Err(err))
+			return models.TokenPair{}, err
+		}
+	}
+
+	log.Info("user logged in with mfa", slog.Bool("remember_device", rememberDevice))
+
+	return pair, nil
+}
+
+// mfaChallenge returns the pending challenge of the MFA token. It fails with
+// ErrInvalidMFAToken if the challenge is completed, expired or has seen too
+// many wrong codes.
+func (a *Auth) mfaChallenge(ctx context.Context, log *slog.Logger, mfaToken string) (models.MFAChallenge, error) {
+	challenge, err := a.mfa.MFAChallenge(ctx, randtoken.Hash(mfaToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrMFAChallengeNotFound) {
+			log.Warn("mfa challenge not found", sl.Err(err))
+			return models.MFAChallenge{}, ErrInvalidMFAToken
+		}
+
+		log.Error("failed to get mfa challenge", sl.Err(err))
+		return models.MFAChallenge{}, err
+	}
+
+	if challenge.UsedAt != nil || challenge.Attempts >= a.cfg.MFAMaxAttempts || time.Now().After(challenge.ExpiresAt) {
+		log.Warn("mfa challenge is no longer valid", slog.Int64("user_id", challenge.UserID))
+		return models.MFAChallenge{}, ErrInvalidMFAToken
+	}
+
+	return challenge, nil
+}
+
+// mfaEnabled reports whether the user has confirmed a second factor.
+func (a *Auth) mfaEnabled(ctx context.Context, userID int64) (bool, error) {
+	enrollment, err := a.mfa.TOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrTOTPNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return enrollment.ConfirmedAt != nil, nil
+}
+
+// checkSecondFactor checks the TOTP code of users who have enabled MFA and
+// accepts any code otherwise.
+func (a *Auth) checkSecondFactor(ctx context.Context, log *slog.Logger, userID int64, code string) error {
+	mfa, err := a.mfaEnabled(ctx, userID)
+	if err != nil {
+		log.Error("failed to check mfa", sl.Err(err))
+		return err
+	}
+	if !mfa {
+		return nil
+	}
+
+	return a.checkTOTP(ctx, log, userID, code)
+}
+
+// startMFAChallenge returns the token the client completes the login with.
+// amr are the methods of the first factor the user has passed.
+func (a *Auth) startMFAChallenge(
+	ctx context.Context,
+	userID int64,
+	appID int,
+	scopes []string,
+	amr []string,
+) (string, error) {
+	token, hash, err := randtoken.New()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = a.mfa.SaveMFAChallenge(ctx, models.MFAChallenge{
+		TokenHash:   hash,
+		UserID:      userID,
+		AppID:       appID,
+		Scopes:      scopes,
+		AuthMethods: amr,
+		ExpiresAt:   now.Add(a.cfg.MFAChallengeTTL),
+		CreatedAt:   now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// checkTOTP accepts a code of the user's authenticator app once. It fails
+// with ErrMFANotEnabled if the user has no TOTP secret and with
+// ErrInvalidMFACode if the code does not match or was already used.
+func (a *Auth) checkTOTP(ctx context.Context, log *slog.Logger, userID int64, code string) error {
+	enrollment, err := a.mfa.TOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrTOTPNotFound) {
+			log.Warn("totp not enabled", sl.Err(err))
+			return ErrMFANotEnabled
+		}
+
+		log.Error("failed to get totp", sl.Err(err))
+		return err
+	}
+
+	step, err := a.matchTOTP(enrollment, code)
+	if err != nil {
+		log.Warn("invalid totp code", sl.Err(err))
+		return err
+	}
+
+	if err = a.mfa.UseTOTPStep(ctx, userID, step); err != nil {
+		if errors.Is(err, storage.ErrTOTPCodeUsed) {
+			log.Warn("totp code replayed", sl.Err(err))
+			return ErrInvalidMFACode
+		}
+
+		log.Error("failed to use totp code", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// matchTOTP returns the time step the code is valid for.
+func (a *Auth) matchTOTP(enrollment models.TOTP, code string) (int64, error) {
+	secret, err := a.secrets.Open(enrollment.Secret)
+	if err != nil {
+		return 0, err
+	}
+
+	step, ok := totp.Validate(string(secret), code, time.Now(), totpSkew)
+	if !ok {
+		return 0, ErrInvalidMFACode
+	}
+
+	return step, nil
+}
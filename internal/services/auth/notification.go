@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/lib/email"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"time"
+)
+
+// NotificationPreferences returns the notification preferences of the
+// user, the defaults if the user has not set them.
+func (a *Auth) NotificationPreferences(ctx context.Context, userID int64) (models.NotificationPreferences, error) {
+	const op = "services.auth.NotificationPreferences"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	prefs, err := a.notificationPreferences(ctx, userID)
+	if err != nil {
+		log.Error("failed to get notification preferences", sl.Err(err))
+		return models.NotificationPreferences{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return prefs, nil
+}
+
+// UpdateNotificationPreferences changes the notification preferences of the
+// user and returns them.
+func (a *Auth) UpdateNotificationPreferences(
+	ctx context.Context,
+	userID int64,
+	update models.NotificationPreferencesUpdate,
+) (models.NotificationPreferences, error) {
+	const op = "services.auth.UpdateNotificationPreferences"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("updating notification preferences")
+
+	if _, err := a.userProvider.UserByID(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.NotificationPreferences{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.NotificationPreferences{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	prefs, err := a.notificationPreferences(ctx, userID)
+	if err != nil {
+		log.Error("failed to get notification preferences", sl.Err(err))
+		return models.NotificationPreferences{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if update.SecurityEmails != nil {
+		prefs.SecurityEmails = *update.SecurityEmails
+	}
+	if update.LoginAlerts != nil {
+		prefs.LoginAlerts = *update.LoginAlerts
+	}
+	if update.Marketing != nil {
+		prefs.Marketing = *update.Marketing
+	}
+	prefs.UpdatedAt = time.Now()
+
+	if err = a.userSaver.SaveNotificationPreferences(ctx, prefs); err != nil {
+		log.Error("failed to save notification preferences", sl.Err(err))
+		return models.NotificationPreferences{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("notification preferences updated")
+
+	return prefs, nil
+}
+
+func (a *Auth) notificationPreferences(ctx context.Context, userID int64) (models.NotificationPreferences, error) {
+	prefs, err := a.userProvider.NotificationPreferences(ctx, userID)
+	if errors.Is(err, storage.ErrNotificationPreferencesNotFound) {
+		return models.DefaultNotificationPreferences(userID), nil
+	}
+
+	return prefs, err
+}
+
+// notify sends the user the email of the notification unless the user has
+// turned the notification off.
+func (a *Auth) notify(
+	ctx context.Context,
+	log *slog.Logger,
+	userID int64,
+	notification models.Notification,
+	msg email.Message,
+) error {
+	prefs, err := a.notificationPreferences(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !prefs.Allows(notification) {
+		log.Info("notification turned off by the user", slog.String("notification", string(notification)))
+		return nil
+	}
+
+	return a.emailSender.Send(ctx, msg)
+}
+
+// alertLogin tells the user about the login the refresh token starts if it
+// comes from a device and network not seen in the login history. The first
+// login is not alerted, as there is nothing to compare it with. Failures
+// are logged and do not fail the login.
+func (a *Auth) alertLogin(ctx context.Context, user models.User, token models.RefreshToken, client clientinfo.Client, device string) {
+	const op = "services.auth.alertLogin"
+
+	// guests have no email, and without a login history every device is new
+	if user.Email == "" || a.cfg.LoginHistorySize <= 0 {
+		return
+	}
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", token.UserID),
+	)
+
+	prefs, err := a.notificationPreferences(ctx, token.UserID)
+	if err != nil {
+		log.Error("failed to get notification preferences", sl.Err(err))
+		return
+	}
+	if !prefs.Allows(models.NotificationLoginAlert) {
+		return
+	}
+
+	logins, err := a.userProvider.LoginHistory(ctx, token.UserID, a.cfg.LoginHistorySize)
+	if err != nil {
+		log.Error("failed to get login history", sl.Err(err))
+		return
+	}
+	if len(logins) == 0 {
+		return
+	}
+	for _, login := range logins {
+		if login.Device == device && login.IP == client.IP {
+			return
+		}
+	}
+
+	if err = a.emailSender.Send(ctx, loginAlertEmail(user.Email, device, client.IP, token.CreatedAt)); err != nil {
+		log.Error("failed to send login alert", sl.Err(err))
+		return
+	}
+
+	log.Info("login alert sent")
+}
+
+func loginAlertEmail(to string, device string, ip string, at time.Time) email.Message {
+	return email.Message{
+		To:      to,
+		Subject: "New login to your account",
+		Body: "Your account was logged in to from a new device at " + at.UTC().Format(time.RFC1123) + ".\n\n" +
+			"Device: " + device + "\n" +
+			"IP address: " + ip + "\n\n" +
+			"If it was not you, reset your password and end your other sessions.\n",
+	}
+}
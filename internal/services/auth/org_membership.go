@@ -0,0 +1,354 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/email"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+var (
+	ErrOrgNotFound = errors.New("org not found")
+	// ErrInvalidOrgMembership means that the role is unknown, or that the
+	// change can not be made, as admins changing their own membership.
+	ErrInvalidOrgMembership = errors.New("invalid org membership")
+	ErrAlreadyOrgMember     = errors.New("user is already a member of the org")
+)
+
+// OrgMembershipStorage keeps the invitations to join orgs and the members of
+// orgs.
+type OrgMembershipStorage interface {
+	Org(ctx context.Context, orgID int64) (models.Org, error)
+	SaveOrgInvitation(ctx context.Context, invitation models.OrgInvitation) (int64, error)
+	OrgInvitation(ctx context.Context, tokenHash string) (models.OrgInvitation, error)
+	AcceptOrgInvitation(ctx context.Context, id int64, userID int64, at time.Time) error
+	DeclineOrgInvitation(ctx context.Context, id int64, at time.Time) error
+	SetOrgMemberRole(ctx context.Context, orgID int64, userID int64, role string, adminID int64, at time.Time) error
+	RemoveOrgMember(ctx context.Context, orgID int64, userID int64, adminID int64, at time.Time) error
+}
+
+// InviteToOrg emails the invited email a single-use link to join the org of
+// the invitation with its role, a member if it has none, on behalf of the
+// admin. Invitees without an account register first. It fails with
+// ErrAlreadyOrgMember if the user of the email is a member already.
+func (a *Auth) InviteToOrg(ctx context.Context, adminID int64, invitation models.OrgInvitation) (models.OrgInvitation, error) {
+	const op = "services.auth.InviteToOrg"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.Int64("org_id", invitation.OrgID),
+		slog.String("email", invitation.Email),
+	)
+
+	log.Info("inviting to org")
+
+	invitation.Email = strings.TrimSpace(invitation.Email)
+	if invitation.Role == "" {
+		invitation.Role = models.OrgRoleMember
+	}
+	if invitation.Role != models.OrgRoleMember && invitation.Role != models.OrgRoleAdmin {
+		log.Warn("unknown org role", slog.String("role", invitation.Role))
+		return models.OrgInvitation{}, fmt.Errorf("%s: %w: unknown role %q", op, ErrInvalidOrgMembership, invitation.Role)
+	}
+	if !strings.Contains(invitation.Email, "@") {
+		log.Warn("invalid email")
+		return models.OrgInvitation{}, fmt.Errorf("%s: %w: invalid email", op, ErrInvalidOrgMembership)
+	}
+
+	org, err := a.orgs.Org(ctx, invitation.OrgID)
+	if err != nil {
+		if errors.Is(err, storage.ErrOrgNotFound) {
+			log.Warn("org not found", sl.Err(err))
+			return models.OrgInvitation{}, fmt.Errorf("%s: %w", op, ErrOrgNotFound)
+		}
+
+		log.Error("failed to get org", sl.Err(err))
+		return models.OrgInvitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.User(ctx, invitation.Email)
+	switch {
+	case err == nil && user.OrgID == invitation.OrgID:
+		log.Warn("user is already a member of the org")
+		return models.OrgInvitation{}, fmt.Errorf("%s: %w", op, ErrAlreadyOrgMember)
+	case err != nil && !errors.Is(err, storage.ErrUserNotFound):
+		log.Error("failed to get user", sl.Err(err))
+		return models.OrgInvitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, hash, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate invitation token", sl.Err(err))
+		return models.OrgInvitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	invitation.TokenHash = hash
+	invitation.InvitedBy = adminID
+	invitation.CreatedAt = now
+	invitation.ExpiresAt = now.Add(a.cfg.InvitationTTL)
+
+	invitation.ID, err = a.orgs.SaveOrgInvitation(ctx, invitation)
+	if err != nil {
+		log.Error("failed to save org invitation", sl.Err(err))
+		return models.OrgInvitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	link := withToken(a.cfg.OrgInvitationURL, token)
+	if err = a.emailSender.Send(ctx, orgInvitationEmail(invitation.Email, org.Name, link)); err != nil {
+		log.Error("failed to send org invitation email", sl.Err(err))
+		return models.OrgInvitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.runOrgMembership(ctx, log, OrgMembershipEvent{
+		Action:  OrgInvited,
+		OrgID:   invitation.OrgID,
+		Email:   invitation.Email,
+		Role:    invitation.Role,
+		ActorID: adminID,
+	})
+
+	log.Info("invited to org", slog.Int64("invitation_id", invitation.ID))
+
+	return invitation, nil
+}
+
+// AcceptOrgInvitation makes the user a member of the org of the invitation
+// with its role and returns the org. The invitation must have been sent to
+// the email of the user. The tokens of the user are revoked, so that the
+// user logs in again within the org. It fails with ErrInvalidInvitation if
+// the token is unknown, answered, expired or meant for someone else.
+func (a *Auth) AcceptOrgInvitation(ctx context.Context, userID int64, token string) (int64, error) {
+	const op = "services.auth.AcceptOrgInvitation"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("accepting org invitation")
+
+	invitation, err := a.orgInvitation(ctx, log, token)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(
+		slog.Int64("invitation_id", invitation.ID),
+		slog.Int64("org_id", invitation.OrgID),
+	)
+
+	user, err := a.userProvider.User(ctx, invitation.Email)
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		log.Error("failed to get user", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if err != nil || int64(user.ID) != userID {
+		log.Warn("org invitation was sent to another email")
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidInvitation)
+	}
+
+	if err = a.orgs.AcceptOrgInvitation(ctx, invitation.ID, userID, time.Now()); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrInvitationUsed):
+			log.Warn("org invitation already answered", sl.Err(err))
+			return 0, fmt.Errorf("%s: %w", op, ErrInvalidInvitation)
+		case errors.Is(err, storage.ErrUserNotFound):
+			log.Warn("user not found", sl.Err(err))
+			return 0, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to accept org invitation", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.runOrgMembership(ctx, log, OrgMembershipEvent{
+		Action:  OrgJoined,
+		OrgID:   invitation.OrgID,
+		UserID:  userID,
+		Email:   user.Email,
+		Role:    invitation.Role,
+		ActorID: userID,
+	})
+
+	log.Info("org invitation accepted")
+
+	return invitation.OrgID, nil
+}
+
+// DeclineOrgInvitation declines the invitation of the token. It fails with
+// ErrInvalidInvitation if the token is unknown, answered or expired.
+func (a *Auth) DeclineOrgInvitation(ctx context.Context, token string) error {
+	const op = "services.auth.DeclineOrgInvitation"
+
+	log := a.log.With(slog.String("op", op))
+
+	log.Info("declining org invitation")
+
+	invitation, err := a.orgInvitation(ctx, log, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("invitation_id", invitation.ID))
+
+	if err = a.orgs.DeclineOrgInvitation(ctx, invitation.ID, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrInvitationUsed) {
+			log.Warn("org invitation already answered", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidInvitation)
+		}
+
+		log.Error("failed to decline org invitation", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.runOrgMembership(ctx, log, OrgMembershipEvent{
+		Action: OrgInvitationDeclined,
+		OrgID:  invitation.OrgID,
+		Email:  invitation.Email,
+		Role:   invitation.Role,
+	})
+
+	log.Info("org invitation declined")
+
+	return nil
+}
+
+// SetOrgMemberRole gives the member of the org the role on behalf of the
+// admin, who can not change their own role. It fails with ErrUserNotFound if
+// the user is not a member of the org.
+func (a *Auth) SetOrgMemberRole(ctx context.Context, adminID int64, orgID int64, userID int64, role string) error {
+	const op = "services.auth.SetOrgMemberRole"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.Int64("org_id", orgID),
+		slog.Int64("user_id", userID),
+		slog.String("role", role),
+	)
+
+	log.Info("setting org member role")
+
+	if role != models.OrgRoleMember && role != models.OrgRoleAdmin {
+		log.Warn("unknown org role")
+		return fmt.Errorf("%s: %w: unknown role %q", op, ErrInvalidOrgMembership, role)
+	}
+	if userID == adminID {
+		log.Warn("admin changing their own role")
+		return fmt.Errorf("%s: %w: admins can not change their own role", op, ErrInvalidOrgMembership)
+	}
+
+	if err := a.orgs.SetOrgMemberRole(ctx, orgID, userID, role, adminID, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user is not a member of the org", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to set org member role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.runOrgMembership(ctx, log, OrgMembershipEvent{
+		Action:  OrgRoleChanged,
+		OrgID:   orgID,
+		UserID:  userID,
+		Role:    role,
+		ActorID: adminID,
+	})
+
+	log.Info("org member role set")
+
+	return nil
+}
+
+// RemoveOrgMember moves the member of the org back to the default org as a
+// regular user on behalf of the admin, who can not remove themselves.
+// Members of the default org can not be removed. It fails with
+// ErrUserNotFound if the user is not a member of the org.
+func (a *Auth) RemoveOrgMember(ctx context.Context, adminID int64, orgID int64, userID int64) error {
+	const op = "services.auth.RemoveOrgMember"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.Int64("org_id", orgID),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("removing org member")
+
+	if orgID == models.DefaultOrgID {
+		log.Warn("removing a member of the default org")
+		return fmt.Errorf("%s: %w: members of the default org can not be removed", op, ErrInvalidOrgMembership)
+	}
+	if userID == adminID {
+		log.Warn("admin removing themselves")
+		return fmt.Errorf("%s: %w: admins can not remove themselves", op, ErrInvalidOrgMembership)
+	}
+
+	if err := a.orgs.RemoveOrgMember(ctx, orgID, userID, adminID, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user is not a member of the org", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to remove org member", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.runOrgMembership(ctx, log, OrgMembershipEvent{
+		Action:  OrgMemberRemoved,
+		OrgID:   orgID,
+		UserID:  userID,
+		ActorID: adminID,
+	})
+
+	log.Info("org member removed")
+
+	return nil
+}
+
+// orgInvitation returns the unanswered, unexpired invitation of the token.
+func (a *Auth) orgInvitation(ctx context.Context, log *slog.Logger, token string) (models.OrgInvitation, error) {
+	invitation, err := a.orgs.OrgInvitation(ctx, randtoken.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrInvitationNotFound) {
+			log.Warn("org invitation not found", sl.Err(err))
+			return models.OrgInvitation{}, ErrInvalidInvitation
+		}
+
+		log.Error("failed to get org invitation", sl.Err(err))
+		return models.OrgInvitation{}, err
+	}
+
+	if invitation.AcceptedAt != nil || invitation.DeclinedAt != nil {
+		log.Warn("org invitation already answered", slog.Int64("invitation_id", invitation.ID))
+		return models.OrgInvitation{}, ErrInvalidInvitation
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		log.Warn("org invitation is expired", slog.Int64("invitation_id", invitation.ID))
+		return models.OrgInvitation{}, ErrInvalidInvitation
+	}
+
+	return invitation, nil
+}
+
+func orgInvitationEmail(to string, org string, link string) email.Message {
+	return email.Message{
+		To:      to,
+		Subject: "You are invited to join " + org,
+		Body: "You have been invited to join " + org + ".\n\n" +
+			"Follow the link to accept or decline the invitation. If you have no account yet, " +
+			"register with this email first:\n" + link + "\n\n" +
+			"If you did not expect the invitation, ignore this email.\n",
+	}
+}
@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"net/url"
+	"sso/internal/domain/models"
+	"sso/internal/lib/email"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/passhash"
+	"sso/internal/lib/password"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage"
+	"time"
+)
+
+var (
+	ErrInvalidResetToken = errors.New("invalid password reset token")
+	ErrWeakPassword      = errors.New("password does not meet the policy")
+)
+
+// PasswordPolicy checks new passwords.
+type PasswordPolicy interface {
+	Check(password string) []password.Violation
+}
+
+// PwnedChecker tells whether a password is known from data breaches.
+type PwnedChecker interface {
+	Pwned(ctx context.Context, password string) (bool, error)
+}
+
+// WeakPasswordError lists the rules of the password policy a new password
+// violates. It matches ErrWeakPassword.
+type WeakPasswordError struct {
+	Violations []password.Violation
+}
+
+func (e *WeakPasswordError) Error() string {
+	return ErrWeakPassword.Error()
+}
+
+func (e *WeakPasswordError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// RequestPasswordReset emails the user a single-use link to set a new
+// password. It does not tell whether a user with the email exists.
+func (a *Auth) RequestPasswordReset(ctx context.Context, email string) error {
+	const op = "services.auth.RequestPasswordReset"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	log.Info("requesting password reset")
+
+	user, err := a.userProvider.User(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return nil
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, hash, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate reset token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	err = a.resetTokens.SavePasswordResetToken(ctx, models.PasswordResetToken{
+		TokenHash: hash,
+		UserID:    int64(user.ID),
+		ExpiresAt: now.Add(a.cfg.PasswordResetTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		log.Error("failed to save reset token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.emailSender.Send(ctx, passwordResetEmail(user.Email, withToken(a.cfg.PasswordResetURL, token)))
+	if err != nil {
+		log.Error("failed to send reset email", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password reset requested")
+
+	return nil
+}
+
+// ConfirmPasswordReset sets the new password of the user the reset token was
+// issued to and logs the user out everywhere. It fails with
+// ErrInvalidResetToken if the token is unknown, used or expired.
+func (a *Auth) ConfirmPasswordReset(ctx context.Context, token string, newPassword string) error {
+	const op = "services.auth.ConfirmPasswordReset"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("confirming password reset")
+
+	// checked first, so that a rejected password does not use up the token
+	if err := a.checkPasswordPolicy(ctx, log, newPassword); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	reset, err := a.resetTokens.PasswordResetToken(ctx, randtoken.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrPasswordResetTokenNotFound) {
+			log.Warn("reset token not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+		}
+
+		log.Error("failed to get reset token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", reset.UserID))
+
+	if time.Now().After(reset.ExpiresAt) {
+		log.Warn("reset token is expired")
+		return fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+	}
+
+	// checked before the token is used, as the policy is
+	user, err := a.userProvider.UserByID(ctx, reset.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if user.PasswordChangeRequired && passhash.Compare(user.PassHash, newPassword) == nil {
+		log.Warn("required password change keeps the password")
+		return fmt.Errorf("%s: %w", op, &WeakPasswordError{Violations: []password.Violation{{
+			Code:    password.ViolationReused,
+			Message: "must differ from the current password",
+		}}})
+	}
+
+	if err = a.resetTokens.UsePasswordResetToken(ctx, reset.ID); err != nil {
+		if errors.Is(err, storage.ErrPasswordResetTokenUsed) {
+			log.Warn("reset token already used", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+		}
+
+		log.Error("failed to use reset token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.Error("failed to generate password hash", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.userSaver.UpdatePassword(ctx, reset.UserID, passHash); err != nil {
+		log.Error("failed to update password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.revokeSessions(ctx, reset.UserID); err != nil {
+		log.Error("failed to revoke user sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password reset")
+
+	return nil
+}
+
+// ChangePassword sets a new password for the user after checking the current
+// one and revokes the refresh tokens of the user. It fails with
+// ErrInvalidCredentials if the current password does not match.
+func (a *Auth) ChangePassword(ctx context.Context, userID int64, oldPassword string, newPassword string) error {
+	const op = "services.auth.ChangePassword"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("changing password")
+
+	if err := a.checkPasswordPolicy(ctx, log, newPassword); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = passhash.Compare(user.PassHash, oldPassword); err != nil {
+		log.Warn("invalid current password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	passHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.Error("failed to generate password hash", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.userSaver.UpdatePassword(ctx, userID, passHash); err != nil {
+		log.Error("failed to update password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.tokenStorage.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		log.Error("failed to revoke refresh tokens", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password changed")
+
+	return nil
+}
+
+// passwordChangeToken completes the login of a user an admin required to set
+// a new password: instead of tokens, the user gets a password reset token
+// that lives for PasswordChangeTTL, to set the new password with
+// ConfirmPasswordReset before logging in again.
+func (a *Auth) passwordChangeToken(ctx context.Context, log *slog.Logger, user models.User) (models.TokenPair, error) {
+	token, hash, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate reset token", sl.Err(err))
+		return models.TokenPair{}, err
+	}
+
+	now := time.Now()
+	err = a.resetTokens.SavePasswordResetToken(ctx, models.PasswordResetToken{
+		TokenHash: hash,
+		UserID:    int64(user.ID),
+		ExpiresAt: now.Add(a.cfg.PasswordChangeTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		log.Error("failed to save reset token", sl.Err(err))
+		return models.TokenPair{}, err
+	}
+
+	log.Info("user needs to change password")
+
+	return models.TokenPair{PasswordChangeToken: token}, nil
+}
+
+// checkPasswordPolicy fails with a WeakPasswordError if the new password
+// violates the password policy or, when a breach check is configured, is
+// known from data breaches. A password that fails the policy is not sent to
+// the breach check. The breach check fails open, so that registration does
+// not depend on its availability.
+func (a *Auth) checkPasswordPolicy(ctx context.Context, log *slog.Logger, newPassword string) error {
+	violations := a.passwordPolicy.Check(newPassword)
+
+	if len(violations) == 0 && a.pwned != nil {
+		pwned, err := a.pwned.Pwned(ctx, newPassword)
+		if err != nil {
+			log.Error("failed to check password against breaches", sl.Err(err))
+		}
+		if pwned {
+			violations = append(violations, password.Violation{
+				Code:    password.ViolationBreached,
+				Message: "appeared in a data breach",
+			})
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	codes := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		codes = append(codes, violation.Code)
+	}
+	log.Warn("password violates the policy", slog.Any("violations", codes))
+
+	return &WeakPasswordError{Violations: violations}
+}
+
+func passwordResetEmail(to string, link string) email.Message {
+	return email.Message{
+		To:      to,
+		Subject: "Reset your password",
+		Body: "Someone asked to reset the password of your account.\n\n" +
+			"Follow the link to set a new password:\n" + link + "\n\n" +
+			"If it was not you, ignore this email.\n",
+	}
+}
+
+// withToken appends the token to the link as the token query parameter.
+func withToken(link string, token string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link + "?token=" + url.QueryEscape(token)
+	}
+
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"strconv"
+	"time"
+)
+
+// ErrQuotaExceeded means that the app used up one of its quotas. It is
+// returned wrapped in a QuotaExceededError.
+var ErrQuotaExceeded = errors.New("app quota exceeded")
+
+// QuotaExceededError tells when the quota the app used up resets.
+type QuotaExceededError struct {
+	Quota      string
+	RetryAfter time.Duration
+}
+
+func (e *QuotaExceededError) Error() string {
+	return ErrQuotaExceeded.Error() + ": " + e.Quota
+}
+
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// QuotaCounter counts the usage of quotas by key in fixed windows.
+type QuotaCounter interface {
+	IncrementUsage(ctx context.Context, key string, expiresAt time.Time) (int, error)
+	Usage(ctx context.Context, key string) (int, error)
+}
+
+// quotaWindows are the windows quotas are counted in.
+var quotaWindows = map[string]time.Duration{
+	models.QuotaLogins:        time.Minute,
+	models.QuotaRegistrations: 24 * time.Hour,
+}
+
+// UseAppQuota counts a request against the quota of the app. It fails with
+// a QuotaExceededError once the app made more requests than its quota allows
+// in the current window. Unknown apps and apps without a limit are not
+// counted, and the quota fails open if the usage can not be counted.
+func (a *Auth) UseAppQuota(ctx context.Context, appID int, quota string) error {
+	const op = "services.auth.UseAppQuota"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+		slog.String("quota", quota),
+	)
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return nil
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return nil
+	}
+
+	limit := appQuota(app, quota)
+	if limit <= 0 {
+		return nil
+	}
+
+	key, resetsAt := quotaKey(appID, quota, time.Now())
+	used, err := a.quotas.IncrementUsage(ctx, key, resetsAt)
+	if err != nil {
+		log.Error("failed to count quota usage", sl.Err(err))
+		return nil
+	}
+
+	if used > limit {
+		log.Warn("app quota exceeded", slog.Int("limit", limit))
+		return fmt.Errorf("%s: %w", op, &QuotaExceededError{Quota: quota, RetryAfter: time.Until(resetsAt)})
+	}
+
+	return nil
+}
+
+// AppUsage reports how much of its quotas the app used in the current
+// windows. It fails with ErrInvalidAppID if there is no such active app.
+func (a *Auth) AppUsage(ctx context.Context, appID int) (models.AppUsage, error) {
+	const op = "services.auth.AppUsage"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.AppUsage{}, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return models.AppUsage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	usage := models.AppUsage{AppID: appID}
+	now := time.Now()
+	for quota, dest := range map[string]*models.QuotaUsage{
+		models.QuotaLogins:        &usage.Logins,
+		models.QuotaRegistrations: &usage.Registrations,
+	} {
+		key, resetsAt := quotaKey(appID, quota, now)
+
+		used, err := a.quotas.Usage(ctx, key)
+		if err != nil {
+			log.Error("failed to get quota usage", sl.Err(err))
+			return models.AppUsage{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		*dest = models.QuotaUsage{Used: used, Limit: appQuota(app, quota), ResetsAt: resetsAt}
+	}
+
+	return usage, nil
+}
+
+func appQuota(app models.App, quota string) int {
+	switch quota {
+	case models.QuotaLogins:
+		return app.LoginQuota
+	case models.QuotaRegistrations:
+		return app.RegistrationQuota
+	default:
+		return 0
+	}
+}
+
+// quotaKey returns the key the usage of the quota by the app is counted by
+// in the window of now, and when the window ends.
+func quotaKey(appID int, quota string, now time.Time) (string, time.Time) {
+	window := quotaWindows[quota]
+	start := now.UTC().Truncate(window)
+
+	return "app:" + strconv.Itoa(appID) + ":" + quota + ":" + strconv.FormatInt(start.Unix(), 10), start.Add(window)
+}
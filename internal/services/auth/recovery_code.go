@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage"
+	"strings"
+)
+
+const (
+	recoveryCodeCount = 10
+	// recoveryCodeSize random bytes make ten base32 characters.
+	recoveryCodeSize = 6
+)
+
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// LoginRecoveryCode completes a login that Login answered with an MFA token,
+// with one of the recovery codes of the user instead of a TOTP code. Each
+// recovery code works once. Recovery logins can not trust the device.
+func (a *Auth) LoginRecoveryCode(ctx context.Context, mfaToken string, code string) (models.TokenPair, error) {
+	const op = "services.auth.LoginRecoveryCode"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("completing mfa login with recovery code")
+
+	pair, err := a.completeMFALogin(ctx, log, mfaToken, amrOTP, false, func(challenge models.MFAChallenge) error {
+		err := a.mfa.UseRecoveryCode(ctx, challenge.UserID, recoveryCodeHash(code))
+		if err != nil {
+			if errors.Is(err, storage.ErrRecoveryCodeNotFound) {
+				log.Warn("invalid recovery code", sl.Err(err))
+				return ErrInvalidMFACode
+			}
+
+			log.Error("failed to use recovery code", sl.Err(err))
+			return err
+		}
+
+		log.Info("recovery code used")
+
+		return nil
+	})
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pair, nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user, who must
+// enter a current TOTP code.
+func (a *Auth) RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) ([]string, error) {
+	const op = "services.auth.RegenerateRecoveryCodes"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("regenerating recovery codes")
+
+	mfa, err := a.mfaEnabled(ctx, userID)
+	if err != nil {
+		log.Error("failed to check mfa", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !mfa {
+		log.Warn("mfa not enabled")
+		return nil, fmt.Errorf("%s: %w", op, ErrMFANotEnabled)
+	}
+
+	if err = a.checkTOTP(ctx, log, userID, code); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	codes, err := a.newRecoveryCodes(ctx, userID)
+	if err != nil {
+		log.Error("failed to save recovery codes", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("recovery codes regenerated")
+
+	return codes, nil
+}
+
+// newRecoveryCodes replaces the recovery codes of the user with new ones and
+// returns them. Only their hashes are stored.
+func (a *Auth) newRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	b := make([]byte, recoveryCodeSize)
+	for range recoveryCodeCount {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+
+		code := recoveryCodeEncoding.EncodeToString(b)
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, recoveryCodeHash(code))
+	}
+
+	if err := a.mfa.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// recoveryCodeHash ignores case and the separators users may type.
+func recoveryCodeHash(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+
+	return randtoken.Hash(code)
+}
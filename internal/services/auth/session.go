@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// ListSessions returns the sessions the user is logged in with, the most
+// recently used first. A session lasts until its refresh tokens expire or
+// are revoked.
+func (a *Auth) ListSessions(ctx context.Context, userID int64) ([]models.UserSession, error) {
+	const op = "services.auth.ListSessions"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	sessions, err := a.tokenStorage.ActiveUserSessions(ctx, userID)
+	if err != nil {
+		log.Error("failed to get sessions", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession logs the user out of the session by revoking its refresh
+// tokens. Access tokens already issued in the session stay valid until they
+// expire. It fails with ErrSessionNotFound if the session does not belong to
+// the user.
+func (a *Auth) RevokeSession(ctx context.Context, userID int64, sessionID int64) error {
+	const op = "services.auth.RevokeSession"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int64("session_id", sessionID),
+	)
+
+	log.Info("revoking session")
+
+	session, err := a.tokenStorage.UserSession(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserSessionNotFound) {
+			log.Warn("session not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+		}
+
+		log.Error("failed to get session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if session.UserID != userID {
+		log.Warn("session belongs to another user")
+		return fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+	}
+
+	if err = a.tokenStorage.RevokeRefreshTokenFamily(ctx, session.FamilyID); err != nil {
+		log.Error("failed to revoke refresh tokens", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("session revoked")
+
+	return nil
+}
+
+// trackSession records the session of the refresh token family, and the
+// login in the login history, on login and the client using it on every
+// refresh. Logins from new devices are alerted to users who want it.
+func (a *Auth) trackSession(ctx context.Context, user models.User, token models.RefreshToken, refreshed bool) error {
+	client := clientinfo.FromContext(ctx)
+	device := clientinfo.Device(client.UserAgent)
+
+	if refreshed {
+		return a.tokenStorage.TouchUserSession(ctx, token.FamilyID, client.IP, client.UserAgent, device, token.CreatedAt)
+	}
+
+	err := a.tokenStorage.SaveUserSession(ctx, models.UserSession{
+		FamilyID:   token.FamilyID,
+		UserID:     token.UserID,
+		AppID:      token.AppID,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		Device:     device,
+		CreatedAt:  token.CreatedAt,
+		LastSeenAt: token.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	// alerted before the login is recorded, so that its device is not known
+	// yet
+	a.alertLogin(ctx, user, token, client, device)
+	a.recordLogin(ctx, token, client, device)
+	a.recordLoginEvent(ctx, models.AuditLoginSucceeded, token.UserID, token.AppID)
+
+	return nil
+}
@@ -0,0 +1,385 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"regexp"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/randtoken"
+	"sso/internal/lib/sms"
+	"sso/internal/storage"
+	"time"
+)
+
+// amrSMS is the amr value of factors proven with a code sent by SMS (RFC 8176).
+const amrSMS = "sms"
+
+// smsCodeDigits is the length of codes sent by SMS.
+const smsCodeDigits = 6
+
+var (
+	ErrInvalidPhone     = errors.New("invalid phone number")
+	ErrPhoneTaken       = errors.New("phone number already taken")
+	ErrPhoneNotVerified = errors.New("phone number is not verified")
+	ErrInvalidSMSCode   = errors.New("invalid sms code")
+	ErrSMSRateLimited   = errors.New("sms code requested too often")
+)
+
+// phonePattern matches phone numbers in E.164 format.
+var phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// RequestPhoneVerification texts a code to the phone number. The number is
+// set for the user once the code is entered with VerifyPhone.
+func (a *Auth) RequestPhoneVerification(ctx context.Context, userID int64, phone string) error {
+	const op = "services.auth.RequestPhoneVerification"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("requesting phone verification")
+
+	if !phonePattern.MatchString(phone) {
+		log.Warn("invalid phone number")
+		return fmt.Errorf("%s: %w", op, ErrInvalidPhone)
+	}
+
+	owner, err := a.userProvider.UserByPhone(ctx, phone)
+	if err == nil && int64(owner.ID) != userID {
+		log.Warn("phone number belongs to another user")
+		return fmt.Errorf("%s: %w", op, ErrPhoneTaken)
+	}
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		log.Error("failed to get user by phone", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.sendSMSCode(ctx, log, userID, phone, models.SMSPhoneVerification); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("phone verification code sent")
+
+	return nil
+}
+
+// VerifyPhone sets the phone number the code was last sent to for the user.
+func (a *Auth) VerifyPhone(ctx context.Context, userID int64, code string) error {
+	const op = "services.auth.VerifyPhone"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("verifying phone")
+
+	sent, err := a.checkSMSCode(ctx, log, userID, models.SMSPhoneVerification, code)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.userSaver.SetPhone(ctx, userID, sent.Phone); err != nil {
+		if errors.Is(err, storage.ErrPhoneTaken) {
+			log.Warn("phone number belongs to another user", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrPhoneTaken)
+		}
+
+		log.Error("failed to set phone", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("phone verified")
+
+	return nil
+}
+
+// RequestSMSLogin texts a login code to the verified phone number. To not
+// disclose which numbers are registered, unknown numbers are not an error.
+func (a *Auth) RequestSMSLogin(ctx context.Context, phone string) error {
+	const op = "services.auth.RequestSMSLogin"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("requesting sms login")
+
+	user, err := a.userProvider.UserByPhone(ctx, phone)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("phone number not found", sl.Err(err))
+			return nil
+		}
+
+		log.Error("failed to get user by phone", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", int64(user.ID)))
+
+	if err = a.sendSMSCode(ctx, log, int64(user.ID), user.Phone, models.SMSLogin); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("sms login code sent")
+
+	return nil
+}
+
+// LoginSMS logs the user in with the code sent by RequestSMSLogin instead of
+// a password. As with Login, users who have enabled MFA get an MFA token.
+func (a *Auth) LoginSMS(
+	ctx context.Context,
+	phone string,
+	code string,
+	appID int,
+	scopes []string,
+) (models.TokenPair, error) {
+	const op = "services.auth.LoginSMS"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("logging user in with sms code")
+
+	user, err := a.userProvider.UserByPhone(ctx, phone)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("phone number not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+
+		log.Error("failed to get user by phone", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", int64(user.ID)))
+
+	sent, err := a.checkSMSCode(ctx, log, int64(user.ID), models.SMSLogin, code)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSMSCode) {
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if sent.Phone != phone {
+		log.Warn("code was sent to another phone number")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	if a.cfg.RequireVerifiedEmail && !user.EmailVerified {
+		log.Warn("email is not verified")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrEmailNotVerified)
+	}
+
+	pair, err := a.login(ctx, log, user, appID, scopes, []string{amrSMS})
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pair, nil
+}
+
+// SendMFASMS texts a code completing the MFA challenge to the verified phone
+// number of the user. It fails with ErrPhoneNotVerified if the user has none,
+// and may not be used when the first factor was an SMS code too.
+func (a *Auth) SendMFASMS(ctx context.Context, mfaToken string) error {
+	const op = "services.auth.SendMFASMS"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("sending mfa code by sms")
+
+	challenge, err := a.mfaChallenge(ctx, log, mfaToken)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", challenge.UserID))
+
+	if slices.Contains(challenge.AuthMethods, amrSMS) {
+		log.Warn("first factor was an sms code")
+		return fmt.Errorf("%s: %w", op, ErrPhoneNotVerified)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, challenge.UserID)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !user.PhoneVerified {
+		log.Warn("phone number is not verified")
+		return fmt.Errorf("%s: %w", op, ErrPhoneNotVerified)
+	}
+
+	if err = a.sendSMSCode(ctx, log, challenge.UserID, user.Phone, models.SMSMFA); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("mfa code sent by sms")
+
+	return nil
+}
+
+// LoginMFASMS completes a login that was answered with an MFA token, with
+// the code sent by SendMFASMS. If rememberDevice is set, the device is
+// trusted to skip the second factor from now on.
+func (a *Auth) LoginMFASMS(
+	ctx context.Context,
+	mfaToken string,
+	code string,
+	rememberDevice bool,
+) (models.TokenPair, error) {
+	const op = "services.auth.LoginMFASMS"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("completing mfa login with sms code")
+
+	pair, err := a.completeMFALogin(ctx, log, mfaToken, amrSMS, rememberDevice, func(challenge models.MFAChallenge) error {
+		if slices.Contains(challenge.AuthMethods, amrSMS) {
+			log.Warn("first factor was an sms code")
+			return ErrInvalidMFACode
+		}
+
+		_, err := a.checkSMSCode(ctx, log, challenge.UserID, models.SMSMFA, code)
+		if errors.Is(err, ErrInvalidSMSCode) {
+			return ErrInvalidMFACode
+		}
+
+		return err
+	})
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pair, nil
+}
+
+// sendSMSCode texts a new code for the purpose to the phone number. It fails
+// with ErrSMSRateLimited if the previous code was sent too recently.
+func (a *Auth) sendSMSCode(
+	ctx context.Context,
+	log *slog.Logger,
+	userID int64,
+	phone string,
+	purpose models.SMSCodePurpose,
+) error {
+	last, err := a.smsCodes.LatestSMSCode(ctx, userID, purpose)
+	if err != nil && !errors.Is(err, storage.ErrSMSCodeNotFound) {
+		log.Error("failed to get last sms code", sl.Err(err))
+		return err
+	}
+	if err == nil && time.Since(last.CreatedAt) < a.cfg.SMSResendInterval {
+		log.Warn("sms code requested too often")
+		return ErrSMSRateLimited
+	}
+
+	code, err := newSMSCode()
+	if err != nil {
+		log.Error("failed to generate sms code", sl.Err(err))
+		return err
+	}
+
+	now := time.Now()
+	err = a.smsCodes.SaveSMSCode(ctx, models.SMSCode{
+		UserID:    userID,
+		Phone:     phone,
+		Purpose:   purpose,
+		CodeHash:  randtoken.Hash(code),
+		ExpiresAt: now.Add(a.cfg.SMSCodeTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		log.Error("failed to save sms code", sl.Err(err))
+		return err
+	}
+
+	err = a.smsSender.Send(ctx, sms.Message{
+		To:   phone,
+		Body: fmt.Sprintf("Your %s code is %s", a.cfg.MFAIssuer, code),
+	})
+	if err != nil {
+		log.Error("failed to send sms", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// checkSMSCode accepts the last code sent to the user for the purpose once.
+// It fails with ErrInvalidSMSCode if the code does not match, is expired or
+// has seen too many wrong entries.
+func (a *Auth) checkSMSCode(
+	ctx context.Context,
+	log *slog.Logger,
+	userID int64,
+	purpose models.SMSCodePurpose,
+	code string,
+) (models.SMSCode, error) {
+	sent, err := a.smsCodes.LatestSMSCode(ctx, userID, purpose)
+	if err != nil {
+		if errors.Is(err, storage.ErrSMSCodeNotFound) {
+			log.Warn("no sms code sent", sl.Err(err))
+			return models.SMSCode{}, ErrInvalidSMSCode
+		}
+
+		log.Error("failed to get sms code", sl.Err(err))
+		return models.SMSCode{}, err
+	}
+
+	if sent.UsedAt != nil || sent.Attempts >= a.cfg.SMSMaxAttempts || time.Now().After(sent.ExpiresAt) {
+		log.Warn("sms code is no longer valid")
+		return models.SMSCode{}, ErrInvalidSMSCode
+	}
+
+	if subtle.ConstantTimeCompare([]byte(randtoken.Hash(code)), []byte(sent.CodeHash)) != 1 {
+		log.Warn("invalid sms code")
+		if err = a.smsCodes.FailSMSCode(ctx, sent.ID); err != nil {
+			log.Error("failed to count sms code attempt", sl.Err(err))
+		}
+		return models.SMSCode{}, ErrInvalidSMSCode
+	}
+
+	if err = a.smsCodes.UseSMSCode(ctx, sent.ID); err != nil {
+		if errors.Is(err, storage.ErrSMSCodeUsed) {
+			log.Warn("sms code already used", sl.Err(err))
+			return models.SMSCode{}, ErrInvalidSMSCode
+		}
+
+		log.Error("failed to use sms code", sl.Err(err))
+		return models.SMSCode{}, err
+	}
+
+	return sent, nil
+}
+
+// newSMSCode returns a random numeric code of smsCodeDigits digits.
+func newSMSCode() (string, error) {
+	limit := big.NewInt(1)
+	for range smsCodeDigits {
+		limit.Mul(limit, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", smsCodeDigits, n), nil
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/passhash"
+	"sso/internal/storage"
+	"time"
+)
+
+// StepUp upgrades the session of the refresh token after the user proves a
+// factor again: the password, or a TOTP code if otp is set. The refresh
+// token is rotated and the new tokens carry the current auth time and the
+// proven method in amr, so that resource servers asking for a recent or a
+// multi-factor authentication accept them (RFC 9470). Wrong passwords and
+// codes count towards the account lockout.
+func (a *Auth) StepUp(ctx context.Context, refreshToken string, password string, otp string) (models.TokenPair, error) {
+	const op = "services.auth.StepUp"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("stepping up session")
+
+	current, user, app, err := a.refreshSession(ctx, log, refreshToken)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(
+		slog.Int64("user_id", current.UserID),
+		slog.Int("app_id", current.AppID),
+		slog.String("family_id", current.FamilyID),
+	)
+
+	method, err := a.checkStepUpFactor(ctx, log, user, password, otp)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	methods := slices.Clone(current.AuthMethods)
+	if !slices.Contains(methods, method) {
+		methods = append(methods, method)
+	}
+
+	stepped := current
+	stepped.AuthTime = time.Now()
+
+	pair, err := a.issueTokens(ctx, user, app, current.Scopes, methods, current.FamilyID, &stepped)
+	if err != nil {
+		if errors.Is(err, storage.ErrRefreshTokenRotated) {
+			// another request rotated the same token first
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, a.revokeReusedFamily(ctx, log, current))
+		}
+
+		log.Error("failed to issue tokens", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("session stepped up", slog.Any("amr", methods))
+
+	return pair, nil
+}
+
+// checkStepUpFactor checks the password of the user, or the TOTP code if
+// otp is set, and returns the amr method it proves.
+func (a *Auth) checkStepUpFactor(
+	ctx context.Context,
+	log *slog.Logger,
+	user models.User,
+	password string,
+	otp string,
+) (string, error) {
+	if err := a.checkLockout(log, user); err != nil {
+		return "", err
+	}
+
+	if otp != "" {
+		if err := a.checkTOTP(ctx, log, int64(user.ID), otp); err != nil {
+			if errors.Is(err, ErrInvalidMFACode) {
+				if failErr := a.recordFailedLogin(ctx, log, user); failErr != nil {
+					return "", failErr
+				}
+			}
+			return "", err
+		}
+
+		return amrOTP, nil
+	}
+
+	if err := passhash.Compare(user.PassHash, password); err != nil {
+		log.Warn("invalid credentials", sl.Err(err))
+
+		if err = a.recordFailedLogin(ctx, log, user); err != nil {
+			return "", err
+		}
+
+		return "", ErrInvalidCredentials
+	}
+
+	return amrPassword, nil
+}
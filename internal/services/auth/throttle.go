@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/lib/logger/sl"
+	"strings"
+	"time"
+)
+
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+
+// throttleKey is a key failed logins are counted by, along with the limit
+// of failures for it. Keys with a zero limit are not throttled.
+type throttleKey struct {
+	key   string
+	limit int
+}
+
+// throttleKeys returns the keys of the login and of the IP the request
+// comes from, unless the IP is in a bypass network. Logins are counted
+// case-insensitively like users are looked up.
+func (a *Auth) throttleKeys(ctx context.Context, login string) []throttleKey {
+	keys := []throttleKey{{
+		key:   "login:" + strings.ToLower(strings.TrimSpace(login)),
+		limit: a.cfg.LoginThrottleEmailLimit,
+	}}
+
+	ip, err := netip.ParseAddr(clientinfo.FromContext(ctx).IP)
+	if err != nil {
+		return keys
+	}
+	ip = ip.Unmap()
+	for _, network := range a.cfg.LoginThrottleBypass {
+		if network.Contains(ip) {
+			return keys
+		}
+	}
+
+	return append(keys, throttleKey{key: "ip:" + ip.String(), limit: a.cfg.LoginThrottleIPLimit})
+}
+
+// checkThrottle fails with ErrTooManyAttempts once the limit of failed logins
+// within LoginThrottleWindow is reached for any of the keys. The throttle
+// fails open: logins go on if the failures can not be counted.
+func (a *Auth) checkThrottle(ctx context.Context, log *slog.Logger, keys []throttleKey) error {
+	since := time.Now().Add(-a.cfg.LoginThrottleWindow)
+	for _, key := range keys {
+		if key.limit <= 0 {
+			continue
+		}
+
+		attempts, err := a.throttle.Attempts(ctx, key.key, since)
+		if err != nil {
+			log.Error("failed to count failed logins", sl.Err(err))
+			continue
+		}
+
+		if attempts >= key.limit {
+			log.Warn("login throttled", slog.String("key", key.key), slog.Int("failed_logins", attempts))
+			return ErrTooManyAttempts
+		}
+	}
+
+	return nil
+}
+
+// recordThrottledFailure counts a failed login for each of the keys.
+func (a *Auth) recordThrottledFailure(ctx context.Context, log *slog.Logger, keys []throttleKey) {
+	now := time.Now()
+	for _, key := range keys {
+		if key.limit <= 0 {
+			continue
+		}
+
+		if err := a.throttle.RecordAttempt(ctx, key.key, now, a.cfg.LoginThrottleWindow); err != nil {
+			log.Error("failed to record failed login", sl.Err(err))
+		}
+	}
+}
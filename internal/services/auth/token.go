@@ -0,0 +1,510 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/randtoken"
+	"sso/internal/lib/tokens"
+	"sso/internal/storage"
+	"strconv"
+	"time"
+)
+
+// ValidateToken verifies the access token and checks that it was not revoked.
+// If audience is not empty, the token must have been issued for the app the
+// audience belongs to, or ValidateToken fails with ErrWrongAudience.
+func (a *Auth) ValidateToken(ctx context.Context, token string, audience string) (tokens.Claims, error) {
+	const op = "services.auth.ValidateToken"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	appID, err := a.tokens.AppID(ctx, token)
+	if err != nil {
+		if errors.Is(err, tokens.ErrInvalidToken) {
+			log.Info("malformed token", sl.Err(err))
+			return tokens.Claims{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		log.Error("failed to get token app", sl.Err(err))
+		return tokens.Claims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return tokens.Claims{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return tokens.Claims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims, err := a.verifyToken(ctx, log, token, app)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			log.Info("token is not valid", sl.Err(err))
+		} else {
+			log.Error("failed to verify token", sl.Err(err))
+		}
+		return tokens.Claims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if audience != "" {
+		// the audience resolves to a single app, which must be the one the
+		// token was verified as issued for
+		expected, err := a.appProvider.AppByAudience(ctx, audience)
+		if err != nil && !errors.Is(err, storage.ErrAppNotFound) {
+			log.Error("failed to get audience app", sl.Err(err))
+			return tokens.Claims{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if err != nil || expected.ID != app.ID {
+			log.Warn("token audience mismatch",
+				slog.String("audience", claims.Audience),
+				slog.String("expected_audience", audience),
+			)
+			return tokens.Claims{}, fmt.Errorf("%s: %w", op, ErrWrongAudience)
+		}
+	}
+
+	if a.cfg.SlidingSessions {
+		if err = a.tokens.Extend(ctx, token, a.accessTokenTTL(app), a.cfg.SessionMaxAge); err != nil {
+			log.Error("failed to extend session", sl.Err(err))
+		}
+	}
+
+	return claims, nil
+}
+
+// Logout revokes the access token and, if given, the refresh token family
+// it was issued with. The access token must then have been issued for the
+// app of the refresh token.
+func (a *Auth) Logout(ctx context.Context, accessToken string, refreshToken string) error {
+	const op = "services.auth.Logout"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	var current *models.RefreshToken
+	audience := ""
+	if refreshToken != "" {
+		found, err := a.tokenStorage.RefreshToken(ctx, randtoken.Hash(refreshToken))
+		switch {
+		case errors.Is(err, storage.ErrRefreshTokenNotFound):
+			log.Warn("refresh token not found")
+		case err != nil:
+			log.Error("failed to get refresh token", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		default:
+			app, err := a.appProvider.App(ctx, found.AppID)
+			if err != nil {
+				if errors.Is(err, storage.ErrAppNotFound) {
+					log.Warn("refresh token app not found", sl.Err(err))
+					return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+				}
+
+				log.Error("failed to get refresh token app", sl.Err(err))
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			current = &found
+			audience = tokens.Audience(app)
+		}
+	}
+
+	claims, err := a.ValidateToken(ctx, accessToken, audience)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", claims.UserID))
+
+	log.Info("logging out user")
+
+	if err = a.denylist.Deny(ctx, claims.ID, claims.ExpiresAt); err != nil {
+		log.Error("failed to deny access token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.tokens.Revoke(ctx, accessToken, claims.ID); err != nil {
+		log.Error("failed to revoke access token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	switch {
+	case current == nil:
+	case current.UserID != claims.UserID:
+		log.Warn("refresh token belongs to another user")
+	default:
+		if err = a.tokenStorage.RevokeRefreshTokenFamily(ctx, current.FamilyID); err != nil {
+			log.Error("failed to revoke refresh tokens", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	log.Info("user logged out")
+
+	return nil
+}
+
+// RevokeUserSessions logs the user out everywhere: every token issued to the
+// user so far stops being valid and all refresh tokens are revoked.
+func (a *Auth) RevokeUserSessions(ctx context.Context, userID int64) error {
+	const op = "services.auth.RevokeUserSessions"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("revoking user sessions")
+
+	if err := a.revokeSessions(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to revoke user sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user sessions revoked")
+
+	return nil
+}
+
+// DenyTokens puts the access tokens of the issuances on the denylist until
+// they expire, for tokens revoked in bulk, such as those of an app.
+func (a *Auth) DenyTokens(ctx context.Context, issuances []models.TokenIssuance) error {
+	const op = "services.auth.DenyTokens"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("tokens", len(issuances)),
+	)
+
+	for _, issuance := range issuances {
+		if err := a.denylist.Deny(ctx, issuance.ID, issuance.ExpiresAt); err != nil {
+			log.Error("failed to deny access token", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	log.Info("access tokens denied")
+
+	return nil
+}
+
+// revokeSessions invalidates every token issued to the user so far by
+// bumping the user token version, revokes all refresh tokens and forgets
+// the trusted devices.
+func (a *Auth) revokeSessions(ctx context.Context, userID int64) error {
+	if err := a.userSaver.IncrementTokenVersion(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := a.tokenStorage.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	return a.mfa.DeleteUserTrustedDevices(ctx, userID)
+}
+
+// Introspect reports whether the token is active for the app that asks about it.
+// The app authenticates with its id and secret; tokens issued for other apps
+// are reported as inactive.
+func (a *Auth) Introspect(ctx context.Context, token string, appID int, appSecret string) (models.TokenIntrospection, error) {
+	const op = "services.auth.Introspect"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	log.Info("introspecting token")
+
+	app, err := a.authenticateApp(ctx, log, appID, appSecret)
+	if err != nil {
+		return models.TokenIntrospection{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims, err := a.verifyToken(ctx, log, token, app)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			log.Info("token is not active", sl.Err(err))
+			return models.TokenIntrospection{Active: false}, nil
+		}
+
+		log.Error("failed to verify token", sl.Err(err))
+		return models.TokenIntrospection{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("token is active", slog.Int64("user_id", claims.UserID))
+
+	return models.TokenIntrospection{
+		Active:      true,
+		UserID:      claims.UserID,
+		AppID:       claims.AppID,
+		Issuer:      claims.Issuer,
+		Audience:    claims.Audience,
+		Scopes:      claims.Scopes,
+		AuthTime:    claims.AuthTime,
+		AuthMethods: claims.AuthMethods,
+		ACR:         claims.ACR,
+		ExpiresAt:   claims.ExpiresAt,
+	}, nil
+}
+
+// AppLogin implements the client credentials grant: the app authenticates
+// with its own secret and gets a token without a user subject.
+func (a *Auth) AppLogin(ctx context.Context, appID int, appSecret string, scopes []string) (models.TokenPair, error) {
+	const op = "services.auth.AppLogin"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	log.Info("logging app")
+
+	app, err := a.authenticateApp(ctx, log, appID, appSecret)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = checkGrant(log, app, models.GrantClientCredentials); err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	granted, err := grantScopes(app, scopes)
+	if err != nil {
+		log.Warn("scope is not allowed for the app", slog.Any("scopes", scopes))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ttl := a.accessTokenTTL(app)
+
+	token, err := a.tokens.NewAppToken(ctx, app, granted, ttl)
+	if err != nil {
+		log.Error("failed to generate token", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app logged in successfully")
+
+	return models.TokenPair{
+		AccessToken: token,
+		ExpiresIn:   ttl,
+		Scopes:      granted,
+	}, nil
+}
+
+// ExchangeToken implements RFC 8693 delegation: the app presents a token it
+// received and gets a token for the same user that is valid for the target
+// audience. The new token records the app in its act claim and does not
+// outlive the subject token. It carries the scopes of the subject token that
+// the target app has, which the user must have consented to if the target
+// app is third-party, and the user must belong to the org of the target app
+// as they would to log in to it.
+func (a *Auth) ExchangeToken(
+	ctx context.Context,
+	subjectToken string,
+	appID int,
+	appSecret string,
+	audience string,
+) (models.TokenPair, error) {
+	const op = "services.auth.ExchangeToken"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+		slog.String("audience", audience),
+	)
+
+	log.Info("exchanging token")
+
+	actorApp, err := a.authenticateApp(ctx, log, appID, appSecret)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// apps only exchange the tokens issued for them
+	claims, err := a.ValidateToken(ctx, subjectToken, tokens.Audience(actorApp))
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if claims.UserID == 0 {
+		log.Warn("subject token has no user")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	targetApp, err := a.appProvider.AppByAudience(ctx, audience)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("target app not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidTarget)
+		}
+
+		log.Error("failed to get target app", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("subject token user not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// apps of the default org are open to users of every org
+	if targetApp.OrgID != models.DefaultOrgID && user.OrgID != targetApp.OrgID {
+		log.Warn("user does not belong to the org of the target app", slog.Int64("org_id", targetApp.OrgID))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrOrgMismatch)
+	}
+
+	// the exchange narrows the scopes of the subject token, never widens them
+	scopes := slices.DeleteFunc(slices.Clone(claims.Scopes), func(scope string) bool {
+		return !slices.Contains(targetApp.Scopes, scope)
+	})
+
+	if err = a.checkConsent(ctx, claims.UserID, targetApp, scopes); err != nil {
+		if errors.Is(err, ErrConsentRequired) {
+			log.Warn("user has not consented to the scopes", slog.Any("scopes", scopes))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to check consent", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ttl := a.accessTokenTTL(targetApp)
+	if remaining := time.Until(claims.ExpiresAt); remaining < ttl {
+		ttl = remaining
+	}
+
+	actor := "app:" + strconv.Itoa(actorApp.ID)
+
+	token, err := a.tokens.NewDelegatedToken(ctx, user, targetApp, scopes, ttl, actor, claims.Actor)
+	if err != nil {
+		log.Error("failed to generate token", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("token exchanged", slog.Int64("user_id", claims.UserID), slog.Int("target_app_id", targetApp.ID))
+
+	return models.TokenPair{
+		AccessToken: token,
+		ExpiresIn:   ttl,
+	}, nil
+}
+
+// AuthenticateApp checks the credentials of the app, failing with
+// ErrInvalidClient if they are wrong.
+func (a *Auth) AuthenticateApp(ctx context.Context, appID int, appSecret string) error {
+	const op = "services.auth.AuthenticateApp"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	if _, err := a.authenticateApp(ctx, log, appID, appSecret); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// authenticateApp checks the app credentials. It fails with ErrInvalidClient
+// if the app does not exist or the secret does not match.
+func (a *Auth) authenticateApp(ctx context.Context, log *slog.Logger, appID int, appSecret string) (models.App, error) {
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.App{}, ErrInvalidClient
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return models.App{}, err
+	}
+
+	if !secretMatches(app, appSecret, time.Now()) {
+		log.Warn("invalid app secret")
+		return models.App{}, ErrInvalidClient
+	}
+
+	return app, nil
+}
+
+// secretMatches tells whether the secret is the one of the app, or the one
+// it replaced while that one is still accepted.
+func secretMatches(app models.App, secret string, now time.Time) bool {
+	hash := []byte(randtoken.Hash(secret))
+	if subtle.ConstantTimeCompare([]byte(app.SecretHash), hash) == 1 {
+		return true
+	}
+
+	return app.PreviousSecretHash != "" &&
+		app.PreviousSecretExpiresAt != nil && now.Before(*app.PreviousSecretExpiresAt) &&
+		subtle.ConstantTimeCompare([]byte(app.PreviousSecretHash), hash) == 1
+}
+
+// verifyToken checks the token signature and expiration against the app
+// and makes sure it is not on the denylist. Presenting a denied token is
+// recorded in the audit log as a replay.
+func (a *Auth) verifyToken(ctx context.Context, log *slog.Logger, token string, app models.App) (tokens.Claims, error) {
+	claims, err := a.tokens.Parse(ctx, token, app)
+	if err != nil {
+		if errors.Is(err, tokens.ErrInvalidToken) {
+			return tokens.Claims{}, fmt.Errorf("%w: %s", ErrInvalidToken, err.Error())
+		}
+		return tokens.Claims{}, err
+	}
+
+	denied, err := a.denylist.IsDenied(ctx, claims.ID)
+	if err != nil {
+		return tokens.Claims{}, err
+	}
+	if denied {
+		a.audit(ctx, log, models.AuditEvent{
+			Type:    models.AuditRevokedTokenReplay,
+			UserID:  claims.UserID,
+			AppID:   claims.AppID,
+			TokenID: claims.ID,
+		})
+		return tokens.Claims{}, fmt.Errorf("%w: token is revoked", ErrInvalidToken)
+	}
+
+	if claims.UserID == 0 {
+		// app token, there is no user to check
+		return claims, nil
+	}
+
+	user, err := a.userProvider.UserByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return tokens.Claims{}, fmt.Errorf("%w: user not found", ErrInvalidToken)
+		}
+		return tokens.Claims{}, err
+	}
+	if user.TokenVersion != claims.TokenVersion {
+		return tokens.Claims{}, fmt.Errorf("%w: user sessions were revoked", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/clientinfo"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage"
+	"time"
+)
+
+var ErrTrustedDeviceNotFound = errors.New("trusted device not found")
+
+// ListTrustedDevices returns the devices the user skips the second factor
+// on, the most recently used first.
+func (a *Auth) ListTrustedDevices(ctx context.Context, userID int64) ([]models.TrustedDevice, error) {
+	const op = "services.auth.ListTrustedDevices"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	devices, err := a.mfa.TrustedDevices(ctx, userID)
+	if err != nil {
+		log.Error("failed to get trusted devices", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return devices, nil
+}
+
+// RevokeTrustedDevice makes the device ask for the second factor again. It
+// fails with ErrTrustedDeviceNotFound if the device is not trusted by the
+// user.
+func (a *Auth) RevokeTrustedDevice(ctx context.Context, userID int64, deviceID int64) error {
+	const op = "services.auth.RevokeTrustedDevice"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int64("device_id", deviceID),
+	)
+
+	log.Info("revoking trusted device")
+
+	if err := a.mfa.DeleteTrustedDevice(ctx, userID, deviceID); err != nil {
+		if errors.Is(err, storage.ErrTrustedDeviceNotFound) {
+			log.Warn("trusted device not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrTrustedDeviceNotFound)
+		}
+
+		log.Error("failed to delete trusted device", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("trusted device revoked")
+
+	return nil
+}
+
+// trustDevice remembers the device of the request for the user and returns
+// the token it proves itself with. The token is bound to the user agent of
+// the device. It returns no token if trusted devices are disabled.
+func (a *Auth) trustDevice(ctx context.Context, userID int64) (string, error) {
+	if a.cfg.TrustedDeviceTTL <= 0 {
+		return "", nil
+	}
+
+	token, hash, err := randtoken.New()
+	if err != nil {
+		return "", err
+	}
+
+	client := clientinfo.FromContext(ctx)
+	now := time.Now()
+
+	err = a.mfa.SaveTrustedDevice(ctx, models.TrustedDevice{
+		UserID:          userID,
+		TokenHash:       hash,
+		FingerprintHash: randtoken.Hash(client.UserAgent),
+		IP:              client.IP,
+		Device:          clientinfo.Device(client.UserAgent),
+		CreatedAt:       now,
+		LastUsedAt:      now,
+		ExpiresAt:       now.Add(a.cfg.TrustedDeviceTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// trustedDevice reports whether the request comes from a device the user
+// trusts: it presents an unexpired device token of the user with the user
+// agent the token was issued to.
+func (a *Auth) trustedDevice(ctx context.Context, log *slog.Logger, userID int64) (bool, error) {
+	client := clientinfo.FromContext(ctx)
+	if client.DeviceToken == "" || a.cfg.TrustedDeviceTTL <= 0 {
+		return false, nil
+	}
+
+	device, err := a.mfa.TrustedDevice(ctx, randtoken.Hash(client.DeviceToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrTrustedDeviceNotFound) {
+			log.Warn("unknown device token")
+			return false, nil
+		}
+		return false, err
+	}
+
+	now := time.Now()
+	switch {
+	case device.UserID != userID:
+		log.Warn("device token belongs to another user")
+		return false, nil
+	case now.After(device.ExpiresAt):
+		log.Info("device token is expired")
+		return false, nil
+	case device.FingerprintHash != randtoken.Hash(client.UserAgent):
+		log.Warn("device token presented by another user agent", slog.Int64("device_id", device.ID))
+		return false, nil
+	}
+
+	if err = a.mfa.TouchTrustedDevice(ctx, device.ID, client.IP, now); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
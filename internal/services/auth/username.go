@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/username"
+	"sso/internal/storage"
+)
+
+var (
+	ErrInvalidUsername = errors.New("invalid username")
+	ErrUsernameTaken   = errors.New("username already taken")
+)
+
+// SetUsername sets the username the user can log in with instead of the
+// email. The username is normalized first, see username.Normalize.
+func (a *Auth) SetUsername(ctx context.Context, userID int64, name string) error {
+	const op = "services.auth.SetUsername"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("setting username")
+
+	name = username.Normalize(name)
+	if !username.Valid(name) {
+		log.Warn("invalid username")
+		return fmt.Errorf("%s: %w", op, ErrInvalidUsername)
+	}
+
+	if err := a.userSaver.SetUsername(ctx, userID, name); err != nil {
+		if errors.Is(err, storage.ErrUsernameTaken) {
+			log.Warn("username belongs to another user", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUsernameTaken)
+		}
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to set username", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("username set")
+
+	return nil
+}
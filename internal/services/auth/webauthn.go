@@ -0,0 +1,415 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage"
+	"strconv"
+	"time"
+)
+
+// Passkey logins prove possession of a key with user verification (RFC 8176).
+var amrWebAuthn = []string{"hwk", "user"}
+
+var (
+	ErrInvalidWebAuthnSession   = errors.New("invalid webauthn session")
+	ErrInvalidWebAuthnResponse  = errors.New("invalid webauthn response")
+	ErrWebAuthnCredentialExists = errors.New("webauthn credential already registered")
+)
+
+// BeginWebAuthnRegistration starts registering a passkey for the user.
+func (a *Auth) BeginWebAuthnRegistration(ctx context.Context, userID int64) (models.WebAuthnOptions, error) {
+	const op = "services.auth.BeginWebAuthnRegistration"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("beginning webauthn registration")
+
+	user, err := a.webAuthnUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.WebAuthnOptions{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.WebAuthnOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, credential := range user.credentials {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, session, err := a.webAuthn.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(exclusions),
+	)
+	if err != nil {
+		log.Error("failed to begin webauthn registration", sl.Err(err))
+		return models.WebAuthnOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	options, err := a.saveWebAuthnSession(ctx, models.WebAuthnSession{
+		Ceremony: models.WebAuthnRegistration,
+		UserID:   userID,
+	}, session, creation)
+	if err != nil {
+		log.Error("failed to save webauthn session", sl.Err(err))
+		return models.WebAuthnOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return options, nil
+}
+
+// FinishWebAuthnRegistration verifies the attestation the browser created for
+// the session and stores the passkey.
+func (a *Auth) FinishWebAuthnRegistration(ctx context.Context, userID int64, sessionToken string, response []byte) error {
+	const op = "services.auth.FinishWebAuthnRegistration"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("finishing webauthn registration")
+
+	session, data, err := a.useWebAuthnSession(ctx, log, sessionToken, models.WebAuthnRegistration)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if session.UserID != userID {
+		log.Warn("webauthn session belongs to another user")
+		return fmt.Errorf("%s: %w", op, ErrInvalidWebAuthnSession)
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		log.Warn("failed to parse webauthn response", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, ErrInvalidWebAuthnResponse)
+	}
+
+	user, err := a.webAuthnUser(ctx, userID)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	credential, err := a.webAuthn.CreateCredential(user, data, parsed)
+	if err != nil {
+		log.Warn("invalid webauthn attestation", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, ErrInvalidWebAuthnResponse)
+	}
+
+	record, err := json.Marshal(credential)
+	if err != nil {
+		log.Error("failed to encode webauthn credential", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.passkeys.SaveWebAuthnCredential(ctx, models.WebAuthnCredential{
+		UserID:       userID,
+		CredentialID: credential.ID,
+		Data:         record,
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrWebAuthnCredentialExists) {
+			log.Warn("webauthn credential already registered", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrWebAuthnCredentialExists)
+		}
+
+		log.Error("failed to save webauthn credential", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("webauthn credential registered")
+
+	return nil
+}
+
+// BeginWebAuthnLogin starts a passkey login for the app. The browser offers
+// the passkeys it has for the relying party, so no user is named upfront.
+func (a *Auth) BeginWebAuthnLogin(ctx context.Context, appID int, scopes []string) (models.WebAuthnOptions, error) {
+	const op = "services.auth.BeginWebAuthnLogin"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	log.Info("beginning webauthn login")
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.WebAuthnOptions{}, fmt.Errorf("%s: %w", op, ErrInvalidAppID)
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return models.WebAuthnOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	granted, err := grantScopes(app, scopes)
+	if err != nil {
+		log.Warn("scope is not allowed for the app", slog.Any("scopes", scopes))
+		return models.WebAuthnOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	assertion, session, err := a.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		log.Error("failed to begin webauthn login", sl.Err(err))
+		return models.WebAuthnOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	options, err := a.saveWebAuthnSession(ctx, models.WebAuthnSession{
+		Ceremony: models.WebAuthnLogin,
+		AppID:    app.ID,
+		Scopes:   granted,
+	}, session, assertion)
+	if err != nil {
+		log.Error("failed to save webauthn session", sl.Err(err))
+		return models.WebAuthnOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return options, nil
+}
+
+// FinishWebAuthnLogin verifies the assertion the browser signed for the
+// session and issues the same tokens as a password login.
+func (a *Auth) FinishWebAuthnLogin(ctx context.Context, sessionToken string, response []byte) (models.TokenPair, error) {
+	const op = "services.auth.FinishWebAuthnLogin"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("finishing webauthn login")
+
+	session, data, err := a.useWebAuthnSession(ctx, log, sessionToken, models.WebAuthnLogin)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		log.Warn("failed to parse webauthn response", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidWebAuthnResponse)
+	}
+
+	found, credential, err := a.webAuthn.ValidatePasskeyLogin(func(_, userHandle []byte) (webauthn.User, error) {
+		userID, err := strconv.ParseInt(string(userHandle), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		return a.webAuthnUser(ctx, userID)
+	}, data, parsed)
+	if err != nil {
+		log.Warn("invalid webauthn assertion", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidWebAuthnResponse)
+	}
+
+	user := found.(*webAuthnUser).user
+	log = log.With(slog.Int("user_id", user.ID))
+
+	if credential.Authenticator.CloneWarning {
+		log.Warn("webauthn signature counter went back, the authenticator may be cloned")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidWebAuthnResponse)
+	}
+
+	record, err := json.Marshal(credential)
+	if err != nil {
+		log.Error("failed to encode webauthn credential", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.passkeys.UpdateWebAuthnCredential(ctx, credential.ID, record, time.Now()); err != nil {
+		log.Error("failed to update webauthn credential", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.cfg.RequireVerifiedEmail && !user.EmailVerified {
+		log.Warn("email is not verified")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrEmailNotVerified)
+	}
+
+	if err = a.checkExpiry(log, user); err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = a.runPreLogin(ctx, log, user, session.AppID, amrWebAuthn); err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appProvider.App(ctx, session.AppID)
+	if err != nil {
+		log.Error("failed to get app", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.PasswordChangeRequired {
+		pair, err := a.passwordChangeToken(ctx, log, user)
+		if err != nil {
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return pair, nil
+	}
+
+	familyID, _, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate token family", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pair, err := a.issueTokens(ctx, user, app, session.Scopes, amrWebAuthn, familyID, nil)
+	if err != nil {
+		log.Error("failed to issue tokens", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user logged in with webauthn")
+
+	return pair, nil
+}
+
+// webAuthnUser adapts a user and its passkeys to the webauthn library.
+type webAuthnUser struct {
+	user        models.User
+	credentials []webauthn.Credential
+}
+
+// WebAuthnID is the user handle. It is the user id, which tells nothing
+// about the user by itself.
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(strconv.Itoa(u.user.ID))
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func (a *Auth) webAuthnUser(ctx context.Context, userID int64) (*webAuthnUser, error) {
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := a.passkeys.WebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(records))
+	for _, record := range records {
+		var credential webauthn.Credential
+		if err = json.Unmarshal(record.Data, &credential); err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+// saveWebAuthnSession stores the session data of a ceremony and returns the
+// options for the browser along with the session token.
+func (a *Auth) saveWebAuthnSession(
+	ctx context.Context,
+	session models.WebAuthnSession,
+	data *webauthn.SessionData,
+	options any,
+) (models.WebAuthnOptions, error) {
+	token, hash, err := randtoken.New()
+	if err != nil {
+		return models.WebAuthnOptions{}, err
+	}
+
+	encodedData, err := json.Marshal(data)
+	if err != nil {
+		return models.WebAuthnOptions{}, err
+	}
+
+	encodedOptions, err := json.Marshal(options)
+	if err != nil {
+		return models.WebAuthnOptions{}, err
+	}
+
+	now := time.Now()
+	session.TokenHash = hash
+	session.Data = encodedData
+	session.ExpiresAt = now.Add(a.cfg.WebAuthnSessionTTL)
+	session.CreatedAt = now
+
+	if err = a.passkeys.SaveWebAuthnSession(ctx, session); err != nil {
+		return models.WebAuthnOptions{}, err
+	}
+
+	return models.WebAuthnOptions{Session: token, Options: encodedOptions}, nil
+}
+
+// useWebAuthnSession finishes the ceremony of the session token. Each session
+// can be finished once.
+func (a *Auth) useWebAuthnSession(
+	ctx context.Context,
+	log *slog.Logger,
+	token string,
+	ceremony models.WebAuthnCeremony,
+) (models.WebAuthnSession, webauthn.SessionData, error) {
+	session, err := a.passkeys.WebAuthnSession(ctx, randtoken.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrWebAuthnSessionNotFound) {
+			log.Warn("webauthn session not found", sl.Err(err))
+			return models.WebAuthnSession{}, webauthn.SessionData{}, ErrInvalidWebAuthnSession
+		}
+
+		log.Error("failed to get webauthn session", sl.Err(err))
+		return models.WebAuthnSession{}, webauthn.SessionData{}, err
+	}
+
+	if session.Ceremony != ceremony || time.Now().After(session.ExpiresAt) {
+		log.Warn("webauthn session is not valid for the ceremony")
+		return models.WebAuthnSession{}, webauthn.SessionData{}, ErrInvalidWebAuthnSession
+	}
+
+	if err = a.passkeys.UseWebAuthnSession(ctx, session.ID); err != nil {
+		if errors.Is(err, storage.ErrWebAuthnSessionUsed) {
+			log.Warn("webauthn session already used", sl.Err(err))
+			return models.WebAuthnSession{}, webauthn.SessionData{}, ErrInvalidWebAuthnSession
+		}
+
+		log.Error("failed to use webauthn session", sl.Err(err))
+		return models.WebAuthnSession{}, webauthn.SessionData{}, err
+	}
+
+	var data webauthn.SessionData
+	if err = json.Unmarshal(session.Data, &data); err != nil {
+		log.Error("failed to decode webauthn session", sl.Err(err))
+		return models.WebAuthnSession{}, webauthn.SessionData{}, err
+	}
+
+	return session, data, nil
+}
@@ -0,0 +1,190 @@
+package management
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// apiKeyScheme starts every API key, so that leaked keys are easy to spot,
+// and maxAPIKeyName caps the length of the names keys are given.
+const (
+	apiKeyScheme  = "sso_"
+	maxAPIKeyName = 64
+)
+
+// IssueAPIKey issues a new API key named name for the app on behalf of the
+// admin. Keys look like sso_<prefix>_<secret>. Only the hash of the key is
+// stored, so the returned key is the only place it is shown.
+func (m *Management) IssueAPIKey(ctx context.Context, adminID int64, appID int, name string) (models.APIKey, error) {
+	const op = "services.management.IssueAPIKey"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.Int("app_id", appID),
+	)
+
+	log.Info("issuing api key")
+
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxAPIKeyName {
+		log.Warn("invalid api key name")
+		return models.APIKey{}, fmt.Errorf("%s: %w: name must be 1 to %d characters", op, ErrInvalidAPIKey, maxAPIKeyName)
+	}
+
+	app, err := m.App(ctx, appID)
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if app.Status == models.AppStatusDisabled {
+		log.Warn("app is disabled")
+		return models.APIKey{}, fmt.Errorf("%s: %w: app is disabled", op, ErrInvalidAPIKey)
+	}
+
+	b := make([]byte, 4)
+	if _, err = rand.Read(b); err != nil {
+		log.Error("failed to generate prefix", sl.Err(err))
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+	secret, _, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate key", sl.Err(err))
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key := models.APIKey{
+		AppID:     appID,
+		Name:      name,
+		Prefix:    hex.EncodeToString(b),
+		CreatedBy: adminID,
+		CreatedAt: time.Now(),
+	}
+	key.Key = apiKeyScheme + key.Prefix + "_" + secret
+	key.KeyHash = randtoken.Hash(key.Key)
+
+	key.ID, err = m.apiKeys.SaveAPIKey(ctx, key)
+	if err != nil {
+		log.Error("failed to save api key", sl.Err(err))
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("api key issued", slog.Int64("key_id", key.ID), slog.String("prefix", key.Prefix))
+
+	key.KeyHash = ""
+
+	return key, nil
+}
+
+// APIKeys returns the keys of the app, the revoked ones included, newest
+// first, without their hashes.
+func (m *Management) APIKeys(ctx context.Context, appID int) ([]models.APIKey, error) {
+	const op = "services.management.APIKeys"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	if _, err := m.App(ctx, appID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys, err := m.apiKeys.APIKeys(ctx, appID)
+	if err != nil {
+		log.Error("failed to get api keys", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range keys {
+		keys[i].KeyHash = ""
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes the key of the app on behalf of the admin. It fails
+// with ErrAPIKeyNotFound if the app has no such key or it is revoked
+// already.
+func (m *Management) RevokeAPIKey(ctx context.Context, adminID int64, appID int, keyID int64) error {
+	const op = "services.management.RevokeAPIKey"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.Int("app_id", appID),
+		slog.Int64("key_id", keyID),
+	)
+
+	log.Info("revoking api key")
+
+	if err := m.apiKeys.RevokeAPIKey(ctx, appID, keyID, adminID, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.Warn("api key not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrAPIKeyNotFound)
+		}
+
+		log.Error("failed to revoke api key", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("api key revoked")
+
+	return nil
+}
+
+// ValidateAPIKey returns the key if it is a key of the app that is not
+// revoked, and records that it was used. Any other key fails with
+// ErrAPIKeyNotFound, keys of other apps included, so that apps can not probe
+// each other's keys.
+func (m *Management) ValidateAPIKey(ctx context.Context, appID int, key string) (models.APIKey, error) {
+	const op = "services.management.ValidateAPIKey"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	if !strings.HasPrefix(key, apiKeyScheme) {
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, ErrAPIKeyNotFound)
+	}
+
+	apiKey, err := m.apiKeys.APIKeyByHash(ctx, randtoken.Hash(key))
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.Warn("unknown api key")
+			return models.APIKey{}, fmt.Errorf("%s: %w", op, ErrAPIKeyNotFound)
+		}
+
+		log.Error("failed to get api key", sl.Err(err))
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("key_id", apiKey.ID))
+
+	if apiKey.AppID != appID || apiKey.RevokedAt != nil {
+		log.Warn("api key of another app or revoked")
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, ErrAPIKeyNotFound)
+	}
+
+	now := time.Now()
+	if err = m.apiKeys.TouchAPIKey(ctx, apiKey.ID, now); err != nil {
+		// a missed use is not worth failing the validation for
+		log.Error("failed to record api key use", sl.Err(err))
+	} else if apiKey.LastUsedAt == nil || apiKey.LastUsedAt.Before(now.Add(-time.Minute)) {
+		apiKey.LastUsedAt = &now
+	}
+	apiKey.KeyHash = ""
+
+	return apiKey, nil
+}
@@ -0,0 +1,415 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/randtoken"
+	"sso/internal/lib/redirecturi"
+	"sso/internal/lib/tokens"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+var (
+	// appName allows names such as "Billing portal".
+	appName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _.-]{0,63}$`)
+	// scopeName allows scopes such as "profile" or "invoices:read".
+	scopeName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.:-]{0,63}$`)
+)
+
+// maxAppScopes caps the scopes an app is allowed, maxAppRedirectURIs the
+// redirect URIs it registers, maxAppClaimsSize the size of its static
+// claims as JSON, and maxSecretGracePeriod how long a rotated secret can
+// still be accepted.
+const (
+	maxAppScopes         = 64
+	maxAppRedirectURIs   = 16
+	maxAppClaimsSize     = 4096
+	maxSecretGracePeriod = 7 * 24 * time.Hour
+)
+
+// Apps returns the apps of the org, or every app if orgID is zero, the
+// disabled ones included, ordered by id, without their credentials.
+func (m *Management) Apps(ctx context.Context, orgID int64) ([]models.App, error) {
+	const op = "services.management.Apps"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("org_id", orgID),
+	)
+
+	apps, err := m.apps.Apps(ctx, orgID)
+	if err != nil {
+		log.Error("failed to get apps", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range apps {
+		apps[i] = withoutCredentials(apps[i])
+	}
+
+	return apps, nil
+}
+
+// App returns the app even if it is disabled, without its credentials.
+func (m *Management) App(ctx context.Context, appID int) (models.App, error) {
+	const op = "services.management.App"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	app, err := m.apps.AppIncludingDisabled(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return withoutCredentials(app), nil
+}
+
+// CreateApp creates the app on behalf of the admin with a new id, a random
+// secret and a random signing key, in the default org unless app.OrgID names
+// another. Only the hash of the secret is stored, so
+// the returned app is the only place the secret is shown.
+func (m *Management) CreateApp(ctx context.Context, adminID int64, app models.App) (models.App, error) {
+	const op = "services.management.CreateApp"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.String("name", app.Name),
+	)
+
+	log.Info("creating app")
+
+	app, err := normalizeApp(app)
+	if err != nil {
+		log.Warn("invalid app", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w: %s", op, ErrInvalidApp, err.Error())
+	}
+
+	if app.OrgID == 0 {
+		app.OrgID = models.DefaultOrgID
+	}
+	if _, err = m.Org(ctx, app.OrgID); err != nil {
+		if errors.Is(err, ErrOrgNotFound) {
+			return models.App{}, fmt.Errorf("%s: %w: unknown org %d", op, ErrInvalidApp, app.OrgID)
+		}
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app.Secret, app.SecretHash, err = randtoken.New()
+	if err != nil {
+		log.Error("failed to generate secret", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+	app.SigningKey, _, err = randtoken.New()
+	if err != nil {
+		log.Error("failed to generate signing key", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+	app.CreatedAt = time.Now()
+	app.Status = models.AppStatusActive
+
+	app.ID, err = m.apps.SaveApp(ctx, app, adminID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppExists) {
+			log.Warn("app name or audience taken", sl.Err(err))
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrAppExists)
+		}
+
+		log.Error("failed to save app", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app created", slog.Int("app_id", app.ID))
+
+	secret := app.Secret
+	app = withoutCredentials(app)
+	app.Secret = secret
+
+	return app, nil
+}
+
+// UpdateApp replaces the settings of the app with the id of app on behalf
+// of the admin, and returns it. The secret, the org and the claim mappings
+// of the app are kept.
+func (m *Management) UpdateApp(ctx context.Context, adminID int64, app models.App) (models.App, error) {
+	const op = "services.management.UpdateApp"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.Int("app_id", app.ID),
+	)
+
+	log.Info("updating app")
+
+	app, err := normalizeApp(app)
+	if err != nil {
+		log.Warn("invalid app", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w: %s", op, ErrInvalidApp, err.Error())
+	}
+
+	if err = m.apps.UpdateApp(ctx, app, adminID, time.Now()); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrAppNotFound):
+			log.Warn("app not found", sl.Err(err))
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		case errors.Is(err, storage.ErrAppExists):
+			log.Warn("app name or audience taken", sl.Err(err))
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrAppExists)
+		}
+
+		log.Error("failed to update app", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	updated, err := m.App(ctx, app.ID)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app updated")
+
+	return updated, nil
+}
+
+// DisableApp disables the app on behalf of the admin: users can no longer
+// log in to it or refresh its tokens. Disabling a disabled app does nothing.
+func (m *Management) DisableApp(ctx context.Context, adminID int64, appID int) error {
+	const op = "services.management.DisableApp"
+
+	if _, err := m.SetAppStatus(ctx, adminID, appID, models.AppStatusDisabled, false); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetAppStatus gives the app the status on behalf of the admin and returns
+// the app. Logins to disabled apps and refreshes of their tokens fail, and
+// their access tokens are not valid while they are disabled. With
+// revokeTokens, the tokens issued for the app are revoked for good, so that
+// they stay invalid if the app is enabled again. Setting the status the app
+// has already does nothing unless tokens are revoked.
+func (m *Management) SetAppStatus(
+	ctx context.Context,
+	adminID int64,
+	appID int,
+	status string,
+	revokeTokens bool,
+) (models.App, error) {
+	const op = "services.management.SetAppStatus"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.Int("app_id", appID),
+		slog.String("status", status),
+		slog.Bool("revoke_tokens", revokeTokens),
+	)
+
+	log.Info("setting app status")
+
+	switch status {
+	case models.AppStatusActive, models.AppStatusDeprecated, models.AppStatusDisabled:
+	default:
+		log.Warn("unknown app status")
+		return models.App{}, fmt.Errorf("%s: %w: unknown status %q", op, ErrInvalidApp, status)
+	}
+
+	app, err := m.App(ctx, appID)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if app.Status == status && !revokeTokens {
+		return app, nil
+	}
+
+	revoked, err := m.apps.SetAppStatus(ctx, appID, status, revokeTokens, adminID, time.Now())
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		log.Error("failed to set app status", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(revoked) > 0 {
+		if err = m.denier.DenyTokens(ctx, revoked); err != nil {
+			log.Error("failed to deny revoked tokens", sl.Err(err))
+			return models.App{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	log.Info("app status set", slog.Int("revoked_tokens", len(revoked)))
+
+	return m.App(ctx, appID)
+}
+
+// RotateAppSecret replaces the secret of the app with a random one on behalf
+// of the admin and returns the app with it, the only place it is shown. The
+// replaced secret is still accepted for the grace period, so that the app
+// can roll over without downtime. The signing key of the app is replaced as
+// well, so access and ID tokens issued for it before no longer verify, while
+// its refresh tokens stay valid and get new ones.
+func (m *Management) RotateAppSecret(ctx context.Context, adminID int64, appID int, grace time.Duration) (models.App, error) {
+	const op = "services.management.RotateAppSecret"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.Int("app_id", appID),
+		slog.Duration("grace", grace),
+	)
+
+	log.Info("rotating app secret")
+
+	if grace < 0 || grace > maxSecretGracePeriod {
+		log.Warn("invalid grace period")
+		return models.App{}, fmt.Errorf("%s: %w: grace period must be between 0 and %s",
+			op, ErrInvalidApp, maxSecretGracePeriod)
+	}
+
+	secret, hash, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate secret", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+	signingKey, _, err := randtoken.New()
+	if err != nil {
+		log.Error("failed to generate signing key", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	var previousExpiresAt *time.Time
+	if grace > 0 {
+		expiresAt := now.Add(grace)
+		previousExpiresAt = &expiresAt
+	}
+
+	if err = m.apps.RotateAppSecret(ctx, appID, hash, signingKey, previousExpiresAt, adminID, now); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		log.Error("failed to rotate app secret", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := m.App(ctx, appID)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+	app.Secret = secret
+
+	log.Info("app secret rotated")
+
+	return app, nil
+}
+
+// withoutCredentials returns the app without its secret, the hashes of its
+// secrets and its signing key.
+func withoutCredentials(app models.App) models.App {
+	app.Secret = ""
+	app.SecretHash = ""
+	app.PreviousSecretHash = ""
+	app.SigningKey = ""
+
+	return app
+}
+
+// ValidateApp validates the settings of the app and normalizes them as
+// CreateApp does, for apps created other than through the admin API.
+func ValidateApp(app models.App) (models.App, error) {
+	return normalizeApp(app)
+}
+
+// normalizeApp validates the settings of the app and returns it with its
+// name trimmed and its scopes, grant types and redirect URIs sorted, without
+// duplicates.
+func normalizeApp(app models.App) (models.App, error) {
+	app.Name = strings.TrimSpace(app.Name)
+	if !appName.MatchString(app.Name) {
+		return app, errors.New("name must consist of letters, digits, spaces, dots, dashes and underscores")
+	}
+
+	if len(app.Audience) > 256 || strings.ContainsAny(app.Audience, " \t\r\n") {
+		return app, errors.New("invalid audience")
+	}
+
+	switch app.TokenFormat {
+	case "", tokens.FormatJWT, tokens.FormatPASETO, tokens.FormatOpaque:
+	default:
+		return app, fmt.Errorf("unknown token format %q", app.TokenFormat)
+	}
+
+	if len(app.Scopes) > maxAppScopes {
+		return app, fmt.Errorf("an app is allowed at most %d scopes", maxAppScopes)
+	}
+	for _, scope := range app.Scopes {
+		if !scopeName.MatchString(scope) {
+			return app, fmt.Errorf("invalid scope %q", scope)
+		}
+	}
+	slices.Sort(app.Scopes)
+	app.Scopes = slices.Compact(app.Scopes)
+
+	for _, grant := range app.GrantTypes {
+		switch grant {
+		case models.GrantPassword, models.GrantClientCredentials, models.GrantDeviceCode, models.GrantRefreshToken:
+		default:
+			return app, fmt.Errorf("unknown grant type %q", grant)
+		}
+	}
+	slices.Sort(app.GrantTypes)
+	app.GrantTypes = slices.Compact(app.GrantTypes)
+
+	if len(app.RedirectURIs) > maxAppRedirectURIs {
+		return app, fmt.Errorf("an app can register at most %d redirect uris", maxAppRedirectURIs)
+	}
+	for _, uri := range app.RedirectURIs {
+		if err := redirecturi.Validate(uri); err != nil {
+			return app, fmt.Errorf("%q: %w", uri, err)
+		}
+	}
+	slices.Sort(app.RedirectURIs)
+	app.RedirectURIs = slices.Compact(app.RedirectURIs)
+
+	claims, err := json.Marshal(app.Claims)
+	if err != nil {
+		return app, errors.New("invalid claims")
+	}
+	if len(claims) > maxAppClaimsSize {
+		return app, errors.New("claims are too large")
+	}
+
+	if app.AccessTokenTTL < 0 || app.RefreshTokenTTL < 0 {
+		return app, errors.New("token lifetimes can not be negative")
+	}
+	if app.LoginQuota < 0 || app.RegistrationQuota < 0 {
+		return app, errors.New("quotas can not be negative")
+	}
+
+	return app, nil
+}
@@ -0,0 +1,53 @@
+package management
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+// defaultAppStatsRange is the range app stats cover unless told otherwise,
+// and maxAppStatsRange the longest one they can cover.
+const (
+	defaultAppStatsRange = 30 * 24 * time.Hour
+	maxAppStatsRange     = 366 * 24 * time.Hour
+)
+
+// AppStats returns the activity of the app from the time from up to to. A
+// zero to is now, and a zero from is 30 days before to. Ranges longer than a
+// year fail with ErrInvalidTimeRange.
+func (m *Management) AppStats(ctx context.Context, appID int, from time.Time, to time.Time) (models.AppStats, error) {
+	const op = "services.management.AppStats"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultAppStatsRange)
+	}
+	if !from.Before(to) || to.Sub(from) > maxAppStatsRange {
+		log.Warn("invalid time range", slog.Time("from", from), slog.Time("to", to))
+		return models.AppStats{}, fmt.Errorf("%s: %w: from must be before to, at most %s apart",
+			op, ErrInvalidTimeRange, maxAppStatsRange)
+	}
+
+	if _, err := m.App(ctx, appID); err != nil {
+		return models.AppStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stats, err := m.apps.AppStats(ctx, appID, from, to)
+	if err != nil {
+		log.Error("failed to get app stats", sl.Err(err))
+		return models.AppStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,37 @@
+package management
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+)
+
+// maxAuditEvents caps the events AuditEvents returns at once.
+const maxAuditEvents = 500
+
+// AuditEvents returns the audit events matching the filter, oldest first.
+// Downstream services poll it for events such as AuditAccountDeleted,
+// passing the id of the last event they have seen as AfterID.
+func (m *Management) AuditEvents(ctx context.Context, filter models.AuditEventFilter) ([]models.AuditEvent, error) {
+	const op = "services.management.AuditEvents"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("type", string(filter.Type)),
+		slog.Int64("after_id", filter.AfterID),
+	)
+
+	if filter.Limit <= 0 || filter.Limit > maxAuditEvents {
+		filter.Limit = maxAuditEvents
+	}
+
+	events, err := m.auditLog.AuditEvents(ctx, filter)
+	if err != nil {
+		log.Error("failed to get audit events", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,351 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"time"
+)
+
+// maxGroupRoles caps the roles a group carries.
+const maxGroupRoles = 64
+
+func (m *Management) Groups(ctx context.Context) ([]models.Group, error) {
+	const op = "services.management.Groups"
+
+	log := m.log.With(slog.String("op", op))
+
+	groups, err := m.groups.Groups(ctx)
+	if err != nil {
+		log.Error("failed to get groups", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return groups, nil
+}
+
+func (m *Management) Group(ctx context.Context, name string) (models.Group, error) {
+	const op = "services.management.Group"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("group", name),
+	)
+
+	group, err := m.groups.Group(ctx, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			log.Warn("group not found", sl.Err(err))
+			return models.Group{}, fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		}
+
+		log.Error("failed to get group", sl.Err(err))
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return group, nil
+}
+
+// SetGroup creates the group or replaces the description and the roles of
+// the one with the same name, and returns it. The roles must exist.
+func (m *Management) SetGroup(ctx context.Context, group models.Group) (models.Group, error) {
+	const op = "services.management.SetGroup"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("group", group.Name),
+	)
+
+	log.Info("setting group")
+
+	if err := validateGroup(group); err != nil {
+		log.Warn("invalid group", sl.Err(err))
+		return models.Group{}, fmt.Errorf("%s: %w: %s", op, ErrInvalidGroup, err.Error())
+	}
+
+	slices.Sort(group.Roles)
+	group.Roles = slices.Compact(group.Roles)
+
+	err := m.groups.UpdateGroup(ctx, group)
+	if errors.Is(err, storage.ErrGroupNotFound) {
+		group.CreatedAt = time.Now()
+		_, err = m.groups.SaveGroup(ctx, group)
+		if errors.Is(err, storage.ErrGroupExists) {
+			// created concurrently, the other request wins
+			err = m.groups.UpdateGroup(ctx, group)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrRoleNotFound) {
+			log.Warn("role not found", sl.Err(err))
+			return models.Group{}, fmt.Errorf("%s: %w: %s", op, ErrInvalidGroup, err.Error())
+		}
+
+		log.Error("failed to save group", sl.Err(err))
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	group, err = m.groups.Group(ctx, group.Name)
+	if err != nil {
+		log.Error("failed to get group", sl.Err(err))
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.permissions.invalidateAll()
+
+	log.Info("group set")
+
+	return group, nil
+}
+
+// DeleteGroup deletes the group, whose members lose the roles they had
+// through it.
+func (m *Management) DeleteGroup(ctx context.Context, name string) error {
+	const op = "services.management.DeleteGroup"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("group", name),
+	)
+
+	log.Info("deleting group")
+
+	if err := m.groups.DeleteGroup(ctx, name); err != nil {
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			log.Warn("group not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		}
+
+		log.Error("failed to delete group", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.permissions.invalidateAll()
+
+	log.Info("group deleted")
+
+	return nil
+}
+
+// AssignGroupRole gives the group the role on behalf of the admin, so that
+// its members have it globally. Giving a role the group carries does
+// nothing.
+func (m *Management) AssignGroupRole(ctx context.Context, adminID int64, name string, role string) error {
+	const op = "services.management.AssignGroupRole"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.String("group", name),
+		slog.String("role", role),
+	)
+
+	log.Info("assigning group role")
+
+	if err := validateGroupRole(role); err != nil {
+		log.Warn("invalid role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.groups.AssignGroupRole(ctx, name, role, adminID, time.Now()); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrGroupNotFound):
+			log.Warn("group not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		case errors.Is(err, storage.ErrRoleNotFound):
+			log.Warn("role not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrRoleNotFound)
+		}
+
+		log.Error("failed to assign group role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.permissions.invalidateAll()
+
+	log.Info("group role assigned")
+
+	return nil
+}
+
+// RevokeGroupRole takes the role from the group on behalf of the admin.
+// Revoking a role the group does not carry does nothing.
+func (m *Management) RevokeGroupRole(ctx context.Context, adminID int64, name string, role string) error {
+	const op = "services.management.RevokeGroupRole"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.String("group", name),
+		slog.String("role", role),
+	)
+
+	log.Info("revoking group role")
+
+	if err := validateGroupRole(role); err != nil {
+		log.Warn("invalid role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.groups.RevokeGroupRole(ctx, name, role, adminID, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			log.Warn("group not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		}
+
+		log.Error("failed to revoke group role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.permissions.invalidateAll()
+
+	log.Info("group role revoked")
+
+	return nil
+}
+
+// GroupMembers returns the ids of the members of the group, ascending.
+func (m *Management) GroupMembers(ctx context.Context, name string) ([]int64, error) {
+	const op = "services.management.GroupMembers"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("group", name),
+	)
+
+	members, err := m.groups.GroupMembers(ctx, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			log.Warn("group not found", sl.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		}
+
+		log.Error("failed to get group members", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return members, nil
+}
+
+// AddGroupMember adds the user to the group. Adding a member again does
+// nothing.
+func (m *Management) AddGroupMember(ctx context.Context, name string, userID int64) error {
+	const op = "services.management.AddGroupMember"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("group", name),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("adding group member")
+
+	if err := m.groups.AddGroupMember(ctx, name, userID, time.Now()); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrGroupNotFound):
+			log.Warn("group not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		case errors.Is(err, storage.ErrUserNotFound):
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to add group member", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.permissions.invalidateUser(userID)
+
+	log.Info("group member added")
+
+	return nil
+}
+
+// RemoveGroupMember removes the user from the group. Removing a user who is
+// not a member does nothing.
+func (m *Management) RemoveGroupMember(ctx context.Context, name string, userID int64) error {
+	const op = "services.management.RemoveGroupMember"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("group", name),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("removing group member")
+
+	if err := m.groups.RemoveGroupMember(ctx, name, userID); err != nil {
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			log.Warn("group not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		}
+
+		log.Error("failed to remove group member", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.permissions.invalidateUser(userID)
+
+	log.Info("group member removed")
+
+	return nil
+}
+
+// UserGroups returns the groups of the user.
+func (m *Management) UserGroups(ctx context.Context, userID int64) ([]models.Group, error) {
+	const op = "services.management.UserGroups"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	if _, err := m.isAdmin(ctx, log, userID, 0); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	groups, err := m.groups.UserGroups(ctx, userID)
+	if err != nil {
+		log.Error("failed to get groups", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return groups, nil
+}
+
+func validateGroup(group models.Group) error {
+	if !roleName.MatchString(group.Name) {
+		return errors.New("name must consist of lowercase letters, digits, dots, dashes and underscores")
+	}
+	if len(group.Description) > 256 {
+		return errors.New("description is too long")
+	}
+
+	if len(group.Roles) > maxGroupRoles {
+		return fmt.Errorf("a group carries at most %d roles", maxGroupRoles)
+	}
+	for _, role := range group.Roles {
+		// admins are made by the is_admin flag, not by groups
+		if role == RoleAdmin {
+			return fmt.Errorf("role %q can not be given to groups", role)
+		}
+	}
+
+	return nil
+}
+
+func validateGroupRole(role string) error {
+	if !roleName.MatchString(role) {
+		return fmt.Errorf("%w: invalid name", ErrInvalidRole)
+	}
+	if role == RoleAdmin {
+		return fmt.Errorf("%w: role %q can not be given to groups", ErrInvalidRole, role)
+	}
+
+	return nil
+}
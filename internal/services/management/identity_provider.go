@@ -0,0 +1,124 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/idp"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"time"
+)
+
+// identityProviderName restricts names to what can appear unescaped in the
+// login and callback paths.
+var identityProviderName = regexp.MustCompile(`^[a-z0-9-]{1,64}$`)
+
+// builtinIdentityProviders are configured in the config file and cannot be
+// shadowed by stored providers.
+var builtinIdentityProviders = []string{"google"}
+
+// IdentityProviders returns the upstream OpenID Connect providers users can
+// log in with besides the ones of the config file.
+func (m *Management) IdentityProviders(ctx context.Context) ([]models.IdentityProvider, error) {
+	const op = "services.management.IdentityProviders"
+
+	log := m.log.With(slog.String("op", op))
+
+	providers, err := m.idps.IdentityProviders(ctx)
+	if err != nil {
+		log.Error("failed to get identity providers", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return providers, nil
+}
+
+// SetIdentityProvider creates the provider or replaces the one with the same
+// name. The discovery document of the issuer must be reachable, so that a
+// mistyped issuer is reported now rather than on the first login.
+func (m *Management) SetIdentityProvider(ctx context.Context, provider models.IdentityProvider) error {
+	const op = "services.management.SetIdentityProvider"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("name", provider.Name),
+		slog.String("issuer", provider.Issuer),
+	)
+
+	log.Info("setting identity provider")
+
+	if err := validateIdentityProvider(provider); err != nil {
+		log.Warn("invalid identity provider", sl.Err(err))
+		return fmt.Errorf("%s: %w: %s", op, ErrInvalidIdentityProvider, err.Error())
+	}
+
+	if _, err := idp.Discover(ctx, provider.Issuer, idp.Config{}); err != nil {
+		log.Warn("failed to discover identity provider", sl.Err(err))
+		return fmt.Errorf("%s: %w: %s", op, ErrInvalidIdentityProvider, err.Error())
+	}
+
+	provider.CreatedAt = time.Now()
+	if err := m.idps.SaveIdentityProvider(ctx, provider); err != nil {
+		log.Error("failed to save identity provider", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("identity provider set")
+
+	return nil
+}
+
+func (m *Management) DeleteIdentityProvider(ctx context.Context, name string) error {
+	const op = "services.management.DeleteIdentityProvider"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("name", name),
+	)
+
+	log.Info("deleting identity provider")
+
+	if err := m.idps.DeleteIdentityProvider(ctx, name); err != nil {
+		if errors.Is(err, storage.ErrIdentityProviderNotFound) {
+			log.Warn("identity provider not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrIdentityProviderNotFound)
+		}
+
+		log.Error("failed to delete identity provider", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("identity provider deleted")
+
+	return nil
+}
+
+func validateIdentityProvider(provider models.IdentityProvider) error {
+	if !identityProviderName.MatchString(provider.Name) {
+		return errors.New("name must consist of lowercase letters, digits and dashes")
+	}
+	if slices.Contains(builtinIdentityProviders, provider.Name) {
+		return fmt.Errorf("name %q is reserved", provider.Name)
+	}
+
+	if provider.ClientID == "" {
+		return errors.New("client id is required")
+	}
+
+	issuer, err := url.Parse(provider.Issuer)
+	if err != nil || issuer.Host == "" || issuer.RawQuery != "" || issuer.Fragment != "" {
+		return errors.New("issuer must be an absolute URL without query and fragment")
+	}
+	// ID tokens are trusted because of the TLS connection to the issuer
+	if issuer.Scheme != "https" && !(issuer.Scheme == "http" && issuer.Hostname() == "localhost") {
+		return errors.New("issuer must be an https URL")
+	}
+
+	return nil
+}
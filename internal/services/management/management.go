@@ -0,0 +1,350 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/policy"
+	"sso/internal/lib/tokens"
+	"sso/internal/storage"
+	"time"
+)
+
+// Management implements administrative operations on apps and users.
+type Management struct {
+	log           *slog.Logger
+	apps          AppStorage
+	orgs          OrgStorage
+	claimMappings ClaimMappingStorage
+	idps          IdentityProviderStorage
+	roles         RoleStorage
+	groups        GroupStorage
+	users         UserStorage
+	auditLog      AuditLog
+	resetter      PasswordResetter
+	denier        TokenDenier
+	userData      UserDataProvider
+	policies      PolicyStorage
+	apiKeys       APIKeyStorage
+	policy        policy.Engine
+	permissions   *permissionCache
+	cfg           Config
+}
+
+type Config struct {
+	// DeletedUserRetention is how long users deleted by admins can be
+	// restored before they are anonymized.
+	DeletedUserRetention time.Duration
+	// LoginHistorySize is how many of the latest logins of each user are
+	// kept.
+	LoginHistorySize int
+	// PermissionCacheTTL is how long answers of CheckPermission are cached,
+	// zero disables the cache.
+	PermissionCacheTTL time.Duration
+}
+
+type AppStorage interface {
+	App(ctx context.Context, appID int) (models.App, error)
+	AppIncludingDisabled(ctx context.Context, appID int) (models.App, error)
+	Apps(ctx context.Context, orgID int64) ([]models.App, error)
+	SaveApp(ctx context.Context, app models.App, adminID int64) (int, error)
+	UpdateApp(ctx context.Context, app models.App, adminID int64, at time.Time) error
+	SetAppStatus(
+		ctx context.Context,
+		appID int,
+		status string,
+		revokeTokens bool,
+		adminID int64,
+		at time.Time,
+	) ([]models.TokenIssuance, error)
+	RotateAppSecret(
+		ctx context.Context,
+		appID int,
+		hash string,
+		signingKey string,
+		previousExpiresAt *time.Time,
+		adminID int64,
+		at time.Time,
+	) error
+	AppStats(ctx context.Context, appID int, from time.Time, to time.Time) (models.AppStats, error)
+}
+
+type OrgStorage interface {
+	SaveOrg(ctx context.Context, org models.Org, adminID int64) (int64, error)
+	Orgs(ctx context.Context) ([]models.Org, error)
+	Org(ctx context.Context, orgID int64) (models.Org, error)
+	SetUserOrg(ctx context.Context, userID int64, orgID int64, adminID int64, at time.Time) error
+}
+
+type ClaimMappingStorage interface {
+	SaveClaimMapping(ctx context.Context, mapping models.ClaimMapping) error
+	ClaimMappings(ctx context.Context, appID int) ([]models.ClaimMapping, error)
+	DeleteClaimMapping(ctx context.Context, appID int, source string) error
+}
+
+type IdentityProviderStorage interface {
+	SaveIdentityProvider(ctx context.Context, provider models.IdentityProvider) error
+	IdentityProviders(ctx context.Context) ([]models.IdentityProvider, error)
+	DeleteIdentityProvider(ctx context.Context, name string) error
+}
+
+type RoleStorage interface {
+	SaveRole(ctx context.Context, role models.Role) (int64, error)
+	UpdateRole(ctx context.Context, role models.Role) error
+	Role(ctx context.Context, name string) (models.Role, error)
+	Roles(ctx context.Context) ([]models.Role, error)
+	DeleteRole(ctx context.Context, name string) error
+	RoleHierarchy(ctx context.Context) (map[string][]string, error)
+	UserRoles(ctx context.Context, userID int64, appID int) ([]models.Role, error)
+	PermissionConditions(ctx context.Context, userID int64, appID int, permission string) ([]string, error)
+	IsAppAdmin(ctx context.Context, userID int64, appID int) (bool, error)
+	AssignRole(ctx context.Context, userID int64, role string, appID int, adminID int64, at time.Time) error
+	RevokeRole(ctx context.Context, userID int64, role string, appID int, adminID int64, at time.Time) error
+}
+
+type GroupStorage interface {
+	SaveGroup(ctx context.Context, group models.Group) (int64, error)
+	UpdateGroup(ctx context.Context, group models.Group) error
+	Group(ctx context.Context, name string) (models.Group, error)
+	Groups(ctx context.Context) ([]models.Group, error)
+	DeleteGroup(ctx context.Context, name string) error
+	AssignGroupRole(ctx context.Context, group string, role string, adminID int64, at time.Time) error
+	RevokeGroupRole(ctx context.Context, group string, role string, adminID int64, at time.Time) error
+	AddGroupMember(ctx context.Context, group string, userID int64, at time.Time) error
+	RemoveGroupMember(ctx context.Context, group string, userID int64) error
+	GroupMembers(ctx context.Context, group string) ([]int64, error)
+	UserGroups(ctx context.Context, userID int64) ([]models.Group, error)
+}
+
+type UserStorage interface {
+	User(ctx context.Context, email string) (models.User, error)
+	UserByIDIncludingDeleted(ctx context.Context, userID int64) (models.User, error)
+	UsersByIDs(ctx context.Context, ids []int64) ([]models.User, error)
+	ListUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error)
+	UpdateUser(ctx context.Context, update models.UserUpdate) (models.User, error)
+	UnlockUser(ctx context.Context, userID int64) error
+	DeleteUser(ctx context.Context, userID int64, adminID int64, at time.Time, purgeAt time.Time) error
+	RestoreUser(ctx context.Context, userID int64, adminID int64, at time.Time) error
+	SuspendUser(ctx context.Context, userID int64, adminID int64, at time.Time) error
+	UnsuspendUser(ctx context.Context, userID int64, adminID int64, at time.Time) error
+	RequirePasswordChange(ctx context.Context, userID int64, adminID int64, at time.Time) error
+	ImportUser(ctx context.Context, user models.UserImport, adminID int64, at time.Time) (int64, error)
+	MergeUsers(ctx context.Context, primaryID int64, duplicateID int64, adminID int64, at time.Time, purgeAt time.Time) error
+	SetUserExpiry(ctx context.Context, userID int64, adminID int64, expiresAt *time.Time, at time.Time) error
+}
+
+// PasswordResetter sends users the link to set a new password.
+type PasswordResetter interface {
+	RequestPasswordReset(ctx context.Context, email string) error
+}
+
+// TokenDenier denies revoked access tokens until they expire.
+type TokenDenier interface {
+	DenyTokens(ctx context.Context, issuances []models.TokenIssuance) error
+}
+
+type AuditLog interface {
+	SaveAuditEvent(ctx context.Context, event models.AuditEvent) error
+	AuditEvents(ctx context.Context, filter models.AuditEventFilter) ([]models.AuditEvent, error)
+}
+
+// UserDataProvider returns the records kept about a user besides the user
+// itself, for data exports.
+type UserDataProvider interface {
+	ActiveUserSessions(ctx context.Context, userID int64) ([]models.UserSession, error)
+	Consents(ctx context.Context, userID int64) ([]models.Consent, error)
+	UserIdentities(ctx context.Context, userID int64) ([]models.UserIdentity, error)
+	TrustedDevices(ctx context.Context, userID int64) ([]models.TrustedDevice, error)
+	LoginHistory(ctx context.Context, userID int64, limit int) ([]models.Login, error)
+	NotificationPreferences(ctx context.Context, userID int64) (models.NotificationPreferences, error)
+}
+
+type PolicyStorage interface {
+	SavePolicyRule(ctx context.Context, rule models.PolicyRule) (int64, error)
+	PolicyRules(ctx context.Context) ([]models.PolicyRule, error)
+	DeletePolicyRule(ctx context.Context, id int64) error
+}
+
+type APIKeyStorage interface {
+	SaveAPIKey(ctx context.Context, key models.APIKey) (int64, error)
+	APIKeys(ctx context.Context, appID int) ([]models.APIKey, error)
+	APIKeyByHash(ctx context.Context, hash string) (models.APIKey, error)
+	TouchAPIKey(ctx context.Context, keyID int64, at time.Time) error
+	RevokeAPIKey(ctx context.Context, appID int, keyID int64, adminID int64, at time.Time) error
+}
+
+var (
+	ErrUserNotFound             = errors.New("user not found")
+	ErrInvalidUserUpdate        = errors.New("invalid user update")
+	ErrInvalidUserFilter        = errors.New("invalid user filter")
+	ErrInvalidUserImport        = errors.New("invalid user import")
+	ErrInvalidUserBatch         = errors.New("invalid user batch")
+	ErrUserExists               = errors.New("user already exists")
+	ErrUsernameTaken            = errors.New("username already taken")
+	ErrUserModified             = errors.New("user modified concurrently")
+	ErrSelfDeletion             = errors.New("admins can not delete themselves")
+	ErrSelfSuspension           = errors.New("admins can not suspend themselves")
+	ErrSelfMerge                = errors.New("users can not be merged into themselves")
+	ErrInvalidExpiry            = errors.New("invalid account expiry")
+	ErrAppNotFound              = errors.New("app not found")
+	ErrAppExists                = errors.New("app already exists")
+	ErrInvalidApp               = errors.New("invalid app")
+	ErrOrgNotFound              = errors.New("org not found")
+	ErrOrgExists                = errors.New("org already exists")
+	ErrInvalidOrg               = errors.New("invalid org")
+	ErrInvalidClaimMapping      = errors.New("invalid claim mapping")
+	ErrClaimMappingNotFound     = errors.New("claim mapping not found")
+	ErrInvalidIdentityProvider  = errors.New("invalid identity provider")
+	ErrIdentityProviderNotFound = errors.New("identity provider not found")
+	ErrInvalidRole              = errors.New("invalid role")
+	ErrRoleNotFound             = errors.New("role not found")
+	ErrInvalidPermission        = errors.New("invalid permission")
+	ErrInvalidAttributes        = errors.New("invalid attributes")
+	ErrInvalidGroup             = errors.New("invalid group")
+	ErrGroupNotFound            = errors.New("group not found")
+	ErrInvalidPolicyRule        = errors.New("invalid policy rule")
+	ErrPolicyRuleNotFound       = errors.New("policy rule not found")
+	ErrInvalidAPIKey            = errors.New("invalid api key")
+	ErrAPIKeyNotFound           = errors.New("api key not found")
+	ErrInvalidTimeRange         = errors.New("invalid time range")
+)
+
+// New returns the management service. policyEngine is nil if permissions
+// only follow role lists.
+func New(
+	log *slog.Logger,
+	apps AppStorage,
+	orgs OrgStorage,
+	claimMappings ClaimMappingStorage,
+	idps IdentityProviderStorage,
+	roles RoleStorage,
+	groups GroupStorage,
+	users UserStorage,
+	auditLog AuditLog,
+	resetter PasswordResetter,
+	denier TokenDenier,
+	userData UserDataProvider,
+	policies PolicyStorage,
+	apiKeys APIKeyStorage,
+	policyEngine policy.Engine,
+	cfg Config,
+) *Management {
+	return &Management{
+		log:           log,
+		apps:          apps,
+		orgs:          orgs,
+		claimMappings: claimMappings,
+		idps:          idps,
+		roles:         roles,
+		groups:        groups,
+		users:         users,
+		auditLog:      auditLog,
+		resetter:      resetter,
+		denier:        denier,
+		userData:      userData,
+		policies:      policies,
+		apiKeys:       apiKeys,
+		policy:        policyEngine,
+		permissions:   newPermissionCache(cfg.PermissionCacheTTL),
+		cfg:           cfg,
+	}
+}
+
+// ClaimMappings returns the claim mappings applied to tokens of the app.
+func (m *Management) ClaimMappings(ctx context.Context, appID int) ([]models.ClaimMapping, error) {
+	const op = "services.management.ClaimMappings"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	if err := m.checkApp(ctx, log, appID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	mappings, err := m.claimMappings.ClaimMappings(ctx, appID)
+	if err != nil {
+		log.Error("failed to get claim mappings", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return mappings, nil
+}
+
+// SetClaimMapping makes tokens issued for the app carry the source claim
+// under the target name from now on.
+func (m *Management) SetClaimMapping(ctx context.Context, mapping models.ClaimMapping) error {
+	const op = "services.management.SetClaimMapping"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", mapping.AppID),
+		slog.String("source", mapping.Source),
+		slog.String("target", mapping.Target),
+	)
+
+	log.Info("setting claim mapping")
+
+	if !tokens.ValidClaimMapping(mapping.Source, mapping.Target) {
+		log.Warn("invalid claim mapping")
+		return fmt.Errorf("%s: %w", op, ErrInvalidClaimMapping)
+	}
+
+	if err := m.checkApp(ctx, log, mapping.AppID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.claimMappings.SaveClaimMapping(ctx, mapping); err != nil {
+		log.Error("failed to save claim mapping", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("claim mapping set")
+
+	return nil
+}
+
+func (m *Management) DeleteClaimMapping(ctx context.Context, appID int, source string) error {
+	const op = "services.management.DeleteClaimMapping"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+		slog.String("source", source),
+	)
+
+	log.Info("deleting claim mapping")
+
+	if err := m.claimMappings.DeleteClaimMapping(ctx, appID, source); err != nil {
+		if errors.Is(err, storage.ErrClaimMappingNotFound) {
+			log.Warn("claim mapping not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrClaimMappingNotFound)
+		}
+
+		log.Error("failed to delete claim mapping", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("claim mapping deleted")
+
+	return nil
+}
+
+// checkApp fails with ErrAppNotFound if there is no app with the id.
+func (m *Management) checkApp(ctx context.Context, log *slog.Logger, appID int) error {
+	if _, err := m.apps.App(ctx, appID); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return ErrAppNotFound
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
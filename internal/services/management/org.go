@@ -0,0 +1,131 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+// orgName allows names such as "Acme Corp".
+var orgName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _.-]{0,63}$`)
+
+// Orgs returns every org ordered by id.
+func (m *Management) Orgs(ctx context.Context) ([]models.Org, error) {
+	const op = "services.management.Orgs"
+
+	log := m.log.With(slog.String("op", op))
+
+	orgs, err := m.orgs.Orgs(ctx)
+	if err != nil {
+		log.Error("failed to get orgs", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return orgs, nil
+}
+
+func (m *Management) Org(ctx context.Context, orgID int64) (models.Org, error) {
+	const op = "services.management.Org"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("org_id", orgID),
+	)
+
+	org, err := m.orgs.Org(ctx, orgID)
+	if err != nil {
+		if errors.Is(err, storage.ErrOrgNotFound) {
+			log.Warn("org not found", sl.Err(err))
+			return models.Org{}, fmt.Errorf("%s: %w", op, ErrOrgNotFound)
+		}
+
+		log.Error("failed to get org", sl.Err(err))
+		return models.Org{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return org, nil
+}
+
+// CreateOrg creates the org on behalf of the admin and returns it with its
+// new id.
+func (m *Management) CreateOrg(ctx context.Context, adminID int64, org models.Org) (models.Org, error) {
+	const op = "services.management.CreateOrg"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.String("name", org.Name),
+	)
+
+	log.Info("creating org")
+
+	org.Name = strings.TrimSpace(org.Name)
+	if !orgName.MatchString(org.Name) {
+		log.Warn("invalid org name")
+		return models.Org{}, fmt.Errorf("%s: %w: name must consist of letters, digits, spaces, dots, dashes and underscores",
+			op, ErrInvalidOrg)
+	}
+	org.CreatedAt = time.Now()
+
+	id, err := m.orgs.SaveOrg(ctx, org, adminID)
+	if err != nil {
+		if errors.Is(err, storage.ErrOrgExists) {
+			log.Warn("org name taken", sl.Err(err))
+			return models.Org{}, fmt.Errorf("%s: %w", op, ErrOrgExists)
+		}
+
+		log.Error("failed to save org", sl.Err(err))
+		return models.Org{}, fmt.Errorf("%s: %w", op, err)
+	}
+	org.ID = id
+
+	log.Info("org created", slog.Int64("org_id", id))
+
+	return org, nil
+}
+
+// SetUserOrg moves the user to the org on behalf of the admin and returns
+// the user. The tokens of the user are revoked, so that the user logs in
+// again within the new org.
+func (m *Management) SetUserOrg(ctx context.Context, adminID int64, userID int64, orgID int64) (models.User, error) {
+	const op = "services.management.SetUserOrg"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.Int64("user_id", userID),
+		slog.Int64("org_id", orgID),
+	)
+
+	log.Info("moving user to org")
+
+	if _, err := m.Org(ctx, orgID); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.orgs.SetUserOrg(ctx, userID, orgID, adminID, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to move user to org", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := m.User(ctx, userID)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user moved to org")
+
+	return user, nil
+}
@@ -0,0 +1,105 @@
+package management
+
+import (
+	"sync"
+	"time"
+)
+
+// permissionCache keeps the answers of CheckPermission for a while. Role
+// changes made through the service drop the answers they may change, other
+// changes, such as a directory making a user an admin, show once the
+// answers expire.
+type permissionCache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	users map[int64]map[permissionKey]cachedPermission
+}
+
+type permissionKey struct {
+	appID      int
+	permission string
+}
+
+type cachedPermission struct {
+	allowed   bool
+	expiresAt time.Time
+}
+
+// newPermissionCache returns a cache keeping answers for ttl, or nil, which
+// caches nothing, if ttl is not positive.
+func newPermissionCache(ttl time.Duration) *permissionCache {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return &permissionCache{
+		ttl:   ttl,
+		users: make(map[int64]map[permissionKey]cachedPermission),
+	}
+}
+
+func (c *permissionCache) get(userID int64, appID int, permission string) (bool, bool) {
+	if c == nil {
+		return false, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.users[userID][permissionKey{appID: appID, permission: permission}]
+	if !ok || time.Now().After(cached.expiresAt) {
+		return false, false
+	}
+
+	return cached.allowed, true
+}
+
+func (c *permissionCache) put(userID int64, appID int, permission string, allowed bool) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	permissions, ok := c.users[userID]
+	if !ok {
+		permissions = make(map[permissionKey]cachedPermission)
+		c.users[userID] = permissions
+	}
+	for key, cached := range permissions {
+		if now.After(cached.expiresAt) {
+			delete(permissions, key)
+		}
+	}
+
+	key := permissionKey{appID: appID, permission: permission}
+	permissions[key] = cachedPermission{allowed: allowed, expiresAt: now.Add(c.ttl)}
+}
+
+// invalidateUser drops the answers for the users.
+func (c *permissionCache) invalidateUser(userIDs ...int64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, userID := range userIDs {
+		delete(c.users, userID)
+	}
+}
+
+// invalidateAll drops every answer, as when a role changes.
+func (c *permissionCache) invalidateAll() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.users)
+}
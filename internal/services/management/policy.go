@@ -0,0 +1,145 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"time"
+)
+
+// policyType matches policy types of Casbin models, as "p" or "g2".
+var policyType = regexp.MustCompile(`^[a-z][a-z0-9_]{0,15}$`)
+
+// maxPolicyValues is how many values a policy rule has at most.
+const maxPolicyValues = 6
+
+// PolicyRules returns the rules of the policy engine in the order they were
+// added.
+func (m *Management) PolicyRules(ctx context.Context) ([]models.PolicyRule, error) {
+	const op = "services.management.PolicyRules"
+
+	log := m.log.With(slog.String("op", op))
+
+	rules, err := m.policies.PolicyRules(ctx)
+	if err != nil {
+		log.Error("failed to get policy rules", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rules, nil
+}
+
+// AddPolicyRule adds the rule and reloads the policy engine, and returns the
+// rule. A rule the engine can not load is taken back and fails with
+// ErrInvalidPolicyRule.
+func (m *Management) AddPolicyRule(ctx context.Context, rule models.PolicyRule) (models.PolicyRule, error) {
+	const op = "services.management.AddPolicyRule"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("type", rule.Type),
+	)
+
+	log.Info("adding policy rule")
+
+	if err := validatePolicyRule(rule); err != nil {
+		log.Warn("invalid policy rule", sl.Err(err))
+		return models.PolicyRule{}, fmt.Errorf("%s: %w: %s", op, ErrInvalidPolicyRule, err.Error())
+	}
+
+	rule.CreatedAt = time.Now()
+
+	id, err := m.policies.SavePolicyRule(ctx, rule)
+	if err != nil {
+		log.Error("failed to save policy rule", sl.Err(err))
+		return models.PolicyRule{}, fmt.Errorf("%s: %w", op, err)
+	}
+	rule.ID = id
+
+	if err = m.reloadPolicy(ctx); err != nil {
+		log.Warn("policy engine rejected rule", sl.Err(err))
+
+		if err := m.policies.DeletePolicyRule(ctx, id); err != nil {
+			log.Error("failed to delete rejected policy rule", sl.Err(err))
+			return models.PolicyRule{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := m.reloadPolicy(ctx); err != nil {
+			log.Error("failed to reload policy", sl.Err(err))
+		}
+
+		return models.PolicyRule{}, fmt.Errorf("%s: %w: %s", op, ErrInvalidPolicyRule, err.Error())
+	}
+
+	log.Info("policy rule added", slog.Int64("rule_id", id))
+
+	return rule, nil
+}
+
+// DeletePolicyRule deletes the rule with the id and reloads the policy
+// engine.
+func (m *Management) DeletePolicyRule(ctx context.Context, id int64) error {
+	const op = "services.management.DeletePolicyRule"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("rule_id", id),
+	)
+
+	log.Info("deleting policy rule")
+
+	if err := m.policies.DeletePolicyRule(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrPolicyRuleNotFound) {
+			log.Warn("policy rule not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrPolicyRuleNotFound)
+		}
+
+		log.Error("failed to delete policy rule", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.reloadPolicy(ctx); err != nil {
+		log.Error("failed to reload policy", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("policy rule deleted")
+
+	return nil
+}
+
+// reloadPolicy loads the rules into the policy engine, if there is one, and
+// drops the permissions cached by the former rules.
+func (m *Management) reloadPolicy(ctx context.Context) error {
+	if m.policy == nil {
+		return nil
+	}
+
+	if err := m.policy.Reload(ctx); err != nil {
+		return err
+	}
+
+	m.permissions.invalidateAll()
+
+	return nil
+}
+
+func validatePolicyRule(rule models.PolicyRule) error {
+	if !policyType.MatchString(rule.Type) {
+		return errors.New("type must be a policy type of the model, as p")
+	}
+	if len(rule.Values) == 0 || len(rule.Values) > maxPolicyValues {
+		return fmt.Errorf("a rule has 1 to %d values", maxPolicyValues)
+	}
+	for _, value := range rule.Values {
+		if value == "" || len(value) > 1024 {
+			return errors.New("values must be 1 to 1024 bytes long")
+		}
+	}
+
+	return nil
+}
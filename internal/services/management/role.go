@@ -0,0 +1,613 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/policy"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+// RoleAdmin is the role of admins, who hold every permission. Users with the
+// is_admin flag administer every app, others get the role within single
+// apps. Its definition is built in and can not be changed.
+const RoleAdmin = "admin"
+
+var (
+	// roleName restricts role names to what can appear unescaped in paths.
+	roleName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+	// permissionName allows names such as "invoices:read".
+	permissionName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.:-]{0,127}$`)
+	// attributeName restricts attribute names to what conditions can refer
+	// to as attrs.<name>.
+	attributeName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+)
+
+// maxRolePermissions caps the permissions a role grants.
+const maxRolePermissions = 256
+
+// maxRoleInherits caps the roles a role inherits directly.
+const maxRoleInherits = 32
+
+// maxAttributes caps the attributes of permission checks, and
+// maxAttributeLength their values.
+const (
+	maxAttributes      = 32
+	maxAttributeLength = 256
+)
+
+// Roles returns the stored roles, without the admin role.
+func (m *Management) Roles(ctx context.Context) ([]models.Role, error) {
+	const op = "services.management.Roles"
+
+	log := m.log.With(slog.String("op", op))
+
+	roles, err := m.roles.Roles(ctx)
+	if err != nil {
+		log.Error("failed to get roles", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+func (m *Management) Role(ctx context.Context, name string) (models.Role, error) {
+	const op = "services.management.Role"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("role", name),
+	)
+
+	role, err := m.roles.Role(ctx, name)
+	if err != nil {
+		if errors.Is(err, storage.ErrRoleNotFound) {
+			log.Warn("role not found", sl.Err(err))
+			return models.Role{}, fmt.Errorf("%s: %w", op, ErrRoleNotFound)
+		}
+
+		log.Error("failed to get role", sl.Err(err))
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return role, nil
+}
+
+// SetRole creates the role or replaces the description, the permissions and
+// the inherited roles of the one with the same name, and returns it. Users
+// with a role hold the permissions of the roles it inherits, transitively,
+// so inherited roles must exist and must not inherit the role back.
+func (m *Management) SetRole(ctx context.Context, role models.Role) (models.Role, error) {
+	const op = "services.management.SetRole"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("role", role.Name),
+	)
+
+	log.Info("setting role")
+
+	if err := validateRole(role); err != nil {
+		log.Warn("invalid role", sl.Err(err))
+		return models.Role{}, fmt.Errorf("%s: %w: %s", op, ErrInvalidRole, err.Error())
+	}
+
+	slices.Sort(role.Permissions)
+	role.Permissions = slices.Compact(role.Permissions)
+	slices.Sort(role.Inherits)
+	role.Inherits = slices.Compact(role.Inherits)
+
+	hierarchy, err := m.roles.RoleHierarchy(ctx)
+	if err != nil {
+		log.Error("failed to get role hierarchy", sl.Err(err))
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+	hierarchy[role.Name] = role.Inherits
+	if cycle := roleCycle(hierarchy, role.Name); cycle != nil {
+		log.Warn("role inheritance cycle", slog.Any("cycle", cycle))
+		return models.Role{}, fmt.Errorf("%s: %w: roles inherit each other: %s",
+			op, ErrInvalidRole, strings.Join(cycle, " > "))
+	}
+
+	err = m.roles.UpdateRole(ctx, role)
+	if errors.Is(err, storage.ErrRoleNotFound) {
+		role.CreatedAt = time.Now()
+		_, err = m.roles.SaveRole(ctx, role)
+		if errors.Is(err, storage.ErrRoleExists) {
+			// created concurrently, the other request wins
+			err = m.roles.UpdateRole(ctx, role)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrRoleNotFound) {
+			log.Warn("inherited role not found", sl.Err(err))
+			return models.Role{}, fmt.Errorf("%s: %w: inherited role does not exist", op, ErrInvalidRole)
+		}
+
+		log.Error("failed to save role", sl.Err(err))
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	role, err = m.roles.Role(ctx, role.Name)
+	if err != nil {
+		log.Error("failed to get role", sl.Err(err))
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.permissions.invalidateAll()
+
+	log.Info("role set")
+
+	return role, nil
+}
+
+// DeleteRole deletes the role, taking it from the users it was assigned to.
+func (m *Management) DeleteRole(ctx context.Context, name string) error {
+	const op = "services.management.DeleteRole"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("role", name),
+	)
+
+	log.Info("deleting role")
+
+	if name == RoleAdmin {
+		log.Warn("admin role can not be deleted")
+		return fmt.Errorf("%s: %w: role %q is built in", op, ErrInvalidRole, name)
+	}
+
+	if err := m.roles.DeleteRole(ctx, name); err != nil {
+		if errors.Is(err, storage.ErrRoleNotFound) {
+			log.Warn("role not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrRoleNotFound)
+		}
+
+		log.Error("failed to delete role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.permissions.invalidateAll()
+
+	log.Info("role deleted")
+
+	return nil
+}
+
+// AssignRole grants the role to the user within the app, or globally if
+// appID is zero, on behalf of the admin. Granting a role the user has does
+// nothing. The admin role is only granted within apps, as the is_admin flag
+// makes global admins.
+func (m *Management) AssignRole(ctx context.Context, adminID int64, userID int64, role string, appID int) error {
+	const op = "services.management.AssignRole"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.Int64("user_id", userID),
+		slog.String("role", role),
+		slog.Int("app_id", appID),
+	)
+
+	log.Info("assigning role")
+
+	if err := m.checkAssignment(ctx, log, role, appID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.roles.AssignRole(ctx, userID, role, appID, adminID, time.Now()); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrRoleNotFound):
+			log.Warn("role not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrRoleNotFound)
+		case errors.Is(err, storage.ErrUserNotFound):
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to assign role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.permissions.invalidateUser(userID)
+
+	log.Info("role assigned")
+
+	return nil
+}
+
+// RevokeRole takes the role the user has within the app, or globally if
+// appID is zero, on behalf of the admin. Revoking a role the user does not
+// have does nothing.
+func (m *Management) RevokeRole(ctx context.Context, adminID int64, userID int64, role string, appID int) error {
+	const op = "services.management.RevokeRole"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("admin_id", adminID),
+		slog.Int64("user_id", userID),
+		slog.String("role", role),
+		slog.Int("app_id", appID),
+	)
+
+	log.Info("revoking role")
+
+	if err := m.checkAssignment(ctx, log, role, appID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.roles.RevokeRole(ctx, userID, role, appID, adminID, time.Now()); err != nil {
+		log.Error("failed to revoke role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.permissions.invalidateUser(userID)
+
+	log.Info("role revoked")
+
+	return nil
+}
+
+// checkAssignment validates the role assigned or revoked within the app.
+func (m *Management) checkAssignment(ctx context.Context, log *slog.Logger, role string, appID int) error {
+	if !roleName.MatchString(role) {
+		log.Warn("invalid role name")
+		return fmt.Errorf("%w: invalid name", ErrInvalidRole)
+	}
+
+	if appID == 0 {
+		if role == RoleAdmin {
+			log.Warn("admin role assigned globally")
+			return fmt.Errorf("%w: global admins are made by the is_admin flag", ErrInvalidRole)
+		}
+
+		return nil
+	}
+
+	return m.checkApp(ctx, log, appID)
+}
+
+// UserRoles returns the roles the user has within the app, or the global
+// ones if appID is zero. Admins of the app, including users with the
+// is_admin flag, have the admin role first.
+func (m *Management) UserRoles(ctx context.Context, userID int64, appID int) ([]models.Role, error) {
+	const op = "services.management.UserRoles"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int("app_id", appID),
+	)
+
+	if appID != 0 {
+		if err := m.checkApp(ctx, log, appID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	isAdmin, err := m.isAdmin(ctx, log, userID, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles, err := m.roles.UserRoles(ctx, userID, appID)
+	if err != nil {
+		log.Error("failed to get roles", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles = slices.DeleteFunc(roles, func(role models.Role) bool { return role.Name == RoleAdmin })
+	if isAdmin {
+		roles = append([]models.Role{{Name: RoleAdmin, Description: "Holds every permission"}}, roles...)
+	}
+
+	return roles, nil
+}
+
+// HasPermission tells whether the user holds the permission within the
+// app, or globally if appID is zero, either through a role or by
+// administering the app. Conditional grants count if their condition holds
+// without a resource or attributes.
+func (m *Management) HasPermission(ctx context.Context, userID int64, appID int, permission string) (bool, error) {
+	const op = "services.management.HasPermission"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int("app_id", appID),
+		slog.String("permission", permission),
+	)
+
+	if !permissionName.MatchString(permission) {
+		log.Warn("invalid permission")
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidPermission)
+	}
+
+	if appID != 0 {
+		if err := m.checkApp(ctx, log, appID); err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	allowed, err := m.hasPermission(ctx, log, policy.ConditionInput{AppID: appID}, userID, permission)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return allowed, nil
+}
+
+// CheckPermission tells resource servers of the app whether the user holds
+// the permission within the app, by a role or else by the policy engine,
+// which may decide by the resource. Conditions of role permissions are
+// evaluated on the resource and the attributes of the check, such as the
+// tenant or the environment. Answers to checks without a resource or
+// attributes are cached for cfg.PermissionCacheTTL.
+func (m *Management) CheckPermission(
+	ctx context.Context,
+	userID int64,
+	appID int,
+	permission string,
+	resource models.Resource,
+	attributes map[string]string,
+) (bool, error) {
+	const op = "services.management.CheckPermission"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int("app_id", appID),
+		slog.String("permission", permission),
+	)
+
+	if !permissionName.MatchString(permission) {
+		log.Warn("invalid permission")
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidPermission)
+	}
+	if err := validateAttributes(attributes); err != nil {
+		log.Warn("invalid attributes", sl.Err(err))
+		return false, fmt.Errorf("%s: %w: %s", op, ErrInvalidAttributes, err.Error())
+	}
+
+	cached := resource == models.Resource{} && len(attributes) == 0
+	if cached {
+		if allowed, ok := m.permissions.get(userID, appID, permission); ok {
+			return allowed, nil
+		}
+	}
+
+	if err := m.checkApp(ctx, log, appID); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	allowed, err := m.hasPermission(ctx, log, policy.ConditionInput{
+		AppID:      appID,
+		Resource:   resource,
+		Attributes: attributes,
+	}, userID, permission)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !allowed && m.policy != nil {
+		allowed, err = m.allowedByPolicy(ctx, log, userID, appID, permission, resource)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if cached {
+		m.permissions.put(userID, appID, permission, allowed)
+	}
+
+	log.Info("permission checked", slog.Bool("allowed", allowed))
+
+	return allowed, nil
+}
+
+// allowedByPolicy asks the policy engine whether the user holds the
+// permission within the app on the resource.
+func (m *Management) allowedByPolicy(
+	ctx context.Context,
+	log *slog.Logger,
+	userID int64,
+	appID int,
+	permission string,
+	resource models.Resource,
+) (bool, error) {
+	roles, err := m.roles.UserRoles(ctx, userID, appID)
+	if err != nil {
+		log.Error("failed to get roles", sl.Err(err))
+		return false, err
+	}
+
+	subject := policy.Subject{ID: userID, Roles: make([]string, 0, len(roles))}
+	for _, role := range roles {
+		subject.Roles = append(subject.Roles, role.Name)
+	}
+
+	allowed, err := m.policy.Allow(ctx, policy.Request{
+		Subject:    subject,
+		AppID:      appID,
+		Resource:   resource,
+		Permission: permission,
+	})
+	if err != nil {
+		log.Error("failed to evaluate policy", sl.Err(err))
+		return false, err
+	}
+
+	return allowed, nil
+}
+
+// hasPermission tells whether the user administers the app of the input or
+// has a role granting the permission within it, unconditionally or under a
+// condition holding for the input.
+func (m *Management) hasPermission(
+	ctx context.Context,
+	log *slog.Logger,
+	in policy.ConditionInput,
+	userID int64,
+	permission string,
+) (bool, error) {
+	isAdmin, err := m.isAdmin(ctx, log, userID, in.AppID)
+	if err != nil {
+		return false, err
+	}
+	if isAdmin {
+		return true, nil
+	}
+
+	conditions, err := m.roles.PermissionConditions(ctx, userID, in.AppID, permission)
+	if err != nil {
+		log.Error("failed to check permission", sl.Err(err))
+		return false, err
+	}
+	if len(conditions) == 0 {
+		return false, nil
+	}
+	if slices.Contains(conditions, "") {
+		return true, nil
+	}
+
+	in.User, err = m.users.UserByIDIncludingDeleted(ctx, userID)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return false, err
+	}
+
+	for _, expression := range conditions {
+		condition, err := policy.ParseCondition(expression)
+		if err != nil {
+			log.Error("invalid stored condition", slog.String("condition", expression), sl.Err(err))
+			continue
+		}
+
+		holds, err := condition.Eval(in)
+		if err != nil {
+			// as when the check lacks an attribute the condition refers to
+			log.Debug("condition not evaluated", slog.String("condition", expression), sl.Err(err))
+			continue
+		}
+		if holds {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// isAdmin tells whether the user administers the app, or every app if
+// appID is zero, failing with ErrUserNotFound if there is no such user or
+// the user is deleted.
+func (m *Management) isAdmin(ctx context.Context, log *slog.Logger, userID int64, appID int) (bool, error) {
+	isAdmin, err := m.roles.IsAppAdmin(ctx, userID, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return false, ErrUserNotFound
+		}
+
+		log.Error("failed to check if user is admin", sl.Err(err))
+		return false, err
+	}
+
+	return isAdmin, nil
+}
+
+func validateRole(role models.Role) error {
+	if !roleName.MatchString(role.Name) {
+		return errors.New("name must consist of lowercase letters, digits, dots, dashes and underscores")
+	}
+	if role.Name == RoleAdmin {
+		return fmt.Errorf("name %q is reserved", role.Name)
+	}
+	if len(role.Description) > 256 {
+		return errors.New("description is too long")
+	}
+
+	if len(role.Permissions) > maxRolePermissions {
+		return fmt.Errorf("a role grants at most %d permissions", maxRolePermissions)
+	}
+	for _, permission := range role.Permissions {
+		if !permissionName.MatchString(permission) {
+			return fmt.Errorf("invalid permission %q", permission)
+		}
+	}
+
+	for permission, expression := range role.Conditions {
+		if !slices.Contains(role.Permissions, permission) {
+			return fmt.Errorf("condition of permission %q the role does not grant", permission)
+		}
+		if _, err := policy.ParseCondition(expression); err != nil {
+			return fmt.Errorf("invalid condition of permission %q: %s", permission, err.Error())
+		}
+	}
+
+	if len(role.Inherits) > maxRoleInherits {
+		return fmt.Errorf("a role inherits at most %d roles", maxRoleInherits)
+	}
+	for _, inherited := range role.Inherits {
+		if !roleName.MatchString(inherited) {
+			return fmt.Errorf("invalid inherited role %q", inherited)
+		}
+		if inherited == RoleAdmin {
+			return fmt.Errorf("role %q can not be inherited", inherited)
+		}
+		if inherited == role.Name {
+			return errors.New("a role can not inherit itself")
+		}
+	}
+
+	return nil
+}
+
+func validateAttributes(attributes map[string]string) error {
+	if len(attributes) > maxAttributes {
+		return fmt.Errorf("a check has at most %d attributes", maxAttributes)
+	}
+	for name, value := range attributes {
+		if !attributeName.MatchString(name) {
+			return fmt.Errorf("invalid attribute name %q", name)
+		}
+		if len(value) > maxAttributeLength {
+			return fmt.Errorf("attribute %q is too long", name)
+		}
+	}
+
+	return nil
+}
+
+// roleCycle returns the roles through which the role inherits itself in the
+// hierarchy, starting and ending with it, or nil if it does not.
+func roleCycle(hierarchy map[string][]string, role string) []string {
+	visited := make(map[string]bool)
+
+	var walk func(name string, path []string) []string
+	walk = func(name string, path []string) []string {
+		for _, inherited := range hierarchy[name] {
+			if inherited == role {
+				return append(path, inherited)
+			}
+			if visited[inherited] {
+				continue
+			}
+			visited[inherited] = true
+
+			if cycle := walk(inherited, append(path, inherited)); cycle != nil {
+				return cycle
+			}
+		}
+
+		return nil
+	}
+
+	return walk(role, []string{role})
+}
@@ -0,0 +1,420 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/metadata"
+	"sso/internal/lib/profile"
+	"sso/internal/lib/username"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+// User returns the user with the id, also if the user is deleted.
+func (m *Management) User(ctx context.Context, userID int64) (models.User, error) {
+	const op = "services.management.User"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	user, err := m.users.UserByIDIncludingDeleted(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// UserByEmail returns the user with the email, matched case-insensitively.
+func (m *Management) UserByEmail(ctx context.Context, email string) (models.User, error) {
+	const op = "services.management.UserByEmail"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	user, err := m.users.User(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// UpdateUser patches the fields of the user named in update.Fields and
+// returns the updated user. With update.Version set, it fails with
+// ErrUserModified if the user has changed since the caller read that
+// version.
+func (m *Management) UpdateUser(ctx context.Context, update models.UserUpdate) (models.User, error) {
+	const op = "services.management.UpdateUser"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", update.UserID),
+		slog.Any("fields", update.Fields),
+	)
+
+	log.Info("updating user")
+
+	update, err := normalizeUserUpdate(update)
+	if err != nil {
+		log.Warn("invalid user update", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w: %s", op, ErrInvalidUserUpdate, err.Error())
+	}
+
+	user, err := m.users.UpdateUser(ctx, update)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserNotFound):
+			log.Warn("user not found", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		case errors.Is(err, storage.ErrUserModified):
+			log.Warn("user modified concurrently", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserModified)
+		case errors.Is(err, storage.ErrUserExists):
+			log.Warn("email belongs to another user", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserExists)
+		case errors.Is(err, storage.ErrUsernameTaken):
+			log.Warn("username belongs to another user", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrUsernameTaken)
+		}
+
+		log.Error("failed to update user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// conditions of permissions may refer to the app metadata
+	m.permissions.invalidateUser(int64(user.ID))
+
+	log.Info("user updated", slog.Int64("version", user.Version))
+
+	return user, nil
+}
+
+// normalizeUserUpdate validates the fields the update sets and normalizes
+// them like they are when users set them.
+func normalizeUserUpdate(update models.UserUpdate) (models.UserUpdate, error) {
+	if len(update.Fields) == 0 {
+		return update, errors.New("no fields to update")
+	}
+
+	var err error
+	for _, field := range update.Fields {
+		switch field {
+		case models.UserFieldEmail:
+			update.Email = strings.TrimSpace(update.Email)
+			addr, err := mail.ParseAddress(update.Email)
+			if err != nil || addr.Address != update.Email {
+				return update, errors.New("invalid email")
+			}
+		case models.UserFieldUsername:
+			// an empty username removes it
+			update.Username = username.Normalize(update.Username)
+			if update.Username != "" && !username.Valid(update.Username) {
+				return update, errors.New("invalid username")
+			}
+		case models.UserFieldDisplayName:
+			if update.DisplayName, err = profile.NormalizeName("display name", update.DisplayName); err != nil {
+				return update, err
+			}
+		case models.UserFieldFirstName:
+			if update.FirstName, err = profile.NormalizeName("first name", update.FirstName); err != nil {
+				return update, err
+			}
+		case models.UserFieldLastName:
+			if update.LastName, err = profile.NormalizeName("last name", update.LastName); err != nil {
+				return update, err
+			}
+		case models.UserFieldLocale:
+			if update.Locale, err = profile.NormalizeLocale(update.Locale); err != nil {
+				return update, err
+			}
+		case models.UserFieldAvatarURL:
+			if update.AvatarURL, err = profile.NormalizeAvatarURL(update.AvatarURL); err != nil {
+				return update, err
+			}
+		case models.UserFieldAppMetadata:
+			if err = metadata.Validate(update.AppMetadata); err != nil {
+				return update, fmt.Errorf("app metadata: %w", err)
+			}
+		case models.UserFieldUserMetadata:
+			if err = metadata.Validate(update.UserMetadata); err != nil {
+				return update, fmt.Errorf("user metadata: %w", err)
+			}
+		default:
+			return update, fmt.Errorf("unknown field %q", field)
+		}
+	}
+
+	return update, nil
+}
+
+// UnlockUser lifts the lockout of the user after failed logins before it
+// expires, and forgets the failed logins.
+func (m *Management) UnlockUser(ctx context.Context, userID int64) error {
+	const op = "services.management.UnlockUser"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	log.Info("unlocking user")
+
+	if err := m.users.UnlockUser(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to unlock user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user unlocked")
+
+	return nil
+}
+
+// DeleteUser soft-deletes the user, which ends their sessions and keeps
+// them from logging in, and records the admin as the actor of the deletion
+// in the audit log. The user is anonymized like accounts their users
+// delete once cfg.DeletedUserRetention has passed, unless an admin
+// restores it before.
+func (m *Management) DeleteUser(ctx context.Context, adminID int64, userID int64) error {
+	const op = "services.management.DeleteUser"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int64("admin_id", adminID),
+	)
+
+	log.Info("deleting user")
+
+	if userID == adminID {
+		log.Warn("admin tried to delete themselves")
+		return fmt.Errorf("%s: %w", op, ErrSelfDeletion)
+	}
+
+	now := time.Now()
+	if err := m.users.DeleteUser(ctx, userID, adminID, now, now.Add(m.cfg.DeletedUserRetention)); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to delete user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.permissions.invalidateUser(userID)
+
+	log.Info("user deleted")
+
+	return nil
+}
+
+// RestoreUser undoes the deletion of the user by an admin, as long as the
+// user is not anonymized yet. Sessions ended by the deletion stay ended.
+func (m *Management) RestoreUser(ctx context.Context, adminID int64, userID int64) error {
+	const op = "services.management.RestoreUser"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int64("admin_id", adminID),
+	)
+
+	log.Info("restoring user")
+
+	if err := m.users.RestoreUser(ctx, userID, adminID, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("no deleted user to restore", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to restore user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.permissions.invalidateUser(userID)
+
+	log.Info("user restored")
+
+	return nil
+}
+
+// SuspendUser bans the user until UnsuspendUser. Every token of the user is
+// revoked at once, and logins fail with a dedicated error rather than as
+// invalid credentials.
+func (m *Management) SuspendUser(ctx context.Context, adminID int64, userID int64) error {
+	const op = "services.management.SuspendUser"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int64("admin_id", adminID),
+	)
+
+	log.Info("suspending user")
+
+	if userID == adminID {
+		log.Warn("admin tried to suspend themselves")
+		return fmt.Errorf("%s: %w", op, ErrSelfSuspension)
+	}
+
+	if err := m.users.SuspendUser(ctx, userID, adminID, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to suspend user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user suspended")
+
+	return nil
+}
+
+// UnsuspendUser lets the suspended user log in again.
+func (m *Management) UnsuspendUser(ctx context.Context, adminID int64, userID int64) error {
+	const op = "services.management.UnsuspendUser"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int64("admin_id", adminID),
+	)
+
+	log.Info("unsuspending user")
+
+	if err := m.users.UnsuspendUser(ctx, userID, adminID, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to unsuspend user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user unsuspended")
+
+	return nil
+}
+
+// RequirePasswordChange makes the user set a new password, as after a
+// credential stuffing incident. The sessions of the user end, and logins
+// return a token that only sets a new password until the user does.
+func (m *Management) RequirePasswordChange(ctx context.Context, adminID int64, userID int64) error {
+	const op = "services.management.RequirePasswordChange"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int64("admin_id", adminID),
+	)
+
+	log.Info("requiring password change")
+
+	if err := m.users.RequirePasswordChange(ctx, userID, adminID, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to require password change", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password change required")
+
+	return nil
+}
+
+// LoginHistory returns the latest successful logins of the user, the most
+// recent first.
+func (m *Management) LoginHistory(ctx context.Context, userID int64) ([]models.Login, error) {
+	const op = "services.management.LoginHistory"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	if _, err := m.users.UserByIDIncludingDeleted(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	logins, err := m.userData.LoginHistory(ctx, userID, m.cfg.LoginHistorySize)
+	if err != nil {
+		log.Error("failed to get login history", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return logins, nil
+}
+
+// NotificationPreferences returns the notification preferences of the
+// user, the defaults if the user has not set them, so that services sending
+// marketing emails can check the opt-in.
+func (m *Management) NotificationPreferences(ctx context.Context, userID int64) (models.NotificationPreferences, error) {
+	const op = "services.management.NotificationPreferences"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	if _, err := m.users.UserByIDIncludingDeleted(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.NotificationPreferences{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.NotificationPreferences{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	prefs, err := m.userData.NotificationPreferences(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotificationPreferencesNotFound) {
+			return models.DefaultNotificationPreferences(userID), nil
+		}
+
+		log.Error("failed to get notification preferences", sl.Err(err))
+		return models.NotificationPreferences{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return prefs, nil
+}
@@ -0,0 +1,83 @@
+package management
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+)
+
+const (
+	// maxBatchGetUsers caps the ids BatchGetUsers resolves at once.
+	maxBatchGetUsers = 10000
+	// batchGetChunkSize is how many users BatchGetUsers loads with one
+	// query and sends at once.
+	batchGetChunkSize = 500
+)
+
+// BatchGetUsers sends the users with the ids to send in chunks of at most
+// batchGetChunkSize, as services enriching events with user data need.
+// Deleted users are included. Each chunk lists the users of a run of ids
+// in order of the ids given, with the ids it found no user for in missing.
+// Duplicate ids are resolved once. It fails with ErrInvalidUserBatch if
+// there are no ids or more than maxBatchGetUsers.
+func (m *Management) BatchGetUsers(
+	ctx context.Context,
+	ids []int64,
+	send func(users []models.User, missing []int64) error,
+) error {
+	const op = "services.management.BatchGetUsers"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int("ids", len(ids)),
+	)
+
+	if len(ids) == 0 || len(ids) > maxBatchGetUsers {
+		log.Warn("invalid user batch")
+		return fmt.Errorf("%s: %w", op, ErrInvalidUserBatch)
+	}
+
+	unique := make([]int64, 0, len(ids))
+	seen := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+
+	for start := 0; start < len(unique); start += batchGetChunkSize {
+		chunk := unique[start:min(start+batchGetChunkSize, len(unique))]
+
+		found, err := m.users.UsersByIDs(ctx, chunk)
+		if err != nil {
+			log.Error("failed to get users", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		byID := make(map[int64]models.User, len(found))
+		for _, user := range found {
+			byID[int64(user.ID)] = user
+		}
+
+		users := make([]models.User, 0, len(found))
+		var missing []int64
+		for _, id := range chunk {
+			if user, ok := byID[id]; ok {
+				users = append(users, user)
+			} else {
+				missing = append(missing, id)
+			}
+		}
+
+		if err = send(users, missing); err != nil {
+			log.Warn("failed to send users", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
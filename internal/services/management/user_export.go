@@ -0,0 +1,105 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"time"
+)
+
+// ExportUserData sends the data kept about the user to send in chunks, as
+// for data portability requests: the user with the sessions, consents,
+// linked identities, trusted devices and login history first, then the
+// audit events of the user in chunks of at most maxAuditEvents. Deleted
+// users can be exported until they are anonymized. The export is recorded
+// in the audit log before any data is sent.
+func (m *Management) ExportUserData(ctx context.Context, adminID int64, userID int64, send func(models.UserDataChunk) error) error {
+	const op = "services.management.ExportUserData"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int64("admin_id", adminID),
+	)
+
+	log.Info("exporting user data")
+
+	user, err := m.users.UserByIDIncludingDeleted(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	first := models.UserDataChunk{User: &user}
+	if first.Sessions, err = m.userData.ActiveUserSessions(ctx, userID); err != nil {
+		log.Error("failed to get sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if first.Consents, err = m.userData.Consents(ctx, userID); err != nil {
+		log.Error("failed to get consents", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if first.Identities, err = m.userData.UserIdentities(ctx, userID); err != nil {
+		log.Error("failed to get identities", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if first.TrustedDevices, err = m.userData.TrustedDevices(ctx, userID); err != nil {
+		log.Error("failed to get trusted devices", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if first.LoginHistory, err = m.userData.LoginHistory(ctx, userID, m.cfg.LoginHistorySize); err != nil {
+		log.Error("failed to get login history", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = m.auditLog.SaveAuditEvent(ctx, models.AuditEvent{
+		Type:      models.AuditUserDataExported,
+		UserID:    userID,
+		ActorID:   adminID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		log.Error("failed to save audit event", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = send(first); err != nil {
+		log.Warn("failed to send user data", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	filter := models.AuditEventFilter{UserID: userID, Limit: maxAuditEvents}
+	for {
+		events, err := m.auditLog.AuditEvents(ctx, filter)
+		if err != nil {
+			log.Error("failed to get audit events", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		if err = send(models.UserDataChunk{AuditEvents: events}); err != nil {
+			log.Warn("failed to send user data", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if len(events) < filter.Limit {
+			break
+		}
+		filter.AfterID = events[len(events)-1].ID
+	}
+
+	log.Info("user data exported")
+
+	return nil
+}
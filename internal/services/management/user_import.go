@@ -0,0 +1,99 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/passhash"
+	"sso/internal/storage"
+	"time"
+)
+
+// ImportUser saves a user migrated from another system, validated like
+// user updates, into the default org unless user.OrgID names another. Users imported without a password hash are sent a password
+// reset link, which ImportUser reports by resetRequested.
+func (m *Management) ImportUser(ctx context.Context, adminID int64, user models.UserImport) (userID int64, resetRequested bool, err error) {
+	const op = "services.management.ImportUser"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("email", user.Email),
+		slog.Int64("admin_id", adminID),
+	)
+
+	log.Info("importing user")
+
+	user, err = normalizeUserImport(user)
+	if err != nil {
+		log.Warn("invalid user import", sl.Err(err))
+		return 0, false, fmt.Errorf("%s: %w: %s", op, ErrInvalidUserImport, err.Error())
+	}
+	if user.OrgID == 0 {
+		user.OrgID = models.DefaultOrgID
+	}
+
+	userID, err = m.users.ImportUser(ctx, user, adminID, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserExists):
+			log.Warn("user already exists", sl.Err(err))
+			return 0, false, fmt.Errorf("%s: %w", op, ErrUserExists)
+		case errors.Is(err, storage.ErrUsernameTaken):
+			log.Warn("username belongs to another user", sl.Err(err))
+			return 0, false, fmt.Errorf("%s: %w", op, ErrUsernameTaken)
+		}
+
+		log.Error("failed to import user", sl.Err(err))
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", userID))
+
+	if user.PassHash == "" {
+		// the user is imported either way, and can ask for another link
+		if err = m.resetter.RequestPasswordReset(ctx, user.Email); err != nil {
+			log.Error("failed to request password reset", sl.Err(err))
+			return userID, false, nil
+		}
+		resetRequested = true
+	}
+
+	log.Info("user imported", slog.Bool("reset_requested", resetRequested))
+
+	return userID, resetRequested, nil
+}
+
+// normalizeUserImport validates the fields of the user like
+// normalizeUserUpdate does, and the password hash.
+func normalizeUserImport(user models.UserImport) (models.UserImport, error) {
+	update, err := normalizeUserUpdate(models.UserUpdate{
+		Fields: []string{
+			models.UserFieldEmail,
+			models.UserFieldUsername,
+			models.UserFieldDisplayName,
+			models.UserFieldAppMetadata,
+			models.UserFieldUserMetadata,
+		},
+		Email:        user.Email,
+		Username:     user.Username,
+		DisplayName:  user.DisplayName,
+		AppMetadata:  user.AppMetadata,
+		UserMetadata: user.UserMetadata,
+	})
+	if err != nil {
+		return user, err
+	}
+
+	if user.PassHash != "" && !passhash.Valid(user.PassHash) {
+		return user, errors.New("unsupported password hash")
+	}
+
+	user.Email = update.Email
+	user.Username = update.Username
+	user.DisplayName = update.DisplayName
+
+	return user, nil
+}
@@ -0,0 +1,146 @@
+package management
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultUserPageSize is the number of users ListUsers returns at once
+	// when the caller does not ask for a number.
+	defaultUserPageSize = 50
+	maxUserPageSize     = 200
+)
+
+// pageToken is the decoded page token of a user listing. It carries the
+// order of the listing, so that a token is not used with another one.
+type pageToken struct {
+	Sort      models.UserSort `json:"s"`
+	Desc      bool            `json:"d,omitempty"`
+	ID        int64           `json:"i"`
+	CreatedAt time.Time       `json:"c"`
+	Email     string          `json:"e,omitempty"`
+}
+
+// ListUsers returns a page of the users matching the filter in its sort
+// order, and the token of the next page, which is empty after the last
+// page. The page token of the first page is empty.
+func (m *Management) ListUsers(ctx context.Context, filter models.UserFilter, token string) ([]models.User, string, error) {
+	const op = "services.management.ListUsers"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.String("sort", string(filter.Sort)),
+		slog.Bool("desc", filter.Desc),
+	)
+
+	switch filter.Sort {
+	case "":
+		filter.Sort = models.UserSortCreatedAt
+	case models.UserSortCreatedAt, models.UserSortEmail:
+	default:
+		log.Warn("unknown sort")
+		return nil, "", fmt.Errorf("%s: %w: unknown sort %q", op, ErrInvalidUserFilter, filter.Sort)
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultUserPageSize
+	}
+	if filter.Limit > maxUserPageSize {
+		filter.Limit = maxUserPageSize
+	}
+
+	if token != "" {
+		cursor, err := decodePageToken(token, filter)
+		if err != nil {
+			log.Warn("invalid page token", sl.Err(err))
+			return nil, "", fmt.Errorf("%s: %w: %s", op, ErrInvalidUserFilter, err.Error())
+		}
+		filter.After = &cursor
+	}
+
+	// one more user than asked for tells whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+
+	users, err := m.users.ListUsers(ctx, filter)
+	if err != nil {
+		if errors.Is(err, storage.ErrFilterUnsupported) {
+			log.Warn("unsupported filter", sl.Err(err))
+			return nil, "", fmt.Errorf("%s: %w: %s", op, ErrInvalidUserFilter, err.Error())
+		}
+		log.Error("failed to list users", sl.Err(err))
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(users) <= limit {
+		return users, "", nil
+	}
+
+	users = users[:limit]
+	last := users[limit-1]
+
+	next, err := json.Marshal(pageToken{
+		Sort:      filter.Sort,
+		Desc:      filter.Desc,
+		ID:        int64(last.ID),
+		CreatedAt: last.CreatedAt,
+		Email:     last.Email,
+	})
+	if err != nil {
+		log.Error("failed to encode page token", sl.Err(err))
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, base64.RawURLEncoding.EncodeToString(next), nil
+}
+
+// SearchUsers returns a page of the users whose email, username or display
+// name match the search like ListUsers does, for support tools to find
+// users by what they know about them.
+func (m *Management) SearchUsers(ctx context.Context, search string, filter models.UserFilter, token string) ([]models.User, string, error) {
+	const op = "services.management.SearchUsers"
+
+	search = strings.TrimSpace(search)
+	if search == "" {
+		m.log.Warn("empty search", slog.String("op", op))
+		return nil, "", fmt.Errorf("%s: %w: empty search", op, ErrInvalidUserFilter)
+	}
+	filter.Search = search
+
+	users, next, err := m.ListUsers(ctx, filter, token)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, next, nil
+}
+
+// decodePageToken returns the cursor the page token points at. It fails if
+// the token is malformed or was issued for another order.
+func decodePageToken(token string, filter models.UserFilter) (models.UserCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return models.UserCursor{}, errors.New("malformed page token")
+	}
+
+	var t pageToken
+	if err = json.Unmarshal(data, &t); err != nil {
+		return models.UserCursor{}, errors.New("malformed page token")
+	}
+
+	if t.Sort != filter.Sort || t.Desc != filter.Desc {
+		return models.UserCursor{}, errors.New("page token was issued for another sort")
+	}
+
+	return models.UserCursor{ID: t.ID, CreatedAt: t.CreatedAt, Email: t.Email}, nil
+}
@@ -0,0 +1,63 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"time"
+)
+
+// MergeUsers merges the duplicate user into the primary one on behalf of the
+// admin, as when someone signed up twice with the same email: the sessions,
+// consents and identities of the duplicate move over to the primary user,
+// which becomes an admin if the duplicate was, and the duplicate is deleted
+// like by DeleteUser. It returns the primary user.
+func (m *Management) MergeUsers(ctx context.Context, adminID int64, primaryID int64, duplicateID int64) (models.User, error) {
+	const op = "services.management.MergeUsers"
+
+	log := m.log.With(
+		slog.String("op", op),
+		slog.Int64("primary_id", primaryID),
+		slog.Int64("duplicate_id", duplicateID),
+		slog.Int64("admin_id", adminID),
+	)
+
+	log.Info("merging users")
+
+	if primaryID == duplicateID {
+		log.Warn("user can not be merged into itself")
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrSelfMerge)
+	}
+	if duplicateID == adminID {
+		log.Warn("admin tried to delete themselves")
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrSelfDeletion)
+	}
+
+	now := time.Now()
+	err := m.users.MergeUsers(ctx, primaryID, duplicateID, adminID, now, now.Add(m.cfg.DeletedUserRetention))
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to merge users", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.permissions.invalidateUser(primaryID, duplicateID)
+
+	user, err := m.users.UserByIDIncludingDeleted(ctx, primaryID)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("users merged")
+
+	return user, nil
+}
@@ -0,0 +1,118 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/publisher"
+	"time"
+)
+
+// Relay publishes the events recorded in the outbox and marks them
+// delivered. Events are published in the order they were recorded, and at
+// least once: an event whose delivery fails to be recorded is published
+// again.
+type Relay struct {
+	log       *slog.Logger
+	storage   Storage
+	publisher Publisher
+	cfg       Config
+}
+
+type Config struct {
+	// BatchSize is how many events are published per run.
+	BatchSize int
+	// Retention is how long delivered events are kept before they are
+	// deleted.
+	Retention time.Duration
+}
+
+type Storage interface {
+	PendingOutboxEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error)
+	MarkOutboxEventDelivered(ctx context.Context, id int64, at time.Time) error
+	FailOutboxEvent(ctx context.Context, id int64, reason string) error
+	DeleteDeliveredOutboxEvents(ctx context.Context, before time.Time) (int, error)
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, msg publisher.Message) error
+}
+
+func New(log *slog.Logger, storage Storage, publisher Publisher, cfg Config) *Relay {
+	return &Relay{
+		log:       log,
+		storage:   storage,
+		publisher: publisher,
+		cfg:       cfg,
+	}
+}
+
+// Relay publishes a batch of pending events and returns how many it
+// published. It stops at the first event which fails to be published, so
+// that later events are not published before it, and leaves it for the next
+// run.
+func (r *Relay) Relay(ctx context.Context) (int, error) {
+	const op = "outbox.Relay"
+
+	log := r.log.With(slog.String("op", op))
+
+	events, err := r.storage.PendingOutboxEvents(ctx, r.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	published := 0
+	for _, event := range events {
+		err = r.publisher.Publish(ctx, publisher.Message{
+			ID:        event.ID,
+			Type:      event.Type,
+			CreatedAt: event.CreatedAt,
+			Data:      event.Payload,
+		})
+		if err != nil {
+			log.Warn("failed to publish event",
+				slog.Int64("id", event.ID),
+				slog.String("type", event.Type),
+				slog.Int("attempts", event.Attempts+1),
+				sl.Err(err),
+			)
+			if err := r.storage.FailOutboxEvent(ctx, event.ID, err.Error()); err != nil {
+				return published, fmt.Errorf("%s: %w", op, err)
+			}
+
+			return published, nil
+		}
+
+		if err = r.storage.MarkOutboxEventDelivered(ctx, event.ID, time.Now()); err != nil {
+			return published, fmt.Errorf("%s: %w", op, err)
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+// DeleteDelivered deletes the events delivered longer than the retention
+// ago and returns how many it deleted.
+func (r *Relay) DeleteDelivered(ctx context.Context) (int, error) {
+	const op = "outbox.DeleteDelivered"
+
+	deleted, err := r.storage.DeleteDeliveredOutboxEvents(ctx, time.Now().Add(-r.cfg.Retention))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
+
+// Close closes the publisher, if it has connections to close.
+func (r *Relay) Close() error {
+	if closer, ok := r.publisher.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
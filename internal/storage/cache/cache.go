@@ -0,0 +1,262 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage/factory"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Cache keeps values for a while, under tags that drop them together.
+type Cache interface {
+	CacheGet(ctx context.Context, key string) ([]byte, bool, error)
+	CacheVersion(ctx context.Context) (int64, error)
+	CacheSet(ctx context.Context, key string, data []byte, ttl time.Duration, version int64, tags ...string) error
+	CacheInvalidate(ctx context.Context, tags ...string) error
+}
+
+const (
+	allUsersTag = "users"
+	allAppsTag  = "apps"
+)
+
+// Storage caches the user and app lookups logins make in front of the
+// storage it wraps, and drops the cached user or app whenever a write
+// changes it. The cache failing only costs the lookups their speed, so its
+// errors are logged rather than returned.
+//
+// Password hashes, app secrets and signing keys are not cached: whoever
+// reads the cache must not get them. They are kept in the memory of the
+// process instead, for the cached value they were loaded with, and loaded
+// from the storage again for values cached by other processes.
+type Storage struct {
+	factory.Storage
+	log   *slog.Logger
+	cache Cache
+	ttl   time.Duration
+
+	mu          sync.Mutex
+	credentials map[string]credentials
+	pruneAt     time.Time
+}
+
+func New(log *slog.Logger, storage factory.Storage, cache Cache, ttl time.Duration) *Storage {
+	return &Storage{
+		Storage:     storage,
+		log:         log,
+		cache:       cache,
+		ttl:         ttl,
+		credentials: make(map[string]credentials),
+	}
+}
+
+func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
+	return cached(ctx, s, "user:email:"+email, users, func() (models.User, error) {
+		return s.Storage.User(ctx, email)
+	})
+}
+
+func (s *Storage) UserByID(ctx context.Context, userID int64) (models.User, error) {
+	return cached(ctx, s, "user:id:"+strconv.FormatInt(userID, 10), users, func() (models.User, error) {
+		return s.Storage.UserByID(ctx, userID)
+	})
+}
+
+func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
+	return cached(ctx, s, "app:id:"+strconv.Itoa(appID), apps, func() (models.App, error) {
+		return s.Storage.App(ctx, appID)
+	})
+}
+
+func (s *Storage) AppByAudience(ctx context.Context, audience string) (models.App, error) {
+	return cached(ctx, s, "app:audience:"+audience, apps, func() (models.App, error) {
+		return s.Storage.AppByAudience(ctx, audience)
+	})
+}
+
+// kind tells how values of a type are cached.
+type kind[T any] struct {
+	// tags returns the tags the value is dropped by.
+	tags func(T) []string
+	// split returns the value without its credentials, and the credentials.
+	split func(T) (T, any)
+	// join returns the value with the credentials split returned.
+	join func(T, any) T
+}
+
+var users = kind[models.User]{
+	tags: func(user models.User) []string {
+		return []string{userTag(int64(user.ID)), allUsersTag}
+	},
+	split: func(user models.User) (models.User, any) {
+		passHash := user.PassHash
+		user.PassHash = ""
+		return user, passHash
+	},
+	join: func(user models.User, creds any) models.User {
+		user.PassHash = creds.(string)
+		return user
+	},
+}
+
+// appSecrets are the credentials of an app.
+type appSecrets struct {
+	secretHash         string
+	previousSecretHash string
+	signingKey         string
+}
+
+var apps = kind[models.App]{
+	tags: func(app models.App) []string {
+		return []string{appTag(app.ID), allAppsTag}
+	},
+	split: func(app models.App) (models.App, any) {
+		secrets := appSecrets{
+			secretHash:         app.SecretHash,
+			previousSecretHash: app.PreviousSecretHash,
+			signingKey:         app.SigningKey,
+		}
+		app.Secret, app.SecretHash, app.PreviousSecretHash, app.SigningKey = "", "", "", ""
+		return app, secrets
+	},
+	join: func(app models.App, creds any) models.App {
+		secrets := creds.(appSecrets)
+		app.SecretHash = secrets.secretHash
+		app.PreviousSecretHash = secrets.previousSecretHash
+		app.SigningKey = secrets.signingKey
+		return app
+	},
+}
+
+// entry is a cached value. Stamp tells the values cached for the key apart,
+// so that credentials are only joined to the value they were loaded with.
+type entry[T any] struct {
+	Value T
+	Stamp string
+}
+
+// credentials are the credentials split off a cached value.
+type credentials struct {
+	stamp     string
+	creds     any
+	expiresAt time.Time
+}
+
+// cached returns the value of the key from the cache, or loads and caches it
+// under its tags. Errors, not found ones included, are not cached.
+func cached[T any](ctx context.Context, s *Storage, key string, kind kind[T], load func() (T, error)) (T, error) {
+	data, ok, err := s.cache.CacheGet(ctx, key)
+	if err != nil {
+		s.log.Warn("failed to read cache", slog.String("key", key), sl.Err(err))
+	}
+	if ok {
+		var e entry[T]
+		if err = json.Unmarshal(data, &e); err == nil {
+			if creds, ok := s.credentialsOf(key, e.Stamp); ok {
+				return kind.join(e.Value, creds), nil
+			}
+
+			// the value was cached by another process, or before this one
+			// started, so its credentials are loaded here
+			v, err := load()
+			if err != nil {
+				return v, err
+			}
+			_, creds := kind.split(v)
+			s.keepCredentials(key, e.Stamp, creds)
+
+			return v, nil
+		}
+		s.log.Warn("failed to decode cached value", slog.String("key", key), sl.Err(err))
+	}
+
+	// the version is read before loading, so that a write invalidating the
+	// value meanwhile keeps it from being cached
+	version, err := s.cache.CacheVersion(ctx)
+	if err != nil {
+		s.log.Warn("failed to read cache version", slog.String("key", key), sl.Err(err))
+		return load()
+	}
+
+	v, err := load()
+	if err != nil {
+		return v, err
+	}
+
+	stamp, _, err := randtoken.New()
+	if err != nil {
+		s.log.Warn("failed to stamp cached value", slog.String("key", key), sl.Err(err))
+		return v, nil
+	}
+
+	value, creds := kind.split(v)
+	e := entry[T]{Value: value, Stamp: stamp}
+	if data, err = json.Marshal(e); err != nil {
+		s.log.Warn("failed to encode cached value", slog.String("key", key), sl.Err(err))
+		return v, nil
+	}
+	s.keepCredentials(key, e.Stamp, creds)
+	if err = s.cache.CacheSet(ctx, key, data, s.ttl, version, kind.tags(v)...); err != nil {
+		s.log.Warn("failed to write cache", slog.String("key", key), sl.Err(err))
+	}
+
+	return v, nil
+}
+
+// credentialsOf returns the credentials of the value cached for the key with
+// the stamp, and false if they are not kept.
+func (s *Storage) credentialsOf(key string, stamp string) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.credentials[key]
+	if !ok || c.stamp != stamp || time.Now().After(c.expiresAt) {
+		return nil, false
+	}
+
+	return c.creds, true
+}
+
+// keepCredentials keeps the credentials of the value cached for the key with
+// the stamp as long as the value is cached at most.
+func (s *Storage) keepCredentials(key string, stamp string, creds any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	// the credentials of values no longer cached are dropped once per ttl
+	if now.After(s.pruneAt) {
+		for k, c := range s.credentials {
+			if now.After(c.expiresAt) {
+				delete(s.credentials, k)
+			}
+		}
+		s.pruneAt = now.Add(s.ttl)
+	}
+
+	s.credentials[key] = credentials{stamp: stamp, creds: creds, expiresAt: now.Add(s.ttl)}
+}
+
+func userTag(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
+
+func appTag(appID int) string {
+	return "app:" + strconv.Itoa(appID)
+}
+
+// invalidate drops the cached values of the tags. It is called whether the
+// write succeeded or not, as a write that failed may still have changed
+// them.
+func (s *Storage) invalidate(ctx context.Context, tags ...string) {
+	// the write is done, even if the request that made it is canceled
+	if err := s.cache.CacheInvalidate(context.WithoutCancel(ctx), tags...); err != nil {
+		s.log.Error("failed to invalidate cache", slog.Any("tags", tags), sl.Err(err))
+	}
+}
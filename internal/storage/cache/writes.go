@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"context"
+	"sso/internal/domain/models"
+	"time"
+)
+
+// The writes below change users, and drop the cached ones they change.
+
+func (s *Storage) AnonymizeUser(ctx context.Context, userID int64, at time.Time) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.AnonymizeUser(ctx, userID, at)
+}
+
+func (s *Storage) CancelUserDeletion(ctx context.Context, userID int64) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.CancelUserDeletion(ctx, userID)
+}
+
+func (s *Storage) DeleteUser(ctx context.Context, userID int64, adminID int64, at time.Time, purgeAt time.Time) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.DeleteUser(ctx, userID, adminID, at, purgeAt)
+}
+
+func (s *Storage) RestoreUser(ctx context.Context, userID int64, adminID int64, at time.Time) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.RestoreUser(ctx, userID, adminID, at)
+}
+
+func (s *Storage) ScheduleUserDeletion(ctx context.Context, userID int64, at time.Time) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.ScheduleUserDeletion(ctx, userID, at)
+}
+
+func (s *Storage) ExpireUser(ctx context.Context, userID int64, at time.Time, purgeAt time.Time) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.ExpireUser(ctx, userID, at, purgeAt)
+}
+
+func (s *Storage) SetUserExpiry(ctx context.Context, userID int64, adminID int64, expiresAt *time.Time, at time.Time) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.SetUserExpiry(ctx, userID, adminID, expiresAt, at)
+}
+
+func (s *Storage) UpgradeGuest(ctx context.Context, userID int64, email string, passHash []byte) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.UpgradeGuest(ctx, userID, email, passHash)
+}
+
+func (s *Storage) LockUser(ctx context.Context, userID int64, until time.Time) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.LockUser(ctx, userID, until)
+}
+
+func (s *Storage) RecordFailedLogin(ctx context.Context, userID int64, at time.Time, windowStart time.Time) (int, error) {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.RecordFailedLogin(ctx, userID, at, windowStart)
+}
+
+func (s *Storage) UnlockUser(ctx context.Context, userID int64) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.UnlockUser(ctx, userID)
+}
+
+func (s *Storage) RecordLogin(ctx context.Context, login models.Login, keep int) error {
+	defer s.invalidate(ctx, userTag(login.UserID))
+	return s.Storage.RecordLogin(ctx, login, keep)
+}
+
+func (s *Storage) SetUserOrg(ctx context.Context, userID int64, orgID int64, adminID int64, at time.Time) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.SetUserOrg(ctx, userID, orgID, adminID, at)
+}
+
+func (s *Storage) AcceptOrgInvitation(ctx context.Context, id int64, userID int64, at time.Time) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.AcceptOrgInvitation(ctx, id, userID, at)
+}
+
+func (s *Storage) RemoveOrgMember(ctx context.Context, orgID int64, userID int64, adminID int64, at time.Time) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.RemoveOrgMember(ctx, orgID, userID, adminID, at)
+}
+
+func (s *Storage) SetOrgMemberRole(ctx context.Context, orgID int64, userID int64, role string, adminID int64, at time.Time) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.SetOrgMemberRole(ctx, orgID, userID, role, adminID, at)
+}
+
+func (s *Storage) RequirePasswordChange(ctx context.Context, userID int64, adminID int64, at time.Time) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.RequirePasswordChange(ctx, userID, adminID, at)
+}
+
+func (s *Storage) IncrementTokenVersion(ctx context.Context, userID int64) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.IncrementTokenVersion(ctx, userID)
+}
+
+func (s *Storage) SetAdmin(ctx context.Context, userID int64, isAdmin bool) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.SetAdmin(ctx, userID, isAdmin)
+}
+
+func (s *Storage) SetAppMetadata(ctx context.Context, userID int64, md map[string]string) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.SetAppMetadata(ctx, userID, md)
+}
+
+func (s *Storage) SetEmailVerified(ctx context.Context, userID int64, email string) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.SetEmailVerified(ctx, userID, email)
+}
+
+func (s *Storage) SetPhone(ctx context.Context, userID int64, phone string) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.SetPhone(ctx, userID, phone)
+}
+
+func (s *Storage) SetUserMetadata(ctx context.Context, userID int64, md map[string]string) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.SetUserMetadata(ctx, userID, md)
+}
+
+func (s *Storage) SetUsername(ctx context.Context, userID int64, username string) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.SetUsername(ctx, userID, username)
+}
+
+func (s *Storage) UpdateEmail(ctx context.Context, userID int64, email string) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.UpdateEmail(ctx, userID, email)
+}
+
+func (s *Storage) UpdatePassword(ctx context.Context, userID int64, passHash []byte) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.UpdatePassword(ctx, userID, passHash)
+}
+
+func (s *Storage) UpdateUser(ctx context.Context, update models.UserUpdate) (models.User, error) {
+	defer s.invalidate(ctx, userTag(update.UserID))
+	return s.Storage.UpdateUser(ctx, update)
+}
+
+func (s *Storage) SuspendUser(ctx context.Context, userID int64, adminID int64, at time.Time) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.SuspendUser(ctx, userID, adminID, at)
+}
+
+func (s *Storage) UnsuspendUser(ctx context.Context, userID int64, adminID int64, at time.Time) error {
+	defer s.invalidate(ctx, userTag(userID))
+	return s.Storage.UnsuspendUser(ctx, userID, adminID, at)
+}
+
+func (s *Storage) MergeUsers(ctx context.Context, primaryID int64, duplicateID int64, adminID int64, at time.Time, purgeAt time.Time) error {
+	defer s.invalidate(ctx, userTag(primaryID), userTag(duplicateID))
+	return s.Storage.MergeUsers(ctx, primaryID, duplicateID, adminID, at, purgeAt)
+}
+
+func (s *Storage) SyncEmailKeys(ctx context.Context) ([]int64, error) {
+	defer s.invalidate(ctx, allUsersTag)
+	return s.Storage.SyncEmailKeys(ctx)
+}
+
+// The writes below change apps, and drop the cached ones they change.
+
+func (s *Storage) UpdateApp(ctx context.Context, app models.App, adminID int64, at time.Time) error {
+	defer s.invalidate(ctx, appTag(app.ID))
+	return s.Storage.UpdateApp(ctx, app, adminID, at)
+}
+
+func (s *Storage) RotateAppSecret(ctx context.Context, appID int, hash string, signingKey string, previousExpiresAt *time.Time, adminID int64, at time.Time) error {
+	defer s.invalidate(ctx, appTag(appID))
+	return s.Storage.RotateAppSecret(ctx, appID, hash, signingKey, previousExpiresAt, adminID, at)
+}
+
+func (s *Storage) SetAppStatus(ctx context.Context, appID int, status string, revokeTokens bool, adminID int64, at time.Time) ([]models.TokenIssuance, error) {
+	defer s.invalidate(ctx, appTag(appID))
+	return s.Storage.SetAppStatus(ctx, appID, status, revokeTokens, adminID, at)
+}
+
+func (s *Storage) SaveClaimMapping(ctx context.Context, mapping models.ClaimMapping) error {
+	defer s.invalidate(ctx, appTag(mapping.AppID))
+	return s.Storage.SaveClaimMapping(ctx, mapping)
+}
+
+func (s *Storage) DeleteClaimMapping(ctx context.Context, appID int, source string) error {
+	defer s.invalidate(ctx, appTag(appID))
+	return s.Storage.DeleteClaimMapping(ctx, appID, source)
+}
+
+func (s *Storage) HashAppSecrets(ctx context.Context) (int, error) {
+	defer s.invalidate(ctx, allAppsTag)
+	return s.Storage.HashAppSecrets(ctx)
+}
@@ -0,0 +1,176 @@
+package factory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sso/internal/lib/policy"
+	"sso/internal/lib/tokens"
+	"sso/internal/services/auth"
+	"sso/internal/services/management"
+	"sso/internal/services/outbox"
+	"sso/internal/storage"
+	"sso/internal/storage/memory"
+	"sso/internal/storage/sqlite"
+	"strings"
+)
+
+const (
+	DriverSQLite = "sqlite"
+	DriverMemory = "memory"
+)
+
+// Storage is all the services keep in the database, whichever driver keeps
+// it.
+type Storage interface {
+	auth.UserSaver
+	auth.UserProvider
+	auth.AppProvider
+	auth.RefreshTokenStorage
+	auth.DeviceAuthorizationStorage
+	auth.AuditLog
+	auth.PasswordResetStorage
+	auth.EmailVerificationStorage
+	auth.EmailChangeStorage
+	auth.MagicLinkStorage
+	auth.MFAStorage
+	auth.WebAuthnStorage
+	auth.SMSCodeStorage
+	auth.FederationStorage
+	auth.InvitationStorage
+	auth.OrgMembershipStorage
+	auth.ConsentStorage
+	management.AppStorage
+	management.OrgStorage
+	management.ClaimMappingStorage
+	management.IdentityProviderStorage
+	management.RoleStorage
+	management.GroupStorage
+	management.UserStorage
+	management.AuditLog
+	management.UserDataProvider
+	management.PolicyStorage
+	management.APIKeyStorage
+	tokens.SessionStore
+	tokens.IssuanceStore
+	policy.RuleProvider
+	outbox.Storage
+
+	SyncEmailKeys(ctx context.Context) ([]int64, error)
+	SealUserPII(ctx context.Context, seal func(string) string) (int, error)
+	HashAppSecrets(ctx context.Context) (int, error)
+	Ping(ctx context.Context) error
+	Stats() sql.DBStats
+	ReplicaStats() []sql.DBStats
+	Close() error
+}
+
+// EmailNormalizer derives the keys the emails of users are told apart by.
+type EmailNormalizer interface {
+	Key(email string) string
+}
+
+// Config selects the driver and the database it connects to. For sqlite
+// DSN is the path to the database file. The memory driver has no DSN.
+type Config struct {
+	Driver string
+	DSN    string
+	Pool   storage.PoolConfig
+	// Replicas are read replicas of the database to send lookups of users
+	// and apps to. Neither driver has any, so New rejects them.
+	Replicas storage.ReplicaConfig
+	// SQLite are the pragmas of the connections of the sqlite driver.
+	SQLite sqlite.Pragmas
+}
+
+// New validates the DSN of the driver and opens the storage with it, so a
+// misconfigured database fails at startup rather than on the first request.
+func New(cfg Config, emails EmailNormalizer) (Storage, error) {
+	const op = "storage.factory.New"
+
+	if err := ValidateDSN(cfg.Driver, cfg.DSN); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(cfg.Replicas.DSNs) > 0 {
+		return nil, fmt.Errorf("%s: the %s driver has no replicas", op, cfg.Driver)
+	}
+
+	var s Storage
+	var err error
+	switch cfg.Driver {
+	case DriverSQLite:
+		s, err = sqlite.New(cfg.DSN, emails, cfg.Pool, cfg.SQLite)
+	case DriverMemory:
+		s = memory.New(emails)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+// Transient returns what tells the errors of the driver that calls may be
+// retried after apart, or nil for the memory driver, which has none.
+func Transient(driver string) func(error) bool {
+	switch driver {
+	case DriverSQLite:
+		return sqlite.Transient
+	}
+
+	return nil
+}
+
+// OpenDB opens the database of the config for other uses than the storage,
+// such as copying data. The memory driver has no SQL database.
+func OpenDB(cfg Config) (*sql.DB, error) {
+	const op = "storage.factory.OpenDB"
+
+	if err := ValidateDSN(cfg.Driver, cfg.DSN); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var db *sql.DB
+	var err error
+	switch cfg.Driver {
+	case DriverSQLite:
+		db, err = sqlite.OpenDB(cfg.DSN, cfg.SQLite)
+	case DriverMemory:
+		err = fmt.Errorf("the %s driver has no sql database", cfg.Driver)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return db, nil
+}
+
+// ValidateDSN checks that the driver is known and that the DSN is one it
+// can connect with, without connecting.
+func ValidateDSN(driver string, dsn string) error {
+	switch driver {
+	case DriverSQLite:
+		if dsn == "" {
+			return fmt.Errorf("the path to the database is required for the sqlite driver")
+		}
+		if dsn == ":memory:" || strings.HasPrefix(dsn, "file:") {
+			return nil
+		}
+		// SQLite creates a missing database file, but not the directory
+		// it goes in
+		dir := filepath.Dir(dsn)
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("sqlite database directory %q: %w", dir, err)
+		}
+	case DriverMemory:
+		if dsn != "" {
+			return fmt.Errorf("the memory driver takes no dsn")
+		}
+	default:
+		return fmt.Errorf("unknown storage driver %q", driver)
+	}
+
+	return nil
+}
@@ -0,0 +1,315 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strconv"
+	"time"
+)
+
+// ScheduleUserDeletion sets when the account of the user is anonymized. It
+// fails with storage.ErrUserNotFound if there is no such user or the account
+// is already deleted.
+func (s *Storage) ScheduleUserDeletion(_ context.Context, userID int64, at time.Time) error {
+	const op = "storage.memory.ScheduleUserDeletion"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.activeUser(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.DeletionScheduledAt = ptr(at.UTC())
+	u.touch()
+
+	return nil
+}
+
+// CancelUserDeletion keeps the account of the user if it is not deleted
+// yet. It fails with storage.ErrUserNotFound otherwise.
+func (s *Storage) CancelUserDeletion(_ context.Context, userID int64) error {
+	const op = "storage.memory.CancelUserDeletion"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.activeUser(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.DeletionScheduledAt = nil
+	u.touch()
+
+	return nil
+}
+
+// UsersDueForDeletion returns the ids of at most limit users whose accounts
+// are scheduled to be deleted by now.
+func (s *Storage) UsersDueForDeletion(_ context.Context, now time.Time, limit int) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	due := s.users.where(func(u *userRow) bool {
+		return u.DeletionScheduledAt != nil && !u.DeletionScheduledAt.After(now) && u.anonymizedAt == nil
+	})
+	slices.SortStableFunc(due, func(a, b *userRow) int {
+		return a.DeletionScheduledAt.Compare(*b.DeletionScheduledAt)
+	})
+
+	return userIDs(due, limit), nil
+}
+
+// AnonymizeUser deletes the personal data of the user and records the
+// deletion in the audit log. The user is kept with a placeholder email, so
+// that the audit log still refers to an existing user, and the email can be
+// registered again. It fails with storage.ErrUserNotFound if the account is
+// not scheduled for deletion or is already anonymized.
+func (s *Storage) AnonymizeUser(_ context.Context, userID int64, at time.Time) error {
+	const op = "storage.memory.AnonymizeUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok || u.DeletionScheduledAt == nil || u.anonymizedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	u.Email = "deleted-" + strconv.FormatInt(userID, 10) + "@deleted.invalid"
+	u.emailKey = nil
+	u.PassHash = ""
+	u.Username = ""
+	u.Phone = ""
+	u.PhoneVerified = false
+	u.EmailVerified = false
+	u.IsAdmin = false
+	u.FailedLogins = 0
+	u.failedLoginsSince = nil
+	u.LockedUntil = nil
+	u.AppMetadata = map[string]string{}
+	u.UserMetadata = map[string]string{}
+	u.DisplayName = ""
+	u.FirstName = ""
+	u.LastName = ""
+	u.Locale = ""
+	u.AvatarURL = ""
+	u.LastLoginIP = ""
+	u.LastLoginUserAgent = ""
+	u.TokenVersion++
+	if u.DeletedAt == nil {
+		u.DeletedAt = ptr(at.UTC())
+	}
+	u.anonymizedAt = ptr(at.UTC())
+	u.touch()
+
+	s.deleteUserData(userID)
+	for _, invitation := range s.invitations.rows {
+		if invitation.UserID == userID {
+			invitation.Email = ""
+		}
+	}
+
+	s.insertAuditEvent(models.AuditEvent{Type: models.AuditAccountDeleted, UserID: userID, CreatedAt: at})
+
+	return nil
+}
+
+// deleteUserData deletes the data of the user that goes with the account.
+func (s *Storage) deleteUserData(userID int64) {
+	s.deleteUserSessions(userID)
+
+	s.deviceAuthorizations.delete(func(a *models.DeviceAuthorization) bool { return a.UserID == userID })
+	s.passwordResetTokens.delete(func(t *models.PasswordResetToken) bool { return t.UserID == userID })
+	s.emailVerificationTokens.delete(func(t *models.EmailVerificationToken) bool { return t.UserID == userID })
+	s.emailChangeTokens.delete(func(t *models.EmailChangeToken) bool { return t.UserID == userID })
+	s.magicLinks.delete(func(l *models.MagicLink) bool { return l.UserID == userID })
+	s.smsCodes.delete(func(c *models.SMSCode) bool { return c.UserID == userID })
+	delete(s.totp, userID)
+	s.mfaChallenges.delete(func(c *models.MFAChallenge) bool { return c.UserID == userID })
+	s.recoveryCodes.delete(func(c *recoveryCodeRow) bool { return c.userID == userID })
+	s.webauthnCredentials.delete(func(c *models.WebAuthnCredential) bool { return c.UserID == userID })
+	s.userIdentities.delete(func(i *models.UserIdentity) bool { return i.UserID == userID })
+	maps.DeleteFunc(s.consents, func(key consentKey, _ *models.Consent) bool { return key.userID == userID })
+	s.loginHistory.delete(func(l *models.Login) bool { return l.UserID == userID })
+	delete(s.notificationPreferences, userID)
+	maps.DeleteFunc(s.userRoles, func(key userRoleKey, _ time.Time) bool { return key.userID == userID })
+	maps.DeleteFunc(s.groupMembers, func(key groupMemberKey, _ time.Time) bool { return key.userID == userID })
+}
+
+// deleteUserSessions ends the sessions of the user, as when an admin
+// deletes or suspends the user.
+func (s *Storage) deleteUserSessions(userID int64) {
+	s.refreshTokens.delete(func(t *models.RefreshToken) bool { return t.UserID == userID })
+	s.userSessions.delete(func(u *models.UserSession) bool { return u.UserID == userID })
+	s.trustedDevices.delete(func(d *models.TrustedDevice) bool { return d.UserID == userID })
+}
+
+// DeleteUser soft-deletes the user on behalf of the admin: the user can no
+// longer log in, their sessions end, and the account is anonymized at
+// purgeAt unless it is restored before. It fails with
+// storage.ErrUserNotFound if there is no such user or the user is already
+// deleted.
+func (s *Storage) DeleteUser(_ context.Context, userID int64, adminID int64, at time.Time, purgeAt time.Time) error {
+	const op = "storage.memory.DeleteUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.activeUser(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.DeletedAt = ptr(at.UTC())
+	u.DeletionScheduledAt = ptr(purgeAt.UTC())
+	u.TokenVersion++
+	u.touch()
+
+	s.deleteUserSessions(userID)
+
+	s.insertAuditEvent(models.AuditEvent{Type: models.AuditUserDeleted, UserID: userID, ActorID: adminID, CreatedAt: at})
+
+	return nil
+}
+
+// RestoreUser undoes the deletion of the user by the admin. It fails with
+// storage.ErrUserNotFound if the user is not deleted or already anonymized.
+func (s *Storage) RestoreUser(_ context.Context, userID int64, adminID int64, at time.Time) error {
+	const op = "storage.memory.RestoreUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok || u.DeletedAt == nil || u.anonymizedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.DeletedAt = nil
+	u.DeletionScheduledAt = nil
+	u.touch()
+
+	s.insertAuditEvent(models.AuditEvent{Type: models.AuditUserRestored, UserID: userID, ActorID: adminID, CreatedAt: at})
+
+	return nil
+}
+
+// RequirePasswordChange makes the user set a new password before logging in
+// again and ends the sessions of the user, recording the admin as the
+// actor. UpdatePassword clears the requirement. It fails with
+// storage.ErrUserNotFound if there is no such user or the user is deleted.
+func (s *Storage) RequirePasswordChange(_ context.Context, userID int64, adminID int64, at time.Time) error {
+	const op = "storage.memory.RequirePasswordChange"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.activeUser(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.PasswordChangeRequired = true
+	u.TokenVersion++
+
+	s.deleteUserSessions(userID)
+
+	s.insertAuditEvent(models.AuditEvent{
+		Type:      models.AuditPasswordChangeForced,
+		UserID:    userID,
+		ActorID:   adminID,
+		CreatedAt: at,
+	})
+
+	return nil
+}
+
+// SetUserExpiry sets when the account of the user expires on behalf of the
+// admin, or makes it permanent if expiresAt is nil. An account disabled on
+// expiry can be used again once its expiry is moved into the future. It
+// fails with storage.ErrUserNotFound if there is no such user or the user is
+// deleted.
+func (s *Storage) SetUserExpiry(_ context.Context, userID int64, adminID int64, expiresAt *time.Time, at time.Time) error {
+	const op = "storage.memory.SetUserExpiry"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.activeUser(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.ExpiresAt = utcPtr(expiresAt)
+	u.ExpiredAt = nil
+
+	s.insertAuditEvent(models.AuditEvent{Type: models.AuditUserExpirySet, UserID: userID, ActorID: adminID, CreatedAt: at})
+
+	return nil
+}
+
+// ExpiredUsers returns the ids of at most limit users whose accounts have
+// expired by now and are not disabled or deleted yet.
+func (s *Storage) ExpiredUsers(_ context.Context, now time.Time, limit int) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expired := s.users.where(func(u *userRow) bool { return u.expired(now) })
+	slices.SortStableFunc(expired, func(a, b *userRow) int {
+		return a.ExpiresAt.Compare(*b.ExpiresAt)
+	})
+
+	return userIDs(expired, limit), nil
+}
+
+// expired tells whether the account has expired by the time and is not
+// disabled or deleted yet.
+func (u *userRow) expired(at time.Time) bool {
+	return u.ExpiresAt != nil && !u.ExpiresAt.After(at) && u.ExpiredAt == nil && u.DeletedAt == nil
+}
+
+// ExpireUser disables the expired account of the user: its sessions end
+// and it stays disabled until its expiry is extended. With purgeAt set, the
+// user is deleted too, as by DeleteUser, and anonymized at purgeAt. It fails
+// with storage.ErrUserNotFound if the account has not expired, as when its
+// expiry was extended meanwhile, or is already disabled or deleted.
+func (s *Storage) ExpireUser(_ context.Context, userID int64, at time.Time, purgeAt time.Time) error {
+	const op = "storage.memory.ExpireUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok || !u.expired(at) {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.ExpiredAt = ptr(at.UTC())
+	u.DeletedAt = nil
+	u.DeletionScheduledAt = nil
+	if !purgeAt.IsZero() {
+		u.DeletedAt = ptr(at.UTC())
+		u.DeletionScheduledAt = ptr(purgeAt.UTC())
+	}
+	u.TokenVersion++
+	u.touch()
+
+	s.deleteUserSessions(userID)
+
+	s.insertAuditEvent(models.AuditEvent{Type: models.AuditUserExpired, UserID: userID, CreatedAt: at})
+
+	return nil
+}
+
+// userIDs returns the ids of at most limit of the users.
+func userIDs(users []*userRow, limit int) []int64 {
+	var ids []int64
+	for _, u := range users {
+		if len(ids) == limit {
+			break
+		}
+		ids = append(ids, int64(u.ID))
+	}
+
+	return ids
+}
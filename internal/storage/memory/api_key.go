@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// SaveAPIKey stores the hashed key of the app on behalf of the admin who
+// created it and returns its new id.
+func (s *Storage) SaveAPIKey(_ context.Context, key models.APIKey) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key.Key = ""
+	key.CreatedAt = key.CreatedAt.UTC()
+	key.LastUsedAt = nil
+	key.RevokedAt = nil
+	key.ID = s.apiKeys.insert(&key)
+
+	s.saveAdminEvent(models.AuditAPIKeyCreated, 0, key.AppID, key.CreatedBy, key.Prefix, key.CreatedAt)
+
+	return key.ID, nil
+}
+
+// APIKeys returns the keys of the app, the revoked ones included, newest
+// first.
+func (s *Storage) APIKeys(_ context.Context, appID int) ([]models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []models.APIKey
+	for _, key := range s.apiKeys.where(func(k *models.APIKey) bool { return k.AppID == appID }) {
+		keys = append(keys, apiKey(key))
+	}
+	slices.Reverse(keys)
+
+	return keys, nil
+}
+
+// APIKeyByHash returns the key with the hash, even if it is revoked. It
+// fails with storage.ErrAPIKeyNotFound if there is no such key.
+func (s *Storage) APIKeyByHash(_ context.Context, hash string) (models.APIKey, error) {
+	const op = "storage.memory.APIKeyByHash"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.apiKeys.first(func(k *models.APIKey) bool { return k.KeyHash == hash })
+	if !ok {
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+	}
+
+	return apiKey(key), nil
+}
+
+// TouchAPIKey records that the key was used at the time. Uses within a
+// minute of the recorded one are not written, as by the databases.
+func (s *Storage) TouchAPIKey(_ context.Context, keyID int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys.get(keyID)
+	if ok && (key.LastUsedAt == nil || key.LastUsedAt.Before(at.Add(-time.Minute))) {
+		key.LastUsedAt = ptr(at.UTC())
+	}
+
+	return nil
+}
+
+// RevokeAPIKey revokes the key of the app on behalf of the admin. It fails
+// with storage.ErrAPIKeyNotFound if the app has no such key that is not
+// revoked already.
+func (s *Storage) RevokeAPIKey(_ context.Context, appID int, keyID int64, adminID int64, at time.Time) error {
+	const op = "storage.memory.RevokeAPIKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys.get(keyID)
+	if !ok || key.AppID != appID || key.RevokedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+	}
+	key.RevokedAt = ptr(at.UTC())
+
+	s.saveAdminEvent(models.AuditAPIKeyRevoked, 0, appID, adminID, key.Prefix, at)
+
+	return nil
+}
+
+func apiKey(key *models.APIKey) models.APIKey {
+	found := *key
+	found.LastUsedAt = utcPtr(key.LastUsedAt)
+	found.RevokedAt = utcPtr(key.RevokedAt)
+
+	return found
+}
@@ -0,0 +1,464 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage"
+	"time"
+)
+
+// appRow is an app as the databases keep it. Claims are kept encoded, so
+// that they decode to the same types they do from the databases.
+type appRow struct {
+	models.App
+	claims       string
+	secretHashed bool
+}
+
+// app returns a copy of the app that callers can change freely.
+func (a *appRow) app() (models.App, error) {
+	app := a.App
+	app.Scopes = slices.Clone(a.Scopes)
+	app.GrantTypes = slices.Clone(a.GrantTypes)
+	app.RedirectURIs = slices.Clone(a.RedirectURIs)
+	app.ClaimMappings = nil
+	if err := json.Unmarshal([]byte(a.claims), &app.Claims); err != nil {
+		return models.App{}, fmt.Errorf("invalid claims: %w", err)
+	}
+
+	return app, nil
+}
+
+// setSettings sets what admins set of the app, as the databases keep it.
+func (a *appRow) setSettings(app models.App) error {
+	claims, err := json.Marshal(appClaims(app))
+	if err != nil {
+		return err
+	}
+
+	a.Name = app.Name
+	a.Audience = app.Audience
+	a.TokenFormat = app.TokenFormat
+	a.Scopes = fields(app.Scopes)
+	a.GrantTypes = fields(app.GrantTypes)
+	a.RedirectURIs = fields(app.RedirectURIs)
+	a.claims = string(claims)
+	a.AccessTokenTTL = app.AccessTokenTTL.Truncate(time.Second)
+	a.RefreshTokenTTL = app.RefreshTokenTTL.Truncate(time.Second)
+	a.ThirdParty = app.ThirdParty
+	a.LoginQuota = app.LoginQuota
+	a.RegistrationQuota = app.RegistrationQuota
+
+	return nil
+}
+
+// checkAppUnique fails like the unique indexes of the apps table if the app
+// shares its name, audience or secret with another app than the one with the
+// id.
+func (s *Storage) checkAppUnique(a *appRow, id int) error {
+	for _, other := range s.apps.rows {
+		if other.ID == id || other == a {
+			continue
+		}
+		if other.Name == a.Name || other.SecretHash == a.SecretHash ||
+			audience(other.App) == audience(a.App) {
+			return storage.ErrAppExists
+		}
+	}
+
+	return nil
+}
+
+// audience returns the audience tokens of the app are issued for, its name
+// if it has no explicit one.
+func audience(app models.App) string {
+	if app.Audience != "" {
+		return app.Audience
+	}
+
+	return app.Name
+}
+
+// SaveApp creates the app with the hash of its secret on behalf of the admin and returns its new id. It
+// fails with storage.ErrAppExists if an app has the name or the audience already.
+func (s *Storage) SaveApp(_ context.Context, app models.App, adminID int64) (int, error) {
+	const op = "storage.memory.SaveApp"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row := &appRow{
+		App: models.App{
+			OrgID:      app.OrgID,
+			SecretHash: app.SecretHash,
+			SigningKey: app.SigningKey,
+			Status:     app.Status,
+			CreatedAt:  app.CreatedAt.UTC(),
+		},
+		secretHashed: true,
+	}
+	if err := row.setSettings(app); err != nil {
+		return 0, fmt.Errorf("%s: %s", op, err.Error())
+	}
+	if err := s.checkAppUnique(row, 0); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id := int(s.apps.insert(row))
+	row.ID = id
+
+	s.saveAdminEvent(models.AuditAppCreated, 0, id, adminID, app.Name, app.CreatedAt)
+
+	return id, nil
+}
+
+// UpdateApp replaces the settings of the app with the id on behalf of the
+// admin, leaving its secret and its org as is. It fails with storage.ErrAppNotFound if
+// there is no such app, and with storage.ErrAppExists if another app has
+// the name or the audience.
+func (s *Storage) UpdateApp(_ context.Context, app models.App, adminID int64, at time.Time) error {
+	const op = "storage.memory.UpdateApp"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.apps.get(int64(app.ID))
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	updated := *row
+	if err := updated.setSettings(app); err != nil {
+		return fmt.Errorf("%s: %s", op, err.Error())
+	}
+	if err := s.checkAppUnique(&updated, app.ID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	*row = updated
+
+	s.saveAdminEvent(models.AuditAppUpdated, 0, app.ID, adminID, app.Name, at)
+
+	return nil
+}
+
+// SetAppStatus gives the app the status on behalf of the admin. With
+// revokeTokens, the refresh tokens of the app are revoked along with the
+// access tokens it was issued, whose unexpired issuances are returned for
+// their ids to be denied. It fails with storage.ErrAppNotFound if there is no
+// such app.
+func (s *Storage) SetAppStatus(
+	_ context.Context,
+	appID int,
+	status string,
+	revokeTokens bool,
+	adminID int64,
+	at time.Time,
+) ([]models.TokenIssuance, error) {
+	const op = "storage.memory.SetAppStatus"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.apps.get(int64(appID))
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	// a disabled app that stays disabled keeps the time it was disabled at
+	if row.Status != status {
+		row.DisabledAt = nil
+		if status == models.AppStatusDisabled {
+			row.DisabledAt = ptr(at.UTC())
+		}
+	}
+	row.Status = status
+
+	var revoked []models.TokenIssuance
+	if revokeTokens {
+		for _, token := range s.refreshTokens.all() {
+			if token.AppID == appID && token.RevokedAt == nil {
+				token.RevokedAt = ptr(at.UTC())
+			}
+		}
+
+		for _, issuance := range s.tokenIssuances.all() {
+			if issuance.AppID != appID || issuance.RevokedAt != nil {
+				continue
+			}
+			if issuance.ExpiresAt.After(at.UTC()) {
+				revoked = append(revoked, *issuance)
+			}
+			issuance.RevokedAt = ptr(at.UTC())
+		}
+	}
+
+	s.saveAdminEvent(models.AuditAppStatusChanged, 0, appID, adminID, status, at)
+
+	return revoked, nil
+}
+
+// RotateAppSecret replaces the secret of the app with the one with the hash,
+// and its signing key with signingKey, on behalf of the admin. The replaced
+// secret is still accepted until previousExpiresAt, if it is set. It fails
+// with storage.ErrAppNotFound if there is no such app.
+func (s *Storage) RotateAppSecret(
+	_ context.Context,
+	appID int,
+	hash string,
+	signingKey string,
+	previousExpiresAt *time.Time,
+	adminID int64,
+	at time.Time,
+) error {
+	const op = "storage.memory.RotateAppSecret"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.apps.get(int64(appID))
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	row.PreviousSecretHash = ""
+	if previousExpiresAt != nil {
+		row.PreviousSecretHash = row.SecretHash
+	}
+	row.PreviousSecretExpiresAt = utcPtr(previousExpiresAt)
+	row.SecretHash = hash
+	row.SigningKey = signingKey
+
+	s.saveAdminEvent(models.AuditAppSecretRotated, 0, appID, adminID, "", at)
+
+	return nil
+}
+
+// HashAppSecrets replaces the plaintext secrets of apps created before
+// secrets were hashed with their hash, and returns how many it replaced.
+// These apps signed their tokens with the secret, so those without a signing
+// key get a random one.
+func (s *Storage) HashAppSecrets(_ context.Context) (int, error) {
+	const op = "storage.memory.HashAppSecrets"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hashed := 0
+	for _, row := range s.apps.all() {
+		if row.secretHashed {
+			continue
+		}
+		if row.SigningKey == "" {
+			key, _, err := randtoken.New()
+			if err != nil {
+				return 0, fmt.Errorf("%s: %w", op, err)
+			}
+			row.SigningKey = key
+		}
+		row.SecretHash = randtoken.Hash(row.SecretHash)
+		row.secretHashed = true
+		hashed++
+	}
+
+	return hashed, nil
+}
+
+// Apps returns the apps of the org, or every app if orgID is zero, the
+// disabled ones included, ordered by id.
+func (s *Storage) Apps(_ context.Context, orgID int64) ([]models.App, error) {
+	const op = "storage.memory.Apps"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var apps []models.App
+	for _, row := range s.apps.all() {
+		if orgID != 0 && row.OrgID != orgID {
+			continue
+		}
+		app, err := row.app()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", op, err.Error())
+		}
+		apps = append(apps, app)
+	}
+
+	return apps, nil
+}
+
+// App returns the app with the id. It fails with storage.ErrAppNotFound if
+// there is no such app, and with storage.ErrAppDisabled if it is disabled.
+func (s *Storage) App(_ context.Context, appID int) (models.App, error) {
+	const op = "storage.memory.App"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row, ok := s.apps.get(int64(appID))
+	if !ok {
+		return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	app, err := row.app()
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %s", op, err.Error())
+	}
+	if app.Status == models.AppStatusDisabled {
+		return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppDisabled)
+	}
+	app.ClaimMappings = s.appClaimMappings(app.ID)
+
+	return app, nil
+}
+
+// AppByAudience returns the app whose tokens are issued for the audience.
+// Apps without an explicit audience are matched by name, and no two apps
+// share an audience. Disabled apps are not found.
+func (s *Storage) AppByAudience(_ context.Context, aud string) (models.App, error) {
+	const op = "storage.memory.AppByAudience"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row, ok := s.apps.first(func(a *appRow) bool {
+		return audience(a.App) == aud && a.Status != models.AppStatusDisabled
+	})
+	if !ok {
+		return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	app, err := row.app()
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %s", op, err.Error())
+	}
+	app.ClaimMappings = s.appClaimMappings(app.ID)
+
+	return app, nil
+}
+
+// AppIncludingDisabled returns the app with the id even if it is disabled.
+// It fails with storage.ErrAppNotFound if there is no such app.
+func (s *Storage) AppIncludingDisabled(_ context.Context, appID int) (models.App, error) {
+	const op = "storage.memory.AppIncludingDisabled"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row, ok := s.apps.get(int64(appID))
+	if !ok {
+		return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	app, err := row.app()
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return app, nil
+}
+
+// appClaims returns the static claims of the app to store, an empty object
+// rather than null if it has none.
+func appClaims(app models.App) map[string]any {
+	if app.Claims == nil {
+		return map[string]any{}
+	}
+
+	return app.Claims
+}
+
+// AppStats counts the logins to the app from the time from up to to, the
+// users who logged in and the access tokens issued for the app, from the
+// audit log and the token issuances.
+func (s *Storage) AppStats(_ context.Context, appID int, from time.Time, to time.Time) (models.AppStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := models.AppStats{AppID: appID, From: from, To: to}
+
+	within := func(t time.Time) bool {
+		return !t.Before(from) && t.Before(to)
+	}
+
+	users := make(map[int64]struct{})
+	for _, event := range s.auditEvents.rows {
+		if event.AppID != appID || !within(event.CreatedAt) {
+			continue
+		}
+		switch event.Type {
+		case models.AuditLoginSucceeded:
+			stats.Logins++
+			if event.UserID != 0 {
+				users[event.UserID] = struct{}{}
+			}
+		case models.AuditLoginFailed:
+			stats.FailedLogins++
+		}
+	}
+	stats.UniqueUsers = len(users)
+
+	for _, issuance := range s.tokenIssuances.rows {
+		if issuance.AppID == appID && within(issuance.IssuedAt) {
+			stats.TokensIssued++
+		}
+	}
+
+	return stats, nil
+}
+
+// SaveClaimMapping creates the mapping or replaces the target of an existing
+// mapping of the same source claim.
+func (s *Storage) SaveClaimMapping(_ context.Context, mapping models.ClaimMapping) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.claimMappings[mapping.AppID] == nil {
+		s.claimMappings[mapping.AppID] = make(map[string]string)
+	}
+	s.claimMappings[mapping.AppID][mapping.Source] = mapping.Target
+
+	return nil
+}
+
+func (s *Storage) ClaimMappings(_ context.Context, appID int) ([]models.ClaimMapping, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var mappings []models.ClaimMapping
+	for _, source := range slices.Sorted(maps.Keys(s.claimMappings[appID])) {
+		mappings = append(mappings, models.ClaimMapping{
+			AppID:  appID,
+			Source: source,
+			Target: s.claimMappings[appID][source],
+		})
+	}
+
+	return mappings, nil
+}
+
+func (s *Storage) DeleteClaimMapping(_ context.Context, appID int, source string) error {
+	const op = "storage.memory.DeleteClaimMapping"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.claimMappings[appID][source]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrClaimMappingNotFound)
+	}
+	delete(s.claimMappings[appID], source)
+
+	return nil
+}
+
+// appClaimMappings returns the claim mappings of the app by source claim.
+func (s *Storage) appClaimMappings(appID int) map[string]string {
+	byName := make(map[string]string, len(s.claimMappings[appID]))
+	maps.Copy(byName, s.claimMappings[appID])
+
+	return byName
+}
@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"context"
+	"sso/internal/domain/models"
+	"time"
+)
+
+func (s *Storage) SaveAuditEvent(_ context.Context, event models.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.insertAuditEvent(event)
+
+	return nil
+}
+
+// insertAuditEvent adds the event to the audit log and records it in the
+// outbox, as the outbox_audit_event trigger does.
+func (s *Storage) insertAuditEvent(event models.AuditEvent) {
+	event.CreatedAt = event.CreatedAt.UTC()
+	event.ID = s.auditEvents.insert(&event)
+
+	// the columns left empty are null
+	orNull := func(id int64) *int64 {
+		if id == 0 {
+			return nil
+		}
+		return &id
+	}
+	var detail *string
+	if event.Detail != "" {
+		detail = &event.Detail
+	}
+
+	s.recordOutboxEvent(string(event.Type), struct {
+		AuditEventID int64   `json:"audit_event_id"`
+		UserID       *int64  `json:"user_id"`
+		AppID        *int64  `json:"app_id"`
+		ActorID      *int64  `json:"actor_id"`
+		Detail       *string `json:"detail"`
+	}{event.ID, orNull(event.UserID), orNull(int64(event.AppID)), orNull(event.ActorID), detail})
+}
+
+// AuditEvents returns the events matching the filter, oldest first.
+func (s *Storage) AuditEvents(_ context.Context, filter models.AuditEventFilter) ([]models.AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []models.AuditEvent
+	for _, event := range s.auditEvents.all() {
+		switch {
+		case event.ID <= filter.AfterID:
+		case filter.Type != "" && event.Type != filter.Type:
+		case filter.UserID != 0 && event.UserID != filter.UserID:
+		case filter.OrgID != 0 && !s.inOrg(event, filter.OrgID):
+		default:
+			events = append(events, *event)
+		}
+		if filter.Limit > 0 && len(events) == filter.Limit {
+			break
+		}
+	}
+
+	return events, nil
+}
+
+// inOrg tells whether the event is about a user or an app of the org.
+func (s *Storage) inOrg(event *models.AuditEvent, orgID int64) bool {
+	if u, ok := s.users.get(event.UserID); ok && u.OrgID == orgID {
+		return true
+	}
+	if a, ok := s.apps.get(int64(event.AppID)); ok && a.OrgID == orgID {
+		return true
+	}
+
+	return false
+}
+
+// saveAdminEvent records the change the admin made in the audit log,
+// leaving user_id and app_id empty when they are zero, as for global roles.
+func (s *Storage) saveAdminEvent(
+	eventType models.AuditEventType,
+	userID int64,
+	appID int,
+	adminID int64,
+	detail string,
+	at time.Time,
+) {
+	s.insertAuditEvent(models.AuditEvent{
+		Type:      eventType,
+		UserID:    userID,
+		AppID:     appID,
+		ActorID:   adminID,
+		Detail:    detail,
+		CreatedAt: at,
+	})
+}
@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+type consentKey struct {
+	userID int64
+	appID  int
+}
+
+// SaveConsent stores the consent of the user to the app, replacing the
+// previous one.
+func (s *Storage) SaveConsent(_ context.Context, consent models.Consent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	consent.Scopes = fields(consent.Scopes)
+	consent.GrantedAt = consent.GrantedAt.UTC()
+	consent.AppName = ""
+	s.consents[consentKey{consent.UserID, consent.AppID}] = &consent
+
+	return nil
+}
+
+func (s *Storage) Consent(_ context.Context, userID int64, appID int) (models.Consent, error) {
+	const op = "storage.memory.Consent"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	consent, ok := s.consent(s.consents[consentKey{userID, appID}])
+	if !ok {
+		return models.Consent{}, fmt.Errorf("%s: %w", op, storage.ErrConsentNotFound)
+	}
+
+	return consent, nil
+}
+
+// Consents returns the consents of the user, the most recently granted
+// first.
+func (s *Storage) Consents(_ context.Context, userID int64) ([]models.Consent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var consents []models.Consent
+	for key, row := range s.consents {
+		if key.userID != userID {
+			continue
+		}
+		if consent, ok := s.consent(row); ok {
+			consents = append(consents, consent)
+		}
+	}
+	slices.SortStableFunc(consents, func(a, b models.Consent) int {
+		return b.GrantedAt.Compare(a.GrantedAt)
+	})
+
+	return consents, nil
+}
+
+// DeleteConsent withdraws the consent of the user to the app. It fails with
+// storage.ErrConsentNotFound if the user has not consented to the app.
+func (s *Storage) DeleteConsent(_ context.Context, userID int64, appID int) error {
+	const op = "storage.memory.DeleteConsent"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := consentKey{userID, appID}
+	if _, ok := s.consents[key]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrConsentNotFound)
+	}
+	delete(s.consents, key)
+
+	return nil
+}
+
+// consent returns a copy of the consent with the name of its app. Consents
+// to apps that are gone are not found.
+func (s *Storage) consent(row *models.Consent) (models.Consent, bool) {
+	if row == nil {
+		return models.Consent{}, false
+	}
+	app, ok := s.apps.get(int64(row.AppID))
+	if !ok {
+		return models.Consent{}, false
+	}
+
+	consent := *row
+	consent.AppName = app.Name
+	consent.Scopes = slices.Clone(row.Scopes)
+
+	return consent, true
+}
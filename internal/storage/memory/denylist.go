@@ -0,0 +1,34 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+// Deny adds the token id to the denylist until the token expires.
+func (s *Storage) Deny(_ context.Context, tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range s.denylist {
+		if now.After(exp) {
+			delete(s.denylist, id)
+		}
+	}
+
+	if now.Before(expiresAt) {
+		s.denylist[tokenID] = expiresAt
+	}
+
+	return nil
+}
+
+func (s *Storage) IsDenied(_ context.Context, tokenID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	exp, ok := s.denylist[tokenID]
+
+	return ok && time.Now().Before(exp), nil
+}
@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveDeviceAuthorization(_ context.Context, auth models.DeviceAuthorization) error {
+	const op = "storage.memory.SaveDeviceAuthorization"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.deviceAuthorizations.first(func(a *models.DeviceAuthorization) bool {
+		return a.DeviceCodeHash == auth.DeviceCodeHash || a.UserCode == auth.UserCode
+	})
+	if exists {
+		return fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeExists)
+	}
+
+	auth.UserID = 0
+	auth.LastPolledAt = nil
+	auth.ExpiresAt = auth.ExpiresAt.UTC()
+	auth.CreatedAt = auth.CreatedAt.UTC()
+	auth.ID = s.deviceAuthorizations.insert(&auth)
+
+	return nil
+}
+
+func (s *Storage) DeviceAuthorization(_ context.Context, deviceCodeHash string) (models.DeviceAuthorization, error) {
+	const op = "storage.memory.DeviceAuthorization"
+
+	auth, err := s.deviceAuthorization(func(a *models.DeviceAuthorization) bool {
+		return a.DeviceCodeHash == deviceCodeHash
+	})
+	if err != nil {
+		return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return auth, nil
+}
+
+func (s *Storage) DeviceAuthorizationByUserCode(_ context.Context, userCode string) (models.DeviceAuthorization, error) {
+	const op = "storage.memory.DeviceAuthorizationByUserCode"
+
+	auth, err := s.deviceAuthorization(func(a *models.DeviceAuthorization) bool {
+		return a.UserCode == userCode
+	})
+	if err != nil {
+		return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return auth, nil
+}
+
+// UpdateDeviceAuthorizationStatus moves the authorization from one status to
+// another. It fails with storage.ErrDeviceCodeNotFound if the authorization
+// is not in the from status anymore.
+func (s *Storage) UpdateDeviceAuthorizationStatus(
+	_ context.Context,
+	id int64,
+	from models.DeviceAuthorizationStatus,
+	to models.DeviceAuthorizationStatus,
+	userID int64,
+) error {
+	const op = "storage.memory.UpdateDeviceAuthorizationStatus"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	auth, ok := s.deviceAuthorizations.get(id)
+	if !ok || auth.Status != from {
+		return fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+	}
+	auth.Status = to
+	if userID != 0 {
+		auth.UserID = userID
+	}
+
+	return nil
+}
+
+func (s *Storage) TouchDeviceAuthorization(_ context.Context, id int64, polledAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if auth, ok := s.deviceAuthorizations.get(id); ok {
+		auth.LastPolledAt = ptr(polledAt.UTC())
+	}
+
+	return nil
+}
+
+func (s *Storage) deviceAuthorization(match func(*models.DeviceAuthorization) bool) (models.DeviceAuthorization, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	auth, ok := s.deviceAuthorizations.first(match)
+	if !ok {
+		return models.DeviceAuthorization{}, storage.ErrDeviceCodeNotFound
+	}
+
+	found := *auth
+	found.LastPolledAt = utcPtr(auth.LastPolledAt)
+
+	return found, nil
+}
@@ -0,0 +1,34 @@
+package memory
+
+import (
+	"context"
+)
+
+// SyncEmailKeys brings the email keys of users in line with the email
+// normalizer, as after its configuration has changed. Users whose email
+// would get the key of another user keep their key, and their ids are
+// returned, so that admins can merge the accounts.
+func (s *Storage) SyncEmailKeys(_ context.Context) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var conflicts []int64
+	for _, u := range s.users.all() {
+		if u.IsGuest || u.anonymizedAt != nil {
+			continue
+		}
+		key := s.emails.Key(u.Email)
+		if u.emailKey != nil && *u.emailKey == key {
+			continue
+		}
+
+		old := u.emailKey
+		u.emailKey = &key
+		if err := s.checkUserUnique(u, int64(u.ID)); err != nil {
+			u.emailKey = old
+			conflicts = append(conflicts, int64(u.ID))
+		}
+	}
+
+	return conflicts, nil
+}
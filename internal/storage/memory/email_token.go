@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveEmailChangeToken(_ context.Context, token models.EmailChangeToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.ExpiresAt = token.ExpiresAt.UTC()
+	token.CreatedAt = token.CreatedAt.UTC()
+	token.UsedAt = nil
+	token.ID = s.emailChangeTokens.insert(&token)
+
+	return nil
+}
+
+func (s *Storage) EmailChangeToken(_ context.Context, tokenHash string) (models.EmailChangeToken, error) {
+	const op = "storage.memory.EmailChangeToken"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, ok := s.emailChangeTokens.first(func(t *models.EmailChangeToken) bool { return t.TokenHash == tokenHash })
+	if !ok {
+		return models.EmailChangeToken{}, fmt.Errorf("%s: %w", op, storage.ErrEmailChangeTokenNotFound)
+	}
+
+	found := *token
+	found.UsedAt = utcPtr(token.UsedAt)
+
+	return found, nil
+}
+
+// UseEmailChangeToken marks the token as used. It fails with
+// storage.ErrEmailChangeTokenUsed if the token has already been used.
+func (s *Storage) UseEmailChangeToken(_ context.Context, id int64) error {
+	const op = "storage.memory.UseEmailChangeToken"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.emailChangeTokens.get(id)
+	if !ok || token.UsedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrEmailChangeTokenUsed)
+	}
+	token.UsedAt = ptr(time.Now().UTC())
+
+	return nil
+}
+
+func (s *Storage) SaveEmailVerificationToken(_ context.Context, token models.EmailVerificationToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.ExpiresAt = token.ExpiresAt.UTC()
+	token.CreatedAt = token.CreatedAt.UTC()
+	token.UsedAt = nil
+	token.ID = s.emailVerificationTokens.insert(&token)
+
+	return nil
+}
+
+func (s *Storage) EmailVerificationToken(_ context.Context, tokenHash string) (models.EmailVerificationToken, error) {
+	const op = "storage.memory.EmailVerificationToken"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, ok := s.emailVerificationTokens.first(func(t *models.EmailVerificationToken) bool { return t.TokenHash == tokenHash })
+	if !ok {
+		return models.EmailVerificationToken{}, fmt.Errorf("%s: %w", op, storage.ErrVerificationTokenNotFound)
+	}
+
+	found := *token
+	found.UsedAt = utcPtr(token.UsedAt)
+
+	return found, nil
+}
+
+// UseEmailVerificationToken marks the token as used. It fails with
+// storage.ErrVerificationTokenUsed if the token has already been used.
+func (s *Storage) UseEmailVerificationToken(_ context.Context, id int64) error {
+	const op = "storage.memory.UseEmailVerificationToken"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.emailVerificationTokens.get(id)
+	if !ok || token.UsedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrVerificationTokenUsed)
+	}
+	token.UsedAt = ptr(time.Now().UTC())
+
+	return nil
+}
+
+func (s *Storage) SavePasswordResetToken(_ context.Context, token models.PasswordResetToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.ExpiresAt = token.ExpiresAt.UTC()
+	token.CreatedAt = token.CreatedAt.UTC()
+	token.UsedAt = nil
+	token.ID = s.passwordResetTokens.insert(&token)
+
+	return nil
+}
+
+func (s *Storage) PasswordResetToken(_ context.Context, tokenHash string) (models.PasswordResetToken, error) {
+	const op = "storage.memory.PasswordResetToken"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, ok := s.passwordResetTokens.first(func(t *models.PasswordResetToken) bool { return t.TokenHash == tokenHash })
+	if !ok {
+		return models.PasswordResetToken{}, fmt.Errorf("%s: %w", op, storage.ErrPasswordResetTokenNotFound)
+	}
+
+	found := *token
+	found.UsedAt = utcPtr(token.UsedAt)
+
+	return found, nil
+}
+
+// UsePasswordResetToken marks the token as used. It fails with
+// storage.ErrPasswordResetTokenUsed if the token has already been used.
+func (s *Storage) UsePasswordResetToken(_ context.Context, id int64) error {
+	const op = "storage.memory.UsePasswordResetToken"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.passwordResetTokens.get(id)
+	if !ok || token.UsedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrPasswordResetTokenUsed)
+	}
+	token.UsedAt = ptr(time.Now().UTC())
+
+	return nil
+}
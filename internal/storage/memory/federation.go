@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+func (s *Storage) SaveFederationState(_ context.Context, state models.FederationState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state.Scopes = fields(state.Scopes)
+	state.ExpiresAt = state.ExpiresAt.UTC()
+	state.CreatedAt = state.CreatedAt.UTC()
+	state.UsedAt = nil
+	state.ID = s.federationStates.insert(&state)
+
+	return nil
+}
+
+func (s *Storage) FederationState(_ context.Context, stateHash string) (models.FederationState, error) {
+	const op = "storage.memory.FederationState"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.federationStates.first(func(f *models.FederationState) bool { return f.StateHash == stateHash })
+	if !ok {
+		return models.FederationState{}, fmt.Errorf("%s: %w", op, storage.ErrFederationStateNotFound)
+	}
+
+	found := *state
+	found.Scopes = slices.Clone(state.Scopes)
+	found.UsedAt = utcPtr(state.UsedAt)
+
+	return found, nil
+}
+
+// UseFederationState marks the login as returned from the provider. It fails
+// with storage.ErrFederationStateUsed if the state has already been used.
+func (s *Storage) UseFederationState(_ context.Context, id int64) error {
+	const op = "storage.memory.UseFederationState"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.federationStates.get(id)
+	if !ok || state.UsedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrFederationStateUsed)
+	}
+	state.UsedAt = ptr(time.Now().UTC())
+
+	return nil
+}
+
+// SaveUserIdentity links the user to the identity. It fails with
+// storage.ErrUserIdentityExists if the identity is linked already.
+func (s *Storage) SaveUserIdentity(_ context.Context, identity models.UserIdentity) error {
+	const op = "storage.memory.SaveUserIdentity"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.userIdentities.first(func(i *models.UserIdentity) bool {
+		return i.Provider == identity.Provider && i.Subject == identity.Subject
+	})
+	if exists {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserIdentityExists)
+	}
+
+	identity.CreatedAt = identity.CreatedAt.UTC()
+	identity.ID = s.userIdentities.insert(&identity)
+
+	return nil
+}
+
+func (s *Storage) UserIdentity(_ context.Context, provider string, subject string) (models.UserIdentity, error) {
+	const op = "storage.memory.UserIdentity"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	identity, ok := s.userIdentities.first(func(i *models.UserIdentity) bool {
+		return i.Provider == provider && i.Subject == subject
+	})
+	if !ok {
+		return models.UserIdentity{}, fmt.Errorf("%s: %w", op, storage.ErrUserIdentityNotFound)
+	}
+
+	return *identity, nil
+}
+
+// UserIdentities returns the identities linked to the user, oldest first.
+func (s *Storage) UserIdentities(_ context.Context, userID int64) ([]models.UserIdentity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var identities []models.UserIdentity
+	for _, identity := range s.userIdentities.where(func(i *models.UserIdentity) bool { return i.UserID == userID }) {
+		identities = append(identities, *identity)
+	}
+
+	return identities, nil
+}
+
+// SaveIdentityProvider creates the provider or replaces the provider with
+// the same name.
+func (s *Storage) SaveIdentityProvider(_ context.Context, provider models.IdentityProvider) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	provider.Scopes = fields(provider.Scopes)
+	provider.CreatedAt = provider.CreatedAt.UTC()
+
+	if old, ok := s.identityProviders.first(func(p *models.IdentityProvider) bool { return p.Name == provider.Name }); ok {
+		provider.ID = old.ID
+		provider.CreatedAt = old.CreatedAt
+		*old = provider
+		return nil
+	}
+	provider.ID = s.identityProviders.insert(&provider)
+
+	return nil
+}
+
+func (s *Storage) IdentityProvider(_ context.Context, name string) (models.IdentityProvider, error) {
+	const op = "storage.memory.IdentityProvider"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	provider, ok := s.identityProviders.first(func(p *models.IdentityProvider) bool { return p.Name == name })
+	if !ok {
+		return models.IdentityProvider{}, fmt.Errorf("%s: %w", op, storage.ErrIdentityProviderNotFound)
+	}
+
+	found := *provider
+	found.Scopes = slices.Clone(provider.Scopes)
+
+	return found, nil
+}
+
+func (s *Storage) IdentityProviders(_ context.Context) ([]models.IdentityProvider, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var providers []models.IdentityProvider
+	for _, provider := range s.identityProviders.rows {
+		found := *provider
+		found.Scopes = slices.Clone(provider.Scopes)
+		providers = append(providers, found)
+	}
+	slices.SortFunc(providers, func(a, b models.IdentityProvider) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return providers, nil
+}
+
+func (s *Storage) DeleteIdentityProvider(_ context.Context, name string) error {
+	const op = "storage.memory.DeleteIdentityProvider"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.identityProviders.delete(func(p *models.IdentityProvider) bool { return p.Name == name }) == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrIdentityProviderNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,287 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+// groupRow is a group with the ids of the roles it carries.
+type groupRow struct {
+	id          int64
+	name        string
+	description string
+	createdAt   time.Time
+	roles       map[int64]bool
+}
+
+type groupMemberKey struct {
+	groupID int64
+	userID  int64
+}
+
+// SaveGroup creates the group with its roles and returns its id. It fails
+// with storage.ErrGroupExists if a group has the name already, and with
+// storage.ErrRoleNotFound if a role does not exist.
+func (s *Storage) SaveGroup(_ context.Context, group models.Group) (int64, error) {
+	const op = "storage.memory.SaveGroup"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groupByName(group.Name); ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrGroupExists)
+	}
+
+	roles, err := s.roleIDs(group.Roles, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := &groupRow{
+		name:        group.Name,
+		description: group.Description,
+		createdAt:   group.CreatedAt.UTC(),
+		roles:       roles,
+	}
+	row.id = s.groups.insert(row)
+
+	return row.id, nil
+}
+
+// UpdateGroup sets the description and the roles of the group with the
+// name. It fails with storage.ErrGroupNotFound if there is no such group,
+// and with storage.ErrRoleNotFound if a role does not exist.
+func (s *Storage) UpdateGroup(_ context.Context, group models.Group) error {
+	const op = "storage.memory.UpdateGroup"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.groupByName(group.Name)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+
+	roles, err := s.roleIDs(group.Roles, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	row.description = group.Description
+	row.roles = roles
+
+	return nil
+}
+
+func (s *Storage) groupByName(name string) (*groupRow, bool) {
+	return s.groups.first(func(g *groupRow) bool { return g.name == name })
+}
+
+// Group returns the group with the name. It fails with
+// storage.ErrGroupNotFound if there is no such group.
+func (s *Storage) Group(_ context.Context, name string) (models.Group, error) {
+	const op = "storage.memory.Group"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row, ok := s.groupByName(name)
+	if !ok {
+		return models.Group{}, fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+
+	return s.group(row), nil
+}
+
+// Groups returns every group, ordered by name.
+func (s *Storage) Groups(_ context.Context) ([]models.Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sortedGroups(s.groups.all()), nil
+}
+
+// DeleteGroup deletes the group with the name, whose members lose the roles
+// they had through it. It fails with storage.ErrGroupNotFound if there is no
+// such group.
+func (s *Storage) DeleteGroup(_ context.Context, name string) error {
+	const op = "storage.memory.DeleteGroup"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.groupByName(name)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+	delete(s.groups.rows, row.id)
+
+	for key := range s.groupMembers {
+		if key.groupID == row.id {
+			delete(s.groupMembers, key)
+		}
+	}
+
+	return nil
+}
+
+// AssignGroupRole gives the group with the name the role on behalf of the
+// admin, unless the group carries it already. It fails with
+// storage.ErrGroupNotFound or storage.ErrRoleNotFound if there is no such
+// group or role.
+func (s *Storage) AssignGroupRole(_ context.Context, group string, role string, adminID int64, at time.Time) error {
+	const op = "storage.memory.AssignGroupRole"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.groupByName(group)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+	r, ok := s.roleByName(role)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+	}
+	if row.roles[r.id] {
+		return nil
+	}
+	row.roles[r.id] = true
+
+	s.saveAdminEvent(models.AuditGroupRoleAssigned, 0, 0, adminID, group+"/"+role, at)
+
+	return nil
+}
+
+// RevokeGroupRole takes the role from the group with the name on behalf of
+// the admin, if the group carries it. It fails with storage.ErrGroupNotFound
+// if there is no such group.
+func (s *Storage) RevokeGroupRole(_ context.Context, group string, role string, adminID int64, at time.Time) error {
+	const op = "storage.memory.RevokeGroupRole"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.groupByName(group)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+	r, ok := s.roleByName(role)
+	if !ok || !row.roles[r.id] {
+		return nil
+	}
+	delete(row.roles, r.id)
+
+	s.saveAdminEvent(models.AuditGroupRoleRevoked, 0, 0, adminID, group+"/"+role, at)
+
+	return nil
+}
+
+// AddGroupMember adds the user to the group with the name, unless the user
+// is a member already. It fails with storage.ErrGroupNotFound or
+// storage.ErrUserNotFound if there is no such group or user.
+func (s *Storage) AddGroupMember(_ context.Context, group string, userID int64, at time.Time) error {
+	const op = "storage.memory.AddGroupMember"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.groupByName(group)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+	if _, ok = s.activeUser(userID); !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	key := groupMemberKey{row.id, userID}
+	if _, ok = s.groupMembers[key]; !ok {
+		s.groupMembers[key] = at.UTC()
+	}
+
+	return nil
+}
+
+// RemoveGroupMember removes the user from the group with the name, if the
+// user is a member. It fails with storage.ErrGroupNotFound if there is no
+// such group.
+func (s *Storage) RemoveGroupMember(_ context.Context, group string, userID int64) error {
+	const op = "storage.memory.RemoveGroupMember"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.groupByName(group)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+	delete(s.groupMembers, groupMemberKey{row.id, userID})
+
+	return nil
+}
+
+// GroupMembers returns the ids of the members of the group with the name,
+// ascending. It fails with storage.ErrGroupNotFound if there is no such
+// group.
+func (s *Storage) GroupMembers(_ context.Context, group string) ([]int64, error) {
+	const op = "storage.memory.GroupMembers"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row, ok := s.groupByName(group)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+
+	var ids []int64
+	for key := range s.groupMembers {
+		if key.groupID == row.id {
+			ids = append(ids, key.userID)
+		}
+	}
+	slices.Sort(ids)
+
+	return ids, nil
+}
+
+// UserGroups returns the groups of the user, ordered by name.
+func (s *Storage) UserGroups(_ context.Context, userID int64) ([]models.Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows := s.groups.where(func(g *groupRow) bool {
+		_, ok := s.groupMembers[groupMemberKey{g.id, userID}]
+		return ok
+	})
+
+	return s.sortedGroups(rows), nil
+}
+
+// sortedGroups returns the groups ordered by name.
+func (s *Storage) sortedGroups(rows []*groupRow) []models.Group {
+	var groups []models.Group
+	for _, row := range rows {
+		groups = append(groups, s.group(row))
+	}
+	slices.SortFunc(groups, func(a, b models.Group) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return groups
+}
+
+func (s *Storage) group(row *groupRow) models.Group {
+	return models.Group{
+		ID:          row.id,
+		Name:        row.name,
+		Description: row.description,
+		Roles:       s.roleNames(row.roles),
+		CreatedAt:   row.createdAt,
+	}
+}
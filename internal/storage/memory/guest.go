@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+// SaveGuest creates an anonymous user with a random placeholder email and
+// no password.
+func (s *Storage) SaveGuest(_ context.Context) (int64, error) {
+	const op = "storage.memory.SaveGuest"
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return 0, fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := s.insertUser(&userRow{User: models.User{
+		Email:   "guest-" + hex.EncodeToString(b) + "@guest.invalid",
+		IsGuest: true,
+	}})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return id, nil
+}
+
+// UpgradeGuest turns the guest into a full user with the email and the
+// password, keeping the id. It fails with storage.ErrUserExists if the email
+// is taken and with storage.ErrUserNotFound if there is no such guest.
+func (s *Storage) UpgradeGuest(_ context.Context, userID int64, email string, passHash []byte) error {
+	const op = "storage.memory.UpgradeGuest"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok || !u.IsGuest {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	key := s.emails.Key(email)
+	upgraded := *u
+	upgraded.Email = email
+	upgraded.emailKey = &key
+	if err := s.checkUserUnique(&upgraded, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+	}
+
+	u.Email = email
+	u.emailKey = &key
+	u.PassHash = string(passHash)
+	u.EmailVerified = false
+	u.IsGuest = false
+	u.touch()
+
+	return nil
+}
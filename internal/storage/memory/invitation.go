@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveInvitation(_ context.Context, invitation models.Invitation) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invitation.ExpiresAt = invitation.ExpiresAt.UTC()
+	invitation.CreatedAt = invitation.CreatedAt.UTC()
+	invitation.AcceptedAt = nil
+	invitation.UserID = 0
+	invitation.ID = s.invitations.insert(&invitation)
+
+	return invitation.ID, nil
+}
+
+// Invitation returns the invitation of the token, accepted or not.
+func (s *Storage) Invitation(_ context.Context, tokenHash string) (models.Invitation, error) {
+	const op = "storage.memory.Invitation"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	invitation, ok := s.invitations.first(func(i *models.Invitation) bool { return i.TokenHash == tokenHash })
+	if !ok {
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+	}
+
+	found := *invitation
+	found.AcceptedAt = utcPtr(invitation.AcceptedAt)
+
+	return found, nil
+}
+
+// AcceptInvitation marks the invitation as accepted and registers the
+// invited user with a verified email in one transaction. It fails with
+// storage.ErrInvitationUsed if the invitation has already been accepted and
+// with storage.ErrUserExists if the email is taken.
+func (s *Storage) AcceptInvitation(_ context.Context, id int64, passHash []byte, at time.Time) (int64, error) {
+	const op = "storage.memory.AcceptInvitation"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invitation, ok := s.invitations.get(id)
+	if !ok || invitation.AcceptedAt != nil {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrInvitationUsed)
+	}
+
+	key := s.emails.Key(invitation.Email)
+	userID, err := s.insertUser(&userRow{
+		User: models.User{
+			Email:         invitation.Email,
+			PassHash:      string(passHash),
+			EmailVerified: true,
+			IsAdmin:       invitation.IsAdmin,
+		},
+		emailKey: &key,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	invitation.AcceptedAt = ptr(at.UTC())
+	invitation.UserID = userID
+
+	return userID, nil
+}
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/storage"
+	"time"
+)
+
+// RecordFailedLogin counts a failed login of the user and returns the number
+// of failed logins since the first one after windowStart. Failures before
+// windowStart are forgotten.
+func (s *Storage) RecordFailedLogin(_ context.Context, userID int64, at time.Time, windowStart time.Time) (int, error) {
+	const op = "storage.memory.RecordFailedLogin"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	if u.failedLoginsSince == nil || u.failedLoginsSince.Before(windowStart) {
+		u.FailedLogins = 1
+		u.failedLoginsSince = ptr(at.UTC())
+	} else {
+		u.FailedLogins++
+	}
+
+	return u.FailedLogins, nil
+}
+
+// LockUser locks the user out until the given time and clears the failed
+// logins.
+func (s *Storage) LockUser(_ context.Context, userID int64, until time.Time) error {
+	const op = "storage.memory.LockUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.LockedUntil = ptr(until.UTC())
+	u.FailedLogins = 0
+	u.failedLoginsSince = nil
+
+	return nil
+}
+
+// UnlockUser lifts the lockout of the user and clears the failed logins.
+func (s *Storage) UnlockUser(_ context.Context, userID int64) error {
+	const op = "storage.memory.UnlockUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.LockedUntil = nil
+	u.FailedLogins = 0
+	u.failedLoginsSince = nil
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sso/internal/domain/models"
+)
+
+// RecordLogin sets the login as the last one of the user and adds it to the
+// login history, which keeps the latest keep logins of the user.
+func (s *Storage) RecordLogin(_ context.Context, login models.Login, keep int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	login.CreatedAt = login.CreatedAt.UTC()
+	login.Methods = fields(login.Methods)
+
+	if u, ok := s.users.get(login.UserID); ok {
+		u.LastLoginAt = ptr(login.CreatedAt)
+		u.LastLoginIP = login.IP
+		u.LastLoginUserAgent = login.UserAgent
+	}
+
+	login.ID = s.loginHistory.insert(&login)
+
+	if keep >= 0 {
+		logins := s.loginHistory.where(func(l *models.Login) bool { return l.UserID == login.UserID })
+		for _, old := range logins[:max(len(logins)-keep, 0)] {
+			delete(s.loginHistory.rows, old.ID)
+		}
+	}
+
+	return nil
+}
+
+// LoginHistory returns the latest logins of the user, the most recent
+// first.
+func (s *Storage) LoginHistory(_ context.Context, userID int64, limit int) ([]models.Login, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var logins []models.Login
+	rows := s.loginHistory.where(func(l *models.Login) bool { return l.UserID == userID })
+	slices.Reverse(rows)
+	for _, login := range rows {
+		if limit >= 0 && len(logins) == limit {
+			break
+		}
+		found := *login
+		found.Methods = slices.Clone(login.Methods)
+		logins = append(logins, found)
+	}
+
+	return logins, nil
+}
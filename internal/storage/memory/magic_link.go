@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveMagicLink(_ context.Context, link models.MagicLink) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link.Scopes = fields(link.Scopes)
+	link.ExpiresAt = link.ExpiresAt.UTC()
+	link.CreatedAt = link.CreatedAt.UTC()
+	link.UsedAt = nil
+	link.ID = s.magicLinks.insert(&link)
+
+	return nil
+}
+
+func (s *Storage) MagicLink(_ context.Context, tokenHash string) (models.MagicLink, error) {
+	const op = "storage.memory.MagicLink"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, ok := s.magicLinks.first(func(l *models.MagicLink) bool { return l.TokenHash == tokenHash })
+	if !ok {
+		return models.MagicLink{}, fmt.Errorf("%s: %w", op, storage.ErrMagicLinkNotFound)
+	}
+
+	found := *link
+	found.Scopes = slices.Clone(link.Scopes)
+	found.UsedAt = utcPtr(link.UsedAt)
+
+	return found, nil
+}
+
+// UseMagicLink marks the link as redeemed. It fails with
+// storage.ErrMagicLinkUsed if the link has already been redeemed.
+func (s *Storage) UseMagicLink(_ context.Context, id int64) error {
+	const op = "storage.memory.UseMagicLink"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.magicLinks.get(id)
+	if !ok || link.UsedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrMagicLinkUsed)
+	}
+	link.UsedAt = ptr(time.Now().UTC())
+
+	return nil
+}
+
+// CountMagicLinks returns the number of links issued to the user since the
+// given time.
+func (s *Storage) CountMagicLinks(_ context.Context, userID int64, since time.Time) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, link := range s.magicLinks.rows {
+		if link.UserID == userID && !link.CreatedAt.Before(since) {
+			count++
+		}
+	}
+
+	return count, nil
+}
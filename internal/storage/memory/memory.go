@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sso/internal/domain/models"
+	"sync"
+	"time"
+)
+
+// EmailNormalizer derives the keys the emails of users are told apart by.
+type EmailNormalizer interface {
+	Key(email string) string
+}
+
+// Storage keeps data in process memory. It is not shared between instances
+// and is meant for local runs and tests. Besides the denylist, the login
+// attempts and the quotas, it keeps everything the databases do, with the
+// same errors, so that the service runs without one.
+type Storage struct {
+	mu       sync.RWMutex
+	denylist map[string]time.Time
+	attempts map[string][]time.Time
+	usage    map[string]usageCounter
+
+	emails EmailNormalizer
+
+	users                   *table[userRow]
+	apps                    *table[appRow]
+	orgs                    *table[models.Org]
+	claimMappings           map[int]map[string]string
+	refreshTokens           *table[models.RefreshToken]
+	tokenIssuances          *table[models.TokenIssuance]
+	sessions                map[string]*sessionRow
+	userSessions            *table[models.UserSession]
+	trustedDevices          *table[models.TrustedDevice]
+	deviceAuthorizations    *table[models.DeviceAuthorization]
+	passwordResetTokens     *table[models.PasswordResetToken]
+	emailVerificationTokens *table[models.EmailVerificationToken]
+	emailChangeTokens       *table[models.EmailChangeToken]
+	magicLinks              *table[models.MagicLink]
+	smsCodes                *table[models.SMSCode]
+	totp                    map[int64]*models.TOTP
+	mfaChallenges           *table[models.MFAChallenge]
+	recoveryCodes           *table[recoveryCodeRow]
+	webauthnCredentials     *table[models.WebAuthnCredential]
+	webauthnSessions        *table[models.WebAuthnSession]
+	federationStates        *table[models.FederationState]
+	userIdentities          *table[models.UserIdentity]
+	identityProviders       *table[models.IdentityProvider]
+	invitations             *table[models.Invitation]
+	orgInvitations          *table[models.OrgInvitation]
+	consents                map[consentKey]*models.Consent
+	loginHistory            *table[models.Login]
+	notificationPreferences map[int64]models.NotificationPreferences
+	roles                   *table[roleRow]
+	userRoles               map[userRoleKey]time.Time
+	groups                  *table[groupRow]
+	groupMembers            map[groupMemberKey]time.Time
+	policyRules             *table[models.PolicyRule]
+	apiKeys                 *table[models.APIKey]
+	auditEvents             *table[models.AuditEvent]
+	outboxEvents            *table[outboxRow]
+}
+
+// New returns an empty storage with the default org and the admin role, as
+// the migrations leave a new database. Emails are told apart by the keys of the
+// normalizer.
+func New(emails EmailNormalizer) *Storage {
+	s := &Storage{
+		denylist: make(map[string]time.Time),
+		attempts: make(map[string][]time.Time),
+		usage:    make(map[string]usageCounter),
+
+		emails: emails,
+
+		users:                   newTable[userRow](),
+		apps:                    newTable[appRow](),
+		orgs:                    newTable[models.Org](),
+		claimMappings:           make(map[int]map[string]string),
+		refreshTokens:           newTable[models.RefreshToken](),
+		tokenIssuances:          newTable[models.TokenIssuance](),
+		sessions:                make(map[string]*sessionRow),
+		userSessions:            newTable[models.UserSession](),
+		trustedDevices:          newTable[models.TrustedDevice](),
+		deviceAuthorizations:    newTable[models.DeviceAuthorization](),
+		passwordResetTokens:     newTable[models.PasswordResetToken](),
+		emailVerificationTokens: newTable[models.EmailVerificationToken](),
+		emailChangeTokens:       newTable[models.EmailChangeToken](),
+		magicLinks:              newTable[models.MagicLink](),
+		smsCodes:                newTable[models.SMSCode](),
+		totp:                    make(map[int64]*models.TOTP),
+		mfaChallenges:           newTable[models.MFAChallenge](),
+		recoveryCodes:           newTable[recoveryCodeRow](),
+		webauthnCredentials:     newTable[models.WebAuthnCredential](),
+		webauthnSessions:        newTable[models.WebAuthnSession](),
+		federationStates:        newTable[models.FederationState](),
+		userIdentities:          newTable[models.UserIdentity](),
+		identityProviders:       newTable[models.IdentityProvider](),
+		invitations:             newTable[models.Invitation](),
+		orgInvitations:          newTable[models.OrgInvitation](),
+		consents:                make(map[consentKey]*models.Consent),
+		loginHistory:            newTable[models.Login](),
+		notificationPreferences: make(map[int64]models.NotificationPreferences),
+		roles:                   newTable[roleRow](),
+		userRoles:               make(map[userRoleKey]time.Time),
+		groups:                  newTable[groupRow](),
+		groupMembers:            make(map[groupMemberKey]time.Time),
+		policyRules:             newTable[models.PolicyRule](),
+		apiKeys:                 newTable[models.APIKey](),
+		auditEvents:             newTable[models.AuditEvent](),
+		outboxEvents:            newTable[outboxRow](),
+	}
+
+	s.orgs.insertWithID(models.DefaultOrgID, &models.Org{
+		ID:        models.DefaultOrgID,
+		Name:      "default",
+		CreatedAt: now(),
+	})
+	s.roles.insert(&roleRow{
+		id:          1,
+		name:        adminRole,
+		description: "Administers the app it is assigned for",
+		createdAt:   now(),
+		permissions: map[string]string{},
+		inherits:    map[int64]bool{},
+	})
+
+	return s
+}
+
+// Stats returns no connection pool statistics, there is no pool.
+func (s *Storage) Stats() sql.DBStats {
+	return sql.DBStats{}
+}
+
+// ReplicaStats returns nothing, the storage has no replicas.
+func (s *Storage) ReplicaStats() []sql.DBStats {
+	return nil
+}
+
+// Ping does nothing, process memory is always reachable.
+func (s *Storage) Ping(context.Context) error {
+	return nil
+}
+
+// Close does nothing, the data is gone with the process.
+func (s *Storage) Close() error {
+	return nil
+}
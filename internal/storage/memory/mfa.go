@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// SaveTOTP starts a TOTP enrollment, replacing an unconfirmed one. It fails
+// with storage.ErrTOTPExists if the user has already confirmed TOTP.
+func (s *Storage) SaveTOTP(_ context.Context, totp models.TOTP) error {
+	const op = "storage.memory.SaveTOTP"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.totp[totp.UserID]; ok && old.ConfirmedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrTOTPExists)
+	}
+
+	s.totp[totp.UserID] = &models.TOTP{
+		UserID:    totp.UserID,
+		Secret:    totp.Secret,
+		CreatedAt: totp.CreatedAt.UTC(),
+	}
+
+	return nil
+}
+
+func (s *Storage) TOTP(_ context.Context, userID int64) (models.TOTP, error) {
+	const op = "storage.memory.TOTP"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	totp, ok := s.totp[userID]
+	if !ok {
+		return models.TOTP{}, fmt.Errorf("%s: %w", op, storage.ErrTOTPNotFound)
+	}
+
+	found := *totp
+	found.ConfirmedAt = utcPtr(totp.ConfirmedAt)
+
+	return found, nil
+}
+
+// ConfirmTOTP enables TOTP for the user with the code of the step. It fails
+// with storage.ErrTOTPNotFound if there is no unconfirmed enrollment.
+func (s *Storage) ConfirmTOTP(_ context.Context, userID int64, step int64) error {
+	const op = "storage.memory.ConfirmTOTP"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totp, ok := s.totp[userID]
+	if !ok || totp.ConfirmedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrTOTPNotFound)
+	}
+	totp.ConfirmedAt = ptr(time.Now().UTC())
+	totp.LastUsedStep = step
+
+	return nil
+}
+
+// UseTOTPStep records that the code of the step was used. It fails with
+// storage.ErrTOTPCodeUsed if a code of the same or a later step was used.
+func (s *Storage) UseTOTPStep(_ context.Context, userID int64, step int64) error {
+	const op = "storage.memory.UseTOTPStep"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totp, ok := s.totp[userID]
+	if !ok || totp.LastUsedStep >= step {
+		return fmt.Errorf("%s: %w", op, storage.ErrTOTPCodeUsed)
+	}
+	totp.LastUsedStep = step
+
+	return nil
+}
+
+func (s *Storage) DeleteTOTP(_ context.Context, userID int64) error {
+	const op = "storage.memory.DeleteTOTP"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.totp[userID]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrTOTPNotFound)
+	}
+	delete(s.totp, userID)
+
+	return nil
+}
+
+func (s *Storage) SaveMFAChallenge(_ context.Context, challenge models.MFAChallenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge.Scopes = fields(challenge.Scopes)
+	challenge.AuthMethods = fields(challenge.AuthMethods)
+	challenge.Attempts = 0
+	challenge.ExpiresAt = challenge.ExpiresAt.UTC()
+	challenge.CreatedAt = challenge.CreatedAt.UTC()
+	challenge.UsedAt = nil
+	challenge.ID = s.mfaChallenges.insert(&challenge)
+
+	return nil
+}
+
+func (s *Storage) MFAChallenge(_ context.Context, tokenHash string) (models.MFAChallenge, error) {
+	const op = "storage.memory.MFAChallenge"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	challenge, ok := s.mfaChallenges.first(func(c *models.MFAChallenge) bool { return c.TokenHash == tokenHash })
+	if !ok {
+		return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrMFAChallengeNotFound)
+	}
+
+	found := *challenge
+	found.Scopes = slices.Clone(challenge.Scopes)
+	found.AuthMethods = slices.Clone(challenge.AuthMethods)
+	found.UsedAt = utcPtr(challenge.UsedAt)
+
+	return found, nil
+}
+
+// FailMFAChallenge counts a wrong code entered for the challenge.
+func (s *Storage) FailMFAChallenge(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if challenge, ok := s.mfaChallenges.get(id); ok {
+		challenge.Attempts++
+	}
+
+	return nil
+}
+
+// UseMFAChallenge marks the challenge as completed. It fails with
+// storage.ErrMFAChallengeUsed if the challenge has already been completed.
+func (s *Storage) UseMFAChallenge(_ context.Context, id int64) error {
+	const op = "storage.memory.UseMFAChallenge"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.mfaChallenges.get(id)
+	if !ok || challenge.UsedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrMFAChallengeUsed)
+	}
+	challenge.UsedAt = ptr(time.Now().UTC())
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+// NotificationPreferences returns the notification preferences of the user.
+// It fails with storage.ErrNotificationPreferencesNotFound if the user has
+// not set them.
+func (s *Storage) NotificationPreferences(_ context.Context, userID int64) (models.NotificationPreferences, error) {
+	const op = "storage.memory.NotificationPreferences"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefs, ok := s.notificationPreferences[userID]
+	if !ok {
+		return models.NotificationPreferences{}, fmt.Errorf("%s: %w", op, storage.ErrNotificationPreferencesNotFound)
+	}
+
+	return prefs, nil
+}
+
+// SaveNotificationPreferences stores the notification preferences of the
+// user, replacing the previous ones.
+func (s *Storage) SaveNotificationPreferences(_ context.Context, prefs models.NotificationPreferences) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs.UpdatedAt = prefs.UpdatedAt.UTC()
+	s.notificationPreferences[prefs.UserID] = prefs
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strconv"
+	"time"
+)
+
+// SaveOrg creates the org on behalf of the admin and returns its new id. It
+// fails with storage.ErrOrgExists if an org has the name already.
+func (s *Storage) SaveOrg(_ context.Context, org models.Org, adminID int64) (int64, error) {
+	const op = "storage.memory.SaveOrg"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orgs.first(func(o *models.Org) bool { return o.Name == org.Name }); ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrOrgExists)
+	}
+
+	org.CreatedAt = org.CreatedAt.UTC()
+	org.ID = s.orgs.insert(&org)
+
+	s.saveAdminEvent(models.AuditOrgCreated, 0, 0, adminID, org.Name, org.CreatedAt)
+
+	return org.ID, nil
+}
+
+// Orgs returns every org ordered by id.
+func (s *Storage) Orgs(_ context.Context) ([]models.Org, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var orgs []models.Org
+	for _, org := range s.orgs.all() {
+		orgs = append(orgs, *org)
+	}
+
+	return orgs, nil
+}
+
+// Org returns the org with the id. It fails with storage.ErrOrgNotFound if
+// there is no such org.
+func (s *Storage) Org(_ context.Context, orgID int64) (models.Org, error) {
+	const op = "storage.memory.Org"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	org, ok := s.orgs.get(orgID)
+	if !ok {
+		return models.Org{}, fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+	}
+
+	return *org, nil
+}
+
+// SetUserOrg moves the user to the org on behalf of the admin. Tokens of the
+// user are revoked, as they name the org the user belonged to. It fails with
+// storage.ErrUserNotFound if there is no such user.
+func (s *Storage) SetUserOrg(_ context.Context, userID int64, orgID int64, adminID int64, at time.Time) error {
+	const op = "storage.memory.SetUserOrg"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	if u.OrgID == orgID {
+		// already in the org
+		return nil
+	}
+	u.OrgID = orgID
+	u.TokenVersion++
+	u.touch()
+
+	s.saveAdminEvent(models.AuditUserOrgChanged, userID, 0, adminID, strconv.FormatInt(orgID, 10), at)
+
+	return nil
+}
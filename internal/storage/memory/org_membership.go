@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strconv"
+	"time"
+)
+
+// SaveOrgInvitation saves the invitation and returns its id.
+func (s *Storage) SaveOrgInvitation(_ context.Context, invitation models.OrgInvitation) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if invitation.Role != models.OrgRoleAdmin {
+		invitation.Role = models.OrgRoleMember
+	}
+	invitation.ExpiresAt = invitation.ExpiresAt.UTC()
+	invitation.CreatedAt = invitation.CreatedAt.UTC()
+	invitation.AcceptedAt = nil
+	invitation.DeclinedAt = nil
+	invitation.UserID = 0
+	invitation.ID = s.orgInvitations.insert(&invitation)
+
+	s.saveAdminEvent(models.AuditOrgInvitationCreated, 0, 0, invitation.InvitedBy,
+		strconv.FormatInt(invitation.OrgID, 10), invitation.CreatedAt)
+
+	return invitation.ID, nil
+}
+
+// OrgInvitation returns the invitation of the token, answered or not.
+func (s *Storage) OrgInvitation(_ context.Context, tokenHash string) (models.OrgInvitation, error) {
+	const op = "storage.memory.OrgInvitation"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	invitation, ok := s.orgInvitations.first(func(i *models.OrgInvitation) bool { return i.TokenHash == tokenHash })
+	if !ok {
+		return models.OrgInvitation{}, fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+	}
+
+	found := *invitation
+	found.AcceptedAt = utcPtr(invitation.AcceptedAt)
+	found.DeclinedAt = utcPtr(invitation.DeclinedAt)
+
+	return found, nil
+}
+
+// AcceptOrgInvitation marks the invitation as accepted by the user and moves
+// the user to its org with its role in one transaction. Tokens of the user
+// are revoked, as they name the org the user belonged to. It fails with
+// storage.ErrInvitationUsed if the invitation has already been answered,
+// and with storage.ErrUserNotFound if there is no such user.
+func (s *Storage) AcceptOrgInvitation(_ context.Context, id int64, userID int64, at time.Time) error {
+	const op = "storage.memory.AcceptOrgInvitation"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invitation, ok := s.orgInvitations.get(id)
+	if !ok || invitation.AcceptedAt != nil || invitation.DeclinedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrInvitationUsed)
+	}
+	u, ok := s.activeUser(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	invitation.AcceptedAt = ptr(at.UTC())
+	invitation.UserID = userID
+
+	u.OrgID = invitation.OrgID
+	u.IsAdmin = invitation.Role == models.OrgRoleAdmin
+	u.TokenVersion++
+	u.touch()
+
+	s.saveAdminEvent(models.AuditOrgInvitationAccepted, userID, 0, userID,
+		strconv.FormatInt(invitation.OrgID, 10), at)
+
+	return nil
+}
+
+// DeclineOrgInvitation marks the invitation as declined. It fails with
+// storage.ErrInvitationUsed if the invitation has already been answered.
+func (s *Storage) DeclineOrgInvitation(_ context.Context, id int64, at time.Time) error {
+	const op = "storage.memory.DeclineOrgInvitation"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invitation, ok := s.orgInvitations.get(id)
+	if !ok || invitation.AcceptedAt != nil || invitation.DeclinedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrInvitationUsed)
+	}
+	invitation.DeclinedAt = ptr(at.UTC())
+
+	s.saveAdminEvent(models.AuditOrgInvitationDeclined, 0, 0, 0, strconv.FormatInt(invitation.OrgID, 10), at)
+
+	return nil
+}
+
+// SetOrgMemberRole gives the member of the org the role on behalf of the
+// admin. Tokens of the member are revoked, so that they do not outlive an
+// admin role. Giving members the role they have does nothing. It fails with
+// storage.ErrUserNotFound if the user is not a member of the org.
+func (s *Storage) SetOrgMemberRole(
+	_ context.Context,
+	orgID int64,
+	userID int64,
+	role string,
+	adminID int64,
+	at time.Time,
+) error {
+	const op = "storage.memory.SetOrgMemberRole"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.activeUser(userID)
+	if !ok || u.OrgID != orgID {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	isAdmin := role == models.OrgRoleAdmin
+	if u.IsAdmin == isAdmin {
+		return nil
+	}
+	u.IsAdmin = isAdmin
+	u.TokenVersion++
+	u.touch()
+
+	s.saveAdminEvent(models.AuditOrgMemberRoleChanged, userID, 0, adminID, role, at)
+
+	return nil
+}
+
+// RemoveOrgMember moves the member of the org back to the default org as a
+// regular user on behalf of the admin, revoking the tokens of the member.
+// It fails with storage.ErrUserNotFound if the user is not a member of the
+// org.
+func (s *Storage) RemoveOrgMember(_ context.Context, orgID int64, userID int64, adminID int64, at time.Time) error {
+	const op = "storage.memory.RemoveOrgMember"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.activeUser(userID)
+	if !ok || u.OrgID != orgID {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.OrgID = models.DefaultOrgID
+	u.IsAdmin = false
+	u.TokenVersion++
+	u.touch()
+
+	s.saveAdminEvent(models.AuditOrgMemberRemoved, userID, 0, adminID, strconv.FormatInt(orgID, 10), at)
+
+	return nil
+}
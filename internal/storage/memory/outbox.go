@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"sso/internal/domain/models"
+	"time"
+)
+
+// outboxRow is an event of the outbox with its delivery state.
+type outboxRow struct {
+	models.OutboxEvent
+	deliveredAt *time.Time
+	lastError   string
+}
+
+// recordOutboxEvent records the event with the change it is about, as the
+// outbox triggers of the databases do.
+func (s *Storage) recordOutboxEvent(eventType string, payload any) {
+	// the payloads are plain structs, which always encode
+	data, _ := json.Marshal(payload)
+
+	row := &outboxRow{OutboxEvent: models.OutboxEvent{
+		Type:      eventType,
+		Payload:   data,
+		CreatedAt: time.Now().UTC(),
+	}}
+	row.ID = s.outboxEvents.insert(row)
+}
+
+// PendingOutboxEvents returns up to limit events which are yet to be
+// delivered, the oldest first.
+func (s *Storage) PendingOutboxEvents(_ context.Context, limit int) ([]models.OutboxEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []models.OutboxEvent
+	for _, row := range s.outboxEvents.all() {
+		if len(events) == limit {
+			break
+		}
+		if row.deliveredAt == nil {
+			events = append(events, row.OutboxEvent)
+		}
+	}
+
+	return events, nil
+}
+
+// MarkOutboxEventDelivered records that the event was published at the
+// time.
+func (s *Storage) MarkOutboxEventDelivered(_ context.Context, id int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if row, ok := s.outboxEvents.get(id); ok {
+		row.deliveredAt = ptr(at.UTC())
+		row.lastError = ""
+	}
+
+	return nil
+}
+
+// FailOutboxEvent records a failed attempt to publish the event, which
+// stays pending.
+func (s *Storage) FailOutboxEvent(_ context.Context, id int64, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if row, ok := s.outboxEvents.get(id); ok {
+		row.Attempts++
+		row.lastError = reason
+	}
+
+	return nil
+}
+
+// DeleteDeliveredOutboxEvents deletes the events delivered before the time
+// and returns how many it deleted.
+func (s *Storage) DeleteDeliveredOutboxEvents(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := s.outboxEvents.delete(func(row *outboxRow) bool {
+		return row.deliveredAt != nil && row.deliveredAt.Before(before)
+	})
+
+	return deleted, nil
+}
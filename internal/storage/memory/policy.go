@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+// maxPolicyValues is how many values the databases keep per rule.
+const maxPolicyValues = 6
+
+// SavePolicyRule saves the rule and returns its id. Rules have at most
+// maxPolicyValues values.
+func (s *Storage) SavePolicyRule(_ context.Context, rule models.PolicyRule) (int64, error) {
+	const op = "storage.memory.SavePolicyRule"
+
+	if len(rule.Values) > maxPolicyValues {
+		return 0, fmt.Errorf("%s: rule has %d values, at most %d are kept", op, len(rule.Values), maxPolicyValues)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values := slices.Clone(rule.Values)
+	for len(values) > 0 && values[len(values)-1] == "" {
+		values = values[:len(values)-1]
+	}
+	rule.Values = values
+	rule.CreatedAt = rule.CreatedAt.UTC()
+	rule.ID = s.policyRules.insert(&rule)
+
+	return rule.ID, nil
+}
+
+// PolicyRules returns every rule in the order they were saved. Trailing
+// empty values are left out.
+func (s *Storage) PolicyRules(_ context.Context) ([]models.PolicyRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rules []models.PolicyRule
+	for _, rule := range s.policyRules.all() {
+		found := *rule
+		found.Values = append([]string{}, rule.Values...)
+		rules = append(rules, found)
+	}
+
+	return rules, nil
+}
+
+// DeletePolicyRule deletes the rule with the id. It fails with
+// storage.ErrPolicyRuleNotFound if there is no such rule.
+func (s *Storage) DeletePolicyRule(_ context.Context, id int64) error {
+	const op = "storage.memory.DeletePolicyRule"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.policyRules.get(id); !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrPolicyRuleNotFound)
+	}
+	delete(s.policyRules.rows, id)
+
+	return nil
+}
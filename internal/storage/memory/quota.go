@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+type usageCounter struct {
+	count     int
+	expiresAt time.Time
+}
+
+// IncrementUsage adds one to the usage counted for the key and returns the
+// new count. The counter is forgotten at expiresAt, the end of its window.
+func (s *Storage) IncrementUsage(_ context.Context, key string, expiresAt time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, counter := range s.usage {
+		if !now.Before(counter.expiresAt) {
+			delete(s.usage, k)
+		}
+	}
+
+	counter := s.usage[key]
+	counter.count++
+	counter.expiresAt = expiresAt
+	s.usage[key] = counter
+
+	return counter.count, nil
+}
+
+// Usage returns the usage counted for the key, zero if nothing was.
+func (s *Storage) Usage(_ context.Context, key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counter, ok := s.usage[key]
+	if !ok || !time.Now().Before(counter.expiresAt) {
+		return 0, nil
+	}
+
+	return counter.count, nil
+}
@@ -0,0 +1,422 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+// roleRow is a role with the conditions of its permissions by name, an
+// empty one for unconditional permissions, and the ids of the roles it
+// inherits.
+type roleRow struct {
+	id          int64
+	name        string
+	description string
+	createdAt   time.Time
+	permissions map[string]string
+	inherits    map[int64]bool
+}
+
+// userRoleKey is an assignment of a role to a user within an app, or
+// globally if appID is zero.
+type userRoleKey struct {
+	userID int64
+	roleID int64
+	appID  int
+}
+
+// SaveRole creates the role with its permissions and inherited roles and
+// returns its id. It fails with storage.ErrRoleExists if a role has the name
+// already, and with storage.ErrRoleNotFound if an inherited role does not
+// exist.
+func (s *Storage) SaveRole(_ context.Context, role models.Role) (int64, error) {
+	const op = "storage.memory.SaveRole"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.roleByName(role.Name); ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrRoleExists)
+	}
+
+	row := &roleRow{
+		name:        role.Name,
+		description: role.Description,
+		createdAt:   role.CreatedAt.UTC(),
+	}
+	row.id = s.roles.lastID + 1
+
+	// the role is there for the inherited ones to be looked up among, as in
+	// the transaction of the databases
+	inherits, err := s.roleIDs(role.Inherits, row)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row.permissions = rolePermissions(role.Permissions, role.Conditions)
+	row.inherits = inherits
+	s.roles.insert(row)
+
+	return row.id, nil
+}
+
+// UpdateRole sets the description, the permissions and the inherited roles
+// of the role with the name. It fails with storage.ErrRoleNotFound if there
+// is no such role or an inherited role does not exist.
+func (s *Storage) UpdateRole(_ context.Context, role models.Role) error {
+	const op = "storage.memory.UpdateRole"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.roleByName(role.Name)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+	}
+
+	inherits, err := s.roleIDs(role.Inherits, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	row.description = role.Description
+	row.permissions = rolePermissions(role.Permissions, role.Conditions)
+	row.inherits = inherits
+
+	return nil
+}
+
+// rolePermissions returns the permissions with their conditions. A
+// permission listed twice keeps its first condition.
+func rolePermissions(permissions []string, conditions map[string]string) map[string]string {
+	granted := make(map[string]string, len(permissions))
+	for _, permission := range permissions {
+		if _, ok := granted[permission]; !ok {
+			granted[permission] = conditions[permission]
+		}
+	}
+
+	return granted
+}
+
+// roleIDs returns the ids of the roles with the names, failing with
+// storage.ErrRoleNotFound if one does not exist. The extra role, if any, is
+// looked up among them too.
+func (s *Storage) roleIDs(names []string, extra *roleRow) (map[int64]bool, error) {
+	ids := make(map[int64]bool, len(names))
+	for _, name := range names {
+		if extra != nil && extra.name == name {
+			ids[extra.id] = true
+			continue
+		}
+		row, ok := s.roleByName(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", storage.ErrRoleNotFound, name)
+		}
+		ids[row.id] = true
+	}
+
+	return ids, nil
+}
+
+func (s *Storage) roleByName(name string) (*roleRow, bool) {
+	return s.roles.first(func(r *roleRow) bool { return r.name == name })
+}
+
+// Role returns the role with the name. It fails with
+// storage.ErrRoleNotFound if there is no such role.
+func (s *Storage) Role(_ context.Context, name string) (models.Role, error) {
+	const op = "storage.memory.Role"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row, ok := s.roleByName(name)
+	if !ok {
+		return models.Role{}, fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+	}
+
+	return s.role(row), nil
+}
+
+// Roles returns every role, ordered by name.
+func (s *Storage) Roles(_ context.Context) ([]models.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sortedRoles(s.roles.all()), nil
+}
+
+// DeleteRole deletes the role with the name, which the users it was
+// assigned to and the roles inheriting it lose. It fails with
+// storage.ErrRoleNotFound if there is no such role.
+func (s *Storage) DeleteRole(_ context.Context, name string) error {
+	const op = "storage.memory.DeleteRole"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.roleByName(name)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+	}
+	delete(s.roles.rows, row.id)
+
+	for _, other := range s.roles.rows {
+		delete(other.inherits, row.id)
+	}
+	for _, group := range s.groups.rows {
+		delete(group.roles, row.id)
+	}
+	maps.DeleteFunc(s.userRoles, func(key userRoleKey, _ time.Time) bool { return key.roleID == row.id })
+
+	return nil
+}
+
+// adminRole is the role migrations create for admins of single apps. Users
+// with the is_admin flag administer every app.
+const adminRole = "admin"
+
+// AssignRole assigns the role with the name to the user within the app, or
+// globally if appID is zero, on behalf of the admin, unless the user has it
+// already. Assignments without an admin, as of default roles on
+// registration, are not audited. It fails with storage.ErrRoleNotFound or
+// storage.ErrUserNotFound if there is no such role or user.
+func (s *Storage) AssignRole(
+	_ context.Context,
+	userID int64,
+	role string,
+	appID int,
+	adminID int64,
+	at time.Time,
+) error {
+	const op = "storage.memory.AssignRole"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.roleByName(role)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+	}
+	if _, ok = s.activeUser(userID); !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	key := userRoleKey{userID, row.id, appID}
+	if _, ok = s.userRoles[key]; ok {
+		return nil
+	}
+	s.userRoles[key] = at.UTC()
+
+	if adminID != 0 {
+		s.saveAdminEvent(models.AuditRoleAssigned, userID, appID, adminID, role, at)
+	}
+
+	return nil
+}
+
+// RevokeRole takes the role with the name the user has within the app, or
+// globally if appID is zero, on behalf of the admin, if the user has it.
+func (s *Storage) RevokeRole(
+	_ context.Context,
+	userID int64,
+	role string,
+	appID int,
+	adminID int64,
+	at time.Time,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row, ok := s.roleByName(role)
+	if !ok {
+		return nil
+	}
+
+	key := userRoleKey{userID, row.id, appID}
+	if _, ok = s.userRoles[key]; !ok {
+		return nil
+	}
+	delete(s.userRoles, key)
+
+	s.saveAdminEvent(models.AuditRoleRevoked, userID, appID, adminID, role, at)
+
+	return nil
+}
+
+// userRoleIDs returns the ids of the roles the user has within an app,
+// those assigned globally included, directly or through a group, and the
+// roles these inherit transitively.
+func (s *Storage) userRoleIDs(userID int64, appID int) map[int64]bool {
+	ids := make(map[int64]bool)
+	var pending []int64
+	add := func(id int64) {
+		if !ids[id] {
+			ids[id] = true
+			pending = append(pending, id)
+		}
+	}
+
+	for key := range s.userRoles {
+		if key.userID == userID && (key.appID == 0 || key.appID == appID) {
+			add(key.roleID)
+		}
+	}
+	for key := range s.groupMembers {
+		if group, ok := s.groups.get(key.groupID); ok && key.userID == userID {
+			for id := range group.roles {
+				add(id)
+			}
+		}
+	}
+	for len(pending) > 0 {
+		id := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if row, ok := s.roles.get(id); ok {
+			for inherited := range row.inherits {
+				add(inherited)
+			}
+		}
+	}
+
+	return ids
+}
+
+// UserRoles returns the roles the user has within the app, or the global
+// ones if appID is zero, directly, through the groups of the user or by
+// inheritance, ordered by name. Global roles apply within every app.
+func (s *Storage) UserRoles(_ context.Context, userID int64, appID int) ([]models.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := s.userRoleIDs(userID, appID)
+
+	return s.sortedRoles(s.roles.where(func(r *roleRow) bool { return ids[r.id] })), nil
+}
+
+// PermissionConditions returns the conditions under which the roles the
+// user has within the app, see UserRoles, grant the permission, an empty
+// one for roles granting it unconditionally. It returns none if no role
+// grants the permission.
+func (s *Storage) PermissionConditions(_ context.Context, userID int64, appID int, permission string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	conditions := make(map[string]bool)
+	for id := range s.userRoleIDs(userID, appID) {
+		row, ok := s.roles.get(id)
+		if !ok {
+			continue
+		}
+		if condition, ok := row.permissions[permission]; ok {
+			conditions[condition] = true
+		}
+	}
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+
+	return slices.Sorted(maps.Keys(conditions)), nil
+}
+
+// IsAppAdmin tells whether the user administers the app, either by the
+// is_admin flag or by having the admin role within the app. It fails with
+// storage.ErrUserNotFound if there is no such user or the user is deleted.
+func (s *Storage) IsAppAdmin(_ context.Context, userID int64, appID int) (bool, error) {
+	const op = "storage.memory.IsAppAdmin"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.activeUser(userID)
+	if !ok {
+		return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	if u.IsAdmin {
+		return true, nil
+	}
+	if appID == 0 {
+		return false, nil
+	}
+
+	row, ok := s.roleByName(adminRole)
+	if !ok {
+		return false, nil
+	}
+	_, ok = s.userRoles[userRoleKey{userID, row.id, appID}]
+
+	return ok, nil
+}
+
+// RoleHierarchy returns the names of the roles each role inherits directly,
+// ordered by name, keyed by the name of the role.
+func (s *Storage) RoleHierarchy(_ context.Context) (map[string][]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hierarchy := make(map[string][]string)
+	for _, row := range s.roles.rows {
+		if inherits := s.roleNames(row.inherits); len(inherits) > 0 {
+			hierarchy[row.name] = inherits
+		}
+	}
+
+	return hierarchy, nil
+}
+
+// roleNames returns the names of the roles with the ids, ordered.
+func (s *Storage) roleNames(ids map[int64]bool) []string {
+	var names []string
+	for id := range ids {
+		if row, ok := s.roles.get(id); ok {
+			names = append(names, row.name)
+		}
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// sortedRoles returns the roles ordered by name.
+func (s *Storage) sortedRoles(rows []*roleRow) []models.Role {
+	var roles []models.Role
+	for _, row := range rows {
+		roles = append(roles, s.role(row))
+	}
+	slices.SortFunc(roles, func(a, b models.Role) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return roles
+}
+
+func (s *Storage) role(row *roleRow) models.Role {
+	role := models.Role{
+		ID:          row.id,
+		Name:        row.name,
+		Description: row.description,
+		Inherits:    s.roleNames(row.inherits),
+		CreatedAt:   row.createdAt,
+	}
+	if len(row.permissions) > 0 {
+		role.Permissions = slices.Sorted(maps.Keys(row.permissions))
+	}
+	for permission, condition := range row.permissions {
+		if condition == "" {
+			continue
+		}
+		if role.Conditions == nil {
+			role.Conditions = make(map[string]string)
+		}
+		role.Conditions[permission] = condition
+	}
+
+	return role
+}
@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/storage"
+	"time"
+)
+
+type recoveryCodeRow struct {
+	userID    int64
+	codeHash  string
+	createdAt time.Time
+	usedAt    *time.Time
+}
+
+// ReplaceRecoveryCodes deletes the recovery codes of the user and saves the
+// new ones in one transaction.
+func (s *Storage) ReplaceRecoveryCodes(_ context.Context, userID int64, codeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recoveryCodes.delete(func(c *recoveryCodeRow) bool { return c.userID == userID })
+
+	now := time.Now().UTC()
+	for _, hash := range codeHashes {
+		s.recoveryCodes.insert(&recoveryCodeRow{userID: userID, codeHash: hash, createdAt: now})
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of the user as used. It
+// fails with storage.ErrRecoveryCodeNotFound if there is none with the hash.
+func (s *Storage) UseRecoveryCode(_ context.Context, userID int64, codeHash string) error {
+	const op = "storage.memory.UseRecoveryCode"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code, ok := s.recoveryCodes.first(func(c *recoveryCodeRow) bool {
+		return c.userID == userID && c.codeHash == codeHash && c.usedAt == nil
+	})
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrRecoveryCodeNotFound)
+	}
+	code.usedAt = ptr(time.Now().UTC())
+
+	return nil
+}
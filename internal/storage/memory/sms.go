@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveSMSCode(_ context.Context, code models.SMSCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code.Attempts = 0
+	code.ExpiresAt = code.ExpiresAt.UTC()
+	code.CreatedAt = code.CreatedAt.UTC()
+	code.UsedAt = nil
+	code.ID = s.smsCodes.insert(&code)
+
+	return nil
+}
+
+// LatestSMSCode returns the last code sent to the user for the purpose. It
+// fails with storage.ErrSMSCodeNotFound if none was sent.
+func (s *Storage) LatestSMSCode(_ context.Context, userID int64, purpose models.SMSCodePurpose) (models.SMSCode, error) {
+	const op = "storage.memory.LatestSMSCode"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	codes := s.smsCodes.where(func(c *models.SMSCode) bool { return c.UserID == userID && c.Purpose == purpose })
+	if len(codes) == 0 {
+		return models.SMSCode{}, fmt.Errorf("%s: %w", op, storage.ErrSMSCodeNotFound)
+	}
+
+	latest := *codes[len(codes)-1]
+	latest.UsedAt = utcPtr(latest.UsedAt)
+
+	return latest, nil
+}
+
+// FailSMSCode counts a wrong code entered against the sent one.
+func (s *Storage) FailSMSCode(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if code, ok := s.smsCodes.get(id); ok {
+		code.Attempts++
+	}
+
+	return nil
+}
+
+// UseSMSCode marks the code as used. It fails with storage.ErrSMSCodeUsed if
+// the code has already been used.
+func (s *Storage) UseSMSCode(_ context.Context, id int64) error {
+	const op = "storage.memory.UseSMSCode"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code, ok := s.smsCodes.get(id)
+	if !ok || code.UsedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrSMSCodeUsed)
+	}
+	code.UsedAt = ptr(time.Now().UTC())
+
+	return nil
+}
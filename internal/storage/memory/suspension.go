@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// SuspendUser keeps the user from logging in until UnsuspendUser and ends
+// the sessions of the user, recording the admin as the actor. It fails with
+// storage.ErrUserNotFound if there is no such user or the user is deleted.
+func (s *Storage) SuspendUser(_ context.Context, userID int64, adminID int64, at time.Time) error {
+	const op = "storage.memory.SuspendUser"
+
+	if err := s.setUserStatus(userID, adminID, at, models.UserStatusSuspended); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UnsuspendUser lets the suspended user log in again. It fails with
+// storage.ErrUserNotFound if there is no such user or the user is deleted.
+func (s *Storage) UnsuspendUser(_ context.Context, userID int64, adminID int64, at time.Time) error {
+	const op = "storage.memory.UnsuspendUser"
+
+	if err := s.setUserStatus(userID, adminID, at, models.UserStatusActive); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// setUserStatus sets the status of the user and records the change in the
+// audit log. Suspending the user also revokes every token of the user.
+func (s *Storage) setUserStatus(userID int64, adminID int64, at time.Time, status models.UserStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.activeUser(userID)
+	if !ok {
+		return storage.ErrUserNotFound
+	}
+	u.Status = status
+
+	event := models.AuditUserUnsuspended
+	if status == models.UserStatusSuspended {
+		u.TokenVersion++
+		s.deleteUserSessions(userID)
+		event = models.AuditUserSuspended
+	}
+
+	s.insertAuditEvent(models.AuditEvent{Type: event, UserID: userID, ActorID: adminID, CreatedAt: at})
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// table keeps the rows of a kind by id. Ids are assigned in increasing
+// order like those of an autoincrement key, and are never reused.
+type table[T any] struct {
+	rows   map[int64]*T
+	lastID int64
+}
+
+func newTable[T any]() *table[T] {
+	return &table[T]{rows: make(map[int64]*T)}
+}
+
+// insert adds the row and returns its new id.
+func (t *table[T]) insert(row *T) int64 {
+	t.lastID++
+	t.rows[t.lastID] = row
+
+	return t.lastID
+}
+
+// insertWithID adds the row with the id, replacing any row with it.
+func (t *table[T]) insertWithID(id int64, row *T) {
+	t.rows[id] = row
+	t.lastID = max(t.lastID, id)
+}
+
+func (t *table[T]) get(id int64) (*T, bool) {
+	row, ok := t.rows[id]
+
+	return row, ok
+}
+
+// all returns the rows in id order.
+func (t *table[T]) all() []*T {
+	ids := slices.Sorted(maps.Keys(t.rows))
+
+	rows := make([]*T, len(ids))
+	for i, id := range ids {
+		rows[i] = t.rows[id]
+	}
+
+	return rows
+}
+
+// where returns the rows matching the predicate in id order.
+func (t *table[T]) where(match func(*T) bool) []*T {
+	var rows []*T
+	for _, row := range t.all() {
+		if match(row) {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+// first returns the row with the lowest id matching the predicate.
+func (t *table[T]) first(match func(*T) bool) (*T, bool) {
+	for _, row := range t.all() {
+		if match(row) {
+			return row, true
+		}
+	}
+
+	return nil, false
+}
+
+// delete deletes the rows matching the predicate and returns how many it
+// deleted.
+func (t *table[T]) delete(match func(*T) bool) int {
+	deleted := 0
+	for id, row := range t.rows {
+		if match(row) {
+			delete(t.rows, id)
+			deleted++
+		}
+	}
+
+	return deleted
+}
+
+// fields returns the words as they come back from the space separated
+// lists the databases keep them in: without empty words, and nil rather
+// than empty.
+func fields(words []string) []string {
+	return strings.Fields(strings.Join(words, " "))
+}
+
+// utcPtr returns the time in UTC, or nil for nil.
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+
+	return &utc
+}
+
+// ptr returns a pointer to a copy of the time.
+func ptr(t time.Time) *time.Time {
+	return &t
+}
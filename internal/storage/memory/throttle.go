@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+// Attempts returns how many attempts were recorded for the key since since.
+func (s *Storage) Attempts(_ context.Context, key string, since time.Time) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, at := range s.attempts[key] {
+		if !at.Before(since) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// RecordAttempt records an attempt for the key, which is forgotten once it
+// is older than window.
+func (s *Storage) RecordAttempt(_ context.Context, key string, at time.Time, window time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	since := at.Add(-window)
+	kept := s.attempts[key][:0]
+	for _, prev := range s.attempts[key] {
+		if !prev.Before(since) {
+			kept = append(kept, prev)
+		}
+	}
+	s.attempts[key] = append(kept, at)
+
+	return nil
+}
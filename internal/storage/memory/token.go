@@ -0,0 +1,217 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveRefreshToken(_ context.Context, token models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.insertRefreshToken(token)
+
+	return nil
+}
+
+func (s *Storage) insertRefreshToken(token models.RefreshToken) {
+	token.Scopes = fields(token.Scopes)
+	token.AuthMethods = fields(token.AuthMethods)
+	token.ExpiresAt = token.ExpiresAt.UTC()
+	token.CreatedAt = token.CreatedAt.UTC()
+	token.SessionStartedAt = token.SessionStartedAt.UTC()
+	token.AuthTime = token.AuthTime.UTC()
+	token.RotatedAt = nil
+	token.RevokedAt = nil
+	token.ID = s.refreshTokens.insert(&token)
+}
+
+func (s *Storage) RefreshToken(_ context.Context, tokenHash string) (models.RefreshToken, error) {
+	const op = "storage.memory.RefreshToken"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, ok := s.refreshTokens.first(func(t *models.RefreshToken) bool { return t.TokenHash == tokenHash })
+	if !ok {
+		return models.RefreshToken{}, fmt.Errorf("%s: %w", op, storage.ErrRefreshTokenNotFound)
+	}
+
+	found := *token
+	found.Scopes = slices.Clone(token.Scopes)
+	found.AuthMethods = slices.Clone(token.AuthMethods)
+
+	return found, nil
+}
+
+// RotateRefreshToken marks the old token as rotated and saves its successor
+// in one transaction. It fails with storage.ErrRefreshTokenRotated if the old
+// token has already been rotated or revoked.
+func (s *Storage) RotateRefreshToken(_ context.Context, oldID int64, token models.RefreshToken) error {
+	const op = "storage.memory.RotateRefreshToken"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.refreshTokens.get(oldID)
+	if !ok || old.RotatedAt != nil || old.RevokedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrRefreshTokenRotated)
+	}
+	old.RotatedAt = ptr(time.Now().UTC())
+
+	s.insertRefreshToken(token)
+
+	return nil
+}
+
+func (s *Storage) RevokeRefreshTokenFamily(_ context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revokeRefreshTokens(func(t *models.RefreshToken) bool { return t.FamilyID == familyID })
+
+	return nil
+}
+
+func (s *Storage) RevokeUserRefreshTokens(_ context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revokeRefreshTokens(func(t *models.RefreshToken) bool { return t.UserID == userID })
+
+	return nil
+}
+
+// revokeRefreshTokens revokes the unrevoked refresh tokens matching the
+// predicate.
+func (s *Storage) revokeRefreshTokens(match func(*models.RefreshToken) bool) {
+	at := time.Now().UTC()
+	for _, token := range s.refreshTokens.rows {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = ptr(at)
+		}
+	}
+}
+
+func (s *Storage) SaveTokenIssuance(_ context.Context, issuance models.TokenIssuance) error {
+	const op = "storage.memory.SaveTokenIssuance"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tokenIssuances.first(func(i *models.TokenIssuance) bool { return i.ID == issuance.ID }); ok {
+		return fmt.Errorf("%s: token issuance %s already exists", op, issuance.ID)
+	}
+
+	issuance.IssuedAt = issuance.IssuedAt.UTC()
+	issuance.ExpiresAt = issuance.ExpiresAt.UTC()
+	issuance.RevokedAt = nil
+	s.tokenIssuances.insert(&issuance)
+
+	return nil
+}
+
+func (s *Storage) RevokeTokenIssuance(_ context.Context, tokenID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	issuance, ok := s.tokenIssuances.first(func(i *models.TokenIssuance) bool { return i.ID == tokenID })
+	if ok && issuance.RevokedAt == nil {
+		issuance.RevokedAt = ptr(time.Now().UTC())
+	}
+
+	return nil
+}
+
+// sessionRow is the session of an opaque token. Claims are kept encoded, so
+// that they decode to the same types they do from the databases.
+type sessionRow struct {
+	appID     int
+	claims    string
+	expiresAt time.Time
+	createdAt time.Time
+}
+
+func (s *Storage) SaveSession(_ context.Context, session models.Session) error {
+	const op = "storage.memory.SaveSession"
+
+	claims, err := json.Marshal(session.Claims)
+	if err != nil {
+		return fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[session.TokenHash]; ok {
+		return fmt.Errorf("%s: session already exists", op)
+	}
+	s.sessions[session.TokenHash] = &sessionRow{
+		appID:     session.AppID,
+		claims:    string(claims),
+		expiresAt: session.ExpiresAt.UTC(),
+		createdAt: session.CreatedAt.UTC(),
+	}
+
+	return nil
+}
+
+// Session returns the session of the opaque token. Expired sessions are
+// reported as not found.
+func (s *Storage) Session(_ context.Context, tokenHash string) (models.Session, error) {
+	const op = "storage.memory.Session"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row, ok := s.sessions[tokenHash]
+	if !ok || !row.expiresAt.After(time.Now()) {
+		return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+
+	session := models.Session{
+		TokenHash: tokenHash,
+		AppID:     row.appID,
+		ExpiresAt: row.expiresAt,
+		CreatedAt: row.createdAt,
+	}
+	if err := json.Unmarshal([]byte(row.claims), &session.Claims); err != nil {
+		return models.Session{}, fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return session, nil
+}
+
+// ExtendSession updates the claims and the expiration of the session.
+func (s *Storage) ExtendSession(_ context.Context, session models.Session) error {
+	const op = "storage.memory.ExtendSession"
+
+	claims, err := json.Marshal(session.Claims)
+	if err != nil {
+		return fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if row, ok := s.sessions[session.TokenHash]; ok {
+		row.claims = string(claims)
+		row.expiresAt = session.ExpiresAt.UTC()
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteSession(_ context.Context, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, tokenHash)
+
+	return nil
+}
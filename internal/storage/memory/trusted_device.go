@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveTrustedDevice(_ context.Context, device models.TrustedDevice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	device.CreatedAt = device.CreatedAt.UTC()
+	device.LastUsedAt = device.LastUsedAt.UTC()
+	device.ExpiresAt = device.ExpiresAt.UTC()
+	device.ID = s.trustedDevices.insert(&device)
+
+	return nil
+}
+
+// TrustedDevice returns the device of the token, expired or not.
+func (s *Storage) TrustedDevice(_ context.Context, tokenHash string) (models.TrustedDevice, error) {
+	const op = "storage.memory.TrustedDevice"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	device, ok := s.trustedDevices.first(func(d *models.TrustedDevice) bool { return d.TokenHash == tokenHash })
+	if !ok {
+		return models.TrustedDevice{}, fmt.Errorf("%s: %w", op, storage.ErrTrustedDeviceNotFound)
+	}
+
+	return *device, nil
+}
+
+// TouchTrustedDevice records a login from the device.
+func (s *Storage) TouchTrustedDevice(_ context.Context, id int64, ip string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if device, ok := s.trustedDevices.get(id); ok {
+		device.IP = ip
+		device.LastUsedAt = at.UTC()
+	}
+
+	return nil
+}
+
+// TrustedDevices returns the unexpired trusted devices of the user, the most
+// recently used first.
+func (s *Storage) TrustedDevices(_ context.Context, userID int64) ([]models.TrustedDevice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var devices []models.TrustedDevice
+	for _, device := range s.trustedDevices.rows {
+		if device.UserID == userID && device.ExpiresAt.After(now) {
+			devices = append(devices, *device)
+		}
+	}
+	slices.SortFunc(devices, func(a, b models.TrustedDevice) int {
+		if c := b.LastUsedAt.Compare(a.LastUsedAt); c != 0 {
+			return c
+		}
+		return int(b.ID - a.ID)
+	})
+
+	return devices, nil
+}
+
+// DeleteTrustedDevice forgets the device of the user. It fails with
+// storage.ErrTrustedDeviceNotFound if the user has no such device.
+func (s *Storage) DeleteTrustedDevice(_ context.Context, userID int64, id int64) error {
+	const op = "storage.memory.DeleteTrustedDevice"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	device, ok := s.trustedDevices.get(id)
+	if !ok || device.UserID != userID {
+		return fmt.Errorf("%s: %w", op, storage.ErrTrustedDeviceNotFound)
+	}
+	delete(s.trustedDevices.rows, id)
+
+	return nil
+}
+
+func (s *Storage) DeleteUserTrustedDevices(_ context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trustedDevices.delete(func(d *models.TrustedDevice) bool { return d.UserID == userID })
+
+	return nil
+}
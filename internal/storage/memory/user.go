@@ -0,0 +1,608 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// userRow is a user as the databases keep it, with the columns models.User
+// leaves out.
+type userRow struct {
+	models.User
+	// emailKey is nil for guests, anonymized users and users left without
+	// a key when keys were introduced.
+	emailKey          *string
+	failedLoginsSince *time.Time
+	anonymizedAt      *time.Time
+}
+
+// user returns a copy of the user that callers can change freely.
+func (u *userRow) user() models.User {
+	user := u.User
+	user.AppMetadata = maps.Clone(u.AppMetadata)
+	user.UserMetadata = maps.Clone(u.UserMetadata)
+	user.Groups = nil
+	user.Roles = nil
+
+	return user
+}
+
+// touch records a change of the profile or credentials of the user, as the
+// users_updated_at trigger does.
+func (u *userRow) touch() {
+	u.UpdatedAt = now()
+	u.Version++
+}
+
+// now returns the current time as the databases set CURRENT_TIMESTAMP, in
+// UTC and to the second.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// insertUser adds the user with the defaults of the users table, after
+// checking its unique columns, and records the user_registered event.
+func (s *Storage) insertUser(u *userRow) (int64, error) {
+	if err := s.checkUserUnique(u, 0); err != nil {
+		return 0, err
+	}
+
+	if u.Status == "" {
+		u.Status = models.UserStatusActive
+	}
+	if u.OrgID == 0 {
+		u.OrgID = models.DefaultOrgID
+	}
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = now()
+		u.UpdatedAt = u.CreatedAt
+	}
+	if u.Version == 0 {
+		u.Version = 1
+	}
+	if u.AppMetadata == nil {
+		u.AppMetadata = map[string]string{}
+	}
+	if u.UserMetadata == nil {
+		u.UserMetadata = map[string]string{}
+	}
+
+	id := s.users.insert(u)
+	u.ID = int(id)
+
+	s.recordOutboxEvent(models.OutboxUserRegistered, struct {
+		UserID  int64  `json:"user_id"`
+		Email   string `json:"email"`
+		IsGuest bool   `json:"is_guest"`
+	}{id, u.Email, u.IsGuest})
+
+	return id, nil
+}
+
+// checkUserUnique fails like the unique indexes of the users table if the
+// user shares its email, email key, username or phone with another user
+// than the one with the id.
+func (s *Storage) checkUserUnique(u *userRow, id int64) error {
+	for _, other := range s.users.rows {
+		if int64(other.ID) == id || other == u {
+			continue
+		}
+		if other.Email == u.Email ||
+			u.emailKey != nil && other.emailKey != nil && *u.emailKey == *other.emailKey {
+			return storage.ErrUserExists
+		}
+		if u.Username != "" && other.Username == u.Username {
+			return storage.ErrUsernameTaken
+		}
+		if u.Phone != "" && other.Phone == u.Phone {
+			return storage.ErrPhoneTaken
+		}
+	}
+
+	return nil
+}
+
+// activeUser returns the user with the id unless it is deleted.
+func (s *Storage) activeUser(userID int64) (*userRow, bool) {
+	u, ok := s.users.get(userID)
+	if !ok || u.DeletedAt != nil {
+		return nil, false
+	}
+
+	return u, true
+}
+
+func (s *Storage) SaveUser(_ context.Context, email string, passHash []byte, profile models.UserProfile) (int64, error) {
+	const op = "storage.memory.SaveUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.emails.Key(email)
+	id, err := s.insertUser(&userRow{
+		User: models.User{
+			Email:       email,
+			PassHash:    string(passHash),
+			FirstName:   profile.FirstName,
+			LastName:    profile.LastName,
+			DisplayName: profile.DisplayName,
+			Locale:      profile.Locale,
+			AvatarURL:   profile.AvatarURL,
+		},
+		emailKey: &key,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// User returns the user with the email, which is matched by its key. Users
+// left without a key, because their email had the key of an older user
+// when keys were introduced, are matched by the exact email.
+func (s *Storage) User(_ context.Context, email string) (models.User, error) {
+	const op = "storage.memory.User"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key := s.emails.Key(email)
+	var byEmail *userRow
+	for _, u := range s.users.all() {
+		if u.DeletedAt != nil {
+			continue
+		}
+		if u.emailKey != nil && *u.emailKey == key {
+			return u.user(), nil
+		}
+		if u.emailKey == nil && u.Email == email && byEmail == nil {
+			byEmail = u
+		}
+	}
+	if byEmail == nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return byEmail.user(), nil
+}
+
+func (s *Storage) UserByID(_ context.Context, userID int64) (models.User, error) {
+	const op = "storage.memory.UserByID"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.activeUser(userID)
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return u.user(), nil
+}
+
+// UserByIDIncludingDeleted returns the user with the id like UserByID, but
+// also if the user is deleted, so that admins can look at it.
+func (s *Storage) UserByIDIncludingDeleted(_ context.Context, userID int64) (models.User, error) {
+	const op = "storage.memory.UserByIDIncludingDeleted"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users.get(userID)
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return u.user(), nil
+}
+
+func (s *Storage) IncrementTokenVersion(_ context.Context, userID int64) error {
+	const op = "storage.memory.IncrementTokenVersion"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.TokenVersion++
+
+	return nil
+}
+
+// UpdatePassword sets the password hash of the user, which also meets a
+// password change an admin required.
+func (s *Storage) UpdatePassword(_ context.Context, userID int64, passHash []byte) error {
+	const op = "storage.memory.UpdatePassword"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.PassHash = string(passHash)
+	u.PasswordChangeRequired = false
+	u.touch()
+
+	return nil
+}
+
+// SetEmailVerified marks the email of the user as verified if it is still
+// the given one.
+func (s *Storage) SetEmailVerified(_ context.Context, userID int64, email string) error {
+	const op = "storage.memory.SetEmailVerified"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok || u.Email != email {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.EmailVerified = true
+	u.touch()
+
+	return nil
+}
+
+func (s *Storage) SetAdmin(_ context.Context, userID int64, isAdmin bool) error {
+	const op = "storage.memory.SetAdmin"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.IsAdmin = isAdmin
+	u.touch()
+
+	return nil
+}
+
+// UpdateEmail changes the email of the user and marks it as verified. It
+// fails with storage.ErrUserExists if another user has the email.
+func (s *Storage) UpdateEmail(_ context.Context, userID int64, email string) error {
+	const op = "storage.memory.UpdateEmail"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	key := s.emails.Key(email)
+	updated := *u
+	updated.Email = email
+	updated.emailKey = &key
+	if err := s.checkUserUnique(&updated, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+	}
+
+	u.Email = email
+	u.emailKey = &key
+	u.EmailVerified = true
+	u.touch()
+
+	return nil
+}
+
+// UserByUsername returns the user with the normalized username.
+func (s *Storage) UserByUsername(_ context.Context, username string) (models.User, error) {
+	const op = "storage.memory.UserByUsername"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users.first(func(u *userRow) bool {
+		return username != "" && u.Username == username && u.DeletedAt == nil
+	})
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return u.user(), nil
+}
+
+// SetUsername sets the normalized username of the user. It fails with
+// storage.ErrUsernameTaken if another user has the username.
+func (s *Storage) SetUsername(_ context.Context, userID int64, username string) error {
+	const op = "storage.memory.SetUsername"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	updated := *u
+	updated.Username = username
+	if err := s.checkUserUnique(&updated, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	u.Username = username
+	u.touch()
+
+	return nil
+}
+
+// UpdateUser applies the update and returns the updated user. It fails with
+// storage.ErrUserModified if update.Version is set and is not the version of
+// the user anymore, and with storage.ErrUserExists or
+// storage.ErrUsernameTaken if the new email or username belongs to another
+// user.
+func (s *Storage) UpdateUser(_ context.Context, update models.UserUpdate) (models.User, error) {
+	const op = "storage.memory.UpdateUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(update.Fields) == 0 {
+		return models.User{}, fmt.Errorf("%s: nothing to update", op)
+	}
+
+	u, ok := s.users.get(update.UserID)
+
+	updated := userRow{}
+	if ok {
+		updated = *u
+		updated.AppMetadata = maps.Clone(u.AppMetadata)
+		updated.UserMetadata = maps.Clone(u.UserMetadata)
+	}
+	for _, field := range update.Fields {
+		switch field {
+		case models.UserFieldEmail:
+			key := s.emails.Key(update.Email)
+			updated.Email = update.Email
+			updated.emailKey = &key
+			updated.EmailVerified = false
+		case models.UserFieldUsername:
+			updated.Username = update.Username
+		case models.UserFieldDisplayName:
+			updated.DisplayName = update.DisplayName
+		case models.UserFieldFirstName:
+			updated.FirstName = update.FirstName
+		case models.UserFieldLastName:
+			updated.LastName = update.LastName
+		case models.UserFieldLocale:
+			updated.Locale = update.Locale
+		case models.UserFieldAvatarURL:
+			updated.AvatarURL = update.AvatarURL
+		case models.UserFieldAppMetadata:
+			updated.AppMetadata = metadata(update.AppMetadata)
+		case models.UserFieldUserMetadata:
+			updated.UserMetadata = metadata(update.UserMetadata)
+		default:
+			return models.User{}, fmt.Errorf("%s: unknown field %q", op, field)
+		}
+	}
+
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	if update.Version != 0 && update.Version != u.Version {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserModified)
+	}
+	if err := s.checkUserUnique(&updated, update.UserID); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	*u = updated
+	u.touch()
+
+	return u.user(), nil
+}
+
+// SetUserMetadata replaces the user metadata of the user.
+func (s *Storage) SetUserMetadata(_ context.Context, userID int64, md map[string]string) error {
+	const op = "storage.memory.SetUserMetadata"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.UserMetadata = metadata(md)
+	u.touch()
+
+	return nil
+}
+
+// SetAppMetadata replaces the app metadata of the user.
+func (s *Storage) SetAppMetadata(_ context.Context, userID int64, md map[string]string) error {
+	const op = "storage.memory.SetAppMetadata"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.AppMetadata = metadata(md)
+	u.touch()
+
+	return nil
+}
+
+// metadata returns a copy of the metadata as stored, with no metadata as an
+// empty map.
+func metadata(md map[string]string) map[string]string {
+	if md == nil {
+		return map[string]string{}
+	}
+
+	return maps.Clone(md)
+}
+
+// ListUsers returns the users matching the filter in its sort order.
+// Deleted users are left out.
+func (s *Storage) ListUsers(_ context.Context, filter models.UserFilter) ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// the databases order emails case-insensitively and compare times to
+	// the second
+	compare := func(a *userRow, b models.UserCursor) int {
+		var c int
+		if filter.Sort == models.UserSortEmail {
+			c = strings.Compare(nocase(a.Email), nocase(b.Email))
+		} else {
+			c = a.CreatedAt.Truncate(time.Second).Compare(b.CreatedAt.Truncate(time.Second))
+		}
+
+		return cmp.Or(c, cmp.Compare(int64(a.ID), b.ID))
+	}
+
+	var search []string
+	if filter.Search != "" {
+		search = words(filter.Search)
+		if len(search) == 0 {
+			return nil, nil
+		}
+	}
+
+	var rows []*userRow
+	for _, u := range s.users.all() {
+		switch {
+		case u.DeletedAt != nil:
+		case filter.EmailPrefix != "" && !strings.HasPrefix(nocase(u.Email), nocase(filter.EmailPrefix)):
+		case search != nil && !matchesSearch(u, search):
+		case filter.EmailVerified != nil && u.EmailVerified != *filter.EmailVerified:
+		case filter.Admin != nil && u.IsAdmin != *filter.Admin:
+		case filter.OrgID != 0 && u.OrgID != filter.OrgID:
+		case !filter.CreatedAfter.IsZero() && !u.CreatedAt.After(filter.CreatedAfter.Truncate(time.Second)):
+		case filter.After != nil && !filter.Desc && compare(u, *filter.After) <= 0:
+		case filter.After != nil && filter.Desc && compare(u, *filter.After) >= 0:
+		default:
+			rows = append(rows, u)
+		}
+	}
+
+	slices.SortFunc(rows, func(a, b *userRow) int {
+		c := compare(a, models.UserCursor{ID: int64(b.ID), CreatedAt: b.CreatedAt, Email: b.Email})
+		if filter.Desc {
+			return -c
+		}
+		return c
+	})
+	if filter.Limit > 0 && len(rows) > filter.Limit {
+		rows = rows[:filter.Limit]
+	}
+
+	var users []models.User
+	for _, u := range rows {
+		users = append(users, u.user())
+	}
+
+	return users, nil
+}
+
+// nocase folds the ASCII letters of the string to lower case, as the
+// NOCASE collation of SQLite does.
+func nocase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+// words splits the text into the lower case words full-text searches
+// match, telling them apart by anything but letters and digits.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// matchesSearch tells whether the email, username or display name of the
+// user has words starting with each word of the search.
+func matchesSearch(u *userRow, search []string) bool {
+	text := words(u.Email + " " + u.Username + " " + u.DisplayName)
+	for _, word := range search {
+		if !slices.ContainsFunc(text, func(w string) bool { return strings.HasPrefix(w, word) }) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// UserByPhone returns the user with the verified phone number.
+func (s *Storage) UserByPhone(_ context.Context, phone string) (models.User, error) {
+	const op = "storage.memory.UserByPhone"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users.first(func(u *userRow) bool {
+		return phone != "" && u.Phone == phone && u.PhoneVerified && u.DeletedAt == nil
+	})
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return u.user(), nil
+}
+
+// SetPhone sets the verified phone number of the user. It fails with
+// storage.ErrPhoneTaken if another user has the number.
+func (s *Storage) SetPhone(_ context.Context, userID int64, phone string) error {
+	const op = "storage.memory.SetPhone"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users.get(userID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	updated := *u
+	updated.Phone = phone
+	if err := s.checkUserUnique(&updated, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	u.Phone = phone
+	u.PhoneVerified = true
+	u.touch()
+
+	return nil
+}
+
+func (s *Storage) IsAdmin(_ context.Context, userID int64) (bool, error) {
+	const op = "storage.memory.IsAdmin"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.activeUser(userID)
+	if !ok {
+		return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return u.IsAdmin, nil
+}
@@ -0,0 +1,31 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sso/internal/domain/models"
+)
+
+// UsersByIDs returns the users with the ids, deleted ones included, ordered
+// by id. Unknown ids are left out.
+func (s *Storage) UsersByIDs(_ context.Context, ids []int64) ([]models.User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	users := make([]models.User, 0, len(ids))
+	for _, id := range ids {
+		if u, ok := s.users.get(id); ok {
+			users = append(users, u.user())
+		}
+	}
+
+	return users, nil
+}
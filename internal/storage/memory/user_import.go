@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sso/internal/domain/models"
+	"time"
+)
+
+// ImportUser saves the user migrated from another system and records the
+// import by the admin in the audit log. It fails with storage.ErrUserExists
+// or storage.ErrUsernameTaken if the email or username belongs to another
+// user.
+func (s *Storage) ImportUser(_ context.Context, user models.UserImport, adminID int64, at time.Time) (int64, error) {
+	const op = "storage.memory.ImportUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.emails.Key(user.Email)
+	id, err := s.insertUser(&userRow{
+		User: models.User{
+			Email:         user.Email,
+			PassHash:      user.PassHash,
+			EmailVerified: user.EmailVerified,
+			Username:      user.Username,
+			DisplayName:   user.DisplayName,
+			OrgID:         user.OrgID,
+			AppMetadata:   maps.Clone(user.AppMetadata),
+			UserMetadata:  maps.Clone(user.UserMetadata),
+		},
+		emailKey: &key,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.insertAuditEvent(models.AuditEvent{Type: models.AuditUserImported, UserID: id, ActorID: adminID, CreatedAt: at})
+
+	return id, nil
+}
@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// MergeUsers moves the sessions, consents, identities, roles and groups of
+// the duplicate user over to the primary one, makes the primary an admin if
+// the duplicate was, and soft-deletes the duplicate on behalf of the admin as
+// DeleteUser does. Consents the primary already has for an app win over those
+// of the duplicate. It fails with storage.ErrUserNotFound if either user does
+// not exist or is deleted.
+func (s *Storage) MergeUsers(
+	_ context.Context,
+	primaryID int64,
+	duplicateID int64,
+	adminID int64,
+	at time.Time,
+	purgeAt time.Time,
+) error {
+	const op = "storage.memory.MergeUsers"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	duplicate, ok := s.activeUser(duplicateID)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	// the duplicate is deleted first in the databases, so that a user merged
+	// into itself is not found
+	primary, ok := s.activeUser(primaryID)
+	if !ok || primaryID == duplicateID {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	duplicate.DeletedAt = ptr(at.UTC())
+	duplicate.DeletionScheduledAt = ptr(purgeAt.UTC())
+	duplicate.TokenVersion++
+	duplicate.touch()
+
+	primary.IsAdmin = primary.IsAdmin || duplicate.IsAdmin
+	primary.touch()
+
+	for _, token := range s.refreshTokens.rows {
+		if token.UserID == duplicateID {
+			token.UserID = primaryID
+		}
+	}
+	for _, session := range s.userSessions.rows {
+		if session.UserID == duplicateID {
+			session.UserID = primaryID
+		}
+	}
+	for _, device := range s.trustedDevices.rows {
+		if device.UserID == duplicateID {
+			device.UserID = primaryID
+		}
+	}
+	for _, identity := range s.userIdentities.rows {
+		if identity.UserID == duplicateID {
+			identity.UserID = primaryID
+		}
+	}
+
+	for key, consent := range maps.Clone(s.consents) {
+		if key.userID != duplicateID {
+			continue
+		}
+		delete(s.consents, key)
+		if _, ok := s.consents[consentKey{primaryID, key.appID}]; !ok {
+			consent.UserID = primaryID
+			s.consents[consentKey{primaryID, key.appID}] = consent
+		}
+	}
+	for key, createdAt := range maps.Clone(s.userRoles) {
+		if key.userID != duplicateID {
+			continue
+		}
+		delete(s.userRoles, key)
+		merged := userRoleKey{primaryID, key.roleID, key.appID}
+		if _, ok := s.userRoles[merged]; !ok {
+			s.userRoles[merged] = createdAt
+		}
+	}
+	for key, createdAt := range maps.Clone(s.groupMembers) {
+		if key.userID != duplicateID {
+			continue
+		}
+		delete(s.groupMembers, key)
+		merged := groupMemberKey{key.groupID, primaryID}
+		if _, ok := s.groupMembers[merged]; !ok {
+			s.groupMembers[merged] = createdAt
+		}
+	}
+
+	// recorded for both users, so that the merge shows in the audit events of
+	// either
+	for _, userID := range []int64{primaryID, duplicateID} {
+		s.insertAuditEvent(models.AuditEvent{Type: models.AuditUserMerged, UserID: userID, ActorID: adminID, CreatedAt: at})
+	}
+
+	return nil
+}
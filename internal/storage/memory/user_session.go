@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveUserSession(_ context.Context, session models.UserSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.CreatedAt = session.CreatedAt.UTC()
+	session.LastSeenAt = session.LastSeenAt.UTC()
+	session.ID = s.userSessions.insert(&session)
+
+	s.recordOutboxEvent(models.OutboxSessionStarted, struct {
+		SessionID int64  `json:"session_id"`
+		UserID    int64  `json:"user_id"`
+		AppID     int    `json:"app_id"`
+		IP        string `json:"ip"`
+		Device    string `json:"device"`
+	}{session.ID, session.UserID, session.AppID, session.IP, session.Device})
+
+	return nil
+}
+
+// TouchUserSession records a use of the session of the token family.
+func (s *Storage) TouchUserSession(
+	_ context.Context,
+	familyID string,
+	ip string,
+	userAgent string,
+	device string,
+	at time.Time,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.userSessions.rows {
+		if session.FamilyID == familyID {
+			session.IP = ip
+			session.UserAgent = userAgent
+			session.Device = device
+			session.LastSeenAt = at.UTC()
+		}
+	}
+
+	return nil
+}
+
+// UserSession returns the session with the id, active or not.
+func (s *Storage) UserSession(_ context.Context, id int64) (models.UserSession, error) {
+	const op = "storage.memory.UserSession"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.userSessions.get(id)
+	if !ok {
+		return models.UserSession{}, fmt.Errorf("%s: %w", op, storage.ErrUserSessionNotFound)
+	}
+
+	return *session, nil
+}
+
+// ActiveUserSessions returns the sessions of the user whose token family
+// still has a usable refresh token, the most recently used first.
+func (s *Storage) ActiveUserSessions(_ context.Context, userID int64) ([]models.UserSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	active := make(map[string]bool)
+	for _, token := range s.refreshTokens.rows {
+		if token.RotatedAt == nil && token.RevokedAt == nil && token.ExpiresAt.After(now) {
+			active[token.FamilyID] = true
+		}
+	}
+
+	var sessions []models.UserSession
+	for _, session := range s.userSessions.rows {
+		if session.UserID == userID && active[session.FamilyID] {
+			sessions = append(sessions, *session)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b models.UserSession) int {
+		if c := b.LastSeenAt.Compare(a.LastSeenAt); c != 0 {
+			return c
+		}
+		return int(b.ID - a.ID)
+	})
+
+	return sessions, nil
+}
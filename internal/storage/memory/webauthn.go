@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveWebAuthnCredential(_ context.Context, credential models.WebAuthnCredential) error {
+	const op = "storage.memory.SaveWebAuthnCredential"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.webauthnCredentials.first(func(c *models.WebAuthnCredential) bool {
+		return bytes.Equal(c.CredentialID, credential.CredentialID)
+	})
+	if exists {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebAuthnCredentialExists)
+	}
+
+	credential.CredentialID = slices.Clone(credential.CredentialID)
+	credential.Data = slices.Clone(credential.Data)
+	credential.CreatedAt = credential.CreatedAt.UTC()
+	credential.LastUsedAt = nil
+	credential.ID = s.webauthnCredentials.insert(&credential)
+
+	return nil
+}
+
+func (s *Storage) WebAuthnCredentials(_ context.Context, userID int64) ([]models.WebAuthnCredential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var credentials []models.WebAuthnCredential
+	for _, credential := range s.webauthnCredentials.where(func(c *models.WebAuthnCredential) bool { return c.UserID == userID }) {
+		found := *credential
+		found.CredentialID = slices.Clone(credential.CredentialID)
+		found.Data = slices.Clone(credential.Data)
+		found.LastUsedAt = utcPtr(credential.LastUsedAt)
+		credentials = append(credentials, found)
+	}
+
+	return credentials, nil
+}
+
+// UpdateWebAuthnCredential stores the credential record after a login, with
+// the new signature counter.
+func (s *Storage) UpdateWebAuthnCredential(_ context.Context, credentialID []byte, data []byte, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, credential := range s.webauthnCredentials.rows {
+		if bytes.Equal(credential.CredentialID, credentialID) {
+			credential.Data = slices.Clone(data)
+			credential.LastUsedAt = ptr(usedAt.UTC())
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveWebAuthnSession(_ context.Context, session models.WebAuthnSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.Scopes = fields(session.Scopes)
+	session.Data = slices.Clone(session.Data)
+	session.ExpiresAt = session.ExpiresAt.UTC()
+	session.CreatedAt = session.CreatedAt.UTC()
+	session.UsedAt = nil
+	session.ID = s.webauthnSessions.insert(&session)
+
+	return nil
+}
+
+func (s *Storage) WebAuthnSession(_ context.Context, tokenHash string) (models.WebAuthnSession, error) {
+	const op = "storage.memory.WebAuthnSession"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.webauthnSessions.first(func(w *models.WebAuthnSession) bool { return w.TokenHash == tokenHash })
+	if !ok {
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, storage.ErrWebAuthnSessionNotFound)
+	}
+
+	found := *session
+	found.Scopes = slices.Clone(session.Scopes)
+	found.Data = slices.Clone(session.Data)
+	found.UsedAt = utcPtr(session.UsedAt)
+
+	return found, nil
+}
+
+// UseWebAuthnSession marks the ceremony as finished. It fails with
+// storage.ErrWebAuthnSessionUsed if the ceremony has already been finished.
+func (s *Storage) UseWebAuthnSession(_ context.Context, id int64) error {
+	const op = "storage.memory.UseWebAuthnSession"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.webauthnSessions.get(id)
+	if !ok || session.UsedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebAuthnSessionUsed)
+	}
+	session.UsedAt = ptr(time.Now().UTC())
+
+	return nil
+}
@@ -0,0 +1,401 @@
+package pii
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/lib/piibox"
+	"sso/internal/storage"
+	"sso/internal/storage/factory"
+	"time"
+)
+
+// Storage encrypts the emails and phone numbers of users, invitations,
+// tokens, SMS codes and linked identities before they reach the storage it
+// wraps, and decrypts them on the way back. Values stored before
+// encryption was turned on are read as they are.
+//
+// Sealing is deterministic, so lookups and unique constraints on equal
+// values keep working. Emails are found by their email keys, which the
+// storage must derive with EmailIndex. What does not work on ciphertext is
+// matching the start of an email, which listings by email prefix fail
+// with storage.ErrFilterUnsupported, ordering by email, which follows the
+// ciphertexts instead, and searching for words of emails.
+type Storage struct {
+	factory.Storage
+	box *piibox.Box
+}
+
+func New(storage factory.Storage, box *piibox.Box) *Storage {
+	return &Storage{Storage: storage, box: box}
+}
+
+// EmailIndex derives the email keys of the storage as blind indexes of the
+// keys of the normalizer, so that the keys reveal nothing of the emails.
+// Sealed emails are opened first.
+type EmailIndex struct {
+	box    *piibox.Box
+	emails factory.EmailNormalizer
+}
+
+func NewEmailIndex(box *piibox.Box, emails factory.EmailNormalizer) EmailIndex {
+	return EmailIndex{box: box, emails: emails}
+}
+
+func (i EmailIndex) Key(email string) string {
+	if plain, err := i.box.Open(email); err == nil {
+		email = plain
+	}
+
+	return i.box.Index(i.emails.Key(email))
+}
+
+// SealUsers encrypts the emails and phone numbers of users stored before
+// encryption was turned on.
+func (s *Storage) SealUsers(ctx context.Context) (int, error) {
+	return s.Storage.SealUserPII(ctx, s.box.Seal)
+}
+
+func (s *Storage) openUser(user *models.User) error {
+	var err error
+	if user.Email, err = s.box.Open(user.Email); err != nil {
+		return err
+	}
+	if user.Phone, err = s.box.Open(user.Phone); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) openUsers(users []models.User) error {
+	for i := range users {
+		if err := s.openUser(&users[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte, profile models.UserProfile) (int64, error) {
+	return s.Storage.SaveUser(ctx, s.box.Seal(email), passHash, profile)
+}
+
+func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
+	const op = "storage.pii.User"
+
+	user, err := s.Storage.User(ctx, s.box.Seal(email))
+	if err != nil {
+		return user, err
+	}
+	if err = s.openUser(&user); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UserByID(ctx context.Context, userID int64) (models.User, error) {
+	const op = "storage.pii.UserByID"
+
+	user, err := s.Storage.UserByID(ctx, userID)
+	if err != nil {
+		return user, err
+	}
+	if err = s.openUser(&user); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UserByIDIncludingDeleted(ctx context.Context, userID int64) (models.User, error) {
+	const op = "storage.pii.UserByIDIncludingDeleted"
+
+	user, err := s.Storage.UserByIDIncludingDeleted(ctx, userID)
+	if err != nil {
+		return user, err
+	}
+	if err = s.openUser(&user); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UserByUsername(ctx context.Context, username string) (models.User, error) {
+	const op = "storage.pii.UserByUsername"
+
+	user, err := s.Storage.UserByUsername(ctx, username)
+	if err != nil {
+		return user, err
+	}
+	if err = s.openUser(&user); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UserByPhone(ctx context.Context, phone string) (models.User, error) {
+	const op = "storage.pii.UserByPhone"
+
+	user, err := s.Storage.UserByPhone(ctx, s.box.Seal(phone))
+	if err != nil {
+		return user, err
+	}
+	if err = s.openUser(&user); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UsersByIDs(ctx context.Context, ids []int64) ([]models.User, error) {
+	const op = "storage.pii.UsersByIDs"
+
+	users, err := s.Storage.UsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.openUsers(users); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+func (s *Storage) ListUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	const op = "storage.pii.ListUsers"
+
+	if filter.EmailPrefix != "" {
+		return nil, fmt.Errorf("%s: %w: email prefix of encrypted emails", op, storage.ErrFilterUnsupported)
+	}
+	if filter.After != nil {
+		after := *filter.After
+		after.Email = s.box.Seal(after.Email)
+		filter.After = &after
+	}
+
+	users, err := s.Storage.ListUsers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.openUsers(users); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+func (s *Storage) UpdateUser(ctx context.Context, update models.UserUpdate) (models.User, error) {
+	const op = "storage.pii.UpdateUser"
+
+	update.Email = s.box.Seal(update.Email)
+
+	user, err := s.Storage.UpdateUser(ctx, update)
+	if err != nil {
+		return user, err
+	}
+	if err = s.openUser(&user); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UpdateEmail(ctx context.Context, userID int64, email string) error {
+	return s.Storage.UpdateEmail(ctx, userID, s.box.Seal(email))
+}
+
+func (s *Storage) SetEmailVerified(ctx context.Context, userID int64, email string) error {
+	return s.Storage.SetEmailVerified(ctx, userID, s.box.Seal(email))
+}
+
+func (s *Storage) UpgradeGuest(ctx context.Context, userID int64, email string, passHash []byte) error {
+	return s.Storage.UpgradeGuest(ctx, userID, s.box.Seal(email), passHash)
+}
+
+func (s *Storage) SetPhone(ctx context.Context, userID int64, phone string) error {
+	return s.Storage.SetPhone(ctx, userID, s.box.Seal(phone))
+}
+
+func (s *Storage) ImportUser(ctx context.Context, user models.UserImport, adminID int64, at time.Time) (int64, error) {
+	user.Email = s.box.Seal(user.Email)
+
+	return s.Storage.ImportUser(ctx, user, adminID, at)
+}
+
+func (s *Storage) SaveInvitation(ctx context.Context, invitation models.Invitation) (int64, error) {
+	invitation.Email = s.box.Seal(invitation.Email)
+
+	return s.Storage.SaveInvitation(ctx, invitation)
+}
+
+func (s *Storage) Invitation(ctx context.Context, tokenHash string) (models.Invitation, error) {
+	const op = "storage.pii.Invitation"
+
+	invitation, err := s.Storage.Invitation(ctx, tokenHash)
+	if err != nil {
+		return invitation, err
+	}
+	if invitation.Email, err = s.box.Open(invitation.Email); err != nil {
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invitation, nil
+}
+
+func (s *Storage) SaveOrgInvitation(ctx context.Context, invitation models.OrgInvitation) (int64, error) {
+	invitation.Email = s.box.Seal(invitation.Email)
+
+	return s.Storage.SaveOrgInvitation(ctx, invitation)
+}
+
+func (s *Storage) OrgInvitation(ctx context.Context, tokenHash string) (models.OrgInvitation, error) {
+	const op = "storage.pii.OrgInvitation"
+
+	invitation, err := s.Storage.OrgInvitation(ctx, tokenHash)
+	if err != nil {
+		return invitation, err
+	}
+	if invitation.Email, err = s.box.Open(invitation.Email); err != nil {
+		return models.OrgInvitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invitation, nil
+}
+
+func (s *Storage) SaveEmailChangeToken(ctx context.Context, token models.EmailChangeToken) error {
+	token.NewEmail = s.box.Seal(token.NewEmail)
+
+	return s.Storage.SaveEmailChangeToken(ctx, token)
+}
+
+func (s *Storage) EmailChangeToken(ctx context.Context, tokenHash string) (models.EmailChangeToken, error) {
+	const op = "storage.pii.EmailChangeToken"
+
+	token, err := s.Storage.EmailChangeToken(ctx, tokenHash)
+	if err != nil {
+		return token, err
+	}
+	if token.NewEmail, err = s.box.Open(token.NewEmail); err != nil {
+		return models.EmailChangeToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+func (s *Storage) SaveEmailVerificationToken(ctx context.Context, token models.EmailVerificationToken) error {
+	token.Email = s.box.Seal(token.Email)
+
+	return s.Storage.SaveEmailVerificationToken(ctx, token)
+}
+
+func (s *Storage) EmailVerificationToken(ctx context.Context, tokenHash string) (models.EmailVerificationToken, error) {
+	const op = "storage.pii.EmailVerificationToken"
+
+	token, err := s.Storage.EmailVerificationToken(ctx, tokenHash)
+	if err != nil {
+		return token, err
+	}
+	if token.Email, err = s.box.Open(token.Email); err != nil {
+		return models.EmailVerificationToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+func (s *Storage) SaveSMSCode(ctx context.Context, code models.SMSCode) error {
+	code.Phone = s.box.Seal(code.Phone)
+
+	return s.Storage.SaveSMSCode(ctx, code)
+}
+
+func (s *Storage) LatestSMSCode(ctx context.Context, userID int64, purpose models.SMSCodePurpose) (models.SMSCode, error) {
+	const op = "storage.pii.LatestSMSCode"
+
+	code, err := s.Storage.LatestSMSCode(ctx, userID, purpose)
+	if err != nil {
+		return code, err
+	}
+	if code.Phone, err = s.box.Open(code.Phone); err != nil {
+		return models.SMSCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return code, nil
+}
+
+func (s *Storage) SaveUserIdentity(ctx context.Context, identity models.UserIdentity) error {
+	identity.Email = s.box.Seal(identity.Email)
+
+	return s.Storage.SaveUserIdentity(ctx, identity)
+}
+
+func (s *Storage) UserIdentity(ctx context.Context, provider string, subject string) (models.UserIdentity, error) {
+	const op = "storage.pii.UserIdentity"
+
+	identity, err := s.Storage.UserIdentity(ctx, provider, subject)
+	if err != nil {
+		return identity, err
+	}
+	if identity.Email, err = s.box.Open(identity.Email); err != nil {
+		return models.UserIdentity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return identity, nil
+}
+
+func (s *Storage) UserIdentities(ctx context.Context, userID int64) ([]models.UserIdentity, error) {
+	const op = "storage.pii.UserIdentities"
+
+	identities, err := s.Storage.UserIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range identities {
+		if identities[i].Email, err = s.box.Open(identities[i].Email); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return identities, nil
+}
+
+// PendingOutboxEvents decrypts the emails of the user_registered events,
+// which the database records as the users table has them.
+func (s *Storage) PendingOutboxEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	const op = "storage.pii.PendingOutboxEvents"
+
+	events, err := s.Storage.PendingOutboxEvents(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i, event := range events {
+		if event.Type != models.OutboxUserRegistered {
+			continue
+		}
+
+		var payload map[string]json.RawMessage
+		if err = json.Unmarshal(event.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		var email string
+		if err = json.Unmarshal(payload["email"], &email); err != nil || !piibox.Sealed(email) {
+			continue
+		}
+		if email, err = s.box.Open(email); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if payload["email"], err = json.Marshal(email); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if events[i].Payload, err = json.Marshal(payload); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return events, nil
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"time"
+)
+
+// PoolConfig limits the connections a storage keeps open to its database,
+// and how long each query sent over them may take. Zero values keep the
+// defaults of database/sql, and no query timeout.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// QueryTimeout limits each statement on its own, those of transactions
+	// included. Statements running out of time fail with
+	// ErrDeadlineExceeded.
+	QueryTimeout time.Duration
+}
+
+// OpenDB opens a db with the connections of the connector, limited as
+// configured.
+func (c PoolConfig) OpenDB(connector driver.Connector) *sql.DB {
+	db := sql.OpenDB(withQueryTimeout(connector, c.QueryTimeout))
+	c.Apply(db)
+
+	return db
+}
+
+// Apply sets the limits on the connections of the db.
+func (c PoolConfig) Apply(db *sql.DB) {
+	if c.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	}
+	if c.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+	}
+}
@@ -0,0 +1,139 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+const (
+	cachePrefix            = "sso:cache:"
+	cacheTagPrefix         = "sso:cache-tag:"
+	cacheInvalidatedPrefix = "sso:cache-invalidated:"
+	cacheVersionKey        = "sso:cache-version"
+
+	// cacheInvalidatedTTL is how long invalidations of a tag are remembered,
+	// far longer than loading a value to cache takes.
+	cacheInvalidatedTTL = 10 * time.Minute
+)
+
+// cacheSetScript caches the value of KEYS[1] unless one of the n tags, whose
+// sets are KEYS[2..n+1] and whose invalidations KEYS[n+2..2n+1], was
+// invalidated after the version. ARGV holds the value, the ttl in
+// milliseconds, the version, n and the key to add to the tag sets.
+var cacheSetScript = redis.NewScript(`
+local n = tonumber(ARGV[4])
+for i = 1, n do
+	local invalidated = redis.call('GET', KEYS[n + 1 + i])
+	if invalidated and tonumber(invalidated) > tonumber(ARGV[3]) then
+		return 0
+	end
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+for i = 1, n do
+	redis.call('SADD', KEYS[1 + i], ARGV[5])
+	-- the tag expires with the last key given to it, so that it does not
+	-- grow for ever
+	redis.call('PEXPIRE', KEYS[1 + i], ARGV[2])
+end
+return 1
+`)
+
+// CacheGet returns the cached value of the key, and false if there is none.
+func (s *Storage) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
+	const op = "storage.redis.CacheGet"
+
+	data, err := s.client.Get(ctx, cachePrefix+key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return data, true, nil
+}
+
+// CacheVersion returns the version of the cache, which grows with every
+// invalidation. Values loaded after it was read are cached with it.
+func (s *Storage) CacheVersion(ctx context.Context) (int64, error) {
+	const op = "storage.redis.CacheVersion"
+
+	version, err := s.client.Get(ctx, cacheVersionKey).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return version, nil
+}
+
+// CacheSet caches the value of the key for the ttl, under the tags that
+// CacheInvalidate drops it by. The value is not cached if one of the tags
+// was invalidated after the version, as it may have been loaded before the
+// write that invalidated it.
+func (s *Storage) CacheSet(
+	ctx context.Context,
+	key string,
+	data []byte,
+	ttl time.Duration,
+	version int64,
+	tags ...string,
+) error {
+	const op = "storage.redis.CacheSet"
+
+	keys := make([]string, 0, 1+2*len(tags))
+	keys = append(keys, cachePrefix+key)
+	for _, tag := range tags {
+		keys = append(keys, cacheTagPrefix+tag)
+	}
+	for _, tag := range tags {
+		keys = append(keys, cacheInvalidatedPrefix+tag)
+	}
+
+	err := cacheSetScript.Run(ctx, s.client, keys, data, ttl.Milliseconds(), version, len(tags), key).Err()
+	if err != nil {
+		return fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return nil
+}
+
+// CacheInvalidate drops the cached values of the keys of the tags, and keeps
+// values loaded before from being cached under them.
+func (s *Storage) CacheInvalidate(ctx context.Context, tags ...string) error {
+	const op = "storage.redis.CacheInvalidate"
+
+	for _, tag := range tags {
+		// the invalidation is recorded before the keys are dropped, so that
+		// values are either dropped or not cached at all
+		version, err := s.client.Incr(ctx, cacheVersionKey).Result()
+		if err != nil {
+			return fmt.Errorf("%s: %s", op, err.Error())
+		}
+		if err = s.client.Set(ctx, cacheInvalidatedPrefix+tag, version, cacheInvalidatedTTL).Err(); err != nil {
+			return fmt.Errorf("%s: %s", op, err.Error())
+		}
+
+		keys, err := s.client.SMembers(ctx, cacheTagPrefix+tag).Result()
+		if err != nil {
+			return fmt.Errorf("%s: %s", op, err.Error())
+		}
+
+		del := make([]string, 0, len(keys)+1)
+		for _, key := range keys {
+			del = append(del, cachePrefix+key)
+		}
+		del = append(del, cacheTagPrefix+tag)
+
+		if err = s.client.Del(ctx, del...).Err(); err != nil {
+			return fmt.Errorf("%s: %s", op, err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+const denylistPrefix = "sso:denylist:"
+
+// Deny adds the token id to the denylist until the token expires.
+func (s *Storage) Deny(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	const op = "storage.redis.Deny"
+
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		// the token is already expired, nothing to deny
+		return nil
+	}
+
+	if err := s.client.Set(ctx, denylistPrefix+tokenID, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return nil
+}
+
+func (s *Storage) IsDenied(ctx context.Context, tokenID string) (bool, error) {
+	const op = "storage.redis.IsDenied"
+
+	err := s.client.Get(ctx, denylistPrefix+tokenID).Err()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return true, nil
+}
@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+const quotaPrefix = "sso:quota:"
+
+// IncrementUsage adds one to the usage counted for the key and returns the
+// new count. The counter is forgotten at expiresAt, the end of its window.
+func (s *Storage) IncrementUsage(ctx context.Context, key string, expiresAt time.Time) (int, error) {
+	const op = "storage.redis.IncrementUsage"
+
+	key = quotaPrefix + key
+
+	var incr *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.PExpireAt(ctx, key, expiresAt)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return int(incr.Val()), nil
+}
+
+// Usage returns the usage counted for the key, zero if nothing was.
+func (s *Storage) Usage(ctx context.Context, key string) (int, error) {
+	const op = "storage.redis.Usage"
+
+	count, err := s.client.Get(ctx, quotaPrefix+key).Int()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return count, nil
+}
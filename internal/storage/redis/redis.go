@@ -0,0 +1,31 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+)
+
+type Storage struct {
+	client *redis.Client
+}
+
+func New(addr string, password string, db int) (*Storage, error) {
+	const op = "storage.redis.New"
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return &Storage{client: client}, nil
+}
+
+func (s *Storage) Close() error {
+	return s.client.Close()
+}
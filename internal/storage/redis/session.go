@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+const sessionPrefix = "sso:session:"
+
+// SaveSession stores the session until it expires.
+func (s *Storage) SaveSession(ctx context.Context, session models.Session) error {
+	const op = "storage.redis.SaveSession"
+
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	if err = s.client.Set(ctx, sessionPrefix+session.TokenHash, data, ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return nil
+}
+
+func (s *Storage) Session(ctx context.Context, tokenHash string) (models.Session, error) {
+	const op = "storage.redis.Session"
+
+	data, err := s.client.Get(ctx, sessionPrefix+tokenHash).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+		}
+		return models.Session{}, fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	var session models.Session
+	if err = json.Unmarshal(data, &session); err != nil {
+		return models.Session{}, fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return session, nil
+}
+
+// ExtendSession stores the session again, with its new expiration.
+func (s *Storage) ExtendSession(ctx context.Context, session models.Session) error {
+	const op = "storage.redis.ExtendSession"
+
+	if err := s.SaveSession(ctx, session); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteSession(ctx context.Context, tokenHash string) error {
+	const op = "storage.redis.DeleteSession"
+
+	if err := s.client.Del(ctx, sessionPrefix+tokenHash).Err(); err != nil {
+		return fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"sso/internal/lib/randtoken"
+	"strconv"
+	"time"
+)
+
+const throttlePrefix = "sso:throttle:"
+
+// Attempts returns how many attempts were recorded for the key since since.
+// Attempts are kept in a sorted set scored by their time, so that the
+// window slides instead of resetting.
+func (s *Storage) Attempts(ctx context.Context, key string, since time.Time) (int, error) {
+	const op = "storage.redis.Attempts"
+
+	count, err := s.client.ZCount(ctx, throttlePrefix+key, strconv.FormatInt(since.UnixMilli(), 10), "+inf").Result()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return int(count), nil
+}
+
+// RecordAttempt records an attempt for the key, which is forgotten once it
+// is older than window.
+func (s *Storage) RecordAttempt(ctx context.Context, key string, at time.Time, window time.Duration) error {
+	const op = "storage.redis.RecordAttempt"
+
+	// attempts made at the same time by several instances are distinct members
+	member, _, err := randtoken.New()
+	if err != nil {
+		return fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	key = throttlePrefix + key
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(at.UnixMilli()), Member: member})
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(at.Add(-window).UnixMilli(), 10))
+		pipe.PExpire(ctx, key, window)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %s", op, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaConfig lists the DSNs of the read replicas of the primary database,
+// and how often their health is checked.
+type ReplicaConfig struct {
+	DSNs          []string
+	CheckInterval time.Duration
+}
+
+// Replicas spreads reads over the replicas of the primary that answered
+// their last health check, and falls back to the primary when none did.
+// Replicas lag behind the primary, so only reads that may miss the latest
+// writes go to them.
+type Replicas struct {
+	primary *sql.DB
+	dbs     []*sql.DB
+	healthy []atomic.Bool
+	next    atomic.Uint64
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// NewReplicas checks the health of the replica dbs every interval, until
+// Close. The replicas are assumed healthy until the first check says
+// otherwise.
+func NewReplicas(primary *sql.DB, dbs []*sql.DB, interval time.Duration) *Replicas {
+	r := &Replicas{
+		primary: primary,
+		dbs:     dbs,
+		healthy: make([]atomic.Bool, len(dbs)),
+		stop:    make(chan struct{}),
+	}
+	for i := range r.healthy {
+		r.healthy[i].Store(true)
+	}
+
+	if len(dbs) > 0 && interval > 0 {
+		r.done.Add(1)
+		go r.check(interval)
+	}
+
+	return r
+}
+
+// DB returns the next healthy replica, or the primary if there is none.
+func (r *Replicas) DB() *sql.DB {
+	n := len(r.dbs)
+	if n == 0 {
+		return r.primary
+	}
+
+	start := r.next.Add(1)
+	for i := 0; i < n; i++ {
+		idx := int((start + uint64(i)) % uint64(n))
+		if r.healthy[idx].Load() {
+			return r.dbs[idx]
+		}
+	}
+
+	return r.primary
+}
+
+// Stats returns the statistics of the connections of each replica.
+func (r *Replicas) Stats() []sql.DBStats {
+	stats := make([]sql.DBStats, len(r.dbs))
+	for i, db := range r.dbs {
+		stats[i] = db.Stats()
+	}
+
+	return stats
+}
+
+// Close stops the health checks and closes the replicas, not the primary.
+func (r *Replicas) Close() error {
+	close(r.stop)
+	r.done.Wait()
+
+	var errs []error
+	for _, db := range r.dbs {
+		errs = append(errs, db.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *Replicas) check(interval time.Duration) {
+	defer r.done.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+
+		for i, db := range r.dbs {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			r.healthy[i].Store(db.PingContext(ctx) == nil)
+			cancel()
+		}
+	}
+}
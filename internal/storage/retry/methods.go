@@ -0,0 +1,1089 @@
+package retry
+
+import (
+	"context"
+	"sso/internal/domain/models"
+	"time"
+)
+
+// Every call to the storage is retried the same way.
+
+func (s *Storage) APIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	return get(ctx, s, "APIKeyByHash", func() (models.APIKey, error) {
+		return s.Storage.APIKeyByHash(ctx, hash)
+	})
+}
+
+func (s *Storage) APIKeys(ctx context.Context, appID int) ([]models.APIKey, error) {
+	return get(ctx, s, "APIKeys", func() ([]models.APIKey, error) {
+		return s.Storage.APIKeys(ctx, appID)
+	})
+}
+
+func (s *Storage) AcceptInvitation(ctx context.Context, id int64, passHash []byte, at time.Time) (int64, error) {
+	return get(ctx, s, "AcceptInvitation", func() (int64, error) {
+		return s.Storage.AcceptInvitation(ctx, id, passHash, at)
+	})
+}
+
+func (s *Storage) AcceptOrgInvitation(ctx context.Context, id int64, userID int64, at time.Time) error {
+	return s.do(ctx, "AcceptOrgInvitation", func() error {
+		return s.Storage.AcceptOrgInvitation(ctx, id, userID, at)
+	})
+}
+
+func (s *Storage) ActiveUserSessions(ctx context.Context, userID int64) ([]models.UserSession, error) {
+	return get(ctx, s, "ActiveUserSessions", func() ([]models.UserSession, error) {
+		return s.Storage.ActiveUserSessions(ctx, userID)
+	})
+}
+
+func (s *Storage) AddGroupMember(ctx context.Context, group string, userID int64, at time.Time) error {
+	return s.do(ctx, "AddGroupMember", func() error {
+		return s.Storage.AddGroupMember(ctx, group, userID, at)
+	})
+}
+
+func (s *Storage) AnonymizeUser(ctx context.Context, userID int64, at time.Time) error {
+	return s.do(ctx, "AnonymizeUser", func() error {
+		return s.Storage.AnonymizeUser(ctx, userID, at)
+	})
+}
+
+func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
+	return get(ctx, s, "App", func() (models.App, error) {
+		return s.Storage.App(ctx, appID)
+	})
+}
+
+func (s *Storage) AppByAudience(ctx context.Context, audience string) (models.App, error) {
+	return get(ctx, s, "AppByAudience", func() (models.App, error) {
+		return s.Storage.AppByAudience(ctx, audience)
+	})
+}
+
+func (s *Storage) AppIncludingDisabled(ctx context.Context, appID int) (models.App, error) {
+	return get(ctx, s, "AppIncludingDisabled", func() (models.App, error) {
+		return s.Storage.AppIncludingDisabled(ctx, appID)
+	})
+}
+
+func (s *Storage) AppStats(ctx context.Context, appID int, from time.Time, to time.Time) (models.AppStats, error) {
+	return get(ctx, s, "AppStats", func() (models.AppStats, error) {
+		return s.Storage.AppStats(ctx, appID, from, to)
+	})
+}
+
+func (s *Storage) Apps(ctx context.Context, orgID int64) ([]models.App, error) {
+	return get(ctx, s, "Apps", func() ([]models.App, error) {
+		return s.Storage.Apps(ctx, orgID)
+	})
+}
+
+func (s *Storage) AssignGroupRole(ctx context.Context, group string, role string, adminID int64, at time.Time) error {
+	return s.do(ctx, "AssignGroupRole", func() error {
+		return s.Storage.AssignGroupRole(ctx, group, role, adminID, at)
+	})
+}
+
+func (s *Storage) AssignRole(ctx context.Context, userID int64, role string, appID int, adminID int64, at time.Time) error {
+	return s.do(ctx, "AssignRole", func() error {
+		return s.Storage.AssignRole(ctx, userID, role, appID, adminID, at)
+	})
+}
+
+func (s *Storage) AuditEvents(ctx context.Context, filter models.AuditEventFilter) ([]models.AuditEvent, error) {
+	return get(ctx, s, "AuditEvents", func() ([]models.AuditEvent, error) {
+		return s.Storage.AuditEvents(ctx, filter)
+	})
+}
+
+func (s *Storage) CancelUserDeletion(ctx context.Context, userID int64) error {
+	return s.do(ctx, "CancelUserDeletion", func() error {
+		return s.Storage.CancelUserDeletion(ctx, userID)
+	})
+}
+
+func (s *Storage) ClaimMappings(ctx context.Context, appID int) ([]models.ClaimMapping, error) {
+	return get(ctx, s, "ClaimMappings", func() ([]models.ClaimMapping, error) {
+		return s.Storage.ClaimMappings(ctx, appID)
+	})
+}
+
+func (s *Storage) ConfirmTOTP(ctx context.Context, userID int64, step int64) error {
+	return s.do(ctx, "ConfirmTOTP", func() error {
+		return s.Storage.ConfirmTOTP(ctx, userID, step)
+	})
+}
+
+func (s *Storage) Consent(ctx context.Context, userID int64, appID int) (models.Consent, error) {
+	return get(ctx, s, "Consent", func() (models.Consent, error) {
+		return s.Storage.Consent(ctx, userID, appID)
+	})
+}
+
+func (s *Storage) Consents(ctx context.Context, userID int64) ([]models.Consent, error) {
+	return get(ctx, s, "Consents", func() ([]models.Consent, error) {
+		return s.Storage.Consents(ctx, userID)
+	})
+}
+
+func (s *Storage) CountMagicLinks(ctx context.Context, userID int64, since time.Time) (int, error) {
+	return get(ctx, s, "CountMagicLinks", func() (int, error) {
+		return s.Storage.CountMagicLinks(ctx, userID, since)
+	})
+}
+
+func (s *Storage) DeclineOrgInvitation(ctx context.Context, id int64, at time.Time) error {
+	return s.do(ctx, "DeclineOrgInvitation", func() error {
+		return s.Storage.DeclineOrgInvitation(ctx, id, at)
+	})
+}
+
+func (s *Storage) DeleteClaimMapping(ctx context.Context, appID int, source string) error {
+	return s.do(ctx, "DeleteClaimMapping", func() error {
+		return s.Storage.DeleteClaimMapping(ctx, appID, source)
+	})
+}
+
+func (s *Storage) DeleteConsent(ctx context.Context, userID int64, appID int) error {
+	return s.do(ctx, "DeleteConsent", func() error {
+		return s.Storage.DeleteConsent(ctx, userID, appID)
+	})
+}
+
+func (s *Storage) DeleteDeliveredOutboxEvents(ctx context.Context, before time.Time) (int, error) {
+	return get(ctx, s, "DeleteDeliveredOutboxEvents", func() (int, error) {
+		return s.Storage.DeleteDeliveredOutboxEvents(ctx, before)
+	})
+}
+
+func (s *Storage) DeleteGroup(ctx context.Context, name string) error {
+	return s.do(ctx, "DeleteGroup", func() error {
+		return s.Storage.DeleteGroup(ctx, name)
+	})
+}
+
+func (s *Storage) DeleteIdentityProvider(ctx context.Context, name string) error {
+	return s.do(ctx, "DeleteIdentityProvider", func() error {
+		return s.Storage.DeleteIdentityProvider(ctx, name)
+	})
+}
+
+func (s *Storage) DeletePolicyRule(ctx context.Context, id int64) error {
+	return s.do(ctx, "DeletePolicyRule", func() error {
+		return s.Storage.DeletePolicyRule(ctx, id)
+	})
+}
+
+func (s *Storage) DeleteRole(ctx context.Context, name string) error {
+	return s.do(ctx, "DeleteRole", func() error {
+		return s.Storage.DeleteRole(ctx, name)
+	})
+}
+
+func (s *Storage) DeleteSession(ctx context.Context, tokenHash string) error {
+	return s.do(ctx, "DeleteSession", func() error {
+		return s.Storage.DeleteSession(ctx, tokenHash)
+	})
+}
+
+func (s *Storage) DeleteTOTP(ctx context.Context, userID int64) error {
+	return s.do(ctx, "DeleteTOTP", func() error {
+		return s.Storage.DeleteTOTP(ctx, userID)
+	})
+}
+
+func (s *Storage) DeleteTrustedDevice(ctx context.Context, userID int64, id int64) error {
+	return s.do(ctx, "DeleteTrustedDevice", func() error {
+		return s.Storage.DeleteTrustedDevice(ctx, userID, id)
+	})
+}
+
+func (s *Storage) DeleteUser(ctx context.Context, userID int64, adminID int64, at time.Time, purgeAt time.Time) error {
+	return s.do(ctx, "DeleteUser", func() error {
+		return s.Storage.DeleteUser(ctx, userID, adminID, at, purgeAt)
+	})
+}
+
+func (s *Storage) DeleteUserTrustedDevices(ctx context.Context, userID int64) error {
+	return s.do(ctx, "DeleteUserTrustedDevices", func() error {
+		return s.Storage.DeleteUserTrustedDevices(ctx, userID)
+	})
+}
+
+func (s *Storage) DeviceAuthorization(ctx context.Context, deviceCodeHash string) (models.DeviceAuthorization, error) {
+	return get(ctx, s, "DeviceAuthorization", func() (models.DeviceAuthorization, error) {
+		return s.Storage.DeviceAuthorization(ctx, deviceCodeHash)
+	})
+}
+
+func (s *Storage) DeviceAuthorizationByUserCode(ctx context.Context, userCode string) (models.DeviceAuthorization, error) {
+	return get(ctx, s, "DeviceAuthorizationByUserCode", func() (models.DeviceAuthorization, error) {
+		return s.Storage.DeviceAuthorizationByUserCode(ctx, userCode)
+	})
+}
+
+func (s *Storage) EmailChangeToken(ctx context.Context, tokenHash string) (models.EmailChangeToken, error) {
+	return get(ctx, s, "EmailChangeToken", func() (models.EmailChangeToken, error) {
+		return s.Storage.EmailChangeToken(ctx, tokenHash)
+	})
+}
+
+func (s *Storage) EmailVerificationToken(ctx context.Context, tokenHash string) (models.EmailVerificationToken, error) {
+	return get(ctx, s, "EmailVerificationToken", func() (models.EmailVerificationToken, error) {
+		return s.Storage.EmailVerificationToken(ctx, tokenHash)
+	})
+}
+
+func (s *Storage) ExpireUser(ctx context.Context, userID int64, at time.Time, purgeAt time.Time) error {
+	return s.do(ctx, "ExpireUser", func() error {
+		return s.Storage.ExpireUser(ctx, userID, at, purgeAt)
+	})
+}
+
+func (s *Storage) ExpiredUsers(ctx context.Context, now time.Time, limit int) ([]int64, error) {
+	return get(ctx, s, "ExpiredUsers", func() ([]int64, error) {
+		return s.Storage.ExpiredUsers(ctx, now, limit)
+	})
+}
+
+func (s *Storage) ExtendSession(ctx context.Context, session models.Session) error {
+	return s.do(ctx, "ExtendSession", func() error {
+		return s.Storage.ExtendSession(ctx, session)
+	})
+}
+
+func (s *Storage) FailMFAChallenge(ctx context.Context, id int64) error {
+	return s.do(ctx, "FailMFAChallenge", func() error {
+		return s.Storage.FailMFAChallenge(ctx, id)
+	})
+}
+
+func (s *Storage) FailOutboxEvent(ctx context.Context, id int64, reason string) error {
+	return s.do(ctx, "FailOutboxEvent", func() error {
+		return s.Storage.FailOutboxEvent(ctx, id, reason)
+	})
+}
+
+func (s *Storage) FailSMSCode(ctx context.Context, id int64) error {
+	return s.do(ctx, "FailSMSCode", func() error {
+		return s.Storage.FailSMSCode(ctx, id)
+	})
+}
+
+func (s *Storage) FederationState(ctx context.Context, stateHash string) (models.FederationState, error) {
+	return get(ctx, s, "FederationState", func() (models.FederationState, error) {
+		return s.Storage.FederationState(ctx, stateHash)
+	})
+}
+
+func (s *Storage) Group(ctx context.Context, name string) (models.Group, error) {
+	return get(ctx, s, "Group", func() (models.Group, error) {
+		return s.Storage.Group(ctx, name)
+	})
+}
+
+func (s *Storage) GroupMembers(ctx context.Context, group string) ([]int64, error) {
+	return get(ctx, s, "GroupMembers", func() ([]int64, error) {
+		return s.Storage.GroupMembers(ctx, group)
+	})
+}
+
+func (s *Storage) Groups(ctx context.Context) ([]models.Group, error) {
+	return get(ctx, s, "Groups", func() ([]models.Group, error) {
+		return s.Storage.Groups(ctx)
+	})
+}
+
+func (s *Storage) HashAppSecrets(ctx context.Context) (int, error) {
+	return get(ctx, s, "HashAppSecrets", func() (int, error) {
+		return s.Storage.HashAppSecrets(ctx)
+	})
+}
+
+func (s *Storage) IdentityProvider(ctx context.Context, name string) (models.IdentityProvider, error) {
+	return get(ctx, s, "IdentityProvider", func() (models.IdentityProvider, error) {
+		return s.Storage.IdentityProvider(ctx, name)
+	})
+}
+
+func (s *Storage) IdentityProviders(ctx context.Context) ([]models.IdentityProvider, error) {
+	return get(ctx, s, "IdentityProviders", func() ([]models.IdentityProvider, error) {
+		return s.Storage.IdentityProviders(ctx)
+	})
+}
+
+func (s *Storage) ImportUser(ctx context.Context, user models.UserImport, adminID int64, at time.Time) (int64, error) {
+	return get(ctx, s, "ImportUser", func() (int64, error) {
+		return s.Storage.ImportUser(ctx, user, adminID, at)
+	})
+}
+
+func (s *Storage) IncrementTokenVersion(ctx context.Context, userID int64) error {
+	return s.do(ctx, "IncrementTokenVersion", func() error {
+		return s.Storage.IncrementTokenVersion(ctx, userID)
+	})
+}
+
+func (s *Storage) Invitation(ctx context.Context, tokenHash string) (models.Invitation, error) {
+	return get(ctx, s, "Invitation", func() (models.Invitation, error) {
+		return s.Storage.Invitation(ctx, tokenHash)
+	})
+}
+
+func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
+	return get(ctx, s, "IsAdmin", func() (bool, error) {
+		return s.Storage.IsAdmin(ctx, userID)
+	})
+}
+
+func (s *Storage) IsAppAdmin(ctx context.Context, userID int64, appID int) (bool, error) {
+	return get(ctx, s, "IsAppAdmin", func() (bool, error) {
+		return s.Storage.IsAppAdmin(ctx, userID, appID)
+	})
+}
+
+func (s *Storage) LatestSMSCode(ctx context.Context, userID int64, purpose models.SMSCodePurpose) (models.SMSCode, error) {
+	return get(ctx, s, "LatestSMSCode", func() (models.SMSCode, error) {
+		return s.Storage.LatestSMSCode(ctx, userID, purpose)
+	})
+}
+
+func (s *Storage) ListUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	return get(ctx, s, "ListUsers", func() ([]models.User, error) {
+		return s.Storage.ListUsers(ctx, filter)
+	})
+}
+
+func (s *Storage) LockUser(ctx context.Context, userID int64, until time.Time) error {
+	return s.do(ctx, "LockUser", func() error {
+		return s.Storage.LockUser(ctx, userID, until)
+	})
+}
+
+func (s *Storage) LoginHistory(ctx context.Context, userID int64, limit int) ([]models.Login, error) {
+	return get(ctx, s, "LoginHistory", func() ([]models.Login, error) {
+		return s.Storage.LoginHistory(ctx, userID, limit)
+	})
+}
+
+func (s *Storage) MFAChallenge(ctx context.Context, tokenHash string) (models.MFAChallenge, error) {
+	return get(ctx, s, "MFAChallenge", func() (models.MFAChallenge, error) {
+		return s.Storage.MFAChallenge(ctx, tokenHash)
+	})
+}
+
+func (s *Storage) MagicLink(ctx context.Context, tokenHash string) (models.MagicLink, error) {
+	return get(ctx, s, "MagicLink", func() (models.MagicLink, error) {
+		return s.Storage.MagicLink(ctx, tokenHash)
+	})
+}
+
+func (s *Storage) MarkOutboxEventDelivered(ctx context.Context, id int64, at time.Time) error {
+	return s.do(ctx, "MarkOutboxEventDelivered", func() error {
+		return s.Storage.MarkOutboxEventDelivered(ctx, id, at)
+	})
+}
+
+func (s *Storage) MergeUsers(ctx context.Context, primaryID int64, duplicateID int64, adminID int64, at time.Time, purgeAt time.Time) error {
+	return s.do(ctx, "MergeUsers", func() error {
+		return s.Storage.MergeUsers(ctx, primaryID, duplicateID, adminID, at, purgeAt)
+	})
+}
+
+func (s *Storage) NotificationPreferences(ctx context.Context, userID int64) (models.NotificationPreferences, error) {
+	return get(ctx, s, "NotificationPreferences", func() (models.NotificationPreferences, error) {
+		return s.Storage.NotificationPreferences(ctx, userID)
+	})
+}
+
+func (s *Storage) Org(ctx context.Context, orgID int64) (models.Org, error) {
+	return get(ctx, s, "Org", func() (models.Org, error) {
+		return s.Storage.Org(ctx, orgID)
+	})
+}
+
+func (s *Storage) OrgInvitation(ctx context.Context, tokenHash string) (models.OrgInvitation, error) {
+	return get(ctx, s, "OrgInvitation", func() (models.OrgInvitation, error) {
+		return s.Storage.OrgInvitation(ctx, tokenHash)
+	})
+}
+
+func (s *Storage) Orgs(ctx context.Context) ([]models.Org, error) {
+	return get(ctx, s, "Orgs", func() ([]models.Org, error) {
+		return s.Storage.Orgs(ctx)
+	})
+}
+
+func (s *Storage) PasswordResetToken(ctx context.Context, tokenHash string) (models.PasswordResetToken, error) {
+	return get(ctx, s, "PasswordResetToken", func() (models.PasswordResetToken, error) {
+		return s.Storage.PasswordResetToken(ctx, tokenHash)
+	})
+}
+
+func (s *Storage) PendingOutboxEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	return get(ctx, s, "PendingOutboxEvents", func() ([]models.OutboxEvent, error) {
+		return s.Storage.PendingOutboxEvents(ctx, limit)
+	})
+}
+
+func (s *Storage) PermissionConditions(ctx context.Context, userID int64, appID int, permission string) ([]string, error) {
+	return get(ctx, s, "PermissionConditions", func() ([]string, error) {
+		return s.Storage.PermissionConditions(ctx, userID, appID, permission)
+	})
+}
+
+func (s *Storage) PolicyRules(ctx context.Context) ([]models.PolicyRule, error) {
+	return get(ctx, s, "PolicyRules", func() ([]models.PolicyRule, error) {
+		return s.Storage.PolicyRules(ctx)
+	})
+}
+
+func (s *Storage) RecordFailedLogin(ctx context.Context, userID int64, at time.Time, windowStart time.Time) (int, error) {
+	return get(ctx, s, "RecordFailedLogin", func() (int, error) {
+		return s.Storage.RecordFailedLogin(ctx, userID, at, windowStart)
+	})
+}
+
+func (s *Storage) RecordLogin(ctx context.Context, login models.Login, keep int) error {
+	return s.do(ctx, "RecordLogin", func() error {
+		return s.Storage.RecordLogin(ctx, login, keep)
+	})
+}
+
+func (s *Storage) RefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	return get(ctx, s, "RefreshToken", func() (models.RefreshToken, error) {
+		return s.Storage.RefreshToken(ctx, tokenHash)
+	})
+}
+
+func (s *Storage) RemoveGroupMember(ctx context.Context, group string, userID int64) error {
+	return s.do(ctx, "RemoveGroupMember", func() error {
+		return s.Storage.RemoveGroupMember(ctx, group, userID)
+	})
+}
+
+func (s *Storage) RemoveOrgMember(ctx context.Context, orgID int64, userID int64, adminID int64, at time.Time) error {
+	return s.do(ctx, "RemoveOrgMember", func() error {
+		return s.Storage.RemoveOrgMember(ctx, orgID, userID, adminID, at)
+	})
+}
+
+func (s *Storage) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	return s.do(ctx, "ReplaceRecoveryCodes", func() error {
+		return s.Storage.ReplaceRecoveryCodes(ctx, userID, codeHashes)
+	})
+}
+
+func (s *Storage) RequirePasswordChange(ctx context.Context, userID int64, adminID int64, at time.Time) error {
+	return s.do(ctx, "RequirePasswordChange", func() error {
+		return s.Storage.RequirePasswordChange(ctx, userID, adminID, at)
+	})
+}
+
+func (s *Storage) RestoreUser(ctx context.Context, userID int64, adminID int64, at time.Time) error {
+	return s.do(ctx, "RestoreUser", func() error {
+		return s.Storage.RestoreUser(ctx, userID, adminID, at)
+	})
+}
+
+func (s *Storage) RevokeAPIKey(ctx context.Context, appID int, keyID int64, adminID int64, at time.Time) error {
+	return s.do(ctx, "RevokeAPIKey", func() error {
+		return s.Storage.RevokeAPIKey(ctx, appID, keyID, adminID, at)
+	})
+}
+
+func (s *Storage) RevokeGroupRole(ctx context.Context, group string, role string, adminID int64, at time.Time) error {
+	return s.do(ctx, "RevokeGroupRole", func() error {
+		return s.Storage.RevokeGroupRole(ctx, group, role, adminID, at)
+	})
+}
+
+func (s *Storage) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	return s.do(ctx, "RevokeRefreshTokenFamily", func() error {
+		return s.Storage.RevokeRefreshTokenFamily(ctx, familyID)
+	})
+}
+
+func (s *Storage) RevokeRole(ctx context.Context, userID int64, role string, appID int, adminID int64, at time.Time) error {
+	return s.do(ctx, "RevokeRole", func() error {
+		return s.Storage.RevokeRole(ctx, userID, role, appID, adminID, at)
+	})
+}
+
+func (s *Storage) RevokeTokenIssuance(ctx context.Context, tokenID string) error {
+	return s.do(ctx, "RevokeTokenIssuance", func() error {
+		return s.Storage.RevokeTokenIssuance(ctx, tokenID)
+	})
+}
+
+func (s *Storage) RevokeUserRefreshTokens(ctx context.Context, userID int64) error {
+	return s.do(ctx, "RevokeUserRefreshTokens", func() error {
+		return s.Storage.RevokeUserRefreshTokens(ctx, userID)
+	})
+}
+
+func (s *Storage) Role(ctx context.Context, name string) (models.Role, error) {
+	return get(ctx, s, "Role", func() (models.Role, error) {
+		return s.Storage.Role(ctx, name)
+	})
+}
+
+func (s *Storage) RoleHierarchy(ctx context.Context) (map[string][]string, error) {
+	return get(ctx, s, "RoleHierarchy", func() (map[string][]string, error) {
+		return s.Storage.RoleHierarchy(ctx)
+	})
+}
+
+func (s *Storage) Roles(ctx context.Context) ([]models.Role, error) {
+	return get(ctx, s, "Roles", func() ([]models.Role, error) {
+		return s.Storage.Roles(ctx)
+	})
+}
+
+func (s *Storage) RotateAppSecret(ctx context.Context, appID int, hash string, signingKey string, previousExpiresAt *time.Time, adminID int64, at time.Time) error {
+	return s.do(ctx, "RotateAppSecret", func() error {
+		return s.Storage.RotateAppSecret(ctx, appID, hash, signingKey, previousExpiresAt, adminID, at)
+	})
+}
+
+func (s *Storage) RotateRefreshToken(ctx context.Context, oldID int64, token models.RefreshToken) error {
+	return s.do(ctx, "RotateRefreshToken", func() error {
+		return s.Storage.RotateRefreshToken(ctx, oldID, token)
+	})
+}
+
+func (s *Storage) SaveAPIKey(ctx context.Context, key models.APIKey) (int64, error) {
+	return get(ctx, s, "SaveAPIKey", func() (int64, error) {
+		return s.Storage.SaveAPIKey(ctx, key)
+	})
+}
+
+func (s *Storage) SaveApp(ctx context.Context, app models.App, adminID int64) (int, error) {
+	return get(ctx, s, "SaveApp", func() (int, error) {
+		return s.Storage.SaveApp(ctx, app, adminID)
+	})
+}
+
+func (s *Storage) SaveAuditEvent(ctx context.Context, event models.AuditEvent) error {
+	return s.do(ctx, "SaveAuditEvent", func() error {
+		return s.Storage.SaveAuditEvent(ctx, event)
+	})
+}
+
+func (s *Storage) SaveClaimMapping(ctx context.Context, mapping models.ClaimMapping) error {
+	return s.do(ctx, "SaveClaimMapping", func() error {
+		return s.Storage.SaveClaimMapping(ctx, mapping)
+	})
+}
+
+func (s *Storage) SaveConsent(ctx context.Context, consent models.Consent) error {
+	return s.do(ctx, "SaveConsent", func() error {
+		return s.Storage.SaveConsent(ctx, consent)
+	})
+}
+
+func (s *Storage) SaveDeviceAuthorization(ctx context.Context, auth models.DeviceAuthorization) error {
+	return s.do(ctx, "SaveDeviceAuthorization", func() error {
+		return s.Storage.SaveDeviceAuthorization(ctx, auth)
+	})
+}
+
+func (s *Storage) SaveEmailChangeToken(ctx context.Context, token models.EmailChangeToken) error {
+	return s.do(ctx, "SaveEmailChangeToken", func() error {
+		return s.Storage.SaveEmailChangeToken(ctx, token)
+	})
+}
+
+func (s *Storage) SaveEmailVerificationToken(ctx context.Context, token models.EmailVerificationToken) error {
+	return s.do(ctx, "SaveEmailVerificationToken", func() error {
+		return s.Storage.SaveEmailVerificationToken(ctx, token)
+	})
+}
+
+func (s *Storage) SaveFederationState(ctx context.Context, state models.FederationState) error {
+	return s.do(ctx, "SaveFederationState", func() error {
+		return s.Storage.SaveFederationState(ctx, state)
+	})
+}
+
+func (s *Storage) SaveGroup(ctx context.Context, group models.Group) (int64, error) {
+	return get(ctx, s, "SaveGroup", func() (int64, error) {
+		return s.Storage.SaveGroup(ctx, group)
+	})
+}
+
+func (s *Storage) SaveGuest(ctx context.Context) (int64, error) {
+	return get(ctx, s, "SaveGuest", func() (int64, error) {
+		return s.Storage.SaveGuest(ctx)
+	})
+}
+
+func (s *Storage) SaveIdentityProvider(ctx context.Context, provider models.IdentityProvider) error {
+	return s.do(ctx, "SaveIdentityProvider", func() error {
+		return s.Storage.SaveIdentityProvider(ctx, provider)
+	})
+}
+
+func (s *Storage) SaveInvitation(ctx context.Context, invitation models.Invitation) (int64, error) {
+	return get(ctx, s, "SaveInvitation", func() (int64, error) {
+		return s.Storage.SaveInvitation(ctx, invitation)
+	})
+}
+
+func (s *Storage) SaveMFAChallenge(ctx context.Context, challenge models.MFAChallenge) error {
+	return s.do(ctx, "SaveMFAChallenge", func() error {
+		return s.Storage.SaveMFAChallenge(ctx, challenge)
+	})
+}
+
+func (s *Storage) SaveMagicLink(ctx context.Context, link models.MagicLink) error {
+	return s.do(ctx, "SaveMagicLink", func() error {
+		return s.Storage.SaveMagicLink(ctx, link)
+	})
+}
+
+func (s *Storage) SaveNotificationPreferences(ctx context.Context, prefs models.NotificationPreferences) error {
+	return s.do(ctx, "SaveNotificationPreferences", func() error {
+		return s.Storage.SaveNotificationPreferences(ctx, prefs)
+	})
+}
+
+func (s *Storage) SaveOrg(ctx context.Context, org models.Org, adminID int64) (int64, error) {
+	return get(ctx, s, "SaveOrg", func() (int64, error) {
+		return s.Storage.SaveOrg(ctx, org, adminID)
+	})
+}
+
+func (s *Storage) SaveOrgInvitation(ctx context.Context, invitation models.OrgInvitation) (int64, error) {
+	return get(ctx, s, "SaveOrgInvitation", func() (int64, error) {
+		return s.Storage.SaveOrgInvitation(ctx, invitation)
+	})
+}
+
+func (s *Storage) SavePasswordResetToken(ctx context.Context, token models.PasswordResetToken) error {
+	return s.do(ctx, "SavePasswordResetToken", func() error {
+		return s.Storage.SavePasswordResetToken(ctx, token)
+	})
+}
+
+func (s *Storage) SavePolicyRule(ctx context.Context, rule models.PolicyRule) (int64, error) {
+	return get(ctx, s, "SavePolicyRule", func() (int64, error) {
+		return s.Storage.SavePolicyRule(ctx, rule)
+	})
+}
+
+func (s *Storage) SaveRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	return s.do(ctx, "SaveRefreshToken", func() error {
+		return s.Storage.SaveRefreshToken(ctx, token)
+	})
+}
+
+func (s *Storage) SaveRole(ctx context.Context, role models.Role) (int64, error) {
+	return get(ctx, s, "SaveRole", func() (int64, error) {
+		return s.Storage.SaveRole(ctx, role)
+	})
+}
+
+func (s *Storage) SaveSMSCode(ctx context.Context, code models.SMSCode) error {
+	return s.do(ctx, "SaveSMSCode", func() error {
+		return s.Storage.SaveSMSCode(ctx, code)
+	})
+}
+
+func (s *Storage) SaveSession(ctx context.Context, session models.Session) error {
+	return s.do(ctx, "SaveSession", func() error {
+		return s.Storage.SaveSession(ctx, session)
+	})
+}
+
+func (s *Storage) SaveTOTP(ctx context.Context, totp models.TOTP) error {
+	return s.do(ctx, "SaveTOTP", func() error {
+		return s.Storage.SaveTOTP(ctx, totp)
+	})
+}
+
+func (s *Storage) SaveTokenIssuance(ctx context.Context, issuance models.TokenIssuance) error {
+	return s.do(ctx, "SaveTokenIssuance", func() error {
+		return s.Storage.SaveTokenIssuance(ctx, issuance)
+	})
+}
+
+func (s *Storage) SaveTrustedDevice(ctx context.Context, device models.TrustedDevice) error {
+	return s.do(ctx, "SaveTrustedDevice", func() error {
+		return s.Storage.SaveTrustedDevice(ctx, device)
+	})
+}
+
+func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte, profile models.UserProfile) (int64, error) {
+	return get(ctx, s, "SaveUser", func() (int64, error) {
+		return s.Storage.SaveUser(ctx, email, passHash, profile)
+	})
+}
+
+func (s *Storage) SaveUserIdentity(ctx context.Context, identity models.UserIdentity) error {
+	return s.do(ctx, "SaveUserIdentity", func() error {
+		return s.Storage.SaveUserIdentity(ctx, identity)
+	})
+}
+
+func (s *Storage) SaveUserSession(ctx context.Context, session models.UserSession) error {
+	return s.do(ctx, "SaveUserSession", func() error {
+		return s.Storage.SaveUserSession(ctx, session)
+	})
+}
+
+func (s *Storage) SaveWebAuthnCredential(ctx context.Context, credential models.WebAuthnCredential) error {
+	return s.do(ctx, "SaveWebAuthnCredential", func() error {
+		return s.Storage.SaveWebAuthnCredential(ctx, credential)
+	})
+}
+
+func (s *Storage) SaveWebAuthnSession(ctx context.Context, session models.WebAuthnSession) error {
+	return s.do(ctx, "SaveWebAuthnSession", func() error {
+		return s.Storage.SaveWebAuthnSession(ctx, session)
+	})
+}
+
+func (s *Storage) ScheduleUserDeletion(ctx context.Context, userID int64, at time.Time) error {
+	return s.do(ctx, "ScheduleUserDeletion", func() error {
+		return s.Storage.ScheduleUserDeletion(ctx, userID, at)
+	})
+}
+
+func (s *Storage) SealUserPII(ctx context.Context, seal func(string) string) (int, error) {
+	return get(ctx, s, "SealUserPII", func() (int, error) {
+		return s.Storage.SealUserPII(ctx, seal)
+	})
+}
+
+func (s *Storage) Session(ctx context.Context, tokenHash string) (models.Session, error) {
+	return get(ctx, s, "Session", func() (models.Session, error) {
+		return s.Storage.Session(ctx, tokenHash)
+	})
+}
+
+func (s *Storage) SetAdmin(ctx context.Context, userID int64, isAdmin bool) error {
+	return s.do(ctx, "SetAdmin", func() error {
+		return s.Storage.SetAdmin(ctx, userID, isAdmin)
+	})
+}
+
+func (s *Storage) SetAppMetadata(ctx context.Context, userID int64, md map[string]string) error {
+	return s.do(ctx, "SetAppMetadata", func() error {
+		return s.Storage.SetAppMetadata(ctx, userID, md)
+	})
+}
+
+func (s *Storage) SetAppStatus(ctx context.Context, appID int, status string, revokeTokens bool, adminID int64, at time.Time) ([]models.TokenIssuance, error) {
+	return get(ctx, s, "SetAppStatus", func() ([]models.TokenIssuance, error) {
+		return s.Storage.SetAppStatus(ctx, appID, status, revokeTokens, adminID, at)
+	})
+}
+
+func (s *Storage) SetEmailVerified(ctx context.Context, userID int64, email string) error {
+	return s.do(ctx, "SetEmailVerified", func() error {
+		return s.Storage.SetEmailVerified(ctx, userID, email)
+	})
+}
+
+func (s *Storage) SetOrgMemberRole(ctx context.Context, orgID int64, userID int64, role string, adminID int64, at time.Time) error {
+	return s.do(ctx, "SetOrgMemberRole", func() error {
+		return s.Storage.SetOrgMemberRole(ctx, orgID, userID, role, adminID, at)
+	})
+}
+
+func (s *Storage) SetPhone(ctx context.Context, userID int64, phone string) error {
+	return s.do(ctx, "SetPhone", func() error {
+		return s.Storage.SetPhone(ctx, userID, phone)
+	})
+}
+
+func (s *Storage) SetUserExpiry(ctx context.Context, userID int64, adminID int64, expiresAt *time.Time, at time.Time) error {
+	return s.do(ctx, "SetUserExpiry", func() error {
+		return s.Storage.SetUserExpiry(ctx, userID, adminID, expiresAt, at)
+	})
+}
+
+func (s *Storage) SetUserMetadata(ctx context.Context, userID int64, md map[string]string) error {
+	return s.do(ctx, "SetUserMetadata", func() error {
+		return s.Storage.SetUserMetadata(ctx, userID, md)
+	})
+}
+
+func (s *Storage) SetUserOrg(ctx context.Context, userID int64, orgID int64, adminID int64, at time.Time) error {
+	return s.do(ctx, "SetUserOrg", func() error {
+		return s.Storage.SetUserOrg(ctx, userID, orgID, adminID, at)
+	})
+}
+
+func (s *Storage) SetUsername(ctx context.Context, userID int64, username string) error {
+	return s.do(ctx, "SetUsername", func() error {
+		return s.Storage.SetUsername(ctx, userID, username)
+	})
+}
+
+func (s *Storage) SuspendUser(ctx context.Context, userID int64, adminID int64, at time.Time) error {
+	return s.do(ctx, "SuspendUser", func() error {
+		return s.Storage.SuspendUser(ctx, userID, adminID, at)
+	})
+}
+
+func (s *Storage) SyncEmailKeys(ctx context.Context) ([]int64, error) {
+	return get(ctx, s, "SyncEmailKeys", func() ([]int64, error) {
+		return s.Storage.SyncEmailKeys(ctx)
+	})
+}
+
+func (s *Storage) TOTP(ctx context.Context, userID int64) (models.TOTP, error) {
+	return get(ctx, s, "TOTP", func() (models.TOTP, error) {
+		return s.Storage.TOTP(ctx, userID)
+	})
+}
+
+func (s *Storage) TouchAPIKey(ctx context.Context, keyID int64, at time.Time) error {
+	return s.do(ctx, "TouchAPIKey", func() error {
+		return s.Storage.TouchAPIKey(ctx, keyID, at)
+	})
+}
+
+func (s *Storage) TouchDeviceAuthorization(ctx context.Context, id int64, polledAt time.Time) error {
+	return s.do(ctx, "TouchDeviceAuthorization", func() error {
+		return s.Storage.TouchDeviceAuthorization(ctx, id, polledAt)
+	})
+}
+
+func (s *Storage) TouchTrustedDevice(ctx context.Context, id int64, ip string, at time.Time) error {
+	return s.do(ctx, "TouchTrustedDevice", func() error {
+		return s.Storage.TouchTrustedDevice(ctx, id, ip, at)
+	})
+}
+
+func (s *Storage) TouchUserSession(ctx context.Context, familyID string, ip string, userAgent string, device string, at time.Time) error {
+	return s.do(ctx, "TouchUserSession", func() error {
+		return s.Storage.TouchUserSession(ctx, familyID, ip, userAgent, device, at)
+	})
+}
+
+func (s *Storage) TrustedDevice(ctx context.Context, tokenHash string) (models.TrustedDevice, error) {
+	return get(ctx, s, "TrustedDevice", func() (models.TrustedDevice, error) {
+		return s.Storage.TrustedDevice(ctx, tokenHash)
+	})
+}
+
+func (s *Storage) TrustedDevices(ctx context.Context, userID int64) ([]models.TrustedDevice, error) {
+	return get(ctx, s, "TrustedDevices", func() ([]models.TrustedDevice, error) {
+		return s.Storage.TrustedDevices(ctx, userID)
+	})
+}
+
+func (s *Storage) UnlockUser(ctx context.Context, userID int64) error {
+	return s.do(ctx, "UnlockUser", func() error {
+		return s.Storage.UnlockUser(ctx, userID)
+	})
+}
+
+func (s *Storage) UnsuspendUser(ctx context.Context, userID int64, adminID int64, at time.Time) error {
+	return s.do(ctx, "UnsuspendUser", func() error {
+		return s.Storage.UnsuspendUser(ctx, userID, adminID, at)
+	})
+}
+
+func (s *Storage) UpdateApp(ctx context.Context, app models.App, adminID int64, at time.Time) error {
+	return s.do(ctx, "UpdateApp", func() error {
+		return s.Storage.UpdateApp(ctx, app, adminID, at)
+	})
+}
+
+func (s *Storage) UpdateDeviceAuthorizationStatus(ctx context.Context, id int64, from models.DeviceAuthorizationStatus, to models.DeviceAuthorizationStatus, userID int64) error {
+	return s.do(ctx, "UpdateDeviceAuthorizationStatus", func() error {
+		return s.Storage.UpdateDeviceAuthorizationStatus(ctx, id, from, to, userID)
+	})
+}
+
+func (s *Storage) UpdateEmail(ctx context.Context, userID int64, email string) error {
+	return s.do(ctx, "UpdateEmail", func() error {
+		return s.Storage.UpdateEmail(ctx, userID, email)
+	})
+}
+
+func (s *Storage) UpdateGroup(ctx context.Context, group models.Group) error {
+	return s.do(ctx, "UpdateGroup", func() error {
+		return s.Storage.UpdateGroup(ctx, group)
+	})
+}
+
+func (s *Storage) UpdatePassword(ctx context.Context, userID int64, passHash []byte) error {
+	return s.do(ctx, "UpdatePassword", func() error {
+		return s.Storage.UpdatePassword(ctx, userID, passHash)
+	})
+}
+
+func (s *Storage) UpdateRole(ctx context.Context, role models.Role) error {
+	return s.do(ctx, "UpdateRole", func() error {
+		return s.Storage.UpdateRole(ctx, role)
+	})
+}
+
+func (s *Storage) UpdateUser(ctx context.Context, update models.UserUpdate) (models.User, error) {
+	return get(ctx, s, "UpdateUser", func() (models.User, error) {
+		return s.Storage.UpdateUser(ctx, update)
+	})
+}
+
+func (s *Storage) UpdateWebAuthnCredential(ctx context.Context, credentialID []byte, data []byte, usedAt time.Time) error {
+	return s.do(ctx, "UpdateWebAuthnCredential", func() error {
+		return s.Storage.UpdateWebAuthnCredential(ctx, credentialID, data, usedAt)
+	})
+}
+
+func (s *Storage) UpgradeGuest(ctx context.Context, userID int64, email string, passHash []byte) error {
+	return s.do(ctx, "UpgradeGuest", func() error {
+		return s.Storage.UpgradeGuest(ctx, userID, email, passHash)
+	})
+}
+
+func (s *Storage) UseEmailChangeToken(ctx context.Context, id int64) error {
+	return s.do(ctx, "UseEmailChangeToken", func() error {
+		return s.Storage.UseEmailChangeToken(ctx, id)
+	})
+}
+
+func (s *Storage) UseEmailVerificationToken(ctx context.Context, id int64) error {
+	return s.do(ctx, "UseEmailVerificationToken", func() error {
+		return s.Storage.UseEmailVerificationToken(ctx, id)
+	})
+}
+
+func (s *Storage) UseFederationState(ctx context.Context, id int64) error {
+	return s.do(ctx, "UseFederationState", func() error {
+		return s.Storage.UseFederationState(ctx, id)
+	})
+}
+
+func (s *Storage) UseMFAChallenge(ctx context.Context, id int64) error {
+	return s.do(ctx, "UseMFAChallenge", func() error {
+		return s.Storage.UseMFAChallenge(ctx, id)
+	})
+}
+
+func (s *Storage) UseMagicLink(ctx context.Context, id int64) error {
+	return s.do(ctx, "UseMagicLink", func() error {
+		return s.Storage.UseMagicLink(ctx, id)
+	})
+}
+
+func (s *Storage) UsePasswordResetToken(ctx context.Context, id int64) error {
+	return s.do(ctx, "UsePasswordResetToken", func() error {
+		return s.Storage.UsePasswordResetToken(ctx, id)
+	})
+}
+
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	return s.do(ctx, "UseRecoveryCode", func() error {
+		return s.Storage.UseRecoveryCode(ctx, userID, codeHash)
+	})
+}
+
+func (s *Storage) UseSMSCode(ctx context.Context, id int64) error {
+	return s.do(ctx, "UseSMSCode", func() error {
+		return s.Storage.UseSMSCode(ctx, id)
+	})
+}
+
+func (s *Storage) UseTOTPStep(ctx context.Context, userID int64, step int64) error {
+	return s.do(ctx, "UseTOTPStep", func() error {
+		return s.Storage.UseTOTPStep(ctx, userID, step)
+	})
+}
+
+func (s *Storage) UseWebAuthnSession(ctx context.Context, id int64) error {
+	return s.do(ctx, "UseWebAuthnSession", func() error {
+		return s.Storage.UseWebAuthnSession(ctx, id)
+	})
+}
+
+func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
+	return get(ctx, s, "User", func() (models.User, error) {
+		return s.Storage.User(ctx, email)
+	})
+}
+
+func (s *Storage) UserByID(ctx context.Context, userID int64) (models.User, error) {
+	return get(ctx, s, "UserByID", func() (models.User, error) {
+		return s.Storage.UserByID(ctx, userID)
+	})
+}
+
+func (s *Storage) UserByIDIncludingDeleted(ctx context.Context, userID int64) (models.User, error) {
+	return get(ctx, s, "UserByIDIncludingDeleted", func() (models.User, error) {
+		return s.Storage.UserByIDIncludingDeleted(ctx, userID)
+	})
+}
+
+func (s *Storage) UserByPhone(ctx context.Context, phone string) (models.User, error) {
+	return get(ctx, s, "UserByPhone", func() (models.User, error) {
+		return s.Storage.UserByPhone(ctx, phone)
+	})
+}
+
+func (s *Storage) UserByUsername(ctx context.Context, username string) (models.User, error) {
+	return get(ctx, s, "UserByUsername", func() (models.User, error) {
+		return s.Storage.UserByUsername(ctx, username)
+	})
+}
+
+func (s *Storage) UserGroups(ctx context.Context, userID int64) ([]models.Group, error) {
+	return get(ctx, s, "UserGroups", func() ([]models.Group, error) {
+		return s.Storage.UserGroups(ctx, userID)
+	})
+}
+
+func (s *Storage) UserIdentities(ctx context.Context, userID int64) ([]models.UserIdentity, error) {
+	return get(ctx, s, "UserIdentities", func() ([]models.UserIdentity, error) {
+		return s.Storage.UserIdentities(ctx, userID)
+	})
+}
+
+func (s *Storage) UserIdentity(ctx context.Context, provider string, subject string) (models.UserIdentity, error) {
+	return get(ctx, s, "UserIdentity", func() (models.UserIdentity, error) {
+		return s.Storage.UserIdentity(ctx, provider, subject)
+	})
+}
+
+func (s *Storage) UserRoles(ctx context.Context, userID int64, appID int) ([]models.Role, error) {
+	return get(ctx, s, "UserRoles", func() ([]models.Role, error) {
+		return s.Storage.UserRoles(ctx, userID, appID)
+	})
+}
+
+func (s *Storage) UserSession(ctx context.Context, id int64) (models.UserSession, error) {
+	return get(ctx, s, "UserSession", func() (models.UserSession, error) {
+		return s.Storage.UserSession(ctx, id)
+	})
+}
+
+func (s *Storage) UsersByIDs(ctx context.Context, ids []int64) ([]models.User, error) {
+	return get(ctx, s, "UsersByIDs", func() ([]models.User, error) {
+		return s.Storage.UsersByIDs(ctx, ids)
+	})
+}
+
+func (s *Storage) UsersDueForDeletion(ctx context.Context, now time.Time, limit int) ([]int64, error) {
+	return get(ctx, s, "UsersDueForDeletion", func() ([]int64, error) {
+		return s.Storage.UsersDueForDeletion(ctx, now, limit)
+	})
+}
+
+func (s *Storage) WebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error) {
+	return get(ctx, s, "WebAuthnCredentials", func() ([]models.WebAuthnCredential, error) {
+		return s.Storage.WebAuthnCredentials(ctx, userID)
+	})
+}
+
+func (s *Storage) WebAuthnSession(ctx context.Context, tokenHash string) (models.WebAuthnSession, error) {
+	return get(ctx, s, "WebAuthnSession", func() (models.WebAuthnSession, error) {
+		return s.Storage.WebAuthnSession(ctx, tokenHash)
+	})
+}
@@ -0,0 +1,151 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"sso/internal/storage/factory"
+	"sync"
+	"time"
+)
+
+// Policy is how calls failing with transient errors are retried.
+type Policy struct {
+	// MaxAttempts caps the attempts at a call, the first one included.
+	MaxAttempts int
+	// BaseDelay caps the wait before the first retry, and the cap doubles
+	// with every retry up to MaxDelay. Retries wait a random time up to the
+	// cap, so that calls which failed together do not retry together.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Budget is the share of calls which may be retried, so that retries do
+	// not pile onto a database which is overloaded rather than contended.
+	Budget float64
+}
+
+// Metrics counts the retries of the calls of each method, and the retries
+// not made because the budget ran out.
+type Metrics interface {
+	Retried(method string)
+	BudgetExhausted(method string)
+}
+
+// Storage retries the calls to the storage it wraps which fail with errors
+// the transient func tells apart, such as deadlocks or a locked SQLite
+// database, and with failures to reach the database. Such errors are raised
+// before the call changes anything, so every method is retried as a whole.
+type Storage struct {
+	factory.Storage
+	transient func(error) bool
+	policy    Policy
+	budget    *budget
+	metrics   Metrics
+}
+
+func New(storage factory.Storage, transient func(error) bool, policy Policy, metrics Metrics) *Storage {
+	return &Storage{
+		Storage:   storage,
+		transient: transient,
+		policy:    policy,
+		budget:    newBudget(policy.Budget),
+		metrics:   metrics,
+	}
+}
+
+func (s *Storage) do(ctx context.Context, method string, call func() error) error {
+	_, err := get(ctx, s, method, func() (struct{}, error) {
+		return struct{}{}, call()
+	})
+
+	return err
+}
+
+// get makes the call until it succeeds, fails with an error which is not
+// transient, runs out of attempts or budget, or ctx is done.
+func get[T any](ctx context.Context, s *Storage, method string, call func() (T, error)) (T, error) {
+	s.budget.deposit()
+
+	for attempt := 1; ; attempt++ {
+		v, err := call()
+		if err == nil || attempt >= s.policy.MaxAttempts || !s.retryable(ctx, err) {
+			return v, err
+		}
+		if !s.budget.withdraw() {
+			s.metrics.BudgetExhausted(method)
+			return v, err
+		}
+		s.metrics.Retried(method)
+
+		timer := time.NewTimer(s.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return v, err
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Storage) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// nothing was sent if the connection could not be made
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	return s.transient(err)
+}
+
+// backoff returns how long to wait before the retry after the attempt,
+// a random time up to the cap of the attempt.
+func (s *Storage) backoff(attempt int) time.Duration {
+	limit := s.policy.MaxDelay
+	if attempt < 32 {
+		limit = min(s.policy.BaseDelay<<(attempt-1), s.policy.MaxDelay)
+	}
+	if limit <= 0 {
+		return 0
+	}
+
+	return rand.N(limit)
+}
+
+// maxBudget caps the retries the budget saves up, so that a quiet spell is
+// not followed by a burst of retries.
+const maxBudget = 10
+
+// budget lets retries make up a share of the calls. Every call adds the
+// share to it and every retry takes one from it.
+type budget struct {
+	mu     sync.Mutex
+	share  float64
+	tokens float64
+}
+
+func newBudget(share float64) *budget {
+	return &budget{share: share, tokens: maxBudget}
+}
+
+func (b *budget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.tokens+b.share, maxBudget)
+}
+
+func (b *budget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+
+	return true
+}
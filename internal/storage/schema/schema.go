@@ -0,0 +1,137 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"net/url"
+	"os"
+	"sso/internal/storage/factory"
+	"sso/migrations"
+)
+
+// Migrator applies the migrations embedded into the binary to the database
+// of a storage driver.
+type Migrator struct {
+	m      *migrate.Migrate
+	source source.Driver
+}
+
+// Status tells which migration the database is at, and the latest one
+// there is.
+type Status struct {
+	// Version is the last applied migration, zero if none is.
+	Version uint
+	// Dirty is set if the last migration failed halfway, and has to be
+	// fixed by hand.
+	Dirty  bool
+	Latest uint
+}
+
+// New opens the database of the driver at the dsn, keeping track of the
+// applied migrations in the table, schema_migrations if empty.
+func New(cfg factory.Config, table string) (*Migrator, error) {
+	const op = "storage.schema.New"
+
+	if cfg.Driver == factory.DriverMemory {
+		return nil, fmt.Errorf("%s: the memory driver has no migrations", op)
+	}
+
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	databaseURL, err := DatabaseURL(cfg.Driver, cfg.DSN, table)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", src, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Migrator{m: m, source: src}, nil
+}
+
+// Up applies the migrations not applied yet, and returns whether there were
+// any.
+func (m *Migrator) Up() (bool, error) {
+	const op = "storage.schema.Up"
+
+	if err := m.m.Up(); err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+// Down rolls back the last applied migration, and returns whether there was
+// one.
+func (m *Migrator) Down() (bool, error) {
+	const op = "storage.schema.Down"
+
+	if err := m.m.Steps(-1); err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, migrate.ErrNilVersion) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+func (m *Migrator) Status() (Status, error) {
+	const op = "storage.schema.Status"
+
+	var status Status
+
+	version, dirty, err := m.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return Status{}, fmt.Errorf("%s: %w", op, err)
+	}
+	status.Version = version
+	status.Dirty = dirty
+
+	latest, err := m.source.First()
+	if err != nil {
+		return Status{}, fmt.Errorf("%s: %w", op, err)
+	}
+	for {
+		next, err := m.source.Next(latest)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				break
+			}
+			return Status{}, fmt.Errorf("%s: %w", op, err)
+		}
+		latest = next
+	}
+	status.Latest = latest
+
+	return status, nil
+}
+
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+
+	return errors.Join(srcErr, dbErr)
+}
+
+// DatabaseURL returns the URL migrate reaches the database of the driver at
+// the dsn with.
+func DatabaseURL(driver string, dsn string, table string) (string, error) {
+	switch driver {
+	case factory.DriverSQLite:
+		return fmt.Sprintf("sqlite3://%s?x-migrations-table=%s", dsn, url.QueryEscape(table)), nil
+	default:
+		return "", fmt.Errorf("unknown storage driver %q", driver)
+	}
+}
@@ -0,0 +1,278 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// userDataTables hold data of users that is deleted with their accounts.
+var userDataTables = []string{
+	"refresh_tokens",
+	"user_sessions",
+	"trusted_devices",
+	"device_authorizations",
+	"password_reset_tokens",
+	"email_verification_tokens",
+	"email_change_tokens",
+	"magic_links",
+	"sms_codes",
+	"user_totp",
+	"mfa_challenges",
+	"recovery_codes",
+	"webauthn_credentials",
+	"user_identities",
+	"consents",
+	"login_history",
+	"notification_preferences",
+	"user_roles",
+	"group_members",
+}
+
+// ScheduleUserDeletion sets when the account of the user is anonymized. It
+// fails with storage.ErrUserNotFound if there is no such user or the account
+// is already deleted.
+func (s *Storage) ScheduleUserDeletion(ctx context.Context, userID int64, at time.Time) error {
+	const op = "storage.sqlite.ScheduleUserDeletion"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users SET deletion_scheduled_at = ? WHERE id = ? AND deleted_at IS NULL",
+		at.UTC(), userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// CancelUserDeletion keeps the account of the user if it is not deleted
+// yet. It fails with storage.ErrUserNotFound otherwise.
+func (s *Storage) CancelUserDeletion(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.CancelUserDeletion"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users SET deletion_scheduled_at = NULL WHERE id = ? AND deleted_at IS NULL",
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// UsersDueForDeletion returns the ids of at most limit users whose accounts
+// are scheduled to be deleted by now.
+func (s *Storage) UsersDueForDeletion(ctx context.Context, now time.Time, limit int) ([]int64, error) {
+	const op = "storage.sqlite.UsersDueForDeletion"
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id FROM users
+		WHERE deletion_scheduled_at <= ? AND anonymized_at IS NULL
+		ORDER BY deletion_scheduled_at, id LIMIT ?`,
+		now.UTC(), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// AnonymizeUser deletes the personal data of the user and records the
+// deletion in the audit log in one transaction. The user row is kept with a
+// placeholder email, so that the audit log still refers to an existing
+// user, and the email can be registered again. It fails with
+// storage.ErrUserNotFound if the account is not scheduled for deletion or
+// is already anonymized.
+func (s *Storage) AnonymizeUser(ctx context.Context, userID int64, at time.Time) error {
+	const op = "storage.sqlite.AnonymizeUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE users SET email = 'deleted-' || id || '@deleted.invalid', email_key = NULL, pass_hash = x'',
+			username = NULL, phone = NULL, phone_verified = 0, email_verified = 0, is_admin = FALSE,
+			failed_logins = 0, failed_logins_since = NULL, locked_until = NULL, app_metadata = '{}',
+			user_metadata = '{}',
+			display_name = '', first_name = '', last_name = '', locale = '', avatar_url = '', last_login_ip = '',
+			last_login_user_agent = '',
+			token_version = token_version + 1, deleted_at = COALESCE(deleted_at, ?), anonymized_at = ?
+		WHERE id = ? AND deletion_scheduled_at IS NOT NULL AND anonymized_at IS NULL`,
+		at.UTC(), at.UTC(), userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	for _, table := range userDataTables {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = ?", userID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE invitations SET email = '' WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO audit_events(type, user_id, created_at) values(?,?,?)",
+		models.AuditAccountDeleted, userID, at.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// sessionTables hold the sessions of users, which end when an admin
+// deletes or suspends the user.
+var sessionTables = []string{
+	"refresh_tokens",
+	"user_sessions",
+	"trusted_devices",
+}
+
+// DeleteUser soft-deletes the user on behalf of the admin: the user can no
+// longer log in, their sessions end, and the account is anonymized at
+// purgeAt unless it is restored before. It fails with
+// storage.ErrUserNotFound if there is no such user or the user is already
+// deleted.
+func (s *Storage) DeleteUser(ctx context.Context, userID int64, adminID int64, at time.Time, purgeAt time.Time) error {
+	const op = "storage.sqlite.DeleteUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE users SET deleted_at = ?, deletion_scheduled_at = ?, token_version = token_version + 1
+		WHERE id = ? AND deleted_at IS NULL`,
+		at.UTC(), purgeAt.UTC(), userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	for _, table := range sessionTables {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = ?", userID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO audit_events(type, user_id, actor_id, created_at) values(?,?,?,?)",
+		models.AuditUserDeleted, userID, adminID, at.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RestoreUser undoes the deletion of the user by the admin. It fails with
+// storage.ErrUserNotFound if the user is not deleted or already anonymized.
+func (s *Storage) RestoreUser(ctx context.Context, userID int64, adminID int64, at time.Time) error {
+	const op = "storage.sqlite.RestoreUser"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE users SET deleted_at = NULL, deletion_scheduled_at = NULL
+		WHERE id = ? AND deleted_at IS NOT NULL AND anonymized_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO audit_events(type, user_id, actor_id, created_at) values(?,?,?,?)",
+		models.AuditUserRestored, userID, adminID, at.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// SetUserExpiry sets when the account of the user expires on behalf of the
+// admin, or makes it permanent if expiresAt is nil. An account disabled on
+// expiry can be used again once its expiry is moved into the future. It
+// fails with storage.ErrUserNotFound if there is no such user or the user is
+// deleted.
+func (s *Storage) SetUserExpiry(ctx context.Context, userID int64, adminID int64, expiresAt *time.Time, at time.Time) error {
+	const op = "storage.sqlite.SetUserExpiry"
+
+	var expires any
+	if expiresAt != nil {
+		expires = expiresAt.UTC()
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE users SET expires_at = ?, expired_at = NULL WHERE id = ? AND deleted_at IS NULL",
+		expires, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO audit_events(type, user_id, actor_id, created_at) values(?,?,?,?)",
+		models.AuditUserExpirySet, userID, adminID, at.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ExpiredUsers returns the ids of at most limit users whose accounts have
+// expired by now and are not disabled or deleted yet.
+func (s *Storage) ExpiredUsers(ctx context.Context, now time.Time, limit int) ([]int64, error) {
+	const op = "storage.sqlite.ExpiredUsers"
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id FROM users
+		WHERE expires_at <= ? AND expired_at IS NULL AND deleted_at IS NULL
+		ORDER BY expires_at, id LIMIT ?`,
+		now.UTC(), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// ExpireUser disables the expired account of the user: its sessions end
+// and it stays disabled until its expiry is extended. With purgeAt set, the
+// user is deleted too, as by DeleteUser, and anonymized at purgeAt. It fails
+// with storage.ErrUserNotFound if the account has not expired, as when its
+// expiry was extended meanwhile, or is already disabled or deleted.
+func (s *Storage) ExpireUser(ctx context.Context, userID int64, at time.Time, purgeAt time.Time) error {
+	const op = "storage.sqlite.ExpireUser"
+
+	var deletedAt, deletionScheduledAt any
+	if !purgeAt.IsZero() {
+		deletedAt, deletionScheduledAt = at.UTC(), purgeAt.UTC()
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE users SET expired_at = ?, deleted_at = ?, deletion_scheduled_at = ?,
+		token_version = token_version + 1
+		WHERE id = ? AND expires_at <= ? AND expired_at IS NULL AND deleted_at IS NULL`,
+		at.UTC(), deletedAt, deletionScheduledAt, userID, at.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	for _, table := range sessionTables {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = ?", userID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO audit_events(type, user_id, created_at) values(?,?,?)",
+		models.AuditUserExpired, userID, at.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
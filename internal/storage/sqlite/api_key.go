@@ -0,0 +1,172 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+const apiKeyColumns = "id, app_id, name, prefix, key_hash, created_by, created_at, last_used_at, revoked_at"
+
+// SaveAPIKey stores the hashed key of the app on behalf of the admin who
+// created it and returns its new id.
+func (s *Storage) SaveAPIKey(ctx context.Context, key models.APIKey) (int64, error) {
+	const op = "storage.sqlite.SaveAPIKey"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO api_keys(app_id, name, prefix, key_hash, created_by, created_at) values(?,?,?,?,?,?)",
+		key.AppID, key.Name, key.Prefix, key.KeyHash, key.CreatedBy, key.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = saveAdminEvent(ctx, tx, models.AuditAPIKeyCreated, 0, key.AppID, key.CreatedBy, key.Prefix, key.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// APIKeys returns the keys of the app, the revoked ones included, newest
+// first.
+func (s *Storage) APIKeys(ctx context.Context, appID int) ([]models.APIKey, error) {
+	const op = "storage.sqlite.APIKeys"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE app_id = ? ORDER BY id DESC",
+		appID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// APIKeyByHash returns the key with the hash, even if it is revoked. It
+// fails with storage.ErrAPIKeyNotFound if there is no such key.
+func (s *Storage) APIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	const op = "storage.sqlite.APIKeyByHash"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = ?", hash)
+
+	key, err := scanAPIKey(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+		}
+
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// TouchAPIKey records that the key was used at the time. Uses within a
+// minute of the recorded one are not written, so busy keys do not cost a
+// write per request.
+func (s *Storage) TouchAPIKey(ctx context.Context, keyID int64, at time.Time) error {
+	const op = "storage.sqlite.TouchAPIKey"
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE api_keys SET last_used_at = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)",
+		at.UTC(), keyID, at.Add(-time.Minute).UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeAPIKey revokes the key of the app on behalf of the admin. It fails
+// with storage.ErrAPIKeyNotFound if the app has no such key that is not
+// revoked already.
+func (s *Storage) RevokeAPIKey(ctx context.Context, appID int, keyID int64, adminID int64, at time.Time) error {
+	const op = "storage.sqlite.RevokeAPIKey"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var prefix string
+	err = tx.QueryRowContext(ctx,
+		"UPDATE api_keys SET revoked_at = ? WHERE id = ? AND app_id = ? AND revoked_at IS NULL RETURNING prefix",
+		at.UTC(), keyID, appID,
+	).Scan(&prefix)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = saveAdminEvent(ctx, tx, models.AuditAPIKeyRevoked, 0, appID, adminID, prefix, at); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func scanAPIKey(row scanner) (models.APIKey, error) {
+	var key models.APIKey
+	var lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(
+		&key.ID, &key.AppID, &key.Name, &key.Prefix, &key.KeyHash,
+		&key.CreatedBy, &key.CreatedAt, &lastUsedAt, &revokedAt,
+	)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return key, nil
+}
@@ -0,0 +1,379 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"sso/internal/domain/models"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+// SaveApp creates the app with the hash of its secret on behalf of the admin and returns its new id. It
+// fails with storage.ErrAppExists if an app has the name or the audience already.
+func (s *Storage) SaveApp(ctx context.Context, app models.App, adminID int64) (int, error) {
+	const op = "storage.sqlite.SaveApp"
+
+	claims, err := json.Marshal(appClaims(app))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO apps(org_id, name, secret, secret_hashed, signing_key, audience, token_format, scopes,
+		grant_types, redirect_uris, claims, access_token_ttl, refresh_token_ttl, third_party, login_quota,
+		registration_quota, status, created_at)
+		values(?,?,?,1,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		app.OrgID, app.Name, app.SecretHash, app.SigningKey, app.Audience, app.TokenFormat,
+		strings.Join(app.Scopes, " "), strings.Join(app.GrantTypes, " "), strings.Join(app.RedirectURIs, " "), string(claims),
+		int64(app.AccessTokenTTL/time.Second), int64(app.RefreshTokenTTL/time.Second), app.ThirdParty,
+		app.LoginQuota, app.RegistrationQuota, app.Status, app.CreatedAt.UTC(),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = saveAdminEvent(ctx, tx, models.AuditAppCreated, 0, int(id), adminID, app.Name, app.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int(id), nil
+}
+
+// UpdateApp replaces the settings of the app with the id on behalf of the
+// admin, leaving its secret and its org as is. It fails with storage.ErrAppNotFound if
+// there is no such app, and with storage.ErrAppExists if another app has
+// the name or the audience.
+func (s *Storage) UpdateApp(ctx context.Context, app models.App, adminID int64, at time.Time) error {
+	const op = "storage.sqlite.UpdateApp"
+
+	claims, err := json.Marshal(appClaims(app))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE apps SET name = ?, audience = ?, token_format = ?, scopes = ?, grant_types = ?, redirect_uris = ?,
+		claims = ?, access_token_ttl = ?, refresh_token_ttl = ?, third_party = ?, login_quota = ?,
+		registration_quota = ? WHERE id = ?`,
+		app.Name, app.Audience, app.TokenFormat, strings.Join(app.Scopes, " "), strings.Join(app.GrantTypes, " "),
+		strings.Join(app.RedirectURIs, " "), string(claims),
+		int64(app.AccessTokenTTL/time.Second), int64(app.RefreshTokenTTL/time.Second), app.ThirdParty,
+		app.LoginQuota, app.RegistrationQuota, app.ID,
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	if err = saveAdminEvent(ctx, tx, models.AuditAppUpdated, 0, app.ID, adminID, app.Name, at); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetAppStatus gives the app the status on behalf of the admin. With
+// revokeTokens, the refresh tokens of the app are revoked along with the
+// access tokens it was issued, whose unexpired issuances are returned for
+// their ids to be denied. It fails with storage.ErrAppNotFound if there is no
+// such app.
+func (s *Storage) SetAppStatus(
+	ctx context.Context,
+	appID int,
+	status string,
+	revokeTokens bool,
+	adminID int64,
+	at time.Time,
+) ([]models.TokenIssuance, error) {
+	const op = "storage.sqlite.SetAppStatus"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var disabledAt *time.Time
+	if status == models.AppStatusDisabled {
+		utc := at.UTC()
+		disabledAt = &utc
+	}
+
+	// a disabled app that stays disabled keeps the time it was disabled at
+	res, err := tx.ExecContext(ctx,
+		"UPDATE apps SET status = ?, disabled_at = CASE WHEN status = ? THEN disabled_at ELSE ? END WHERE id = ?",
+		status, status, disabledAt, appID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	var revoked []models.TokenIssuance
+	if revokeTokens {
+		_, err = tx.ExecContext(ctx,
+			"UPDATE refresh_tokens SET revoked_at = ? WHERE app_id = ? AND revoked_at IS NULL",
+			at.UTC(), appID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		rows, err := tx.QueryContext(ctx,
+			`SELECT id, user_id, issued_at, expires_at FROM token_issuances
+			WHERE app_id = ? AND revoked_at IS NULL AND expires_at > ?`,
+			appID, at.UTC(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			issuance := models.TokenIssuance{AppID: appID}
+			var userID sql.NullInt64
+			if err = rows.Scan(&issuance.ID, &userID, &issuance.IssuedAt, &issuance.ExpiresAt); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			issuance.UserID = userID.Int64
+			revoked = append(revoked, issuance)
+		}
+		if err = rows.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE token_issuances SET revoked_at = ? WHERE app_id = ? AND revoked_at IS NULL",
+			at.UTC(), appID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = saveAdminEvent(ctx, tx, models.AuditAppStatusChanged, 0, appID, adminID, status, at); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revoked, nil
+}
+
+// RotateAppSecret replaces the secret of the app with the one with the hash,
+// and its signing key with signingKey, on behalf of the admin. The replaced
+// secret is still accepted until previousExpiresAt, if it is set. It fails
+// with storage.ErrAppNotFound if there is no such app.
+func (s *Storage) RotateAppSecret(
+	ctx context.Context,
+	appID int,
+	hash string,
+	signingKey string,
+	previousExpiresAt *time.Time,
+	adminID int64,
+	at time.Time,
+) error {
+	const op = "storage.sqlite.RotateAppSecret"
+
+	var expiresAt any
+	if previousExpiresAt != nil {
+		expiresAt = previousExpiresAt.UTC()
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE apps SET previous_secret_hash = CASE WHEN ? IS NULL THEN '' ELSE secret END,
+		previous_secret_expires_at = ?, secret = ?, signing_key = ? WHERE id = ?`,
+		expiresAt, expiresAt, hash, signingKey, appID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	if err = saveAdminEvent(ctx, tx, models.AuditAppSecretRotated, 0, appID, adminID, "", at); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// HashAppSecrets replaces the plaintext secrets of apps created before
+// secrets were hashed with their hash, and returns how many it replaced.
+// These apps signed their tokens with the secret, so those without a signing
+// key get a random one: the tokens they have issued no longer verify.
+func (s *Storage) HashAppSecrets(ctx context.Context) (int, error) {
+	const op = "storage.sqlite.HashAppSecrets"
+
+	type appSecret struct {
+		id         int
+		secret     string
+		signingKey string
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, secret, signing_key FROM apps WHERE secret_hashed = 0")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var plain []appSecret
+	for rows.Next() {
+		var app appSecret
+		if err = rows.Scan(&app.id, &app.secret, &app.signingKey); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		plain = append(plain, app)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	rows.Close()
+
+	for _, app := range plain {
+		if app.signingKey == "" {
+			if app.signingKey, _, err = randtoken.New(); err != nil {
+				return 0, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		_, err = s.db.ExecContext(ctx,
+			"UPDATE apps SET secret = ?, signing_key = ?, secret_hashed = 1 WHERE id = ? AND secret_hashed = 0",
+			randtoken.Hash(app.secret), app.signingKey, app.id,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return len(plain), nil
+}
+
+// Apps returns the apps of the org, or every app if orgID is zero, the
+// disabled ones included, ordered by id.
+func (s *Storage) Apps(ctx context.Context, orgID int64) ([]models.App, error) {
+	const op = "storage.sqlite.Apps"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+appColumns+" FROM apps WHERE ? = 0 OR org_id = ? ORDER BY id",
+		orgID, orgID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var apps []models.App
+	for rows.Next() {
+		app, err := scanApp(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		apps = append(apps, app)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return apps, nil
+}
+
+// AppIncludingDisabled returns the app with the id even if it is disabled.
+// It fails with storage.ErrAppNotFound if there is no such app.
+func (s *Storage) AppIncludingDisabled(ctx context.Context, appID int) (models.App, error) {
+	const op = "storage.sqlite.AppIncludingDisabled"
+
+	app, err := scanApp(s.db.QueryRowContext(ctx, "SELECT "+appColumns+" FROM apps WHERE id = ?", appID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return app, nil
+}
+
+// appClaims returns the static claims of the app to store, an empty object
+// rather than null if it has none.
+func appClaims(app models.App) map[string]any {
+	if app.Claims == nil {
+		return map[string]any{}
+	}
+
+	return app.Claims
+}
@@ -0,0 +1,41 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"time"
+)
+
+// AppStats counts the logins to the app from the time from up to to, the
+// users who logged in and the access tokens issued for the app, from the
+// audit log and the token issuances.
+func (s *Storage) AppStats(ctx context.Context, appID int, from time.Time, to time.Time) (models.AppStats, error) {
+	const op = "storage.sqlite.AppStats"
+
+	stats := models.AppStats{AppID: appID, From: from, To: to}
+
+	err := s.db.QueryRowContext(ctx,
+		`SELECT
+			COUNT(*) FILTER (WHERE type = ?),
+			COUNT(*) FILTER (WHERE type = ?),
+			COUNT(DISTINCT user_id) FILTER (WHERE type = ?)
+		FROM audit_events
+		WHERE app_id = ? AND type IN (?, ?) AND created_at >= ? AND created_at < ?`,
+		models.AuditLoginSucceeded, models.AuditLoginFailed, models.AuditLoginSucceeded,
+		appID, models.AuditLoginSucceeded, models.AuditLoginFailed, from.UTC(), to.UTC(),
+	).Scan(&stats.Logins, &stats.FailedLogins, &stats.UniqueUsers)
+	if err != nil {
+		return models.AppStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM token_issuances WHERE app_id = ? AND issued_at >= ? AND issued_at < ?",
+		appID, from.UTC(), to.UTC(),
+	).Scan(&stats.TokensIssued)
+	if err != nil {
+		return models.AppStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,129 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sso/internal/domain/models"
+	"time"
+)
+
+func (s *Storage) SaveAuditEvent(ctx context.Context, event models.AuditEvent) error {
+	const op = "storage.sqlite.SaveAuditEvent"
+
+	var userID, appID, actorID sql.NullInt64
+	if event.UserID != 0 {
+		userID = sql.NullInt64{Int64: event.UserID, Valid: true}
+	}
+	if event.AppID != 0 {
+		appID = sql.NullInt64{Int64: int64(event.AppID), Valid: true}
+	}
+	if event.ActorID != 0 {
+		actorID = sql.NullInt64{Int64: event.ActorID, Valid: true}
+	}
+
+	var tokenID, detail sql.NullString
+	if event.TokenID != "" {
+		tokenID = sql.NullString{String: event.TokenID, Valid: true}
+	}
+	if event.Detail != "" {
+		detail = sql.NullString{String: event.Detail, Valid: true}
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO audit_events(type, user_id, app_id, actor_id, token_id, detail, created_at) values(?,?,?,?,?,?,?)",
+		event.Type, userID, appID, actorID, tokenID, detail, event.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AuditEvents returns the events matching the filter, oldest first.
+func (s *Storage) AuditEvents(ctx context.Context, filter models.AuditEventFilter) ([]models.AuditEvent, error) {
+	const op = "storage.sqlite.AuditEvents"
+
+	query := `SELECT id, type, COALESCE(user_id, 0), COALESCE(app_id, 0), COALESCE(actor_id, 0),
+		COALESCE(token_id, ''), COALESCE(detail, ''), created_at
+		FROM audit_events WHERE id > ?`
+	args := []any{filter.AfterID}
+	if filter.Type != "" {
+		query += " AND type = ?"
+		args = append(args, filter.Type)
+	}
+	if filter.UserID != 0 {
+		query += " AND user_id = ?"
+		args = append(args, filter.UserID)
+	}
+	if filter.OrgID != 0 {
+		query += ` AND (user_id IN (SELECT id FROM users WHERE org_id = ?)
+			OR app_id IN (SELECT id FROM apps WHERE org_id = ?))`
+		args = append(args, filter.OrgID, filter.OrgID)
+	}
+	query += " ORDER BY id"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		err = rows.Scan(
+			&event.ID,
+			&event.Type,
+			&event.UserID,
+			&event.AppID,
+			&event.ActorID,
+			&event.TokenID,
+			&event.Detail,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// saveAdminEvent records the change the admin made in the audit log,
+// leaving user_id and app_id empty when they are zero, as for global roles.
+func saveAdminEvent(
+	ctx context.Context,
+	tx *sql.Tx,
+	eventType models.AuditEventType,
+	userID int64,
+	appID int,
+	adminID int64,
+	detail string,
+	at time.Time,
+) error {
+	var app sql.NullInt64
+	if appID != 0 {
+		app = sql.NullInt64{Int64: int64(appID), Valid: true}
+	}
+	var user sql.NullInt64
+	if userID != 0 {
+		user = sql.NullInt64{Int64: userID, Valid: true}
+	}
+
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO audit_events(type, user_id, app_id, actor_id, detail, created_at) values(?,?,?,?,?,?)",
+		eventType, user, app, adminID, detail, at.UTC(),
+	)
+
+	return err
+}
@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+// SaveClaimMapping creates the mapping or replaces the target of an existing
+// mapping of the same source claim.
+func (s *Storage) SaveClaimMapping(ctx context.Context, mapping models.ClaimMapping) error {
+	const op = "storage.sqlite.SaveClaimMapping"
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO claim_mappings(app_id, source, target) values(?,?,?)
+		ON CONFLICT (app_id, source) DO UPDATE SET target = excluded.target`,
+		mapping.AppID, mapping.Source, mapping.Target,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ClaimMappings(ctx context.Context, appID int) ([]models.ClaimMapping, error) {
+	const op = "storage.sqlite.ClaimMappings"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT app_id, source, target FROM claim_mappings WHERE app_id = ? ORDER BY source", appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var mappings []models.ClaimMapping
+	for rows.Next() {
+		var mapping models.ClaimMapping
+		if err = rows.Scan(&mapping.AppID, &mapping.Source, &mapping.Target); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		mappings = append(mappings, mapping)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return mappings, nil
+}
+
+func (s *Storage) DeleteClaimMapping(ctx context.Context, appID int, source string) error {
+	const op = "storage.sqlite.DeleteClaimMapping"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM claim_mappings WHERE app_id = ? AND source = ?", appID, source)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrClaimMappingNotFound)
+	}
+
+	return nil
+}
+
+// appClaimMappings returns the claim mappings of the app by source claim.
+func (s *Storage) appClaimMappings(ctx context.Context, appID int) (map[string]string, error) {
+	mappings, err := s.ClaimMappings(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]string, len(mappings))
+	for _, mapping := range mappings {
+		byName[mapping.Source] = mapping.Target
+	}
+
+	return byName, nil
+}
@@ -0,0 +1,118 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+)
+
+const consentColumns = "c.user_id, c.app_id, a.name, c.scopes, c.granted_at"
+
+// SaveConsent stores the consent of the user to the app, replacing the
+// previous one.
+func (s *Storage) SaveConsent(ctx context.Context, consent models.Consent) error {
+	const op = "storage.sqlite.SaveConsent"
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO consents(user_id, app_id, scopes, granted_at) values(?,?,?,?)
+		ON CONFLICT (user_id, app_id) DO UPDATE SET scopes = excluded.scopes, granted_at = excluded.granted_at`,
+		consent.UserID,
+		consent.AppID,
+		strings.Join(consent.Scopes, " "),
+		consent.GrantedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Consent(ctx context.Context, userID int64, appID int) (models.Consent, error) {
+	const op = "storage.sqlite.Consent"
+
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+consentColumns+" FROM consents c JOIN apps a ON a.id = c.app_id WHERE c.user_id = ? AND c.app_id = ?",
+		userID,
+		appID,
+	)
+
+	consent, err := scanConsent(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Consent{}, fmt.Errorf("%s: %w", op, storage.ErrConsentNotFound)
+		}
+		return models.Consent{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return consent, nil
+}
+
+// Consents returns the consents of the user, the most recently granted
+// first.
+func (s *Storage) Consents(ctx context.Context, userID int64) ([]models.Consent, error) {
+	const op = "storage.sqlite.Consents"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+consentColumns+" FROM consents c JOIN apps a ON a.id = c.app_id WHERE c.user_id = ? ORDER BY c.granted_at DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var consents []models.Consent
+	for rows.Next() {
+		consent, err := scanConsent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		consents = append(consents, consent)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return consents, nil
+}
+
+// DeleteConsent withdraws the consent of the user to the app. It fails with
+// storage.ErrConsentNotFound if the user has not consented to the app.
+func (s *Storage) DeleteConsent(ctx context.Context, userID int64, appID int) error {
+	const op = "storage.sqlite.DeleteConsent"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM consents WHERE user_id = ? AND app_id = ?", userID, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrConsentNotFound)
+	}
+
+	return nil
+}
+
+func scanConsent(row scanner) (models.Consent, error) {
+	var consent models.Consent
+	var scopes string
+	err := row.Scan(
+		&consent.UserID,
+		&consent.AppID,
+		&consent.AppName,
+		&scopes,
+		&consent.GrantedAt,
+	)
+	consent.Scopes = strings.Fields(scopes)
+
+	return consent, err
+}
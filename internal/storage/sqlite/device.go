@@ -0,0 +1,136 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveDeviceAuthorization(ctx context.Context, auth models.DeviceAuthorization) error {
+	const op = "storage.sqlite.SaveDeviceAuthorization"
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO device_authorizations(device_code_hash, user_code, app_id, status, expires_at, created_at)
+		values(?,?,?,?,?,?)`,
+		auth.DeviceCodeHash, auth.UserCode, auth.AppID, auth.Status, auth.ExpiresAt.UTC(), auth.CreatedAt.UTC(),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeviceAuthorization(ctx context.Context, deviceCodeHash string) (models.DeviceAuthorization, error) {
+	const op = "storage.sqlite.DeviceAuthorization"
+
+	auth, err := s.deviceAuthorization(ctx, "device_code_hash", deviceCodeHash)
+	if err != nil {
+		return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return auth, nil
+}
+
+func (s *Storage) DeviceAuthorizationByUserCode(ctx context.Context, userCode string) (models.DeviceAuthorization, error) {
+	const op = "storage.sqlite.DeviceAuthorizationByUserCode"
+
+	auth, err := s.deviceAuthorization(ctx, "user_code", userCode)
+	if err != nil {
+		return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return auth, nil
+}
+
+// UpdateDeviceAuthorizationStatus moves the authorization from one status to
+// another. It fails with storage.ErrDeviceCodeNotFound if the authorization
+// is not in the from status anymore.
+func (s *Storage) UpdateDeviceAuthorizationStatus(
+	ctx context.Context,
+	id int64,
+	from models.DeviceAuthorizationStatus,
+	to models.DeviceAuthorizationStatus,
+	userID int64,
+) error {
+	const op = "storage.sqlite.UpdateDeviceAuthorizationStatus"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE device_authorizations SET status = ?, user_id = COALESCE(NULLIF(?, 0), user_id) WHERE id = ? AND status = ?",
+		to, userID, id, from,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) TouchDeviceAuthorization(ctx context.Context, id int64, polledAt time.Time) error {
+	const op = "storage.sqlite.TouchDeviceAuthorization"
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE device_authorizations SET last_polled_at = ? WHERE id = ?",
+		polledAt.UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) deviceAuthorization(ctx context.Context, column string, value string) (models.DeviceAuthorization, error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT id, device_code_hash, user_code, app_id, COALESCE(user_id, 0), status,
+		expires_at, last_polled_at, created_at
+		FROM device_authorizations WHERE `+column+` = ?`)
+	if err != nil {
+		return models.DeviceAuthorization{}, err
+	}
+
+	row := stmt.QueryRowContext(ctx, value)
+
+	var auth models.DeviceAuthorization
+	var lastPolledAt sql.NullTime
+	err = row.Scan(
+		&auth.ID,
+		&auth.DeviceCodeHash,
+		&auth.UserCode,
+		&auth.AppID,
+		&auth.UserID,
+		&auth.Status,
+		&auth.ExpiresAt,
+		&lastPolledAt,
+		&auth.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.DeviceAuthorization{}, storage.ErrDeviceCodeNotFound
+		}
+		return models.DeviceAuthorization{}, err
+	}
+
+	if lastPolledAt.Valid {
+		auth.LastPolledAt = &lastPolledAt.Time
+	}
+
+	return auth, nil
+}
@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveEmailChangeToken(ctx context.Context, token models.EmailChangeToken) error {
+	const op = "storage.sqlite.SaveEmailChangeToken"
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO email_change_tokens(token_hash, user_id, new_email, expires_at, created_at) values(?,?,?,?,?)",
+		token.TokenHash, token.UserID, token.NewEmail, token.ExpiresAt.UTC(), token.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) EmailChangeToken(ctx context.Context, tokenHash string) (models.EmailChangeToken, error) {
+	const op = "storage.sqlite.EmailChangeToken"
+
+	stmt, err := s.db.PrepareContext(ctx, `SELECT id, token_hash, user_id, new_email, expires_at, created_at, used_at
+		FROM email_change_tokens WHERE token_hash = ?`)
+	if err != nil {
+		return models.EmailChangeToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, tokenHash)
+
+	var token models.EmailChangeToken
+	var usedAt sql.NullTime
+	err = row.Scan(&token.ID, &token.TokenHash, &token.UserID, &token.NewEmail, &token.ExpiresAt, &token.CreatedAt, &usedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.EmailChangeToken{}, fmt.Errorf("%s: %w", op, storage.ErrEmailChangeTokenNotFound)
+		}
+		return models.EmailChangeToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+
+	return token, nil
+}
+
+// UseEmailChangeToken marks the token as used. It fails with
+// storage.ErrEmailChangeTokenUsed if the token has already been used.
+func (s *Storage) UseEmailChangeToken(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.UseEmailChangeToken"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE email_change_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL",
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrEmailChangeTokenUsed)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+)
+
+// SyncEmailKeys brings the email keys of users in line with the email
+// normalizer, as after its configuration has changed. Users whose email
+// would get the key of another user keep their key, and their ids are
+// returned, so that admins can merge the accounts.
+func (s *Storage) SyncEmailKeys(ctx context.Context) ([]int64, error) {
+	const op = "storage.sqlite.SyncEmailKeys"
+
+	type userEmail struct {
+		id    int64
+		email string
+		key   string
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, email, COALESCE(email_key, '') FROM users WHERE is_guest = 0 AND anonymized_at IS NULL ORDER BY id",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var stale []userEmail
+	for rows.Next() {
+		var user userEmail
+		if err = rows.Scan(&user.id, &user.email, &user.key); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if key := s.emails.Key(user.email); key != user.key {
+			user.key = key
+			stale = append(stale, user)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	rows.Close()
+
+	var conflicts []int64
+	for _, user := range stale {
+		_, err = s.db.ExecContext(ctx, "UPDATE users SET email_key = ? WHERE id = ?", user.key, user.id)
+		if err != nil {
+			var sqliteErr sqlite3.Error
+			if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+				conflicts = append(conflicts, user.id)
+				continue
+			}
+
+			return conflicts, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return conflicts, nil
+}
@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveEmailVerificationToken(ctx context.Context, token models.EmailVerificationToken) error {
+	const op = "storage.sqlite.SaveEmailVerificationToken"
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO email_verification_tokens(token_hash, user_id, email, expires_at, created_at) values(?,?,?,?,?)",
+		token.TokenHash, token.UserID, token.Email, token.ExpiresAt.UTC(), token.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) EmailVerificationToken(ctx context.Context, tokenHash string) (models.EmailVerificationToken, error) {
+	const op = "storage.sqlite.EmailVerificationToken"
+
+	stmt, err := s.db.PrepareContext(ctx, `SELECT id, token_hash, user_id, email, expires_at, created_at, used_at
+		FROM email_verification_tokens WHERE token_hash = ?`)
+	if err != nil {
+		return models.EmailVerificationToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, tokenHash)
+
+	var token models.EmailVerificationToken
+	var usedAt sql.NullTime
+	err = row.Scan(&token.ID, &token.TokenHash, &token.UserID, &token.Email, &token.ExpiresAt, &token.CreatedAt, &usedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.EmailVerificationToken{}, fmt.Errorf("%s: %w", op, storage.ErrVerificationTokenNotFound)
+		}
+		return models.EmailVerificationToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+
+	return token, nil
+}
+
+// UseEmailVerificationToken marks the token as used. It fails with
+// storage.ErrVerificationTokenUsed if the token has already been used.
+func (s *Storage) UseEmailVerificationToken(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.UseEmailVerificationToken"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE email_verification_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL",
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrVerificationTokenUsed)
+	}
+
+	return nil
+}
@@ -0,0 +1,296 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+func (s *Storage) SaveFederationState(ctx context.Context, state models.FederationState) error {
+	const op = "storage.sqlite.SaveFederationState"
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO federation_states(state_hash, provider, app_id, scopes, nonce, code_verifier, redirect_uri,
+		client_state, expires_at, created_at) values(?,?,?,?,?,?,?,?,?,?)`,
+		state.StateHash, state.Provider, state.AppID, strings.Join(state.Scopes, " "),
+		state.Nonce, state.CodeVerifier, state.Redirect.URI, state.Redirect.State,
+		state.ExpiresAt.UTC(), state.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) FederationState(ctx context.Context, stateHash string) (models.FederationState, error) {
+	const op = "storage.sqlite.FederationState"
+
+	stmt, err := s.db.PrepareContext(ctx, `SELECT id, state_hash, provider, app_id, scopes, nonce, code_verifier, redirect_uri,
+		client_state, expires_at, created_at, used_at FROM federation_states WHERE state_hash = ?`)
+	if err != nil {
+		return models.FederationState{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, stateHash)
+
+	var state models.FederationState
+	var scopes string
+	var usedAt sql.NullTime
+	err = row.Scan(
+		&state.ID,
+		&state.StateHash,
+		&state.Provider,
+		&state.AppID,
+		&scopes,
+		&state.Nonce,
+		&state.CodeVerifier,
+		&state.Redirect.URI,
+		&state.Redirect.State,
+		&state.ExpiresAt,
+		&state.CreatedAt,
+		&usedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.FederationState{}, fmt.Errorf("%s: %w", op, storage.ErrFederationStateNotFound)
+		}
+		return models.FederationState{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	state.Scopes = strings.Fields(scopes)
+	if usedAt.Valid {
+		state.UsedAt = &usedAt.Time
+	}
+
+	return state, nil
+}
+
+// UseFederationState marks the login as returned from the provider. It fails
+// with storage.ErrFederationStateUsed if the state has already been used.
+func (s *Storage) UseFederationState(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.UseFederationState"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE federation_states SET used_at = ? WHERE id = ? AND used_at IS NULL",
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrFederationStateUsed)
+	}
+
+	return nil
+}
+
+// SaveUserIdentity links the user to the identity. It fails with
+// storage.ErrUserIdentityExists if the identity is linked already.
+func (s *Storage) SaveUserIdentity(ctx context.Context, identity models.UserIdentity) error {
+	const op = "storage.sqlite.SaveUserIdentity"
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO user_identities(user_id, provider, subject, email, created_at) values(?,?,?,?,?)",
+		identity.UserID, identity.Provider, identity.Subject, identity.Email, identity.CreatedAt.UTC(),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserIdentityExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) UserIdentity(ctx context.Context, provider string, subject string) (models.UserIdentity, error) {
+	const op = "storage.sqlite.UserIdentity"
+
+	stmt, err := s.db.PrepareContext(ctx, `SELECT id, user_id, provider, subject, email, created_at
+		FROM user_identities WHERE provider = ? AND subject = ?`)
+	if err != nil {
+		return models.UserIdentity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, provider, subject)
+
+	var identity models.UserIdentity
+	err = row.Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.UserIdentity{}, fmt.Errorf("%s: %w", op, storage.ErrUserIdentityNotFound)
+		}
+		return models.UserIdentity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return identity, nil
+}
+
+// UserIdentities returns the identities linked to the user, oldest first.
+func (s *Storage) UserIdentities(ctx context.Context, userID int64) ([]models.UserIdentity, error) {
+	const op = "storage.sqlite.UserIdentities"
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, user_id, provider, subject, email, created_at
+		FROM user_identities WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var identities []models.UserIdentity
+	for rows.Next() {
+		var identity models.UserIdentity
+		err = rows.Scan(
+			&identity.ID,
+			&identity.UserID,
+			&identity.Provider,
+			&identity.Subject,
+			&identity.Email,
+			&identity.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		identities = append(identities, identity)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return identities, nil
+}
+
+// SaveIdentityProvider creates the provider or replaces the provider with
+// the same name.
+func (s *Storage) SaveIdentityProvider(ctx context.Context, provider models.IdentityProvider) error {
+	const op = "storage.sqlite.SaveIdentityProvider"
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO identity_providers(name, issuer, client_id, client_secret, scopes,
+			subject_claim, email_claim, email_verified_claim, name_claim, created_at)
+		values(?,?,?,?,?,?,?,?,?,?)
+		ON CONFLICT (name) DO UPDATE SET issuer = excluded.issuer, client_id = excluded.client_id,
+			client_secret = excluded.client_secret, scopes = excluded.scopes,
+			subject_claim = excluded.subject_claim, email_claim = excluded.email_claim,
+			email_verified_claim = excluded.email_verified_claim, name_claim = excluded.name_claim`,
+		provider.Name, provider.Issuer, provider.ClientID, provider.ClientSecret, strings.Join(provider.Scopes, " "),
+		provider.Claims.Subject, provider.Claims.Email, provider.Claims.EmailVerified, provider.Claims.Name,
+		provider.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+const identityProviderColumns = `id, name, issuer, client_id, client_secret, scopes,
+	subject_claim, email_claim, email_verified_claim, name_claim, created_at`
+
+func (s *Storage) IdentityProvider(ctx context.Context, name string) (models.IdentityProvider, error) {
+	const op = "storage.sqlite.IdentityProvider"
+
+	stmt, err := s.db.PrepareContext(ctx, "SELECT "+identityProviderColumns+" FROM identity_providers WHERE name = ?")
+	if err != nil {
+		return models.IdentityProvider{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	provider, err := scanIdentityProvider(stmt.QueryRowContext(ctx, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.IdentityProvider{}, fmt.Errorf("%s: %w", op, storage.ErrIdentityProviderNotFound)
+		}
+		return models.IdentityProvider{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return provider, nil
+}
+
+func (s *Storage) IdentityProviders(ctx context.Context) ([]models.IdentityProvider, error) {
+	const op = "storage.sqlite.IdentityProviders"
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+identityProviderColumns+" FROM identity_providers ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var providers []models.IdentityProvider
+	for rows.Next() {
+		provider, err := scanIdentityProvider(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		providers = append(providers, provider)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return providers, nil
+}
+
+func (s *Storage) DeleteIdentityProvider(ctx context.Context, name string) error {
+	const op = "storage.sqlite.DeleteIdentityProvider"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM identity_providers WHERE name = ?", name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrIdentityProviderNotFound)
+	}
+
+	return nil
+}
+
+func scanIdentityProvider(row scanner) (models.IdentityProvider, error) {
+	var provider models.IdentityProvider
+	var scopes string
+	err := row.Scan(
+		&provider.ID,
+		&provider.Name,
+		&provider.Issuer,
+		&provider.ClientID,
+		&provider.ClientSecret,
+		&scopes,
+		&provider.Claims.Subject,
+		&provider.Claims.Email,
+		&provider.Claims.EmailVerified,
+		&provider.Claims.Name,
+		&provider.CreatedAt,
+	)
+	if err != nil {
+		return models.IdentityProvider{}, err
+	}
+
+	provider.Scopes = strings.Fields(scopes)
+
+	return provider, nil
+}
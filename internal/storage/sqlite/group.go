@@ -0,0 +1,456 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// groupQuery selects groups with a row per role, see scanGroups.
+const groupQuery = `SELECT g.id, g.name, g.description, g.created_at, COALESCE(r.name, '') FROM groups g
+	LEFT JOIN group_roles gr ON gr.group_id = g.id
+	LEFT JOIN roles r ON r.id = gr.role_id`
+
+// SaveGroup creates the group with its roles and returns its id. It fails
+// with storage.ErrGroupExists if a group has the name already, and with
+// storage.ErrRoleNotFound if a role does not exist.
+func (s *Storage) SaveGroup(ctx context.Context, group models.Group) (int64, error) {
+	const op = "storage.sqlite.SaveGroup"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO groups(name, description, created_at) values(?,?,?)",
+		group.Name, group.Description, group.CreatedAt.UTC(),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrGroupExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = setGroupRoles(ctx, tx, id, group.Roles); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// UpdateGroup sets the description and the roles of the group with the
+// name. It fails with storage.ErrGroupNotFound if there is no such group,
+// and with storage.ErrRoleNotFound if a role does not exist.
+func (s *Storage) UpdateGroup(ctx context.Context, group models.Group) error {
+	const op = "storage.sqlite.UpdateGroup"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		"UPDATE groups SET description = ? WHERE name = ? RETURNING id",
+		group.Description, group.Name,
+	).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM group_roles WHERE group_id = ?", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = setGroupRoles(ctx, tx, id, group.Roles); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// setGroupRoles gives the group the roles, failing with
+// storage.ErrRoleNotFound if one does not exist.
+func setGroupRoles(ctx context.Context, tx *sql.Tx, groupID int64, roles []string) error {
+	for _, role := range roles {
+		res, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO group_roles(group_id, role_id)
+			SELECT ?, id FROM roles WHERE name = ?`,
+			groupID, role,
+		)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("%w: %s", storage.ErrRoleNotFound, role)
+		}
+	}
+
+	return nil
+}
+
+// Group returns the group with the name. It fails with
+// storage.ErrGroupNotFound if there is no such group.
+func (s *Storage) Group(ctx context.Context, name string) (models.Group, error) {
+	const op = "storage.sqlite.Group"
+
+	rows, err := s.db.QueryContext(ctx, groupQuery+" WHERE g.name = ? ORDER BY r.name", name)
+	if err != nil {
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	groups, err := scanGroups(rows)
+	if err != nil {
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(groups) == 0 {
+		return models.Group{}, fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+
+	return groups[0], nil
+}
+
+// Groups returns every group, ordered by name.
+func (s *Storage) Groups(ctx context.Context) ([]models.Group, error) {
+	const op = "storage.sqlite.Groups"
+
+	rows, err := s.db.QueryContext(ctx, groupQuery+" ORDER BY g.name, r.name")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	groups, err := scanGroups(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return groups, nil
+}
+
+// DeleteGroup deletes the group with the name, whose members lose the roles
+// they had through it. It fails with storage.ErrGroupNotFound if there is no
+// such group.
+func (s *Storage) DeleteGroup(ctx context.Context, name string) error {
+	const op = "storage.sqlite.DeleteGroup"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, "DELETE FROM groups WHERE name = ? RETURNING id", name).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, table := range []string{"group_roles", "group_members"} {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE group_id = ?", id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AssignGroupRole gives the group with the name the role on behalf of the
+// admin, unless the group carries it already. It fails with
+// storage.ErrGroupNotFound or storage.ErrRoleNotFound if there is no such
+// group or role.
+func (s *Storage) AssignGroupRole(ctx context.Context, group string, role string, adminID int64, at time.Time) error {
+	const op = "storage.sqlite.AssignGroupRole"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var groupID int64
+	if err = tx.QueryRowContext(ctx, "SELECT id FROM groups WHERE name = ?", group).Scan(&groupID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var roleID int64
+	if err = tx.QueryRowContext(ctx, "SELECT id FROM roles WHERE name = ?", role).Scan(&roleID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT OR IGNORE INTO group_roles(group_id, role_id) values(?,?)",
+		groupID, roleID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return nil
+	}
+
+	err = saveAdminEvent(ctx, tx, models.AuditGroupRoleAssigned, 0, 0, adminID, group+"/"+role, at)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeGroupRole takes the role from the group with the name on behalf of
+// the admin, if the group carries it. It fails with storage.ErrGroupNotFound
+// if there is no such group.
+func (s *Storage) RevokeGroupRole(ctx context.Context, group string, role string, adminID int64, at time.Time) error {
+	const op = "storage.sqlite.RevokeGroupRole"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var groupID int64
+	if err = tx.QueryRowContext(ctx, "SELECT id FROM groups WHERE name = ?", group).Scan(&groupID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"DELETE FROM group_roles WHERE group_id = ? AND role_id = (SELECT id FROM roles WHERE name = ?)",
+		groupID, role,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return nil
+	}
+
+	err = saveAdminEvent(ctx, tx, models.AuditGroupRoleRevoked, 0, 0, adminID, group+"/"+role, at)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AddGroupMember adds the user to the group with the name, unless the user
+// is a member already. It fails with storage.ErrGroupNotFound or
+// storage.ErrUserNotFound if there is no such group or user.
+func (s *Storage) AddGroupMember(ctx context.Context, group string, userID int64, at time.Time) error {
+	const op = "storage.sqlite.AddGroupMember"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var groupID int64
+	if err = tx.QueryRowContext(ctx, "SELECT id FROM groups WHERE name = ?", group).Scan(&groupID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM users WHERE id = ? AND deleted_at IS NULL)",
+		userID,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT OR IGNORE INTO group_members(group_id, user_id, created_at) values(?,?,?)",
+		groupID, userID, at.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RemoveGroupMember removes the user from the group with the name, if the
+// user is a member. It fails with storage.ErrGroupNotFound if there is no
+// such group.
+func (s *Storage) RemoveGroupMember(ctx context.Context, group string, userID int64) error {
+	const op = "storage.sqlite.RemoveGroupMember"
+
+	var groupID int64
+	if err := s.db.QueryRowContext(ctx, "SELECT id FROM groups WHERE name = ?", group).Scan(&groupID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		"DELETE FROM group_members WHERE group_id = ? AND user_id = ?",
+		groupID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GroupMembers returns the ids of the members of the group with the name,
+// ascending. It fails with storage.ErrGroupNotFound if there is no such
+// group.
+func (s *Storage) GroupMembers(ctx context.Context, group string) ([]int64, error) {
+	const op = "storage.sqlite.GroupMembers"
+
+	var groupID int64
+	if err := s.db.QueryRowContext(ctx, "SELECT id FROM groups WHERE name = ?", group).Scan(&groupID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT user_id FROM group_members WHERE group_id = ? ORDER BY user_id",
+		groupID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// UserGroups returns the groups of the user, ordered by name.
+func (s *Storage) UserGroups(ctx context.Context, userID int64) ([]models.Group, error) {
+	const op = "storage.sqlite.UserGroups"
+
+	rows, err := s.db.QueryContext(ctx,
+		groupQuery+" WHERE g.id IN (SELECT group_id FROM group_members WHERE user_id = ?) ORDER BY g.name, r.name",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	groups, err := scanGroups(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return groups, nil
+}
+
+// scanGroups reads the rows of a groupQuery ordered by group, and closes
+// them.
+func scanGroups(rows *sql.Rows) ([]models.Group, error) {
+	defer rows.Close()
+
+	var groups []models.Group
+	for rows.Next() {
+		var group models.Group
+		var role string
+		if err := rows.Scan(&group.ID, &group.Name, &group.Description, &group.CreatedAt, &role); err != nil {
+			return nil, err
+		}
+
+		if len(groups) == 0 || groups[len(groups)-1].ID != group.ID {
+			groups = append(groups, group)
+		}
+		if role != "" {
+			last := &groups[len(groups)-1]
+			last.Roles = append(last.Roles, role)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
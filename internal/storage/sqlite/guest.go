@@ -0,0 +1,61 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"sso/internal/storage"
+)
+
+// SaveGuest creates an anonymous user with a random placeholder email and
+// no password.
+func (s *Storage) SaveGuest(ctx context.Context) (int64, error) {
+	const op = "storage.sqlite.SaveGuest"
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO users(email, pass_hash, is_guest)
+		values('guest-' || lower(hex(randomblob(16))) || '@guest.invalid', x'', 1)`,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// UpgradeGuest turns the guest into a full user with the email and the
+// password, keeping the id. It fails with storage.ErrUserExists if the email
+// is taken and with storage.ErrUserNotFound if there is no such guest.
+func (s *Storage) UpgradeGuest(ctx context.Context, userID int64, email string, passHash []byte) error {
+	const op = "storage.sqlite.UpgradeGuest"
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE users SET email = ?, email_key = ?, pass_hash = ?, email_verified = FALSE, is_guest = 0
+		WHERE id = ? AND is_guest = 1`,
+		email, s.emails.Key(email), passHash, userID,
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
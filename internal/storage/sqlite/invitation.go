@@ -0,0 +1,148 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveInvitation(ctx context.Context, invitation models.Invitation) (int64, error) {
+	const op = "storage.sqlite.SaveInvitation"
+
+	var appID sql.NullInt64
+	if invitation.AppID != 0 {
+		appID = sql.NullInt64{Int64: int64(invitation.AppID), Valid: true}
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO invitations(token_hash, email, is_admin, app_id, invited_by, expires_at, created_at)
+		values(?,?,?,?,?,?,?)`,
+		invitation.TokenHash,
+		invitation.Email,
+		invitation.IsAdmin,
+		appID,
+		invitation.InvitedBy,
+		invitation.ExpiresAt.UTC(),
+		invitation.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Invitation returns the invitation of the token, accepted or not.
+func (s *Storage) Invitation(ctx context.Context, tokenHash string) (models.Invitation, error) {
+	const op = "storage.sqlite.Invitation"
+
+	row := s.db.QueryRowContext(ctx,
+		`SELECT id, token_hash, email, is_admin, app_id, invited_by, expires_at, created_at, accepted_at, user_id
+		FROM invitations WHERE token_hash = ?`,
+		tokenHash,
+	)
+
+	var invitation models.Invitation
+	var appID, userID sql.NullInt64
+	var acceptedAt sql.NullTime
+	err := row.Scan(
+		&invitation.ID,
+		&invitation.TokenHash,
+		&invitation.Email,
+		&invitation.IsAdmin,
+		&appID,
+		&invitation.InvitedBy,
+		&invitation.ExpiresAt,
+		&invitation.CreatedAt,
+		&acceptedAt,
+		&userID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Invitation{}, fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+		}
+		return models.Invitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	invitation.AppID = int(appID.Int64)
+	invitation.UserID = userID.Int64
+	if acceptedAt.Valid {
+		invitation.AcceptedAt = &acceptedAt.Time
+	}
+
+	return invitation, nil
+}
+
+// AcceptInvitation marks the invitation as accepted and registers the
+// invited user with a verified email in one transaction. It fails with
+// storage.ErrInvitationUsed if the invitation has already been accepted and
+// with storage.ErrUserExists if the email is taken.
+func (s *Storage) AcceptInvitation(ctx context.Context, id int64, passHash []byte, at time.Time) (int64, error) {
+	const op = "storage.sqlite.AcceptInvitation"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE invitations SET accepted_at = ? WHERE id = ? AND accepted_at IS NULL",
+		at.UTC(), id,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrInvitationUsed)
+	}
+
+	var email string
+	if err = tx.QueryRowContext(ctx, "SELECT email FROM invitations WHERE id = ?", id).Scan(&email); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err = tx.ExecContext(ctx,
+		`INSERT INTO users(email, email_key, pass_hash, email_verified, is_admin)
+		SELECT email, ?, ?, 1, is_admin FROM invitations WHERE id = ?`,
+		s.emails.Key(email), passHash, id,
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE invitations SET user_id = ? WHERE id = ?", userID, id); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userID, nil
+}
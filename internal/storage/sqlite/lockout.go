@@ -0,0 +1,85 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/storage"
+	"time"
+)
+
+// RecordFailedLogin counts a failed login of the user and returns the number
+// of failed logins since the first one after windowStart. Failures before
+// windowStart are forgotten.
+func (s *Storage) RecordFailedLogin(ctx context.Context, userID int64, at time.Time, windowStart time.Time) (int, error) {
+	const op = "storage.sqlite.RecordFailedLogin"
+
+	row := s.db.QueryRowContext(ctx,
+		`UPDATE users SET
+			failed_logins = CASE WHEN failed_logins_since IS NULL OR failed_logins_since < ?1
+				THEN 1 ELSE failed_logins + 1 END,
+			failed_logins_since = CASE WHEN failed_logins_since IS NULL OR failed_logins_since < ?1
+				THEN ?2 ELSE failed_logins_since END
+		WHERE id = ?3
+		RETURNING failed_logins`,
+		windowStart.UTC(), at.UTC(), userID,
+	)
+
+	var failed int
+	if err := row.Scan(&failed); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return failed, nil
+}
+
+// LockUser locks the user out until the given time and clears the failed
+// logins.
+func (s *Storage) LockUser(ctx context.Context, userID int64, until time.Time) error {
+	const op = "storage.sqlite.LockUser"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users SET locked_until = ?, failed_logins = 0, failed_logins_since = NULL WHERE id = ?",
+		until.UTC(), userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// UnlockUser lifts the lockout of the user and clears the failed logins.
+func (s *Storage) UnlockUser(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.UnlockUser"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users SET locked_until = NULL, failed_logins = 0, failed_logins_since = NULL WHERE id = ?",
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
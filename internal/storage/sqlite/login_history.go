@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"strings"
+)
+
+// RecordLogin sets the login as the last one of the user and adds it to the
+// login history, which keeps the latest keep logins of the user.
+func (s *Storage) RecordLogin(ctx context.Context, login models.Login, keep int) error {
+	const op = "storage.sqlite.RecordLogin"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE users SET last_login_at = ?, last_login_ip = ?, last_login_user_agent = ? WHERE id = ?",
+		login.CreatedAt.UTC(), login.IP, login.UserAgent, login.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO login_history(user_id, app_id, ip, user_agent, device, amr, created_at)
+		values(?,?,?,?,?,?,?)`,
+		login.UserID,
+		login.AppID,
+		login.IP,
+		login.UserAgent,
+		login.Device,
+		strings.Join(login.Methods, " "),
+		login.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM login_history WHERE user_id = ? AND id NOT IN (
+			SELECT id FROM login_history WHERE user_id = ? ORDER BY id DESC LIMIT ?
+		)`,
+		login.UserID, login.UserID, keep,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// LoginHistory returns the latest logins of the user, the most recent
+// first.
+func (s *Storage) LoginHistory(ctx context.Context, userID int64, limit int) ([]models.Login, error) {
+	const op = "storage.sqlite.LoginHistory"
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, user_id, app_id, ip, user_agent, device, amr, created_at
+		FROM login_history WHERE user_id = ? ORDER BY id DESC LIMIT ?`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var logins []models.Login
+	for rows.Next() {
+		var login models.Login
+		var amr string
+		err = rows.Scan(
+			&login.ID,
+			&login.UserID,
+			&login.AppID,
+			&login.IP,
+			&login.UserAgent,
+			&login.Device,
+			&amr,
+			&login.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		login.Methods = strings.Fields(amr)
+		logins = append(logins, login)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return logins, nil
+}
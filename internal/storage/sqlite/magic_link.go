@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+func (s *Storage) SaveMagicLink(ctx context.Context, link models.MagicLink) error {
+	const op = "storage.sqlite.SaveMagicLink"
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO magic_links(token_hash, user_id, app_id, scopes, expires_at, created_at) values(?,?,?,?,?,?)",
+		link.TokenHash, link.UserID, link.AppID, strings.Join(link.Scopes, " "),
+		link.ExpiresAt.UTC(), link.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) MagicLink(ctx context.Context, tokenHash string) (models.MagicLink, error) {
+	const op = "storage.sqlite.MagicLink"
+
+	stmt, err := s.db.PrepareContext(ctx, `SELECT id, token_hash, user_id, app_id, scopes, expires_at, created_at, used_at
+		FROM magic_links WHERE token_hash = ?`)
+	if err != nil {
+		return models.MagicLink{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, tokenHash)
+
+	var link models.MagicLink
+	var scopes string
+	var usedAt sql.NullTime
+	err = row.Scan(
+		&link.ID,
+		&link.TokenHash,
+		&link.UserID,
+		&link.AppID,
+		&scopes,
+		&link.ExpiresAt,
+		&link.CreatedAt,
+		&usedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MagicLink{}, fmt.Errorf("%s: %w", op, storage.ErrMagicLinkNotFound)
+		}
+		return models.MagicLink{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	link.Scopes = strings.Fields(scopes)
+	if usedAt.Valid {
+		link.UsedAt = &usedAt.Time
+	}
+
+	return link, nil
+}
+
+// UseMagicLink marks the link as redeemed. It fails with
+// storage.ErrMagicLinkUsed if the link has already been redeemed.
+func (s *Storage) UseMagicLink(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.UseMagicLink"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE magic_links SET used_at = ? WHERE id = ? AND used_at IS NULL",
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrMagicLinkUsed)
+	}
+
+	return nil
+}
+
+// CountMagicLinks returns the number of links issued to the user since the
+// given time.
+func (s *Storage) CountMagicLinks(ctx context.Context, userID int64, since time.Time) (int, error) {
+	const op = "storage.sqlite.CountMagicLinks"
+
+	var count int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM magic_links WHERE user_id = ? AND created_at >= ?",
+		userID, since.UTC(),
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,225 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+// SaveTOTP starts a TOTP enrollment, replacing an unconfirmed one. It fails
+// with storage.ErrTOTPExists if the user has already confirmed TOTP.
+func (s *Storage) SaveTOTP(ctx context.Context, totp models.TOTP) error {
+	const op = "storage.sqlite.SaveTOTP"
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO user_totp(user_id, secret, created_at) values(?,?,?)
+		ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, last_used_step = 0, created_at = excluded.created_at
+		WHERE user_totp.confirmed_at IS NULL`,
+		totp.UserID, totp.Secret, totp.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrTOTPExists)
+	}
+
+	return nil
+}
+
+func (s *Storage) TOTP(ctx context.Context, userID int64) (models.TOTP, error) {
+	const op = "storage.sqlite.TOTP"
+
+	stmt, err := s.db.PrepareContext(ctx, "SELECT user_id, secret, last_used_step, created_at, confirmed_at FROM user_totp WHERE user_id = ?")
+	if err != nil {
+		return models.TOTP{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, userID)
+
+	var totp models.TOTP
+	var confirmedAt sql.NullTime
+	err = row.Scan(&totp.UserID, &totp.Secret, &totp.LastUsedStep, &totp.CreatedAt, &confirmedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.TOTP{}, fmt.Errorf("%s: %w", op, storage.ErrTOTPNotFound)
+		}
+		return models.TOTP{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if confirmedAt.Valid {
+		totp.ConfirmedAt = &confirmedAt.Time
+	}
+
+	return totp, nil
+}
+
+// ConfirmTOTP enables TOTP for the user with the code of the step. It fails
+// with storage.ErrTOTPNotFound if there is no unconfirmed enrollment.
+func (s *Storage) ConfirmTOTP(ctx context.Context, userID int64, step int64) error {
+	const op = "storage.sqlite.ConfirmTOTP"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE user_totp SET confirmed_at = ?, last_used_step = ? WHERE user_id = ? AND confirmed_at IS NULL",
+		time.Now().UTC(), step, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrTOTPNotFound)
+	}
+
+	return nil
+}
+
+// UseTOTPStep records that the code of the step was used. It fails with
+// storage.ErrTOTPCodeUsed if a code of the same or a later step was used.
+func (s *Storage) UseTOTPStep(ctx context.Context, userID int64, step int64) error {
+	const op = "storage.sqlite.UseTOTPStep"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE user_totp SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?",
+		step, userID, step,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrTOTPCodeUsed)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteTOTP(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.DeleteTOTP"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM user_totp WHERE user_id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrTOTPNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveMFAChallenge(ctx context.Context, challenge models.MFAChallenge) error {
+	const op = "storage.sqlite.SaveMFAChallenge"
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO mfa_challenges(token_hash, user_id, app_id, scopes, amr, expires_at, created_at) values(?,?,?,?,?,?,?)",
+		challenge.TokenHash, challenge.UserID, challenge.AppID, strings.Join(challenge.Scopes, " "),
+		strings.Join(challenge.AuthMethods, " "), challenge.ExpiresAt.UTC(), challenge.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) MFAChallenge(ctx context.Context, tokenHash string) (models.MFAChallenge, error) {
+	const op = "storage.sqlite.MFAChallenge"
+
+	stmt, err := s.db.PrepareContext(ctx, `SELECT id, token_hash, user_id, app_id, scopes, amr, attempts, expires_at, created_at, used_at
+		FROM mfa_challenges WHERE token_hash = ?`)
+	if err != nil {
+		return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, tokenHash)
+
+	var challenge models.MFAChallenge
+	var scopes, amr string
+	var usedAt sql.NullTime
+	err = row.Scan(
+		&challenge.ID,
+		&challenge.TokenHash,
+		&challenge.UserID,
+		&challenge.AppID,
+		&scopes,
+		&amr,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+		&challenge.CreatedAt,
+		&usedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrMFAChallengeNotFound)
+		}
+		return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	challenge.Scopes = strings.Fields(scopes)
+	challenge.AuthMethods = strings.Fields(amr)
+	if usedAt.Valid {
+		challenge.UsedAt = &usedAt.Time
+	}
+
+	return challenge, nil
+}
+
+// FailMFAChallenge counts a wrong code entered for the challenge.
+func (s *Storage) FailMFAChallenge(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.FailMFAChallenge"
+
+	_, err := s.db.ExecContext(ctx, "UPDATE mfa_challenges SET attempts = attempts + 1 WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UseMFAChallenge marks the challenge as completed. It fails with
+// storage.ErrMFAChallengeUsed if the challenge has already been completed.
+func (s *Storage) UseMFAChallenge(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.UseMFAChallenge"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE mfa_challenges SET used_at = ? WHERE id = ? AND used_at IS NULL",
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrMFAChallengeUsed)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+// NotificationPreferences returns the notification preferences of the user.
+// It fails with storage.ErrNotificationPreferencesNotFound if the user has
+// not set them.
+func (s *Storage) NotificationPreferences(ctx context.Context, userID int64) (models.NotificationPreferences, error) {
+	const op = "storage.sqlite.NotificationPreferences"
+
+	prefs := models.NotificationPreferences{UserID: userID}
+	err := s.db.QueryRowContext(ctx,
+		"SELECT security_emails, login_alerts, marketing, updated_at FROM notification_preferences WHERE user_id = ?",
+		userID,
+	).Scan(&prefs.SecurityEmails, &prefs.LoginAlerts, &prefs.Marketing, &prefs.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.NotificationPreferences{}, fmt.Errorf("%s: %w", op, storage.ErrNotificationPreferencesNotFound)
+		}
+		return models.NotificationPreferences{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return prefs, nil
+}
+
+// SaveNotificationPreferences stores the notification preferences of the
+// user, replacing the previous ones.
+func (s *Storage) SaveNotificationPreferences(ctx context.Context, prefs models.NotificationPreferences) error {
+	const op = "storage.sqlite.SaveNotificationPreferences"
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO notification_preferences(user_id, security_emails, login_alerts, marketing, updated_at)
+		values(?,?,?,?,?)
+		ON CONFLICT (user_id) DO UPDATE SET security_emails = excluded.security_emails,
+		login_alerts = excluded.login_alerts, marketing = excluded.marketing, updated_at = excluded.updated_at`,
+		prefs.UserID,
+		prefs.SecurityEmails,
+		prefs.LoginAlerts,
+		prefs.Marketing,
+		prefs.UpdatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
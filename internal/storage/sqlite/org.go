@@ -0,0 +1,146 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strconv"
+	"time"
+)
+
+// SaveOrg creates the org on behalf of the admin and returns its new id. It
+// fails with storage.ErrOrgExists if an org has the name already.
+func (s *Storage) SaveOrg(ctx context.Context, org models.Org, adminID int64) (int64, error) {
+	const op = "storage.sqlite.SaveOrg"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO orgs(name, created_at) values(?,?)",
+		org.Name, org.CreatedAt.UTC(),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrOrgExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = saveAdminEvent(ctx, tx, models.AuditOrgCreated, 0, 0, adminID, org.Name, org.CreatedAt); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Orgs returns every org ordered by id.
+func (s *Storage) Orgs(ctx context.Context) ([]models.Org, error) {
+	const op = "storage.sqlite.Orgs"
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, created_at FROM orgs ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var orgs []models.Org
+	for rows.Next() {
+		var org models.Org
+		if err = rows.Scan(&org.ID, &org.Name, &org.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		orgs = append(orgs, org)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return orgs, nil
+}
+
+// Org returns the org with the id. It fails with storage.ErrOrgNotFound if
+// there is no such org.
+func (s *Storage) Org(ctx context.Context, orgID int64) (models.Org, error) {
+	const op = "storage.sqlite.Org"
+
+	var org models.Org
+	err := s.db.QueryRowContext(ctx, "SELECT id, name, created_at FROM orgs WHERE id = ?", orgID).
+		Scan(&org.ID, &org.Name, &org.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Org{}, fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+		}
+		return models.Org{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return org, nil
+}
+
+// SetUserOrg moves the user to the org on behalf of the admin. Tokens of the
+// user are revoked, as they name the org the user belonged to. It fails with
+// storage.ErrUserNotFound if there is no such user.
+func (s *Storage) SetUserOrg(ctx context.Context, userID int64, orgID int64, adminID int64, at time.Time) error {
+	const op = "storage.sqlite.SetUserOrg"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE users SET org_id = ?, token_version = token_version + 1 WHERE id = ? AND org_id != ?",
+		orgID, userID, orgID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		var exists bool
+		err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", userID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if !exists {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		// already in the org
+		return nil
+	}
+
+	err = saveAdminEvent(ctx, tx, models.AuditUserOrgChanged, userID, 0, adminID, strconv.FormatInt(orgID, 10), at)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
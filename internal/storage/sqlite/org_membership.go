@@ -0,0 +1,315 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strconv"
+	"time"
+)
+
+// SaveOrgInvitation saves the invitation and returns its id.
+func (s *Storage) SaveOrgInvitation(ctx context.Context, invitation models.OrgInvitation) (int64, error) {
+	const op = "storage.sqlite.SaveOrgInvitation"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO org_invitations(token_hash, org_id, email, is_admin, invited_by, expires_at, created_at)
+		values(?,?,?,?,?,?,?)`,
+		invitation.TokenHash,
+		invitation.OrgID,
+		invitation.Email,
+		invitation.Role == models.OrgRoleAdmin,
+		invitation.InvitedBy,
+		invitation.ExpiresAt.UTC(),
+		invitation.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = saveAdminEvent(ctx, tx, models.AuditOrgInvitationCreated, 0, 0, invitation.InvitedBy,
+		strconv.FormatInt(invitation.OrgID, 10), invitation.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// OrgInvitation returns the invitation of the token, answered or not.
+func (s *Storage) OrgInvitation(ctx context.Context, tokenHash string) (models.OrgInvitation, error) {
+	const op = "storage.sqlite.OrgInvitation"
+
+	row := s.db.QueryRowContext(ctx,
+		`SELECT id, token_hash, org_id, email, is_admin, invited_by, expires_at, created_at, accepted_at,
+		declined_at, user_id FROM org_invitations WHERE token_hash = ?`,
+		tokenHash,
+	)
+
+	var invitation models.OrgInvitation
+	var isAdmin bool
+	var acceptedAt, declinedAt sql.NullTime
+	var userID sql.NullInt64
+	err := row.Scan(
+		&invitation.ID,
+		&invitation.TokenHash,
+		&invitation.OrgID,
+		&invitation.Email,
+		&isAdmin,
+		&invitation.InvitedBy,
+		&invitation.ExpiresAt,
+		&invitation.CreatedAt,
+		&acceptedAt,
+		&declinedAt,
+		&userID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OrgInvitation{}, fmt.Errorf("%s: %w", op, storage.ErrInvitationNotFound)
+		}
+		return models.OrgInvitation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	invitation.Role = models.OrgRoleMember
+	if isAdmin {
+		invitation.Role = models.OrgRoleAdmin
+	}
+	if acceptedAt.Valid {
+		invitation.AcceptedAt = &acceptedAt.Time
+	}
+	if declinedAt.Valid {
+		invitation.DeclinedAt = &declinedAt.Time
+	}
+	invitation.UserID = userID.Int64
+
+	return invitation, nil
+}
+
+// AcceptOrgInvitation marks the invitation as accepted by the user and moves
+// the user to its org with its role in one transaction. Tokens of the user
+// are revoked, as they name the org the user belonged to. It fails with
+// storage.ErrInvitationUsed if the invitation has already been answered,
+// and with storage.ErrUserNotFound if there is no such user.
+func (s *Storage) AcceptOrgInvitation(ctx context.Context, id int64, userID int64, at time.Time) error {
+	const op = "storage.sqlite.AcceptOrgInvitation"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE org_invitations SET accepted_at = ?, user_id = ?
+		WHERE id = ? AND accepted_at IS NULL AND declined_at IS NULL`,
+		at.UTC(), userID, id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrInvitationUsed)
+	}
+
+	var orgID int64
+	var isAdmin bool
+	err = tx.QueryRowContext(ctx, "SELECT org_id, is_admin FROM org_invitations WHERE id = ?", id).
+		Scan(&orgID, &isAdmin)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err = tx.ExecContext(ctx,
+		`UPDATE users SET org_id = ?, is_admin = ?, token_version = token_version + 1
+		WHERE id = ? AND deleted_at IS NULL`,
+		orgID, isAdmin, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err = res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	err = saveAdminEvent(ctx, tx, models.AuditOrgInvitationAccepted, userID, 0, userID,
+		strconv.FormatInt(orgID, 10), at)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeclineOrgInvitation marks the invitation as declined. It fails with
+// storage.ErrInvitationUsed if the invitation has already been answered.
+func (s *Storage) DeclineOrgInvitation(ctx context.Context, id int64, at time.Time) error {
+	const op = "storage.sqlite.DeclineOrgInvitation"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE org_invitations SET declined_at = ? WHERE id = ? AND accepted_at IS NULL AND declined_at IS NULL",
+		at.UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrInvitationUsed)
+	}
+
+	var orgID int64
+	if err = tx.QueryRowContext(ctx, "SELECT org_id FROM org_invitations WHERE id = ?", id).Scan(&orgID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = saveAdminEvent(ctx, tx, models.AuditOrgInvitationDeclined, 0, 0, 0, strconv.FormatInt(orgID, 10), at)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetOrgMemberRole gives the member of the org the role on behalf of the
+// admin. Tokens of the member are revoked, so that they do not outlive an
+// admin role. Giving members the role they have does nothing. It fails with
+// storage.ErrUserNotFound if the user is not a member of the org.
+func (s *Storage) SetOrgMemberRole(
+	ctx context.Context,
+	orgID int64,
+	userID int64,
+	role string,
+	adminID int64,
+	at time.Time,
+) error {
+	const op = "storage.sqlite.SetOrgMemberRole"
+
+	isAdmin := role == models.OrgRoleAdmin
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var current bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT is_admin FROM users WHERE id = ? AND org_id = ? AND deleted_at IS NULL",
+		userID, orgID,
+	).Scan(&current)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if current == isAdmin {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE users SET is_admin = ?, token_version = token_version + 1 WHERE id = ?",
+		isAdmin, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = saveAdminEvent(ctx, tx, models.AuditOrgMemberRoleChanged, userID, 0, adminID, role, at); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RemoveOrgMember moves the member of the org back to the default org as a
+// regular user on behalf of the admin, revoking the tokens of the member.
+// It fails with storage.ErrUserNotFound if the user is not a member of the
+// org.
+func (s *Storage) RemoveOrgMember(ctx context.Context, orgID int64, userID int64, adminID int64, at time.Time) error {
+	const op = "storage.sqlite.RemoveOrgMember"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE users SET org_id = ?, is_admin = 0, token_version = token_version + 1
+		WHERE id = ? AND org_id = ? AND deleted_at IS NULL`,
+		models.DefaultOrgID, userID, orgID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	err = saveAdminEvent(ctx, tx, models.AuditOrgMemberRemoved, userID, 0, adminID, strconv.FormatInt(orgID, 10), at)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"time"
+)
+
+// The outbox events are recorded by triggers on the tables of the changes
+// they are about, see the outbox_events migration.
+
+// PendingOutboxEvents returns up to limit events which are yet to be
+// delivered, the oldest first.
+func (s *Storage) PendingOutboxEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	const op = "storage.sqlite.PendingOutboxEvents"
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, type, payload, created_at, attempts
+		FROM outbox_events WHERE delivered_at IS NULL ORDER BY id LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var event models.OutboxEvent
+		var payload []byte
+		if err = rows.Scan(&event.ID, &event.Type, &payload, &event.CreatedAt, &event.Attempts); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// MarkOutboxEventDelivered records that the event was published at the
+// time.
+func (s *Storage) MarkOutboxEventDelivered(ctx context.Context, id int64, at time.Time) error {
+	const op = "storage.sqlite.MarkOutboxEventDelivered"
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE outbox_events SET delivered_at = ?, last_error = '' WHERE id = ?",
+		at.UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// FailOutboxEvent records a failed attempt to publish the event, which
+// stays pending.
+func (s *Storage) FailOutboxEvent(ctx context.Context, id int64, reason string) error {
+	const op = "storage.sqlite.FailOutboxEvent"
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE outbox_events SET attempts = attempts + 1, last_error = ? WHERE id = ?",
+		reason, id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteDeliveredOutboxEvents deletes the events delivered before the time
+// and returns how many it deleted.
+func (s *Storage) DeleteDeliveredOutboxEvents(ctx context.Context, before time.Time) (int, error) {
+	const op = "storage.sqlite.DeleteDeliveredOutboxEvents"
+
+	res, err := s.db.ExecContext(ctx,
+		"DELETE FROM outbox_events WHERE delivered_at IS NOT NULL AND delivered_at < ?",
+		before.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int(deleted), nil
+}
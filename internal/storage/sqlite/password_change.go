@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// RequirePasswordChange makes the user set a new password before logging in
+// again and ends the sessions of the user, recording the admin as the
+// actor. UpdatePassword clears the requirement. It fails with
+// storage.ErrUserNotFound if there is no such user or the user is deleted.
+func (s *Storage) RequirePasswordChange(ctx context.Context, userID int64, adminID int64, at time.Time) error {
+	const op = "storage.sqlite.RequirePasswordChange"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE users SET password_change_required = TRUE, token_version = token_version + 1
+		WHERE id = ? AND deleted_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	for _, table := range sessionTables {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = ?", userID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO audit_events(type, user_id, actor_id, created_at) values(?,?,?,?)",
+		models.AuditPasswordChangeForced, userID, adminID, at.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SavePasswordResetToken(ctx context.Context, token models.PasswordResetToken) error {
+	const op = "storage.sqlite.SavePasswordResetToken"
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO password_reset_tokens(token_hash, user_id, expires_at, created_at) values(?,?,?,?)",
+		token.TokenHash, token.UserID, token.ExpiresAt.UTC(), token.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) PasswordResetToken(ctx context.Context, tokenHash string) (models.PasswordResetToken, error) {
+	const op = "storage.sqlite.PasswordResetToken"
+
+	stmt, err := s.db.PrepareContext(ctx, `SELECT id, token_hash, user_id, expires_at, created_at, used_at
+		FROM password_reset_tokens WHERE token_hash = ?`)
+	if err != nil {
+		return models.PasswordResetToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, tokenHash)
+
+	var token models.PasswordResetToken
+	var usedAt sql.NullTime
+	err = row.Scan(&token.ID, &token.TokenHash, &token.UserID, &token.ExpiresAt, &token.CreatedAt, &usedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PasswordResetToken{}, fmt.Errorf("%s: %w", op, storage.ErrPasswordResetTokenNotFound)
+		}
+		return models.PasswordResetToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+
+	return token, nil
+}
+
+// UsePasswordResetToken marks the token as used. It fails with
+// storage.ErrPasswordResetTokenUsed if the token has already been used.
+func (s *Storage) UsePasswordResetToken(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.UsePasswordResetToken"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE password_reset_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL",
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrPasswordResetTokenUsed)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+)
+
+// SealUserPII rewrites the emails and phone numbers of users with seal, as
+// after encryption has been turned on, and returns how many users changed.
+// seal must return values it has already sealed as they are.
+func (s *Storage) SealUserPII(ctx context.Context, seal func(string) string) (int, error) {
+	const op = "storage.sqlite.SealUserPII"
+
+	type userPII struct {
+		id    int64
+		email string
+		phone string
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, email, COALESCE(phone, '') FROM users ORDER BY id")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var plain []userPII
+	for rows.Next() {
+		var user userPII
+		if err = rows.Scan(&user.id, &user.email, &user.phone); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		email, phone := seal(user.email), seal(user.phone)
+		if email != user.email || phone != user.phone {
+			user.email, user.phone = email, phone
+			plain = append(plain, user)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	rows.Close()
+
+	for _, user := range plain {
+		_, err = s.db.ExecContext(ctx,
+			"UPDATE users SET email = ?, phone = NULLIF(?, '') WHERE id = ?",
+			user.email, user.phone, user.id,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return len(plain), nil
+}
@@ -0,0 +1,111 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+)
+
+// maxPolicyValues is how many values the policy_rules table keeps per rule.
+const maxPolicyValues = 6
+
+// SavePolicyRule saves the rule and returns its id. Rules have at most
+// maxPolicyValues values.
+func (s *Storage) SavePolicyRule(ctx context.Context, rule models.PolicyRule) (int64, error) {
+	const op = "storage.sqlite.SavePolicyRule"
+
+	if len(rule.Values) > maxPolicyValues {
+		return 0, fmt.Errorf("%s: rule has %d values, at most %d are kept", op, len(rule.Values), maxPolicyValues)
+	}
+
+	values := make([]any, maxPolicyValues)
+	for i := range values {
+		values[i] = ""
+		if i < len(rule.Values) {
+			values[i] = rule.Values[i]
+		}
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		"INSERT INTO policy_rules(ptype, v0, v1, v2, v3, v4, v5, created_at) values(?,?,?,?,?,?,?,?)",
+		append(append([]any{rule.Type}, values...), rule.CreatedAt.UTC())...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// PolicyRules returns every rule in the order they were saved. Trailing
+// empty values are left out.
+func (s *Storage) PolicyRules(ctx context.Context) ([]models.PolicyRule, error) {
+	const op = "storage.sqlite.PolicyRules"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, ptype, v0, v1, v2, v3, v4, v5, created_at FROM policy_rules ORDER BY id",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var rules []models.PolicyRule
+	for rows.Next() {
+		var rule models.PolicyRule
+		values := make([]string, maxPolicyValues)
+		err = rows.Scan(
+			&rule.ID,
+			&rule.Type,
+			&values[0],
+			&values[1],
+			&values[2],
+			&values[3],
+			&values[4],
+			&values[5],
+			&rule.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for len(values) > 0 && values[len(values)-1] == "" {
+			values = values[:len(values)-1]
+		}
+		rule.Values = values
+
+		rules = append(rules, rule)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rules, nil
+}
+
+// DeletePolicyRule deletes the rule with the id. It fails with
+// storage.ErrPolicyRuleNotFound if there is no such rule.
+func (s *Storage) DeletePolicyRule(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.DeletePolicyRule"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM policy_rules WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrPolicyRuleNotFound)
+	}
+
+	return nil
+}
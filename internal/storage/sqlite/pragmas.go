@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pragmas are set on every connection to the database. Empty values keep
+// the defaults of the driver.
+type Pragmas struct {
+	// JournalMode is delete, truncate, persist, memory, wal or off. In wal
+	// mode readers do not wait for writers.
+	JournalMode string
+	// Synchronous is off, normal, full or extra.
+	Synchronous string
+	// BusyTimeout is how long statements wait for the lock of the database
+	// before failing with "database is locked".
+	BusyTimeout time.Duration
+	// ForeignKeys is on or off.
+	ForeignKeys string
+	// TxLock is deferred, immediate or exclusive. Immediate transactions
+	// take the write lock as they begin, so that they wait for each other
+	// rather than fail when a read lock cannot be upgraded.
+	TxLock string
+}
+
+// dsn returns the dsn with the pragmas added as settings of the driver,
+// which settings the dsn has already take precedence over.
+func (p Pragmas) dsn(dsn string) string {
+	params := url.Values{}
+	if p.JournalMode != "" {
+		params.Set("_journal_mode", p.JournalMode)
+	}
+	if p.Synchronous != "" {
+		params.Set("_synchronous", p.Synchronous)
+	}
+	if p.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.FormatInt(p.BusyTimeout.Milliseconds(), 10))
+	}
+	if p.ForeignKeys != "" {
+		params.Set("_foreign_keys", p.ForeignKeys)
+	}
+	if p.TxLock != "" {
+		params.Set("_txlock", p.TxLock)
+	}
+	if len(params) == 0 {
+		return dsn
+	}
+
+	// the driver goes by the first value of a setting
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + params.Encode()
+	}
+
+	return dsn + "?" + params.Encode()
+}
@@ -0,0 +1,545 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// roleQuery selects roles with a row per permission and its condition, see
+// scanRoles.
+const roleQuery = `SELECT r.id, r.name, r.description, r.created_at, COALESCE(p.name, ''),
+	COALESCE(rp.condition, '') FROM roles r
+	LEFT JOIN role_permissions rp ON rp.role_id = r.id
+	LEFT JOIN permissions p ON p.id = rp.permission_id`
+
+// SaveRole creates the role with its permissions and inherited roles and
+// returns its id. It fails with storage.ErrRoleExists if a role has the name
+// already, and with storage.ErrRoleNotFound if an inherited role does not
+// exist.
+func (s *Storage) SaveRole(ctx context.Context, role models.Role) (int64, error) {
+	const op = "storage.sqlite.SaveRole"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO roles(name, description, created_at) values(?,?,?)",
+		role.Name, role.Description, role.CreatedAt.UTC(),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrRoleExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = setRolePermissions(ctx, tx, id, role.Permissions, role.Conditions); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if err = setRoleInherits(ctx, tx, id, role.Inherits); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// UpdateRole sets the description, the permissions and the inherited roles
+// of the role with the name. It fails with storage.ErrRoleNotFound if there
+// is no such role or an inherited role does not exist.
+func (s *Storage) UpdateRole(ctx context.Context, role models.Role) error {
+	const op = "storage.sqlite.UpdateRole"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		"UPDATE roles SET description = ? WHERE name = ? RETURNING id",
+		role.Description, role.Name,
+	).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, table := range []string{"role_permissions", "role_inherits"} {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE role_id = ?", id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err = setRolePermissions(ctx, tx, id, role.Permissions, role.Conditions); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = setRoleInherits(ctx, tx, id, role.Inherits); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// setRolePermissions grants the permissions to the role, under their
+// conditions, creating those that are new.
+func setRolePermissions(
+	ctx context.Context,
+	tx *sql.Tx,
+	roleID int64,
+	permissions []string,
+	conditions map[string]string,
+) error {
+	for _, permission := range permissions {
+		if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO permissions(name) values(?)", permission); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO role_permissions(role_id, permission_id, condition)
+			SELECT ?, id, ? FROM permissions WHERE name = ?`,
+			roleID, conditions[permission], permission,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setRoleInherits makes the role inherit the roles, failing with
+// storage.ErrRoleNotFound if one does not exist.
+func setRoleInherits(ctx context.Context, tx *sql.Tx, roleID int64, roles []string) error {
+	for _, role := range roles {
+		res, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO role_inherits(role_id, inherited_id)
+			SELECT ?, id FROM roles WHERE name = ?`,
+			roleID, role,
+		)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("%w: %s", storage.ErrRoleNotFound, role)
+		}
+	}
+
+	return nil
+}
+
+// Role returns the role with the name. It fails with
+// storage.ErrRoleNotFound if there is no such role.
+func (s *Storage) Role(ctx context.Context, name string) (models.Role, error) {
+	const op = "storage.sqlite.Role"
+
+	rows, err := s.db.QueryContext(ctx, roleQuery+" WHERE r.name = ? ORDER BY p.name", name)
+	if err != nil {
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles, err := scanRoles(rows)
+	if err != nil {
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(roles) == 0 {
+		return models.Role{}, fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+	}
+	if err = s.setInherits(ctx, roles); err != nil {
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles[0], nil
+}
+
+// Roles returns every role, ordered by name.
+func (s *Storage) Roles(ctx context.Context) ([]models.Role, error) {
+	const op = "storage.sqlite.Roles"
+
+	rows, err := s.db.QueryContext(ctx, roleQuery+" ORDER BY r.name, p.name")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles, err := scanRoles(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err = s.setInherits(ctx, roles); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// DeleteRole deletes the role with the name, which the users it was
+// assigned to and the roles inheriting it lose. It fails with
+// storage.ErrRoleNotFound if there is no such role.
+func (s *Storage) DeleteRole(ctx context.Context, name string) error {
+	const op = "storage.sqlite.DeleteRole"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, "DELETE FROM roles WHERE name = ? RETURNING id", name).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, table := range []string{"role_permissions", "role_inherits", "user_roles", "group_roles"} {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE role_id = ?", id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM role_inherits WHERE inherited_id = ?", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// adminRole is the role migrations create for admins of single apps. Users
+// with the is_admin flag administer every app.
+const adminRole = "admin"
+
+// AssignRole assigns the role with the name to the user within the app, or
+// globally if appID is zero, on behalf of the admin, unless the user has it
+// already. Assignments without an admin, as of default roles on
+// registration, are not audited. It fails with storage.ErrRoleNotFound or storage.ErrUserNotFound
+// if there is no such role or user.
+func (s *Storage) AssignRole(
+	ctx context.Context,
+	userID int64,
+	role string,
+	appID int,
+	adminID int64,
+	at time.Time,
+) error {
+	const op = "storage.sqlite.AssignRole"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var roleID int64
+	if err = tx.QueryRowContext(ctx, "SELECT id FROM roles WHERE name = ?", role).Scan(&roleID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM users WHERE id = ? AND deleted_at IS NULL)",
+		userID,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT OR IGNORE INTO user_roles(user_id, role_id, app_id, created_at) values(?,?,?,?)",
+		userID, roleID, appID, at.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return nil
+	}
+
+	if adminID != 0 {
+		if err = saveAdminEvent(ctx, tx, models.AuditRoleAssigned, userID, appID, adminID, role, at); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeRole takes the role with the name the user has within the app, or
+// globally if appID is zero, on behalf of the admin, if the user has it.
+func (s *Storage) RevokeRole(
+	ctx context.Context,
+	userID int64,
+	role string,
+	appID int,
+	adminID int64,
+	at time.Time,
+) error {
+	const op = "storage.sqlite.RevokeRole"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		"DELETE FROM user_roles WHERE user_id = ? AND role_id = (SELECT id FROM roles WHERE name = ?) AND app_id = ?",
+		userID, role, appID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return nil
+	}
+
+	if err = saveAdminEvent(ctx, tx, models.AuditRoleRevoked, userID, appID, adminID, role, at); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// userRoleIDs selects the ids of the roles the user has within an app,
+// those assigned globally included, directly or through a group, and the
+// roles these inherit transitively. UNION drops the ids reached before, so
+// that cycles end. It takes the user id, the app id and the user id again.
+const userRoleIDs = `WITH RECURSIVE user_role_ids(id) AS (
+	SELECT role_id FROM user_roles WHERE user_id = ? AND app_id IN (0, ?)
+	UNION SELECT gr.role_id FROM group_roles gr
+	JOIN group_members gm ON gm.group_id = gr.group_id WHERE gm.user_id = ?
+	UNION SELECT ri.inherited_id FROM role_inherits ri JOIN user_role_ids u ON u.id = ri.role_id
+	) SELECT id FROM user_role_ids`
+
+// UserRoles returns the roles the user has within the app, or the global
+// ones if appID is zero, directly, through the groups of the user or by
+// inheritance, ordered by name. Global roles apply within every app.
+func (s *Storage) UserRoles(ctx context.Context, userID int64, appID int) ([]models.Role, error) {
+	const op = "storage.sqlite.UserRoles"
+
+	rows, err := s.db.QueryContext(ctx,
+		roleQuery+" WHERE r.id IN ("+userRoleIDs+") ORDER BY r.name, p.name",
+		userID, appID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles, err := scanRoles(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err = s.setInherits(ctx, roles); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// PermissionConditions returns the conditions under which the roles the
+// user has within the app, see UserRoles, grant the permission, an empty
+// one for roles granting it unconditionally. It returns none if no role
+// grants the permission.
+func (s *Storage) PermissionConditions(ctx context.Context, userID int64, appID int, permission string) ([]string, error) {
+	const op = "storage.sqlite.PermissionConditions"
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT DISTINCT rp.condition FROM role_permissions rp
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE rp.role_id IN (`+userRoleIDs+`) AND p.name = ?
+		ORDER BY rp.condition`,
+		userID, appID, userID, permission,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var conditions []string
+	for rows.Next() {
+		var condition string
+		if err = rows.Scan(&condition); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		conditions = append(conditions, condition)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return conditions, nil
+}
+
+// IsAppAdmin tells whether the user administers the app, either by the
+// is_admin flag or by having the admin role within the app. It fails with
+// storage.ErrUserNotFound if there is no such user or the user is deleted.
+func (s *Storage) IsAppAdmin(ctx context.Context, userID int64, appID int) (bool, error) {
+	const op = "storage.sqlite.IsAppAdmin"
+
+	var isAdmin bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT u.is_admin OR EXISTS(SELECT 1 FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = u.id AND ur.app_id = ? AND ur.app_id != 0 AND r.name = ?)
+		FROM users u WHERE u.id = ? AND u.deleted_at IS NULL`,
+		appID, adminRole, userID,
+	).Scan(&isAdmin)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return isAdmin, nil
+}
+
+// RoleHierarchy returns the names of the roles each role inherits directly,
+// ordered by name, keyed by the name of the role.
+func (s *Storage) RoleHierarchy(ctx context.Context) (map[string][]string, error) {
+	const op = "storage.sqlite.RoleHierarchy"
+
+	hierarchy, err := s.roleHierarchy(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return hierarchy, nil
+}
+
+func (s *Storage) roleHierarchy(ctx context.Context) (map[string][]string, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT r.name, i.name FROM role_inherits ri
+		JOIN roles r ON r.id = ri.role_id
+		JOIN roles i ON i.id = ri.inherited_id
+		ORDER BY r.name, i.name`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hierarchy := make(map[string][]string)
+	for rows.Next() {
+		var role, inherited string
+		if err = rows.Scan(&role, &inherited); err != nil {
+			return nil, err
+		}
+		hierarchy[role] = append(hierarchy[role], inherited)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return hierarchy, nil
+}
+
+// setInherits sets the roles the roles inherit directly.
+func (s *Storage) setInherits(ctx context.Context, roles []models.Role) error {
+	if len(roles) == 0 {
+		return nil
+	}
+
+	hierarchy, err := s.roleHierarchy(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range roles {
+		roles[i].Inherits = hierarchy[roles[i].Name]
+	}
+
+	return nil
+}
+
+// scanRoles reads the rows of a roleQuery ordered by role, and closes them.
+func scanRoles(rows *sql.Rows) ([]models.Role, error) {
+	defer rows.Close()
+
+	var roles []models.Role
+	for rows.Next() {
+		var role models.Role
+		var permission, condition string
+		err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt, &permission, &condition)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(roles) == 0 || roles[len(roles)-1].ID != role.ID {
+			roles = append(roles, role)
+		}
+		if permission != "" {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, permission)
+			if condition != "" {
+				if last.Conditions == nil {
+					last.Conditions = make(map[string]string)
+				}
+				last.Conditions[permission] = condition
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
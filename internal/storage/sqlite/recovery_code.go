@@ -0,0 +1,66 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/storage"
+	"time"
+)
+
+// ReplaceRecoveryCodes deletes the recovery codes of the user and saves the
+// new ones in one transaction.
+func (s *Storage) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	const op = "storage.sqlite.ReplaceRecoveryCodes"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now().UTC()
+	for _, hash := range codeHashes {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO recovery_codes(user_id, code_hash, created_at) values(?,?,?)",
+			userID, hash, now,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of the user as used. It
+// fails with storage.ErrRecoveryCodeNotFound if there is none with the hash.
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	const op = "storage.sqlite.UseRecoveryCode"
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE recovery_codes SET used_at = ?
+		WHERE id = (SELECT id FROM recovery_codes WHERE user_id = ? AND code_hash = ? AND used_at IS NULL LIMIT 1)`,
+		time.Now().UTC(), userID, codeHash,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrRecoveryCodeNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+func (s *Storage) SaveRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	const op = "storage.sqlite.SaveRefreshToken"
+
+	if err := saveRefreshToken(ctx, s.db, token); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	const op = "storage.sqlite.RefreshToken"
+
+	stmt, err := s.db.PrepareContext(ctx, `SELECT id, token_hash, family_id, user_id, app_id, scopes, amr, expires_at, created_at,
+		session_started_at, auth_time, rotated_at, revoked_at
+		FROM refresh_tokens WHERE token_hash = ?`)
+	if err != nil {
+		return models.RefreshToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, tokenHash)
+
+	var token models.RefreshToken
+	var scopes, amr string
+	var rotatedAt, revokedAt sql.NullTime
+	err = row.Scan(
+		&token.ID,
+		&token.TokenHash,
+		&token.FamilyID,
+		&token.UserID,
+		&token.AppID,
+		&scopes,
+		&amr,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+		&token.SessionStartedAt,
+		&token.AuthTime,
+		&rotatedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.RefreshToken{}, fmt.Errorf("%s: %w", op, storage.ErrRefreshTokenNotFound)
+		}
+		return models.RefreshToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	token.Scopes = strings.Fields(scopes)
+	token.AuthMethods = strings.Fields(amr)
+	if rotatedAt.Valid {
+		token.RotatedAt = &rotatedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken marks the old token as rotated and saves its successor
+// in one transaction. It fails with storage.ErrRefreshTokenRotated if the old
+// token has already been rotated or revoked.
+func (s *Storage) RotateRefreshToken(ctx context.Context, oldID int64, token models.RefreshToken) error {
+	const op = "storage.sqlite.RotateRefreshToken"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE refresh_tokens SET rotated_at = ? WHERE id = ? AND rotated_at IS NULL AND revoked_at IS NULL",
+		time.Now().UTC(), oldID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrRefreshTokenRotated)
+	}
+
+	if err = saveRefreshToken(ctx, tx, token); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	const op = "storage.sqlite.RevokeRefreshTokenFamily"
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL",
+		time.Now().UTC(), familyID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokeUserRefreshTokens(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.RevokeUserRefreshTokens"
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL",
+		time.Now().UTC(), userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func saveRefreshToken(ctx context.Context, db execer, token models.RefreshToken) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO refresh_tokens(token_hash, family_id, user_id, app_id, scopes, amr, expires_at, created_at,
+			session_started_at, auth_time)
+		values(?,?,?,?,?,?,?,?,?,?)`,
+		token.TokenHash,
+		token.FamilyID,
+		token.UserID,
+		token.AppID,
+		strings.Join(token.Scopes, " "),
+		strings.Join(token.AuthMethods, " "),
+		token.ExpiresAt.UTC(),
+		token.CreatedAt.UTC(),
+		token.SessionStartedAt.UTC(),
+		token.AuthTime.UTC(),
+	)
+
+	return err
+}
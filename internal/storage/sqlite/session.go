@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveSession(ctx context.Context, session models.Session) error {
+	const op = "storage.sqlite.SaveSession"
+
+	claims, err := json.Marshal(session.Claims)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		"INSERT INTO sessions(token_hash, app_id, claims, expires_at, created_at) values(?,?,?,?,?)",
+		session.TokenHash, session.AppID, string(claims), session.ExpiresAt.UTC(), session.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Session returns the session of the opaque token. Expired sessions are
+// reported as not found.
+func (s *Storage) Session(ctx context.Context, tokenHash string) (models.Session, error) {
+	const op = "storage.sqlite.Session"
+
+	stmt, err := s.db.PrepareContext(ctx, `SELECT token_hash, app_id, claims, expires_at, created_at
+		FROM sessions WHERE token_hash = ? AND expires_at > ?`)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, tokenHash, time.Now().UTC())
+
+	var session models.Session
+	var claims string
+	err = row.Scan(&session.TokenHash, &session.AppID, &claims, &session.ExpiresAt, &session.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+		}
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = json.Unmarshal([]byte(claims), &session.Claims); err != nil {
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, nil
+}
+
+// ExtendSession updates the claims and the expiration of the session.
+func (s *Storage) ExtendSession(ctx context.Context, session models.Session) error {
+	const op = "storage.sqlite.ExtendSession"
+
+	claims, err := json.Marshal(session.Claims)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		"UPDATE sessions SET claims = ?, expires_at = ? WHERE token_hash = ?",
+		string(claims), session.ExpiresAt.UTC(), session.TokenHash,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteSession(ctx context.Context, tokenHash string) error {
+	const op = "storage.sqlite.DeleteSession"
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE token_hash = ?", tokenHash); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
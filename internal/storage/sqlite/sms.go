@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveSMSCode(ctx context.Context, code models.SMSCode) error {
+	const op = "storage.sqlite.SaveSMSCode"
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO sms_codes(user_id, phone, purpose, code_hash, expires_at, created_at) values(?,?,?,?,?,?)",
+		code.UserID, code.Phone, string(code.Purpose), code.CodeHash, code.ExpiresAt.UTC(), code.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// LatestSMSCode returns the last code sent to the user for the purpose. It
+// fails with storage.ErrSMSCodeNotFound if none was sent.
+func (s *Storage) LatestSMSCode(ctx context.Context, userID int64, purpose models.SMSCodePurpose) (models.SMSCode, error) {
+	const op = "storage.sqlite.LatestSMSCode"
+
+	stmt, err := s.db.PrepareContext(ctx, `SELECT id, user_id, phone, purpose, code_hash, attempts, expires_at, created_at, used_at
+		FROM sms_codes WHERE user_id = ? AND purpose = ? ORDER BY id DESC LIMIT 1`)
+	if err != nil {
+		return models.SMSCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, userID, string(purpose))
+
+	var code models.SMSCode
+	var usedAt sql.NullTime
+	err = row.Scan(
+		&code.ID,
+		&code.UserID,
+		&code.Phone,
+		&code.Purpose,
+		&code.CodeHash,
+		&code.Attempts,
+		&code.ExpiresAt,
+		&code.CreatedAt,
+		&usedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.SMSCode{}, fmt.Errorf("%s: %w", op, storage.ErrSMSCodeNotFound)
+		}
+		return models.SMSCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if usedAt.Valid {
+		code.UsedAt = &usedAt.Time
+	}
+
+	return code, nil
+}
+
+// FailSMSCode counts a wrong code entered against the sent one.
+func (s *Storage) FailSMSCode(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.FailSMSCode"
+
+	_, err := s.db.ExecContext(ctx, "UPDATE sms_codes SET attempts = attempts + 1 WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UseSMSCode marks the code as used. It fails with storage.ErrSMSCodeUsed if
+// the code has already been used.
+func (s *Storage) UseSMSCode(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.UseSMSCode"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE sms_codes SET used_at = ? WHERE id = ? AND used_at IS NULL",
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrSMSCodeUsed)
+	}
+
+	return nil
+}
@@ -3,83 +3,698 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/mattn/go-sqlite3"
-	_ "github.com/mattn/go-sqlite3"
 	"sso/internal/domain/models"
 	"sso/internal/storage"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Storage struct {
-	db *sql.DB
+	db     *sql.DB
+	emails EmailNormalizer
 }
 
-func New(storagePath string) (*Storage, error) {
-	const op = "storage.sqlite. New"
+// EmailNormalizer derives the keys the emails of users are told apart by.
+// Users are looked up by the key of their email, and no two users can have
+// emails with the same key.
+type EmailNormalizer interface {
+	Key(email string) string
+}
 
-	db, err := sql.Open("sqlite3", storagePath)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %s", op, err.Error())
+// New opens the database at the path, setting the pragmas on every
+// connection to it, and checks that they are valid.
+func New(storagePath string, emails EmailNormalizer, pool storage.PoolConfig, pragmas Pragmas) (*Storage, error) {
+	const op = "storage.sqlite.New"
+
+	db := pool.OpenDB(connector{dsn: pragmas.dsn(storagePath)})
+	if err := db.PingContext(context.Background()); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{db: db, emails: emails}, nil
+}
+
+// OpenDB opens the database at the path with the pragmas for other uses
+// than the storage, such as copying data.
+func OpenDB(storagePath string, pragmas Pragmas) (*sql.DB, error) {
+	const op = "storage.sqlite.OpenDB"
+
+	db := sql.OpenDB(connector{dsn: pragmas.dsn(storagePath)})
+	if err := db.PingContext(context.Background()); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return db, nil
+}
+
+// Transient reports whether the error is "database is locked", raised when
+// another connection holds the lock the statement needs for longer than the
+// busy timeout. Calls failing with it may be retried.
+func Transient(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
 	}
 
-	return &Storage{db: db}, nil
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
+
+// connector opens connections to the database at the dsn, as sql.Open does
+// for drivers without a connector of their own.
+type connector struct {
+	dsn string
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return c.Driver().Open(c.dsn)
+}
+
+func (c connector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
 }
 
-func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (int64, error) {
+// Ping checks that the database of the storage can be reached.
+func (s *Storage) Ping(ctx context.Context) error {
+	const op = "storage.sqlite.Ping"
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Close closes the connections of the storage.
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// Stats returns the statistics of the connections of the storage.
+func (s *Storage) Stats() sql.DBStats {
+	return s.db.Stats()
+}
+
+// ReplicaStats returns nothing, as SQLite databases have no replicas.
+func (s *Storage) ReplicaStats() []sql.DBStats {
+	return nil
+}
+
+func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte, profile models.UserProfile) (int64, error) {
 	const op = "storage.sqlite.SaveUser"
 
-	stmt, err := s.db.Prepare("INSERT INTO users(email, pass_hash) values(?,?)")
+	stmt, err := s.db.PrepareContext(ctx, `INSERT INTO users(email, email_key, pass_hash, first_name, last_name, display_name,
+		locale, avatar_url) values(?,?,?,?,?,?,?,?)`)
 	if err != nil {
-		return 0, fmt.Errorf("%s: %s", op, err.Error())
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	res, err := stmt.ExecContext(ctx, email, passHash)
+	res, err := stmt.ExecContext(ctx,
+		email,
+		s.emails.Key(email),
+		passHash,
+		profile.FirstName,
+		profile.LastName,
+		profile.DisplayName,
+		profile.Locale,
+		profile.AvatarURL,
+	)
 	if err != nil {
 		var sqliteErr sqlite3.Error
 		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
 			return 0, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
 		}
 
-		return 0, fmt.Errorf("%s: %s", op, err.Error())
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
 	id, err := res.LastInsertId()
 	if err != nil {
-		return 0, fmt.Errorf("%s: %s", op, err.Error())
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return id, nil
 }
 
+// User returns the user with the email, which is matched by its key. Users
+// left without a key, because their email had the key of an older user
+// when keys were introduced, are matched by the exact email.
 func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	const op = "storage.sqlite.User"
 
-	stmt, err := s.db.Prepare("SELECT id, email, pass_hash FROM users where email = ?")
+	stmt, err := s.db.PrepareContext(ctx, "SELECT "+userColumns+` FROM users
+		WHERE (email_key = ? OR email_key IS NULL AND email = ?) AND deleted_at IS NULL
+		ORDER BY email_key IS NULL LIMIT 1`)
 	if err != nil {
-		return models.User{}, fmt.Errorf("%s: %s", op, err.Error())
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	row := stmt.QueryRowContext(ctx, email)
+	row := stmt.QueryRowContext(ctx, s.emails.Key(email), email)
 
-	var user models.User
-	err = row.Scan(&user.ID, &user.Email, &user.PassHash)
+	user, err := scanUser(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
 		}
-		return models.User{}, fmt.Errorf("%s: %s", op, err.Error())
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return user, err
 }
 
+func (s *Storage) UserByID(ctx context.Context, userID int64) (models.User, error) {
+	const op = "storage.sqlite.UserByID"
+
+	stmt, err := s.db.PrepareContext(ctx, "SELECT "+userColumns+" FROM users where id = ? AND deleted_at IS NULL")
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, userID)
+
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// UserByIDIncludingDeleted returns the user with the id like UserByID, but
+// also if the user is deleted, so that admins can look at it.
+func (s *Storage) UserByIDIncludingDeleted(ctx context.Context, userID int64) (models.User, error) {
+	const op = "storage.sqlite.UserByIDIncludingDeleted"
+
+	user, err := scanUser(s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users where id = ?", userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) IncrementTokenVersion(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.IncrementTokenVersion"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET token_version = token_version + 1 WHERE id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// UpdatePassword sets the password hash of the user, which also meets a
+// password change an admin required.
+func (s *Storage) UpdatePassword(ctx context.Context, userID int64, passHash []byte) error {
+	const op = "storage.sqlite.UpdatePassword"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users SET pass_hash = ?, password_change_required = FALSE WHERE id = ?",
+		passHash, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// SetEmailVerified marks the email of the user as verified if it is still
+// the given one.
+func (s *Storage) SetEmailVerified(ctx context.Context, userID int64, email string) error {
+	const op = "storage.sqlite.SetEmailVerified"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET email_verified = TRUE WHERE id = ? AND email = ?", userID, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) SetAdmin(ctx context.Context, userID int64, isAdmin bool) error {
+	const op = "storage.sqlite.SetAdmin"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET is_admin = ? WHERE id = ?", isAdmin, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// UpdateEmail changes the email of the user and marks it as verified. It
+// fails with storage.ErrUserExists if another user has the email.
+func (s *Storage) UpdateEmail(ctx context.Context, userID int64, email string) error {
+	const op = "storage.sqlite.UpdateEmail"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users SET email = ?, email_key = ?, email_verified = TRUE WHERE id = ?",
+		email, s.emails.Key(email), userID,
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// UserByUsername returns the user with the normalized username.
+func (s *Storage) UserByUsername(ctx context.Context, username string) (models.User, error) {
+	const op = "storage.sqlite.UserByUsername"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users where username = ? AND deleted_at IS NULL", username)
+
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// SetUsername sets the normalized username of the user. It fails with
+// storage.ErrUsernameTaken if another user has the username.
+func (s *Storage) SetUsername(ctx context.Context, userID int64, username string) error {
+	const op = "storage.sqlite.SetUsername"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET username = ? WHERE id = ?", username, userID)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrUsernameTaken)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// UpdateUser applies the update and returns the updated user. It fails with
+// storage.ErrUserModified if update.Version is set and is not the version of
+// the user anymore, and with storage.ErrUserExists or
+// storage.ErrUsernameTaken if the new email or username belongs to another
+// user.
+func (s *Storage) UpdateUser(ctx context.Context, update models.UserUpdate) (models.User, error) {
+	const op = "storage.sqlite.UpdateUser"
+
+	var sets []string
+	var args []any
+	for _, field := range update.Fields {
+		switch field {
+		case models.UserFieldEmail:
+			sets = append(sets, "email = ?", "email_key = ?", "email_verified = FALSE")
+			args = append(args, update.Email, s.emails.Key(update.Email))
+		case models.UserFieldUsername:
+			// usernames are unique, but any number of users can have none
+			sets = append(sets, "username = ?")
+			args = append(args, sql.NullString{String: update.Username, Valid: update.Username != ""})
+		case models.UserFieldDisplayName:
+			sets = append(sets, "display_name = ?")
+			args = append(args, update.DisplayName)
+		case models.UserFieldFirstName:
+			sets = append(sets, "first_name = ?")
+			args = append(args, update.FirstName)
+		case models.UserFieldLastName:
+			sets = append(sets, "last_name = ?")
+			args = append(args, update.LastName)
+		case models.UserFieldLocale:
+			sets = append(sets, "locale = ?")
+			args = append(args, update.Locale)
+		case models.UserFieldAvatarURL:
+			sets = append(sets, "avatar_url = ?")
+			args = append(args, update.AvatarURL)
+		case models.UserFieldAppMetadata, models.UserFieldUserMetadata:
+			md := update.AppMetadata
+			if field == models.UserFieldUserMetadata {
+				md = update.UserMetadata
+			}
+			data, err := encodeMetadata(md)
+			if err != nil {
+				return models.User{}, fmt.Errorf("%s: %w", op, err)
+			}
+			sets = append(sets, field+" = ?")
+			args = append(args, data)
+		default:
+			return models.User{}, fmt.Errorf("%s: unknown field %q", op, field)
+		}
+	}
+	if len(sets) == 0 {
+		return models.User{}, fmt.Errorf("%s: nothing to update", op)
+	}
+
+	query := "UPDATE users SET " + strings.Join(sets, ", ") + " WHERE id = ?"
+	args = append(args, update.UserID)
+	if update.Version != 0 {
+		query += " AND version = ?"
+		args = append(args, update.Version)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			if strings.Contains(sqliteErr.Error(), "users.username") {
+				return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUsernameTaken)
+			}
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+		}
+
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := scanUser(tx.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = ?", update.UserID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserModified)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// SetUserMetadata replaces the user metadata of the user.
+func (s *Storage) SetUserMetadata(ctx context.Context, userID int64, md map[string]string) error {
+	const op = "storage.sqlite.SetUserMetadata"
+
+	data, err := encodeMetadata(md)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET user_metadata = ? WHERE id = ?", data, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// SetAppMetadata replaces the app metadata of the user.
+func (s *Storage) SetAppMetadata(ctx context.Context, userID int64, md map[string]string) error {
+	const op = "storage.sqlite.SetAppMetadata"
+
+	data, err := encodeMetadata(md)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET app_metadata = ? WHERE id = ?", data, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// encodeMetadata encodes the metadata as stored, with no metadata as an
+// empty object.
+func encodeMetadata(md map[string]string) (string, error) {
+	if md == nil {
+		return "{}", nil
+	}
+
+	data, err := json.Marshal(md)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// userSortKeys are the expressions users are ordered by for each sort. They
+// match the indexes on users, so that listing does not scan the table.
+var userSortKeys = map[models.UserSort]string{
+	models.UserSortCreatedAt: "COALESCE(created_at, '')",
+	models.UserSortEmail:     "email COLLATE NOCASE",
+}
+
+// ListUsers returns the users matching the filter in its sort order.
+// Deleted users are left out.
+func (s *Storage) ListUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	const op = "storage.sqlite.ListUsers"
+
+	key, ok := userSortKeys[filter.Sort]
+	if !ok {
+		key = userSortKeys[models.UserSortCreatedAt]
+	}
+
+	query := "SELECT " + userColumns + " FROM users WHERE deleted_at IS NULL"
+	var args []any
+	if filter.EmailPrefix != "" {
+		// a range rather than LIKE, so that the email index is used
+		query += " AND email COLLATE NOCASE >= ? AND email COLLATE NOCASE < ?"
+		args = append(args, filter.EmailPrefix, filter.EmailPrefix+string(utf8.MaxRune))
+	}
+	if filter.Search != "" {
+		// a search of no words matches no users
+		query += " AND id IN (SELECT docid FROM users_fts WHERE users_fts MATCH ?)"
+		args = append(args, ftsQuery(filter.Search))
+	}
+	if filter.EmailVerified != nil {
+		query += " AND email_verified = ?"
+		args = append(args, *filter.EmailVerified)
+	}
+	if filter.Admin != nil {
+		query += " AND is_admin = ?"
+		args = append(args, *filter.Admin)
+	}
+	if filter.OrgID != 0 {
+		query += " AND org_id = ?"
+		args = append(args, filter.OrgID)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query += " AND created_at > ?"
+		args = append(args, filter.CreatedAfter.UTC().Format(time.DateTime))
+	}
+	if after := filter.After; after != nil {
+		cmp := ">"
+		if filter.Desc {
+			cmp = "<"
+		}
+		query += " AND (" + key + ", id) " + cmp + " (?, ?)"
+		if filter.Sort == models.UserSortEmail {
+			args = append(args, after.Email, after.ID)
+		} else {
+			args = append(args, formatNullTime(after.CreatedAt), after.ID)
+		}
+	}
+	order := "ASC"
+	if filter.Desc {
+		order = "DESC"
+	}
+	query += " ORDER BY " + key + " " + order + ", id " + order
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// ftsQuery returns the full-text query matching the words of the search as
+// prefixes. Other characters only separate the words, so that searches can
+// not use the query syntax.
+func ftsQuery(search string) string {
+	words := strings.FieldsFunc(search, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + "*"
+	}
+
+	return strings.Join(words, " ")
+}
+
+// formatNullTime formats the time like SQLite stores CURRENT_TIMESTAMP, or
+// as an empty string for the zero time.
+func formatNullTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.DateTime)
+}
+
+// UserByPhone returns the user with the verified phone number.
+func (s *Storage) UserByPhone(ctx context.Context, phone string) (models.User, error) {
+	const op = "storage.sqlite.UserByPhone"
+
+	stmt, err := s.db.PrepareContext(ctx, "SELECT "+userColumns+" FROM users where phone = ? AND phone_verified AND deleted_at IS NULL")
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, phone)
+
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// SetPhone sets the verified phone number of the user. It fails with
+// storage.ErrPhoneTaken if another user has the number.
+func (s *Storage) SetPhone(ctx context.Context, userID int64, phone string) error {
+	const op = "storage.sqlite.SetPhone"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE users SET phone = ?, phone_verified = TRUE WHERE id = ?", phone, userID)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrPhoneTaken)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
 func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 	const op = "storage.sqlite.User"
 
-	stmt, err := s.db.Prepare("SELECT is_admin FROM users where id = ?")
+	stmt, err := s.db.PrepareContext(ctx, "SELECT is_admin FROM users where id = ? AND deleted_at IS NULL")
 	if err != nil {
-		return false, fmt.Errorf("%s: %s", op, err.Error())
+		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
 	row := stmt.QueryRowContext(ctx, userID)
@@ -90,29 +705,203 @@ func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 		if errors.Is(err, sql.ErrNoRows) {
 			return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
 		}
-		return false, fmt.Errorf("%s: %s", op, err.Error())
+		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return isAdmin, nil
 }
 
+// App returns the app with the id. It fails with storage.ErrAppNotFound if
+// there is no such app, and with storage.ErrAppDisabled if it is disabled.
 func (s *Storage) App(ctx context.Context, appID int) (models.App, error) {
 	const op = "storage.sqlite.App"
 
-	stmt, err := s.db.Prepare("SELECT id, name, secret FROM apps WHERE id = ?")
+	stmt, err := s.db.PrepareContext(ctx, "SELECT "+appColumns+" FROM apps WHERE id = ?")
 	if err != nil {
-		return models.App{}, fmt.Errorf("%s: %s", op, err.Error())
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	row := stmt.QueryRowContext(ctx, appID)
+	app, err := scanApp(stmt.QueryRowContext(ctx, appID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if app.Status == models.AppStatusDisabled {
+		return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppDisabled)
+	}
 
-	var app models.App
-	err = row.Scan(&app.ID, &app.Name, &app.Secret)
+	if app.ClaimMappings, err = s.appClaimMappings(ctx, app.ID); err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return app, nil
+}
+
+// AppByAudience returns the app whose tokens are issued for the audience.
+// Apps without an explicit audience are matched by name, and no two apps
+// share an audience. Disabled apps are not found.
+func (s *Storage) AppByAudience(ctx context.Context, audience string) (models.App, error) {
+	const op = "storage.sqlite.AppByAudience"
+
+	stmt, err := s.db.PrepareContext(ctx, "SELECT "+appColumns+` FROM apps
+		WHERE (CASE WHEN audience = '' THEN name ELSE audience END) = ? AND status != 'disabled'`)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := scanApp(stmt.QueryRowContext(ctx, audience))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
 		}
-		return models.App{}, fmt.Errorf("%s: %s", op, err.Error())
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if app.ClaimMappings, err = s.appClaimMappings(ctx, app.ID); err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return app, nil
+}
+
+const userColumns = `id, email, COALESCE(username, ''), display_name, pass_hash, email_verified, COALESCE(phone, ''),
+	phone_verified, token_version, failed_logins, locked_until, deletion_scheduled_at, is_guest, is_admin,
+	created_at, updated_at, version, app_metadata, user_metadata, deleted_at, status, first_name, last_name, locale,
+	avatar_url, last_login_at, last_login_ip, last_login_user_agent, expires_at, expired_at,
+	password_change_required, org_id`
+
+func scanUser(row scanner) (models.User, error) {
+	var user models.User
+	var lockedUntil, deletionScheduledAt, deletedAt, lastLoginAt, expiresAt, expiredAt, createdAt, updatedAt sql.NullTime
+	var appMetadata, userMetadata string
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&user.Username,
+		&user.DisplayName,
+		&user.PassHash,
+		&user.EmailVerified,
+		&user.Phone,
+		&user.PhoneVerified,
+		&user.TokenVersion,
+		&user.FailedLogins,
+		&lockedUntil,
+		&deletionScheduledAt,
+		&user.IsGuest,
+		&user.IsAdmin,
+		&createdAt,
+		&updatedAt,
+		&user.Version,
+		&appMetadata,
+		&userMetadata,
+		&deletedAt,
+		&user.Status,
+		&user.FirstName,
+		&user.LastName,
+		&user.Locale,
+		&user.AvatarURL,
+		&lastLoginAt,
+		&user.LastLoginIP,
+		&user.LastLoginUserAgent,
+		&expiresAt,
+		&expiredAt,
+		&user.PasswordChangeRequired,
+		&user.OrgID,
+	)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err = json.Unmarshal([]byte(appMetadata), &user.AppMetadata); err != nil {
+		return models.User{}, fmt.Errorf("app metadata: %w", err)
+	}
+	if err = json.Unmarshal([]byte(userMetadata), &user.UserMetadata); err != nil {
+		return models.User{}, fmt.Errorf("user metadata: %w", err)
+	}
+
+	if lockedUntil.Valid {
+		user.LockedUntil = &lockedUntil.Time
+	}
+	if deletionScheduledAt.Valid {
+		user.DeletionScheduledAt = &deletionScheduledAt.Time
+	}
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	if lastLoginAt.Valid {
+		user.LastLoginAt = &lastLoginAt.Time
+	}
+	if expiresAt.Valid {
+		user.ExpiresAt = &expiresAt.Time
+	}
+	if expiredAt.Valid {
+		user.ExpiredAt = &expiredAt.Time
+	}
+	user.CreatedAt = createdAt.Time
+	user.UpdatedAt = updatedAt.Time
+
+	return user, nil
+}
+
+const appColumns = `id, org_id, name, secret, previous_secret_hash, previous_secret_expires_at, signing_key, audience,
+	token_format, scopes, grant_types, redirect_uris, claims, access_token_ttl, refresh_token_ttl, third_party,
+	login_quota, registration_quota, status, created_at, disabled_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanApp(row scanner) (models.App, error) {
+	var app models.App
+	var scopes, grantTypes, redirectURIs, claims string
+	var accessTTL, refreshTTL int64
+	var previousExpiresAt, createdAt, disabledAt sql.NullTime
+	err := row.Scan(
+		&app.ID,
+		&app.OrgID,
+		&app.Name,
+		&app.SecretHash,
+		&app.PreviousSecretHash,
+		&previousExpiresAt,
+		&app.SigningKey,
+		&app.Audience,
+		&app.TokenFormat,
+		&scopes,
+		&grantTypes,
+		&redirectURIs,
+		&claims,
+		&accessTTL,
+		&refreshTTL,
+		&app.ThirdParty,
+		&app.LoginQuota,
+		&app.RegistrationQuota,
+		&app.Status,
+		&createdAt,
+		&disabledAt,
+	)
+	if err != nil {
+		return models.App{}, err
+	}
+
+	if err = json.Unmarshal([]byte(claims), &app.Claims); err != nil {
+		return models.App{}, fmt.Errorf("invalid claims: %w", err)
+	}
+
+	app.Scopes = strings.Fields(scopes)
+	app.GrantTypes = strings.Fields(grantTypes)
+	app.RedirectURIs = strings.Fields(redirectURIs)
+	app.AccessTokenTTL = time.Duration(accessTTL) * time.Second
+	app.RefreshTokenTTL = time.Duration(refreshTTL) * time.Second
+	if previousExpiresAt.Valid {
+		app.PreviousSecretExpiresAt = &previousExpiresAt.Time
+	}
+	if createdAt.Valid {
+		app.CreatedAt = createdAt.Time
+	}
+	if disabledAt.Valid {
+		app.DisabledAt = &disabledAt.Time
 	}
 
 	return app, nil
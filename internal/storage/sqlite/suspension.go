@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// SuspendUser keeps the user from logging in until UnsuspendUser and ends
+// the sessions of the user, recording the admin as the actor. It fails with
+// storage.ErrUserNotFound if there is no such user or the user is deleted.
+func (s *Storage) SuspendUser(ctx context.Context, userID int64, adminID int64, at time.Time) error {
+	const op = "storage.sqlite.SuspendUser"
+
+	if err := s.setUserStatus(ctx, userID, adminID, at, models.UserStatusSuspended); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UnsuspendUser lets the suspended user log in again. It fails with
+// storage.ErrUserNotFound if there is no such user or the user is deleted.
+func (s *Storage) UnsuspendUser(ctx context.Context, userID int64, adminID int64, at time.Time) error {
+	const op = "storage.sqlite.UnsuspendUser"
+
+	if err := s.setUserStatus(ctx, userID, adminID, at, models.UserStatusActive); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// setUserStatus sets the status of the user and records the change in the
+// audit log. Suspending the user also revokes every token of the user.
+func (s *Storage) setUserStatus(
+	ctx context.Context,
+	userID int64,
+	adminID int64,
+	at time.Time,
+	status models.UserStatus,
+) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := "UPDATE users SET status = ? WHERE id = ? AND deleted_at IS NULL"
+	event := models.AuditUserUnsuspended
+	if status == models.UserStatusSuspended {
+		query = "UPDATE users SET status = ?, token_version = token_version + 1 WHERE id = ? AND deleted_at IS NULL"
+		event = models.AuditUserSuspended
+	}
+
+	res, err := tx.ExecContext(ctx, query, status, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return storage.ErrUserNotFound
+	}
+
+	if status == models.UserStatusSuspended {
+		for _, table := range sessionTables {
+			if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = ?", userID); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO audit_events(type, user_id, actor_id, created_at) values(?,?,?,?)",
+		event, userID, adminID, at.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
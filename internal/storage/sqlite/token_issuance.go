@@ -0,0 +1,42 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sso/internal/domain/models"
+	"time"
+)
+
+func (s *Storage) SaveTokenIssuance(ctx context.Context, issuance models.TokenIssuance) error {
+	const op = "storage.sqlite.SaveTokenIssuance"
+
+	var userID sql.NullInt64
+	if issuance.UserID != 0 {
+		userID = sql.NullInt64{Int64: issuance.UserID, Valid: true}
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO token_issuances(id, user_id, app_id, issued_at, expires_at) values(?,?,?,?,?)",
+		issuance.ID, userID, issuance.AppID, issuance.IssuedAt.UTC(), issuance.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokeTokenIssuance(ctx context.Context, tokenID string) error {
+	const op = "storage.sqlite.RevokeTokenIssuance"
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE token_issuances SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL",
+		time.Now().UTC(), tokenID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
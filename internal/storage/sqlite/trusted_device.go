@@ -0,0 +1,147 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+const trustedDeviceColumns = "id, user_id, token_hash, fingerprint_hash, ip, device, created_at, last_used_at, expires_at"
+
+func (s *Storage) SaveTrustedDevice(ctx context.Context, device models.TrustedDevice) error {
+	const op = "storage.sqlite.SaveTrustedDevice"
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO trusted_devices(user_id, token_hash, fingerprint_hash, ip, device, created_at, last_used_at,
+			expires_at)
+		values(?,?,?,?,?,?,?,?)`,
+		device.UserID,
+		device.TokenHash,
+		device.FingerprintHash,
+		device.IP,
+		device.Device,
+		device.CreatedAt.UTC(),
+		device.LastUsedAt.UTC(),
+		device.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TrustedDevice returns the device of the token, expired or not.
+func (s *Storage) TrustedDevice(ctx context.Context, tokenHash string) (models.TrustedDevice, error) {
+	const op = "storage.sqlite.TrustedDevice"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+trustedDeviceColumns+" FROM trusted_devices WHERE token_hash = ?", tokenHash)
+
+	device, err := scanTrustedDevice(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.TrustedDevice{}, fmt.Errorf("%s: %w", op, storage.ErrTrustedDeviceNotFound)
+		}
+		return models.TrustedDevice{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return device, nil
+}
+
+// TouchTrustedDevice records a login from the device.
+func (s *Storage) TouchTrustedDevice(ctx context.Context, id int64, ip string, at time.Time) error {
+	const op = "storage.sqlite.TouchTrustedDevice"
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE trusted_devices SET ip = ?, last_used_at = ? WHERE id = ?",
+		ip, at.UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TrustedDevices returns the unexpired trusted devices of the user, the most
+// recently used first.
+func (s *Storage) TrustedDevices(ctx context.Context, userID int64) ([]models.TrustedDevice, error) {
+	const op = "storage.sqlite.TrustedDevices"
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+trustedDeviceColumns+` FROM trusted_devices
+		WHERE user_id = ? AND expires_at > ?
+		ORDER BY last_used_at DESC, id DESC`,
+		userID, time.Now().UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var devices []models.TrustedDevice
+	for rows.Next() {
+		device, err := scanTrustedDevice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		devices = append(devices, device)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return devices, nil
+}
+
+// DeleteTrustedDevice forgets the device of the user. It fails with
+// storage.ErrTrustedDeviceNotFound if the user has no such device.
+func (s *Storage) DeleteTrustedDevice(ctx context.Context, userID int64, id int64) error {
+	const op = "storage.sqlite.DeleteTrustedDevice"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM trusted_devices WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrTrustedDeviceNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteUserTrustedDevices(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.DeleteUserTrustedDevices"
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM trusted_devices WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func scanTrustedDevice(row scanner) (models.TrustedDevice, error) {
+	var device models.TrustedDevice
+	err := row.Scan(
+		&device.ID,
+		&device.UserID,
+		&device.TokenHash,
+		&device.FingerprintHash,
+		&device.IP,
+		&device.Device,
+		&device.CreatedAt,
+		&device.LastUsedAt,
+		&device.ExpiresAt,
+	)
+
+	return device, err
+}
@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+// ImportUser saves the user migrated from another system and records the
+// import by the admin in the audit log. It fails with storage.ErrUserExists
+// or storage.ErrUsernameTaken if the email or username belongs to another
+// user.
+func (s *Storage) ImportUser(ctx context.Context, user models.UserImport, adminID int64, at time.Time) (int64, error) {
+	const op = "storage.sqlite.ImportUser"
+
+	appMetadata, err := encodeMetadata(user.AppMetadata)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	userMetadata, err := encodeMetadata(user.UserMetadata)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO users(email, email_key, pass_hash, email_verified, username, display_name, app_metadata,
+		user_metadata, org_id) values(?,?,?,?,?,?,?,?,?)`,
+		user.Email,
+		s.emails.Key(user.Email),
+		[]byte(user.PassHash),
+		user.EmailVerified,
+		sql.NullString{String: user.Username, Valid: user.Username != ""},
+		user.DisplayName,
+		appMetadata,
+		userMetadata,
+		user.OrgID,
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			if strings.Contains(sqliteErr.Error(), "users.username") {
+				return 0, fmt.Errorf("%s: %w", op, storage.ErrUsernameTaken)
+			}
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO audit_events(type, user_id, actor_id, created_at) values(?,?,?,?)",
+		models.AuditUserImported, id, adminID, at.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,139 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// mergedUserTables hold the data of users that MergeUsers moves over to
+// the primary user as is.
+var mergedUserTables = []string{
+	"refresh_tokens",
+	"user_sessions",
+	"trusted_devices",
+	"user_identities",
+}
+
+// MergeUsers moves the sessions, consents, identities, roles and groups of
+// the duplicate user over to the primary one, makes the primary an admin if
+// the duplicate was, and soft-deletes the duplicate on behalf of the admin as
+// DeleteUser does. Consents the primary already has for an app win over those
+// of the duplicate. It fails with storage.ErrUserNotFound if either user does
+// not exist or is deleted.
+func (s *Storage) MergeUsers(
+	ctx context.Context,
+	primaryID int64,
+	duplicateID int64,
+	adminID int64,
+	at time.Time,
+	purgeAt time.Time,
+) error {
+	const op = "storage.sqlite.MergeUsers"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE users SET deleted_at = ?, deletion_scheduled_at = ?, token_version = token_version + 1
+		WHERE id = ? AND deleted_at IS NULL`,
+		at.UTC(), purgeAt.UTC(), duplicateID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	res, err = tx.ExecContext(ctx,
+		`UPDATE users SET is_admin = is_admin OR (SELECT is_admin FROM users WHERE id = ?)
+		WHERE id = ? AND deleted_at IS NULL`,
+		duplicateID, primaryID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err = res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	for _, table := range mergedUserTables {
+		_, err = tx.ExecContext(ctx, "UPDATE "+table+" SET user_id = ? WHERE user_id = ?", primaryID, duplicateID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT OR IGNORE INTO consents(user_id, app_id, scopes, granted_at)
+		SELECT ?, app_id, scopes, granted_at FROM consents WHERE user_id = ?`,
+		primaryID, duplicateID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM consents WHERE user_id = ?", duplicateID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT OR IGNORE INTO user_roles(user_id, role_id, app_id, created_at)
+		SELECT ?, role_id, app_id, created_at FROM user_roles WHERE user_id = ?`,
+		primaryID, duplicateID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = ?", duplicateID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT OR IGNORE INTO group_members(group_id, user_id, created_at)
+		SELECT group_id, ?, created_at FROM group_members WHERE user_id = ?`,
+		primaryID, duplicateID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM group_members WHERE user_id = ?", duplicateID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// recorded for both users, so that the merge shows in the audit events of
+	// either
+	for _, userID := range []int64{primaryID, duplicateID} {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO audit_events(type, user_id, actor_id, created_at) values(?,?,?,?)",
+			models.AuditUserMerged, userID, adminID, at.UTC(),
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
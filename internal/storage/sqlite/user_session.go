@@ -0,0 +1,125 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+const userSessionColumns = "id, family_id, user_id, app_id, ip, user_agent, device, created_at, last_seen_at"
+
+func (s *Storage) SaveUserSession(ctx context.Context, session models.UserSession) error {
+	const op = "storage.sqlite.SaveUserSession"
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO user_sessions(family_id, user_id, app_id, ip, user_agent, device, created_at, last_seen_at)
+		values(?,?,?,?,?,?,?,?)`,
+		session.FamilyID,
+		session.UserID,
+		session.AppID,
+		session.IP,
+		session.UserAgent,
+		session.Device,
+		session.CreatedAt.UTC(),
+		session.LastSeenAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TouchUserSession records a use of the session of the token family.
+func (s *Storage) TouchUserSession(
+	ctx context.Context,
+	familyID string,
+	ip string,
+	userAgent string,
+	device string,
+	at time.Time,
+) error {
+	const op = "storage.sqlite.TouchUserSession"
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE user_sessions SET ip = ?, user_agent = ?, device = ?, last_seen_at = ? WHERE family_id = ?",
+		ip, userAgent, device, at.UTC(), familyID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UserSession returns the session with the id, active or not.
+func (s *Storage) UserSession(ctx context.Context, id int64) (models.UserSession, error) {
+	const op = "storage.sqlite.UserSession"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+userSessionColumns+" FROM user_sessions WHERE id = ?", id)
+
+	session, err := scanUserSession(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.UserSession{}, fmt.Errorf("%s: %w", op, storage.ErrUserSessionNotFound)
+		}
+		return models.UserSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, nil
+}
+
+// ActiveUserSessions returns the sessions of the user whose token family
+// still has a usable refresh token, the most recently used first.
+func (s *Storage) ActiveUserSessions(ctx context.Context, userID int64) ([]models.UserSession, error) {
+	const op = "storage.sqlite.ActiveUserSessions"
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+userSessionColumns+` FROM user_sessions s
+		WHERE s.user_id = ? AND EXISTS (
+			SELECT 1 FROM refresh_tokens t
+			WHERE t.family_id = s.family_id AND t.rotated_at IS NULL AND t.revoked_at IS NULL AND t.expires_at > ?
+		)
+		ORDER BY s.last_seen_at DESC, s.id DESC`,
+		userID, time.Now().UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var sessions []models.UserSession
+	for rows.Next() {
+		session, err := scanUserSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+func scanUserSession(row scanner) (models.UserSession, error) {
+	var session models.UserSession
+	err := row.Scan(
+		&session.ID,
+		&session.FamilyID,
+		&session.UserID,
+		&session.AppID,
+		&session.IP,
+		&session.UserAgent,
+		&session.Device,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+	)
+
+	return session, err
+}
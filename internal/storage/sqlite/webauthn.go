@@ -0,0 +1,172 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+func (s *Storage) SaveWebAuthnCredential(ctx context.Context, credential models.WebAuthnCredential) error {
+	const op = "storage.sqlite.SaveWebAuthnCredential"
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO webauthn_credentials(user_id, credential_id, data, created_at) values(?,?,?,?)",
+		credential.UserID, credential.CredentialID, credential.Data, credential.CreatedAt.UTC(),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, storage.ErrWebAuthnCredentialExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) WebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error) {
+	const op = "storage.sqlite.WebAuthnCredentials"
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, user_id, credential_id, data, created_at, last_used_at
+		FROM webauthn_credentials WHERE user_id = ? ORDER BY id`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var credentials []models.WebAuthnCredential
+	for rows.Next() {
+		var credential models.WebAuthnCredential
+		var lastUsedAt sql.NullTime
+		err = rows.Scan(
+			&credential.ID,
+			&credential.UserID,
+			&credential.CredentialID,
+			&credential.Data,
+			&credential.CreatedAt,
+			&lastUsedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if lastUsedAt.Valid {
+			credential.LastUsedAt = &lastUsedAt.Time
+		}
+
+		credentials = append(credentials, credential)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return credentials, nil
+}
+
+// UpdateWebAuthnCredential stores the credential record after a login, with
+// the new signature counter.
+func (s *Storage) UpdateWebAuthnCredential(ctx context.Context, credentialID []byte, data []byte, usedAt time.Time) error {
+	const op = "storage.sqlite.UpdateWebAuthnCredential"
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE webauthn_credentials SET data = ?, last_used_at = ? WHERE credential_id = ?",
+		data, usedAt.UTC(), credentialID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveWebAuthnSession(ctx context.Context, session models.WebAuthnSession) error {
+	const op = "storage.sqlite.SaveWebAuthnSession"
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO webauthn_sessions(token_hash, ceremony, user_id, app_id, scopes, data, expires_at, created_at)
+		values(?,?,?,?,?,?,?,?)`,
+		session.TokenHash, string(session.Ceremony), session.UserID, session.AppID, strings.Join(session.Scopes, " "),
+		session.Data, session.ExpiresAt.UTC(), session.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) WebAuthnSession(ctx context.Context, tokenHash string) (models.WebAuthnSession, error) {
+	const op = "storage.sqlite.WebAuthnSession"
+
+	stmt, err := s.db.PrepareContext(ctx, `SELECT id, token_hash, ceremony, user_id, app_id, scopes, data, expires_at, created_at, used_at
+		FROM webauthn_sessions WHERE token_hash = ?`)
+	if err != nil {
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, tokenHash)
+
+	var session models.WebAuthnSession
+	var ceremony, scopes string
+	var usedAt sql.NullTime
+	err = row.Scan(
+		&session.ID,
+		&session.TokenHash,
+		&ceremony,
+		&session.UserID,
+		&session.AppID,
+		&scopes,
+		&session.Data,
+		&session.ExpiresAt,
+		&session.CreatedAt,
+		&usedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, storage.ErrWebAuthnSessionNotFound)
+		}
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	session.Ceremony = models.WebAuthnCeremony(ceremony)
+	session.Scopes = strings.Fields(scopes)
+	if usedAt.Valid {
+		session.UsedAt = &usedAt.Time
+	}
+
+	return session, nil
+}
+
+// UseWebAuthnSession marks the ceremony as finished. It fails with
+// storage.ErrWebAuthnSessionUsed if the ceremony has already been finished.
+func (s *Storage) UseWebAuthnSession(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.UseWebAuthnSession"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE webauthn_sessions SET used_at = ? WHERE id = ? AND used_at IS NULL",
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebAuthnSessionUsed)
+	}
+
+	return nil
+}
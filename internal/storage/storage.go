@@ -1,9 +1,93 @@
 package storage
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrUserExists   = errors.New("user already exists")
 	ErrUserNotFound = errors.New("user not found")
+	ErrUserModified = errors.New("user modified concurrently")
 	ErrAppNotFound  = errors.New("application not found")
+	ErrAppExists    = errors.New("application already exists")
+	ErrOrgNotFound  = errors.New("organization not found")
+	ErrOrgExists    = errors.New("organization already exists")
+
+	// ErrAppDisabled wraps ErrAppNotFound, as disabled apps are not found
+	// by those who do not tell them apart.
+	ErrAppDisabled = fmt.Errorf("%w: application is disabled", ErrAppNotFound)
+
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenRotated  = errors.New("refresh token already rotated")
+
+	ErrDeviceCodeExists   = errors.New("device code already exists")
+	ErrDeviceCodeNotFound = errors.New("device code not found")
+
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrUserSessionNotFound = errors.New("user session not found")
+
+	ErrClaimMappingNotFound = errors.New("claim mapping not found")
+
+	ErrPasswordResetTokenNotFound = errors.New("password reset token not found")
+	ErrPasswordResetTokenUsed     = errors.New("password reset token already used")
+
+	ErrVerificationTokenNotFound = errors.New("email verification token not found")
+	ErrVerificationTokenUsed     = errors.New("email verification token already used")
+
+	ErrEmailChangeTokenNotFound = errors.New("email change token not found")
+	ErrEmailChangeTokenUsed     = errors.New("email change token already used")
+
+	ErrTOTPExists            = errors.New("totp already enabled")
+	ErrTOTPNotFound          = errors.New("totp not found")
+	ErrTOTPCodeUsed          = errors.New("totp code already used")
+	ErrMFAChallengeNotFound  = errors.New("mfa challenge not found")
+	ErrMFAChallengeUsed      = errors.New("mfa challenge already used")
+	ErrRecoveryCodeNotFound  = errors.New("recovery code not found")
+	ErrTrustedDeviceNotFound = errors.New("trusted device not found")
+
+	ErrMagicLinkNotFound = errors.New("magic link not found")
+	ErrMagicLinkUsed     = errors.New("magic link already used")
+
+	ErrInvitationNotFound = errors.New("invitation not found")
+	ErrInvitationUsed     = errors.New("invitation already used")
+
+	ErrConsentNotFound = errors.New("consent not found")
+
+	ErrFederationStateNotFound  = errors.New("federation state not found")
+	ErrFederationStateUsed      = errors.New("federation state already used")
+	ErrUserIdentityExists       = errors.New("user identity already linked")
+	ErrUserIdentityNotFound     = errors.New("user identity not found")
+	ErrIdentityProviderNotFound = errors.New("identity provider not found")
+
+	ErrPhoneTaken      = errors.New("phone number already taken")
+	ErrUsernameTaken   = errors.New("username already taken")
+	ErrSMSCodeNotFound = errors.New("sms code not found")
+	ErrSMSCodeUsed     = errors.New("sms code already used")
+
+	ErrWebAuthnCredentialExists = errors.New("webauthn credential already exists")
+	ErrWebAuthnSessionNotFound  = errors.New("webauthn session not found")
+	ErrWebAuthnSessionUsed      = errors.New("webauthn session already used")
+
+	ErrNotificationPreferencesNotFound = errors.New("notification preferences not found")
+
+	ErrRoleExists   = errors.New("role already exists")
+	ErrRoleNotFound = errors.New("role not found")
+
+	ErrGroupExists   = errors.New("group already exists")
+	ErrGroupNotFound = errors.New("group not found")
+
+	ErrPolicyRuleNotFound = errors.New("policy rule not found")
+
+	ErrAPIKeyNotFound = errors.New("api key not found")
+
+	// ErrFilterUnsupported is what listings fail with when the storage
+	// cannot apply a filter, as with encrypted columns.
+	ErrFilterUnsupported = errors.New("filter not supported")
+
+	// ErrDeadlineExceeded is what queries which ran out of time fail with,
+	// be it the query timeout or the deadline of the caller. It wraps
+	// context.DeadlineExceeded, which callers may check for instead.
+	ErrDeadlineExceeded = fmt.Errorf("storage: %w", context.DeadlineExceeded)
 )
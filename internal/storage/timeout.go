@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The timeout wrappers run every statement sent over the connections of a
+// connector with its own deadline, so that each query of a transaction gets
+// the whole timeout rather than a share of one set for the transaction.
+// Rows keep the deadline of their query until they are closed.
+
+// withQueryTimeout returns the connector with every statement limited to
+// the timeout, or the connector itself if the timeout is zero.
+func withQueryTimeout(connector driver.Connector, timeout time.Duration) driver.Connector {
+	if timeout <= 0 {
+		return connector
+	}
+
+	return timeoutConnector{Connector: connector, timeout: timeout}
+}
+
+type timeoutConnector struct {
+	driver.Connector
+	timeout time.Duration
+}
+
+func (c timeoutConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &timeoutConn{conn: conn, timeout: c.timeout}, nil
+}
+
+// deadlineError tells the error of a statement which ran out of time apart,
+// as ErrDeadlineExceeded. The error of the driver is kept as text only, as
+// database/sql retries on some of them, which it must not once time is up.
+func deadlineError(ctx context.Context, err error) error {
+	if err == nil || err == driver.ErrSkip || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrDeadlineExceeded
+	}
+
+	return fmt.Errorf("%w: %s", ErrDeadlineExceeded, err.Error())
+}
+
+// timeoutConn implements the optional interfaces database/sql looks for,
+// falling back to what it does without them if the wrapped connection does
+// not implement one.
+type timeoutConn struct {
+	conn    driver.Conn
+	timeout time.Duration
+}
+
+func (c *timeoutConn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := c.conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &timeoutStmt{stmt: stmt, conn: c.conn, timeout: c.timeout}, nil
+}
+
+func (c *timeoutConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	preparer, ok := c.conn.(driver.ConnPrepareContext)
+	if !ok {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return c.Prepare(query)
+	}
+
+	stmt, err := preparer.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &timeoutStmt{stmt: stmt, conn: c.conn, timeout: c.timeout}, nil
+}
+
+func (c *timeoutConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *timeoutConn) Begin() (driver.Tx, error) {
+	return c.conn.Begin()
+}
+
+func (c *timeoutConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errors.New("driver does not support isolation levels or read-only transactions")
+	}
+
+	return c.conn.Begin()
+}
+
+func (c *timeoutConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	res, err := execer.ExecContext(ctx, query, args)
+
+	return res, deadlineError(ctx, err)
+}
+
+func (c *timeoutConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		cancel()
+		return nil, deadlineError(ctx, err)
+	}
+
+	return &timeoutRows{rows: rows, ctx: ctx, cancel: cancel}, nil
+}
+
+func (c *timeoutConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *timeoutConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *timeoutConn) IsValid() bool {
+	if validator, ok := c.conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+
+	return true
+}
+
+func (c *timeoutConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+type timeoutStmt struct {
+	stmt    driver.Stmt
+	conn    driver.Conn
+	timeout time.Duration
+}
+
+func (s *timeoutStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *timeoutStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *timeoutStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.stmt.Exec(args)
+}
+
+func (s *timeoutStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.stmt.Query(args)
+}
+
+func (s *timeoutStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var res driver.Result
+	var err error
+	if execer, ok := s.stmt.(driver.StmtExecContext); ok {
+		res, err = execer.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			res, err = s.stmt.Exec(values)
+		}
+	}
+
+	return res, deadlineError(ctx, err)
+}
+
+func (s *timeoutStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			rows, err = s.stmt.Query(values)
+		}
+	}
+	if err != nil {
+		cancel()
+		return nil, deadlineError(ctx, err)
+	}
+
+	return &timeoutRows{rows: rows, ctx: ctx, cancel: cancel}, nil
+}
+
+// CheckNamedValue checks the argument as the statement does, or else as its
+// connection does, which database/sql would ask if the statement could not.
+func (s *timeoutStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	if checker, ok := s.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+func (s *timeoutStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if converter, ok := s.stmt.(driver.ColumnConverter); ok {
+		return converter.ColumnConverter(idx)
+	}
+
+	return driver.DefaultParameterConverter
+}
+
+// namedValues returns the values of the arguments, which statements
+// without StmtExecContext or StmtQueryContext take by position only.
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("driver does not support the use of named parameters")
+		}
+		values[i] = arg.Value
+	}
+
+	return values, nil
+}
+
+type timeoutRows struct {
+	rows   driver.Rows
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (r *timeoutRows) Columns() []string {
+	return r.rows.Columns()
+}
+
+func (r *timeoutRows) Close() error {
+	defer r.cancel()
+
+	return r.rows.Close()
+}
+
+func (r *timeoutRows) Next(dest []driver.Value) error {
+	err := r.rows.Next(dest)
+	if err == io.EOF {
+		return err
+	}
+
+	return deadlineError(r.ctx, err)
+}
@@ -0,0 +1,97 @@
+package transfer
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// timeLayouts are the ways SQLite keeps times as text, those the storage
+// writes and those of CURRENT_TIMESTAMP, and the text forms of the others.
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// convert converts a value read from the source into one the driver of the
+// target takes for a column of the kind, as booleans are integers in
+// SQLite.
+func convert(v any, k kind) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch k {
+	case kindBool:
+		switch x := v.(type) {
+		case bool:
+			return x, nil
+		case int64:
+			return x != 0, nil
+		case []byte:
+			return strconv.ParseBool(string(x))
+		case string:
+			return strconv.ParseBool(x)
+		}
+	case kindInt:
+		switch x := v.(type) {
+		case int64:
+			return x, nil
+		case bool:
+			if x {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case []byte:
+			return strconv.ParseInt(string(x), 10, 64)
+		case string:
+			return strconv.ParseInt(x, 10, 64)
+		}
+	case kindTime:
+		switch x := v.(type) {
+		case time.Time:
+			return x.UTC(), nil
+		case []byte:
+			return parseTime(string(x))
+		case string:
+			return parseTime(x)
+		}
+	case kindBytes:
+		switch x := v.(type) {
+		case []byte:
+			return x, nil
+		case string:
+			return []byte(x), nil
+		}
+	case kindText:
+		switch x := v.(type) {
+		case string:
+			return x, nil
+		case []byte:
+			return string(x), nil
+		case int64:
+			return strconv.FormatInt(x, 10), nil
+		case time.Time:
+			return x.UTC().Format(time.RFC3339Nano), nil
+		}
+	default:
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("can not convert %T to %s", v, k)
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("can not parse time %q", s)
+}
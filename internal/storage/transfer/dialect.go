@@ -0,0 +1,243 @@
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sso/internal/storage/factory"
+	"strings"
+)
+
+// kind is how values of a column are converted for the database they are
+// copied into.
+type kind int
+
+const (
+	kindOther kind = iota
+	kindInt
+	kindBool
+	kindTime
+	kindBytes
+	kindText
+)
+
+func (k kind) String() string {
+	switch k {
+	case kindInt:
+		return "integer"
+	case kindBool:
+		return "boolean"
+	case kindTime:
+		return "time"
+	case kindBytes:
+		return "binary"
+	case kindText:
+		return "text"
+	default:
+		return "other"
+	}
+}
+
+// kindOf tells the kind of a column from the type it is declared with.
+func kindOf(declared string) kind {
+	t := strings.ToLower(declared)
+	switch {
+	case strings.Contains(t, "bool"):
+		return kindBool
+	case strings.Contains(t, "int"):
+		return kindInt
+	case strings.Contains(t, "time"), strings.Contains(t, "date"):
+		return kindTime
+	case strings.Contains(t, "blob"), strings.Contains(t, "bytea"), strings.Contains(t, "binary"):
+		return kindBytes
+	case strings.Contains(t, "char"), strings.Contains(t, "text"), strings.Contains(t, "json"):
+		return kindText
+	default:
+		return kindOther
+	}
+}
+
+type column struct {
+	name string
+	kind kind
+}
+
+// table is a table as it is copied: its columns without the generated
+// ones, and the tables its foreign keys reference.
+type table struct {
+	name    string
+	columns []column
+	refs    []string
+	// identity is the column whose sequence hands out ids, if the database
+	// has to be told of the ids copied into it.
+	identity string
+}
+
+func (t *table) column(name string) (column, bool) {
+	for _, c := range t.columns {
+		if c.name == name {
+			return c, true
+		}
+	}
+
+	return column{}, false
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// dialect is what the drivers differ in when copying: how the schema is
+// read, how parameters are written and how transactions are begun.
+type dialect interface {
+	tables(ctx context.Context, q querier) (map[string]*table, error)
+	placeholder(n int) string
+	readOptions() *sql.TxOptions
+	// afterCopy brings the table in line with the rows copied into it.
+	afterCopy(ctx context.Context, q querier, t *table) error
+}
+
+func dialectOf(driver string) (dialect, error) {
+	switch driver {
+	case factory.DriverSQLite:
+		return sqliteDialect{}, nil
+	default:
+		return nil, fmt.Errorf("the %s driver has no database to copy", driver)
+	}
+}
+
+// quote quotes the identifier.
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) tables(ctx context.Context, q querier) (map[string]*table, error) {
+	rows, err := q.QueryContext(ctx, `SELECT name, COALESCE(sql, '') FROM sqlite_master WHERE type = 'table'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names, virtual []string
+	for rows.Next() {
+		var name, ddl string
+		if err = rows.Scan(&name, &ddl); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(strings.ToUpper(ddl), "CREATE VIRTUAL TABLE") {
+			virtual = append(virtual, name)
+			continue
+		}
+		names = append(names, name)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	tables := make(map[string]*table)
+	for _, name := range names {
+		// the tables of SQLite itself and those virtual tables keep their
+		// data in are filled by it
+		if strings.HasPrefix(name, "sqlite_") || shadowOf(name, virtual) {
+			continue
+		}
+
+		t := &table{name: name}
+		if t.columns, err = sqliteColumns(ctx, q, name); err != nil {
+			return nil, err
+		}
+		if t.refs, err = sqliteRefs(ctx, q, name); err != nil {
+			return nil, err
+		}
+		tables[name] = t
+	}
+
+	return tables, nil
+}
+
+func shadowOf(name string, virtual []string) bool {
+	for _, v := range virtual {
+		if strings.HasPrefix(name, v+"_") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sqliteColumns(ctx context.Context, q querier, name string) ([]column, error) {
+	rows, err := q.QueryContext(ctx, "SELECT name, type, hidden FROM pragma_table_xinfo(?)", name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []column
+	for rows.Next() {
+		var c, declared string
+		var hidden int
+		if err = rows.Scan(&c, &declared, &hidden); err != nil {
+			return nil, err
+		}
+		// hidden columns are generated
+		if hidden != 0 {
+			continue
+		}
+		columns = append(columns, column{name: c, kind: kindOf(declared)})
+	}
+
+	return columns, rows.Err()
+}
+
+func sqliteRefs(ctx context.Context, q querier, name string) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `SELECT DISTINCT "table" FROM pragma_foreign_key_list(?)`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []string
+	for rows.Next() {
+		var ref string
+		if err = rows.Scan(&ref); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+
+	return refs, rows.Err()
+}
+
+func (sqliteDialect) placeholder(int) string {
+	return "?"
+}
+
+func (sqliteDialect) readOptions() *sql.TxOptions {
+	return nil
+}
+
+// afterCopy does nothing, as SQLite goes on from the largest id there is.
+func (sqliteDialect) afterCopy(context.Context, querier, *table) error {
+	return nil
+}
+
+// each calls fn with the scan of every row the query returns.
+func each(ctx context.Context, q querier, query string, fn func(scan func(...any) error) error) error {
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = fn(rows.Scan); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
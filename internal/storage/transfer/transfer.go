@@ -0,0 +1,390 @@
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sso/internal/storage/factory"
+	"strings"
+)
+
+var (
+	ErrTargetNotEmpty = errors.New("target database already has users")
+	ErrSchemaMismatch = errors.New("schemas of the databases differ")
+	ErrIntegrity      = errors.New("integrity check failed")
+)
+
+// triggerFilled are the tables triggers of the schema insert into as other
+// tables are, which are copied last, once what the triggers inserted while
+// copying is deleted.
+var triggerFilled = []string{"outbox_events"}
+
+// Database is a database of one of the drivers of factory.
+type Database struct {
+	Driver string
+	DB     *sql.DB
+}
+
+// Report tells how many rows of each table were copied, in the order they
+// were copied in.
+type Report struct {
+	Tables []TableReport
+	// Skipped are the tables of the source the target has no counterpart
+	// of, such as those of SQLite full-text indexes.
+	Skipped []string
+}
+
+type TableReport struct {
+	Table string
+	Rows  int64
+}
+
+// Copy copies the rows of every table of the target from the source, with
+// their ids, in a transaction of each database, so that the copy is of one
+// point in time and is committed in whole or not at all. Both databases
+// must be migrated to the latest schema of their drivers, and the target
+// must have no users; the rows the migrations seed it with are replaced.
+// Tables named in exclude, such as those of migrations, are left alone.
+//
+// Before committing, the row count and the sum of the ids of every table
+// are checked against the source. SQLite sources are checked for
+// corruption and rows breaking foreign keys first.
+func Copy(ctx context.Context, from Database, to Database, exclude []string) (Report, error) {
+	const op = "storage.transfer.Copy"
+
+	fromDialect, err := dialectOf(from.Driver)
+	if err != nil {
+		return Report{}, fmt.Errorf("%s: source: %w", op, err)
+	}
+	toDialect, err := dialectOf(to.Driver)
+	if err != nil {
+		return Report{}, fmt.Errorf("%s: target: %w", op, err)
+	}
+
+	if from.Driver == factory.DriverSQLite {
+		if err = checkSQLite(ctx, from.DB); err != nil {
+			return Report{}, fmt.Errorf("%s: source: %w", op, err)
+		}
+	}
+
+	src, err := from.DB.BeginTx(ctx, fromDialect.readOptions())
+	if err != nil {
+		return Report{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = src.Rollback() }()
+
+	dst, err := to.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Report{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = dst.Rollback() }()
+
+	srcTables, err := fromDialect.tables(ctx, src)
+	if err != nil {
+		return Report{}, fmt.Errorf("%s: source: %w", op, err)
+	}
+	dstTables, err := toDialect.tables(ctx, dst)
+	if err != nil {
+		return Report{}, fmt.Errorf("%s: target: %w", op, err)
+	}
+	for _, name := range exclude {
+		delete(srcTables, name)
+		delete(dstTables, name)
+	}
+
+	var report Report
+	for name := range srcTables {
+		if _, ok := dstTables[name]; !ok {
+			report.Skipped = append(report.Skipped, name)
+		}
+	}
+	slices.Sort(report.Skipped)
+
+	if err = match(srcTables, dstTables); err != nil {
+		return Report{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	order, err := copyOrder(dstTables)
+	if err != nil {
+		return Report{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, ok := dstTables["users"]; ok {
+		users, _, err := count(ctx, dst, dstTables["users"])
+		if err != nil {
+			return Report{}, fmt.Errorf("%s: target: %w", op, err)
+		}
+		if users > 0 {
+			return Report{}, fmt.Errorf("%s: %w", op, ErrTargetNotEmpty)
+		}
+	}
+
+	// the rows the migrations seed the target with go, referencing tables
+	// after those they reference
+	for i := len(order) - 1; i >= 0; i-- {
+		if _, err = dst.ExecContext(ctx, "DELETE FROM "+quote(order[i].name)); err != nil {
+			return Report{}, fmt.Errorf("%s: target: %s: %w", op, order[i].name, err)
+		}
+	}
+
+	for _, t := range order {
+		if slices.Contains(triggerFilled, t.name) {
+			if _, err = dst.ExecContext(ctx, "DELETE FROM "+quote(t.name)); err != nil {
+				return Report{}, fmt.Errorf("%s: target: %s: %w", op, t.name, err)
+			}
+		}
+
+		rows, err := copyTable(ctx, src, dst, toDialect, srcTables[t.name], t)
+		if err != nil {
+			return Report{}, fmt.Errorf("%s: %s: %w", op, t.name, err)
+		}
+		if err = toDialect.afterCopy(ctx, dst, t); err != nil {
+			return Report{}, fmt.Errorf("%s: target: %s: %w", op, t.name, err)
+		}
+		report.Tables = append(report.Tables, TableReport{Table: t.name, Rows: rows})
+	}
+
+	for _, t := range report.Tables {
+		if err = verify(ctx, src, dst, srcTables[t.Table], dstTables[t.Table], t.Rows); err != nil {
+			return Report{}, fmt.Errorf("%s: %s: %w", op, t.Table, err)
+		}
+	}
+
+	if err = dst.Commit(); err != nil {
+		return Report{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return report, nil
+}
+
+// checkSQLite checks the database for corruption and for rows breaking
+// foreign keys, which older databases may have from before they were
+// enforced.
+func checkSQLite(ctx context.Context, db *sql.DB) error {
+	var result string
+	if err := db.QueryRowContext(ctx, "PRAGMA integrity_check(1)").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("%w: %s", ErrIntegrity, result)
+	}
+
+	var broken []string
+	err := each(ctx, db, `SELECT DISTINCT "table" FROM pragma_foreign_key_check`, func(scan func(...any) error) error {
+		var name string
+		if err := scan(&name); err != nil {
+			return err
+		}
+		broken = append(broken, name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(broken) > 0 {
+		return fmt.Errorf("%w: rows of %s reference rows which do not exist", ErrIntegrity, strings.Join(broken, ", "))
+	}
+
+	return nil
+}
+
+// match checks that every table of the target is in the source with the
+// same columns, so that nothing is left behind or made up.
+func match(src map[string]*table, dst map[string]*table) error {
+	var problems []string
+	for _, name := range sortedNames(dst) {
+		s, ok := src[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("table %s is missing from the source", name))
+			continue
+		}
+		for _, c := range dst[name].columns {
+			if _, ok = s.column(c.name); !ok {
+				problems = append(problems, fmt.Sprintf("column %s.%s is missing from the source", name, c.name))
+			}
+		}
+		for _, c := range s.columns {
+			if _, ok = dst[name].column(c.name); !ok {
+				problems = append(problems, fmt.Sprintf("column %s.%s is missing from the target", name, c.name))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s; migrate both to the latest schema", ErrSchemaMismatch, strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// copyOrder orders the tables so that every table comes after those its
+// foreign keys reference, and the tables filled by triggers come last.
+func copyOrder(tables map[string]*table) ([]*table, error) {
+	var order []*table
+	done := make(map[string]bool)
+	for len(order) < len(tables) {
+		progressed := false
+		for _, name := range sortedNames(tables) {
+			if done[name] || slices.Contains(triggerFilled, name) && !onlyTriggerFilledLeft(tables, done) {
+				continue
+			}
+			ready := true
+			for _, ref := range tables[name].refs {
+				if _, ok := tables[ref]; ok && ref != name && !done[ref] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				order = append(order, tables[name])
+				done[name] = true
+				progressed = true
+			}
+		}
+		if !progressed {
+			var left []string
+			for _, name := range sortedNames(tables) {
+				if !done[name] {
+					left = append(left, name)
+				}
+			}
+			return nil, fmt.Errorf("foreign keys of %s reference each other", strings.Join(left, ", "))
+		}
+	}
+
+	return order, nil
+}
+
+func onlyTriggerFilledLeft(tables map[string]*table, done map[string]bool) bool {
+	for name := range tables {
+		if !done[name] && !slices.Contains(triggerFilled, name) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sortedNames(tables map[string]*table) []string {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// batchParams caps the parameters of an insert, well under the limits of
+// every driver.
+const batchParams = 500
+
+// copyTable copies the rows of the table from src into dst, and returns how
+// many it copied.
+func copyTable(ctx context.Context, src querier, dst querier, d dialect, from *table, to *table) (int64, error) {
+	names := make([]string, len(to.columns))
+	for i, c := range to.columns {
+		names[i] = quote(c.name)
+	}
+	columns := strings.Join(names, ", ")
+
+	rows, err := src.QueryContext(ctx, "SELECT "+columns+" FROM "+quote(from.name))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	batch := max(1, batchParams/len(to.columns))
+	args := make([]any, 0, batch*len(to.columns))
+	flush := func() error {
+		if len(args) == 0 {
+			return nil
+		}
+		values := make([]string, 0, len(args)/len(to.columns))
+		for i := 0; i < len(args); i += len(to.columns) {
+			params := make([]string, len(to.columns))
+			for j := range params {
+				params[j] = d.placeholder(i + j + 1)
+			}
+			values = append(values, "("+strings.Join(params, ", ")+")")
+		}
+
+		_, err := dst.ExecContext(ctx,
+			"INSERT INTO "+quote(to.name)+" ("+columns+") VALUES "+strings.Join(values, ", "),
+			args...,
+		)
+		args = args[:0]
+
+		return err
+	}
+
+	var copied int64
+	values := make([]any, len(to.columns))
+	dest := make([]any, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return copied, err
+		}
+		for i, c := range to.columns {
+			v, err := convert(values[i], c.kind)
+			if err != nil {
+				return copied, fmt.Errorf("column %s: %w", c.name, err)
+			}
+			args = append(args, v)
+		}
+		copied++
+
+		if len(args) >= batch*len(to.columns) {
+			if err = flush(); err != nil {
+				return copied, err
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return copied, err
+	}
+
+	return copied, flush()
+}
+
+// verify checks that the target has as many rows of the table as were
+// copied and as the source has, with the same ids.
+func verify(ctx context.Context, src querier, dst querier, from *table, to *table, copied int64) error {
+	srcRows, srcIDs, err := count(ctx, src, from)
+	if err != nil {
+		return err
+	}
+	dstRows, dstIDs, err := count(ctx, dst, to)
+	if err != nil {
+		return err
+	}
+
+	if srcRows != copied || dstRows != copied {
+		return fmt.Errorf("%w: the source has %d rows, %d were copied and the target has %d", ErrIntegrity, srcRows, copied, dstRows)
+	}
+	if srcIDs != dstIDs {
+		return fmt.Errorf("%w: the ids of the target differ from those of the source", ErrIntegrity)
+	}
+
+	return nil
+}
+
+// count returns the rows of the table, and the sum of their ids if it has
+// an id column.
+func count(ctx context.Context, q querier, t *table) (int64, int64, error) {
+	ids := "0"
+	if c, ok := t.column("id"); ok && c.kind == kindInt {
+		ids = "COALESCE(SUM(" + quote("id") + "), 0)"
+	}
+
+	var rows, sum int64
+	err := each(ctx, q, "SELECT COUNT(*), "+ids+" FROM "+quote(t.name), func(scan func(...any) error) error {
+		return scan(&rows, &sum)
+	})
+
+	return rows, sum, err
+}
@@ -0,0 +1 @@
+ALTER TABLE apps DROP COLUMN token_format;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE apps
+    ADD COLUMN token_format TEXT NOT NULL DEFAULT '';
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS sessions
+(
+    token_hash TEXT     PRIMARY KEY,
+    app_id     INTEGER  NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    claims     TEXT     NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions (expires_at);
\ No newline at end of file
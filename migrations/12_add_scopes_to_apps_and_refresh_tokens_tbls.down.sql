@@ -0,0 +1,2 @@
+ALTER TABLE refresh_tokens DROP COLUMN scopes;
+ALTER TABLE apps DROP COLUMN scopes;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE apps
+    ADD COLUMN scopes TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens
+    ADD COLUMN scopes TEXT NOT NULL DEFAULT '';
\ No newline at end of file
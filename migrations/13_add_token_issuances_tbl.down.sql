@@ -0,0 +1 @@
+DROP TABLE IF EXISTS token_issuances;
\ No newline at end of file
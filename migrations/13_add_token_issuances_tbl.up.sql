@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS token_issuances
+(
+    id         TEXT     PRIMARY KEY,
+    user_id    INTEGER,
+    app_id     INTEGER  NOT NULL,
+    issued_at  DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_token_issuances_user_id ON token_issuances (user_id);
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS audit_events
+(
+    id         INTEGER  PRIMARY KEY,
+    type       TEXT     NOT NULL,
+    user_id    INTEGER,
+    app_id     INTEGER,
+    token_id   TEXT,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_user_id ON audit_events (user_id);
\ No newline at end of file
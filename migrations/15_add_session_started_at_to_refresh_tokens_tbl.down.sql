@@ -0,0 +1 @@
+ALTER TABLE refresh_tokens DROP COLUMN session_started_at;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE refresh_tokens
+    ADD COLUMN session_started_at DATETIME;
+UPDATE refresh_tokens
+SET session_started_at = created_at;
\ No newline at end of file
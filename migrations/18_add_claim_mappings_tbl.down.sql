@@ -0,0 +1 @@
+DROP TABLE IF EXISTS claim_mappings;
\ No newline at end of file
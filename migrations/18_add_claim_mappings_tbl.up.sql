@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS claim_mappings
+(
+    app_id INTEGER NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    source TEXT    NOT NULL,
+    target TEXT    NOT NULL,
+    PRIMARY KEY (app_id, source)
+);
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS password_reset_tokens
+(
+    id         INTEGER PRIMARY KEY,
+    token_hash TEXT     NOT NULL UNIQUE,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    used_at    DATETIME
+);
\ No newline at end of file
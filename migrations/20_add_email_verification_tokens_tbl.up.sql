@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS email_verification_tokens
+(
+    id         INTEGER PRIMARY KEY,
+    token_hash TEXT     NOT NULL UNIQUE,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email      TEXT     NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    used_at    DATETIME
+);
\ No newline at end of file
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS user_totp
+(
+    user_id        INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret         TEXT     NOT NULL,
+    last_used_step INTEGER  NOT NULL DEFAULT 0,
+    created_at     DATETIME NOT NULL,
+    confirmed_at   DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS mfa_challenges
+(
+    id         INTEGER PRIMARY KEY,
+    token_hash TEXT     NOT NULL UNIQUE,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id     INTEGER  NOT NULL,
+    scopes     TEXT     NOT NULL DEFAULT '',
+    attempts   INTEGER  NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    used_at    DATETIME
+);
\ No newline at end of file
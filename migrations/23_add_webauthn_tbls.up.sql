@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS webauthn_credentials
+(
+    id            INTEGER PRIMARY KEY,
+    user_id       INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    credential_id BLOB     NOT NULL UNIQUE,
+    data          BLOB     NOT NULL,
+    created_at    DATETIME NOT NULL,
+    last_used_at  DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials (user_id);
+
+CREATE TABLE IF NOT EXISTS webauthn_sessions
+(
+    id         INTEGER PRIMARY KEY,
+    token_hash TEXT     NOT NULL UNIQUE,
+    ceremony   TEXT     NOT NULL,
+    user_id    INTEGER  NOT NULL DEFAULT 0,
+    app_id     INTEGER  NOT NULL DEFAULT 0,
+    scopes     TEXT     NOT NULL DEFAULT '',
+    data       BLOB     NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    used_at    DATETIME
+);
\ No newline at end of file
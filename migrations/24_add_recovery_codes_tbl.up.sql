@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS recovery_codes
+(
+    id         INTEGER PRIMARY KEY,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash  TEXT     NOT NULL,
+    created_at DATETIME NOT NULL,
+    used_at    DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
\ No newline at end of file
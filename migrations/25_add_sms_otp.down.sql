@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS sms_codes;
+ALTER TABLE mfa_challenges DROP COLUMN amr;
+DROP INDEX IF EXISTS idx_users_phone;
+ALTER TABLE users DROP COLUMN phone_verified;
+ALTER TABLE users DROP COLUMN phone;
\ No newline at end of file
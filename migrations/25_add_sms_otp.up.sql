@@ -0,0 +1,22 @@
+ALTER TABLE users
+    ADD COLUMN phone TEXT;
+ALTER TABLE users
+    ADD COLUMN phone_verified INTEGER NOT NULL DEFAULT 0;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_phone ON users (phone);
+
+ALTER TABLE mfa_challenges
+    ADD COLUMN amr TEXT NOT NULL DEFAULT 'pwd';
+
+CREATE TABLE IF NOT EXISTS sms_codes
+(
+    id         INTEGER PRIMARY KEY,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    phone      TEXT     NOT NULL,
+    purpose    TEXT     NOT NULL,
+    code_hash  TEXT     NOT NULL,
+    attempts   INTEGER  NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    used_at    DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_sms_codes_user_id ON sms_codes (user_id, purpose);
\ No newline at end of file
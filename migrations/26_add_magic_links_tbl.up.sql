@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS magic_links
+(
+    id         INTEGER PRIMARY KEY,
+    token_hash TEXT     NOT NULL UNIQUE,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id     INTEGER  NOT NULL,
+    scopes     TEXT     NOT NULL DEFAULT '',
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    used_at    DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_magic_links_user_id ON magic_links (user_id, created_at);
\ No newline at end of file
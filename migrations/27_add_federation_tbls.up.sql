@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS federation_states
+(
+    id            INTEGER PRIMARY KEY,
+    state_hash    TEXT     NOT NULL UNIQUE,
+    provider      TEXT     NOT NULL,
+    app_id        INTEGER  NOT NULL,
+    scopes        TEXT     NOT NULL DEFAULT '',
+    nonce         TEXT     NOT NULL,
+    code_verifier TEXT     NOT NULL,
+    expires_at    DATETIME NOT NULL,
+    created_at    DATETIME NOT NULL,
+    used_at       DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS user_identities
+(
+    id         INTEGER PRIMARY KEY,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider   TEXT     NOT NULL,
+    subject    TEXT     NOT NULL,
+    email      TEXT     NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    UNIQUE (provider, subject)
+);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS identity_providers;
\ No newline at end of file
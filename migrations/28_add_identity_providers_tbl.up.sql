@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS identity_providers
+(
+    id                   INTEGER PRIMARY KEY,
+    name                 TEXT     NOT NULL UNIQUE,
+    issuer               TEXT     NOT NULL,
+    client_id            TEXT     NOT NULL,
+    client_secret        TEXT     NOT NULL,
+    scopes               TEXT     NOT NULL DEFAULT '',
+    subject_claim        TEXT     NOT NULL DEFAULT 'sub',
+    email_claim          TEXT     NOT NULL DEFAULT 'email',
+    email_verified_claim TEXT     NOT NULL DEFAULT 'email_verified',
+    name_claim           TEXT     NOT NULL DEFAULT 'name',
+    created_at           DATETIME NOT NULL
+);
\ No newline at end of file
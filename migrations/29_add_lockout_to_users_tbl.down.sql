@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN locked_until;
+ALTER TABLE users DROP COLUMN failed_logins_since;
+ALTER TABLE users DROP COLUMN failed_logins;
\ No newline at end of file
@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN failed_logins INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users
+    ADD COLUMN failed_logins_since DATETIME;
+ALTER TABLE users
+    ADD COLUMN locked_until DATETIME;
\ No newline at end of file
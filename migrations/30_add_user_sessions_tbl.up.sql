@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS user_sessions
+(
+    id           INTEGER PRIMARY KEY,
+    family_id    TEXT     NOT NULL UNIQUE,
+    user_id      INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id       INTEGER  NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    ip           TEXT     NOT NULL,
+    user_agent   TEXT     NOT NULL,
+    device       TEXT     NOT NULL,
+    created_at   DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user_id ON user_sessions (user_id);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE audit_events DROP COLUMN actor_id;
\ No newline at end of file
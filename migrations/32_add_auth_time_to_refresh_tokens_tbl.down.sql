@@ -0,0 +1 @@
+ALTER TABLE refresh_tokens DROP COLUMN auth_time;
\ No newline at end of file
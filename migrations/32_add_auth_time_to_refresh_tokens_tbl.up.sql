@@ -0,0 +1,4 @@
+ALTER TABLE refresh_tokens
+    ADD COLUMN auth_time DATETIME;
+UPDATE refresh_tokens
+SET auth_time = session_started_at;
\ No newline at end of file
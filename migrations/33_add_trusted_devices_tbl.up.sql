@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS trusted_devices
+(
+    id               INTEGER PRIMARY KEY,
+    token_hash       TEXT     NOT NULL UNIQUE,
+    user_id          INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    fingerprint_hash TEXT     NOT NULL,
+    ip               TEXT     NOT NULL,
+    device           TEXT     NOT NULL,
+    created_at       DATETIME NOT NULL,
+    last_used_at     DATETIME NOT NULL,
+    expires_at       DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_trusted_devices_user_id ON trusted_devices (user_id);
\ No newline at end of file
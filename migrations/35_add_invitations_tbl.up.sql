@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS invitations
+(
+    id          INTEGER PRIMARY KEY,
+    token_hash  TEXT     NOT NULL UNIQUE,
+    email       TEXT     NOT NULL,
+    is_admin    INTEGER  NOT NULL DEFAULT 0,
+    app_id      INTEGER REFERENCES apps (id) ON DELETE CASCADE,
+    invited_by  INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at  DATETIME NOT NULL,
+    created_at  DATETIME NOT NULL,
+    accepted_at DATETIME,
+    user_id     INTEGER REFERENCES users (id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_invitations_email ON invitations (email COLLATE NOCASE);
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN deletion_scheduled_at DATETIME;
+ALTER TABLE users
+    ADD COLUMN deleted_at DATETIME;
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users (deletion_scheduled_at);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE users
+    ADD COLUMN is_guest INTEGER NOT NULL DEFAULT 0;
\ No newline at end of file
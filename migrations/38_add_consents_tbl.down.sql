@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS consents;
+ALTER TABLE apps DROP COLUMN third_party;
\ No newline at end of file
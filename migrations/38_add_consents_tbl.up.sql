@@ -0,0 +1,9 @@
+ALTER TABLE apps ADD COLUMN third_party INTEGER NOT NULL DEFAULT 0;
+CREATE TABLE IF NOT EXISTS consents
+(
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id     INTEGER  NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    scopes     TEXT     NOT NULL DEFAULT '',
+    granted_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, app_id)
+);
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS users_updated_at;
+DROP TRIGGER IF EXISTS users_created_at;
+ALTER TABLE users DROP COLUMN updated_at;
+ALTER TABLE users DROP COLUMN created_at;
\ No newline at end of file
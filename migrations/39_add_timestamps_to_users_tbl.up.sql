@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN created_at DATETIME;
+ALTER TABLE users ADD COLUMN updated_at DATETIME;
+CREATE TRIGGER IF NOT EXISTS users_created_at
+    AFTER INSERT
+    ON users
+    WHEN NEW.created_at IS NULL
+BEGIN
+    UPDATE users SET created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+CREATE TRIGGER IF NOT EXISTS users_updated_at
+    AFTER UPDATE OF email, username, pass_hash, email_verified, phone, phone_verified, is_admin, is_guest,
+    deletion_scheduled_at
+    ON users
+BEGIN
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
\ No newline at end of file
@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS users_updated_at;
+CREATE TRIGGER IF NOT EXISTS users_updated_at
+    AFTER UPDATE OF email, username, pass_hash, email_verified, phone, phone_verified, is_admin, is_guest,
+    deletion_scheduled_at
+    ON users
+BEGIN
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+ALTER TABLE users DROP COLUMN version;
+ALTER TABLE users DROP COLUMN display_name;
\ No newline at end of file
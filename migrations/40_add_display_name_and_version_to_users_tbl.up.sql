@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+DROP TRIGGER IF EXISTS users_updated_at;
+CREATE TRIGGER IF NOT EXISTS users_updated_at
+    AFTER UPDATE OF email, username, display_name, pass_hash, email_verified, phone, phone_verified, is_admin,
+    is_guest, deletion_scheduled_at
+    ON users
+BEGIN
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = NEW.id;
+END;
\ No newline at end of file
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users (COALESCE(created_at, ''));
+CREATE INDEX IF NOT EXISTS idx_users_email_nocase ON users (email COLLATE NOCASE);
\ No newline at end of file
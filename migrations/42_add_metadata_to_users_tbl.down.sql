@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS users_updated_at;
+CREATE TRIGGER IF NOT EXISTS users_updated_at
+    AFTER UPDATE OF email, username, display_name, pass_hash, email_verified, phone, phone_verified, is_admin,
+    is_guest, deletion_scheduled_at
+    ON users
+BEGIN
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = NEW.id;
+END;
+ALTER TABLE users DROP COLUMN user_metadata;
+ALTER TABLE users DROP COLUMN app_metadata;
\ No newline at end of file
@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN app_metadata TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE users ADD COLUMN user_metadata TEXT NOT NULL DEFAULT '{}';
+DROP TRIGGER IF EXISTS users_updated_at;
+CREATE TRIGGER IF NOT EXISTS users_updated_at
+    AFTER UPDATE OF email, username, display_name, pass_hash, email_verified, phone, phone_verified, is_admin,
+    is_guest, deletion_scheduled_at, app_metadata, user_metadata
+    ON users
+BEGIN
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = NEW.id;
+END;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN anonymized_at DATETIME;
+UPDATE users SET anonymized_at = deleted_at WHERE deleted_at IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN status;
\ No newline at end of file
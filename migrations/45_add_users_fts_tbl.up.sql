@@ -0,0 +1,23 @@
+CREATE VIRTUAL TABLE IF NOT EXISTS users_fts USING fts4(email, username, display_name, tokenize=unicode61);
+INSERT INTO users_fts(docid, email, username, display_name)
+SELECT id, email, COALESCE(username, ''), display_name FROM users;
+CREATE TRIGGER IF NOT EXISTS users_fts_insert
+    AFTER INSERT
+    ON users
+BEGIN
+    INSERT INTO users_fts(docid, email, username, display_name)
+    VALUES (NEW.id, NEW.email, COALESCE(NEW.username, ''), NEW.display_name);
+END;
+CREATE TRIGGER IF NOT EXISTS users_fts_update
+    AFTER UPDATE OF email, username, display_name
+    ON users
+BEGIN
+    UPDATE users_fts SET email = NEW.email, username = COALESCE(NEW.username, ''), display_name = NEW.display_name
+    WHERE docid = NEW.id;
+END;
+CREATE TRIGGER IF NOT EXISTS users_fts_delete
+    AFTER DELETE
+    ON users
+BEGIN
+    DELETE FROM users_fts WHERE docid = OLD.id;
+END;
\ No newline at end of file
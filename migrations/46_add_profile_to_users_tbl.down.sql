@@ -0,0 +1,12 @@
+DROP TRIGGER IF EXISTS users_updated_at;
+CREATE TRIGGER IF NOT EXISTS users_updated_at
+    AFTER UPDATE OF email, username, display_name, pass_hash, email_verified, phone, phone_verified, is_admin,
+    is_guest, deletion_scheduled_at, app_metadata, user_metadata
+    ON users
+BEGIN
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = NEW.id;
+END;
+ALTER TABLE users DROP COLUMN avatar_url;
+ALTER TABLE users DROP COLUMN locale;
+ALTER TABLE users DROP COLUMN last_name;
+ALTER TABLE users DROP COLUMN first_name;
\ No newline at end of file
@@ -0,0 +1,12 @@
+ALTER TABLE users ADD COLUMN first_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN last_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN locale TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+DROP TRIGGER IF EXISTS users_updated_at;
+CREATE TRIGGER IF NOT EXISTS users_updated_at
+    AFTER UPDATE OF email, username, display_name, pass_hash, email_verified, phone, phone_verified, is_admin,
+    is_guest, deletion_scheduled_at, app_metadata, user_metadata, first_name, last_name, locale, avatar_url
+    ON users
+BEGIN
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = NEW.id;
+END;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_email_key;
+ALTER TABLE users DROP COLUMN email_key;
\ No newline at end of file
@@ -0,0 +1,7 @@
+ALTER TABLE users ADD COLUMN email_key TEXT;
+UPDATE users SET email_key = lower(trim(email))
+WHERE is_guest = 0 AND anonymized_at IS NULL AND id = (
+    SELECT MIN(u.id) FROM users u
+    WHERE lower(trim(u.email)) = lower(trim(users.email)) AND u.is_guest = 0 AND u.anonymized_at IS NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_key ON users (email_key);
\ No newline at end of file
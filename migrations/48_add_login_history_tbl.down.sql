@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_login_history_user_id;
+DROP TABLE IF EXISTS login_history;
+ALTER TABLE users DROP COLUMN last_login_user_agent;
+ALTER TABLE users DROP COLUMN last_login_ip;
+ALTER TABLE users DROP COLUMN last_login_at;
\ No newline at end of file
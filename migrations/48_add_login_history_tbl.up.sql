@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN last_login_at DATETIME;
+ALTER TABLE users ADD COLUMN last_login_ip TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN last_login_user_agent TEXT NOT NULL DEFAULT '';
+CREATE TABLE IF NOT EXISTS login_history
+(
+    id         INTEGER PRIMARY KEY,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id     INTEGER  NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    ip         TEXT     NOT NULL,
+    user_agent TEXT     NOT NULL,
+    device     TEXT     NOT NULL,
+    amr        TEXT     NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_login_history_user_id ON login_history (user_id, id);
\ No newline at end of file
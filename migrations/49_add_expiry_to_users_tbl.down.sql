@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_expires_at;
+ALTER TABLE users DROP COLUMN expired_at;
+ALTER TABLE users DROP COLUMN expires_at;
\ No newline at end of file
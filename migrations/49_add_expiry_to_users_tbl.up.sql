@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN expires_at DATETIME;
+ALTER TABLE users ADD COLUMN expired_at DATETIME;
+CREATE INDEX IF NOT EXISTS idx_users_expires_at ON users (expires_at) WHERE expires_at IS NOT NULL AND expired_at IS NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN password_change_required BOOLEAN NOT NULL DEFAULT FALSE;
\ No newline at end of file
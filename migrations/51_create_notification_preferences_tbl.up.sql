@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS notification_preferences
+(
+    user_id         INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    security_emails BOOLEAN  NOT NULL,
+    login_alerts    BOOLEAN  NOT NULL,
+    marketing       BOOLEAN  NOT NULL,
+    updated_at      DATETIME NOT NULL
+);
\ No newline at end of file
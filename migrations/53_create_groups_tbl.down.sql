@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_group_members_user_id;
+DROP TABLE IF EXISTS group_members;
+DROP TABLE IF EXISTS group_roles;
+DROP TABLE IF EXISTS groups;
\ No newline at end of file
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS groups
+(
+    id          INTEGER PRIMARY KEY,
+    name        TEXT     NOT NULL UNIQUE,
+    description TEXT     NOT NULL DEFAULT '',
+    created_at  DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS group_roles
+(
+    group_id INTEGER NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    role_id  INTEGER NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, role_id)
+);
+CREATE TABLE IF NOT EXISTS group_members
+(
+    group_id   INTEGER  NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (group_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_group_members_user_id ON group_members (user_id);
\ No newline at end of file
@@ -0,0 +1,15 @@
+DELETE FROM user_roles WHERE role_id = (SELECT id FROM roles WHERE name = 'admin');
+DELETE FROM roles WHERE name = 'admin';
+CREATE TABLE user_roles_global
+(
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role_id    INTEGER  NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, role_id)
+);
+INSERT OR IGNORE INTO user_roles_global(user_id, role_id, created_at)
+SELECT user_id, role_id, created_at FROM user_roles WHERE app_id = 0;
+DROP INDEX IF EXISTS idx_user_roles_role_id;
+DROP TABLE user_roles;
+ALTER TABLE user_roles_global RENAME TO user_roles;
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles (role_id);
\ No newline at end of file
@@ -0,0 +1,16 @@
+CREATE TABLE user_roles_scoped
+(
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role_id    INTEGER  NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    app_id     INTEGER  NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, role_id, app_id)
+);
+INSERT INTO user_roles_scoped(user_id, role_id, app_id, created_at)
+SELECT user_id, role_id, 0, created_at FROM user_roles;
+DROP INDEX IF EXISTS idx_user_roles_role_id;
+DROP TABLE user_roles;
+ALTER TABLE user_roles_scoped RENAME TO user_roles;
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles (role_id);
+INSERT OR IGNORE INTO roles(name, description, created_at)
+VALUES ('admin', 'Administers the app it is assigned for', CURRENT_TIMESTAMP);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE audit_events
+    ADD COLUMN detail TEXT;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS policy_rules;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS policy_rules
+(
+    id         INTEGER PRIMARY KEY,
+    ptype      TEXT     NOT NULL,
+    v0         TEXT     NOT NULL DEFAULT '',
+    v1         TEXT     NOT NULL DEFAULT '',
+    v2         TEXT     NOT NULL DEFAULT '',
+    v3         TEXT     NOT NULL DEFAULT '',
+    v4         TEXT     NOT NULL DEFAULT '',
+    v5         TEXT     NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_role_inherits_inherited_id;
+DROP TABLE IF EXISTS role_inherits;
\ No newline at end of file
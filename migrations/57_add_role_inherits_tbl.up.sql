@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS role_inherits
+(
+    role_id      INTEGER NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    inherited_id INTEGER NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, inherited_id)
+);
+CREATE INDEX IF NOT EXISTS idx_role_inherits_inherited_id ON role_inherits (inherited_id);
\ No newline at end of file
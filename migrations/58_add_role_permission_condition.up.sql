@@ -0,0 +1 @@
+ALTER TABLE role_permissions ADD COLUMN condition TEXT NOT NULL DEFAULT '';
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE apps DROP COLUMN disabled_at;
+ALTER TABLE apps DROP COLUMN created_at;
\ No newline at end of file
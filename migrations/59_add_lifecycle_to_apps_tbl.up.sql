@@ -0,0 +1,2 @@
+ALTER TABLE apps ADD COLUMN created_at DATETIME;
+ALTER TABLE apps ADD COLUMN disabled_at DATETIME;
\ No newline at end of file
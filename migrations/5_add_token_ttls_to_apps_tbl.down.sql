@@ -0,0 +1,2 @@
+ALTER TABLE apps DROP COLUMN refresh_token_ttl;
+ALTER TABLE apps DROP COLUMN access_token_ttl;
\ No newline at end of file
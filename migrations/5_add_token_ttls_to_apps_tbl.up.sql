@@ -0,0 +1,4 @@
+ALTER TABLE apps
+    ADD COLUMN access_token_ttl INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE apps
+    ADD COLUMN refresh_token_ttl INTEGER NOT NULL DEFAULT 0;
\ No newline at end of file
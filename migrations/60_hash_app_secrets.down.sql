@@ -0,0 +1,13 @@
+-- hashed secrets cannot be turned back into the plaintext ones the apps had
+-- before, so the migration refuses to run once any secret is hashed
+CREATE TEMP TABLE hashed_app_secrets
+(
+    hashed BOOLEAN NOT NULL CONSTRAINT app_secrets_are_hashed_and_cannot_be_restored CHECK (hashed = 0)
+);
+INSERT INTO hashed_app_secrets SELECT secret_hashed FROM apps WHERE secret_hashed = 1 LIMIT 1;
+DROP TABLE hashed_app_secrets;
+
+ALTER TABLE apps DROP COLUMN previous_secret_expires_at;
+ALTER TABLE apps DROP COLUMN previous_secret_hash;
+ALTER TABLE apps DROP COLUMN signing_key;
+ALTER TABLE apps DROP COLUMN secret_hashed;
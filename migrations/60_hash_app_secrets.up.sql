@@ -0,0 +1,4 @@
+ALTER TABLE apps ADD COLUMN secret_hashed BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE apps ADD COLUMN signing_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN previous_secret_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE apps ADD COLUMN previous_secret_expires_at DATETIME;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE apps DROP COLUMN grant_types;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE apps ADD COLUMN grant_types TEXT NOT NULL DEFAULT '';
\ No newline at end of file
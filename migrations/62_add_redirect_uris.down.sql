@@ -0,0 +1,3 @@
+ALTER TABLE federation_states DROP COLUMN client_state;
+ALTER TABLE federation_states DROP COLUMN redirect_uri;
+ALTER TABLE apps DROP COLUMN redirect_uris;
\ No newline at end of file
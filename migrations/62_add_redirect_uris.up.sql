@@ -0,0 +1,3 @@
+ALTER TABLE apps ADD COLUMN redirect_uris TEXT NOT NULL DEFAULT '';
+ALTER TABLE federation_states ADD COLUMN redirect_uri TEXT NOT NULL DEFAULT '';
+ALTER TABLE federation_states ADD COLUMN client_state TEXT NOT NULL DEFAULT '';
\ No newline at end of file
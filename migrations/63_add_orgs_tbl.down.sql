@@ -0,0 +1,12 @@
+DROP TRIGGER IF EXISTS users_updated_at;
+CREATE TRIGGER IF NOT EXISTS users_updated_at
+    AFTER UPDATE OF email, username, display_name, pass_hash, email_verified, phone, phone_verified, is_admin,
+    is_guest, deletion_scheduled_at, app_metadata, user_metadata, first_name, last_name, locale, avatar_url
+    ON users
+BEGIN
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = NEW.id;
+END;
+DROP INDEX IF EXISTS idx_users_org_id;
+ALTER TABLE apps DROP COLUMN org_id;
+ALTER TABLE users DROP COLUMN org_id;
+DROP TABLE IF EXISTS orgs;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS orgs
+(
+    id         INTEGER PRIMARY KEY,
+    name       TEXT     NOT NULL UNIQUE,
+    created_at DATETIME NOT NULL
+);
+INSERT INTO orgs(id, name, created_at) VALUES (1, 'default', CURRENT_TIMESTAMP);
+ALTER TABLE users ADD COLUMN org_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE apps ADD COLUMN org_id INTEGER NOT NULL DEFAULT 1;
+CREATE INDEX IF NOT EXISTS idx_users_org_id ON users (org_id);
+DROP TRIGGER IF EXISTS users_updated_at;
+CREATE TRIGGER IF NOT EXISTS users_updated_at
+    AFTER UPDATE OF email, username, display_name, pass_hash, email_verified, phone, phone_verified, is_admin,
+    is_guest, deletion_scheduled_at, app_metadata, user_metadata, first_name, last_name, locale, avatar_url, org_id
+    ON users
+BEGIN
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = NEW.id;
+END;
\ No newline at end of file
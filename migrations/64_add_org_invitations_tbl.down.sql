@@ -0,0 +1 @@
+DROP TABLE IF EXISTS org_invitations;
\ No newline at end of file
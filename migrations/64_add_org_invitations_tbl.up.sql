@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS org_invitations
+(
+    id          INTEGER PRIMARY KEY,
+    token_hash  TEXT     NOT NULL UNIQUE,
+    org_id      INTEGER  NOT NULL REFERENCES orgs (id) ON DELETE CASCADE,
+    email       TEXT     NOT NULL,
+    is_admin    INTEGER  NOT NULL DEFAULT 0,
+    invited_by  INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at  DATETIME NOT NULL,
+    created_at  DATETIME NOT NULL,
+    accepted_at DATETIME,
+    declined_at DATETIME,
+    user_id     INTEGER REFERENCES users (id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_org_invitations_org_id ON org_invitations (org_id);
\ No newline at end of file
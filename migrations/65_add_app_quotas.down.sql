@@ -0,0 +1,2 @@
+ALTER TABLE apps DROP COLUMN registration_quota;
+ALTER TABLE apps DROP COLUMN login_quota;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE apps ADD COLUMN login_quota INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE apps ADD COLUMN registration_quota INTEGER NOT NULL DEFAULT 0;
\ No newline at end of file
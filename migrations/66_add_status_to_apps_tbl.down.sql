@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_token_issuances_app_id;
+ALTER TABLE apps DROP COLUMN status;
\ No newline at end of file
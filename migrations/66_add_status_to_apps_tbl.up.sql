@@ -0,0 +1,3 @@
+ALTER TABLE apps ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+UPDATE apps SET status = 'disabled' WHERE disabled_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_token_issuances_app_id ON token_issuances (app_id);
\ No newline at end of file
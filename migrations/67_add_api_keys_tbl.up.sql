@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id           INTEGER PRIMARY KEY,
+    app_id       INTEGER  NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    name         TEXT     NOT NULL,
+    prefix       TEXT     NOT NULL,
+    key_hash     TEXT     NOT NULL UNIQUE,
+    created_by   INTEGER  NOT NULL,
+    created_at   DATETIME NOT NULL,
+    last_used_at DATETIME,
+    revoked_at   DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_api_keys_app_id ON api_keys (app_id);
\ No newline at end of file
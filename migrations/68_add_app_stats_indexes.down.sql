@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_token_issuances_app_id_issued_at;
+DROP INDEX IF EXISTS idx_audit_events_app_id;
\ No newline at end of file
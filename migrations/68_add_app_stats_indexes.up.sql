@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_audit_events_app_id ON audit_events (app_id, type, created_at);
+CREATE INDEX IF NOT EXISTS idx_token_issuances_app_id_issued_at ON token_issuances (app_id, issued_at);
\ No newline at end of file
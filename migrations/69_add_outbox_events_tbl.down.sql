@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS outbox_audit_event;
+DROP TRIGGER IF EXISTS outbox_session_started;
+DROP TRIGGER IF EXISTS outbox_user_registered;
+DROP TABLE IF EXISTS outbox_events;
\ No newline at end of file
@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS outbox_events
+(
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    type         TEXT     NOT NULL,
+    payload      TEXT     NOT NULL,
+    created_at   DATETIME NOT NULL,
+    delivered_at DATETIME,
+    attempts     INTEGER  NOT NULL DEFAULT 0,
+    last_error   TEXT     NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events (id) WHERE delivered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_delivered_at ON outbox_events (delivered_at);
+
+-- the events are recorded by the statements that make the changes, so
+-- that they are committed or rolled back together
+CREATE TRIGGER IF NOT EXISTS outbox_user_registered
+    AFTER INSERT
+    ON users
+BEGIN
+    INSERT INTO outbox_events(type, payload, created_at)
+    VALUES ('user_registered',
+            json_object('user_id', NEW.id, 'email', NEW.email,
+                        'is_guest', json(CASE WHEN NEW.is_guest THEN 'true' ELSE 'false' END)),
+            strftime('%Y-%m-%d %H:%M:%f', 'now'));
+END;
+CREATE TRIGGER IF NOT EXISTS outbox_session_started
+    AFTER INSERT
+    ON user_sessions
+BEGIN
+    INSERT INTO outbox_events(type, payload, created_at)
+    VALUES ('session_started',
+            json_object('session_id', NEW.id, 'user_id', NEW.user_id, 'app_id', NEW.app_id, 'ip', NEW.ip,
+                        'device', NEW.device),
+            strftime('%Y-%m-%d %H:%M:%f', 'now'));
+END;
+CREATE TRIGGER IF NOT EXISTS outbox_audit_event
+    AFTER INSERT
+    ON audit_events
+BEGIN
+    INSERT INTO outbox_events(type, payload, created_at)
+    VALUES (NEW.type,
+            json_object('audit_event_id', NEW.id, 'user_id', NEW.user_id, 'app_id', NEW.app_id,
+                        'actor_id', NEW.actor_id, 'detail', NEW.detail),
+            strftime('%Y-%m-%d %H:%M:%f', 'now'));
+END;
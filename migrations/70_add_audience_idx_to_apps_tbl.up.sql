@@ -0,0 +1,3 @@
+-- tokens of apps without an audience are issued for their name, so the
+-- audience apps are looked up by is the one or the other
+CREATE UNIQUE INDEX IF NOT EXISTS idx_apps_audience ON apps ((CASE WHEN audience = '' THEN name ELSE audience END));
@@ -0,0 +1 @@
+ALTER TABLE apps DROP COLUMN audience;
\ No newline at end of file
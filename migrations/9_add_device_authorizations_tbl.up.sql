@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS device_authorizations
+(
+    id               INTEGER PRIMARY KEY,
+    device_code_hash TEXT     NOT NULL UNIQUE,
+    user_code        TEXT     NOT NULL UNIQUE,
+    app_id           INTEGER  NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    user_id          INTEGER  REFERENCES users (id) ON DELETE CASCADE,
+    status           TEXT     NOT NULL DEFAULT 'pending',
+    expires_at       DATETIME NOT NULL,
+    last_polled_at   DATETIME,
+    created_at       DATETIME NOT NULL
+);
\ No newline at end of file
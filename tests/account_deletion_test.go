@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type auditEventsResponse struct {
+	Events []struct {
+		ID      int64  `json:"id"`
+		Type    string `json:"type"`
+		UserID  int64  `json:"user_id"`
+		AppID   int    `json:"app_id"`
+		ActorID int64  `json:"actor_id"`
+		Detail  string `json:"detail"`
+	} `json:"events"`
+}
+
+func TestAccountDeletion_ScheduleAndCancel(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+
+	code, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, code)
+
+	code = bearerPostForm(t, st, login.AccessToken, "/account/delete", url.Values{"password": {randomFakePassword()}})
+	require.Equal(t, http.StatusBadRequest, code)
+
+	requestedAt := time.Now()
+	resp := bearerPostFormBody(t, st, login.AccessToken, "/account/delete", url.Values{"password": {pass}})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	var scheduled struct {
+		DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&scheduled))
+	assert.WithinDuration(t, requestedAt.Add(st.Cfg.AccountDeletion.GracePeriod), scheduled.DeletionScheduledAt, 5*time.Second)
+
+	// the tokens are revoked right away
+	code, _ = adminRequest(t, st, login.AccessToken, http.MethodGet, "/sessions", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, pending := requestToken(t, st, loginForm)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "account_pending_deletion", pending.Error)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	code, body := adminRequest(t, st, adminToken(t, st), http.MethodGet,
+		"/admin/audit-events?type=account_deletion_scheduled&user_id="+strconv.FormatInt(respReg.GetUserId(), 10), nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var events auditEventsResponse
+	require.NoError(t, json.Unmarshal(body, &events))
+	require.Len(t, events.Events, 1)
+	assert.Equal(t, respReg.GetUserId(), events.Events[0].UserID)
+
+	cancelResp, err := http.PostForm(st.HTTPURL+"/account/delete/cancel", url.Values{
+		"username": {email},
+		"password": {pass},
+	})
+	require.NoError(t, err)
+	cancelResp.Body.Close()
+	require.Equal(t, http.StatusNoContent, cancelResp.StatusCode)
+
+	code, _ = requestToken(t, st, loginForm)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestAuditEvents_Paging(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	code, body := adminRequest(t, st, admin, http.MethodGet, "/admin/audit-events?limit=2", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var first auditEventsResponse
+	require.NoError(t, json.Unmarshal(body, &first))
+	require.LessOrEqual(t, len(first.Events), 2)
+	if len(first.Events) == 0 {
+		return
+	}
+
+	last := first.Events[len(first.Events)-1].ID
+	code, body = adminRequest(t, st, admin, http.MethodGet, "/admin/audit-events?after="+strconv.FormatInt(last, 10), nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var next auditEventsResponse
+	require.NoError(t, json.Unmarshal(body, &next))
+	for _, event := range next.Events {
+		assert.Greater(t, event.ID, last)
+	}
+
+	code, _ = adminRequest(t, st, admin, http.MethodGet, "/admin/audit-events?after=-1", nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
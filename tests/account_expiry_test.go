@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type userExpiryResponse struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func TestAccountExpiry(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+	code, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, code)
+
+	admin := adminToken(t, st)
+	userPath := "/admin/users/" + strconv.FormatInt(respReg.GetUserId(), 10)
+
+	expiresAt := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	code, body := adminRequest(t, st, admin, http.MethodPut, userPath+"/expiry", map[string]any{
+		"expires_at": expiresAt,
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	var user userExpiryResponse
+	require.NoError(t, json.Unmarshal(body, &user))
+	require.NotNil(t, user.ExpiresAt)
+	assert.True(t, expiresAt.Equal(*user.ExpiresAt))
+
+	code, expired := requestToken(t, st, loginForm)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "account_expired", expired.Error)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// sessions of expired accounts can not be refreshed
+	code, refreshed := requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "account_expired", refreshed.Error)
+
+	// extending an expired account counts from now
+	code, body = adminRequest(t, st, admin, http.MethodPut, userPath+"/expiry", map[string]any{
+		"extend_by": "24h",
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	user = userExpiryResponse{}
+	require.NoError(t, json.Unmarshal(body, &user))
+	require.NotNil(t, user.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), *user.ExpiresAt, time.Minute)
+
+	code, _ = requestToken(t, st, loginForm)
+	assert.Equal(t, http.StatusOK, code)
+
+	// extending an account that has not expired counts from its expiry
+	extendedFrom := *user.ExpiresAt
+	code, body = adminRequest(t, st, admin, http.MethodPut, userPath+"/expiry", map[string]any{
+		"extend_by": "1h",
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	user = userExpiryResponse{}
+	require.NoError(t, json.Unmarshal(body, &user))
+	require.NotNil(t, user.ExpiresAt)
+	assert.WithinDuration(t, extendedFrom.Add(time.Hour), *user.ExpiresAt, time.Second)
+
+	code, body = adminRequest(t, st, admin, http.MethodPut, userPath+"/expiry", map[string]any{
+		"expires_at": nil,
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	user = userExpiryResponse{}
+	require.NoError(t, json.Unmarshal(body, &user))
+	assert.Nil(t, user.ExpiresAt)
+
+	code, body = adminRequest(t, st, admin, http.MethodGet,
+		"/admin/audit-events?user_id="+strconv.FormatInt(respReg.GetUserId(), 10), nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var events auditEventsResponse
+	require.NoError(t, json.Unmarshal(body, &events))
+
+	var types []string
+	for _, event := range events.Events {
+		types = append(types, event.Type)
+	}
+	assert.Contains(t, types, "user_expiry_set")
+}
+
+func TestAccountExpiry_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	userPath := "/admin/users/" + strconv.FormatInt(respReg.GetUserId(), 10) + "/expiry"
+
+	tests := []struct {
+		name string
+		path string
+		body map[string]any
+		code int
+	}{
+		{
+			name: "Invalid duration",
+			path: userPath,
+			body: map[string]any{"extend_by": "a month"},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "Negative duration",
+			path: userPath,
+			body: map[string]any{"extend_by": "-1h"},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "Both expiry and extension",
+			path: userPath,
+			body: map[string]any{"expires_at": time.Now().Add(time.Hour), "extend_by": "1h"},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "Unknown user",
+			path: "/admin/users/999999999/expiry",
+			body: map[string]any{"expires_at": time.Now().Add(time.Hour)},
+			code: http.StatusNotFound,
+		},
+		{
+			name: "Extend unknown user",
+			path: "/admin/users/999999999/expiry",
+			body: map[string]any{"extend_by": "1h"},
+			code: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := adminRequest(t, st, admin, http.MethodPut, tt.path, tt.body)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"sso/tests/suite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type apiKeyResponse struct {
+	ID         int64      `json:"id"`
+	AppID      int        `json:"app_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type apiKeyValidationResponse struct {
+	Valid bool   `json:"valid"`
+	KeyID int64  `json:"key_id"`
+	AppID int    `json:"app_id"`
+	Name  string `json:"name"`
+}
+
+func TestAPIKeys_HappyPath(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	code, body := adminRequest(t, st, admin, http.MethodPost, "/admin/apps", map[string]any{"name": randomAppName()})
+	require.Equal(t, http.StatusCreated, code)
+
+	var app appResponse
+	require.NoError(t, json.Unmarshal(body, &app))
+	keysPath := "/admin/apps/" + strconv.Itoa(app.ID) + "/api-keys"
+
+	code, body = adminRequest(t, st, admin, http.MethodPost, keysPath, map[string]any{"name": "Billing export"})
+	require.Equal(t, http.StatusCreated, code)
+
+	var issued apiKeyResponse
+	require.NoError(t, json.Unmarshal(body, &issued))
+	assert.Equal(t, app.ID, issued.AppID)
+	assert.Equal(t, "Billing export", issued.Name)
+	assert.True(t, strings.HasPrefix(issued.Key, "sso_"+issued.Prefix+"_"), issued.Key)
+
+	status, validation := validateAPIKey(t, st, strconv.Itoa(app.ID), app.Secret, issued.Key)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, apiKeyValidationResponse{
+		Valid: true,
+		KeyID: issued.ID,
+		AppID: app.ID,
+		Name:  "Billing export",
+	}, validation)
+
+	// keys are only valid for the app they were issued for
+	status, validation = validateAPIKey(t, st, strconv.Itoa(appID), appSecret, issued.Key)
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, validation.Valid)
+
+	_, validation = validateAPIKey(t, st, strconv.Itoa(app.ID), app.Secret, issued.Key+"x")
+	assert.False(t, validation.Valid)
+
+	code, body = adminRequest(t, st, admin, http.MethodGet, keysPath, nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var keys struct {
+		Keys []apiKeyResponse `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(body, &keys))
+	require.Len(t, keys.Keys, 1)
+	assert.Equal(t, issued.Prefix, keys.Keys[0].Prefix)
+	assert.Empty(t, keys.Keys[0].Key)
+	assert.NotNil(t, keys.Keys[0].LastUsedAt)
+	assert.Nil(t, keys.Keys[0].RevokedAt)
+
+	keyPath := keysPath + "/" + strconv.FormatInt(issued.ID, 10)
+	code, _ = adminRequest(t, st, admin, http.MethodDelete, keyPath, nil)
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = adminRequest(t, st, admin, http.MethodDelete, keyPath, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	_, validation = validateAPIKey(t, st, strconv.Itoa(app.ID), app.Secret, issued.Key)
+	assert.False(t, validation.Valid)
+
+	for _, typ := range []string{"api_key_created", "api_key_revoked"} {
+		code, body = adminRequest(t, st, admin, http.MethodGet, "/admin/audit-events?type="+typ+"&limit=100", nil)
+		require.Equal(t, http.StatusOK, code)
+
+		var events auditEventsResponse
+		require.NoError(t, json.Unmarshal(body, &events))
+
+		found := false
+		for _, event := range events.Events {
+			if event.AppID == app.ID && event.Detail == issued.Prefix {
+				found = true
+			}
+		}
+		assert.True(t, found, typ)
+	}
+}
+
+func TestAPIKeys_Invalid(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+	keysPath := "/admin/apps/" + strconv.Itoa(appID) + "/api-keys"
+
+	code, _ := adminRequest(t, st, admin, http.MethodPost, keysPath, map[string]any{"name": " "})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = adminRequest(t, st, admin, http.MethodPost, keysPath, map[string]any{"name": strings.Repeat("k", 65)})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = adminRequest(t, st, admin, http.MethodPost, "/admin/apps/999999/api-keys", map[string]any{"name": "ci"})
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = adminRequest(t, st, admin, http.MethodDelete, keysPath+"/999999", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	status, _ := validateAPIKey(t, st, strconv.Itoa(appID), "wrong-secret", "sso_00000000_key")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = validateAPIKey(t, st, strconv.Itoa(appID), appSecret, "")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func validateAPIKey(t *testing.T, st *suite.Suite, clientID, clientSecret, key string) (int, apiKeyValidationResponse) {
+	t.Helper()
+
+	form := url.Values{"key": {key}}
+
+	req, err := http.NewRequest(http.MethodPost, st.HTTPURL+"/api-keys/validate", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientID, clientSecret)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body apiKeyValidationResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp.StatusCode, body
+}
@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type appStatsResponse struct {
+	AppID        int       `json:"app_id"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Logins       int       `json:"logins"`
+	FailedLogins int       `json:"failed_logins"`
+	FailureRate  float64   `json:"failure_rate"`
+	UniqueUsers  int       `json:"unique_users"`
+	TokensIssued int       `json:"tokens_issued"`
+}
+
+func TestAppStats_HappyPath(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	code, body := adminRequest(t, st, admin, http.MethodPost, "/admin/apps", map[string]any{"name": randomAppName()})
+	require.Equal(t, http.StatusCreated, code)
+
+	var app appResponse
+	require.NoError(t, json.Unmarshal(body, &app))
+	statsPath := "/admin/apps/" + strconv.Itoa(app.ID) + "/stats"
+
+	login := func(email, pass string) int {
+		t.Helper()
+
+		code, _ := requestToken(t, st, url.Values{
+			"grant_type": {"password"},
+			"username":   {email},
+			"password":   {pass},
+			"client_id":  {strconv.Itoa(app.ID)},
+		})
+
+		return code
+	}
+
+	firstEmail, firstPass := registerUser(t, st)
+	secondEmail, secondPass := registerUser(t, st)
+
+	require.Equal(t, http.StatusOK, login(firstEmail, firstPass))
+	require.Equal(t, http.StatusOK, login(firstEmail, firstPass))
+	require.Equal(t, http.StatusOK, login(secondEmail, secondPass))
+	require.NotEqual(t, http.StatusOK, login(secondEmail, "wrong-"+secondPass))
+
+	code, body = adminRequest(t, st, admin, http.MethodGet, statsPath, nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var stats appStatsResponse
+	require.NoError(t, json.Unmarshal(body, &stats))
+	assert.Equal(t, app.ID, stats.AppID)
+	assert.Equal(t, 3, stats.Logins)
+	assert.Equal(t, 1, stats.FailedLogins)
+	assert.InDelta(t, 0.25, stats.FailureRate, 1e-9)
+	assert.Equal(t, 2, stats.UniqueUsers)
+	assert.Equal(t, 3, stats.TokensIssued)
+	assert.WithinDuration(t, time.Now(), stats.To, time.Minute)
+	assert.WithinDuration(t, stats.To.Add(-30*24*time.Hour), stats.From, time.Second)
+
+	// a range before the app existed is empty
+	from := time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+	to := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
+	code, body = adminRequest(t, st, admin, http.MethodGet,
+		statsPath+"?from="+url.QueryEscape(from)+"&to="+url.QueryEscape(to), nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var empty appStatsResponse
+	require.NoError(t, json.Unmarshal(body, &empty))
+	assert.Zero(t, empty.Logins)
+	assert.Zero(t, empty.FailureRate)
+	assert.Zero(t, empty.TokensIssued)
+}
+
+func TestAppStats_Invalid(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+	statsPath := "/admin/apps/" + strconv.Itoa(appID) + "/stats"
+
+	now := time.Now().UTC()
+	for _, query := range []string{
+		"?from=yesterday",
+		"?from=" + url.QueryEscape(now.Format(time.RFC3339)) + "&to=" + url.QueryEscape(now.Add(-time.Hour).Format(time.RFC3339)),
+		"?from=" + url.QueryEscape(now.AddDate(-2, 0, 0).Format(time.RFC3339)),
+	} {
+		code, _ := adminRequest(t, st, admin, http.MethodGet, statsPath+query, nil)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+
+	code, _ := adminRequest(t, st, admin, http.MethodGet, "/admin/apps/999999/stats", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+// registerUser registers a user and returns its email and password.
+func registerUser(t *testing.T, st *suite.Suite) (string, string) {
+	t.Helper()
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(context.Background(), &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	return email, pass
+}
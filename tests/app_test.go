@@ -0,0 +1,529 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type appResponse struct {
+	ID              int            `json:"id"`
+	OrgID           int64          `json:"org_id"`
+	Name            string         `json:"name"`
+	Secret          string         `json:"secret"`
+	Audience        string         `json:"audience"`
+	TokenFormat     string         `json:"token_format"`
+	Scopes          []string       `json:"scopes"`
+	GrantTypes      []string       `json:"grant_types"`
+	Claims          map[string]any `json:"claims"`
+	AccessTokenTTL  int64          `json:"access_token_ttl"`
+	RefreshTokenTTL int64          `json:"refresh_token_ttl"`
+	ThirdParty      bool           `json:"third_party"`
+	LoginQuota      int            `json:"login_quota"`
+	DisabledAt      *string        `json:"disabled_at"`
+
+	RegistrationQuota int    `json:"registration_quota"`
+	Status            string `json:"status"`
+
+	PreviousSecretExpiresAt *string `json:"previous_secret_expires_at"`
+}
+
+func TestApps_CRUD(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+	name := randomAppName()
+
+	status, body := adminRequest(t, st, admin, http.MethodPost, "/admin/apps", map[string]any{
+		"name":             name,
+		"scopes":           []string{"profile", "email", "profile"},
+		"claims":           map[string]any{"tier": "gold"},
+		"access_token_ttl": 300,
+	})
+	require.Equal(t, http.StatusCreated, status)
+
+	var created appResponse
+	require.NoError(t, json.Unmarshal(body, &created))
+	require.NotZero(t, created.ID)
+	require.NotEmpty(t, created.Secret)
+	assert.Equal(t, name, created.Name)
+	assert.Equal(t, []string{"email", "profile"}, created.Scopes)
+	assert.Equal(t, int64(300), created.AccessTokenTTL)
+
+	clientID := strconv.Itoa(created.ID)
+
+	status, token := requestToken(t, st, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {created.Secret},
+	})
+	require.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, token.AccessToken)
+
+	status, body = adminRequest(t, st, admin, http.MethodPut, "/admin/apps/"+clientID, map[string]any{
+		"name":         name + "-renamed",
+		"token_format": "opaque",
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	var updated appResponse
+	require.NoError(t, json.Unmarshal(body, &updated))
+	assert.Equal(t, name+"-renamed", updated.Name)
+	assert.Equal(t, "opaque", updated.TokenFormat)
+	assert.Empty(t, updated.Secret)
+	assert.Empty(t, updated.Scopes)
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, "/admin/apps", nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var listed struct {
+		Apps []appResponse `json:"apps"`
+	}
+	require.NoError(t, json.Unmarshal(body, &listed))
+	assert.Contains(t, listed.Apps, updated)
+	for _, app := range listed.Apps {
+		assert.Empty(t, app.Secret)
+	}
+
+	status, _ = adminRequest(t, st, admin, http.MethodPost, "/admin/apps/"+clientID+"/disable", nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	// disabling twice does nothing
+	status, _ = adminRequest(t, st, admin, http.MethodPost, "/admin/apps/"+clientID+"/disable", nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, "/admin/apps/"+clientID, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var disabled appResponse
+	require.NoError(t, json.Unmarshal(body, &disabled))
+	assert.NotNil(t, disabled.DisabledAt)
+
+	status, token = requestToken(t, st, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {created.Secret},
+	})
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "invalid_client", token.Error)
+
+	status, _ = adminRequest(t, st, admin, http.MethodGet, "/admin/apps/999999", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestApps_Status(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	code, body := adminRequest(t, st, admin, http.MethodPost, "/admin/apps", map[string]any{"name": randomAppName()})
+	require.Equal(t, http.StatusCreated, code)
+
+	var created appResponse
+	require.NoError(t, json.Unmarshal(body, &created))
+	assert.Equal(t, "active", created.Status)
+
+	appPath := "/admin/apps/" + strconv.Itoa(created.ID)
+	setStatus := func(appStatus string, revoke bool) appResponse {
+		t.Helper()
+
+		code, body := adminRequest(t, st, admin, http.MethodPut, appPath+"/status", map[string]any{
+			"status":        appStatus,
+			"revoke_tokens": revoke,
+		})
+		require.Equal(t, http.StatusOK, code)
+
+		var app appResponse
+		require.NoError(t, json.Unmarshal(body, &app))
+		require.Equal(t, appStatus, app.Status)
+
+		return app
+	}
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(created.ID)},
+	}
+	loginRequest := &ssov1.LoginRequest{Email: email, Password: pass, AppId: int32(created.ID)}
+
+	code, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, code)
+
+	// deprecated apps keep working
+	setStatus("deprecated", false)
+	_, err = st.AuthClient.Login(ctx, loginRequest)
+	require.NoError(t, err)
+
+	disabled := setStatus("disabled", false)
+	assert.NotNil(t, disabled.DisabledAt)
+
+	_, err = st.AuthClient.Login(ctx, loginRequest)
+	require.Error(t, err)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	code, resp := requestToken(t, st, loginForm)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "app_disabled", resp.Error)
+
+	code, resp = requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "app_disabled", resp.Error)
+
+	// the disabled app cannot introspect, so the token is checked by logging out
+	req, err := http.NewRequest(http.MethodPost, st.HTTPURL+"/logout", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+login.AccessToken)
+	logout, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	logout.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, logout.StatusCode)
+
+	// tokens of an app enabled again are valid again unless revoked
+	enabled := setStatus("active", false)
+	assert.Nil(t, enabled.DisabledAt)
+
+	code, info := introspect(t, st, login.AccessToken, strconv.Itoa(created.ID), created.Secret)
+	require.Equal(t, http.StatusOK, code)
+	assert.True(t, info.Active)
+
+	setStatus("disabled", true)
+	setStatus("active", false)
+
+	code, info = introspect(t, st, login.AccessToken, strconv.Itoa(created.ID), created.Secret)
+	require.Equal(t, http.StatusOK, code)
+	assert.False(t, info.Active)
+
+	code, resp = requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "invalid_grant", resp.Error)
+
+	code, _ = requestToken(t, st, loginForm)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _ = adminRequest(t, st, admin, http.MethodPut, appPath+"/status", map[string]any{"status": "retired"})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body = adminRequest(t, st, admin, http.MethodGet,
+		"/admin/audit-events?type=app_status_changed&limit=100", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var events auditEventsResponse
+	require.NoError(t, json.Unmarshal(body, &events))
+	var changes []string
+	for _, event := range events.Events {
+		if event.AppID == created.ID {
+			changes = append(changes, event.Detail)
+		}
+	}
+	assert.Equal(t, []string{"deprecated", "disabled", "active", "disabled", "active"}, changes)
+}
+
+func TestApps_Invalid(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+	name := randomAppName()
+
+	status, _ := adminRequest(t, st, admin, http.MethodPost, "/admin/apps", map[string]any{"name": name})
+	require.Equal(t, http.StatusCreated, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPost, "/admin/apps", map[string]any{"name": name})
+	assert.Equal(t, http.StatusConflict, status)
+
+	// tokens of an app without an audience are issued for its name, which
+	// no other app may then take as audience, nor the other way round
+	status, _ = adminRequest(t, st, admin, http.MethodPost, "/admin/apps",
+		map[string]any{"name": randomAppName(), "audience": name})
+	assert.Equal(t, http.StatusConflict, status)
+
+	audience := randomAppName()
+	status, _ = adminRequest(t, st, admin, http.MethodPost, "/admin/apps",
+		map[string]any{"name": randomAppName(), "audience": audience})
+	require.Equal(t, http.StatusCreated, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPost, "/admin/apps", map[string]any{"name": audience})
+	assert.Equal(t, http.StatusConflict, status)
+
+	status, body := adminRequest(t, st, admin, http.MethodPost, "/admin/apps", map[string]any{"name": randomAppName()})
+	require.Equal(t, http.StatusCreated, status)
+
+	var other appResponse
+	require.NoError(t, json.Unmarshal(body, &other))
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/apps/"+strconv.Itoa(other.ID),
+		map[string]any{"name": other.Name, "audience": audience})
+	assert.Equal(t, http.StatusConflict, status)
+
+	for _, req := range []map[string]any{
+		{"name": ""},
+		{"name": randomAppName(), "token_format": "xml"},
+		{"name": randomAppName(), "scopes": []string{"Not A Scope"}},
+		{"name": randomAppName(), "access_token_ttl": -1},
+		{"name": randomAppName(), "grant_types": []string{"implicit"}},
+		{"name": randomAppName(), "redirect_uris": []string{"http://app.example.com/callback"}},
+		{"name": randomAppName(), "redirect_uris": []string{"https://app.example.com/callback#token"}},
+	} {
+		status, _ = adminRequest(t, st, admin, http.MethodPost, "/admin/apps", req)
+		assert.Equal(t, http.StatusBadRequest, status, req)
+	}
+
+	// apps are managed by admins only
+	status, _ = adminRequest(t, st, "not-a-token", http.MethodGet, "/admin/apps", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestApps_RotateSecret(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	status, body := adminRequest(t, st, admin, http.MethodPost, "/admin/apps", map[string]any{"name": randomAppName()})
+	require.Equal(t, http.StatusCreated, status)
+
+	var created appResponse
+	require.NoError(t, json.Unmarshal(body, &created))
+
+	clientID := strconv.Itoa(created.ID)
+	path := "/admin/apps/" + clientID + "/rotate-secret"
+
+	authenticates := func(secret string) bool {
+		status, _ := requestToken(t, st, url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {clientID},
+			"client_secret": {secret},
+		})
+		return status == http.StatusOK
+	}
+
+	status, issued := requestToken(t, st, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {created.Secret},
+	})
+	require.Equal(t, http.StatusOK, status)
+	_, introspected := introspect(t, st, issued.AccessToken, clientID, created.Secret)
+	require.True(t, introspected.Active)
+
+	status, body = adminRequest(t, st, admin, http.MethodPost, path, map[string]any{"grace_period": 3600})
+	require.Equal(t, http.StatusOK, status)
+
+	var rotated appResponse
+	require.NoError(t, json.Unmarshal(body, &rotated))
+	require.NotEmpty(t, rotated.Secret)
+	assert.NotEqual(t, created.Secret, rotated.Secret)
+	assert.NotNil(t, rotated.PreviousSecretExpiresAt)
+
+	// the signing key is rotated with the secret, so tokens issued before
+	// no longer verify
+	_, introspected = introspect(t, st, issued.AccessToken, clientID, rotated.Secret)
+	assert.False(t, introspected.Active)
+
+	// both secrets work during the grace period
+	assert.True(t, authenticates(rotated.Secret))
+	assert.True(t, authenticates(created.Secret))
+
+	status, body = adminRequest(t, st, admin, http.MethodPost, path, map[string]any{"grace_period": 0})
+	require.Equal(t, http.StatusOK, status)
+
+	var revoked appResponse
+	require.NoError(t, json.Unmarshal(body, &revoked))
+	assert.Nil(t, revoked.PreviousSecretExpiresAt)
+
+	assert.True(t, authenticates(revoked.Secret))
+	assert.False(t, authenticates(rotated.Secret))
+	assert.False(t, authenticates(created.Secret))
+
+	// the secret is not shown again
+	status, body = adminRequest(t, st, admin, http.MethodGet, "/admin/apps/"+clientID, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var found appResponse
+	require.NoError(t, json.Unmarshal(body, &found))
+	assert.Empty(t, found.Secret)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPost, path, map[string]any{"grace_period": -1})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPost, "/admin/apps/999999/rotate-secret", map[string]any{})
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestApps_GrantTypes(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	name := randomAppName()
+
+	code, body := adminRequest(t, st, admin, http.MethodPost, "/admin/apps", map[string]any{
+		"name":        name,
+		"grant_types": []string{"password", "client_credentials", "password"},
+	})
+	require.Equal(t, http.StatusCreated, code)
+
+	var created appResponse
+	require.NoError(t, json.Unmarshal(body, &created))
+	assert.Equal(t, []string{"client_credentials", "password"}, created.GrantTypes)
+
+	clientID := strconv.Itoa(created.ID)
+
+	code, _ = requestToken(t, st, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {created.Secret},
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	code, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {clientID},
+	})
+	require.Equal(t, http.StatusOK, code)
+	require.NotEmpty(t, login.RefreshToken)
+
+	code, resp := requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "unauthorized_client", resp.Error)
+
+	code = postForm(t, st, "/device/authorize", url.Values{"client_id": {clientID}})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/apps/"+clientID, map[string]any{
+		"name":        name,
+		"grant_types": []string{"client_credentials"},
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	code, resp = requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {clientID},
+	})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "unauthorized_client", resp.Error)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: pass, AppId: int32(created.ID)})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestApps_TokenTTL(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+
+	code, body := adminRequest(t, st, admin, http.MethodPost, "/admin/apps", map[string]any{
+		"name":              randomAppName(),
+		"access_token_ttl":  300,
+		"refresh_token_ttl": 2,
+	})
+	require.Equal(t, http.StatusCreated, code)
+
+	var created appResponse
+	require.NoError(t, json.Unmarshal(body, &created))
+	assert.Equal(t, int64(2), created.RefreshTokenTTL)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(created.ID)},
+	}
+
+	start := time.Now()
+	code, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(300), login.ExpiresIn)
+	assert.Equal(t, 300*time.Second, tokenLifetime(t, login.AccessToken))
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: pass, AppId: int32(created.ID)})
+	require.NoError(t, err)
+	assert.Equal(t, 300*time.Second, tokenLifetime(t, respLogin.GetToken()))
+
+	// the refresh token of the app expires two seconds after the login
+	code, refreshed := requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(300), refreshed.ExpiresIn)
+
+	time.Sleep(time.Until(start.Add(2500 * time.Millisecond)))
+	code, expired := requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshed.RefreshToken},
+	})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "invalid_grant", expired.Error)
+
+	// apps without overrides keep the lifetimes of the config
+	loginForm.Set("client_id", strconv.Itoa(appID))
+	code, login = requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(st.Cfg.TokenTTL/time.Second), login.ExpiresIn)
+	assert.Equal(t, st.Cfg.TokenTTL, tokenLifetime(t, login.AccessToken))
+}
+
+// tokenLifetime returns the time from the issue of the JWT to its expiry.
+func tokenLifetime(t *testing.T, token string) time.Duration {
+	t.Helper()
+
+	claims := jwt.MapClaims{}
+	_, _, err := jwt.NewParser().ParseUnverified(token, claims)
+	require.NoError(t, err)
+
+	iat, err := claims.GetIssuedAt()
+	require.NoError(t, err)
+	exp, err := claims.GetExpirationTime()
+	require.NoError(t, err)
+
+	return exp.Sub(iat.Time)
+}
+
+func randomAppName() string {
+	return "app-" + strings.ToLower(gofakeit.LetterN(12))
+}
@@ -16,7 +16,11 @@ import (
 const (
 	emptyAppID = 0
 	appID      = 1
+	appName    = "test"
 	appSecret  = "test-secret"
+	// appSigningKey is what tokens of the test app are signed with, set by
+	// the test migrations as the key is never shown.
+	appSigningKey = "test-signing-key"
 
 	passDefaultLen = 10
 )
@@ -47,7 +51,7 @@ func TestRegisterLogin_Login_HappyPath(t *testing.T) {
 	loginTime := time.Now()
 
 	tokenParsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		return []byte(appSecret), nil
+		return []byte(appSigningKey), nil
 	})
 	require.NoError(t, err)
 
@@ -57,11 +61,48 @@ func TestRegisterLogin_Login_HappyPath(t *testing.T) {
 	assert.Equal(t, respReg.GetUserId(), int64(claims["uid"].(float64)))
 	assert.Equal(t, email, claims["email"].(string))
 	assert.Equal(t, appID, int(claims["app_id"].(float64)))
+	assert.Equal(t, appName, claims["aud"].(string))
+	assert.Equal(t, st.Cfg.Issuer, claims["iss"].(string))
 
 	const deltaSeconds = 1
 
 	// check if exp of token is in correct range, ttl get from st.Cfg.TokenTTL
 	assert.InDelta(t, loginTime.Add(st.Cfg.TokenTTL).Unix(), claims["exp"].(float64), deltaSeconds)
+	assert.InDelta(t, loginTime.Unix(), claims["nbf"].(float64), deltaSeconds)
+	assert.InDelta(t, loginTime.Unix(), claims["iat"].(float64), deltaSeconds)
+}
+
+func TestRegisterLogin_AppClaims(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	tokenParsed, err := jwt.Parse(respLogin.GetToken(), func(token *jwt.Token) (interface{}, error) {
+		return []byte(appSigningKey), nil
+	})
+	require.NoError(t, err)
+
+	claims, ok := tokenParsed.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+
+	// static claims are configured for the test app in tests/migrations
+	assert.Equal(t, "test-tenant", claims["tenant_id"])
+	assert.Equal(t, []interface{}{"reader"}, claims["roles"])
+	assert.Equal(t, email, claims["email"].(string))
 }
 
 func TestRegisterLogin_DuplicatedRegistration(t *testing.T) {
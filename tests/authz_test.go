@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	authgrpc "sso/internal/grpc/auth"
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// methodRole is the role the config lets call IsAdmin, besides admins.
+const methodRole = "auditor"
+
+func TestAuthz_MethodRoles(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	req := &ssov1.IsAdminRequest{UserId: respReg.GetUserId()}
+
+	_, err = st.AuthClient.IsAdmin(ctx, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = st.AuthClient.IsAdmin(withAccessToken(ctx, "not-a-token"), req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+	code, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, code)
+
+	_, err = st.AuthClient.IsAdmin(withAccessToken(ctx, login.AccessToken), req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	admin := adminToken(t, st)
+	resp, err := st.AuthClient.IsAdmin(withAccessToken(ctx, admin), req)
+	require.NoError(t, err)
+	assert.False(t, resp.GetIsAdmin())
+
+	// the role lets the user in within the app the token is issued for
+	code, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/roles/"+methodRole, map[string]any{
+		"description": "Calls the IsAdmin RPC",
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	rolePath := "/admin/users/" + strconv.FormatInt(respReg.GetUserId(), 10) + "/roles/" + methodRole
+	code, _ = adminRequest(t, st, admin, http.MethodPut, rolePath+"?app_id="+strconv.Itoa(appID), nil)
+	require.Equal(t, http.StatusNoContent, code)
+
+	code, login = requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, code)
+
+	resp, err = st.AuthClient.IsAdmin(withAccessToken(ctx, login.AccessToken), req)
+	require.NoError(t, err)
+	assert.False(t, resp.GetIsAdmin())
+
+	// the other methods are open
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+}
+
+func TestAuthz_OtherAppToken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	// the admin logs in to another app than the one the APIs take tokens of
+	code, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {adminEmail},
+		"password":   {adminPassword},
+		"client_id":  {"2"},
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	_, err := st.AuthClient.IsAdmin(withAccessToken(ctx, login.AccessToken), &ssov1.IsAdminRequest{UserId: 1})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	code, _ = adminRequest(t, st, login.AccessToken, http.MethodGet, "/admin/apps", nil)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, _ = adminRequest(t, st, login.AccessToken, http.MethodGet, "/metadata", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+// withAccessToken authorizes calls of protected methods with the token.
+func withAccessToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, authgrpc.AuthorizationMetadataKey, "Bearer "+token)
+}
@@ -0,0 +1,230 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/emailaddr"
+	"sso/internal/lib/logger/slogdiscard"
+	"sso/internal/storage"
+	"sso/internal/storage/cache"
+	"sso/internal/storage/memory"
+	"sso/internal/storage/redis"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cacheTTL = time.Minute
+
+// countingStorage counts the lookups the cache lets through to the memory
+// storage. afterLoad, if set, runs once a user is loaded, before it is
+// returned.
+type countingStorage struct {
+	*memory.Storage
+
+	mu        sync.Mutex
+	loads     int
+	afterLoad func()
+}
+
+func (s *countingStorage) UserByID(ctx context.Context, userID int64) (models.User, error) {
+	user, err := s.Storage.UserByID(ctx, userID)
+
+	s.mu.Lock()
+	s.loads++
+	afterLoad := s.afterLoad
+	s.afterLoad = nil
+	s.mu.Unlock()
+
+	if afterLoad != nil {
+		afterLoad()
+	}
+
+	return user, err
+}
+
+func (s *countingStorage) App(ctx context.Context, appID int) (models.App, error) {
+	s.mu.Lock()
+	s.loads++
+	s.mu.Unlock()
+
+	return s.Storage.App(ctx, appID)
+}
+
+func (s *countingStorage) Loads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.loads
+}
+
+// newCachedStorage returns a memory storage with a user and an app, the
+// cache in front of it and the redis server of the cache.
+func newCachedStorage(t *testing.T) (*cache.Storage, *countingStorage, *miniredis.Miniredis, int64, int) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	redisStorage, err := redis.New(server.Addr(), "", 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = redisStorage.Close() })
+
+	backend := &countingStorage{Storage: memory.New(emailaddr.Normalizer{})}
+
+	ctx := context.Background()
+	userID, err := backend.SaveUser(ctx, gofakeit.Email(), []byte("cached-pass-hash"), models.UserProfile{})
+	require.NoError(t, err)
+	appID, err := backend.SaveApp(ctx, models.App{
+		Name:       "cached",
+		SecretHash: "cached-secret-hash",
+		SigningKey: "cached-signing-key",
+		Status:     models.AppStatusActive,
+		CreatedAt:  time.Now(),
+	}, 0)
+	require.NoError(t, err)
+
+	return cache.New(slogdiscard.NewDiscardLogger(), backend, redisStorage, cacheTTL), backend, server, userID, appID
+}
+
+func TestCache_HitAndMiss(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cached, backend, _, userID, appID := newCachedStorage(t)
+
+	for range 3 {
+		user, err := cached.UserByID(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, "cached-pass-hash", user.PassHash)
+	}
+	assert.Equal(t, 1, backend.Loads())
+
+	for range 3 {
+		app, err := cached.App(ctx, appID)
+		require.NoError(t, err)
+		assert.Equal(t, "cached-signing-key", app.SigningKey)
+		assert.Equal(t, "cached-secret-hash", app.SecretHash)
+	}
+	assert.Equal(t, 2, backend.Loads())
+
+	// users not found are not cached
+	for range 2 {
+		_, err := cached.UserByID(ctx, userID+1000)
+		require.ErrorIs(t, err, storage.ErrUserNotFound)
+	}
+	assert.Equal(t, 4, backend.Loads())
+}
+
+func TestCache_Invalidation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cached, backend, _, userID, _ := newCachedStorage(t)
+
+	_, err := cached.UserByID(ctx, userID)
+	require.NoError(t, err)
+
+	require.NoError(t, cached.IncrementTokenVersion(ctx, userID))
+	require.NoError(t, cached.UpdatePassword(ctx, userID, []byte("new-pass-hash")))
+
+	user, err := cached.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), user.TokenVersion)
+	assert.Equal(t, "new-pass-hash", user.PassHash)
+	assert.Equal(t, 2, backend.Loads())
+}
+
+func TestCache_TTL(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cached, backend, server, userID, _ := newCachedStorage(t)
+
+	_, err := cached.UserByID(ctx, userID)
+	require.NoError(t, err)
+
+	server.FastForward(cacheTTL / 2)
+	_, err = cached.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, backend.Loads())
+
+	server.FastForward(cacheTTL)
+	_, err = cached.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, backend.Loads())
+}
+
+func TestCache_StaleLoadNotCached(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cached, backend, _, userID, _ := newCachedStorage(t)
+
+	// the tokens of the user are revoked while a slow lookup still holds
+	// the user from before
+	backend.afterLoad = func() {
+		require.NoError(t, cached.IncrementTokenVersion(ctx, userID))
+	}
+
+	stale, err := cached.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), stale.TokenVersion)
+
+	user, err := cached.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), user.TokenVersion)
+	assert.Equal(t, 2, backend.Loads())
+}
+
+func TestCache_CredentialsNotCached(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cached, backend, server, userID, appID := newCachedStorage(t)
+
+	_, err := cached.UserByID(ctx, userID)
+	require.NoError(t, err)
+	_, err = cached.App(ctx, appID)
+	require.NoError(t, err)
+
+	for _, key := range server.Keys() {
+		if server.Type(key) != "string" {
+			continue
+		}
+		value, err := server.Get(key)
+		require.NoError(t, err)
+		for _, secret := range []string{"cached-pass-hash", "cached-secret-hash", "cached-signing-key"} {
+			assert.NotContains(t, value, secret, "key %s", key)
+		}
+		if strings.HasPrefix(key, "sso:cache:user:") {
+			assert.Contains(t, value, `"TokenVersion"`)
+		}
+	}
+
+	// another process sharing the cache loads the credentials of the
+	// cached values from the storage
+	redisStorage, err := redis.New(server.Addr(), "", 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = redisStorage.Close() })
+	other := cache.New(slogdiscard.NewDiscardLogger(), backend, redisStorage, cacheTTL)
+
+	loads := backend.Loads()
+	user, err := other.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "cached-pass-hash", user.PassHash)
+	app, err := other.App(ctx, appID)
+	require.NoError(t, err)
+	assert.Equal(t, "cached-signing-key", app.SigningKey)
+	assert.Equal(t, loads+2, backend.Loads())
+
+	// and keeps them for the values it has seen
+	_, err = other.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, loads+2, backend.Loads())
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// untrustedAddr is a loopback address outside of the bypass networks of the
+// config.
+const untrustedAddr = "127.0.0.2"
+
+func TestChallenge_Register(t *testing.T) {
+	ctx, st := suite.New(t)
+	stub := startChallengeStub(t, st)
+	client := untrustedClient(t, st)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := client.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.Register(withChallenge(ctx, "unsolved"), &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	token := stub.solve()
+
+	respReg, err := client.Register(withChallenge(ctx, token), &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+	assert.Equal(t, untrustedAddr, stub.remoteIP(token))
+
+	_, err = client.Login(ctx, &ssov1.LoginRequest{Email: email, Password: pass, AppId: appID})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.Login(withChallenge(ctx, stub.solve()), &ssov1.LoginRequest{Email: email, Password: pass, AppId: appID})
+	require.NoError(t, err)
+
+	// only the methods bots call are challenged
+	_, err = client.IsAdmin(withAccessToken(ctx, adminToken(t, st)), &ssov1.IsAdminRequest{UserId: respReg.GetUserId()})
+	require.NoError(t, err)
+}
+
+func TestChallenge_Bypass(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	// the suite connects from a trusted address
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+}
+
+func withChallenge(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "x-challenge-token", token)
+}
+
+// untrustedClient returns a client connecting from untrustedAddr.
+func untrustedClient(t *testing.T, st *suite.Suite) ssov1.AuthClient {
+	t.Helper()
+
+	dialer := &net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(untrustedAddr)}}
+
+	cc, err := grpc.NewClient("passthrough:///"+net.JoinHostPort(untrustedAddr, strconv.Itoa(st.Cfg.Grpc.Port)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp", addr)
+		}),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+
+	return ssov1.NewAuthClient(cc)
+}
+
+// challengeStub plays the siteverify api of the config. Tokens it hands out
+// are valid once.
+type challengeStub struct {
+	secret string
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+var (
+	challengeStubOnce sync.Once
+	challengeStubInst *challengeStub
+	challengeStubErr  error
+)
+
+// startChallengeStub starts the stub shared by the tests of the package.
+func startChallengeStub(t *testing.T, st *suite.Suite) *challengeStub {
+	t.Helper()
+
+	challengeStubOnce.Do(func() {
+		addr, err := url.Parse(st.Cfg.Challenge.VerifyURL)
+		if err != nil {
+			challengeStubErr = err
+			return
+		}
+
+		ln, err := net.Listen("tcp", addr.Host)
+		if err != nil {
+			challengeStubErr = err
+			return
+		}
+
+		stub := &challengeStub{
+			secret: st.Cfg.Challenge.Secret,
+			tokens: make(map[string]string),
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST "+addr.Path, stub.siteverify)
+		go func() { _ = http.Serve(ln, mux) }()
+
+		challengeStubInst = stub
+	})
+	require.NoError(t, challengeStubErr)
+
+	return challengeStubInst
+}
+
+// solve returns the token of a solved challenge.
+func (s *challengeStub) solve() string {
+	token := "solved-" + gofakeit.UUID()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token] = ""
+
+	return token
+}
+
+// remoteIP returns the address the token was verified for.
+func (s *challengeStub) remoteIP(token string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tokens[token]
+}
+
+func (s *challengeStub) siteverify(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token := r.PostFormValue("response")
+
+	remoteIP, ok := s.tokens[token]
+	success := ok && remoteIP == "" && r.PostFormValue("secret") == s.secret
+
+	resp := map[string]any{"success": success}
+	if success {
+		s.tokens[token] = r.PostFormValue("remoteip")
+	} else {
+		resp["error-codes"] = []string{"invalid-input-response"}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
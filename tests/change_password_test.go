@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangePassword_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	newPass := randomFakePassword()
+	status = bearerPostForm(t, st, login.AccessToken, "/password/change", url.Values{
+		"old_password": {pass},
+		"new_password": {newPass},
+	})
+	require.Equal(t, http.StatusNoContent, status)
+
+	// refresh tokens issued before the change are revoked
+	status, _ = requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.Error(t, err)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: newPass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+}
+
+func TestChangePassword_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		token          string
+		form           url.Values
+		expectedStatus int
+	}{
+		{
+			name:           "Wrong Current Password",
+			token:          respLogin.GetToken(),
+			form:           url.Values{"old_password": {randomFakePassword()}, "new_password": {randomFakePassword()}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Too Short New Password",
+			token:          respLogin.GetToken(),
+			form:           url.Values{"old_password": {pass}, "new_password": {"abc"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid Token",
+			token:          "invalid-token",
+			form:           url.Values{"old_password": {pass}, "new_password": {randomFakePassword()}},
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := bearerPostForm(t, st, tt.token, "/password/change", tt.form)
+			assert.Equal(t, tt.expectedStatus, status)
+		})
+	}
+}
+
+func bearerPostForm(t *testing.T, st *suite.Suite, token string, path string, form url.Values) int {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, st.HTTPURL+path, strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
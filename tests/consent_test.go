@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// thirdPartyAppID is the test app users have to consent to.
+const thirdPartyAppID = 7
+
+type consentResponse struct {
+	AppID   int    `json:"app_id"`
+	AppName string `json:"app_name"`
+	Scope   string `json:"scope"`
+}
+
+func TestConsent_GrantAndRevoke(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	thirdPartyLogin := func(scope string) (int, tokenResponse) {
+		return requestToken(t, st, url.Values{
+			"grant_type": {"password"},
+			"username":   {email},
+			"password":   {pass},
+			"client_id":  {strconv.Itoa(thirdPartyAppID)},
+			"scope":      {scope},
+		})
+	}
+
+	status, resp := thirdPartyLogin("profile")
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "consent_required", resp.Error)
+
+	// first-party apps need no consent
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	code, consent := grantConsent(t, st, login.AccessToken, thirdPartyAppID, "profile")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "test-third-party", consent.AppName)
+	assert.Equal(t, "profile", consent.Scope)
+
+	status, granted := thirdPartyLogin("profile")
+	require.Equal(t, http.StatusOK, status)
+
+	status, resp = thirdPartyLogin("profile email")
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "consent_required", resp.Error)
+
+	// consents add up
+	code, consent = grantConsent(t, st, login.AccessToken, thirdPartyAppID, "email")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "profile email", consent.Scope)
+
+	code, body := adminRequest(t, st, login.AccessToken, http.MethodGet, "/consents", nil)
+	require.Equal(t, http.StatusOK, code)
+	var consents struct {
+		Consents []consentResponse `json:"consents"`
+	}
+	require.NoError(t, json.Unmarshal(body, &consents))
+	require.Len(t, consents.Consents, 1)
+	assert.Equal(t, thirdPartyAppID, consents.Consents[0].AppID)
+
+	consentPath := "/consents/" + strconv.Itoa(thirdPartyAppID)
+	code, _ = adminRequest(t, st, login.AccessToken, http.MethodDelete, consentPath, nil)
+	require.Equal(t, http.StatusNoContent, code)
+
+	// revoked consent stops refreshed tokens too
+	status, resp = requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {granted.RefreshToken},
+	})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "consent_required", resp.Error)
+
+	code, _ = adminRequest(t, st, login.AccessToken, http.MethodDelete, consentPath, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = grantConsent(t, st, login.AccessToken, thirdPartyAppID, "admin")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestConsent_GrantFailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	code, _ := grantConsent(t, st, login.AccessToken, thirdPartyAppID, "profile")
+	require.Equal(t, http.StatusOK, code)
+
+	status, thirdParty := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(thirdPartyAppID)},
+		"scope":      {"profile"},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	// the third-party app does not widen its own consent
+	code, _ = grantConsent(t, st, thirdParty.AccessToken, thirdPartyAppID, "email")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// nor does anyone acting on behalf of the user
+	code, body := adminRequest(t, st, adminToken(t, st), http.MethodPost,
+		impersonatePath(respReg.GetUserId()), map[string]any{"app_id": appID})
+	require.Equal(t, http.StatusOK, code)
+
+	var impersonation impersonationResponse
+	require.NoError(t, json.Unmarshal(body, &impersonation))
+
+	code, _ = grantConsent(t, st, impersonation.AccessToken, thirdPartyAppID, "email")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, _ = grantConsent(t, st, "", thirdPartyAppID, "email")
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func grantConsent(t *testing.T, st *suite.Suite, token string, appID int, scope string) (int, consentResponse) {
+	t.Helper()
+
+	resp := bearerPostFormBody(t, st, token, "/consents", url.Values{
+		"client_id": {strconv.Itoa(appID)},
+		"scope":     {scope},
+	})
+	defer resp.Body.Close()
+
+	var body consentResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp.StatusCode, body
+}
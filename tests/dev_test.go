@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"sso/internal/config"
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHandler keeps the attributes of the records logged.
+type recordingHandler struct {
+	mu    sync.Mutex
+	attrs map[string]slog.Value
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r.Attrs(func(a slog.Attr) bool {
+		h.attrs[a.Key] = a.Value
+		return true
+	})
+
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *recordingHandler) WithGroup(string) slog.Handler { return h }
+
+func (h *recordingHandler) attr(key string) slog.Value {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.attrs[key]
+}
+
+func TestDevMode_Login(t *testing.T) {
+	logs := &recordingHandler{attrs: map[string]slog.Value{}}
+	ctx, st := suite.NewInProcess(t, slog.New(logs), (*config.Config).SetDev)
+
+	// the admin the dev mode seeds logs in to the dev app
+	devAppID := int32(logs.attr("app_id").Int64())
+	require.NotZero(t, devAppID)
+	resp, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    logs.attr("admin_email").String(),
+		Password: logs.attr("admin_password").String(),
+		AppId:    devAppID,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.GetToken())
+
+	// users registered in memory log in too
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+	_, err = st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: pass, AppId: devAppID})
+	require.NoError(t, err)
+}
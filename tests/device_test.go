@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type deviceAuthorizationResponse struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int64  `json:"expires_in"`
+	Interval        int64  `json:"interval"`
+}
+
+func TestDevice_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	resp, err := http.PostForm(st.HTTPURL+"/device/authorize", url.Values{"client_id": {strconv.Itoa(appID)}})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var code deviceAuthorizationResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&code))
+	require.NotEmpty(t, code.DeviceCode)
+	require.NotEmpty(t, code.UserCode)
+	assert.Equal(t, st.Cfg.Device.VerificationURI, code.VerificationURI)
+
+	poll := url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code": {code.DeviceCode},
+		"client_id":   {strconv.Itoa(appID)},
+	}
+
+	status, pending := requestToken(t, st, poll)
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "authorization_pending", pending.Error)
+
+	verifyResp, err := http.PostForm(st.HTTPURL+"/device", url.Values{
+		// user codes are accepted in any case and without the dash
+		"user_code": {strings.ToLower(strings.ReplaceAll(code.UserCode, "-", ""))},
+		"username":  {email},
+		"password":  {pass},
+		"action":    {"approve"},
+	})
+	require.NoError(t, err)
+	verifyResp.Body.Close()
+	require.Equal(t, http.StatusNoContent, verifyResp.StatusCode)
+
+	time.Sleep(time.Duration(code.Interval) * time.Second)
+
+	status, tokens := requestToken(t, st, poll)
+	require.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEmpty(t, tokens.RefreshToken)
+
+	time.Sleep(time.Duration(code.Interval) * time.Second)
+
+	// device codes are single use
+	status, used := requestToken(t, st, poll)
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", used.Error)
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailChange_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	newEmail := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	status := bearerPostForm(t, st, respLogin.GetToken(), "/email/change", url.Values{
+		"password":  {pass},
+		"new_email": {newEmail},
+	})
+	require.Equal(t, http.StatusAccepted, status)
+
+	// the email does not change until the new address confirms
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	token := emailToken(t, st, newEmail)
+
+	status = postForm(t, st, "/email/change/confirm", url.Values{"token": {token}})
+	require.Equal(t, http.StatusNoContent, status)
+
+	status = postForm(t, st, "/email/change/confirm", url.Values{"token": {token}})
+	require.Equal(t, http.StatusBadRequest, status)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.Error(t, err)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    newEmail,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+}
+
+func TestEmailChange_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	takenEmail := gofakeit.Email()
+	pass := randomFakePassword()
+
+	for _, e := range []string{email, takenEmail} {
+		_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+			Email:    e,
+			Password: pass,
+		})
+		require.NoError(t, err)
+	}
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		token          string
+		form           url.Values
+		expectedStatus int
+	}{
+		{
+			name:           "Wrong Password",
+			token:          respLogin.GetToken(),
+			form:           url.Values{"password": {randomFakePassword()}, "new_email": {gofakeit.Email()}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Taken Email",
+			token:          respLogin.GetToken(),
+			form:           url.Values{"password": {pass}, "new_email": {takenEmail}},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "Invalid Token",
+			token:          "invalid-token",
+			form:           url.Values{"password": {pass}, "new_email": {gofakeit.Email()}},
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := bearerPostForm(t, st, tt.token, "/email/change", tt.form)
+			assert.Equal(t, tt.expectedStatus, status)
+		})
+	}
+}
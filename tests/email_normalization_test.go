@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestEmailNormalization(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	local := strings.ToLower(gofakeit.LetterN(12))
+
+	tests := []struct {
+		name      string
+		email     string
+		duplicate string
+	}{
+		{
+			name:      "Case",
+			email:     local + "@example.org",
+			duplicate: strings.ToUpper(local) + "@Example.ORG",
+		},
+		{
+			name:      "International domain",
+			email:     local + "@bücher.example",
+			duplicate: local + "@xn--bcher-kva.example",
+		},
+		{
+			// the test config folds Gmail addresses
+			name:      "Gmail",
+			email:     local + "@gmail.com",
+			duplicate: local[:4] + "." + local[4:] + "+news@googlemail.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pass := randomFakePassword()
+
+			_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+				Email:    tt.email,
+				Password: pass,
+			})
+			require.NoError(t, err)
+
+			_, err = st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+				Email:    tt.duplicate,
+				Password: randomFakePassword(),
+			})
+			assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+			// any spelling of the email logs the user in
+			assert.NoError(t, passwordLogin(ctx, st, tt.duplicate, pass))
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailVerification_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+
+	status, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, false, parseIDToken(t, login.IDToken)["email_verified"])
+
+	token := emailToken(t, st, email)
+
+	status = postForm(t, st, "/email/verify", url.Values{"token": {token}})
+	require.Equal(t, http.StatusNoContent, status)
+
+	// the verification link works only once
+	status = postForm(t, st, "/email/verify", url.Values{"token": {token}})
+	require.Equal(t, http.StatusBadRequest, status)
+
+	status, login = requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, parseIDToken(t, login.IDToken)["email_verified"])
+}
+
+func TestEmailVerification_UnknownToken(t *testing.T) {
+	_, st := suite.New(t)
+
+	status := postForm(t, st, "/email/verify", url.Values{"token": {"unknown-token"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+}
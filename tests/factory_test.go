@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"sso/internal/lib/emailaddr"
+	"sso/internal/storage"
+	"sso/internal/storage/factory"
+	"sso/internal/storage/memory"
+	"sso/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFactory_Drivers(t *testing.T) {
+	t.Parallel()
+
+	st, err := factory.New(factory.Config{Driver: factory.DriverSQLite, DSN: newSQLiteDB(t)}, emailaddr.Normalizer{})
+	require.NoError(t, err)
+	assert.IsType(t, &sqlite.Storage{}, st)
+	assert.NoError(t, st.Ping(context.Background()))
+	require.NoError(t, st.Close())
+
+	st, err = factory.New(factory.Config{Driver: factory.DriverMemory}, emailaddr.Normalizer{})
+	require.NoError(t, err)
+	assert.IsType(t, &memory.Storage{}, st)
+	require.NoError(t, st.Close())
+
+	assert.NotNil(t, factory.Transient(factory.DriverSQLite))
+	assert.Nil(t, factory.Transient(factory.DriverMemory))
+}
+
+func TestFactory_ValidateDSN(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		driver  string
+		dsn     string
+		wantErr bool
+	}{
+		{name: "sqlite", driver: factory.DriverSQLite, dsn: filepath.Join(dir, "sso.db")},
+		{name: "sqlite in memory", driver: factory.DriverSQLite, dsn: ":memory:"},
+		{name: "sqlite uri", driver: factory.DriverSQLite, dsn: "file:sso.db?mode=memory"},
+		{name: "sqlite without path", driver: factory.DriverSQLite, wantErr: true},
+		{name: "sqlite in missing dir", driver: factory.DriverSQLite, dsn: filepath.Join(dir, "missing", "sso.db"), wantErr: true},
+		{name: "memory", driver: factory.DriverMemory},
+		{name: "memory with dsn", driver: factory.DriverMemory, dsn: "sso.db", wantErr: true},
+		{name: "unknown driver", driver: "oracle", dsn: "sso", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := factory.ValidateDSN(tt.driver, tt.dsn)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFactory_InvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := factory.New(factory.Config{Driver: "oracle", DSN: "sso"}, emailaddr.Normalizer{})
+	assert.ErrorContains(t, err, "unknown storage driver")
+
+	// sqlite has no replicas to send reads to
+	_, err = factory.New(factory.Config{
+		Driver:   factory.DriverSQLite,
+		DSN:      newSQLiteDB(t),
+		Replicas: storage.ReplicaConfig{DSNs: []string{newSQLiteDB(t)}},
+	}, emailaddr.Normalizer{})
+	assert.ErrorContains(t, err, "no replicas")
+}
@@ -0,0 +1,453 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFederation_GoogleLogin(t *testing.T) {
+	_, st := suite.New(t)
+	google := startOIDCStub(t, st)
+
+	subject := gofakeit.UUID()
+	email := gofakeit.Email()
+
+	status, login := federatedLogin(t, st, google, "google", jwt.MapClaims{
+		"sub":            subject,
+		"email":          email,
+		"email_verified": true,
+	})
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, login.AccessToken)
+
+	claims := parseIDToken(t, login.IDToken)
+	assert.Equal(t, email, claims["email"])
+	assert.Equal(t, true, claims["email_verified"])
+	assert.Equal(t, []interface{}{"fed"}, claims["amr"])
+
+	// the next login finds the linked user
+	status, again := federatedLogin(t, st, google, "google", jwt.MapClaims{
+		"sub":            subject,
+		"email":          email,
+		"email_verified": true,
+	})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, claims["sub"], parseIDToken(t, again.IDToken)["sub"])
+
+	status, _ = federatedLogin(t, st, google, "google", jwt.MapClaims{
+		"sub":            gofakeit.UUID(),
+		"email":          gofakeit.Email(),
+		"email_verified": false,
+	})
+	assert.Equal(t, http.StatusForbidden, status)
+}
+
+func TestFederation_LinkByVerifiedEmail(t *testing.T) {
+	ctx, st := suite.New(t)
+	google := startOIDCStub(t, st)
+
+	email := gofakeit.Email()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	googleClaims := jwt.MapClaims{
+		"sub":            gofakeit.UUID(),
+		"email":          email,
+		"email_verified": true,
+	}
+
+	// the account is not linked until its email is verified
+	status, resp := federatedLogin(t, st, google, "google", googleClaims)
+	require.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "identity_not_linkable", resp.Error)
+
+	status = postForm(t, st, "/email/verify", url.Values{"token": {emailToken(t, st, email)}})
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, login := federatedLogin(t, st, google, "google", googleClaims)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, strconv.FormatInt(respReg.GetUserId(), 10), parseIDToken(t, login.IDToken)["sub"])
+}
+
+func TestFederation_StoredProvider(t *testing.T) {
+	_, st := suite.New(t)
+	upstream := startOIDCStub(t, st)
+	token := adminToken(t, st)
+
+	name := "acme-" + strings.ToLower(gofakeit.LetterN(8))
+	provider := map[string]any{
+		"issuer":        upstream.issuer,
+		"client_id":     "acme-client",
+		"client_secret": "acme-secret",
+		"scopes":        []string{"email"},
+		"claims":        map[string]string{"email": "mail"},
+	}
+
+	status, _ := adminRequest(t, st, token, http.MethodPut, "/admin/identity-providers/"+name, provider)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, body := adminRequest(t, st, token, http.MethodGet, "/admin/identity-providers", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), `"name":"`+name+`"`)
+	assert.NotContains(t, string(body), "acme-secret")
+
+	email := gofakeit.Email()
+	status, login := federatedLogin(t, st, upstream, name, jwt.MapClaims{
+		"sub":            gofakeit.UUID(),
+		"mail":           email,
+		"email_verified": true,
+	})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, email, parseIDToken(t, login.IDToken)["email"])
+
+	status, _ = adminRequest(t, st, token, http.MethodDelete, "/admin/identity-providers/"+name, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, _ = federatedGet(t, st, "/oauth/"+name+"/authorize?client_id="+strconv.Itoa(appID))
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = adminRequest(t, st, token, http.MethodDelete, "/admin/identity-providers/"+name, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestFederation_StoredProviderFailCases(t *testing.T) {
+	_, st := suite.New(t)
+	upstream := startOIDCStub(t, st)
+	token := adminToken(t, st)
+
+	tests := []struct {
+		name     string
+		provider string
+		issuer   string
+		clientID string
+	}{
+		{name: "Reserved name", provider: "google", issuer: upstream.issuer, clientID: "client"},
+		{name: "Invalid name", provider: "Acme", issuer: upstream.issuer, clientID: "client"},
+		{name: "Empty client id", provider: "acme", issuer: upstream.issuer, clientID: ""},
+		{name: "Plain http issuer", provider: "acme", issuer: "http://idp.example.com", clientID: "client"},
+		{name: "No discovery document", provider: "acme", issuer: upstream.issuer + "/missing", clientID: "client"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _ := adminRequest(t, st, token, http.MethodPut, "/admin/identity-providers/"+tt.provider, map[string]any{
+				"issuer":    tt.issuer,
+				"client_id": tt.clientID,
+			})
+			assert.Equal(t, http.StatusBadRequest, status)
+		})
+	}
+}
+
+func TestFederation_FailCases(t *testing.T) {
+	_, st := suite.New(t)
+	google := startOIDCStub(t, st)
+
+	status, _ := federatedGet(t, st, "/oauth/unknown/authorize?client_id="+strconv.Itoa(appID))
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = federatedGet(t, st, "/oauth/google/callback?state=unknown&code=unknown")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	state, nonce := startFederatedLogin(t, st, "google", st.Cfg.Federation.Google.ClientID)
+	code := google.issue(jwt.MapClaims{
+		"sub":            gofakeit.UUID(),
+		"email":          gofakeit.Email(),
+		"email_verified": true,
+		"nonce":          nonce,
+	})
+
+	status, _ = federatedGet(t, st, "/oauth/google/callback?"+url.Values{"state": {state}, "code": {code}}.Encode())
+	require.Equal(t, http.StatusOK, status)
+
+	// the state works only once
+	status, _ = federatedGet(t, st, "/oauth/google/callback?"+url.Values{"state": {state}, "code": {code}}.Encode())
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// an ID token for another login is rejected
+	state, _ = startFederatedLogin(t, st, "google", st.Cfg.Federation.Google.ClientID)
+	code = google.issue(jwt.MapClaims{
+		"sub":            gofakeit.UUID(),
+		"email":          gofakeit.Email(),
+		"email_verified": true,
+		"nonce":          "other-nonce",
+	})
+
+	status, _ = federatedGet(t, st, "/oauth/google/callback?"+url.Values{"state": {state}, "code": {code}}.Encode())
+	assert.Equal(t, http.StatusBadGateway, status)
+}
+
+func TestFederation_RedirectURI(t *testing.T) {
+	_, st := suite.New(t)
+	google := startOIDCStub(t, st)
+	admin := adminToken(t, st)
+
+	status, body := adminRequest(t, st, admin, http.MethodPost, "/admin/apps", map[string]any{
+		"name":          randomAppName(),
+		"redirect_uris": []string{"https://app.example.com/callback", "http://localhost/callback"},
+	})
+	require.Equal(t, http.StatusCreated, status)
+
+	var app appResponse
+	require.NoError(t, json.Unmarshal(body, &app))
+	clientID := strconv.Itoa(app.ID)
+
+	for _, uri := range []string{
+		"https://evil.example.com/callback",
+		"https://app.example.com/callback/../evil",
+		"https://app.example.com/callback?next=https://evil.example.com",
+		"http://app.example.com/callback",
+		"http://localhost.evil.example.com/callback",
+	} {
+		status, resp := federatedGet(t, st, "/oauth/google/authorize?"+url.Values{
+			"client_id":    {clientID},
+			"redirect_uri": {uri},
+		}.Encode())
+		assert.Equal(t, http.StatusBadRequest, status, uri)
+		assert.Equal(t, "invalid_redirect_uri", resp.Error, uri)
+	}
+
+	// local development servers may listen on any port
+	federatedRedirect(t, st, "/oauth/google/authorize?"+url.Values{
+		"client_id":    {clientID},
+		"redirect_uri": {"http://localhost:53682/callback"},
+	}.Encode())
+
+	location := federatedRedirect(t, st, "/oauth/google/authorize?"+url.Values{
+		"client_id":    {clientID},
+		"redirect_uri": {"https://app.example.com/callback"},
+		"state":        {"client-state"},
+	}.Encode())
+
+	code := google.issue(jwt.MapClaims{
+		"sub":            gofakeit.UUID(),
+		"email":          gofakeit.Email(),
+		"email_verified": true,
+		"nonce":          location.Query().Get("nonce"),
+	})
+
+	location = federatedRedirect(t, st, "/oauth/google/callback?"+url.Values{
+		"state": {location.Query().Get("state")},
+		"code":  {code},
+	}.Encode())
+	assert.Equal(t, "https://app.example.com/callback", location.Scheme+"://"+location.Host+location.Path)
+
+	fragment, err := url.ParseQuery(location.Fragment)
+	require.NoError(t, err)
+	assert.NotEmpty(t, fragment.Get("access_token"))
+	assert.Equal(t, "Bearer", fragment.Get("token_type"))
+	assert.Equal(t, "client-state", fragment.Get("state"))
+}
+
+// federatedLogin logs in with the provider, which asserts the claims.
+func federatedLogin(t *testing.T, st *suite.Suite, upstream *oidcStub, provider string, claims jwt.MapClaims) (int, tokenResponse) {
+	t.Helper()
+
+	state, nonce := startFederatedLogin(t, st, provider, "")
+	claims["nonce"] = nonce
+	code := upstream.issue(claims)
+
+	return federatedGet(t, st, "/oauth/"+provider+"/callback?"+url.Values{"state": {state}, "code": {code}}.Encode())
+}
+
+// startFederatedLogin returns the state and nonce of the redirect to the
+// provider, checking the client id unless it is empty.
+func startFederatedLogin(t *testing.T, st *suite.Suite, provider string, clientID string) (string, string) {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	resp, err := client.Get(st.HTTPURL + "/oauth/" + provider + "/authorize?client_id=" + strconv.Itoa(appID))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	q := location.Query()
+	if clientID != "" {
+		assert.Equal(t, clientID, q.Get("client_id"))
+	}
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+
+	return q.Get("state"), q.Get("nonce")
+}
+
+// federatedRedirect requests the path, expecting a redirect, and returns
+// where it leads.
+func federatedRedirect(t *testing.T, st *suite.Suite, path string) *url.URL {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	resp, err := client.Get(st.HTTPURL + path)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	return location
+}
+
+func federatedGet(t *testing.T, st *suite.Suite, path string) (int, tokenResponse) {
+	t.Helper()
+
+	resp, err := http.Get(st.HTTPURL + path)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp.StatusCode, body
+}
+
+// oidcStub plays an OpenID provider at the issuer of the Google config: it
+// redeems codes for ID tokens the tests asked it to issue. Stored providers
+// find it through its discovery document.
+type oidcStub struct {
+	issuer string
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]jwt.MapClaims
+}
+
+var (
+	oidcStubOnce sync.Once
+	oidcStubInst *oidcStub
+	oidcStubErr  error
+)
+
+// startOIDCStub starts the stub shared by the tests of the package.
+func startOIDCStub(t *testing.T, st *suite.Suite) *oidcStub {
+	t.Helper()
+
+	oidcStubOnce.Do(func() {
+		issuer, err := url.Parse(st.Cfg.Federation.Google.Issuer)
+		if err != nil {
+			oidcStubErr = err
+			return
+		}
+
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			oidcStubErr = err
+			return
+		}
+
+		ln, err := net.Listen("tcp", issuer.Host)
+		if err != nil {
+			oidcStubErr = err
+			return
+		}
+
+		stub := &oidcStub{
+			issuer: st.Cfg.Federation.Google.Issuer,
+			key:    key,
+			codes:  make(map[string]jwt.MapClaims),
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /.well-known/openid-configuration", stub.discovery)
+		mux.HandleFunc("GET /jwks", stub.jwks)
+		mux.HandleFunc("POST /token", stub.token)
+		go func() { _ = http.Serve(ln, mux) }()
+
+		oidcStubInst = stub
+	})
+	require.NoError(t, oidcStubErr)
+
+	return oidcStubInst
+}
+
+// issue returns a code the stub redeems for an ID token with the claims.
+func (s *oidcStub) issue(claims jwt.MapClaims) string {
+	code := gofakeit.UUID()
+
+	s.mu.Lock()
+	s.codes[code] = claims
+	s.mu.Unlock()
+
+	return code
+}
+
+func (s *oidcStub) discovery(w http.ResponseWriter, _ *http.Request) {
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 s.issuer,
+		"authorization_endpoint": s.issuer + "/authorize",
+		"token_endpoint":         s.issuer + "/token",
+		"jwks_uri":               s.issuer + "/jwks",
+	})
+}
+
+func (s *oidcStub) jwks(w http.ResponseWriter, _ *http.Request) {
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"keys": []map[string]string{{
+			"kid": "stub",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *oidcStub) token(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	claims, ok := s.codes[r.PostFormValue("code")]
+	delete(s.codes, r.PostFormValue("code"))
+	s.mu.Unlock()
+
+	if !ok || r.PostFormValue("code_verifier") == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims["iss"] = s.issuer
+	claims["aud"] = r.PostFormValue("client_id")
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(time.Hour).Unix()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "stub"
+
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+}
@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type groupResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Roles       []string `json:"roles"`
+}
+
+func TestGroups_Membership(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+	user, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	role := randomRoleName()
+	groupName := randomRoleName()
+	userID := strconv.FormatInt(user.GetUserId(), 10)
+	userPath := "/admin/users/" + userID
+	groupPath := "/admin/groups/" + groupName
+
+	status, _ := adminRequest(t, st, admin, http.MethodPut, "/admin/roles/"+role, map[string]any{
+		"permissions": []string{"reports:read"},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	status, body := adminRequest(t, st, admin, http.MethodPut, groupPath, map[string]any{
+		"description": "Analysts",
+		"roles":       []string{role},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	var created groupResponse
+	require.NoError(t, json.Unmarshal(body, &created))
+	assert.Equal(t, groupResponse{Name: groupName, Description: "Analysts", Roles: []string{role}}, created)
+
+	// cached before the user joins the group
+	status, allowed := checkPermission(t, st, strconv.Itoa(appID), appSecret, userID, "reports:read")
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, allowed)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, groupPath+"/members/"+userID, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, groupPath+"/members", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"user_ids":[`+userID+`]}`, string(body))
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, userPath+"/groups", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), `"name":"`+groupName+`"`)
+
+	// the roles of the group are the roles of its members
+	status, body = adminRequest(t, st, admin, http.MethodGet, userPath+"/roles", nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var roles rolesResponse
+	require.NoError(t, json.Unmarshal(body, &roles))
+	require.Len(t, roles.Roles, 1)
+	assert.Equal(t, role, roles.Roles[0].Name)
+
+	status, allowed = checkPermission(t, st, strconv.Itoa(appID), appSecret, userID, "reports:read")
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, allowed)
+
+	// tokens carry the groups on request
+	status, tokens := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+		"scope":      {"groups"},
+	})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []any{groupName}, parseToken(t, tokens.AccessToken)["groups"])
+
+	status, tokens = requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, parseToken(t, tokens.AccessToken), "groups")
+
+	status, _ = adminRequest(t, st, admin, http.MethodDelete, groupPath+"/members/"+userID, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, allowed = checkPermission(t, st, strconv.Itoa(appID), appSecret, userID, "reports:read")
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, allowed)
+
+	status, _ = adminRequest(t, st, admin, http.MethodDelete, groupPath, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodGet, groupPath, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestGroups_AssignRole(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	role := randomRoleName()
+	groupPath := "/admin/groups/" + randomRoleName()
+	userID := strconv.FormatInt(user.GetUserId(), 10)
+
+	status, _ := adminRequest(t, st, admin, http.MethodPut, "/admin/roles/"+role, map[string]any{
+		"permissions": []string{"reports:write"},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, groupPath, map[string]any{})
+	require.Equal(t, http.StatusOK, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, groupPath+"/members/"+userID, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, groupPath+"/roles/"+role, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, body := adminRequest(t, st, admin, http.MethodGet, groupPath, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var group groupResponse
+	require.NoError(t, json.Unmarshal(body, &group))
+	assert.Equal(t, []string{role}, group.Roles)
+
+	status, allowed := checkPermission(t, st, strconv.Itoa(appID), appSecret, userID, "reports:write")
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, allowed)
+
+	status, _ = adminRequest(t, st, admin, http.MethodDelete, groupPath+"/roles/"+role, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, allowed = checkPermission(t, st, strconv.Itoa(appID), appSecret, userID, "reports:write")
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, allowed)
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, "/admin/audit-events?type=group_role_revoked", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), `"detail":"`+group.Name+"/"+role+`"`)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, groupPath+"/roles/admin", nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, groupPath+"/roles/"+randomRoleName(), nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/groups/"+randomRoleName()+"/roles/"+role, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestGroups_Invalid(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	status, _ := adminRequest(t, st, admin, http.MethodPut, "/admin/groups/"+randomRoleName(), map[string]any{
+		"roles": []string{randomRoleName()},
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/groups/"+randomRoleName(), map[string]any{
+		"roles": []string{"admin"},
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/groups/"+randomRoleName()+"/members/1", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	groupPath := "/admin/groups/" + randomRoleName()
+	status, _ = adminRequest(t, st, admin, http.MethodPut, groupPath, map[string]any{})
+	require.Equal(t, http.StatusOK, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, groupPath+"/members/999999999", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
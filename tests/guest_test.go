@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuest_Upgrade(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	status, guest := createGuest(t, st, appID)
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, guest.AccessToken)
+	assert.NotEmpty(t, guest.RefreshToken)
+
+	claims := parseToken(t, guest.AccessToken)
+	assert.Equal(t, true, claims["guest"])
+	assert.NotContains(t, claims, "email")
+	guestID := int64(claims["uid"].(float64))
+	require.NotZero(t, guestID)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	code := bearerPostForm(t, st, guest.AccessToken, "/guest/upgrade", url.Values{
+		"email":    {email},
+		"password": {pass},
+	})
+	require.Equal(t, http.StatusNoContent, code)
+
+	// the upgraded guest verifies the email like any new user
+	assert.NotEmpty(t, emailToken(t, st, email))
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	claims = parseToken(t, respLogin.GetToken())
+	assert.Equal(t, guestID, int64(claims["uid"].(float64)))
+	assert.Equal(t, email, claims["email"])
+	assert.NotContains(t, claims, "guest")
+
+	// only guests can be upgraded
+	code = bearerPostForm(t, st, guest.AccessToken, "/guest/upgrade", url.Values{
+		"email":    {gofakeit.Email()},
+		"password": {randomFakePassword()},
+	})
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestGuest_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	status, _ := createGuest(t, st, 1000)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, guest := createGuest(t, st, appID)
+	require.Equal(t, http.StatusOK, status)
+
+	tests := []struct {
+		name     string
+		token    string
+		email    string
+		password string
+		code     int
+	}{
+		{
+			name:     "Taken email",
+			token:    guest.AccessToken,
+			email:    email,
+			password: randomFakePassword(),
+			code:     http.StatusConflict,
+		},
+		{
+			name:     "Weak password",
+			token:    guest.AccessToken,
+			email:    gofakeit.Email(),
+			password: "password",
+			code:     http.StatusBadRequest,
+		},
+		{
+			name:     "Without email",
+			token:    guest.AccessToken,
+			password: randomFakePassword(),
+			code:     http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid token",
+			token:    "invalid-token",
+			email:    gofakeit.Email(),
+			password: randomFakePassword(),
+			code:     http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := bearerPostForm(t, st, tt.token, "/guest/upgrade", url.Values{
+				"email":    {tt.email},
+				"password": {tt.password},
+			})
+			assert.Equal(t, tt.code, code)
+		})
+	}
+}
+
+func createGuest(t *testing.T, st *suite.Suite, appID int) (int, tokenResponse) {
+	t.Helper()
+
+	resp, err := http.PostForm(st.HTTPURL+"/guest", url.Values{
+		"client_id": {strconv.Itoa(appID)},
+	})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp.StatusCode, body
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestHealth_Ready(t *testing.T) {
+	_, st := suite.New(t)
+
+	resp, err := http.Get(st.HTTPURL + "/readyz")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok", string(body))
+}
+
+func TestHealth_GRPC(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	cc, err := grpc.NewClient(net.JoinHostPort("localhost", strconv.Itoa(st.Cfg.Grpc.Port)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+
+	client := healthgrpc.NewHealthClient(cc)
+
+	// the server as a whole and each of its services are reported
+	for _, service := range []string{"", "auth.Auth"} {
+		resp, err := client.Check(ctx, &healthgrpc.HealthCheckRequest{Service: service})
+		require.NoError(t, err, service)
+		assert.Equal(t, healthgrpc.HealthCheckResponse_SERVING, resp.GetStatus(), service)
+	}
+
+	_, err = client.Check(ctx, &healthgrpc.HealthCheckRequest{Service: "unknown.Service"})
+	require.Error(t, err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHooks_BlockedEmailDomain(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Username() + "@Blocked.test",
+		Password: randomFakePassword(),
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Contains(t, err.Error(), "email domain is not allowed")
+}
+
+func TestHooks_Webhook(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	stub := startHookStub(t, st)
+
+	// denied registration
+	deniedEmail := gofakeit.Email()
+	stub.set(deniedEmail, hookDecision{Deny: true, Reason: "not on the guest list"})
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    deniedEmail,
+		Password: randomFakePassword(),
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Contains(t, err.Error(), "not on the guest list")
+
+	// enriched registration
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+	stub.set(email, hookDecision{AppMetadata: map[string]string{"plan": "trial"}})
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, respReg.GetUserId(), stub.registered(email))
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+	code, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, code)
+
+	code, body := adminRequest(t, st, login.AccessToken, http.MethodGet, "/metadata", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var md metadataResponse
+	require.NoError(t, json.Unmarshal(body, &md))
+	assert.Equal(t, map[string]string{"plan": "trial"}, md.AppMetadata)
+
+	// denied login
+	stub.set(email, hookDecision{Deny: true, Reason: "account under review"})
+
+	resp, err := http.PostForm(st.HTTPURL+"/token", loginForm)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	var rejected struct {
+		Error  string `json:"error"`
+		Reason string `json:"reason"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&rejected))
+	assert.Equal(t, "rejected", rejected.Error)
+	assert.Equal(t, "account under review", rejected.Reason)
+
+	err = passwordLogin(ctx, st, email, pass)
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	stub.set(email, hookDecision{})
+	require.NoError(t, passwordLogin(ctx, st, email, pass))
+}
+
+type hookDecision struct {
+	Deny        bool              `json:"deny,omitempty"`
+	Reason      string            `json:"reason,omitempty"`
+	AppMetadata map[string]string `json:"app_metadata,omitempty"`
+}
+
+// hookStub plays the webhook of the config. It lets everyone through unless
+// a test sets a decision for the email.
+type hookStub struct {
+	secret string
+
+	mu        sync.Mutex
+	decisions map[string]hookDecision
+	userIDs   map[string]int64
+}
+
+var (
+	hookStubOnce sync.Once
+	hookStubInst *hookStub
+	hookStubErr  error
+)
+
+// startHookStub starts the stub shared by the tests of the package.
+func startHookStub(t *testing.T, st *suite.Suite) *hookStub {
+	t.Helper()
+
+	hookStubOnce.Do(func() {
+		webhook := st.Cfg.Hooks.Webhooks[0]
+
+		addr, err := url.Parse(webhook.URL)
+		if err != nil {
+			hookStubErr = err
+			return
+		}
+
+		ln, err := net.Listen("tcp", addr.Host)
+		if err != nil {
+			hookStubErr = err
+			return
+		}
+
+		stub := &hookStub{
+			secret:    webhook.Secret,
+			decisions: make(map[string]hookDecision),
+			userIDs:   make(map[string]int64),
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST "+addr.Path, stub.hook)
+		go func() { _ = http.Serve(ln, mux) }()
+
+		hookStubInst = stub
+	})
+	require.NoError(t, hookStubErr)
+
+	return hookStubInst
+}
+
+func (s *hookStub) set(email string, decision hookDecision) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.decisions[email] = decision
+}
+
+// registered returns the id the post_register event told for the email.
+func (s *hookStub) registered(email string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.userIDs[email]
+}
+
+func (s *hookStub) hook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.secret))
+	mac.Write([]byte(r.Header.Get("X-Webhook-Timestamp") + "."))
+	mac.Write(body)
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(r.Header.Get("X-Webhook-Signature"))) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var event struct {
+		UserID int64  `json:"user_id"`
+		Email  string `json:"email"`
+	}
+	if err = json.Unmarshal(body, &event); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("X-Webhook-Event") == "post_register" {
+		s.userIDs[event.Email] = event.UserID
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.decisions[event.Email])
+}
@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type impersonationResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func TestImpersonation_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	adminID := int64(parseToken(t, admin)["uid"].(float64))
+
+	issuedAt := time.Now()
+	code, body := adminRequest(t, st, admin, http.MethodPost, impersonatePath(respReg.GetUserId()), map[string]any{"app_id": appID})
+	require.Equal(t, http.StatusOK, code)
+
+	var resp impersonationResponse
+	require.NoError(t, json.Unmarshal(body, &resp))
+	assert.Equal(t, "Bearer", resp.TokenType)
+	assert.Equal(t, int64(st.Cfg.Impersonation.TokenTTL.Seconds()), resp.ExpiresIn)
+
+	claims := parseToken(t, resp.AccessToken)
+	assert.Equal(t, respReg.GetUserId(), int64(claims["uid"].(float64)))
+	assert.Equal(t, map[string]any{"sub": "admin:" + strconv.FormatInt(adminID, 10)}, claims["act"])
+	assert.InDelta(t, issuedAt.Add(st.Cfg.Impersonation.TokenTTL).Unix(), claims["exp"].(float64), 1)
+
+	// the token acts as the user
+	code, _ = adminRequest(t, st, resp.AccessToken, http.MethodGet, "/sessions", nil)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestImpersonation_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	adminID := int64(parseToken(t, admin)["uid"].(float64))
+
+	tests := []struct {
+		name           string
+		token          string
+		userID         int64
+		body           any
+		expectedStatus int
+	}{
+		{
+			name:           "Not an admin",
+			token:          respLogin.GetToken(),
+			userID:         respReg.GetUserId(),
+			body:           map[string]any{"app_id": appID},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Admin target",
+			token:          admin,
+			userID:         adminID,
+			body:           map[string]any{"app_id": appID},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Unknown user",
+			token:          admin,
+			userID:         999999999,
+			body:           map[string]any{"app_id": appID},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Unknown app",
+			token:          admin,
+			userID:         respReg.GetUserId(),
+			body:           map[string]any{"app_id": 999999},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "No app",
+			token:          admin,
+			userID:         respReg.GetUserId(),
+			body:           map[string]any{},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := adminRequest(t, st, tt.token, http.MethodPost, impersonatePath(tt.userID), tt.body)
+			assert.Equal(t, tt.expectedStatus, code)
+		})
+	}
+}
+
+func impersonatePath(userID int64) string {
+	return "/admin/users/" + strconv.FormatInt(userID, 10) + "/impersonate"
+}
+
+func parseToken(t *testing.T, token string) jwt.MapClaims {
+	t.Helper()
+
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return []byte(appSigningKey), nil
+	})
+	require.NoError(t, err)
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+
+	return claims
+}
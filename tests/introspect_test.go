@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type introspectResponse struct {
+	Active   bool     `json:"active"`
+	Sub      string   `json:"sub"`
+	ClientID string   `json:"client_id"`
+	Iss      string   `json:"iss"`
+	Aud      string   `json:"aud"`
+	Exp      int64    `json:"exp"`
+	Scope    string   `json:"scope"`
+	AuthTime int64    `json:"auth_time"`
+	ACR      string   `json:"acr"`
+	AMR      []string `json:"amr"`
+}
+
+func TestIntrospect_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	status, resp := introspect(t, st, respLogin.GetToken(), strconv.Itoa(appID), appSecret)
+	require.Equal(t, http.StatusOK, status)
+
+	assert.True(t, resp.Active)
+	assert.Equal(t, strconv.FormatInt(respReg.GetUserId(), 10), resp.Sub)
+	assert.Equal(t, strconv.Itoa(appID), resp.ClientID)
+	assert.Equal(t, st.Cfg.Issuer, resp.Iss)
+	assert.Equal(t, appName, resp.Aud)
+	assert.NotZero(t, resp.Exp)
+}
+
+func TestIntrospect_InactiveToken(t *testing.T) {
+	_, st := suite.New(t)
+
+	status, resp := introspect(t, st, "not-a-token", strconv.Itoa(appID), appSecret)
+	require.Equal(t, http.StatusOK, status)
+
+	assert.False(t, resp.Active)
+	assert.Empty(t, resp.Sub)
+}
+
+func TestIntrospect_InvalidClient(t *testing.T) {
+	_, st := suite.New(t)
+
+	status, _ := introspect(t, st, "not-a-token", strconv.Itoa(appID), "wrong-secret")
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func introspect(t *testing.T, st *suite.Suite, token, clientID, clientSecret string) (int, introspectResponse) {
+	t.Helper()
+
+	form := url.Values{"token": {token}}
+
+	req, err := http.NewRequest(http.MethodPost, st.HTTPURL+"/introspect", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientID, clientSecret)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body introspectResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp.StatusCode, body
+}
+
+func TestIntrospect_PASETOToken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	const (
+		pasetoAppID     = 3
+		pasetoAppName   = "test-paseto"
+		pasetoAppSecret = "test-paseto-secret"
+	)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    pasetoAppID,
+	})
+	require.NoError(t, err)
+
+	token := respLogin.GetToken()
+	require.True(t, strings.HasPrefix(token, "v4.local."))
+
+	status, resp := introspect(t, st, token, strconv.Itoa(pasetoAppID), pasetoAppSecret)
+	require.Equal(t, http.StatusOK, status)
+
+	assert.True(t, resp.Active)
+	assert.Equal(t, strconv.FormatInt(respReg.GetUserId(), 10), resp.Sub)
+	assert.Equal(t, strconv.Itoa(pasetoAppID), resp.ClientID)
+	assert.Equal(t, st.Cfg.Issuer, resp.Iss)
+	assert.Equal(t, pasetoAppName, resp.Aud)
+}
+
+func TestIntrospect_OpaqueToken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	const (
+		opaqueAppID     = 4
+		opaqueAppSecret = "test-opaque-secret"
+	)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    opaqueAppID,
+	})
+	require.NoError(t, err)
+
+	token := respLogin.GetToken()
+	require.True(t, strings.HasPrefix(token, "ot_"))
+
+	status, resp := introspect(t, st, token, strconv.Itoa(opaqueAppID), opaqueAppSecret)
+	require.Equal(t, http.StatusOK, status)
+
+	assert.True(t, resp.Active)
+	assert.Equal(t, strconv.FormatInt(respReg.GetUserId(), 10), resp.Sub)
+	assert.Equal(t, strconv.Itoa(opaqueAppID), resp.ClientID)
+
+	req, err := http.NewRequest(http.MethodPost, st.HTTPURL+"/logout", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	logoutResp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	logoutResp.Body.Close()
+	require.Equal(t, http.StatusNoContent, logoutResp.StatusCode)
+
+	status, resp = introspect(t, st, token, strconv.Itoa(opaqueAppID), opaqueAppSecret)
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, resp.Active)
+}
@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type invitationAcceptedResponse struct {
+	UserID int64 `json:"user_id"`
+	tokenResponse
+}
+
+func TestInvitation_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	code, _ := adminRequest(t, st, adminToken(t, st), http.MethodPost, "/admin/invitations", map[string]any{
+		"email":  email,
+		"role":   "admin",
+		"app_id": appID,
+	})
+	require.Equal(t, http.StatusCreated, code)
+
+	token := emailToken(t, st, email)
+
+	status, accepted := acceptInvitation(t, st, token, pass)
+	require.Equal(t, http.StatusCreated, status)
+	require.NotZero(t, accepted.UserID)
+	assert.NotEmpty(t, accepted.AccessToken)
+	assert.NotEmpty(t, accepted.RefreshToken)
+
+	claims := parseToken(t, accepted.AccessToken)
+	assert.Equal(t, accepted.UserID, int64(claims["uid"].(float64)))
+	assert.Equal(t, true, parseIDToken(t, accepted.IDToken)["email_verified"])
+
+	respIsAdmin, err := st.AuthClient.IsAdmin(withAccessToken(ctx, adminToken(t, st)), &ssov1.IsAdminRequest{UserId: accepted.UserID})
+	require.NoError(t, err)
+	assert.True(t, respIsAdmin.GetIsAdmin())
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	// invitations are single-use
+	status, _ = acceptInvitation(t, st, token, randomFakePassword())
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestInvitation_WithoutApp(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+
+	code, body := adminRequest(t, st, adminToken(t, st), http.MethodPost, "/admin/invitations", map[string]any{
+		"email": email,
+	})
+	require.Equal(t, http.StatusCreated, code)
+
+	var invitation struct {
+		Role string `json:"role"`
+	}
+	require.NoError(t, json.Unmarshal(body, &invitation))
+	assert.Equal(t, "user", invitation.Role)
+
+	status, accepted := acceptInvitation(t, st, emailToken(t, st, email), randomFakePassword())
+	require.Equal(t, http.StatusCreated, status)
+	require.NotZero(t, accepted.UserID)
+	assert.Empty(t, accepted.AccessToken)
+
+	respIsAdmin, err := st.AuthClient.IsAdmin(withAccessToken(ctx, adminToken(t, st)), &ssov1.IsAdminRequest{UserId: accepted.UserID})
+	require.NoError(t, err)
+	assert.False(t, respIsAdmin.GetIsAdmin())
+}
+
+func TestInvitation_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	admin := adminToken(t, st)
+
+	tests := []struct {
+		name  string
+		token string
+		body  map[string]any
+		code  int
+	}{
+		{
+			name:  "Not an admin",
+			token: login.AccessToken,
+			body:  map[string]any{"email": gofakeit.Email()},
+			code:  http.StatusForbidden,
+		},
+		{
+			name:  "Registered email",
+			token: admin,
+			body:  map[string]any{"email": email},
+			code:  http.StatusConflict,
+		},
+		{
+			name:  "Unknown role",
+			token: admin,
+			body:  map[string]any{"email": gofakeit.Email(), "role": "owner"},
+			code:  http.StatusBadRequest,
+		},
+		{
+			name:  "Unknown app",
+			token: admin,
+			body:  map[string]any{"email": gofakeit.Email(), "app_id": 1000},
+			code:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := adminRequest(t, st, tt.token, http.MethodPost, "/admin/invitations", tt.body)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+
+	status, _ = acceptInvitation(t, st, "unknown-token", randomFakePassword())
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func acceptInvitation(t *testing.T, st *suite.Suite, token string, password string) (int, invitationAcceptedResponse) {
+	t.Helper()
+
+	resp, err := http.PostForm(st.HTTPURL+"/invitations/accept", url.Values{
+		"token":    {token},
+		"password": {password},
+	})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body invitationAcceptedResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp.StatusCode, body
+}
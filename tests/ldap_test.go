@@ -0,0 +1,365 @@
+package tests
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	ldapAppID         = 6
+	ldapAppSigningKey = "test-ldap-signing-key"
+	ldapAdminsDN      = "cn=admins,ou=groups,dc=sso,dc=test"
+)
+
+func TestLDAP_Login(t *testing.T) {
+	ctx, st := suite.New(t)
+	directory := startLDAPStub(t, st)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+	directory.add(email, pass)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    ldapAppID,
+	})
+	require.NoError(t, err)
+
+	claims := parseLDAPToken(t, respLogin.GetToken())
+	assert.Equal(t, email, claims["email"])
+
+	// the next login finds the provisioned user
+	respLogin, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    ldapAppID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, claims["uid"], parseLDAPToken(t, respLogin.GetToken())["uid"])
+
+	isAdmin, err := st.AuthClient.IsAdmin(withAccessToken(ctx, adminToken(t, st)), &ssov1.IsAdminRequest{UserId: int64(claims["uid"].(float64))})
+	require.NoError(t, err)
+	assert.False(t, isAdmin.GetIsAdmin())
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: randomFakePassword(),
+		AppId:    ldapAppID,
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestLDAP_LocalPasswordNotAccepted(t *testing.T) {
+	ctx, st := suite.New(t)
+	startLDAPStub(t, st)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    ldapAppID,
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// apps without a directory still accept the local password
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+}
+
+func TestLDAP_ExistingUser(t *testing.T) {
+	ctx, st := suite.New(t)
+	directory := startLDAPStub(t, st)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+	directory.add(email, pass)
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	// the local user is not used until its email is verified
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    ldapAppID,
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	code := postForm(t, st, "/email/verify", url.Values{"token": {emailToken(t, st, email)}})
+	require.Equal(t, http.StatusNoContent, code)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    ldapAppID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, respReg.GetUserId(), int64(parseLDAPToken(t, respLogin.GetToken())["uid"].(float64)))
+}
+
+func TestLDAP_GroupRoles(t *testing.T) {
+	ctx, st := suite.New(t)
+	directory := startLDAPStub(t, st)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+	directory.add(email, pass, strings.ToUpper(ldapAdminsDN))
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    ldapAppID,
+	})
+	require.NoError(t, err)
+	userID := int64(parseLDAPToken(t, respLogin.GetToken())["uid"].(float64))
+
+	isAdmin, err := st.AuthClient.IsAdmin(withAccessToken(ctx, adminToken(t, st)), &ssov1.IsAdminRequest{UserId: userID})
+	require.NoError(t, err)
+	assert.True(t, isAdmin.GetIsAdmin())
+
+	// leaving the group revokes the role on the next login
+	directory.add(email, pass)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    ldapAppID,
+	})
+	require.NoError(t, err)
+
+	isAdmin, err = st.AuthClient.IsAdmin(withAccessToken(ctx, adminToken(t, st)), &ssov1.IsAdminRequest{UserId: userID})
+	require.NoError(t, err)
+	assert.False(t, isAdmin.GetIsAdmin())
+}
+
+func parseLDAPToken(t *testing.T, token string) jwt.MapClaims {
+	t.Helper()
+
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return []byte(ldapAppSigningKey), nil
+	})
+	require.NoError(t, err)
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+
+	return claims
+}
+
+// ldapStub plays the directory of the config. It answers simple binds and
+// searches by mail, the only requests the service makes.
+type ldapStub struct {
+	bindDN       string
+	bindPassword string
+
+	mu    sync.Mutex
+	users map[string]ldapUser
+}
+
+type ldapUser struct {
+	dn       string
+	password string
+	groups   []string
+}
+
+var (
+	ldapStubOnce sync.Once
+	ldapStubInst *ldapStub
+	ldapStubErr  error
+)
+
+// startLDAPStub starts the stub shared by the tests of the package.
+func startLDAPStub(t *testing.T, st *suite.Suite) *ldapStub {
+	t.Helper()
+
+	ldapStubOnce.Do(func() {
+		cfg := st.Cfg.LDAP.Directories["corp"]
+
+		addr, err := url.Parse(cfg.URL)
+		if err != nil {
+			ldapStubErr = err
+			return
+		}
+
+		ln, err := net.Listen("tcp", addr.Host)
+		if err != nil {
+			ldapStubErr = err
+			return
+		}
+
+		stub := &ldapStub{
+			bindDN:       cfg.BindDN,
+			bindPassword: cfg.BindPassword,
+			users:        make(map[string]ldapUser),
+		}
+
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				go stub.serve(conn)
+			}
+		}()
+
+		ldapStubInst = stub
+	})
+	require.NoError(t, ldapStubErr)
+
+	return ldapStubInst
+}
+
+// add creates or replaces the directory user with the mail.
+func (s *ldapStub) add(mail string, password string, groups ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[mail] = ldapUser{
+		dn:       "uid=" + ldap.EscapeDN(mail) + ",ou=people,dc=sso,dc=test",
+		password: password,
+		groups:   groups,
+	}
+}
+
+func (s *ldapStub) serve(conn net.Conn) {
+	defer conn.Close()
+
+	var boundDN string
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		id, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := op.Children[1].Data.String()
+			code := s.bind(dn, op.Children[2].Data.String())
+			if code == ldap.LDAPResultSuccess {
+				boundDN = dn
+			}
+			_, _ = conn.Write(ldapMessage(id, ldapResult(ldap.ApplicationBindResponse, code)))
+		case ldap.ApplicationSearchRequest:
+			if boundDN != s.bindDN {
+				_, _ = conn.Write(ldapMessage(id, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights)))
+				continue
+			}
+
+			// the filter is an equality match on mail
+			filter := op.Children[6]
+			if user, ok := s.user(filter.Children[1].Data.String()); ok {
+				_, _ = conn.Write(ldapMessage(id, ldapEntry(user, filter.Children[1].Data.String())))
+			}
+			_, _ = conn.Write(ldapMessage(id, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)))
+		default:
+			return
+		}
+	}
+}
+
+func (s *ldapStub) bind(dn string, password string) uint16 {
+	if dn == s.bindDN && password == s.bindPassword {
+		return ldap.LDAPResultSuccess
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.dn == dn && user.password == password {
+			return ldap.LDAPResultSuccess
+		}
+	}
+
+	return ldap.LDAPResultInvalidCredentials
+}
+
+func (s *ldapStub) user(mail string) (ldapUser, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[mail]
+
+	return user, ok
+}
+
+func ldapMessage(id int64, op *ber.Packet) []byte {
+	msg := ber.NewSequence("message")
+	msg.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "id"))
+	msg.AppendChild(op)
+
+	return msg.Bytes()
+}
+
+func ldapResult(tag ber.Tag, code uint16) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matched dn"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "message"))
+
+	return result
+}
+
+func ldapEntry(user ldapUser, mail string) *ber.Packet {
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, user.dn, "dn"))
+
+	attrs := ber.NewSequence("attributes")
+	attrs.AppendChild(ldapAttribute("mail", mail))
+	attrs.AppendChild(ldapAttribute("memberOf", user.groups...))
+	entry.AppendChild(attrs)
+
+	return entry
+}
+
+func ldapAttribute(name string, values ...string) *ber.Packet {
+	attr := ber.NewSequence("attribute")
+	attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+
+	set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "values")
+	for _, value := range values {
+		set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+	}
+	attr.AppendChild(set)
+
+	return attr
+}
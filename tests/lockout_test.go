@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLockout_LockAndUnlock(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < st.Cfg.Lockout.Threshold; i++ {
+		err = passwordLogin(ctx, st, email, randomFakePassword())
+		require.Error(t, err)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+
+	// the right password does not help while locked
+	err = passwordLogin(ctx, st, email, pass)
+	require.Error(t, err)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	admin := adminToken(t, st)
+	unlockPath := "/admin/users/" + strconv.FormatInt(respReg.GetUserId(), 10) + "/unlock"
+
+	code, _ := adminRequest(t, st, admin, http.MethodPost, unlockPath, nil)
+	require.Equal(t, http.StatusNoContent, code)
+
+	require.NoError(t, passwordLogin(ctx, st, email, pass))
+
+	code, _ = adminRequest(t, st, admin, http.MethodPost, "/admin/users/999999999/unlock", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestLockout_SuccessResetsFailures(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	for round := 0; round < 2; round++ {
+		for i := 0; i < st.Cfg.Lockout.Threshold-1; i++ {
+			require.Error(t, passwordLogin(ctx, st, email, randomFakePassword()))
+		}
+
+		require.NoError(t, passwordLogin(ctx, st, email, pass))
+	}
+}
+
+func passwordLogin(ctx context.Context, st *suite.Suite, email string, password string) error {
+	_, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    appID,
+	})
+
+	return err
+}
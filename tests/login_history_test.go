@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type loginHistoryResponse struct {
+	Logins []struct {
+		AppID     int       `json:"app_id"`
+		IP        string    `json:"ip"`
+		AMR       []string  `json:"amr"`
+		CreatedAt time.Time `json:"created_at"`
+	} `json:"logins"`
+}
+
+func TestLoginHistory(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+	code, _ := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, code)
+	code, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, code)
+
+	// refreshes are not logins
+	code, _ = requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	// failed logins are not either
+	require.Error(t, passwordLogin(ctx, st, email, randomFakePassword()))
+
+	code, body := adminRequest(t, st, login.AccessToken, http.MethodGet, "/login-history", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var history loginHistoryResponse
+	require.NoError(t, json.Unmarshal(body, &history))
+	require.Len(t, history.Logins, 2)
+	for _, entry := range history.Logins {
+		assert.Equal(t, appID, entry.AppID)
+		assert.NotEmpty(t, entry.IP)
+		assert.Equal(t, []string{"pwd"}, entry.AMR)
+	}
+	assert.False(t, history.Logins[0].CreatedAt.Before(history.Logins[1].CreatedAt))
+
+	admin := adminToken(t, st)
+	userPath := "/admin/users/" + strconv.FormatInt(respReg.GetUserId(), 10)
+
+	code, body = adminRequest(t, st, admin, http.MethodGet, userPath+"/login-history", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var adminHistory loginHistoryResponse
+	require.NoError(t, json.Unmarshal(body, &adminHistory))
+	assert.Equal(t, history, adminHistory)
+
+	code, body = adminRequest(t, st, admin, http.MethodGet, userPath, nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var user struct {
+		LastLoginAt *time.Time `json:"last_login_at"`
+		LastLoginIP string     `json:"last_login_ip"`
+	}
+	require.NoError(t, json.Unmarshal(body, &user))
+	require.NotNil(t, user.LastLoginAt)
+	assert.WithinDuration(t, history.Logins[0].CreatedAt, *user.LastLoginAt, time.Second)
+	assert.Equal(t, history.Logins[0].IP, user.LastLoginIP)
+}
+
+func TestLoginHistory_FailCases(t *testing.T) {
+	_, st := suite.New(t)
+
+	code, _ := adminRequest(t, st, "", http.MethodGet, "/login-history", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	admin := adminToken(t, st)
+
+	code, _ = adminRequest(t, st, admin, http.MethodGet, "/admin/users/999999999/login-history", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
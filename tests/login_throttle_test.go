@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// throttledAddr is a loopback address outside of the login throttle bypass
+// networks of the config, used by no other test.
+const throttledAddr = "127.0.0.3"
+
+func TestLoginThrottle_Email(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	// nobody is registered with the email, so no account gets locked
+	email := gofakeit.Email()
+
+	for i := 0; i < st.Cfg.LoginThrottle.EmailLimit; i++ {
+		err := passwordLogin(ctx, st, email, randomFakePassword())
+		require.Error(t, err)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+
+	err := passwordLogin(ctx, st, strings.ToUpper(email), randomFakePassword())
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// other logins are not throttled
+	pass := randomFakePassword()
+	other := gofakeit.Email()
+	_, err = st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: other, Password: pass})
+	require.NoError(t, err)
+	require.NoError(t, passwordLogin(ctx, st, other, pass))
+}
+
+func TestLoginThrottle_IP(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	for i := 0; i < st.Cfg.LoginThrottle.IPLimit; i++ {
+		code, body := throttledPasswordGrant(t, st, gofakeit.Email(), randomFakePassword())
+		require.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "invalid_grant", body.Error)
+	}
+
+	// even the right password is rejected from the throttled IP
+	code, body := throttledPasswordGrant(t, st, email, pass)
+	assert.Equal(t, http.StatusTooManyRequests, code)
+	assert.Equal(t, "slow_down", body.Error)
+
+	require.NoError(t, passwordLogin(ctx, st, email, pass))
+}
+
+// throttledPasswordGrant logs in with the password grant from throttledAddr.
+func throttledPasswordGrant(t *testing.T, st *suite.Suite, email string, password string) (int, tokenResponse) {
+	t.Helper()
+
+	dialer := &net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(throttledAddr)}}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}}
+	defer client.CloseIdleConnections()
+
+	form := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {password},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+	resp, err := client.PostForm(st.HTTPURL+"/token", form)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp.StatusCode, body
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMagicLink_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	status := postForm(t, st, "/magic-link", url.Values{
+		"email":     {email},
+		"client_id": {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusAccepted, status)
+
+	token := emailToken(t, st, email)
+
+	status, login := redeemMagicLink(t, st, token)
+	require.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, login.AccessToken)
+	assert.NotEmpty(t, login.RefreshToken)
+
+	// the link works only once
+	status, resp := redeemMagicLink(t, st, token)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_magic_link", resp.Error)
+}
+
+func TestMagicLink_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	// unknown emails are not disclosed
+	status := postForm(t, st, "/magic-link", url.Values{
+		"email":     {gofakeit.Email()},
+		"client_id": {strconv.Itoa(appID)},
+	})
+	assert.Equal(t, http.StatusAccepted, status)
+
+	status = postForm(t, st, "/magic-link", url.Values{
+		"email":     {email},
+		"client_id": {"999"},
+	})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = redeemMagicLink(t, st, "unknown")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	sentBefore := sentEmails(t, st, email)
+
+	// requests above the limit are accepted but send nothing
+	for i := 0; i < st.Cfg.MagicLink.Limit+2; i++ {
+		status = postForm(t, st, "/magic-link", url.Values{
+			"email":     {email},
+			"client_id": {strconv.Itoa(appID)},
+		})
+		require.Equal(t, http.StatusAccepted, status)
+	}
+
+	assert.Equal(t, sentBefore+st.Cfg.MagicLink.Limit, sentEmails(t, st, email))
+}
+
+func redeemMagicLink(t *testing.T, st *suite.Suite, token string) (int, tokenResponse) {
+	t.Helper()
+
+	form := url.Values{"token": {token}}
+	resp, err := http.Post(st.HTTPURL+"/magic-link/redeem", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp.StatusCode, body
+}
+
+// sentEmails returns the number of emails sent to the address.
+func sentEmails(t *testing.T, st *suite.Suite, to string) int {
+	t.Helper()
+
+	dir := st.Cfg.Email.Dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join("..", dir)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*_"+to+".eml"))
+	require.NoError(t, err)
+
+	return len(files)
+}
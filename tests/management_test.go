@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"sso/internal/config"
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	adminEmail    = "admin@sso.test"
+	adminPassword = "test-admin-password"
+
+	mappingAppID         = 5
+	mappingAppSigningKey = "test-mapping-signing-key"
+)
+
+func TestManagement_ClaimMappings(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	admin := adminToken(t, st)
+	mappingsPath := "/admin/apps/" + strconv.Itoa(mappingAppID) + "/claim-mappings"
+
+	status, _ := adminRequest(t, st, admin, http.MethodPut, mappingsPath+"/roles",
+		map[string]string{"target": "https://test-mapping/roles"})
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, body := adminRequest(t, st, admin, http.MethodGet, mappingsPath, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"mappings": [{"source": "roles", "target": "https://test-mapping/roles"}]}`, string(body))
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    mappingAppID,
+	})
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(respLogin.GetToken(), claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(mappingAppSigningKey), nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []interface{}{"reader"}, claims["https://test-mapping/roles"])
+	assert.NotContains(t, claims, "roles")
+
+	status, _ = adminRequest(t, st, admin, http.MethodDelete, mappingsPath+"/roles", nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodDelete, mappingsPath+"/roles", nil)
+	require.Equal(t, http.StatusNotFound, status)
+}
+
+func TestManagement_ClaimMappings_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	status, _ := adminRequest(t, st, admin, http.MethodPut, "/admin/apps/1/claim-mappings/exp",
+		map[string]string{"target": "expires"})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/apps/1/claim-mappings/roles",
+		map[string]string{"target": "aud"})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodGet, "/admin/apps/999/claim-mappings", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = adminRequest(t, st, "", http.MethodGet, "/admin/apps/1/claim-mappings", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	status, _ = adminRequest(t, st, respLogin.GetToken(), http.MethodGet, "/admin/apps/1/claim-mappings", nil)
+	assert.Equal(t, http.StatusForbidden, status)
+}
+
+// adminToken logs in as the seeded admin user.
+func adminToken(t *testing.T, st *suite.Suite) string {
+	t.Helper()
+
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {adminEmail},
+		"password":   {adminPassword},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	return login.AccessToken
+}
+
+func TestManagement_ThirdPartyAppToken(t *testing.T) {
+	_, st := suite.New(t)
+
+	// the admin lets a third-party app have tokens of theirs
+	code, _ := grantConsent(t, st, adminToken(t, st), thirdPartyAppID, "profile")
+	require.Equal(t, http.StatusOK, code)
+
+	code, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {adminEmail},
+		"password":   {adminPassword},
+		"client_id":  {strconv.Itoa(thirdPartyAppID)},
+		"scope":      {"profile"},
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	code, _ = adminRequest(t, st, login.AccessToken, http.MethodGet, "/admin/apps", nil)
+	assert.Equal(t, http.StatusForbidden, code)
+}
+
+func TestManagement_AdminAudience(t *testing.T) {
+	_, st := suite.NewInProcess(t, nil, func(cfg *config.Config) {
+		cfg.AdminAudience = cfg.Audience
+		cfg.Audience = "test-account"
+	})
+
+	admin := adminToken(t, st)
+
+	code, _ := adminRequest(t, st, admin, http.MethodGet, "/admin/apps", nil)
+	assert.Equal(t, http.StatusOK, code)
+
+	// the app of the admin API is not the one of the account endpoints
+	code, _ = adminRequest(t, st, admin, http.MethodGet, "/metadata", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+// adminRequest calls the admin API with the token, sending body as JSON.
+func adminRequest(t *testing.T, st *suite.Suite, token string, method string, path string, body any) (int, []byte) {
+	t.Helper()
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, st.HTTPURL+path, reqBody)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp.StatusCode, respBody
+}
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type metadataResponse struct {
+	AppMetadata  map[string]string `json:"app_metadata"`
+	UserMetadata map[string]string `json:"user_metadata"`
+}
+
+func TestMetadata(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+	code, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, code)
+
+	// users write their user metadata, app metadata in the body is ignored
+	code, _ = adminRequest(t, st, login.AccessToken, http.MethodPut, "/metadata", map[string]any{
+		"user_metadata": map[string]string{"locale": "de"},
+		"app_metadata":  map[string]string{"plan": "enterprise"},
+	})
+	require.Equal(t, http.StatusNoContent, code)
+
+	// admins write app metadata through the user update
+	code, _ = updateUser(t, st, adminToken(t, st), respReg.GetUserId(), "", map[string]any{
+		"update_mask":  "app_metadata",
+		"app_metadata": map[string]string{"plan": "pro"},
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	code, body := adminRequest(t, st, login.AccessToken, http.MethodGet, "/metadata", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var md metadataResponse
+	require.NoError(t, json.Unmarshal(body, &md))
+	assert.Equal(t, map[string]string{"plan": "pro"}, md.AppMetadata)
+	assert.Equal(t, map[string]string{"locale": "de"}, md.UserMetadata)
+
+	// tokens only carry the metadata with the metadata scope
+	code, plain := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, code)
+	claims := parseToken(t, plain.AccessToken)
+	assert.NotContains(t, claims, "app_metadata")
+	assert.NotContains(t, claims, "user_metadata")
+
+	loginForm.Set("scope", "profile metadata")
+	code, scoped := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, code)
+	claims = parseToken(t, scoped.AccessToken)
+	assert.Equal(t, map[string]any{"plan": "pro"}, claims["app_metadata"])
+	assert.Equal(t, map[string]any{"locale": "de"}, claims["user_metadata"])
+}
+
+func TestMetadata_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	code, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	tooMany := make(map[string]string)
+	for i := range 33 {
+		tooMany["key"+strconv.Itoa(i)] = "value"
+	}
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+	}{
+		{
+			name:     "Invalid key",
+			metadata: map[string]string{"not a key": "value"},
+		},
+		{
+			name:     "Too many keys",
+			metadata: tooMany,
+		},
+		{
+			name:     "Value too long",
+			metadata: map[string]string{"key": strings.Repeat("a", 513)},
+		},
+		{
+			name: "Too large",
+			metadata: map[string]string{
+				"a": strings.Repeat("a", 500), "b": strings.Repeat("b", 500), "c": strings.Repeat("c", 500),
+				"d": strings.Repeat("d", 500), "e": strings.Repeat("e", 500), "f": strings.Repeat("f", 500),
+				"g": strings.Repeat("g", 500), "h": strings.Repeat("h", 500), "i": strings.Repeat("i", 500),
+			},
+		},
+	}
+
+	admin := adminToken(t, st)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := adminRequest(t, st, login.AccessToken, http.MethodPut, "/metadata", map[string]any{
+				"user_metadata": tt.metadata,
+			})
+			assert.Equal(t, http.StatusBadRequest, code)
+
+			code, _ = updateUser(t, st, admin, respReg.GetUserId(), "", map[string]any{
+				"update_mask":  "app_metadata",
+				"app_metadata": tt.metadata,
+			})
+			assert.Equal(t, http.StatusBadRequest, code)
+		})
+	}
+
+	code, _ = adminRequest(t, st, "", http.MethodGet, "/metadata", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
@@ -0,0 +1,262 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"sso/internal/lib/totp"
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const grantTypeMFAOTP = "urn:sso:params:oauth:grant-type:mfa-otp"
+
+const grantTypeMFARecoveryCode = "urn:sso:params:oauth:grant-type:mfa-recovery-code"
+
+type totpEnrollmentResponse struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func TestMFA_TOTPLogin(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+
+	status, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, status)
+
+	secret := enrollTOTP(t, st, login.AccessToken).Secret
+
+	// every step accepts one code, so the test uses consecutive steps
+	step := currentTOTPStep()
+
+	status = bearerPostForm(t, st, login.AccessToken, "/mfa/totp/verify", url.Values{
+		"code": {totpCode(t, secret, step-1)},
+	})
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, challenge := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, "mfa_required", challenge.Error)
+	assert.Empty(t, challenge.AccessToken)
+	require.NotEmpty(t, challenge.MFAToken)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.Error(t, err)
+
+	status, _ = requestToken(t, st, url.Values{
+		"grant_type": {grantTypeMFAOTP},
+		"mfa_token":  {challenge.MFAToken},
+		"otp":        {"000000"},
+	})
+	require.Equal(t, http.StatusBadRequest, status)
+
+	status, mfaLogin := requestToken(t, st, url.Values{
+		"grant_type": {grantTypeMFAOTP},
+		"mfa_token":  {challenge.MFAToken},
+		"otp":        {totpCode(t, secret, step)},
+	})
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, mfaLogin.AccessToken)
+	assert.Equal(t, []interface{}{"pwd", "otp"}, parseIDToken(t, mfaLogin.IDToken)["amr"])
+
+	// the challenge and the code can not be used again
+	status, _ = requestToken(t, st, url.Values{
+		"grant_type": {grantTypeMFAOTP},
+		"mfa_token":  {challenge.MFAToken},
+		"otp":        {totpCode(t, secret, step)},
+	})
+	require.Equal(t, http.StatusBadRequest, status)
+
+	status = bearerPostForm(t, st, mfaLogin.AccessToken, "/mfa/totp/disable", url.Values{
+		"code": {totpCode(t, secret, step+1)},
+	})
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, _ = requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, status)
+}
+
+func TestMFA_EnrollFailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+	token := respLogin.GetToken()
+
+	status := bearerPostForm(t, st, token, "/mfa/totp/verify", url.Values{"code": {"123456"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	secret := enrollTOTP(t, st, token).Secret
+
+	status = bearerPostForm(t, st, token, "/mfa/totp/verify", url.Values{"code": {"000000"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status = bearerPostForm(t, st, token, "/mfa/totp/verify", url.Values{
+		"code": {totpCode(t, secret, currentTOTPStep())},
+	})
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, _ = adminRequest(t, st, token, http.MethodPost, "/mfa/totp/enroll", nil)
+	assert.Equal(t, http.StatusConflict, status)
+
+	status, _ = adminRequest(t, st, "invalid-token", http.MethodPost, "/mfa/totp/enroll", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestMFA_RecoveryCodes(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+
+	status, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, status)
+
+	enrollment := enrollTOTP(t, st, login.AccessToken)
+	require.Len(t, enrollment.RecoveryCodes, 10)
+
+	step := currentTOTPStep()
+	status = bearerPostForm(t, st, login.AccessToken, "/mfa/totp/verify", url.Values{
+		"code": {totpCode(t, enrollment.Secret, step)},
+	})
+	require.Equal(t, http.StatusNoContent, status)
+
+	recoveryLogin := func(code string) (int, tokenResponse) {
+		status, challenge := requestToken(t, st, loginForm)
+		require.Equal(t, http.StatusForbidden, status)
+
+		return requestToken(t, st, url.Values{
+			"grant_type":    {grantTypeMFARecoveryCode},
+			"mfa_token":     {challenge.MFAToken},
+			"recovery_code": {code},
+		})
+	}
+
+	// codes are accepted in any case
+	status, tokens := recoveryLogin(strings.ToUpper(enrollment.RecoveryCodes[0]))
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, tokens.AccessToken)
+
+	status, _ = recoveryLogin(enrollment.RecoveryCodes[0])
+	require.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = adminRequest(t, st, "", http.MethodPost, "/mfa/recovery-codes", nil)
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	status = bearerPostForm(t, st, tokens.AccessToken, "/mfa/recovery-codes", url.Values{"code": {"000000"}})
+	require.Equal(t, http.StatusBadRequest, status)
+
+	req, err := http.NewRequest(http.MethodPost, st.HTTPURL+"/mfa/recovery-codes",
+		strings.NewReader(url.Values{"code": {totpCode(t, enrollment.Secret, step+1)}}.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var regenerated struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&regenerated))
+	require.Len(t, regenerated.RecoveryCodes, 10)
+
+	// the old codes are gone
+	status, _ = recoveryLogin(enrollment.RecoveryCodes[1])
+	require.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = recoveryLogin(regenerated.RecoveryCodes[0])
+	require.Equal(t, http.StatusOK, status)
+}
+
+func enrollTOTP(t *testing.T, st *suite.Suite, token string) totpEnrollmentResponse {
+	t.Helper()
+
+	status, body := adminRequest(t, st, token, http.MethodPost, "/mfa/totp/enroll", nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var enrollment totpEnrollmentResponse
+	require.NoError(t, json.Unmarshal(body, &enrollment))
+	require.NotEmpty(t, enrollment.Secret)
+	assert.Contains(t, enrollment.URI, "otpauth://totp/")
+
+	return enrollment
+}
+
+// currentTOTPStep waits out the end of a period, so that codes computed
+// for the returned step stay valid while the test runs.
+func currentTOTPStep() int64 {
+	now := time.Now()
+	if left := totp.Period - now.Sub(time.Unix(totp.Step(now)*int64(totp.Period.Seconds()), 0)); left < 5*time.Second {
+		time.Sleep(left)
+	}
+
+	return totp.Step(time.Now())
+}
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, step)
+	require.NoError(t, err)
+
+	return code
+}
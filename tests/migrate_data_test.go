@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sso/internal/app"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/emailaddr"
+	"sso/internal/lib/logger/slogdiscard"
+	"sso/internal/storage"
+	"sso/internal/storage/sqlite"
+	"sso/internal/storage/transfer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateData_SQLiteToSQLite(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	from := newSQLiteDB(t)
+	to := filepath.Join(t.TempDir(), "copy.db")
+
+	src, err := sqlite.New(from, emailaddr.Normalizer{}, storage.PoolConfig{}, sqlite.Pragmas{})
+	require.NoError(t, err)
+
+	// the ids have a gap, which the copy must keep rather than renumber
+	firstID, err := src.SaveUser(ctx, "first@example.com", []byte("hash"), models.UserProfile{})
+	require.NoError(t, err)
+	userID, err := src.SaveUser(ctx, "second@example.com", []byte("hash"), models.UserProfile{})
+	require.NoError(t, err)
+	require.NoError(t, src.DeleteUser(ctx, firstID, userID, time.Now(), time.Now()))
+	lastID, err := src.SaveUser(ctx, "third@example.com", []byte("hash"), models.UserProfile{})
+	require.NoError(t, err)
+
+	appID, err := src.SaveApp(ctx, models.App{Name: "copied", OrgID: models.DefaultOrgID, SecretHash: "secret-hash"}, userID)
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, src.SaveRefreshToken(ctx, models.RefreshToken{
+		TokenHash:        "token-hash",
+		FamilyID:         "family",
+		UserID:           lastID,
+		AppID:            appID,
+		ExpiresAt:        now.Add(time.Hour),
+		CreatedAt:        now,
+		SessionStartedAt: now,
+		AuthTime:         now,
+	}))
+	require.NoError(t, src.Close())
+
+	cfg := &config.Config{Storage: config.StorageConfig{MigrationsTable: "schema_migrations"}}
+	log := slogdiscard.NewDiscardLogger()
+
+	require.NoError(t, app.MigrateData(log, cfg, "sqlite:"+from, "sqlite:"+to))
+
+	dst, err := sqlite.New(to, emailaddr.Normalizer{}, storage.PoolConfig{}, sqlite.Pragmas{ForeignKeys: "on"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = dst.Close() })
+
+	user, err := dst.User(ctx, "second@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, userID, int64(user.ID))
+
+	user, err = dst.User(ctx, "third@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, lastID, int64(user.ID))
+
+	_, err = dst.User(ctx, "first@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	copied, err := dst.App(ctx, appID)
+	require.NoError(t, err)
+	assert.Equal(t, "copied", copied.Name)
+
+	// the token still references the user and the app by their ids
+	token, err := dst.RefreshToken(ctx, "token-hash")
+	require.NoError(t, err)
+	assert.Equal(t, lastID, token.UserID)
+	assert.Equal(t, appID, token.AppID)
+
+	// new users go on from the largest id there is
+	newID, err := dst.SaveUser(ctx, "fourth@example.com", []byte("hash"), models.UserProfile{})
+	require.NoError(t, err)
+	assert.Greater(t, newID, lastID)
+
+	// copying again would duplicate the users, so it is refused
+	err = app.MigrateData(log, cfg, "sqlite:"+from, "sqlite:"+to)
+	assert.ErrorIs(t, err, transfer.ErrTargetNotEmpty)
+
+	err = app.MigrateData(log, cfg, "sqlite:"+from, "sqlite:"+from)
+	assert.Error(t, err)
+}
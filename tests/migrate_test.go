@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"sso/internal/app"
+	"sso/internal/config"
+	"sso/internal/lib/emailaddr"
+	"sso/internal/lib/randtoken"
+	"sso/internal/storage"
+	"sso/internal/storage/factory"
+	"sso/internal/storage/schema"
+	"sso/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate_Commands(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "sso.db")
+	cfg := &config.Config{Storage: config.StorageConfig{
+		Driver:          factory.DriverSQLite,
+		DSN:             path,
+		MigrationsTable: "schema_migrations",
+	}}
+	logs := &recordingHandler{attrs: map[string]slog.Value{}}
+	log := slog.New(logs)
+
+	status := func() (uint64, uint64) {
+		t.Helper()
+
+		require.NoError(t, app.Migrate(log, cfg, "status"))
+		assert.False(t, logs.attr("dirty").Bool())
+
+		return logs.attr("version").Uint64(), logs.attr("latest").Uint64()
+	}
+
+	// the migrations come from the binary, not from a directory
+	version, latest := status()
+	assert.Zero(t, version)
+	require.NotZero(t, latest)
+
+	require.NoError(t, app.Migrate(log, cfg, "up"))
+	version, _ = status()
+	assert.Equal(t, latest, version)
+
+	db, err := sqlite.OpenDB(path, sqlite.Pragmas{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	var users int
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM users").Scan(&users))
+
+	// applying them again changes nothing
+	require.NoError(t, app.Migrate(log, cfg, "up"))
+	version, _ = status()
+	assert.Equal(t, latest, version)
+
+	require.NoError(t, app.Migrate(log, cfg, "down"))
+	version, _ = status()
+	assert.Equal(t, latest-1, version)
+
+	require.NoError(t, app.Migrate(log, cfg, "up"))
+	version, _ = status()
+	assert.Equal(t, latest, version)
+
+	assert.Error(t, app.Migrate(log, cfg, "sideways"))
+}
+
+func TestMigrate_DownToEmpty(t *testing.T) {
+	t.Parallel()
+
+	migrator, err := schema.New(factory.Config{Driver: factory.DriverSQLite, DSN: newSQLiteDB(t)}, "")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = migrator.Close() })
+
+	for {
+		rolledBack, err := migrator.Down()
+		require.NoError(t, err)
+		if !rolledBack {
+			break
+		}
+	}
+
+	status, err := migrator.Status()
+	require.NoError(t, err)
+	assert.Zero(t, status.Version)
+
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	assert.True(t, applied)
+
+	status, err = migrator.Status()
+	require.NoError(t, err)
+	assert.Equal(t, status.Latest, status.Version)
+}
+
+func TestMigrate_HashedAppSecrets(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := newSQLiteDB(t)
+
+	db, err := sqlite.OpenDB(path, sqlite.Pragmas{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	res, err := db.Exec("INSERT INTO apps (name, secret) VALUES ('legacy', 'legacy-secret')")
+	require.NoError(t, err)
+	appID, err := res.LastInsertId()
+	require.NoError(t, err)
+
+	st, err := sqlite.New(path, emailaddr.Normalizer{}, storage.PoolConfig{}, sqlite.Pragmas{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = st.Close() })
+
+	_, err = st.HashAppSecrets(ctx)
+	require.NoError(t, err)
+
+	// the plaintext secret no longer signs the tokens of the app
+	legacy, err := st.App(ctx, int(appID))
+	require.NoError(t, err)
+	assert.Equal(t, randtoken.Hash("legacy-secret"), legacy.SecretHash)
+	assert.NotEmpty(t, legacy.SigningKey)
+	assert.NotEqual(t, "legacy-secret", legacy.SigningKey)
+
+	// the plaintext secrets cannot be restored, so going back past the
+	// migration that hashed them fails rather than leaving hashes behind
+	migrator, err := schema.New(factory.Config{Driver: factory.DriverSQLite, DSN: path}, "")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = migrator.Close() })
+
+	rolledBack := true
+	for rolledBack && err == nil {
+		rolledBack, err = migrator.Down()
+	}
+	assert.ErrorContains(t, err, "app_secrets_are_hashed_and_cannot_be_restored")
+
+	var signingKey string
+	require.NoError(t, db.QueryRow("SELECT signing_key FROM apps WHERE id = ?", appID).Scan(&signingKey))
+	assert.Equal(t, legacy.SigningKey, signingKey)
+}
+
+func TestMigrate_MemoryDriver(t *testing.T) {
+	t.Parallel()
+
+	_, err := schema.New(factory.Config{Driver: factory.DriverMemory}, "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,3 @@
+INSERT INTO apps (id, name, secret, scopes, third_party)
+VALUES (7, 'test-third-party', 'test-third-party-secret', 'profile email', 1)
+ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,3 @@
+UPDATE apps
+SET scopes = 'profile email metadata'
+WHERE id = 1;
\ No newline at end of file
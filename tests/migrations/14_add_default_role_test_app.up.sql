@@ -0,0 +1,5 @@
+INSERT INTO apps (id, name, secret)
+VALUES (8, 'test-default-role', 'test-default-role-secret')
+ON CONFLICT DO NOTHING;
+INSERT OR IGNORE INTO roles(name, description, created_at)
+VALUES ('member', 'Assigned to users registering', CURRENT_TIMESTAMP);
\ No newline at end of file
@@ -0,0 +1,12 @@
+UPDATE apps
+SET signing_key = 'test-signing-key'
+WHERE id = 1;
+UPDATE apps
+SET signing_key = 'test-downstream-signing-key'
+WHERE id = 2;
+UPDATE apps
+SET signing_key = 'test-mapping-signing-key'
+WHERE id = 5;
+UPDATE apps
+SET signing_key = 'test-ldap-signing-key'
+WHERE id = 6;
@@ -0,0 +1,3 @@
+UPDATE apps
+SET claims = '{"tenant_id": "test-tenant", "roles": ["reader"]}'
+WHERE id = 1;
\ No newline at end of file
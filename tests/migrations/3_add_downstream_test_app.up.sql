@@ -0,0 +1,3 @@
+INSERT INTO apps (id, name, secret)
+VALUES (2, 'test-downstream', 'test-downstream-secret')
+ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,3 @@
+INSERT INTO apps (id, name, secret, token_format)
+VALUES (3, 'test-paseto', 'test-paseto-secret', 'paseto')
+ON CONFLICT DO NOTHING;
\ No newline at end of file
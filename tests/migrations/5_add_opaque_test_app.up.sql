@@ -0,0 +1,3 @@
+INSERT INTO apps (id, name, secret, token_format)
+VALUES (4, 'test-opaque', 'test-opaque-secret', 'opaque')
+ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,3 @@
+INSERT INTO users (email, pass_hash, is_admin)
+VALUES ('admin@sso.test', '$2a$10$4ZVG0KyE9JZDaZCSyESwvOlP970R66lVmDWcWCAp/ooijgs1ZUQAO', TRUE)
+ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,3 @@
+INSERT INTO apps (id, name, secret, claims)
+VALUES (5, 'test-mapping', 'test-mapping-secret', '{"roles": ["reader"]}')
+ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,3 @@
+INSERT INTO apps (id, name, secret)
+VALUES (6, 'test-ldap', 'test-ldap-secret')
+ON CONFLICT DO NOTHING;
\ No newline at end of file
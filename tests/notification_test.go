@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type notificationPreferencesResponse struct {
+	SecurityEmails bool    `json:"security_emails"`
+	LoginAlerts    bool    `json:"login_alerts"`
+	Marketing      bool    `json:"marketing"`
+	UpdatedAt      *string `json:"updated_at"`
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+	code, login := requestTokenAs(t, st, firefoxUserAgent, loginForm)
+	require.Equal(t, http.StatusOK, code)
+
+	code, body := adminRequest(t, st, login.AccessToken, http.MethodGet, "/notification-preferences", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var prefs notificationPreferencesResponse
+	require.NoError(t, json.Unmarshal(body, &prefs))
+	assert.True(t, prefs.SecurityEmails)
+	assert.False(t, prefs.LoginAlerts)
+	assert.False(t, prefs.Marketing)
+	assert.Nil(t, prefs.UpdatedAt)
+
+	code, body = adminRequest(t, st, login.AccessToken, http.MethodPatch, "/notification-preferences", map[string]any{
+		"login_alerts": true,
+		"marketing":    true,
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	prefs = notificationPreferencesResponse{}
+	require.NoError(t, json.Unmarshal(body, &prefs))
+	assert.True(t, prefs.SecurityEmails)
+	assert.True(t, prefs.LoginAlerts)
+	assert.True(t, prefs.Marketing)
+	assert.NotNil(t, prefs.UpdatedAt)
+
+	// services sending marketing emails check the opt-in
+	admin := adminToken(t, st)
+	code, body = adminRequest(t, st, admin, http.MethodGet,
+		"/admin/users/"+strconv.FormatInt(respReg.GetUserId(), 10)+"/notification-preferences", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	prefs = notificationPreferencesResponse{}
+	require.NoError(t, json.Unmarshal(body, &prefs))
+	assert.True(t, prefs.Marketing)
+
+	// logins from known devices are not alerted
+	sent := sentEmails(t, st, email)
+	code, _ = requestTokenAs(t, st, firefoxUserAgent, loginForm)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, sent, sentEmails(t, st, email))
+
+	code, _ = requestTokenAs(t, st, otherUserAgent, loginForm)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, sent+1, sentEmails(t, st, email))
+
+	// security emails are not sent once turned off, emails the user asks for
+	// are
+	code, _ = adminRequest(t, st, login.AccessToken, http.MethodPatch, "/notification-preferences", map[string]any{
+		"security_emails": false,
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	sent = sentEmails(t, st, email)
+	newEmail := gofakeit.Email()
+	code = bearerPostForm(t, st, login.AccessToken, "/email/change", url.Values{
+		"password":  {pass},
+		"new_email": {newEmail},
+	})
+	require.Equal(t, http.StatusAccepted, code)
+	assert.Equal(t, sent, sentEmails(t, st, email))
+	assert.Equal(t, 1, sentEmails(t, st, newEmail))
+}
+
+func TestNotificationPreferences_FailCases(t *testing.T) {
+	_, st := suite.New(t)
+
+	code, _ := adminRequest(t, st, "", http.MethodGet, "/notification-preferences", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = adminRequest(t, st, "", http.MethodPatch, "/notification-preferences", map[string]any{"marketing": true})
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	admin := adminToken(t, st)
+	code, _ = adminRequest(t, st, admin, http.MethodPatch, "/notification-preferences", "marketing")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = adminRequest(t, st, admin, http.MethodGet, "/admin/users/999999999/notification-preferences", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
@@ -0,0 +1,209 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type orgMembersResponse struct {
+	Members []struct {
+		UserID int64  `json:"user_id"`
+		Email  string `json:"email"`
+		Role   string `json:"role"`
+	} `json:"members"`
+	NextPageToken string `json:"next_page_token"`
+}
+
+func TestOrgMembership_HappyPath(t *testing.T) {
+	_, st := suite.New(t)
+
+	platformAdmin := adminToken(t, st)
+	org := createOrg(t, st, platformAdmin)
+	orgPath := "/admin/orgs/" + strconv.FormatInt(org.ID, 10)
+
+	adminEmail, adminPass, adminID := joinOrg(t, st, platformAdmin, org.ID, "admin")
+
+	// tokens from before joining are revoked, so the new admin logs in again
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {adminEmail},
+		"password":   {adminPass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, float64(org.ID), parseToken(t, login.AccessToken)["org_id"])
+	orgAdmin := login.AccessToken
+
+	_, _, memberID := joinOrg(t, st, orgAdmin, org.ID, "")
+
+	code, body := adminRequest(t, st, orgAdmin, http.MethodGet, orgPath+"/members?page_size=1", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var first orgMembersResponse
+	require.NoError(t, json.Unmarshal(body, &first))
+	require.Len(t, first.Members, 1)
+	require.NotEmpty(t, first.NextPageToken)
+
+	code, body = adminRequest(t, st, orgAdmin, http.MethodGet,
+		orgPath+"/members?page_size=1&page_token="+url.QueryEscape(first.NextPageToken), nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var second orgMembersResponse
+	require.NoError(t, json.Unmarshal(body, &second))
+	require.Len(t, second.Members, 1)
+
+	roles := map[int64]string{
+		first.Members[0].UserID:  first.Members[0].Role,
+		second.Members[0].UserID: second.Members[0].Role,
+	}
+	assert.Equal(t, map[int64]string{adminID: "admin", memberID: "member"}, roles)
+
+	memberPath := orgPath + "/members/" + strconv.FormatInt(memberID, 10)
+	adminPath := orgPath + "/members/" + strconv.FormatInt(adminID, 10)
+
+	code, _ = adminRequest(t, st, orgAdmin, http.MethodPut, memberPath+"/role", map[string]any{"role": "admin"})
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = adminRequest(t, st, orgAdmin, http.MethodPut, memberPath+"/role", map[string]any{"role": "owner"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = adminRequest(t, st, orgAdmin, http.MethodPut, adminPath+"/role", map[string]any{"role": "member"})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = adminRequest(t, st, orgAdmin, http.MethodDelete, adminPath, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = adminRequest(t, st, orgAdmin, http.MethodDelete, memberPath, nil)
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = adminRequest(t, st, orgAdmin, http.MethodDelete, memberPath, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, body = adminRequest(t, st, orgAdmin, http.MethodGet, orgPath+"/members", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var members orgMembersResponse
+	require.NoError(t, json.Unmarshal(body, &members))
+	require.Len(t, members.Members, 1)
+	assert.Equal(t, adminID, members.Members[0].UserID)
+
+	// org admins only manage the members of their org
+	code, _ = adminRequest(t, st, orgAdmin, http.MethodGet, "/admin/orgs/1/members", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	for _, typ := range []string{"org_invitation_accepted", "org_member_role_changed", "org_member_removed"} {
+		code, body = adminRequest(t, st, platformAdmin, http.MethodGet,
+			"/admin/audit-events?type="+typ+"&user_id="+strconv.FormatInt(memberID, 10), nil)
+		require.Equal(t, http.StatusOK, code)
+
+		var events auditEventsResponse
+		require.NoError(t, json.Unmarshal(body, &events))
+		assert.Len(t, events.Events, 1, typ)
+	}
+}
+
+func TestOrgMembership_Invitations(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	platformAdmin := adminToken(t, st)
+	org := createOrg(t, st, platformAdmin)
+	invitationsPath := "/admin/orgs/" + strconv.FormatInt(org.ID, 10) + "/invitations"
+
+	email := gofakeit.Email()
+	code, _ := adminRequest(t, st, platformAdmin, http.MethodPost, invitationsPath, map[string]any{"email": email})
+	require.Equal(t, http.StatusCreated, code)
+
+	token := emailToken(t, st, email)
+	assert.Equal(t, http.StatusNoContent, postForm(t, st, "/org-invitations/decline", url.Values{"token": {token}}))
+	assert.Equal(t, http.StatusBadRequest, postForm(t, st, "/org-invitations/decline", url.Values{"token": {token}}))
+
+	// invitations can only be accepted by the user of the invited email
+	pass := randomFakePassword()
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	code, _ = adminRequest(t, st, platformAdmin, http.MethodPost, invitationsPath, map[string]any{"email": email})
+	require.Equal(t, http.StatusCreated, code)
+	token = emailToken(t, st, email)
+
+	code, _ = acceptOrgInvitation(t, st, platformAdmin, token)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = adminRequest(t, st, platformAdmin, http.MethodPost, invitationsPath, map[string]any{
+		"email": gofakeit.Email(),
+		"role":  "owner",
+	})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = adminRequest(t, st, platformAdmin, http.MethodPost, "/admin/orgs/999999/invitations", map[string]any{
+		"email": gofakeit.Email(),
+	})
+	assert.Equal(t, http.StatusNotFound, code)
+
+	_, _, memberID := joinOrg(t, st, platformAdmin, org.ID, "")
+	code, _ = adminRequest(t, st, platformAdmin, http.MethodDelete,
+		"/admin/orgs/1/members/"+strconv.FormatInt(memberID, 10), nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+// joinOrg registers a user, invites them to the org with the role and has
+// them accept the invitation. It returns the email, password and id of the
+// user.
+func joinOrg(t *testing.T, st *suite.Suite, admin string, orgID int64, role string) (string, string, int64) {
+	t.Helper()
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(context.Background(), &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	path := "/admin/orgs/" + strconv.FormatInt(orgID, 10) + "/invitations"
+	code, _ := adminRequest(t, st, admin, http.MethodPost, path, map[string]any{"email": email, "role": role})
+	require.Equal(t, http.StatusCreated, code)
+
+	token := emailToken(t, st, email)
+
+	code, body := acceptOrgInvitation(t, st, login.AccessToken, token)
+	require.Equal(t, http.StatusOK, code)
+
+	var accepted struct {
+		OrgID int64 `json:"org_id"`
+	}
+	require.NoError(t, json.Unmarshal(body, &accepted))
+	require.Equal(t, orgID, accepted.OrgID)
+
+	// members can not be invited again
+	code, _ = adminRequest(t, st, admin, http.MethodPost, path, map[string]any{"email": email})
+	require.Equal(t, http.StatusConflict, code)
+
+	return email, pass, respReg.GetUserId()
+}
+
+func acceptOrgInvitation(t *testing.T, st *suite.Suite, accessToken string, token string) (int, []byte) {
+	t.Helper()
+
+	resp := bearerPostFormBody(t, st, accessToken, "/org-invitations/accept", url.Values{"token": {token}})
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp.StatusCode, body
+}
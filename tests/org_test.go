@@ -0,0 +1,236 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type orgResponse struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestOrgs_Create(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+	name := randomOrgName()
+
+	code, body := adminRequest(t, st, admin, http.MethodPost, "/admin/orgs", map[string]any{"name": name})
+	require.Equal(t, http.StatusCreated, code)
+
+	var created orgResponse
+	require.NoError(t, json.Unmarshal(body, &created))
+	require.NotZero(t, created.ID)
+	assert.Equal(t, name, created.Name)
+
+	code, _ = adminRequest(t, st, admin, http.MethodPost, "/admin/orgs", map[string]any{"name": name})
+	assert.Equal(t, http.StatusConflict, code)
+
+	code, _ = adminRequest(t, st, admin, http.MethodPost, "/admin/orgs", map[string]any{"name": " "})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body = adminRequest(t, st, admin, http.MethodGet, "/admin/orgs", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var listed struct {
+		Orgs []orgResponse `json:"orgs"`
+	}
+	require.NoError(t, json.Unmarshal(body, &listed))
+	assert.Contains(t, listed.Orgs, created)
+	assert.Contains(t, listed.Orgs, orgResponse{ID: 1, Name: "default"})
+
+	code, _ = adminRequest(t, st, admin, http.MethodGet, "/admin/orgs/999999", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestOrgs_Scoping(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	platformAdmin := adminToken(t, st)
+	org := createOrg(t, st, platformAdmin)
+
+	code, body := adminRequest(t, st, platformAdmin, http.MethodPost, "/admin/apps", map[string]any{
+		"name":   randomAppName(),
+		"org_id": org.ID,
+	})
+	require.Equal(t, http.StatusCreated, code)
+
+	var orgApp appResponse
+	require.NoError(t, json.Unmarshal(body, &orgApp))
+	assert.Equal(t, org.ID, orgApp.OrgID)
+
+	// the admin of the org, invited as a platform admin and moved to the org
+	adminEmail := gofakeit.Email()
+	adminPass := randomFakePassword()
+
+	code, _ = adminRequest(t, st, platformAdmin, http.MethodPost, "/admin/invitations", map[string]any{
+		"email":  adminEmail,
+		"role":   "admin",
+		"app_id": appID,
+	})
+	require.Equal(t, http.StatusCreated, code)
+
+	status, accepted := acceptInvitation(t, st, emailToken(t, st, adminEmail), adminPass)
+	require.Equal(t, http.StatusCreated, status)
+
+	orgAdminID := moveToOrg(t, st, platformAdmin, accepted.UserID, org.ID)
+
+	// tokens from before the move are revoked
+	code, _ = adminRequest(t, st, accepted.AccessToken, http.MethodGet, "/admin/apps", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// apps of the default org are open to every org
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {adminEmail},
+		"password":   {adminPass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, float64(org.ID), parseToken(t, login.AccessToken)["org_id"])
+	orgAdmin := login.AccessToken
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	userPath := "/admin/users/" + strconv.FormatInt(respReg.GetUserId(), 10)
+
+	// users of other orgs can not log in to apps of the org
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(orgApp.ID)},
+	}
+	status, resp := requestToken(t, st, loginForm)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "access_denied", resp.Error)
+
+	// org admins do not see users and apps of other orgs
+	code, _ = adminRequest(t, st, orgAdmin, http.MethodGet, userPath, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = adminRequest(t, st, orgAdmin, http.MethodGet, "/admin/users/"+url.PathEscape(email), nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = adminRequest(t, st, orgAdmin, http.MethodPost, userPath+"/suspend", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = adminRequest(t, st, orgAdmin, http.MethodGet, "/admin/apps/"+strconv.Itoa(appID), nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, body = adminRequest(t, st, orgAdmin, http.MethodGet, "/admin/apps", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var apps struct {
+		Apps []appResponse `json:"apps"`
+	}
+	require.NoError(t, json.Unmarshal(body, &apps))
+	require.Len(t, apps.Apps, 1)
+	assert.Equal(t, orgApp.ID, apps.Apps[0].ID)
+
+	// settings shared by every org are left to platform admins
+	code, _ = adminRequest(t, st, orgAdmin, http.MethodGet, "/admin/roles", nil)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = adminRequest(t, st, orgAdmin, http.MethodPost, "/admin/orgs", map[string]any{"name": randomOrgName()})
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = adminRequest(t, st, orgAdmin, http.MethodPut, userPath+"/org", map[string]any{"org_id": org.ID})
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// apps org admins create belong to their org
+	code, body = adminRequest(t, st, orgAdmin, http.MethodPost, "/admin/apps", map[string]any{
+		"name":   randomAppName(),
+		"org_id": 1,
+	})
+	require.Equal(t, http.StatusCreated, code)
+
+	var created appResponse
+	require.NoError(t, json.Unmarshal(body, &created))
+	assert.Equal(t, org.ID, created.OrgID)
+
+	moveToOrg(t, st, platformAdmin, respReg.GetUserId(), org.ID)
+
+	status, _ = requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, status)
+
+	code, body = adminRequest(t, st, orgAdmin, http.MethodGet, "/admin/users?page_size=100", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var users usersResponse
+	require.NoError(t, json.Unmarshal(body, &users))
+	ids := make([]int64, 0, len(users.Users))
+	for _, user := range users.Users {
+		assert.Equal(t, org.ID, user.OrgID)
+		ids = append(ids, user.ID)
+	}
+	assert.ElementsMatch(t, []int64{orgAdminID, respReg.GetUserId()}, ids)
+
+	code, _ = adminRequest(t, st, orgAdmin, http.MethodGet, userPath, nil)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestOrgs_SetUserOrgInvalid(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	path := "/admin/users/" + strconv.FormatInt(respReg.GetUserId(), 10) + "/org"
+
+	code, _ := adminRequest(t, st, admin, http.MethodPut, path, map[string]any{"org_id": 999999})
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = adminRequest(t, st, admin, http.MethodPut, path, map[string]any{"org_id": 0})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/users/999999/org", map[string]any{"org_id": 1})
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func createOrg(t *testing.T, st *suite.Suite, admin string) orgResponse {
+	t.Helper()
+
+	code, body := adminRequest(t, st, admin, http.MethodPost, "/admin/orgs", map[string]any{"name": randomOrgName()})
+	require.Equal(t, http.StatusCreated, code)
+
+	var org orgResponse
+	require.NoError(t, json.Unmarshal(body, &org))
+
+	return org
+}
+
+// moveToOrg moves the user to the org and returns the id of the user.
+func moveToOrg(t *testing.T, st *suite.Suite, admin string, userID int64, orgID int64) int64 {
+	t.Helper()
+
+	path := "/admin/users/" + strconv.FormatInt(userID, 10) + "/org"
+	code, body := adminRequest(t, st, admin, http.MethodPut, path, map[string]any{"org_id": orgID})
+	require.Equal(t, http.StatusOK, code)
+
+	var user userResponse
+	require.NoError(t, json.Unmarshal(body, &user))
+	require.Equal(t, orgID, user.OrgID)
+
+	return user.ID
+}
+
+func randomOrgName() string {
+	return "org-" + strings.ToLower(gofakeit.LetterN(12))
+}
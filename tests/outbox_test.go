@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutbox_Events(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	stub := startOutboxStub(t, st)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+	userID := respReg.GetUserId()
+
+	var registered outboxEvent
+	require.Eventually(t, func() bool {
+		var ok bool
+		registered, ok = stub.find("user_registered", userID)
+		return ok
+	}, 10*time.Second, 100*time.Millisecond)
+
+	var data struct {
+		UserID  int64  `json:"user_id"`
+		Email   string `json:"email"`
+		IsGuest bool   `json:"is_guest"`
+	}
+	require.NoError(t, json.Unmarshal(registered.Data, &data))
+	assert.Equal(t, userID, data.UserID)
+	assert.Equal(t, email, data.Email)
+	assert.False(t, data.IsGuest)
+	assert.Equal(t, "user_registered", registered.Event)
+
+	code, _ := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	var started outboxEvent
+	require.Eventually(t, func() bool {
+		var ok bool
+		started, ok = stub.find("session_started", userID)
+		return ok
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.Greater(t, started.ID, registered.ID)
+
+	var session struct {
+		SessionID int64 `json:"session_id"`
+		AppID     int64 `json:"app_id"`
+	}
+	require.NoError(t, json.Unmarshal(started.Data, &session))
+	assert.NotZero(t, session.SessionID)
+	assert.Equal(t, int64(appID), session.AppID)
+}
+
+type outboxEvent struct {
+	ID    int64           `json:"id"`
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data"`
+	Event string          `json:"-"`
+}
+
+// outboxStub plays the webhook the outbox events are published to, and
+// keeps the events.
+type outboxStub struct {
+	secret string
+
+	mu     sync.Mutex
+	events []outboxEvent
+}
+
+var (
+	outboxStubOnce sync.Once
+	outboxStubInst *outboxStub
+	outboxStubErr  error
+)
+
+// startOutboxStub starts the stub shared by the tests of the package.
+func startOutboxStub(t *testing.T, st *suite.Suite) *outboxStub {
+	t.Helper()
+
+	outboxStubOnce.Do(func() {
+		webhook := st.Cfg.Outbox.Webhook
+
+		addr, err := url.Parse(webhook.URL)
+		if err != nil {
+			outboxStubErr = err
+			return
+		}
+
+		ln, err := net.Listen("tcp", addr.Host)
+		if err != nil {
+			outboxStubErr = err
+			return
+		}
+
+		stub := &outboxStub{secret: webhook.Secret}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST "+addr.Path, stub.publish)
+		go func() { _ = http.Serve(ln, mux) }()
+
+		outboxStubInst = stub
+	})
+	require.NoError(t, outboxStubErr)
+
+	return outboxStubInst
+}
+
+// find returns the event of the type about the user.
+func (s *outboxStub) find(eventType string, userID int64) (outboxEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, event := range s.events {
+		if event.Type != eventType {
+			continue
+		}
+		var data struct {
+			UserID int64 `json:"user_id"`
+		}
+		if json.Unmarshal(event.Data, &data) == nil && data.UserID == userID {
+			return event, true
+		}
+	}
+
+	return outboxEvent{}, false
+}
+
+func (s *outboxStub) publish(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.secret))
+	mac.Write([]byte(r.Header.Get("X-Webhook-Timestamp") + "."))
+	mac.Write(body)
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(r.Header.Get("X-Webhook-Signature"))) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var event outboxEvent
+	if err = json.Unmarshal(body, &event); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	event.Event = r.Header.Get("X-Webhook-Event")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+	w.WriteHeader(http.StatusNoContent)
+}
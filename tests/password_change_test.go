@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRequirePasswordChange(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+	code, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, code)
+
+	admin := adminToken(t, st)
+	userPath := "/admin/users/" + strconv.FormatInt(respReg.GetUserId(), 10)
+
+	code, _ = adminRequest(t, st, admin, http.MethodPost, userPath+"/require-password-change", nil)
+	require.Equal(t, http.StatusNoContent, code)
+
+	// the sessions of the user end
+	code, _ = requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body := adminRequest(t, st, admin, http.MethodGet, userPath, nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var user struct {
+		PasswordChangeRequired bool `json:"password_change_required"`
+	}
+	require.NoError(t, json.Unmarshal(body, &user))
+	assert.True(t, user.PasswordChangeRequired)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	code, changeRequired := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, "password_change_required", changeRequired.Error)
+	assert.Empty(t, changeRequired.AccessToken)
+	require.NotEmpty(t, changeRequired.PasswordChangeToken)
+
+	// the current password can not be kept
+	resp, err := http.PostForm(st.HTTPURL+"/password/reset/confirm", url.Values{
+		"token":    {changeRequired.PasswordChangeToken},
+		"password": {pass},
+	})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	var weak struct {
+		Error      string `json:"error"`
+		Violations []struct {
+			Code string `json:"code"`
+		} `json:"violations"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&weak))
+	assert.Equal(t, "invalid_password", weak.Error)
+	require.Len(t, weak.Violations, 1)
+	assert.Equal(t, "reused", weak.Violations[0].Code)
+
+	newPass := randomFakePassword()
+	code = postForm(t, st, "/password/reset/confirm", url.Values{
+		"token":    {changeRequired.PasswordChangeToken},
+		"password": {newPass},
+	})
+	require.Equal(t, http.StatusNoContent, code)
+
+	code, _ = requestToken(t, st, loginForm)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	loginForm.Set("password", newPass)
+	code, _ = requestToken(t, st, loginForm)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, body = adminRequest(t, st, admin, http.MethodGet,
+		"/admin/audit-events?user_id="+strconv.FormatInt(respReg.GetUserId(), 10), nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var events auditEventsResponse
+	require.NoError(t, json.Unmarshal(body, &events))
+
+	var types []string
+	for _, event := range events.Events {
+		types = append(types, event.Type)
+	}
+	assert.Contains(t, types, "password_change_forced")
+}
+
+func TestRequirePasswordChange_FailCases(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	tests := []struct {
+		name string
+		path string
+		code int
+	}{
+		{
+			name: "Unknown user",
+			path: "/admin/users/999999999/require-password-change",
+			code: http.StatusNotFound,
+		},
+		{
+			name: "Invalid user id",
+			path: "/admin/users/abc/require-password-change",
+			code: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := adminRequest(t, st, admin, http.MethodPost, tt.path, nil)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+
+	// users must be admins
+	code, _ := adminRequest(t, st, "", http.MethodPost, "/admin/users/1/require-password-change", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
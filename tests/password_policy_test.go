@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPasswordPolicy_Register(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	tests := []struct {
+		name     string
+		password string
+		reasons  []string
+	}{
+		{name: "Too Short", password: "xk3", reasons: []string{"too_short"}},
+		{name: "Common Password", password: "Password1", reasons: []string{"banned"}},
+		{name: "Too Long", password: gofakeit.LetterN(65), reasons: []string{"too_long"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+				Email:    gofakeit.Email(),
+				Password: tt.password,
+			})
+			require.Error(t, err)
+
+			grpcStatus, ok := status.FromError(err)
+			require.True(t, ok)
+			assert.Equal(t, codes.InvalidArgument, grpcStatus.Code())
+			assert.Equal(t, tt.reasons, violationReasons(t, grpcStatus))
+		})
+	}
+}
+
+func TestPasswordPolicy_ChangePassword(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	resp := bearerPostFormBody(t, st, respLogin.GetToken(), "/password/change",
+		url.Values{"old_password": {pass}, "new_password": {"qwerty"}})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	var body struct {
+		Error      string `json:"error"`
+		Violations []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"violations"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "invalid_password", body.Error)
+	require.Len(t, body.Violations, 2)
+	assert.Equal(t, "too_short", body.Violations[0].Code)
+	assert.Equal(t, "banned", body.Violations[1].Code)
+	assert.NotEmpty(t, body.Violations[0].Message)
+}
+
+func TestPasswordPolicy_ResetKeepsToken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	code := postForm(t, st, "/password/reset", url.Values{"email": {email}})
+	require.Equal(t, http.StatusAccepted, code)
+
+	token := emailToken(t, st, email)
+
+	code = postForm(t, st, "/password/reset/confirm", url.Values{"token": {token}, "password": {"123456"}})
+	require.Equal(t, http.StatusBadRequest, code)
+
+	// the rejected password did not use up the link
+	code = postForm(t, st, "/password/reset/confirm", url.Values{"token": {token}, "password": {randomFakePassword()}})
+	assert.Equal(t, http.StatusNoContent, code)
+}
+
+// violationReasons returns the reasons of the field violations in the
+// details of the status.
+func violationReasons(t *testing.T, grpcStatus *status.Status) []string {
+	t.Helper()
+
+	var reasons []string
+	for _, detail := range grpcStatus.Details() {
+		badRequest, ok := detail.(*errdetails.BadRequest)
+		require.True(t, ok)
+
+		for _, violation := range badRequest.GetFieldViolations() {
+			assert.Equal(t, "password", violation.GetField())
+			reasons = append(reasons, violation.GetReason())
+		}
+	}
+
+	return reasons
+}
+
+func bearerPostFormBody(t *testing.T, st *suite.Suite, token string, path string, form url.Values) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, st.HTTPURL+path, strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	return resp
+}
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordReset_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	status := postForm(t, st, "/password/reset", url.Values{"email": {email}})
+	require.Equal(t, http.StatusAccepted, status)
+
+	token := emailToken(t, st, email)
+
+	newPass := randomFakePassword()
+	status = postForm(t, st, "/password/reset/confirm", url.Values{
+		"token":    {token},
+		"password": {newPass},
+	})
+	require.Equal(t, http.StatusNoContent, status)
+
+	// the reset link works only once
+	status = postForm(t, st, "/password/reset/confirm", url.Values{
+		"token":    {token},
+		"password": {randomFakePassword()},
+	})
+	require.Equal(t, http.StatusBadRequest, status)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.Error(t, err)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: newPass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	// sessions from before the reset are revoked
+	status, info := introspect(t, st, respLogin.GetToken(), strconv.Itoa(appID), appSecret)
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, info.Active)
+}
+
+func TestPasswordReset_UnknownEmail(t *testing.T) {
+	_, st := suite.New(t)
+
+	status := postForm(t, st, "/password/reset", url.Values{"email": {gofakeit.Email()}})
+	assert.Equal(t, http.StatusAccepted, status)
+
+	status = postForm(t, st, "/password/reset/confirm", url.Values{
+		"token":    {"unknown-token"},
+		"password": {randomFakePassword()},
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func postForm(t *testing.T, st *suite.Suite, path string, form url.Values) int {
+	t.Helper()
+
+	resp, err := http.PostForm(st.HTTPURL+path, form)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+var emailTokenRe = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// emailToken returns the token from the last email sent to the address.
+// The server must be configured with the file email sender.
+func emailToken(t *testing.T, st *suite.Suite, to string) string {
+	t.Helper()
+
+	dir := st.Cfg.Email.Dir
+	if !filepath.IsAbs(dir) {
+		// the server runs from the repository root
+		dir = filepath.Join("..", dir)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*_"+to+".eml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files, "no email sent to %s", to)
+	sort.Strings(files)
+
+	data, err := os.ReadFile(files[len(files)-1])
+	require.NoError(t, err)
+
+	match := emailTokenRe.FindStringSubmatch(strings.ReplaceAll(string(data), "\r\n", "\n"))
+	require.Len(t, match, 2)
+
+	return match[1]
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/emailaddr"
+	"sso/internal/lib/piibox"
+	"sso/internal/storage"
+	"sso/internal/storage/factory"
+	"sso/internal/storage/pii"
+	"sso/internal/storage/schema"
+	"sso/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSQLiteDB returns the path of a new database with the embedded
+// migrations applied.
+func newSQLiteDB(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "sso.db")
+
+	migrator, err := schema.New(factory.Config{Driver: factory.DriverSQLite, DSN: path}, "")
+	require.NoError(t, err)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+	require.NoError(t, migrator.Close())
+
+	return path
+}
+
+// rawEmail returns the email of the user as the database stores it.
+func rawEmail(t *testing.T, path string, userID int64) string {
+	t.Helper()
+
+	db, err := sqlite.OpenDB(path, sqlite.Pragmas{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	var email string
+	require.NoError(t, db.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&email))
+
+	return email
+}
+
+func newPIIStorage(t *testing.T, path string, box *piibox.Box) *pii.Storage {
+	t.Helper()
+
+	inner, err := sqlite.New(path, pii.NewEmailIndex(box, emailaddr.Normalizer{}), storage.PoolConfig{}, sqlite.Pragmas{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = inner.Close() })
+
+	return pii.New(inner, box)
+}
+
+func TestPII_EncryptedAtRest(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := newSQLiteDB(t)
+
+	box, err := piibox.New("passphrase")
+	require.NoError(t, err)
+	st := newPIIStorage(t, path, box)
+
+	userID, err := st.SaveUser(ctx, "Jane.Doe@Example.com", []byte("hash"), models.UserProfile{})
+	require.NoError(t, err)
+
+	raw := rawEmail(t, path, userID)
+	assert.True(t, piibox.Sealed(raw))
+	assert.NotContains(t, raw, "Doe")
+
+	user, err := st.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "Jane.Doe@Example.com", user.Email)
+
+	// the blind index finds the user by any spelling of the email
+	user, err = st.User(ctx, "jane.doe@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, userID, int64(user.ID))
+	assert.Equal(t, "Jane.Doe@Example.com", user.Email)
+
+	_, err = st.SaveUser(ctx, "JANE.DOE@example.com", []byte("hash"), models.UserProfile{})
+	assert.ErrorIs(t, err, storage.ErrUserExists)
+
+	_, err = st.User(ctx, "john@example.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+}
+
+func TestPII_ListUsersByEmailPrefix(t *testing.T) {
+	t.Parallel()
+
+	box, err := piibox.New("passphrase")
+	require.NoError(t, err)
+	st := newPIIStorage(t, newSQLiteDB(t), box)
+
+	_, err = st.ListUsers(context.Background(), models.UserFilter{EmailPrefix: "jane"})
+	assert.ErrorIs(t, err, storage.ErrFilterUnsupported)
+}
+
+func TestPII_EncryptExistingUsers(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := newSQLiteDB(t)
+
+	// users stored before encryption was turned on
+	plain, err := sqlite.New(path, emailaddr.Normalizer{}, storage.PoolConfig{}, sqlite.Pragmas{})
+	require.NoError(t, err)
+	userID, err := plain.SaveUser(ctx, "jane@example.com", []byte("hash"), models.UserProfile{})
+	require.NoError(t, err)
+	require.NoError(t, plain.Close())
+
+	box, err := piibox.New("passphrase")
+	require.NoError(t, err)
+	st := newPIIStorage(t, path, box)
+
+	// plaintext emails are read as they are, but are not found by their
+	// keys until these are synced
+	user, err := st.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", user.Email)
+
+	conflicts, err := st.SyncEmailKeys(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+
+	sealed, err := st.SealUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sealed)
+	assert.True(t, piibox.Sealed(rawEmail(t, path, userID)))
+
+	user, err = st.User(ctx, "jane@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, userID, int64(user.ID))
+	assert.Equal(t, "jane@example.com", user.Email)
+
+	// both are done once
+	conflicts, err = st.SyncEmailKeys(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+	sealed, err = st.SealUsers(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, sealed)
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"sso/internal/lib/piibox"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPIIBox_SealOpen(t *testing.T) {
+	t.Parallel()
+
+	box, err := piibox.New("passphrase")
+	require.NoError(t, err)
+
+	sealed := box.Seal("user@example.com")
+	assert.True(t, piibox.Sealed(sealed))
+	assert.NotContains(t, sealed, "user@example.com")
+
+	// sealing is deterministic, and does not seal twice
+	assert.Equal(t, sealed, box.Seal("user@example.com"))
+	assert.Equal(t, sealed, box.Seal(sealed))
+	assert.NotEqual(t, sealed, box.Seal("other@example.com"))
+	assert.Empty(t, box.Seal(""))
+
+	plain, err := box.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "user@example.com", plain)
+
+	// values stored before encryption was turned on are read as they are
+	plain, err = box.Open("user@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "user@example.com", plain)
+}
+
+func TestPIIBox_InvalidCiphertext(t *testing.T) {
+	t.Parallel()
+
+	box, err := piibox.New("passphrase")
+	require.NoError(t, err)
+	other, err := piibox.New("other passphrase")
+	require.NoError(t, err)
+
+	sealed := box.Seal("user@example.com")
+
+	_, err = other.Open(sealed)
+	assert.ErrorIs(t, err, piibox.ErrInvalidCiphertext)
+
+	last := "A"
+	if strings.HasSuffix(sealed, last) {
+		last = "B"
+	}
+	_, err = box.Open(sealed[:len(sealed)-1] + last)
+	assert.ErrorIs(t, err, piibox.ErrInvalidCiphertext)
+
+	_, err = box.Open("pii1:!")
+	assert.ErrorIs(t, err, piibox.ErrInvalidCiphertext)
+
+	_, err = piibox.New("")
+	assert.Error(t, err)
+}
+
+func TestPIIBox_Index(t *testing.T) {
+	t.Parallel()
+
+	box, err := piibox.New("passphrase")
+	require.NoError(t, err)
+	other, err := piibox.New("other passphrase")
+	require.NoError(t, err)
+
+	index := box.Index("user@example.com")
+	assert.Equal(t, index, box.Index("user@example.com"))
+	assert.NotEqual(t, index, box.Index("other@example.com"))
+	assert.NotEqual(t, index, other.Index("user@example.com"))
+	assert.NotContains(t, index, "user")
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type policyRuleResponse struct {
+	ID     int64    `json:"id"`
+	Type   string   `json:"type"`
+	Values []string `json:"values"`
+}
+
+func TestPolicyRules_Owner(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	userID := strconv.FormatInt(user.GetUserId(), 10)
+	permission := randomRoleName() + ":edit"
+
+	// owners edit their own resources
+	status, body := adminRequest(t, st, admin, http.MethodPost, "/admin/policy-rules", map[string]any{
+		"type":   "p",
+		"values": []string{"r.sub.ID == r.obj.OwnerID", strconv.Itoa(appID), permission},
+	})
+	require.Equal(t, http.StatusCreated, status)
+
+	var rule policyRuleResponse
+	require.NoError(t, json.Unmarshal(body, &rule))
+	assert.Equal(t, "p", rule.Type)
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, "/admin/policy-rules", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), `"id":`+strconv.FormatInt(rule.ID, 10)+`,`)
+
+	status, allowed := checkResourcePermission(t, st, userID, permission, userID)
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, allowed)
+
+	status, allowed = checkResourcePermission(t, st, userID, permission, "999999999")
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, allowed)
+
+	status, allowed = checkPermission(t, st, strconv.Itoa(appID), appSecret, userID, permission)
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, allowed)
+
+	status, _ = adminRequest(t, st, admin, http.MethodDelete, "/admin/policy-rules/"+strconv.FormatInt(rule.ID, 10), nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, allowed = checkResourcePermission(t, st, userID, permission, userID)
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, allowed)
+
+	status, _ = adminRequest(t, st, admin, http.MethodDelete, "/admin/policy-rules/"+strconv.FormatInt(rule.ID, 10), nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestPolicyRules_Invalid(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	tests := []struct {
+		name   string
+		typ    string
+		values []string
+	}{
+		{name: "no values", typ: "p"},
+		{name: "too many values", typ: "p", values: []string{"1", "2", "3", "4", "5", "6", "7"}},
+		{name: "empty value", typ: "p", values: []string{"true", "", "invoices:read"}},
+		{name: "invalid type", typ: "P 1", values: []string{"true", "*", "invoices:read"}},
+		{name: "type not in model", typ: "g", values: []string{"alice", "admin"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _ := adminRequest(t, st, admin, http.MethodPost, "/admin/policy-rules", map[string]any{
+				"type":   tt.typ,
+				"values": tt.values,
+			})
+			assert.Equal(t, http.StatusBadRequest, status)
+		})
+	}
+}
+
+// checkResourcePermission asks whether the user holds the permission on a
+// resource of the owner as the test app.
+func checkResourcePermission(t *testing.T, st *suite.Suite, userID, permission, ownerID string) (int, bool) {
+	t.Helper()
+
+	form := url.Values{
+		"user_id":           {userID},
+		"permission":        {permission},
+		"resource_type":     {"document"},
+		"resource_id":       {gofakeit.UUID()},
+		"resource_owner_id": {ownerID},
+	}
+
+	return postPermissionCheck(t, st, strconv.Itoa(appID), appSecret, form)
+}
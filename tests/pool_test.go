@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"sso/internal/lib/emailaddr"
+	"sso/internal/lib/metrics"
+	"sso/internal/storage"
+	"sso/internal/storage/sqlite"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool_LimitsAndMetrics(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	pool := storage.PoolConfig{MaxOpenConns: 2, MaxIdleConns: 1, ConnMaxIdleTime: time.Hour}
+	st, err := sqlite.New(newSQLiteDB(t), emailaddr.Normalizer{}, pool, sqlite.Pragmas{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = st.Close() })
+
+	assert.Equal(t, 2, st.Stats().MaxOpenConnections)
+
+	// a third lookup waits for one of the two connections held
+	db, err := sqlite.OpenDB(newSQLiteDB(t), sqlite.Pragmas{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	pool.Apply(db)
+
+	first, err := db.Conn(ctx)
+	require.NoError(t, err)
+	second, err := db.Conn(ctx)
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = first.Close()
+	}()
+	third, err := db.Conn(ctx)
+	require.NoError(t, err)
+	require.NoError(t, third.Close())
+	require.NoError(t, second.Close())
+
+	stats := db.Stats()
+	assert.Equal(t, int64(1), stats.WaitCount)
+	// only one of the two connections is kept idle
+	assert.Equal(t, 1, stats.Idle)
+	assert.Equal(t, int64(1), stats.MaxIdleClosed)
+
+	collector := metrics.NewDBStatsCollector(db, "primary")
+	expected := `
+# HELP sso_storage_idle_connections Number of idle connections.
+# TYPE sso_storage_idle_connections gauge
+sso_storage_idle_connections{db="primary"} 1
+# HELP sso_storage_in_use_connections Number of connections in use.
+# TYPE sso_storage_in_use_connections gauge
+sso_storage_in_use_connections{db="primary"} 0
+# HELP sso_storage_max_idle_closed_total Total number of connections closed due to the idle limit.
+# TYPE sso_storage_max_idle_closed_total counter
+sso_storage_max_idle_closed_total{db="primary"} 1
+# HELP sso_storage_max_open_connections Maximum number of open connections to the database.
+# TYPE sso_storage_max_open_connections gauge
+sso_storage_max_open_connections{db="primary"} 2
+# HELP sso_storage_wait_count_total Total number of waits for a connection.
+# TYPE sso_storage_wait_count_total counter
+sso_storage_wait_count_total{db="primary"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"sso_storage_idle_connections",
+		"sso_storage_in_use_connections",
+		"sso_storage_max_idle_closed_total",
+		"sso_storage_max_open_connections",
+		"sso_storage_wait_count_total",
+	))
+	assert.Equal(t, 9, testutil.CollectAndCount(collector))
+}
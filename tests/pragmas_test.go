@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/emailaddr"
+	"sso/internal/storage"
+	"sso/internal/storage/sqlite"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// localPragmas are the pragmas of config/local.yml.
+var localPragmas = sqlite.Pragmas{
+	JournalMode: "wal",
+	Synchronous: "normal",
+	BusyTimeout: 5 * time.Second,
+	ForeignKeys: "on",
+	TxLock:      "immediate",
+}
+
+// pragma returns the value of the pragma on a connection of the db.
+func pragma(t *testing.T, conn *sql.Conn, name string) string {
+	t.Helper()
+
+	var value string
+	require.NoError(t, conn.QueryRowContext(context.Background(), "PRAGMA "+name).Scan(&value))
+
+	return value
+}
+
+func TestPragmas_EveryConnection(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	db, err := sqlite.OpenDB(newSQLiteDB(t), localPragmas)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	// held at once, so that they are distinct connections
+	conns := make([]*sql.Conn, 3)
+	for i := range conns {
+		conns[i], err = db.Conn(ctx)
+		require.NoError(t, err)
+	}
+
+	for _, conn := range conns {
+		assert.Equal(t, "wal", pragma(t, conn, "journal_mode"))
+		assert.Equal(t, "1", pragma(t, conn, "synchronous"))
+		assert.Equal(t, "5000", pragma(t, conn, "busy_timeout"))
+		assert.Equal(t, "1", pragma(t, conn, "foreign_keys"))
+		require.NoError(t, conn.Close())
+	}
+}
+
+func TestPragmas_DSNTakesPrecedence(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite.OpenDB(newSQLiteDB(t)+"?_busy_timeout=1000&_foreign_keys=off", localPragmas)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(t, "1000", pragma(t, conn, "busy_timeout"))
+	assert.Equal(t, "0", pragma(t, conn, "foreign_keys"))
+	assert.Equal(t, "wal", pragma(t, conn, "journal_mode"))
+}
+
+func TestPragmas_ForeignKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	st, err := sqlite.New(newSQLiteDB(t), emailaddr.Normalizer{}, storage.PoolConfig{}, localPragmas)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = st.Close() })
+
+	now := time.Now()
+	err = st.SaveRefreshToken(ctx, models.RefreshToken{
+		TokenHash:        "orphan",
+		FamilyID:         "family",
+		UserID:           404,
+		AppID:            1,
+		ExpiresAt:        now.Add(time.Hour),
+		CreatedAt:        now,
+		SessionStartedAt: now,
+		AuthTime:         now,
+	})
+	assert.ErrorContains(t, err, "FOREIGN KEY constraint failed")
+}
+
+func TestPragmas_ConcurrentWrites(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	db, err := sqlite.OpenDB(newSQLiteDB(t), localPragmas)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	// transactions which read before they write fail with database is
+	// locked when both read first, unless they take the write lock as they
+	// begin and the busy timeout lets them wait for each other
+	readThenWrite := func(delay time.Duration) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		var n int
+		if err = tx.QueryRowContext(ctx, "SELECT count(*) FROM users").Scan(&n); err != nil {
+			return err
+		}
+		time.Sleep(delay)
+		_, err = tx.ExecContext(ctx, "INSERT INTO users(email, pass_hash) VALUES (?, ?)", gofakeit.Email(), []byte("hash"))
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- readThenWrite(100 * time.Millisecond) }()
+	time.Sleep(20 * time.Millisecond)
+	go func() { errs <- readThenWrite(0) }()
+
+	assert.NoError(t, <-errs)
+	assert.NoError(t, <-errs)
+}
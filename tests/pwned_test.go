@@ -0,0 +1,257 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"sso/internal/lib/pwned"
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// bloomPassword is in tests/testdata/pwned.bloom.
+const bloomPassword = "offline-breached-pass"
+
+func TestPwned_Register(t *testing.T) {
+	ctx, st := suite.New(t)
+	stub := startPwnedStub(t, st)
+
+	breached := pwnedTestPassword()
+	stub.breach(breached)
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: breached,
+	})
+	require.Error(t, err)
+
+	grpcStatus, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, grpcStatus.Code())
+	assert.Equal(t, []string{"breached"}, violationReasons(t, grpcStatus))
+
+	// padding entries of the range api do not count as breaches
+	padded := pwnedTestPassword()
+	stub.pad(padded)
+
+	_, err = st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: padded,
+	})
+	require.NoError(t, err)
+}
+
+func TestPwned_BloomFallback(t *testing.T) {
+	ctx, st := suite.New(t)
+	stub := startPwnedStub(t, st)
+
+	stub.fail(bloomPassword)
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: bloomPassword,
+	})
+	require.Error(t, err)
+
+	grpcStatus, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, grpcStatus.Code())
+	assert.Equal(t, []string{"breached"}, violationReasons(t, grpcStatus))
+
+	// passwords missing from the filter are accepted while the api is down
+	pass := pwnedTestPassword()
+	stub.fail(pass)
+
+	_, err = st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: pass,
+	})
+	require.NoError(t, err)
+}
+
+func TestPwned_ChangePassword(t *testing.T) {
+	ctx, st := suite.New(t)
+	stub := startPwnedStub(t, st)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	breached := pwnedTestPassword()
+	stub.breach(breached)
+
+	resp := bearerPostFormBody(t, st, respLogin.GetToken(), "/password/change",
+		url.Values{"old_password": {pass}, "new_password": {breached}})
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	var body struct {
+		Error      string `json:"error"`
+		Violations []struct {
+			Code string `json:"code"`
+		} `json:"violations"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "invalid_password", body.Error)
+	require.Len(t, body.Violations, 1)
+	assert.Equal(t, "breached", body.Violations[0].Code)
+}
+
+// pwnedTestPassword returns a password the stub can be told about without
+// affecting the passwords other tests, which run in parallel, register.
+func TestPwned_BloomFilterFile(t *testing.T) {
+	t.Parallel()
+
+	filter, err := pwned.LoadBloomFilter("testdata/pwned.bloom")
+	require.NoError(t, err)
+	assert.True(t, filter.Contains(sha1.Sum([]byte(bloomPassword))))
+
+	built := pwned.NewBloomFilter(100, 0.001)
+	built.Add(sha1.Sum([]byte(bloomPassword)))
+
+	var buf bytes.Buffer
+	_, err = built.WriteTo(&buf)
+	require.NoError(t, err)
+	data := buf.Bytes()
+
+	read, err := pwned.ReadBloomFilter(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.True(t, read.Contains(sha1.Sum([]byte(bloomPassword))))
+
+	// filters of the layout before enhanced double hashing would miss
+	// breached passwords, so they are refused
+	legacy := append([]byte("SSOBLOOM"), data[8:]...)
+	_, err = pwned.ReadBloomFilter(bytes.NewReader(legacy))
+	assert.ErrorIs(t, err, pwned.ErrInvalidBloomFilter)
+
+	_, err = pwned.ReadBloomFilter(bytes.NewReader(data[:len(data)-1]))
+	assert.ErrorIs(t, err, pwned.ErrInvalidBloomFilter)
+}
+
+func pwnedTestPassword() string {
+	return "pwned-" + randomFakePassword()
+}
+
+// pwnedStub plays the Pwned Passwords range api of the config.
+type pwnedStub struct {
+	mu          sync.Mutex
+	suffixes    map[string][]string
+	unavailable map[string]bool
+}
+
+var (
+	pwnedStubOnce sync.Once
+	pwnedStubInst *pwnedStub
+	pwnedStubErr  error
+)
+
+// startPwnedStub starts the stub shared by the tests of the package.
+func startPwnedStub(t *testing.T, st *suite.Suite) *pwnedStub {
+	t.Helper()
+
+	pwnedStubOnce.Do(func() {
+		addr, err := url.Parse(st.Cfg.PwnedPasswords.HIBPURL)
+		if err != nil {
+			pwnedStubErr = err
+			return
+		}
+
+		ln, err := net.Listen("tcp", addr.Host)
+		if err != nil {
+			pwnedStubErr = err
+			return
+		}
+
+		stub := &pwnedStub{
+			suffixes:    make(map[string][]string),
+			unavailable: make(map[string]bool),
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /range/{prefix}", stub.rangeSearch)
+		go func() { _ = http.Serve(ln, mux) }()
+
+		pwnedStubInst = stub
+	})
+	require.NoError(t, pwnedStubErr)
+
+	return pwnedStubInst
+}
+
+// breach makes the api report the password as breached.
+func (s *pwnedStub) breach(password string) {
+	s.add(password, 3)
+}
+
+// pad makes the api return the password as a padding entry.
+func (s *pwnedStub) pad(password string) {
+	s.add(password, 0)
+}
+
+// fail makes the api fail for the range of the password.
+func (s *pwnedStub) fail(password string) {
+	prefix, _ := pwnedHash(password)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unavailable[prefix] = true
+}
+
+func (s *pwnedStub) add(password string, count int) {
+	prefix, suffix := pwnedHash(password)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.suffixes[prefix] = append(s.suffixes[prefix], fmt.Sprintf("%s:%d", suffix, count))
+}
+
+func (s *pwnedStub) rangeSearch(w http.ResponseWriter, r *http.Request) {
+	prefix := r.PathValue("prefix")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.unavailable[prefix] {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	lines := append([]string{strings.Repeat("0", 35) + ":0"}, s.suffixes[prefix]...)
+	_, _ = w.Write([]byte(strings.Join(lines, "\r\n")))
+}
+
+func pwnedHash(password string) (string, string) {
+	hash := sha1.Sum([]byte(password))
+	hexHash := strings.ToUpper(hex.EncodeToString(hash[:]))
+
+	return hexHash[:5], hexHash[5:]
+}
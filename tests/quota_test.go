@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	authgrpc "sso/internal/grpc/auth"
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type appUsageResponse struct {
+	AppID  int `json:"app_id"`
+	Logins struct {
+		Used  int `json:"used"`
+		Limit int `json:"limit"`
+	} `json:"logins"`
+	Registrations struct {
+		Used  int `json:"used"`
+		Limit int `json:"limit"`
+	} `json:"registrations"`
+}
+
+func TestQuotas_Logins(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	admin := adminToken(t, st)
+	app := createQuotaApp(t, st, admin, map[string]any{"login_quota": 2})
+	assert.Equal(t, 2, app.LoginQuota)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	waitForFreshMinute()
+
+	login := &ssov1.LoginRequest{Email: email, Password: pass, AppId: int32(app.ID)}
+	for range 2 {
+		_, err = st.AuthClient.Login(ctx, login)
+		require.NoError(t, err)
+	}
+
+	var header metadata.MD
+	_, err = st.AuthClient.Login(ctx, login, grpc.Header(&header))
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	retryAfter := header.Get(authgrpc.RetryAfterMetadataKey)
+	require.Len(t, retryAfter, 1)
+	seconds, err := strconv.Atoi(retryAfter[0])
+	require.NoError(t, err)
+	assert.Positive(t, seconds)
+	assert.LessOrEqual(t, seconds, 60)
+
+	// other apps are not limited
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: pass, AppId: appID})
+	require.NoError(t, err)
+
+	usage := appUsage(t, st, admin, app.ID)
+	assert.Equal(t, 3, usage.Logins.Used)
+	assert.Equal(t, 2, usage.Logins.Limit)
+	assert.Equal(t, 0, usage.Registrations.Limit)
+}
+
+func TestQuotas_Registrations(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	admin := adminToken(t, st)
+	app := createQuotaApp(t, st, admin, map[string]any{"registration_quota": 1})
+
+	appCtx := metadata.AppendToOutgoingContext(ctx, authgrpc.AppIDMetadataKey, strconv.Itoa(app.ID))
+
+	_, err := st.AuthClient.Register(appCtx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.Register(appCtx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// registrations naming no app are not counted
+	_, err = st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	usage := appUsage(t, st, admin, app.ID)
+	assert.Equal(t, app.ID, usage.AppID)
+	assert.Equal(t, 2, usage.Registrations.Used)
+	assert.Equal(t, 1, usage.Registrations.Limit)
+}
+
+func TestQuotas_Invalid(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	code, _ := adminRequest(t, st, admin, http.MethodPost, "/admin/apps", map[string]any{
+		"name":        randomAppName(),
+		"login_quota": -1,
+	})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = adminRequest(t, st, admin, http.MethodGet, "/admin/apps/999999/usage", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func createQuotaApp(t *testing.T, st *suite.Suite, admin string, quotas map[string]any) appResponse {
+	t.Helper()
+
+	quotas["name"] = randomAppName()
+	code, body := adminRequest(t, st, admin, http.MethodPost, "/admin/apps", quotas)
+	require.Equal(t, http.StatusCreated, code)
+
+	var app appResponse
+	require.NoError(t, json.Unmarshal(body, &app))
+
+	return app
+}
+
+func appUsage(t *testing.T, st *suite.Suite, admin string, id int) appUsageResponse {
+	t.Helper()
+
+	code, body := adminRequest(t, st, admin, http.MethodGet, "/admin/apps/"+strconv.Itoa(id)+"/usage", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var usage appUsageResponse
+	require.NoError(t, json.Unmarshal(body, &usage))
+
+	return usage
+}
+
+// waitForFreshMinute waits for the next minute if the current one is about
+// to end, so that the logins of a test are counted in the same window.
+func waitForFreshMinute() {
+	if left := time.Until(time.Now().Truncate(time.Minute).Add(time.Minute)); left < 5*time.Second {
+		time.Sleep(left)
+	}
+}
@@ -0,0 +1,541 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roleResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	Inherits    []string `json:"inherits"`
+}
+
+type rolesResponse struct {
+	Roles []roleResponse `json:"roles"`
+}
+
+func TestRoles_CRUD(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+	name := randomRoleName()
+
+	status, body := adminRequest(t, st, admin, http.MethodPut, "/admin/roles/"+name, map[string]any{
+		"description": "Reads invoices",
+		"permissions": []string{"invoices:read", "invoices:export", "invoices:read"},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	var created roleResponse
+	require.NoError(t, json.Unmarshal(body, &created))
+	assert.Equal(t, name, created.Name)
+	assert.Equal(t, "Reads invoices", created.Description)
+	assert.Equal(t, []string{"invoices:export", "invoices:read"}, created.Permissions)
+
+	status, body = adminRequest(t, st, admin, http.MethodPut, "/admin/roles/"+name, map[string]any{
+		"description": "Writes invoices",
+		"permissions": []string{"invoices:write"},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, "/admin/roles/"+name, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var updated roleResponse
+	require.NoError(t, json.Unmarshal(body, &updated))
+	assert.Equal(t, "Writes invoices", updated.Description)
+	assert.Equal(t, []string{"invoices:write"}, updated.Permissions)
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, "/admin/roles", nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var listed rolesResponse
+	require.NoError(t, json.Unmarshal(body, &listed))
+	assert.Contains(t, listed.Roles, updated)
+
+	status, _ = adminRequest(t, st, admin, http.MethodDelete, "/admin/roles/"+name, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodGet, "/admin/roles/"+name, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodDelete, "/admin/roles/"+name, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestRoles_Invalid(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	tests := []struct {
+		name        string
+		role        string
+		permissions []string
+	}{
+		{name: "reserved name", role: "admin"},
+		{name: "uppercase name", role: "Billing"},
+		{name: "invalid permission", role: randomRoleName(), permissions: []string{"invoices read"}},
+		{name: "empty permission", role: randomRoleName(), permissions: []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _ := adminRequest(t, st, admin, http.MethodPut, "/admin/roles/"+tt.role, map[string]any{
+				"permissions": tt.permissions,
+			})
+			assert.Equal(t, http.StatusBadRequest, status)
+		})
+	}
+}
+
+func TestUserRoles_Admin(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+	adminPath := "/admin/users/" + strconv.FormatInt(int64(parseToken(t, admin)["uid"].(float64)), 10)
+
+	status, body := adminRequest(t, st, admin, http.MethodGet, adminPath+"/roles", nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var roles rolesResponse
+	require.NoError(t, json.Unmarshal(body, &roles))
+	require.NotEmpty(t, roles.Roles)
+	assert.Equal(t, "admin", roles.Roles[0].Name)
+
+	// admins hold every permission, stored or not
+	status, body = adminRequest(t, st, admin, http.MethodGet, adminPath+"/permissions/"+randomRoleName()+":read", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"allowed":true}`, string(body))
+
+	// and administer every app
+	appQuery := "?app_id=" + strconv.Itoa(appID)
+	status, body = adminRequest(t, st, admin, http.MethodGet, adminPath+"/roles"+appQuery, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	roles = rolesResponse{}
+	require.NoError(t, json.Unmarshal(body, &roles))
+	require.NotEmpty(t, roles.Roles)
+	assert.Equal(t, "admin", roles.Roles[0].Name)
+
+	status, _ = adminRequest(t, st, admin, http.MethodGet, adminPath+"/roles?app_id=999999", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodGet, adminPath+"/roles?app_id=first", nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, tokens := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {adminEmail},
+		"password":   {adminPassword},
+		"client_id":  {strconv.Itoa(appID)},
+		"scope":      {"roles"},
+	})
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, parseToken(t, tokens.AccessToken)["roles"], "admin")
+
+	// the admin role is built in
+	status, _ = adminRequest(t, st, admin, http.MethodDelete, "/admin/roles/admin", nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestUserRoles_RegularUser(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	userPath := "/admin/users/" + strconv.FormatInt(user.GetUserId(), 10)
+
+	status, body := adminRequest(t, st, admin, http.MethodGet, userPath+"/roles", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"roles":[]}`, string(body))
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, userPath+"/permissions/invoices:read", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"allowed":false}`, string(body))
+
+	status, _ = adminRequest(t, st, admin, http.MethodGet, userPath+"/permissions/Invoices", nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodGet, "/admin/users/999999999/roles", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestAssignRole(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	adminID := int64(parseToken(t, admin)["uid"].(float64))
+	role := randomRoleName()
+	userID := strconv.FormatInt(user.GetUserId(), 10)
+	rolePath := "/admin/users/" + userID + "/roles/" + role
+	appQuery := "?app_id=" + strconv.Itoa(appID)
+
+	status, _ := adminRequest(t, st, admin, http.MethodPut, "/admin/roles/"+role, map[string]any{
+		"permissions": []string{"invoices:read"},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	// granted within the test app only
+	status, _ = adminRequest(t, st, admin, http.MethodPut, rolePath+appQuery, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, body := adminRequest(t, st, admin, http.MethodGet, "/admin/users/"+userID+"/permissions/invoices:read"+appQuery, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"allowed":true}`, string(body))
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, "/admin/users/"+userID+"/permissions/invoices:read", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"allowed":false}`, string(body))
+
+	status, body = adminRequest(t, st, admin, http.MethodGet,
+		"/admin/audit-events?type=role_assigned&user_id="+userID, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var events auditEventsResponse
+	require.NoError(t, json.Unmarshal(body, &events))
+	require.Len(t, events.Events, 1)
+	assert.Equal(t, adminID, events.Events[0].ActorID)
+	assert.Equal(t, appID, events.Events[0].AppID)
+	assert.Equal(t, role, events.Events[0].Detail)
+
+	status, _ = adminRequest(t, st, admin, http.MethodDelete, rolePath+appQuery, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, "/admin/users/"+userID+"/roles"+appQuery, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"roles":[]}`, string(body))
+
+	status, body = adminRequest(t, st, admin, http.MethodGet,
+		"/admin/audit-events?type=role_revoked&user_id="+userID, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	events = auditEventsResponse{}
+	require.NoError(t, json.Unmarshal(body, &events))
+	require.Len(t, events.Events, 1)
+	assert.Equal(t, adminID, events.Events[0].ActorID)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/users/"+userID+"/roles/"+randomRoleName(), nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/users/999999999/roles/"+role, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// global admins are made by the is_admin flag
+	status, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/users/"+userID+"/roles/admin", nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// global roles apply within every app
+	status, _ = adminRequest(t, st, admin, http.MethodPut, rolePath, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, "/admin/users/"+userID+"/permissions/invoices:read"+appQuery, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"allowed":true}`, string(body))
+}
+
+func TestAssignRole_AppAdmin(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+	user, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	userID := strconv.FormatInt(user.GetUserId(), 10)
+
+	status, _ := adminRequest(t, st, admin, http.MethodPut,
+		"/admin/users/"+userID+"/roles/admin?app_id="+strconv.Itoa(appID), nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	// admins of the app hold every permission within it and no other
+	status, allowed := checkPermission(t, st, strconv.Itoa(appID), appSecret, userID, "invoices:delete")
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, allowed)
+
+	status, body := adminRequest(t, st, admin, http.MethodGet, "/admin/users/"+userID+"/permissions/invoices:delete", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"allowed":false}`, string(body))
+
+	status, tokens := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+		"scope":      {"roles"},
+	})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []any{"admin"}, parseToken(t, tokens.AccessToken)["roles"])
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/users/"+userID+"/roles/admin?app_id=999999", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestRoles_Inheritance(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	admin := adminToken(t, st)
+	support, manager := randomRoleName(), randomRoleName()
+
+	status, _ := adminRequest(t, st, admin, http.MethodPut, "/admin/roles/"+support, map[string]any{
+		"permissions": []string{"tickets:read"},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	status, body := adminRequest(t, st, admin, http.MethodPut, "/admin/roles/"+manager, map[string]any{
+		"permissions": []string{"tickets:assign"},
+		"inherits":    []string{support},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	var created roleResponse
+	require.NoError(t, json.Unmarshal(body, &created))
+	assert.Equal(t, []string{support}, created.Inherits)
+
+	user, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+	userID := strconv.FormatInt(user.GetUserId(), 10)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/users/"+userID+"/roles/"+manager, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, allowed := checkPermission(t, st, strconv.Itoa(appID), appSecret, userID, "tickets:read")
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, allowed)
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, "/admin/users/"+userID+"/roles", nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var roles rolesResponse
+	require.NoError(t, json.Unmarshal(body, &roles))
+	var names []string
+	for _, role := range roles.Roles {
+		names = append(names, role.Name)
+	}
+	assert.ElementsMatch(t, []string{support, manager}, names)
+
+	// support inheriting manager back would make a cycle
+	status, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/roles/"+support, map[string]any{
+		"permissions": []string{"tickets:read"},
+		"inherits":    []string{manager},
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/roles/"+support, map[string]any{
+		"inherits": []string{randomRoleName()},
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodDelete, "/admin/roles/"+support, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, allowed = checkPermission(t, st, strconv.Itoa(appID), appSecret, userID, "tickets:read")
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, allowed)
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, "/admin/roles/"+manager, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var updated roleResponse
+	require.NoError(t, json.Unmarshal(body, &updated))
+	assert.Empty(t, updated.Inherits)
+}
+
+func TestDefaultRoles(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	path := "/admin/users/" + strconv.FormatInt(user.GetUserId(), 10) + "/roles"
+
+	// the config assigns member within the default role test app
+	status, body := adminRequest(t, st, admin, http.MethodGet, path+"?app_id=8", nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var roles rolesResponse
+	require.NoError(t, json.Unmarshal(body, &roles))
+	require.Len(t, roles.Roles, 1)
+	assert.Equal(t, "member", roles.Roles[0].Name)
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, path+"?app_id="+strconv.Itoa(appID), nil)
+	require.Equal(t, http.StatusOK, status)
+
+	roles = rolesResponse{}
+	require.NoError(t, json.Unmarshal(body, &roles))
+	assert.Empty(t, roles.Roles)
+}
+
+func TestCheckPermission_Conditions(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	userID := strconv.FormatInt(user.GetUserId(), 10)
+	name := randomRoleName()
+
+	status, body := adminRequest(t, st, admin, http.MethodPut, "/admin/roles/"+name, map[string]any{
+		"permissions": []string{"orgs:manage", "tickets:close"},
+		"conditions": map[string]string{
+			"orgs:manage":   "attrs.org == user.org",
+			"tickets:close": "resource.owner_id == user.id",
+		},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	var created struct {
+		Conditions map[string]string `json:"conditions"`
+	}
+	require.NoError(t, json.Unmarshal(body, &created))
+	assert.Equal(t, "attrs.org == user.org", created.Conditions["orgs:manage"])
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/users/"+userID+"/roles/"+name, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, _ = updateUser(t, st, admin, user.GetUserId(), "", map[string]any{
+		"update_mask":  "app_metadata",
+		"app_metadata": map[string]string{"org": "acme"},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	check := func(permission string, values url.Values) bool {
+		t.Helper()
+
+		values.Set("user_id", userID)
+		values.Set("permission", permission)
+		status, allowed := postPermissionCheck(t, st, strconv.Itoa(appID), appSecret, values)
+		require.Equal(t, http.StatusOK, status)
+
+		return allowed
+	}
+
+	assert.True(t, check("orgs:manage", url.Values{"attrs.org": {"acme"}}))
+	assert.False(t, check("orgs:manage", url.Values{"attrs.org": {"globex"}}))
+	assert.False(t, check("orgs:manage", url.Values{}))
+
+	assert.True(t, check("tickets:close", url.Values{"resource_owner_id": {userID}}))
+	assert.False(t, check("tickets:close", url.Values{"resource_owner_id": {"1"}}))
+
+	status, _ = postPermissionCheck(t, st, strconv.Itoa(appID), appSecret, url.Values{
+		"user_id":        {userID},
+		"permission":     {"orgs:manage"},
+		"attrs.Bad Name": {"acme"},
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/roles/"+name, map[string]any{
+		"permissions": []string{"orgs:manage"},
+		"conditions":  map[string]string{"orgs:manage": "secrets.key == 1"},
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPut, "/admin/roles/"+name, map[string]any{
+		"permissions": []string{"orgs:manage"},
+		"conditions":  map[string]string{"tickets:close": "true"},
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestCheckPermission(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	adminID := strconv.FormatInt(int64(parseToken(t, admin)["uid"].(float64)), 10)
+	userID := strconv.FormatInt(user.GetUserId(), 10)
+
+	status, allowed := checkPermission(t, st, strconv.Itoa(appID), appSecret, adminID, "invoices:read")
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, allowed)
+
+	status, allowed = checkPermission(t, st, strconv.Itoa(appID), appSecret, userID, "invoices:read")
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, allowed)
+
+	status, _ = checkPermission(t, st, strconv.Itoa(appID), "wrong-secret", userID, "invoices:read")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = checkPermission(t, st, strconv.Itoa(appID), appSecret, userID, "Invoices read")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = checkPermission(t, st, strconv.Itoa(appID), appSecret, "999999999", "invoices:read")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// admin tokens are not app credentials
+	status, _ = adminRequest(t, st, admin, http.MethodPost, "/permissions/check", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func checkPermission(t *testing.T, st *suite.Suite, clientID, clientSecret, userID, permission string) (int, bool) {
+	t.Helper()
+
+	form := url.Values{"user_id": {userID}, "permission": {permission}}
+
+	return postPermissionCheck(t, st, clientID, clientSecret, form)
+}
+
+func postPermissionCheck(t *testing.T, st *suite.Suite, clientID, clientSecret string, form url.Values) (int, bool) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, st.HTTPURL+"/permissions/check", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientID, clientSecret)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body struct {
+		Allowed bool `json:"allowed"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp.StatusCode, body.Allowed
+}
+
+func randomRoleName() string {
+	return "role-" + strings.ToLower(gofakeit.LetterN(12))
+}
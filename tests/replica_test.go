@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sso/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// downConnector fails to connect while the database is down, as a replica
+// that cannot be reached.
+type downConnector struct {
+	sqliteConnector
+	down *atomic.Bool
+}
+
+func (c downConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if c.down.Load() {
+		return nil, errors.New("connection refused")
+	}
+
+	return c.sqliteConnector.Connect(ctx)
+}
+
+func newReplica(t *testing.T, name string) (*sql.DB, *atomic.Bool) {
+	t.Helper()
+
+	down := &atomic.Bool{}
+	db := sql.OpenDB(downConnector{sqliteConnector: sqliteConnector{dsn: filepath.Join(t.TempDir(), name)}, down: down})
+	// no idle connections, so that every ping connects anew
+	db.SetMaxIdleConns(-1)
+
+	return db, down
+}
+
+// readsFrom returns the dbs the replicas send n reads to.
+func readsFrom(r *storage.Replicas, n int) map[*sql.DB]int {
+	reads := map[*sql.DB]int{}
+	for i := 0; i < n; i++ {
+		reads[r.DB()]++
+	}
+
+	return reads
+}
+
+func TestReplicas_Fallback(t *testing.T) {
+	t.Parallel()
+
+	primary := sql.OpenDB(sqliteConnector{dsn: filepath.Join(t.TempDir(), "primary.db")})
+	t.Cleanup(func() { _ = primary.Close() })
+	first, firstDown := newReplica(t, "first.db")
+	second, secondDown := newReplica(t, "second.db")
+
+	const interval = 10 * time.Millisecond
+	replicas := storage.NewReplicas(primary, []*sql.DB{first, second}, interval)
+
+	// reads are spread over the replicas, and none goes to the primary
+	reads := readsFrom(replicas, 10)
+	assert.Equal(t, map[*sql.DB]int{first: 5, second: 5}, reads)
+
+	firstDown.Store(true)
+	require.Eventually(t, func() bool {
+		reads := readsFrom(replicas, 10)
+		return reads[second] == 10
+	}, time.Second, interval)
+
+	// with no replica up, the primary serves the reads
+	secondDown.Store(true)
+	require.Eventually(t, func() bool {
+		return readsFrom(replicas, 10)[primary] == 10
+	}, time.Second, interval)
+
+	// replicas back up are read from again
+	firstDown.Store(false)
+	require.Eventually(t, func() bool {
+		return readsFrom(replicas, 10)[first] == 10
+	}, time.Second, interval)
+
+	require.NoError(t, replicas.Close())
+	assert.NoError(t, primary.Ping())
+	assert.Error(t, first.Ping())
+}
+
+func TestReplicas_None(t *testing.T) {
+	t.Parallel()
+
+	primary := sql.OpenDB(sqliteConnector{dsn: filepath.Join(t.TempDir(), "primary.db")})
+	t.Cleanup(func() { _ = primary.Close() })
+
+	replicas := storage.NewReplicas(primary, nil, time.Millisecond)
+	assert.Same(t, primary, replicas.DB())
+	assert.Empty(t, replicas.Stats())
+	require.NoError(t, replicas.Close())
+}
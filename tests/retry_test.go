@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"sso/internal/domain/models"
+	"sso/internal/lib/emailaddr"
+	"sso/internal/storage/memory"
+	"sso/internal/storage/retry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTransient = errors.New("database is locked")
+
+// flakyStorage fails the user lookups of the memory storage with err until
+// it has failed failures times.
+type flakyStorage struct {
+	*memory.Storage
+
+	mu       sync.Mutex
+	err      error
+	failures int
+	calls    int
+}
+
+func (s *flakyStorage) UserByID(ctx context.Context, userID int64) (models.User, error) {
+	s.mu.Lock()
+	s.calls++
+	fail := s.calls <= s.failures
+	s.mu.Unlock()
+
+	if fail {
+		return models.User{}, s.err
+	}
+
+	return s.Storage.UserByID(ctx, userID)
+}
+
+func (s *flakyStorage) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls
+}
+
+type retryMetrics struct {
+	mu        sync.Mutex
+	retried   int
+	exhausted int
+}
+
+func (m *retryMetrics) Retried(string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retried++
+}
+
+func (m *retryMetrics) BudgetExhausted(string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.exhausted++
+}
+
+// newRetryStorage returns the retrying storage in front of a flaky one
+// holding a user, and the id of the user.
+func newRetryStorage(t *testing.T, err error, failures int, policy retry.Policy) (*retry.Storage, *flakyStorage, *retryMetrics, int64) {
+	t.Helper()
+
+	flaky := &flakyStorage{Storage: memory.New(emailaddr.Normalizer{}), err: err, failures: failures}
+	userID, saveErr := flaky.SaveUser(context.Background(), "retry@example.com", []byte("hash"), models.UserProfile{})
+	require.NoError(t, saveErr)
+
+	metrics := &retryMetrics{}
+	transient := func(err error) bool { return errors.Is(err, errTransient) }
+
+	return retry.New(flaky, transient, policy, metrics), flaky, metrics, userID
+}
+
+func TestRetry_TransientErrors(t *testing.T) {
+	t.Parallel()
+
+	policy := retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Budget: 0.1}
+	storage, flaky, metrics, userID := newRetryStorage(t, errTransient, 2, policy)
+
+	user, err := storage.UserByID(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Equal(t, userID, int64(user.ID))
+	assert.Equal(t, 3, flaky.Calls())
+	assert.Equal(t, 2, metrics.retried)
+}
+
+func TestRetry_MaxAttempts(t *testing.T) {
+	t.Parallel()
+
+	policy := retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Budget: 0.1}
+	storage, flaky, _, userID := newRetryStorage(t, errTransient, 5, policy)
+
+	_, err := storage.UserByID(context.Background(), userID)
+	require.ErrorIs(t, err, errTransient)
+	assert.Equal(t, 3, flaky.Calls())
+}
+
+func TestRetry_NonTransientErrors(t *testing.T) {
+	t.Parallel()
+
+	errConstraint := errors.New("constraint failed")
+	policy := retry.Policy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Budget: 0.1}
+	storage, flaky, metrics, userID := newRetryStorage(t, errConstraint, 1, policy)
+
+	_, err := storage.UserByID(context.Background(), userID)
+	require.ErrorIs(t, err, errConstraint)
+	assert.Equal(t, 1, flaky.Calls())
+	assert.Zero(t, metrics.retried)
+}
+
+func TestRetry_DialErrors(t *testing.T) {
+	t.Parallel()
+
+	// nothing reached the database, whatever the driver tells apart
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	policy := retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Budget: 0.1}
+	storage, flaky, _, userID := newRetryStorage(t, dialErr, 1, policy)
+
+	_, err := storage.UserByID(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, flaky.Calls())
+}
+
+func TestRetry_BackoffCap(t *testing.T) {
+	t.Parallel()
+
+	// without the cap the third retry would wait up to four hours
+	policy := retry.Policy{MaxAttempts: 4, BaseDelay: time.Hour, MaxDelay: 20 * time.Millisecond, Budget: 0.1}
+	storage, flaky, _, userID := newRetryStorage(t, errTransient, 3, policy)
+
+	start := time.Now()
+	_, err := storage.UserByID(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 4, flaky.Calls())
+}
+
+func TestRetry_ContextDone(t *testing.T) {
+	t.Parallel()
+
+	policy := retry.Policy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour, Budget: 0.1}
+	storage, flaky, _, userID := newRetryStorage(t, errTransient, 5, policy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := storage.UserByID(ctx, userID)
+	require.ErrorIs(t, err, errTransient)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 1, flaky.Calls())
+}
+
+func TestRetry_Budget(t *testing.T) {
+	t.Parallel()
+
+	// calls add nothing to the budget, which starts with ten retries
+	policy := retry.Policy{MaxAttempts: 100, BaseDelay: 0, MaxDelay: 0, Budget: 0}
+	storage, flaky, metrics, userID := newRetryStorage(t, errTransient, 1000, policy)
+
+	_, err := storage.UserByID(context.Background(), userID)
+	require.ErrorIs(t, err, errTransient)
+	assert.Equal(t, 11, flaky.Calls())
+	assert.Equal(t, 10, metrics.retried)
+	assert.Equal(t, 1, metrics.exhausted)
+
+	// the budget is spent, so the next call is not retried at all
+	_, err = storage.UserByID(context.Background(), userID)
+	require.ErrorIs(t, err, errTransient)
+	assert.Equal(t, 12, flaky.Calls())
+	assert.Equal(t, 2, metrics.exhausted)
+}
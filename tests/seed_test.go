@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"testing"
+
+	"sso/internal/app"
+	"sso/internal/config"
+	"sso/internal/lib/logger/slogdiscard"
+	"sso/internal/seed"
+	"sso/internal/storage/factory"
+	"sso/internal/storage/sqlite"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// seededSecrets returns the secret hashes of the apps and the password
+// hashes of the users by their names and emails, failing if a name or an
+// email is there twice.
+func seededSecrets(t *testing.T, path string) (map[string]string, map[string][]byte) {
+	t.Helper()
+
+	db, err := sqlite.OpenDB(path, sqlite.Pragmas{})
+	require.NoError(t, err)
+	defer db.Close()
+
+	apps := map[string]string{}
+	rows, err := db.Query("SELECT name, secret FROM apps")
+	require.NoError(t, err)
+	for rows.Next() {
+		var name, secret string
+		require.NoError(t, rows.Scan(&name, &secret))
+		require.NotContains(t, apps, name)
+		apps[name] = secret
+	}
+	require.NoError(t, rows.Err())
+
+	users := map[string][]byte{}
+	rows, err = db.Query("SELECT email, pass_hash FROM users")
+	require.NoError(t, err)
+	for rows.Next() {
+		var email string
+		var passHash []byte
+		require.NoError(t, rows.Scan(&email, &passHash))
+		require.NotContains(t, users, email)
+		users[email] = passHash
+	}
+	require.NoError(t, rows.Err())
+
+	return apps, users
+}
+
+func TestSeed_Fixture(t *testing.T) {
+	t.Parallel()
+
+	path := newSQLiteDB(t)
+	cfg := &config.Config{Storage: config.StorageConfig{Driver: factory.DriverSQLite, DSN: path}}
+	log := slogdiscard.NewDiscardLogger()
+
+	data, err := seed.Load("testdata/seed.yml")
+	require.NoError(t, err)
+	require.Len(t, data.Apps, 2)
+	require.Len(t, data.Admins, 2)
+
+	require.NoError(t, app.Seed(log, cfg, data))
+
+	apps, users := seededSecrets(t, path)
+	require.Contains(t, apps, "seeded-web")
+	require.Contains(t, apps, "seeded-cli")
+	require.Contains(t, users, "seeded-admin@example.com")
+	require.Contains(t, users, "seeded-ops@example.com")
+
+	// the given secrets and passwords are stored hashed
+	assert.NotEqual(t, "seeded-web-secret", apps["seeded-web"])
+	assert.NoError(t, bcrypt.CompareHashAndPassword(users["seeded-admin@example.com"], []byte("seeded-admin-password")))
+
+	// seeding again, even with other secrets, leaves everything as it is
+	require.NoError(t, app.Seed(log, cfg, data))
+
+	data.Apps[0].Secret = "another-secret"
+	data.Admins[0].Password = "another-password"
+	require.NoError(t, app.Seed(log, cfg, data))
+
+	reseededApps, reseededUsers := seededSecrets(t, path)
+	assert.Equal(t, apps, reseededApps)
+	assert.Equal(t, users, reseededUsers)
+}
@@ -0,0 +1,285 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"sso/internal/config"
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const firefoxUserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:130.0) Gecko/20100101 Firefox/130.0"
+
+type sessionResponse struct {
+	ID         int64     `json:"id"`
+	AppID      int       `json:"app_id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Device     string    `json:"device"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+func TestSessions_ListAndRevoke(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	status, login := requestTokenAs(t, st, firefoxUserAgent, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	sessions := listSessions(t, st, login.AccessToken)
+	require.Len(t, sessions, 2)
+
+	browser, grpcSession := sessionByDevice(t, sessions, "Firefox on Linux"), sessionByDevice(t, sessions, "gRPC client")
+	assert.Equal(t, appID, browser.AppID)
+	assert.Equal(t, firefoxUserAgent, browser.UserAgent)
+	assert.NotEmpty(t, browser.IP)
+	assert.False(t, browser.CreatedAt.IsZero())
+
+	// refreshing from another client updates the session
+	status, refreshed := requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	browser = sessionByDevice(t, listSessions(t, st, login.AccessToken), "Go HTTP client")
+	assert.False(t, browser.LastSeenAt.Before(browser.CreatedAt))
+
+	code := revokeSession(t, st, login.AccessToken, grpcSession.ID)
+	require.Equal(t, http.StatusNoContent, code)
+
+	sessions = listSessions(t, st, login.AccessToken)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, browser.ID, sessions[0].ID)
+
+	code = revokeSession(t, st, login.AccessToken, browser.ID)
+	require.Equal(t, http.StatusNoContent, code)
+
+	status, revoked := requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshed.RefreshToken},
+	})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", revoked.Error)
+
+	assert.Empty(t, listSessions(t, st, login.AccessToken))
+}
+
+func TestSessions_RevokeFailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	sessions := listSessions(t, st, respLogin.GetToken())
+	require.Len(t, sessions, 1)
+
+	// sessions of other users are not found
+	code := revokeSession(t, st, adminToken(t, st), sessions[0].ID)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code = revokeSession(t, st, respLogin.GetToken(), 999999999)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code = revokeSession(t, st, "", sessions[0].ID)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	assert.Len(t, listSessions(t, st, respLogin.GetToken()), 1)
+}
+
+func TestSessions_FixedExpiry(t *testing.T) {
+	ctx, st := suite.NewInProcess(t, nil, func(cfg *config.Config) {
+		cfg.TokenFormat = "opaque"
+		cfg.TokenTTL = time.Second
+		cfg.RefreshTokenTTL = time.Second
+		cfg.Session.Sliding = false
+	})
+
+	start, login := loginForSession(t, ctx, st)
+
+	waitUntil(start.Add(500 * time.Millisecond))
+	assert.Equal(t, http.StatusOK, useAccessToken(t, st, login.AccessToken))
+	code, refreshed := refreshSession(t, st, login.RefreshToken)
+	require.Equal(t, http.StatusOK, code)
+
+	// neither use moved the end of the session a second after the login
+	waitUntil(start.Add(1300 * time.Millisecond))
+	assert.Equal(t, http.StatusUnauthorized, useAccessToken(t, st, login.AccessToken))
+	code, expired := refreshSession(t, st, refreshed.RefreshToken)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "invalid_grant", expired.Error)
+}
+
+func TestSessions_SlidingExpiry(t *testing.T) {
+	ctx, st := suite.NewInProcess(t, nil, func(cfg *config.Config) {
+		cfg.TokenFormat = "opaque"
+		cfg.TokenTTL = time.Second
+		cfg.RefreshTokenTTL = time.Second
+		cfg.Session.Sliding = true
+		cfg.Session.MaxAge = 2500 * time.Millisecond
+	})
+
+	start, login := loginForSession(t, ctx, st)
+
+	// every use extends the session by a second, past the first second
+	refreshToken := login.RefreshToken
+	for _, at := range []time.Duration{700 * time.Millisecond, 1400 * time.Millisecond, 2100 * time.Millisecond} {
+		waitUntil(start.Add(at))
+		assert.Equal(t, http.StatusOK, useAccessToken(t, st, login.AccessToken), "at %s", at)
+
+		code, refreshed := refreshSession(t, st, refreshToken)
+		require.Equal(t, http.StatusOK, code, "at %s", at)
+		refreshToken = refreshed.RefreshToken
+	}
+
+	// but not past the max age of the session
+	waitUntil(start.Add(2800 * time.Millisecond))
+	assert.Equal(t, http.StatusUnauthorized, useAccessToken(t, st, login.AccessToken))
+	code, expired := refreshSession(t, st, refreshToken)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "invalid_grant", expired.Error)
+}
+
+// loginForSession registers a user and logs them in to the test app, and
+// returns the time just before the login.
+func loginForSession(t *testing.T, ctx context.Context, st *suite.Suite) (time.Time, tokenResponse) {
+	t.Helper()
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: pass})
+	require.NoError(t, err)
+
+	start := time.Now()
+	code, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, code)
+	require.NotEmpty(t, login.RefreshToken)
+
+	return start, login
+}
+
+// useAccessToken makes a request the access token is validated for.
+func useAccessToken(t *testing.T, st *suite.Suite, token string) int {
+	t.Helper()
+
+	code, _ := adminRequest(t, st, token, http.MethodGet, "/sessions", nil)
+
+	return code
+}
+
+func refreshSession(t *testing.T, st *suite.Suite, refreshToken string) (int, tokenResponse) {
+	t.Helper()
+
+	return requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+}
+
+func waitUntil(at time.Time) {
+	time.Sleep(time.Until(at))
+}
+
+func requestTokenAs(t *testing.T, st *suite.Suite, userAgent string, form url.Values) (int, tokenResponse) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, st.HTTPURL+"/token", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp.StatusCode, body
+}
+
+func listSessions(t *testing.T, st *suite.Suite, token string) []sessionResponse {
+	t.Helper()
+
+	code, body := adminRequest(t, st, token, http.MethodGet, "/sessions", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var resp struct {
+		Sessions []sessionResponse `json:"sessions"`
+	}
+	require.NoError(t, json.Unmarshal(body, &resp))
+
+	return resp.Sessions
+}
+
+func revokeSession(t *testing.T, st *suite.Suite, token string, sessionID int64) int {
+	t.Helper()
+
+	code, _ := adminRequest(t, st, token, http.MethodDelete, "/sessions/"+strconv.FormatInt(sessionID, 10), nil)
+
+	return code
+}
+
+func sessionByDevice(t *testing.T, sessions []sessionResponse, device string) sessionResponse {
+	t.Helper()
+
+	for _, session := range sessions {
+		if session.Device == device {
+			return session
+		}
+	}
+	require.Failf(t, "session not found", "no session of %q in %v", device, sessions)
+
+	return sessionResponse{}
+}
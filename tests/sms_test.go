@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const grantTypeSMSOTP = "urn:sso:params:oauth:grant-type:sms-otp"
+
+const grantTypeMFASMS = "urn:sso:params:oauth:grant-type:mfa-sms"
+
+func TestSMS_PhoneLogin(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	phone := randomPhone()
+
+	status = bearerPostForm(t, st, login.AccessToken, "/phone", url.Values{"phone": {"12345"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status = bearerPostForm(t, st, login.AccessToken, "/phone", url.Values{"phone": {phone}})
+	require.Equal(t, http.StatusAccepted, status)
+
+	status = bearerPostForm(t, st, login.AccessToken, "/phone", url.Values{"phone": {phone}})
+	assert.Equal(t, http.StatusTooManyRequests, status)
+
+	status = bearerPostForm(t, st, login.AccessToken, "/phone/verify", url.Values{"code": {"abcdef"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status = bearerPostForm(t, st, login.AccessToken, "/phone/verify", url.Values{
+		"code": {smsCode(t, st, phone)},
+	})
+	require.Equal(t, http.StatusNoContent, status)
+
+	// unknown numbers are not disclosed
+	status = postForm(t, st, "/sms/login", url.Values{"phone": {randomPhone()}})
+	assert.Equal(t, http.StatusAccepted, status)
+
+	status = postForm(t, st, "/sms/login", url.Values{"phone": {phone}})
+	require.Equal(t, http.StatusAccepted, status)
+
+	smsLoginForm := url.Values{
+		"grant_type": {grantTypeSMSOTP},
+		"phone":      {phone},
+		"otp":        {"abcdef"},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+
+	status, _ = requestToken(t, st, smsLoginForm)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	smsLoginForm.Set("otp", smsCode(t, st, phone))
+
+	status, smsLogin := requestToken(t, st, smsLoginForm)
+	require.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, smsLogin.AccessToken)
+	assert.Equal(t, []interface{}{"sms"}, parseIDToken(t, smsLogin.IDToken)["amr"])
+
+	// the code is single use
+	status, _ = requestToken(t, st, smsLoginForm)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestSMS_SecondFactor(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+
+	status, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, status)
+
+	phone := randomPhone()
+
+	status = bearerPostForm(t, st, login.AccessToken, "/phone", url.Values{"phone": {phone}})
+	require.Equal(t, http.StatusAccepted, status)
+
+	status = bearerPostForm(t, st, login.AccessToken, "/phone/verify", url.Values{
+		"code": {smsCode(t, st, phone)},
+	})
+	require.Equal(t, http.StatusNoContent, status)
+
+	secret := enrollTOTP(t, st, login.AccessToken).Secret
+
+	status = bearerPostForm(t, st, login.AccessToken, "/mfa/totp/verify", url.Values{
+		"code": {totpCode(t, secret, currentTOTPStep())},
+	})
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, challenge := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusForbidden, status)
+	require.NotEmpty(t, challenge.MFAToken)
+
+	status = postForm(t, st, "/mfa/sms", url.Values{"mfa_token": {"unknown"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status = postForm(t, st, "/mfa/sms", url.Values{"mfa_token": {challenge.MFAToken}})
+	require.Equal(t, http.StatusAccepted, status)
+
+	status, mfaLogin := requestToken(t, st, url.Values{
+		"grant_type": {grantTypeMFASMS},
+		"mfa_token":  {challenge.MFAToken},
+		"otp":        {smsCode(t, st, phone)},
+	})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []interface{}{"pwd", "sms"}, parseIDToken(t, mfaLogin.IDToken)["amr"])
+}
+
+var smsCodeRe = regexp.MustCompile(`code is ([0-9]+)`)
+
+// smsCode returns the code from the last SMS sent to the phone number.
+// The server must be configured with the file SMS sender.
+func smsCode(t *testing.T, st *suite.Suite, to string) string {
+	t.Helper()
+
+	dir := st.Cfg.SMS.Dir
+	if !filepath.IsAbs(dir) {
+		// the server runs from the repository root
+		dir = filepath.Join("..", dir)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*_"+to+".sms"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files, "no sms sent to %s", to)
+	sort.Strings(files)
+
+	data, err := os.ReadFile(files[len(files)-1])
+	require.NoError(t, err)
+
+	match := smsCodeRe.FindStringSubmatch(string(data))
+	require.Len(t, match, 2)
+
+	return match[1]
+}
+
+func randomPhone() string {
+	return "+1555" + gofakeit.Numerify("#######")
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepUp_OTP(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	_, info := introspect(t, st, login.AccessToken, strconv.Itoa(appID), appSecret)
+	require.True(t, info.Active)
+	assert.Equal(t, "aal1", info.ACR)
+	assert.Equal(t, []string{"pwd"}, info.AMR)
+	assert.NotZero(t, info.AuthTime)
+
+	secret := enrollTOTP(t, st, login.AccessToken).Secret
+	step := currentTOTPStep()
+
+	status = bearerPostForm(t, st, login.AccessToken, "/mfa/totp/verify", url.Values{
+		"code": {totpCode(t, secret, step-1)},
+	})
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, body := stepUp(t, st, url.Values{"refresh_token": {login.RefreshToken}, "otp": {"000000"}})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", body.Error)
+
+	status, stepped := stepUp(t, st, url.Values{
+		"refresh_token": {login.RefreshToken},
+		"otp":           {totpCode(t, secret, step)},
+	})
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, stepped.AccessToken)
+	require.NotEmpty(t, stepped.RefreshToken)
+
+	_, steppedInfo := introspect(t, st, stepped.AccessToken, strconv.Itoa(appID), appSecret)
+	require.True(t, steppedInfo.Active)
+	assert.Equal(t, "aal2", steppedInfo.ACR)
+	assert.Equal(t, []string{"pwd", "otp"}, steppedInfo.AMR)
+	assert.GreaterOrEqual(t, steppedInfo.AuthTime, info.AuthTime)
+
+	// refreshing keeps the step-up
+	status, refreshed := requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {stepped.RefreshToken},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	_, refreshedInfo := introspect(t, st, refreshed.AccessToken, strconv.Itoa(appID), appSecret)
+	assert.Equal(t, "aal2", refreshedInfo.ACR)
+	assert.Equal(t, steppedInfo.AuthTime, refreshedInfo.AuthTime)
+
+	// the rotated refresh token can not be stepped up
+	status, _ = stepUp(t, st, url.Values{"refresh_token": {login.RefreshToken}, "password": {pass}})
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestStepUp_Password(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	status, body := stepUp(t, st, url.Values{"refresh_token": {login.RefreshToken}})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_request", body.Error)
+
+	status, body = stepUp(t, st, url.Values{"refresh_token": {login.RefreshToken}, "password": {randomFakePassword()}})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", body.Error)
+
+	status, stepped := stepUp(t, st, url.Values{"refresh_token": {login.RefreshToken}, "password": {pass}})
+	require.Equal(t, http.StatusOK, status)
+
+	_, info := introspect(t, st, stepped.AccessToken, strconv.Itoa(appID), appSecret)
+	assert.Equal(t, "aal1", info.ACR)
+	assert.Equal(t, []string{"pwd"}, info.AMR)
+
+	status, body = stepUp(t, st, url.Values{"refresh_token": {stepped.RefreshToken}, "otp": {"123456"}})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "mfa_not_enabled", body.Error)
+}
+
+func stepUp(t *testing.T, st *suite.Suite, form url.Values) (int, tokenResponse) {
+	t.Helper()
+
+	resp, err := http.Post(st.HTTPURL+"/step-up", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp.StatusCode, body
+}
@@ -0,0 +1,116 @@
+package suite
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sso/internal/app"
+	"sso/internal/config"
+	"sso/internal/lib/logger/slogdiscard"
+	"sso/internal/seed"
+	"sso/internal/storage/factory"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// NewInProcess starts the service within the test, for settings the shared
+// server does not run with. It loads the config of the shared server, keeps
+// everything in memory, changes the config with configure, and logs to log
+// if it is not nil. The storage starts out with the schema the migrations
+// leave, and the test app and the admin of the test migrations, created
+// after those of the dev mode.
+func NewInProcess(t *testing.T, log *slog.Logger, configure func(cfg *config.Config)) (context.Context, *Suite) {
+	t.Helper()
+	t.Parallel()
+
+	cfg := config.MustLoadPath(configPath())
+	cfg.Storage.Driver = factory.DriverMemory
+	cfg.Storage.AutoMigrate = false
+	cfg.Storage.Replicas = nil
+	cfg.Grpc.Port = freePort(t)
+	cfg.HTTP.Port = freePort(t)
+	cfg.Email.Dir = t.TempDir()
+	cfg.SMS.Dir = t.TempDir()
+	// the paths of the config are relative to the root of the repository
+	if file := cfg.PwnedPasswords.BloomFile; file != "" && !filepath.IsAbs(file) {
+		cfg.PwnedPasswords.BloomFile = filepath.Join("..", file)
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	if log == nil {
+		log = slogdiscard.NewDiscardLogger()
+	}
+	application := app.New(log, cfg)
+	t.Cleanup(func() { _ = application.Storage.Close() })
+
+	_, err := seed.Apply(context.Background(), application.Storage, seed.Data{
+		Apps:   []seed.App{{Name: "test", Secret: "test-secret"}},
+		Admins: []seed.Admin{{Email: "admin@sso.test", Password: "test-admin-password"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to seed the test app: %v", err)
+	}
+
+	go application.GRPCServer.MustRun()
+	go application.HTTPServer.MustRun()
+	t.Cleanup(application.GRPCServer.Stop)
+	t.Cleanup(application.HTTPServer.Stop)
+	waitListening(t, grpcAddress(cfg))
+	waitListening(t, httpAddress(cfg))
+
+	ctx, cancelCtx := context.WithTimeout(context.Background(), cfg.Grpc.Timeout)
+	t.Cleanup(cancelCtx)
+
+	cc, err := grpc.NewClient(grpcAddress(cfg),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc server connection failed: %v", err)
+	}
+	t.Cleanup(func() { _ = cc.Close() })
+
+	return ctx, &Suite{
+		T:          t,
+		Cfg:        cfg,
+		AuthClient: ssov1.NewAuthClient(cc),
+		HTTPURL:    "http://" + httpAddress(cfg),
+	}
+}
+
+// freePort returns a port nothing listens on.
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	defer lis.Close()
+
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+// waitListening waits for the server at the address to accept connections.
+func waitListening(t *testing.T, address string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", address)
+		if err == nil {
+			_ = conn.Close()
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server at %s is not listening: %v", address, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
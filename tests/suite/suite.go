@@ -18,6 +18,7 @@ type Suite struct {
 	*testing.T                  // Потребуется для вызова методов *testing.T внутри Suite
 	Cfg        *config.Config   // Конфигурация приложения
 	AuthClient ssov1.AuthClient // Клиент для взаимодействия с gRPC-сервером
+	HTTPURL    string           // Базовый адрес HTTP-сервера
 }
 
 const (
@@ -48,6 +49,7 @@ func New(t *testing.T) (context.Context, *Suite) {
 		T:          t,
 		Cfg:        cfg,
 		AuthClient: ssov1.NewAuthClient(cc),
+		HTTPURL:    "http://" + httpAddress(cfg),
 	}
 }
 
@@ -64,3 +66,7 @@ func configPath() string {
 func grpcAddress(cfg *config.Config) string {
 	return net.JoinHostPort(grpcHost, strconv.Itoa(cfg.Grpc.Port))
 }
+
+func httpAddress(cfg *config.Config) string {
+	return net.JoinHostPort(grpcHost, strconv.Itoa(cfg.HTTP.Port))
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSuspension(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+	code, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, code)
+
+	admin := adminToken(t, st)
+	userPath := "/admin/users/" + strconv.FormatInt(respReg.GetUserId(), 10)
+
+	code, _ = adminRequest(t, st, admin, http.MethodPost, userPath+"/suspend", nil)
+	require.Equal(t, http.StatusNoContent, code)
+
+	// the tokens of the user are revoked right away
+	code, _ = adminRequest(t, st, login.AccessToken, http.MethodGet, "/sessions", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, suspended := requestToken(t, st, loginForm)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "account_suspended", suspended.Error)
+
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// wrong passwords do not tell that the account is suspended
+	_, err = st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: randomFakePassword(),
+		AppId:    appID,
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	code, body := adminRequest(t, st, admin, http.MethodGet, userPath, nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var user struct {
+		Status string `json:"status"`
+	}
+	require.NoError(t, json.Unmarshal(body, &user))
+	assert.Equal(t, "suspended", user.Status)
+
+	code, _ = adminRequest(t, st, admin, http.MethodPost, userPath+"/unsuspend", nil)
+	require.Equal(t, http.StatusNoContent, code)
+
+	code, _ = requestToken(t, st, loginForm)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, body = adminRequest(t, st, admin, http.MethodGet,
+		"/admin/audit-events?user_id="+strconv.FormatInt(respReg.GetUserId(), 10), nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var events auditEventsResponse
+	require.NoError(t, json.Unmarshal(body, &events))
+
+	var types []string
+	for _, event := range events.Events {
+		types = append(types, event.Type)
+	}
+	assert.Subset(t, types, []string{"user_suspended", "user_unsuspended"})
+}
+
+func TestSuspension_FailCases(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	code, body := adminRequest(t, st, admin, http.MethodGet, "/admin/users/"+adminEmail, nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var adminUser userResponse
+	require.NoError(t, json.Unmarshal(body, &adminUser))
+
+	tests := []struct {
+		name string
+		path string
+		code int
+	}{
+		{
+			name: "Self",
+			path: "/admin/users/" + strconv.FormatInt(adminUser.ID, 10) + "/suspend",
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "Unknown user",
+			path: "/admin/users/999999999/suspend",
+			code: http.StatusNotFound,
+		},
+		{
+			name: "Unsuspend unknown user",
+			path: "/admin/users/999999999/unsuspend",
+			code: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := adminRequest(t, st, admin, http.MethodPost, tt.path, nil)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+}
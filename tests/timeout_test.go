@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"context"
+	"database/sql/driver"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sso/internal/storage"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// endlessQuery counts rows for ever, until SQLite is interrupted.
+const endlessQuery = `WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT count(*) FROM c`
+
+type sqliteConnector struct {
+	dsn string
+}
+
+func (c sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.Driver().Open(c.dsn)
+}
+
+func (c sqliteConnector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	// a single connection, so that one left busy would block the pool
+	pool := storage.PoolConfig{MaxOpenConns: 1, QueryTimeout: 100 * time.Millisecond}
+	db := pool.OpenDB(sqliteConnector{dsn: filepath.Join(t.TempDir(), "timeout.db")})
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err := db.ExecContext(ctx, `CREATE TABLE counts (n INTEGER)`)
+	require.NoError(t, err)
+
+	start := time.Now()
+	var n int64
+	err = db.QueryRowContext(ctx, endlessQuery).Scan(&n)
+	require.ErrorIs(t, err, storage.ErrDeadlineExceeded)
+	// the gRPC layer answers DeadlineExceeded rather than Internal for it
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	_, err = db.ExecContext(ctx, `INSERT INTO counts `+endlessQuery)
+	require.ErrorIs(t, err, storage.ErrDeadlineExceeded)
+
+	// prepared statements run out of time the same way
+	stmt, err := db.PrepareContext(ctx, endlessQuery)
+	require.NoError(t, err)
+	err = stmt.QueryRowContext(ctx).Scan(&n)
+	require.ErrorIs(t, err, storage.ErrDeadlineExceeded)
+	require.NoError(t, stmt.Close())
+
+	// every statement of a transaction gets the whole timeout
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, `INSERT INTO counts VALUES (1)`)
+	require.NoError(t, err)
+	time.Sleep(150 * time.Millisecond)
+	_, err = tx.ExecContext(ctx, `INSERT INTO counts VALUES (2)`)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	// the connection went back to the pool and still works
+	err = db.QueryRowContext(ctx, `SELECT count(*) FROM counts`).Scan(&n)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Zero(t, db.Stats().InUse)
+
+	// errors of statements which did not run out of time are kept
+	_, err = db.ExecContext(ctx, `INSERT INTO missing VALUES (1)`)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, storage.ErrDeadlineExceeded)
+}
@@ -0,0 +1,545 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	downstreamAppName       = "test-downstream"
+	downstreamAppSigningKey = "test-downstream-signing-key"
+)
+
+type tokenResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	RefreshToken    string `json:"refresh_token"`
+	IDToken         string `json:"id_token"`
+	Scope           string `json:"scope"`
+	Error           string `json:"error"`
+	MFAToken        string `json:"mfa_token"`
+	DeviceToken     string `json:"device_token"`
+	// PasswordChangeToken is set when an admin required a new password.
+	PasswordChangeToken string `json:"password_change_token"`
+}
+
+func TestToken_RefreshRotation(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, login.AccessToken)
+	require.NotEmpty(t, login.RefreshToken)
+	assert.Equal(t, "Bearer", login.TokenType)
+	assert.Equal(t, int64(st.Cfg.TokenTTL.Seconds()), login.ExpiresIn)
+
+	status, refreshed := requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, refreshed.RefreshToken)
+	assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+
+	// reusing the rotated token revokes the whole family
+	status, reused := requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", reused.Error)
+
+	status, revoked := requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshed.RefreshToken},
+	})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", revoked.Error)
+}
+
+func TestToken_FailCases(t *testing.T) {
+	_, st := suite.New(t)
+
+	tests := []struct {
+		name           string
+		form           url.Values
+		expectedStatus int
+		expectedErr    string
+	}{
+		{
+			name:           "Unsupported grant type",
+			form:           url.Values{"grant_type": {"implicit"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedErr:    "unsupported_grant_type",
+		},
+		{
+			name:           "Unknown refresh token",
+			form:           url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"unknown"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedErr:    "invalid_grant",
+		},
+		{
+			name: "Password grant without client",
+			form: url.Values{
+				"grant_type": {"password"},
+				"username":   {gofakeit.Email()},
+				"password":   {randomFakePassword()},
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedErr:    "invalid_request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := requestToken(t, st, tt.form)
+			assert.Equal(t, tt.expectedStatus, status)
+			assert.Equal(t, tt.expectedErr, resp.Error)
+		})
+	}
+}
+
+func requestToken(t *testing.T, st *suite.Suite, form url.Values) (int, tokenResponse) {
+	t.Helper()
+
+	resp, err := http.Post(st.HTTPURL+"/token", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp.StatusCode, body
+}
+
+func TestLogout_RevokesTokens(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	req, err := http.NewRequest(http.MethodPost, st.HTTPURL+"/logout",
+		strings.NewReader(url.Values{"refresh_token": {login.RefreshToken}}.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+login.AccessToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	status, info := introspect(t, st, login.AccessToken, strconv.Itoa(appID), appSecret)
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, info.Active)
+
+	// the revoked token is replayed
+	req, err = http.NewRequest(http.MethodPost, st.HTTPURL+"/logout", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+login.AccessToken)
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	status, refreshed := requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", refreshed.Error)
+}
+
+func TestLogout_OtherAppRefreshToken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+	status, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, status)
+
+	loginForm.Set("client_id", "2")
+	status, downstream := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, status)
+
+	// the refresh token of another app does not go with the access token
+	req, err := http.NewRequest(http.MethodPost, st.HTTPURL+"/logout",
+		strings.NewReader(url.Values{"refresh_token": {downstream.RefreshToken}}.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+login.AccessToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	status, info := introspect(t, st, login.AccessToken, strconv.Itoa(appID), appSecret)
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, info.Active)
+
+	status, _ = requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {downstream.RefreshToken},
+	})
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+
+	// the user is logged in on two devices
+	var logins []tokenResponse
+	for range 2 {
+		status, login := requestToken(t, st, loginForm)
+		require.Equal(t, http.StatusOK, status)
+		logins = append(logins, login)
+	}
+
+	path := "/admin/users/" + strconv.FormatInt(respReg.GetUserId(), 10) + "/revoke-sessions"
+
+	// users can not log others, or themselves, out through the admin api
+	status, _ := adminRequest(t, st, logins[0].AccessToken, http.MethodPost, path, nil)
+	require.Equal(t, http.StatusForbidden, status)
+
+	admin := adminToken(t, st)
+	status, _ = adminRequest(t, st, admin, http.MethodPost, path, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	for _, login := range logins {
+		status, info := introspect(t, st, login.AccessToken, strconv.Itoa(appID), appSecret)
+		require.Equal(t, http.StatusOK, status)
+		assert.False(t, info.Active)
+
+		status, _ = adminRequest(t, st, login.AccessToken, http.MethodGet, "/sessions", nil)
+		assert.Equal(t, http.StatusUnauthorized, status)
+
+		status, refreshed := requestToken(t, st, url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {login.RefreshToken},
+		})
+		require.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "invalid_grant", refreshed.Error)
+	}
+
+	// the user can log in again
+	status, _ = requestToken(t, st, loginForm)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = adminRequest(t, st, admin, http.MethodPost, "/admin/users/999999999/revoke-sessions", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestToken_Exchange(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	form := url.Values{
+		"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"subject_token":      {respLogin.GetToken()},
+		"subject_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		"audience":           {downstreamAppName},
+		"client_id":          {strconv.Itoa(appID)},
+		"client_secret":      {appSecret},
+	}
+
+	status, exchanged := requestToken(t, st, form)
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, exchanged.AccessToken)
+	assert.Equal(t, "urn:ietf:params:oauth:token-type:access_token", exchanged.IssuedTokenType)
+
+	tokenParsed, err := jwt.Parse(exchanged.AccessToken, func(token *jwt.Token) (interface{}, error) {
+		return []byte(downstreamAppSigningKey), nil
+	})
+	require.NoError(t, err)
+
+	claims, ok := tokenParsed.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+
+	assert.Equal(t, respReg.GetUserId(), int64(claims["uid"].(float64)))
+	assert.Equal(t, downstreamAppName, claims["aud"])
+	assert.Equal(t, map[string]interface{}{"sub": "app:" + strconv.Itoa(appID)}, claims["act"])
+
+	form.Set("audience", "unknown-audience")
+	status, failed := requestToken(t, st, form)
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_target", failed.Error)
+}
+
+func TestToken_ExchangeChecks(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+		"scope":      {"profile email metadata"},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	exchange := func(audience string) (int, tokenResponse) {
+		return requestToken(t, st, url.Values{
+			"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+			"subject_token":      {login.AccessToken},
+			"subject_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+			"audience":           {audience},
+			"client_id":          {strconv.Itoa(appID)},
+			"client_secret":      {appSecret},
+		})
+	}
+
+	// third-party apps get the tokens of users who consented only
+	status, failed := exchange("test-third-party")
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "consent_required", failed.Error)
+
+	code, _ := grantConsent(t, st, login.AccessToken, thirdPartyAppID, "profile email")
+	require.Equal(t, http.StatusOK, code)
+
+	status, exchanged := exchange("test-third-party")
+	require.Equal(t, http.StatusOK, status)
+
+	// the scopes of the subject token the target app has carry over
+	status, info := introspect(t, st, exchanged.AccessToken, strconv.Itoa(thirdPartyAppID), "test-third-party-secret")
+	require.Equal(t, http.StatusOK, status)
+	require.True(t, info.Active)
+	assert.Equal(t, "profile email", info.Scope)
+
+	// apps of other orgs are closed to the user
+	admin := adminToken(t, st)
+	org := createOrg(t, st, admin)
+	code, body := adminRequest(t, st, admin, http.MethodPost, "/admin/apps", map[string]any{
+		"name":   randomAppName(),
+		"org_id": org.ID,
+	})
+	require.Equal(t, http.StatusCreated, code)
+
+	var orgApp appResponse
+	require.NoError(t, json.Unmarshal(body, &orgApp))
+
+	status, failed = exchange(orgApp.Name)
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "access_denied", failed.Error)
+}
+
+func TestToken_ClientCredentials(t *testing.T) {
+	_, st := suite.New(t)
+
+	status, resp := requestToken(t, st, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {strconv.Itoa(appID)},
+		"client_secret": {appSecret},
+	})
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, resp.AccessToken)
+	assert.Empty(t, resp.RefreshToken)
+
+	status, info := introspect(t, st, resp.AccessToken, strconv.Itoa(appID), appSecret)
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, info.Active)
+	assert.Empty(t, info.Sub)
+	assert.Equal(t, strconv.Itoa(appID), info.ClientID)
+
+	status, resp = requestToken(t, st, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {strconv.Itoa(appID)},
+		"client_secret": {"wrong-secret"},
+	})
+	require.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "invalid_client", resp.Error)
+}
+
+func TestToken_Scopes(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+		"scope":      {"profile"},
+	})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "profile", login.Scope)
+
+	status, info := introspect(t, st, login.AccessToken, strconv.Itoa(appID), appSecret)
+	require.Equal(t, http.StatusOK, status)
+	require.True(t, info.Active)
+	assert.Equal(t, "profile", info.Scope)
+
+	status, refreshed := requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "profile", refreshed.Scope)
+
+	status, denied := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+		"scope":      {"profile admin"},
+	})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_scope", denied.Error)
+}
+
+func TestToken_IDToken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, login.IDToken)
+
+	claims := parseIDToken(t, login.IDToken)
+	assert.Equal(t, strconv.FormatInt(respReg.GetUserId(), 10), claims["sub"])
+	assert.Equal(t, strconv.Itoa(appID), claims["aud"])
+	assert.Equal(t, st.Cfg.Issuer, claims["iss"])
+	assert.Equal(t, email, claims["email"])
+	assert.Equal(t, false, claims["email_verified"])
+	assert.Equal(t, []interface{}{"pwd"}, claims["amr"])
+	assert.NotZero(t, claims["auth_time"])
+
+	status, refreshed := requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	// the ID token of a refresh keeps the time of the original login
+	refreshedClaims := parseIDToken(t, refreshed.IDToken)
+	assert.Equal(t, claims["auth_time"], refreshedClaims["auth_time"])
+}
+
+func parseIDToken(t *testing.T, idToken string) jwt.MapClaims {
+	t.Helper()
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(appSigningKey), nil
+	})
+	require.NoError(t, err)
+
+	return claims
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const otherUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/128.0 Safari/537.36"
+
+type trustedDeviceResponse struct {
+	ID     int64  `json:"id"`
+	Device string `json:"device"`
+}
+
+func TestTrustedDevice_SkipsSecondFactor(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+
+	status, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, status)
+
+	secret := enrollTOTP(t, st, login.AccessToken).Secret
+	step := currentTOTPStep()
+
+	status = bearerPostForm(t, st, login.AccessToken, "/mfa/totp/verify", url.Values{
+		"code": {totpCode(t, secret, step-1)},
+	})
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, challenge := requestTokenOnDevice(t, st, firefoxUserAgent, "", loginForm)
+	require.Equal(t, http.StatusForbidden, status)
+
+	status, mfaLogin := requestTokenOnDevice(t, st, firefoxUserAgent, "", url.Values{
+		"grant_type":      {grantTypeMFAOTP},
+		"mfa_token":       {challenge.MFAToken},
+		"otp":             {totpCode(t, secret, step)},
+		"remember_device": {"true"},
+	})
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, mfaLogin.DeviceToken)
+
+	// the device token skips the second factor on the same device only
+	status, trusted := requestTokenOnDevice(t, st, firefoxUserAgent, mfaLogin.DeviceToken, loginForm)
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, trusted.AccessToken)
+	assert.Equal(t, []interface{}{"pwd"}, parseIDToken(t, trusted.IDToken)["amr"])
+
+	status, _ = requestTokenOnDevice(t, st, otherUserAgent, mfaLogin.DeviceToken, loginForm)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	devices := listTrustedDevices(t, st, trusted.AccessToken)
+	require.Len(t, devices, 1)
+	assert.Equal(t, "Firefox on Linux", devices[0].Device)
+
+	devicePath := "/trusted-devices/" + strconv.FormatInt(devices[0].ID, 10)
+
+	code, _ := adminRequest(t, st, trusted.AccessToken, http.MethodDelete, devicePath, nil)
+	require.Equal(t, http.StatusNoContent, code)
+
+	status, _ = requestTokenOnDevice(t, st, firefoxUserAgent, mfaLogin.DeviceToken, loginForm)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	code, _ = adminRequest(t, st, trusted.AccessToken, http.MethodDelete, devicePath, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestTrustedDevice_NotRemembered(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	loginForm := url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	}
+
+	status, login := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusOK, status)
+
+	secret := enrollTOTP(t, st, login.AccessToken).Secret
+	step := currentTOTPStep()
+
+	status = bearerPostForm(t, st, login.AccessToken, "/mfa/totp/verify", url.Values{
+		"code": {totpCode(t, secret, step-1)},
+	})
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, challenge := requestToken(t, st, loginForm)
+	require.Equal(t, http.StatusForbidden, status)
+
+	status, mfaLogin := requestToken(t, st, url.Values{
+		"grant_type": {grantTypeMFAOTP},
+		"mfa_token":  {challenge.MFAToken},
+		"otp":        {totpCode(t, secret, step)},
+	})
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, mfaLogin.DeviceToken)
+	assert.Empty(t, listTrustedDevices(t, st, mfaLogin.AccessToken))
+
+	status, _ = requestTokenOnDevice(t, st, firefoxUserAgent, "not-a-device-token", loginForm)
+	assert.Equal(t, http.StatusForbidden, status)
+}
+
+func requestTokenOnDevice(
+	t *testing.T,
+	st *suite.Suite,
+	userAgent string,
+	deviceToken string,
+	form url.Values,
+) (int, tokenResponse) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, st.HTTPURL+"/token", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", userAgent)
+	if deviceToken != "" {
+		req.Header.Set("X-Device-Token", deviceToken)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	return resp.StatusCode, body
+}
+
+func listTrustedDevices(t *testing.T, st *suite.Suite, token string) []trustedDeviceResponse {
+	t.Helper()
+
+	code, body := adminRequest(t, st, token, http.MethodGet, "/trusted-devices", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var resp struct {
+		Devices []trustedDeviceResponse `json:"devices"`
+	}
+	require.NoError(t, json.Unmarshal(body, &resp))
+
+	return resp.Devices
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type batchResult struct {
+	User  *userResponse `json:"user"`
+	ID    int64         `json:"id"`
+	Error string        `json:"error"`
+}
+
+func TestBatchGetUsers(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	var ids []int64
+	emails := make(map[int64]string)
+	for range 3 {
+		email := gofakeit.Email()
+		respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+			Email:    email,
+			Password: randomFakePassword(),
+		})
+		require.NoError(t, err)
+
+		ids = append(ids, respReg.GetUserId())
+		emails[respReg.GetUserId()] = email
+	}
+
+	const unknownID = 999999999
+	admin := adminToken(t, st)
+
+	code, body := adminRequest(t, st, admin, http.MethodPost, "/admin/users/batch-get", map[string]any{
+		// duplicates are resolved once
+		"ids": []int64{ids[2], ids[0], unknownID, ids[1], ids[0]},
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	var results []batchResult
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		var result batchResult
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &result))
+		results = append(results, result)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, results, 4)
+
+	for i, id := range []int64{ids[2], ids[0], ids[1]} {
+		require.NotNil(t, results[i].User)
+		assert.Equal(t, id, results[i].User.ID)
+		assert.Equal(t, emails[id], results[i].User.Email)
+	}
+	assert.Nil(t, results[3].User)
+	assert.Equal(t, int64(unknownID), results[3].ID)
+	assert.Equal(t, "not_found", results[3].Error)
+}
+
+func TestBatchGetUsers_FailCases(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	tooMany := make([]int64, 10001)
+	for i := range tooMany {
+		tooMany[i] = int64(i + 1)
+	}
+
+	tests := []struct {
+		name string
+		body any
+		code int
+	}{
+		{
+			name: "No ids",
+			body: map[string]any{"ids": []int64{}},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "Too many ids",
+			body: map[string]any{"ids": tooMany},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "Malformed body",
+			body: "ids",
+			code: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := adminRequest(t, st, admin, http.MethodPost, "/admin/users/batch-get", tt.body)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+
+	code, _ := adminRequest(t, st, "", http.MethodPost, "/admin/users/batch-get", map[string]any{"ids": []int64{1}})
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type userExportResponse struct {
+	User     userResponse `json:"user"`
+	Sessions []struct {
+		ID    int64 `json:"id"`
+		AppID int   `json:"app_id"`
+	} `json:"sessions"`
+	Consents    []json.RawMessage `json:"consents"`
+	Identities  []json.RawMessage `json:"identities"`
+	AuditEvents []struct {
+		Type    string `json:"type"`
+		ActorID int64  `json:"actor_id"`
+	} `json:"audit_events"`
+}
+
+func TestExportUserData(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	code, _ := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	admin := adminToken(t, st)
+	userPath := "/admin/users/" + strconv.FormatInt(respReg.GetUserId(), 10)
+
+	// the export covers suspended users too
+	code, _ = adminRequest(t, st, admin, http.MethodPost, userPath+"/suspend", nil)
+	require.Equal(t, http.StatusNoContent, code)
+	code, _ = adminRequest(t, st, admin, http.MethodPost, userPath+"/unsuspend", nil)
+	require.Equal(t, http.StatusNoContent, code)
+
+	code, _ = requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	code, body := adminRequest(t, st, admin, http.MethodGet, userPath+"/export", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var export userExportResponse
+	require.NoError(t, json.Unmarshal(body, &export))
+
+	assert.Equal(t, respReg.GetUserId(), export.User.ID)
+	assert.Equal(t, email, export.User.Email)
+	require.Len(t, export.Sessions, 1)
+	assert.Equal(t, appID, export.Sessions[0].AppID)
+	assert.NotNil(t, export.Consents)
+	assert.NotNil(t, export.Identities)
+
+	var types []string
+	for _, event := range export.AuditEvents {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{
+		"login_succeeded", "user_suspended", "user_unsuspended", "login_succeeded", "user_data_exported",
+	}, types)
+	assert.NotZero(t, export.AuditEvents[4].ActorID)
+}
+
+func TestExportUserData_FailCases(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	code, _ := adminRequest(t, st, admin, http.MethodGet, "/admin/users/999999999/export", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = adminRequest(t, st, admin, http.MethodGet, "/admin/users/abc/export", nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = adminRequest(t, st, "", http.MethodGet, "/admin/users/1/export", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sso/tests/suite"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type importResult struct {
+	Line             int    `json:"line"`
+	Email            string `json:"email"`
+	UserID           int64  `json:"user_id"`
+	PasswordReset    bool   `json:"password_reset"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func TestImportUsers(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	bcryptEmail := gofakeit.Email()
+	bcryptPass := randomFakePassword()
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(bcryptPass), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	argonEmail := gofakeit.Email()
+	argonPass := randomFakePassword()
+
+	resetEmail := gofakeit.Email()
+
+	results := importUsers(t, st, admin,
+		fmt.Sprintf(`{"email":%q,"password_hash":%q,"email_verified":true,"display_name":"Imported"}`, bcryptEmail, bcryptHash),
+		fmt.Sprintf(`{"email":%q,"password_hash":%q}`, argonEmail, argon2idHash(t, argonPass)),
+		"",
+		fmt.Sprintf(`{"email":%q,"app_metadata":{"plan":"pro"}}`, resetEmail),
+	)
+	require.Len(t, results, 3)
+
+	assert.Equal(t, 1, results[0].Line)
+	assert.Equal(t, 2, results[1].Line)
+	assert.Equal(t, 4, results[2].Line)
+	for _, result := range results {
+		assert.Empty(t, result.Error, result.ErrorDescription)
+		assert.NotZero(t, result.UserID)
+	}
+	assert.False(t, results[0].PasswordReset)
+	assert.True(t, results[2].PasswordReset)
+
+	require.NoError(t, passwordLogin(ctx, st, bcryptEmail, bcryptPass))
+	require.NoError(t, passwordLogin(ctx, st, argonEmail, argonPass))
+	assert.Error(t, passwordLogin(ctx, st, argonEmail, randomFakePassword()))
+
+	code, body := adminRequest(t, st, admin, http.MethodGet, "/admin/users/"+strconv.FormatInt(results[0].UserID, 10), nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var user userResponse
+	require.NoError(t, json.Unmarshal(body, &user))
+	assert.Equal(t, "Imported", user.DisplayName)
+	assert.True(t, user.EmailVerified)
+
+	// users without a hash set a password through the reset link
+	newPass := randomFakePassword()
+	code = postForm(t, st, "/password/reset/confirm", url.Values{
+		"token":    {emailToken(t, st, resetEmail)},
+		"password": {newPass},
+	})
+	require.Equal(t, http.StatusNoContent, code)
+	require.NoError(t, passwordLogin(ctx, st, resetEmail, newPass))
+
+	code, body = adminRequest(t, st, admin, http.MethodGet,
+		"/admin/audit-events?type=user_imported&user_id="+strconv.FormatInt(results[1].UserID, 10), nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var events auditEventsResponse
+	require.NoError(t, json.Unmarshal(body, &events))
+	require.Len(t, events.Events, 1)
+	assert.NotZero(t, events.Events[0].ActorID)
+}
+
+func TestImportUsers_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+	hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	results := importUsers(t, st, admin,
+		fmt.Sprintf(`{"email":%q,"password_hash":%q}`, email, hash),
+		fmt.Sprintf(`{"email":%q}`, email),
+		`{"email":`,
+		fmt.Sprintf(`{"email":%q,"password_hash":"md5:5f4dcc3b5aa765d61d8327deb882cf99"}`, gofakeit.Email()),
+		`{"email":"not an email"}`,
+		fmt.Sprintf(`{"email":%q,"password_hash":"$argon2id$v=19$m=4194304,t=3,p=4$c2FsdHNhbHQ$aGFzaGhhc2g"}`, gofakeit.Email()),
+	)
+	require.Len(t, results, 6)
+
+	// the failures do not stop the import
+	assert.Empty(t, results[0].Error)
+	assert.Equal(t, "user_exists", results[1].Error)
+	for _, result := range results[2:] {
+		assert.Equal(t, "invalid_request", result.Error, "line %d", result.Line)
+		assert.NotEmpty(t, result.ErrorDescription, "line %d", result.Line)
+	}
+	assert.Equal(t, 3, results[2].Line)
+
+	// the existing user keeps the password
+	require.NoError(t, passwordLogin(ctx, st, email, pass))
+
+	code, _ := adminRequest(t, st, "", http.MethodPost, "/admin/users/import", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+// importUsers posts the records as the lines of an import and returns the
+// result lines.
+func importUsers(t *testing.T, st *suite.Suite, token string, records ...string) []importResult {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost,
+		st.HTTPURL+"/admin/users/import", strings.NewReader(strings.Join(records, "\n")))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var results []importResult
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var result importResult
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &result))
+		results = append(results, result)
+	}
+	require.NoError(t, scanner.Err())
+
+	return results
+}
+
+func argon2idHash(t *testing.T, password string) string {
+	t.Helper()
+
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	require.NoError(t, err)
+
+	key := argon2.IDKey([]byte(password), salt, 1, 64*1024, 2, 32)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, 64*1024, 1, 2,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
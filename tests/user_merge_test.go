@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeUsers(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	primary, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	duplicateEmail := gofakeit.Email()
+	duplicatePass := randomFakePassword()
+	duplicate, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    duplicateEmail,
+		Password: duplicatePass,
+	})
+	require.NoError(t, err)
+
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {duplicateEmail},
+		"password":   {duplicatePass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	admin := adminToken(t, st)
+	primaryPath := "/admin/users/" + strconv.FormatInt(primary.GetUserId(), 10)
+	duplicatePath := "/admin/users/" + strconv.FormatInt(duplicate.GetUserId(), 10)
+
+	status, body := adminRequest(t, st, admin, http.MethodPost, primaryPath+"/merge", map[string]any{
+		"duplicate_id": duplicate.GetUserId(),
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	var merged userResponse
+	require.NoError(t, json.Unmarshal(body, &merged))
+	assert.Equal(t, primary.GetUserId(), merged.ID)
+
+	status, body = adminRequest(t, st, admin, http.MethodGet, duplicatePath, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var deleted struct {
+		DeletedAt *string `json:"deleted_at"`
+	}
+	require.NoError(t, json.Unmarshal(body, &deleted))
+	assert.NotNil(t, deleted.DeletedAt)
+
+	// the session of the duplicate goes on as the primary user
+	status, refreshed := requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	claims := jwt.MapClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(refreshed.AccessToken, claims)
+	require.NoError(t, err)
+	assert.Equal(t, primary.GetUserId(), int64(claims["uid"].(float64)))
+
+	// the duplicate can no longer log in
+	require.Error(t, passwordLogin(ctx, st, duplicateEmail, duplicatePass))
+
+	status, body = adminRequest(t, st, admin, http.MethodGet,
+		"/admin/audit-events?type=user_merged&user_id="+strconv.FormatInt(primary.GetUserId(), 10), nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var events auditEventsResponse
+	require.NoError(t, json.Unmarshal(body, &events))
+	require.Len(t, events.Events, 1)
+}
+
+func TestMergeUsers_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	user, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	userPath := "/admin/users/" + strconv.FormatInt(user.GetUserId(), 10)
+
+	tests := []struct {
+		name     string
+		path     string
+		body     any
+		expected int
+	}{
+		{
+			name:     "Into itself",
+			path:     userPath + "/merge",
+			body:     map[string]any{"duplicate_id": user.GetUserId()},
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "No duplicate",
+			path:     userPath + "/merge",
+			body:     map[string]any{},
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "Unknown duplicate",
+			path:     userPath + "/merge",
+			body:     map[string]any{"duplicate_id": 999999999},
+			expected: http.StatusNotFound,
+		},
+		{
+			name:     "Unknown primary",
+			path:     "/admin/users/999999999/merge",
+			body:     map[string]any{"duplicate_id": user.GetUserId()},
+			expected: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _ := adminRequest(t, st, admin, http.MethodPost, tt.path, tt.body)
+			assert.Equal(t, tt.expected, status)
+		})
+	}
+}
@@ -0,0 +1,634 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type userResponse struct {
+	ID            int64      `json:"id"`
+	Email         string     `json:"email"`
+	Username      string     `json:"username"`
+	DisplayName   string     `json:"display_name"`
+	FirstName     string     `json:"first_name"`
+	LastName      string     `json:"last_name"`
+	Locale        string     `json:"locale"`
+	AvatarURL     string     `json:"avatar_url"`
+	EmailVerified bool       `json:"email_verified"`
+	IsAdmin       bool       `json:"is_admin"`
+	OrgID         int64      `json:"org_id"`
+	Roles         []string   `json:"roles"`
+	DeletedAt     *time.Time `json:"deleted_at"`
+	CreatedAt     *time.Time `json:"created_at"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+	ETag          string     `json:"etag"`
+}
+
+func TestUser_Get(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	userPath := "/admin/users/" + strconv.FormatInt(respReg.GetUserId(), 10)
+
+	code, body := adminRequest(t, st, admin, http.MethodGet, userPath, nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var user userResponse
+	require.NoError(t, json.Unmarshal(body, &user))
+	assert.Equal(t, respReg.GetUserId(), user.ID)
+	assert.Equal(t, email, user.Email)
+	assert.False(t, user.EmailVerified)
+	assert.False(t, user.IsAdmin)
+	assert.Empty(t, user.Roles)
+	require.NotNil(t, user.CreatedAt)
+	assert.WithinDuration(t, time.Now(), *user.CreatedAt, time.Minute)
+	require.NotNil(t, user.UpdatedAt)
+
+	// emails are matched case-insensitively
+	code, body = adminRequest(t, st, admin, http.MethodGet, "/admin/users/"+url.PathEscape(strings.ToUpper(email)), nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var byEmail userResponse
+	require.NoError(t, json.Unmarshal(body, &byEmail))
+	assert.Equal(t, user.ID, byEmail.ID)
+
+	code, body = adminRequest(t, st, admin, http.MethodGet, "/admin/users/admin@sso.test", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var adminUser userResponse
+	require.NoError(t, json.Unmarshal(body, &adminUser))
+	assert.True(t, adminUser.IsAdmin)
+	assert.Equal(t, []string{"admin"}, adminUser.Roles)
+}
+
+func TestUser_Get_FailCases(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	tests := []struct {
+		name string
+		path string
+		code int
+	}{
+		{
+			name: "Unknown id",
+			path: "/admin/users/999999999",
+			code: http.StatusNotFound,
+		},
+		{
+			name: "Unknown email",
+			path: "/admin/users/" + gofakeit.Email(),
+			code: http.StatusNotFound,
+		},
+		{
+			name: "Invalid id",
+			path: "/admin/users/abc",
+			code: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := adminRequest(t, st, admin, http.MethodGet, tt.path, nil)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+
+	code, _ := adminRequest(t, st, "invalid-token", http.MethodGet, "/admin/users/1", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestUser_Update(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	userID := respReg.GetUserId()
+
+	code, body := adminRequest(t, st, admin, http.MethodGet, "/admin/users/"+strconv.FormatInt(userID, 10), nil)
+	require.Equal(t, http.StatusOK, code)
+	var user userResponse
+	require.NoError(t, json.Unmarshal(body, &user))
+	require.NotEmpty(t, user.ETag)
+
+	// fields outside of the mask are left alone
+	code, updated := updateUser(t, st, admin, userID, user.ETag, map[string]any{
+		"update_mask":  "display_name",
+		"display_name": "  Jane Doe ",
+		"email":        gofakeit.Email(),
+	})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Jane Doe", updated.DisplayName)
+	assert.Equal(t, email, updated.Email)
+	assert.NotEqual(t, user.ETag, updated.ETag)
+
+	// the etag read before the update is stale
+	code, _ = updateUser(t, st, admin, userID, user.ETag, map[string]any{
+		"update_mask":  "display_name",
+		"display_name": "John Doe",
+	})
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+
+	newEmail := gofakeit.Email()
+	name := "user" + strconv.FormatInt(userID, 10)
+	code, updated = updateUser(t, st, admin, userID, updated.ETag, map[string]any{
+		"update_mask": "email,username",
+		"email":       newEmail,
+		"username":    strings.ToUpper(name),
+	})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, newEmail, updated.Email)
+	assert.Equal(t, name, updated.Username)
+	assert.False(t, updated.EmailVerified)
+	assert.Equal(t, "Jane Doe", updated.DisplayName)
+
+	code, updated = updateUser(t, st, admin, userID, updated.ETag, map[string]any{
+		"update_mask": "first_name,last_name,locale,avatar_url",
+		"first_name":  " Jane",
+		"last_name":   "Doe ",
+		"locale":      "en_us",
+		"avatar_url":  "https://cdn.example.com/avatars/jane.png",
+	})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Jane", updated.FirstName)
+	assert.Equal(t, "Doe", updated.LastName)
+	assert.Equal(t, "en-US", updated.Locale)
+	assert.Equal(t, "https://cdn.example.com/avatars/jane.png", updated.AvatarURL)
+
+	// without an etag the update applies to whatever version there is
+	code, _ = updateUser(t, st, admin, userID, "", map[string]any{
+		"update_mask": "username",
+		"username":    "",
+	})
+	require.Equal(t, http.StatusOK, code)
+}
+
+func TestUser_Update_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	taken := gofakeit.Email()
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    taken,
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    gofakeit.Email(),
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+
+	tests := []struct {
+		name   string
+		userID int64
+		body   map[string]any
+		code   int
+	}{
+		{
+			name:   "Empty mask",
+			userID: respReg.GetUserId(),
+			body:   map[string]any{"display_name": "Jane"},
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "Unknown field",
+			userID: respReg.GetUserId(),
+			body:   map[string]any{"update_mask": "is_admin", "is_admin": true},
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "Invalid email",
+			userID: respReg.GetUserId(),
+			body:   map[string]any{"update_mask": "email", "email": "not-an-email"},
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "Invalid locale",
+			userID: respReg.GetUserId(),
+			body:   map[string]any{"update_mask": "locale", "locale": "not a locale"},
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "Insecure avatar url",
+			userID: respReg.GetUserId(),
+			body:   map[string]any{"update_mask": "avatar_url", "avatar_url": "http://example.com/a.png"},
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "Long first name",
+			userID: respReg.GetUserId(),
+			body:   map[string]any{"update_mask": "first_name", "first_name": strings.Repeat("a", 65)},
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "Taken email",
+			userID: respReg.GetUserId(),
+			body:   map[string]any{"update_mask": "email", "email": taken},
+			code:   http.StatusConflict,
+		},
+		{
+			name:   "Unknown user",
+			userID: 999999999,
+			body:   map[string]any{"update_mask": "display_name", "display_name": "Jane"},
+			code:   http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := updateUser(t, st, admin, tt.userID, "", tt.body)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+}
+
+type usersResponse struct {
+	Users         []userResponse `json:"users"`
+	NextPageToken string         `json:"next_page_token"`
+}
+
+func TestUser_List(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	prefix := "list-" + strings.ToLower(gofakeit.LetterN(12))
+	var emails []string
+	for i := range 3 {
+		email := prefix + "-" + strconv.Itoa(i) + "@example.com"
+		_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+			Email:    email,
+			Password: randomFakePassword(),
+		})
+		require.NoError(t, err)
+		emails = append(emails, email)
+	}
+
+	admin := adminToken(t, st)
+
+	listUsers := func(query url.Values) usersResponse {
+		t.Helper()
+
+		code, body := adminRequest(t, st, admin, http.MethodGet, "/admin/users?"+query.Encode(), nil)
+		require.Equal(t, http.StatusOK, code)
+
+		var resp usersResponse
+		require.NoError(t, json.Unmarshal(body, &resp))
+
+		return resp
+	}
+
+	// the prefix matches case-insensitively
+	query := url.Values{
+		"email_prefix": {strings.ToUpper(prefix)},
+		"sort":         {"email"},
+		"page_size":    {"2"},
+	}
+	first := listUsers(query)
+	require.Len(t, first.Users, 2)
+	assert.Equal(t, emails[0], first.Users[0].Email)
+	assert.Equal(t, emails[1], first.Users[1].Email)
+	require.NotEmpty(t, first.NextPageToken)
+
+	query.Set("page_token", first.NextPageToken)
+	second := listUsers(query)
+	require.Len(t, second.Users, 1)
+	assert.Equal(t, emails[2], second.Users[0].Email)
+	assert.Empty(t, second.NextPageToken)
+
+	desc := listUsers(url.Values{"email_prefix": {prefix}, "sort": {"-created_at"}})
+	require.Len(t, desc.Users, 3)
+	assert.Equal(t, emails[2], desc.Users[0].Email)
+	assert.Equal(t, emails[0], desc.Users[2].Email)
+
+	verified := listUsers(url.Values{"email_prefix": {prefix}, "email_verified": {"true"}})
+	assert.Empty(t, verified.Users)
+
+	admins := listUsers(url.Values{"email_prefix": {adminEmail}, "admin": {"true"}})
+	require.Len(t, admins.Users, 1)
+	assert.True(t, admins.Users[0].IsAdmin)
+
+	future := listUsers(url.Values{
+		"email_prefix":  {prefix},
+		"created_after": {time.Now().Add(time.Hour).Format(time.RFC3339)},
+	})
+	assert.Empty(t, future.Users)
+}
+
+func TestUser_List_FailCases(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	code, body := adminRequest(t, st, admin, http.MethodGet, "/admin/users?page_size=1&sort=email", nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var page usersResponse
+	require.NoError(t, json.Unmarshal(body, &page))
+	require.NotEmpty(t, page.NextPageToken)
+
+	tests := []struct {
+		name  string
+		query url.Values
+	}{
+		{
+			name:  "Unknown sort",
+			query: url.Values{"sort": {"password"}},
+		},
+		{
+			name:  "Invalid page size",
+			query: url.Values{"page_size": {"0"}},
+		},
+		{
+			name:  "Invalid created_after",
+			query: url.Values{"created_after": {"yesterday"}},
+		},
+		{
+			name:  "Malformed page token",
+			query: url.Values{"page_token": {"%%%"}},
+		},
+		{
+			name:  "Page token of another sort",
+			query: url.Values{"sort": {"-email"}, "page_token": {page.NextPageToken}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := adminRequest(t, st, admin, http.MethodGet, "/admin/users?"+tt.query.Encode(), nil)
+			assert.Equal(t, http.StatusBadRequest, code)
+		})
+	}
+}
+
+func TestUser_Search(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	word := strings.ToLower(gofakeit.LetterN(12))
+	email := word + ".search@example.com"
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: randomFakePassword(),
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+
+	name := "u" + strings.ToLower(gofakeit.LetterN(12))
+	display := "Zed " + strings.ToUpper(word[:1]) + gofakeit.LetterN(10)
+	code, _ := updateUser(t, st, admin, respReg.GetUserId(), "", map[string]any{
+		"update_mask":  "username,display_name",
+		"username":     name,
+		"display_name": display,
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	search := func(q string) usersResponse {
+		t.Helper()
+
+		code, body := adminRequest(t, st, admin, http.MethodGet, "/admin/users/search?"+url.Values{"q": {q}}.Encode(), nil)
+		require.Equal(t, http.StatusOK, code)
+
+		var resp usersResponse
+		require.NoError(t, json.Unmarshal(body, &resp))
+
+		return resp
+	}
+
+	for _, q := range []string{
+		word[:6],                       // email prefix
+		strings.ToUpper(name[:8]),      // username prefix, case-insensitive
+		display,                        // display name words
+		"zed " + word[:4] + " example", // words of different fields
+	} {
+		resp := search(q)
+		require.Len(t, resp.Users, 1, q)
+		assert.Equal(t, email, resp.Users[0].Email)
+	}
+
+	assert.Empty(t, search(word+"x").Users)
+	assert.Empty(t, search("@@@").Users)
+
+	code, _ = adminRequest(t, st, admin, http.MethodGet, "/admin/users/search?q=+", nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestUser_Delete(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	code, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	admin := adminToken(t, st)
+	userPath := "/admin/users/" + strconv.FormatInt(respReg.GetUserId(), 10)
+
+	code, _ = adminRequest(t, st, admin, http.MethodDelete, userPath, nil)
+	require.Equal(t, http.StatusNoContent, code)
+
+	// the sessions of the user end right away
+	code, _ = adminRequest(t, st, login.AccessToken, http.MethodGet, "/sessions", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = requestToken(t, st, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {login.RefreshToken},
+	})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	assert.Error(t, passwordLogin(ctx, st, email, pass))
+
+	code, body := adminRequest(t, st, admin, http.MethodGet, userPath, nil)
+	require.Equal(t, http.StatusOK, code)
+
+	// the user is kept until it is purged, so that it can be restored
+	var user userResponse
+	require.NoError(t, json.Unmarshal(body, &user))
+	assert.Equal(t, email, user.Email)
+	require.NotNil(t, user.DeletedAt)
+	assert.WithinDuration(t, time.Now(), *user.DeletedAt, time.Minute)
+
+	// the email is not free until then
+	_, err = st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: randomFakePassword(),
+	})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	code, body = adminRequest(t, st, admin, http.MethodGet, "/admin/users/"+adminEmail, nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var adminUser userResponse
+	require.NoError(t, json.Unmarshal(body, &adminUser))
+
+	code, body = adminRequest(t, st, admin, http.MethodGet,
+		"/admin/audit-events?type=user_deleted&user_id="+strconv.FormatInt(respReg.GetUserId(), 10), nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var events auditEventsResponse
+	require.NoError(t, json.Unmarshal(body, &events))
+	require.Len(t, events.Events, 1)
+	assert.Equal(t, adminUser.ID, events.Events[0].ActorID)
+
+	// deleted users can not be deleted again
+	code, _ = adminRequest(t, st, admin, http.MethodDelete, userPath, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestUser_Restore(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	admin := adminToken(t, st)
+	userPath := "/admin/users/" + strconv.FormatInt(respReg.GetUserId(), 10)
+
+	// only deleted users can be restored
+	code, _ := adminRequest(t, st, admin, http.MethodPost, userPath+"/restore", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = adminRequest(t, st, admin, http.MethodDelete, userPath, nil)
+	require.Equal(t, http.StatusNoContent, code)
+	require.Error(t, passwordLogin(ctx, st, email, pass))
+
+	code, _ = adminRequest(t, st, admin, http.MethodPost, userPath+"/restore", nil)
+	require.Equal(t, http.StatusNoContent, code)
+
+	assert.NoError(t, passwordLogin(ctx, st, email, pass))
+
+	code, body := adminRequest(t, st, admin, http.MethodGet, userPath, nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var user userResponse
+	require.NoError(t, json.Unmarshal(body, &user))
+	assert.Nil(t, user.DeletedAt)
+
+	code, body = adminRequest(t, st, admin, http.MethodGet,
+		"/admin/audit-events?type=user_restored&user_id="+strconv.FormatInt(respReg.GetUserId(), 10), nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var events auditEventsResponse
+	require.NoError(t, json.Unmarshal(body, &events))
+	assert.Len(t, events.Events, 1)
+}
+
+func TestUser_Delete_FailCases(t *testing.T) {
+	_, st := suite.New(t)
+
+	admin := adminToken(t, st)
+
+	code, body := adminRequest(t, st, admin, http.MethodGet, "/admin/users/"+adminEmail, nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var adminUser userResponse
+	require.NoError(t, json.Unmarshal(body, &adminUser))
+
+	tests := []struct {
+		name string
+		path string
+		code int
+	}{
+		{
+			name: "Self",
+			path: "/admin/users/" + strconv.FormatInt(adminUser.ID, 10),
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "Invalid id",
+			path: "/admin/users/abc",
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "Unknown user",
+			path: "/admin/users/999999999",
+			code: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := adminRequest(t, st, admin, http.MethodDelete, tt.path, nil)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+}
+
+func updateUser(t *testing.T, st *suite.Suite, token string, userID int64, etag string, body any) (int, userResponse) {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPatch, st.HTTPURL+"/admin/users/"+strconv.FormatInt(userID, 10), bytes.NewReader(data))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var user userResponse
+	if resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&user))
+		assert.Equal(t, user.ETag, resp.Header.Get("ETag"))
+	}
+
+	return resp.StatusCode, user
+}
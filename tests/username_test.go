@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsername_Login(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+	name := "user_" + strings.ToLower(gofakeit.LetterN(12))
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	status, login := requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {email},
+		"password":   {pass},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	status = bearerPostForm(t, st, login.AccessToken, "/username", url.Values{
+		"username": {strings.ToUpper(name)},
+	})
+	require.Equal(t, http.StatusNoContent, status)
+
+	// usernames and emails are trimmed and matched case-insensitively
+	for _, identifier := range []string{name, "  " + strings.ToUpper(name) + " ", strings.ToUpper(email)} {
+		status, byName := requestToken(t, st, url.Values{
+			"grant_type": {"password"},
+			"username":   {identifier},
+			"password":   {pass},
+			"client_id":  {strconv.Itoa(appID)},
+		})
+		require.Equal(t, http.StatusOK, status, identifier)
+		assert.Equal(t, name, parseIDToken(t, byName.IDToken)["preferred_username"])
+
+		respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+			Email:    identifier,
+			Password: pass,
+			AppId:    appID,
+		})
+		require.NoError(t, err, identifier)
+		assert.NotEmpty(t, respLogin.GetToken())
+	}
+
+	status, _ = requestToken(t, st, url.Values{
+		"grant_type": {"password"},
+		"username":   {name},
+		"password":   {randomFakePassword()},
+		"client_id":  {strconv.Itoa(appID)},
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestUsername_Taken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	name := "user_" + strings.ToLower(gofakeit.LetterN(12))
+
+	var tokens []string
+	for range 2 {
+		email := gofakeit.Email()
+		pass := randomFakePassword()
+
+		_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+			Email:    email,
+			Password: pass,
+		})
+		require.NoError(t, err)
+
+		status, login := requestToken(t, st, url.Values{
+			"grant_type": {"password"},
+			"username":   {email},
+			"password":   {pass},
+			"client_id":  {strconv.Itoa(appID)},
+		})
+		require.Equal(t, http.StatusOK, status)
+		tokens = append(tokens, login.AccessToken)
+	}
+
+	status := bearerPostForm(t, st, tokens[0], "/username", url.Values{"username": {name}})
+	require.Equal(t, http.StatusNoContent, status)
+
+	status = bearerPostForm(t, st, tokens[1], "/username", url.Values{"username": {strings.ToUpper(name)}})
+	assert.Equal(t, http.StatusConflict, status)
+
+	for _, invalid := range []string{"ab", "_" + name, "user@example.com", "user name"} {
+		status = bearerPostForm(t, st, tokens[1], "/username", url.Values{"username": {invalid}})
+		assert.Equal(t, http.StatusBadRequest, status, invalid)
+	}
+}
@@ -0,0 +1,278 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"sso/tests/suite"
+
+	ssov1 "github.com/SamEkb/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webAuthnOrigin and webAuthnRPID match the webauthn section of config/local.yml.
+const (
+	webAuthnOrigin = "http://localhost:8082"
+	webAuthnRPID   = "localhost"
+)
+
+type webAuthnOptionsResponse struct {
+	Session string `json:"session"`
+	Options struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			User      struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	} `json:"options"`
+}
+
+func TestWebAuthn_RegisterAndLogin(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	respReg, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	authenticator := newSoftAuthenticator(t)
+
+	registration := webAuthnBegin(t, st, respLogin.GetToken(), "/webauthn/register/begin", nil)
+	credential := authenticator.create(t, registration)
+
+	status, _ := adminRequest(t, st, respLogin.GetToken(), http.MethodPost, "/webauthn/register/finish", map[string]any{
+		"session":    registration.Session,
+		"credential": credential,
+	})
+	require.Equal(t, http.StatusNoContent, status)
+
+	// a ceremony can be finished once
+	status, _ = adminRequest(t, st, respLogin.GetToken(), http.MethodPost, "/webauthn/register/finish", map[string]any{
+		"session":    registration.Session,
+		"credential": credential,
+	})
+	require.Equal(t, http.StatusBadRequest, status)
+
+	login := webAuthnBegin(t, st, "", "/webauthn/login/begin", map[string]any{"client_id": appID})
+	assertion := authenticator.get(t, login, false)
+
+	status, body := adminRequest(t, st, "", http.MethodPost, "/webauthn/login/finish", map[string]any{
+		"session":    login.Session,
+		"credential": assertion,
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	var tokens tokenResponse
+	require.NoError(t, json.Unmarshal(body, &tokens))
+	require.NotEmpty(t, tokens.AccessToken)
+	require.NotEmpty(t, tokens.RefreshToken)
+
+	claims := parseIDToken(t, tokens.IDToken)
+	assert.Equal(t, strconv.FormatInt(respReg.GetUserId(), 10), claims["sub"])
+	assert.Equal(t, []interface{}{"hwk", "user"}, claims["amr"])
+
+	status, _ = adminRequest(t, st, "", http.MethodPost, "/webauthn/login/finish", map[string]any{
+		"session":    login.Session,
+		"credential": assertion,
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestWebAuthn_InvalidSignature(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	pass := randomFakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: pass,
+	})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: pass,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	authenticator := newSoftAuthenticator(t)
+
+	registration := webAuthnBegin(t, st, respLogin.GetToken(), "/webauthn/register/begin", nil)
+	status, _ := adminRequest(t, st, respLogin.GetToken(), http.MethodPost, "/webauthn/register/finish", map[string]any{
+		"session":    registration.Session,
+		"credential": authenticator.create(t, registration),
+	})
+	require.Equal(t, http.StatusNoContent, status)
+
+	login := webAuthnBegin(t, st, "", "/webauthn/login/begin", map[string]any{"client_id": appID})
+	status, _ = adminRequest(t, st, "", http.MethodPost, "/webauthn/login/finish", map[string]any{
+		"session":    login.Session,
+		"credential": authenticator.get(t, login, true),
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = adminRequest(t, st, "", http.MethodPost, "/webauthn/login/begin", map[string]any{"client_id": 999})
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func webAuthnBegin(t *testing.T, st *suite.Suite, token string, path string, body any) webAuthnOptionsResponse {
+	t.Helper()
+
+	status, respBody := adminRequest(t, st, token, http.MethodPost, path, body)
+	require.Equal(t, http.StatusOK, status)
+
+	var options webAuthnOptionsResponse
+	require.NoError(t, json.Unmarshal(respBody, &options))
+	require.NotEmpty(t, options.Session)
+	require.NotEmpty(t, options.Options.PublicKey.Challenge)
+
+	return options
+}
+
+// softAuthenticator plays a platform authenticator holding one passkey, with
+// "none" attestation.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   string
+	counter      uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	credentialID := make([]byte, 16)
+	_, err = rand.Read(credentialID)
+	require.NoError(t, err)
+
+	return &softAuthenticator{key: key, credentialID: credentialID}
+}
+
+// authenticator data flags
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+func (a *softAuthenticator) create(t *testing.T, options webAuthnOptionsResponse) map[string]any {
+	t.Helper()
+
+	a.userHandle = options.Options.PublicKey.User.ID
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	require.NoError(t, err)
+
+	authData := a.authData(flagUserPresent | flagUserVerified | flagAttested)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, publicKey...)
+
+	attestationObject, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	require.NoError(t, err)
+
+	return map[string]any{
+		"id":    b64(a.credentialID),
+		"rawId": b64(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    b64(clientData(t, "webauthn.create", options.Options.PublicKey.Challenge)),
+			"attestationObject": b64(attestationObject),
+		},
+	}
+}
+
+func (a *softAuthenticator) get(t *testing.T, options webAuthnOptionsResponse, corrupt bool) map[string]any {
+	t.Helper()
+
+	a.counter++
+	authData := a.authData(flagUserPresent | flagUserVerified)
+	clientDataJSON := clientData(t, "webauthn.get", options.Options.PublicKey.Challenge)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	if corrupt {
+		digest[0] ^= 0xff
+	}
+
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	require.NoError(t, err)
+
+	return map[string]any{
+		"id":    b64(a.credentialID),
+		"rawId": b64(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    b64(clientDataJSON),
+			"authenticatorData": b64(authData),
+			"signature":         b64(signature),
+			"userHandle":        a.userHandle,
+		},
+	}
+}
+
+func (a *softAuthenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(webAuthnRPID))
+
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.counter)
+}
+
+func clientData(t *testing.T, ceremony string, challenge string) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]any{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    webAuthnOrigin,
+	})
+	require.NoError(t, err)
+
+	return data
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}